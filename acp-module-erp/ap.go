@@ -0,0 +1,931 @@
+package erp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/bitfantasy/acp-module-sdk"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===========================================================================
+// 应付明细账：SRM 结算单 → 应付发票(未清项) → 付款/付款批次 → 账龄
+//
+// 负债在采购入库时已按 po_receipt 规则贷记应付账款，应付发票只登记未清项
+// 与到期日，不再生成凭证；付款按 ap_settlement / ap_fx_diff 规则借应付、
+// 贷银行，外币差额记汇兑损益。
+// ===========================================================================
+
+// AP invoice statuses.
+const (
+	apInvoiceOpen          = "open"
+	apInvoicePartiallyPaid = "partially_paid"
+	apInvoicePaid          = "paid"
+	apInvoiceCancelled     = "cancelled"
+)
+
+var apOpenStatuses = []string{apInvoiceOpen, apInvoicePartiallyPaid}
+
+// Payment run statuses.
+const (
+	paymentRunProposed = "proposed"
+	paymentRunPaid     = "paid"
+)
+
+// apOpenAmount is the unpaid functional amount of an invoice.
+func apOpenAmount(inv *ErpAPInvoice) float64 {
+	return roundAmount(inv.Total - inv.PaidAmount)
+}
+
+// srmSupplierLite mirrors the SRM supplier columns AP needs.
+type srmSupplierLite struct {
+	ID           string
+	Code         string
+	Name         string
+	PaymentTerms string
+	BankName     string
+	BankAccount  string
+}
+
+func (srmSupplierLite) TableName() string { return "srm_suppliers" }
+
+func loadSupplier(db *gorm.DB, supplierID string) srmSupplierLite {
+	var s srmSupplierLite
+	db.Table("srm_suppliers").Select("id, code, name, payment_terms, bank_name, bank_account").
+		Where("id = ?", supplierID).Scan(&s)
+	return s
+}
+
+var termDaysPattern = regexp.MustCompile(`\d+`)
+
+// paymentTermDue derives the due date from free-text SRM payment terms:
+// NET30 / 30天 → invoice date + 30; 月结30天 / EOM30 → month end + 30;
+// COD / 预付 / 现结 → invoice date. Empty terms default to NET30.
+func paymentTermDue(terms string, invoiceDate time.Time) time.Time {
+	t := strings.ToUpper(strings.TrimSpace(terms))
+	if t == "" {
+		return invoiceDate.AddDate(0, 0, 30)
+	}
+	for _, immediate := range []string{"COD", "PREPAID", "预付", "货到付款", "现结"} {
+		if strings.Contains(t, immediate) {
+			return invoiceDate
+		}
+	}
+	days := 30
+	if m := termDaysPattern.FindString(t); m != "" {
+		days, _ = strconv.Atoi(m)
+	}
+	base := invoiceDate
+	if strings.Contains(t, "月结") || strings.Contains(t, "EOM") {
+		base = time.Date(invoiceDate.Year(), invoiceDate.Month()+1, 0, 0, 0, 0, 0, invoiceDate.Location())
+	}
+	return base.AddDate(0, 0, days)
+}
+
+// functionalCurrency is the currency journal amounts are kept in.
+const functionalCurrency = "CNY"
+
+// settlementAmounts resolves the functional amounts of a settlement: cash is
+// paid at the settlement rate, AP is carried at the PO-locked rates and the
+// difference is the realized FX diff. An exchange_rate in input reprices the
+// cash side against the same booked AP, so the FX diff follows the rate;
+// fx_diff in input overrides it outright.
+func settlementAmounts(stl *srmSettlementLite, input map[string]any) (rate, cash, fxDiff, ap float64, err error) {
+	rate = 1.0
+	if stl.ExchangeRate != nil && *stl.ExchangeRate > 0 {
+		rate = *stl.ExchangeRate
+	}
+	cash = math.Round(stl.FinalAmount*rate*100) / 100
+	if stl.FunctionalAmount != nil {
+		cash = *stl.FunctionalAmount
+	}
+	fxDiff = derefFloat(stl.FXDiff)
+	if override := getFloat(input, "exchange_rate"); override > 0 {
+		booked := cash - fxDiff
+		rate = override
+		cash = math.Round(stl.FinalAmount*rate*100) / 100
+		fxDiff = cash - booked
+	}
+	if _, ok := input["fx_diff"]; ok {
+		fxDiff = getFloat(input, "fx_diff")
+	}
+	fxDiff = math.Round(fxDiff*100) / 100
+	ap = math.Round((cash-fxDiff)*100) / 100
+	if cash <= 0 || ap <= 0 {
+		return 0, 0, 0, 0, fmt.Errorf("invalid functional amounts: cash %.2f, AP %.2f", cash, ap)
+	}
+	return rate, cash, fxDiff, ap, nil
+}
+
+func loadSettlement(db *gorm.DB, settlementID string) (*srmSettlementLite, error) {
+	var stl srmSettlementLite
+	if err := db.Table("srm_settlements").
+		Select("id, settlement_code, supplier_id, final_amount, currency, exchange_rate, functional_amount, fx_diff, invoice_no, status, period_start, period_end").
+		Where("id = ?", settlementID).
+		Scan(&stl).Error; err != nil || stl.ID == "" {
+		return nil, fmt.Errorf("settlement not found: %s", settlementID)
+	}
+	if stl.FinalAmount <= 0 {
+		return nil, fmt.Errorf("settlement final_amount must be positive, got %.2f", stl.FinalAmount)
+	}
+	if stl.Currency == "" {
+		stl.Currency = "CNY"
+	}
+	return &stl, nil
+}
+
+// createAPInvoice fills due date and code and writes the invoice.
+func createAPInvoice(tx *gorm.DB, inv *ErpAPInvoice) error {
+	if inv.Total <= 0 {
+		return fmt.Errorf("invoice total must be positive")
+	}
+	now := time.Now()
+	if inv.InvoiceDate == nil {
+		inv.InvoiceDate = &now
+	}
+	if inv.PaymentTerms == "" {
+		inv.PaymentTerms = loadSupplier(tx, inv.SupplierID).PaymentTerms
+	}
+	if inv.DueDate == nil {
+		due := paymentTermDue(inv.PaymentTerms, *inv.InvoiceDate)
+		inv.DueDate = &due
+	}
+	if inv.Currency == "" {
+		inv.Currency = "CNY"
+	}
+	if inv.OriginalAmount <= 0 {
+		inv.OriginalAmount = inv.Total
+	}
+	if inv.ExchangeRate <= 0 {
+		inv.ExchangeRate = inv.Total / inv.OriginalAmount
+	}
+	inv.Status = apInvoiceOpen
+	_, err := autoCodeWithRetry(tx, "erp_ap_invoices", "API", func(c string) error {
+		inv.ID = uuid.New().String()
+		inv.Code = c
+		return tx.Create(inv).Error
+	})
+	return err
+}
+
+// invoiceFromSettlement registers the open item of a settlement, carried at
+// the booked (PO-rate) amount. Idempotent per settlement.
+func invoiceFromSettlement(tx *gorm.DB, stl *srmSettlementLite, input map[string]any) (*ErpAPInvoice, error) {
+	var inv ErpAPInvoice
+	if tx.Where("settlement_id = ? AND status <> ?", stl.ID, apInvoiceCancelled).First(&inv).Error == nil {
+		return &inv, nil
+	}
+	_, _, _, ap, err := settlementAmounts(stl, input)
+	if err != nil {
+		return nil, err
+	}
+	inv = ErpAPInvoice{
+		SupplierID:     stl.SupplierID,
+		SettlementID:   stl.ID,
+		InvoiceNo:      stl.InvoiceNo,
+		InvoiceDate:    parseDate(getStr(input, "invoice_date")),
+		PaymentTerms:   getStr(input, "payment_terms"),
+		DueDate:        parseDate(getStr(input, "due_date")),
+		Currency:       stl.Currency,
+		OriginalAmount: stl.FinalAmount,
+		Total:          ap,
+		Notes:          getStr(input, "notes"),
+		CreatedBy:      getStr(input, "created_by"),
+	}
+	if inv.InvoiceDate == nil {
+		inv.InvoiceDate = stl.PeriodEnd
+	}
+	if err := createAPInvoice(tx, &inv); err != nil {
+		return nil, fmt.Errorf("create AP invoice failed: %w", err)
+	}
+	return &inv, nil
+}
+
+// apPaymentLines builds DR 应付 / (汇兑损益) / CR 银行 from the ap_settlement
+// and ap_fx_diff rules. FX loss is debited, gain credited.
+func apPaymentLines(tx *gorm.DB, pay *ErpAPPayment, label string) ([]ErpJournalLine, error) {
+	apRule, err := resolvePostingRule(tx, postingContext{TxType: postingTxAPSettlement})
+	if err != nil {
+		return nil, err
+	}
+	if apRule == nil {
+		return nil, fmt.Errorf("no active posting rule for %s", postingTxAPSettlement)
+	}
+	apAccount, err := postableAccount(tx, apRule.DebitAccount)
+	if err != nil {
+		return nil, err
+	}
+	cashAccount, err := postableAccount(tx, apRule.CreditAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分录金额均为本位币，外币付款的原币金额记在摘要里
+	foreign := ""
+	if pay.Currency != functionalCurrency {
+		foreign = fmt.Sprintf(" (%s %.2f)", pay.Currency, pay.OriginalAmount)
+	}
+	lines := []ErpJournalLine{{
+		AccountID:      apAccount.ID,
+		Debit:          pay.Amount,
+		Currency:       functionalCurrency,
+		OriginalAmount: pay.Amount,
+		Description:    label + " 应付账款冲销" + foreign,
+		SupplierID:     pay.SupplierID,
+	}}
+	if pay.FXDiff != 0 {
+		fxRule, err := resolvePostingRule(tx, postingContext{TxType: postingTxAPFXDiff})
+		if err != nil {
+			return nil, err
+		}
+		if fxRule == nil {
+			return nil, fmt.Errorf("no active posting rule for %s", postingTxAPFXDiff)
+		}
+		fxCode := fxRule.DebitAccount
+		if pay.FXDiff < 0 {
+			fxCode = fxRule.CreditAccount
+		}
+		fxAccount, err := postableAccount(tx, fxCode)
+		if err != nil {
+			return nil, err
+		}
+		rate := 1.0
+		if pay.OriginalAmount > 0 {
+			rate = pay.CashAmount / pay.OriginalAmount
+		}
+		line := ErpJournalLine{
+			AccountID:      fxAccount.ID,
+			Currency:       functionalCurrency,
+			OriginalAmount: math.Abs(pay.FXDiff),
+			Description:    fmt.Sprintf("%s 已实现汇兑损益 (%s @ %.6f)", label, pay.Currency, rate),
+			SupplierID:     pay.SupplierID,
+		}
+		if pay.FXDiff > 0 {
+			line.Debit = pay.FXDiff
+		} else {
+			line.Credit = -pay.FXDiff
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, ErpJournalLine{
+		AccountID:      cashAccount.ID,
+		Credit:         pay.CashAmount,
+		Currency:       functionalCurrency,
+		OriginalAmount: pay.CashAmount,
+		Description:    label + " 银行付款" + foreign,
+		SupplierID:     pay.SupplierID,
+	})
+	return lines, nil
+}
+
+// applyAPPayment writes the payment, clears the allocated invoices and posts
+// the payment entry. Allocations must sum to pay.Amount, may not exceed an
+// invoice's open amount and must be in the payment currency; the invoices
+// are locked for the rest of the transaction. entry carries
+// source/description; empty fields default to the payment.
+func applyAPPayment(tx *gorm.DB, pay *ErpAPPayment, allocs []ErpAPPaymentAllocation, entry *ErpJournalEntry) error {
+	pay.Amount = roundAmount(pay.Amount)
+	if pay.Amount <= 0 {
+		return fmt.Errorf("payment amount must be positive")
+	}
+	var sum float64
+	for _, a := range allocs {
+		sum += a.Amount
+	}
+	if math.Abs(roundAmount(sum)-pay.Amount) >= 0.005 {
+		return fmt.Errorf("allocations %.2f do not match payment amount %.2f", sum, pay.Amount)
+	}
+	if pay.CashAmount == 0 {
+		pay.CashAmount = roundAmount(pay.Amount + pay.FXDiff)
+	}
+	if pay.Currency == "" {
+		pay.Currency = "CNY"
+	}
+	if pay.OriginalAmount <= 0 {
+		pay.OriginalAmount = pay.CashAmount
+	}
+	if pay.PaidDate == nil {
+		now := time.Now()
+		pay.PaidDate = &now
+	}
+	if pay.BankAccount == "" {
+		s := loadSupplier(tx, pay.SupplierID)
+		pay.BankName, pay.BankAccount = s.BankName, s.BankAccount
+	}
+	pay.Status = "posted"
+	if _, err := autoCodeWithRetry(tx, "erp_ap_payments", "APP", func(c string) error {
+		pay.ID = uuid.New().String()
+		pay.Code = c
+		return tx.Create(pay).Error
+	}); err != nil {
+		return fmt.Errorf("create AP payment failed: %w", err)
+	}
+
+	for _, a := range allocs {
+		var inv ErpAPInvoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, "id = ?", a.InvoiceID).Error; err != nil {
+			return fmt.Errorf("AP invoice not found: %s", a.InvoiceID)
+		}
+		if inv.SupplierID != pay.SupplierID {
+			return fmt.Errorf("AP invoice %s belongs to another supplier", inv.Code)
+		}
+		if inv.Currency != pay.Currency {
+			return fmt.Errorf("AP invoice %s is in %s, payment is in %s", inv.Code, inv.Currency, pay.Currency)
+		}
+		if inv.Status != apInvoiceOpen && inv.Status != apInvoicePartiallyPaid {
+			return fmt.Errorf("AP invoice %s is %s", inv.Code, inv.Status)
+		}
+		if a.Amount > apOpenAmount(&inv)+0.005 {
+			return fmt.Errorf("allocation %.2f exceeds open amount %.2f of %s", a.Amount, apOpenAmount(&inv), inv.Code)
+		}
+		a.ID, a.PaymentID = uuid.New().String(), pay.ID
+		if err := tx.Create(&a).Error; err != nil {
+			return err
+		}
+		paid := roundAmount(inv.PaidAmount + a.Amount)
+		status := apInvoicePartiallyPaid
+		if paid >= inv.Total-0.005 {
+			status = apInvoicePaid
+		}
+		if err := tx.Model(&inv).Updates(map[string]any{"paid_amount": paid, "status": status}).Error; err != nil {
+			return err
+		}
+	}
+
+	lines, err := apPaymentLines(tx, pay, pay.Code)
+	if err != nil {
+		return err
+	}
+	if entry.SourceType == "" {
+		entry.SourceType, entry.SourceID = "ap_payment", pay.ID
+	}
+	if entry.Description == "" {
+		entry.Description = fmt.Sprintf("供应商付款 %s", pay.Code)
+	}
+	if entry.EntryDate == nil {
+		entry.EntryDate = pay.PaidDate
+	}
+	if err := createPostedEntry(tx, entry, lines); err != nil {
+		return err
+	}
+	pay.JournalEntryID = entry.ID
+	return tx.Model(pay).Update("journal_entry_id", entry.ID).Error
+}
+
+// fifoAPAllocations spreads amount over the supplier's open invoices in the
+// payment currency by due date. Overpayment (prepayment) is rejected.
+func fifoAPAllocations(tx *gorm.DB, supplierID, currency string, amount float64) ([]ErpAPPaymentAllocation, error) {
+	var invoices []ErpAPInvoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("supplier_id = ? AND currency = ? AND status IN ?", supplierID, currency, apOpenStatuses).
+		Order("due_date ASC, created_at ASC").Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	var allocs []ErpAPPaymentAllocation
+	remaining := roundAmount(amount)
+	for i := range invoices {
+		if remaining <= 0 {
+			break
+		}
+		amt := math.Min(apOpenAmount(&invoices[i]), remaining)
+		if amt <= 0 {
+			continue
+		}
+		allocs = append(allocs, ErpAPPaymentAllocation{InvoiceID: invoices[i].ID, Amount: amt})
+		remaining = roundAmount(remaining - amt)
+	}
+	if remaining > 0.005 {
+		return nil, fmt.Errorf("payment exceeds open %s payables of supplier by %.2f", currency, remaining)
+	}
+	return allocs, nil
+}
+
+// ---------------------------------------------------------------------------
+// Commands
+// ---------------------------------------------------------------------------
+
+// cmdCreateAPInvoice registers a supplier invoice, either from an SRM
+// settlement (settlement_id) or manually (supplier_id + amount). The due date
+// follows the supplier's payment terms unless given.
+func cmdCreateAPInvoice(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	var inv *ErpAPInvoice
+	if settlementID := getStr(input, "settlement_id"); settlementID != "" {
+		stl, err := loadSettlement(db, settlementID)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			inv, err = invoiceFromSettlement(tx, stl, input)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		// SRM 对账单已确认 → 已开票（跨模块表，失败不影响应付登记）
+		db.Table("srm_settlements").Where("id = ? AND status = ?", stl.ID, "confirmed").
+			Updates(map[string]any{"status": "invoiced", "updated_at": time.Now()})
+	} else {
+		supplierID := getStr(input, "supplier_id")
+		if supplierID == "" {
+			return nil, fmt.Errorf("settlement_id or supplier_id is required")
+		}
+		inv = &ErpAPInvoice{
+			SupplierID:     supplierID,
+			InvoiceNo:      getStr(input, "invoice_no"),
+			InvoiceDate:    parseDate(getStr(input, "invoice_date")),
+			PaymentTerms:   getStr(input, "payment_terms"),
+			DueDate:        parseDate(getStr(input, "due_date")),
+			Currency:       getStr(input, "currency"),
+			ExchangeRate:   getFloat(input, "exchange_rate"),
+			OriginalAmount: getFloat(input, "original_amount"),
+			Total:          roundAmount(getFloat(input, "amount")),
+			Notes:          getStr(input, "notes"),
+			CreatedBy:      getStr(input, "created_by"),
+		}
+		if err := createAPInvoice(db, inv); err != nil {
+			return nil, fmt.Errorf("create AP invoice failed: %w", err)
+		}
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.ap.invoice_created",
+		fmt.Sprintf("应付发票: %s, 金额 ¥%.2f, 到期 %s", inv.Code, inv.Total, inv.DueDate.Format("2006-01-02")),
+		map[string]any{"invoice_id": inv.ID, "code": inv.Code, "supplier_id": inv.SupplierID, "total": inv.Total})
+	return map[string]any{
+		"invoice_id":  inv.ID,
+		"code":        inv.Code,
+		"supplier_id": inv.SupplierID,
+		"total":       inv.Total,
+		"open_amount": apOpenAmount(inv),
+		"due_date":    inv.DueDate.Format("2006-01-02"),
+		"status":      inv.Status,
+	}, nil
+}
+
+// cmdRecordAPPayment pays a supplier. amount is the AP relieved (booked
+// amount); fx_diff is the realized FX loss (>0) or gain (<0). Without
+// allocations the payment clears open invoices by due date.
+func cmdRecordAPPayment(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	supplierID := getStr(input, "supplier_id")
+	if supplierID == "" {
+		return nil, fmt.Errorf("supplier_id is required")
+	}
+	amount := roundAmount(getFloat(input, "amount"))
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	pay := ErpAPPayment{
+		SupplierID:     supplierID,
+		Amount:         amount,
+		FXDiff:         roundAmount(getFloat(input, "fx_diff")),
+		Currency:       getStr(input, "currency"),
+		OriginalAmount: getFloat(input, "original_amount"),
+		PaymentMethod:  getStr(input, "payment_method"),
+		BankName:       getStr(input, "bank_name"),
+		BankAccount:    getStr(input, "bank_account"),
+		ReferenceNo:    getStr(input, "reference_no"),
+		PaidDate:       parseDate(getStr(input, "paid_date")),
+		Notes:          getStr(input, "notes"),
+	}
+	if pay.PaymentMethod == "" {
+		pay.PaymentMethod = "bank_transfer"
+	}
+	if pay.Currency == "" {
+		pay.Currency = "CNY"
+	}
+	var allocs []ErpAPPaymentAllocation
+	for _, a := range getMapSlice(input, "allocations") {
+		if id, amt := getStr(a, "invoice_id"), getFloat(a, "amount"); id != "" && amt > 0 {
+			allocs = append(allocs, ErpAPPaymentAllocation{InvoiceID: id, Amount: roundAmount(amt)})
+		}
+	}
+
+	entry := ErpJournalEntry{PostedBy: getStr(input, "posted_by")}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(allocs) == 0 {
+			var err error
+			if allocs, err = fifoAPAllocations(tx, supplierID, pay.Currency, amount); err != nil {
+				return err
+			}
+		}
+		return applyAPPayment(tx, &pay, allocs, &entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record AP payment failed: %w", err)
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.ap.payment_recorded",
+		fmt.Sprintf("供应商付款: %s, 冲销应付 ¥%.2f, 付款 ¥%.2f", pay.Code, pay.Amount, pay.CashAmount),
+		map[string]any{"payment_id": pay.ID, "code": pay.Code, "supplier_id": supplierID, "amount": pay.Amount, "journal_entry_id": entry.ID})
+	return map[string]any{
+		"payment_id":       pay.ID,
+		"code":             pay.Code,
+		"amount":           pay.Amount,
+		"cash_amount":      pay.CashAmount,
+		"fx_diff":          pay.FXDiff,
+		"allocations":      len(allocs),
+		"journal_entry_id": entry.ID,
+		"journal_code":     entry.Code,
+	}, nil
+}
+
+// cmdProposePaymentRun proposes every open invoice due on or before the
+// cut-off date (default: one week ahead) that is not already in another
+// proposed run.
+func cmdProposePaymentRun(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	now := time.Now()
+	cutoff := parseDate(getStr(input, "cutoff_date"))
+	if cutoff == nil {
+		d := now.AddDate(0, 0, 7)
+		cutoff = &d
+	}
+	paymentDate := parseDate(getStr(input, "payment_date"))
+	if paymentDate == nil {
+		paymentDate = &now
+	}
+	currency := getStr(input, "currency")
+	if currency == "" {
+		currency = "CNY"
+	}
+
+	q := db.Where("status IN ? AND currency = ? AND (due_date IS NULL OR due_date <= ?)", apOpenStatuses, currency, *cutoff).
+		Where("id NOT IN (?)", db.Table("erp_payment_run_items AS i").
+			Joins("JOIN erp_payment_runs r ON r.id = i.run_id").
+			Where("r.status = ? AND i.selected", paymentRunProposed).Select("i.invoice_id"))
+	if ids := getStrSlice(input, "supplier_ids"); len(ids) > 0 {
+		q = q.Where("supplier_id IN ?", ids)
+	}
+	var invoices []ErpAPInvoice
+	if err := q.Order("supplier_id, due_date").Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	if len(invoices) == 0 {
+		return nil, fmt.Errorf("no open payables due by %s", cutoff.Format("2006-01-02"))
+	}
+
+	run := ErpPaymentRun{
+		CutoffDate:  cutoff,
+		PaymentDate: paymentDate,
+		Currency:    currency,
+		Status:      paymentRunProposed,
+		CreatedBy:   getStr(input, "created_by"),
+		Notes:       getStr(input, "notes"),
+	}
+	suppliers := map[string]bool{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, inv := range invoices {
+			run.TotalAmount += apOpenAmount(&inv)
+			suppliers[inv.SupplierID] = true
+		}
+		run.TotalAmount = roundAmount(run.TotalAmount)
+		run.ItemCount = len(invoices)
+		if _, err := autoCodeWithRetry(tx, "erp_payment_runs", "PR", func(c string) error {
+			run.ID = uuid.New().String()
+			run.Code = c
+			return tx.Create(&run).Error
+		}); err != nil {
+			return err
+		}
+		for _, inv := range invoices {
+			open := apOpenAmount(&inv)
+			if err := tx.Create(&ErpPaymentRunItem{
+				ID:         uuid.New().String(),
+				RunID:      run.ID,
+				InvoiceID:  inv.ID,
+				SupplierID: inv.SupplierID,
+				DueDate:    inv.DueDate,
+				OpenAmount: open,
+				PayAmount:  open,
+				Selected:   true,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("propose payment run failed: %w", err)
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.ap.payment_run_proposed",
+		fmt.Sprintf("付款建议: %s, %d 张发票, %d 个供应商, ¥%.2f (截止 %s)", run.Code, run.ItemCount, len(suppliers), run.TotalAmount, cutoff.Format("2006-01-02")),
+		map[string]any{"run_id": run.ID, "code": run.Code, "total_amount": run.TotalAmount})
+	return map[string]any{
+		"run_id":         run.ID,
+		"code":           run.Code,
+		"cutoff_date":    cutoff.Format("2006-01-02"),
+		"item_count":     run.ItemCount,
+		"supplier_count": len(suppliers),
+		"total_amount":   run.TotalAmount,
+		"export_url":     fmt.Sprintf("/api/m/erp/payment-runs/%s/export", run.ID),
+	}, nil
+}
+
+// cmdExecutePaymentRun approves a proposed run: excluded invoices are
+// deselected, each supplier gets one payment for its selected items and the
+// run is marked paid. Amounts are re-checked against the current open
+// amount in case invoices were paid since the proposal. Foreign-currency
+// runs pay at the booked rate; realized FX goes through record_ap_payment.
+func cmdExecutePaymentRun(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	id := getStr(input, "run_id")
+	if id == "" {
+		return nil, fmt.Errorf("run_id is required")
+	}
+	var run ErpPaymentRun
+	excluded := map[string]bool{}
+	for _, invID := range getStrSlice(input, "exclude_invoice_ids") {
+		excluded[invID] = true
+	}
+
+	var payments []ErpAPPayment
+	var total float64
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 锁定付款批次，防止并发执行重复付款
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&run, "id = ?", id).Error; err != nil {
+			return fmt.Errorf("payment run not found")
+		}
+		if run.Status != paymentRunProposed {
+			return fmt.Errorf("payment run %s is %s", run.Code, run.Status)
+		}
+		var items []ErpPaymentRunItem
+		tx.Where("run_id = ? AND selected", run.ID).Order("supplier_id, due_date").Find(&items)
+		bySupplier := map[string][]*ErpPaymentRunItem{}
+		var order []string
+		for i := range items {
+			it := &items[i]
+			var inv ErpAPInvoice
+			if excluded[it.InvoiceID] || tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inv, "id = ?", it.InvoiceID).Error != nil {
+				tx.Model(it).Update("selected", false)
+				continue
+			}
+			if open := apOpenAmount(&inv); it.PayAmount > open {
+				it.PayAmount = open
+			}
+			if it.PayAmount <= 0 || (inv.Status != apInvoiceOpen && inv.Status != apInvoicePartiallyPaid) {
+				tx.Model(it).Updates(map[string]any{"selected": false, "pay_amount": 0})
+				continue
+			}
+			if _, ok := bySupplier[it.SupplierID]; !ok {
+				order = append(order, it.SupplierID)
+			}
+			bySupplier[it.SupplierID] = append(bySupplier[it.SupplierID], it)
+		}
+		if len(order) == 0 {
+			return fmt.Errorf("payment run %s has nothing left to pay", run.Code)
+		}
+
+		for _, supplierID := range order {
+			pay := ErpAPPayment{
+				SupplierID:    supplierID,
+				PaymentRunID:  run.ID,
+				Currency:      run.Currency,
+				PaymentMethod: "bank_transfer",
+				ReferenceNo:   getStr(input, "reference_no"),
+				PaidDate:      run.PaymentDate,
+			}
+			var allocs []ErpAPPaymentAllocation
+			var original float64
+			for _, it := range bySupplier[supplierID] {
+				pay.Amount += it.PayAmount
+				allocs = append(allocs, ErpAPPaymentAllocation{InvoiceID: it.InvoiceID, Amount: it.PayAmount})
+				original += apItemOriginal(tx, it)
+			}
+			pay.OriginalAmount = roundAmount(original)
+			entry := ErpJournalEntry{
+				Description: fmt.Sprintf("付款批次 %s", run.Code),
+				PostedBy:    getStr(input, "approved_by"),
+			}
+			if err := applyAPPayment(tx, &pay, allocs, &entry); err != nil {
+				return err
+			}
+			for _, it := range bySupplier[supplierID] {
+				if err := tx.Model(it).Updates(map[string]any{"pay_amount": it.PayAmount, "payment_id": pay.ID}).Error; err != nil {
+					return err
+				}
+			}
+			total += pay.Amount
+			payments = append(payments, pay)
+		}
+		return tx.Model(&run).Updates(map[string]any{
+			"status":       paymentRunPaid,
+			"approved_by":  getStr(input, "approved_by"),
+			"executed_at":  &now,
+			"total_amount": roundAmount(total),
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute payment run failed: %w", err)
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.ap.payment_run_executed",
+		fmt.Sprintf("付款批次执行: %s, %d 笔付款, ¥%.2f", run.Code, len(payments), total),
+		map[string]any{"run_id": run.ID, "code": run.Code, "payments": len(payments), "total_amount": roundAmount(total)})
+	paymentIDs := make([]string, 0, len(payments))
+	for _, p := range payments {
+		paymentIDs = append(paymentIDs, p.ID)
+	}
+	return map[string]any{
+		"run_id":       run.ID,
+		"code":         run.Code,
+		"status":       paymentRunPaid,
+		"payment_ids":  paymentIDs,
+		"total_amount": roundAmount(total),
+		"export_url":   fmt.Sprintf("/api/m/erp/payment-runs/%s/export", run.ID),
+	}, nil
+}
+
+// apItemOriginal converts a run item's functional pay amount back to the
+// invoice currency at the invoice's booked rate.
+func apItemOriginal(db *gorm.DB, it *ErpPaymentRunItem) float64 {
+	var inv ErpAPInvoice
+	if db.First(&inv, "id = ?", it.InvoiceID).Error != nil || inv.Total <= 0 {
+		return it.PayAmount
+	}
+	return roundAmount(it.PayAmount * inv.OriginalAmount / inv.Total)
+}
+
+// ---------------------------------------------------------------------------
+// Aging & bank file
+// ---------------------------------------------------------------------------
+
+type apAgingRow struct {
+	SupplierID    string  `json:"supplier_id"`
+	SupplierCode  string  `json:"supplier_code"`
+	SupplierName  string  `json:"supplier_name"`
+	DueNext7      float64 `json:"due_next_7"`
+	DueNext30     float64 `json:"due_next_30"`
+	DueLater      float64 `json:"due_later"`
+	Overdue30     float64 `json:"overdue_30"`
+	Overdue60     float64 `json:"overdue_60"`
+	Overdue90     float64 `json:"overdue_90"`
+	OverdueOver90 float64 `json:"overdue_over_90"`
+	Total         float64 `json:"total"`
+	InvoiceCount  int     `json:"invoice_count"`
+}
+
+func (r *apAgingRow) add(o apAgingRow) {
+	r.DueNext7 += o.DueNext7
+	r.DueNext30 += o.DueNext30
+	r.DueLater += o.DueLater
+	r.Overdue30 += o.Overdue30
+	r.Overdue60 += o.Overdue60
+	r.Overdue90 += o.Overdue90
+	r.OverdueOver90 += o.OverdueOver90
+	r.Total += o.Total
+	r.InvoiceCount += o.InvoiceCount
+}
+
+// apAging buckets open payables per supplier as of a date: not yet due
+// (within 7 / 30 days / later) and overdue (1-30 / 31-60 / 61-90 / >90).
+func apAging(db *gorm.DB, asOf time.Time) ([]apAgingRow, apAgingRow) {
+	asOf = time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, asOf.Location())
+	var invoices []ErpAPInvoice
+	db.Where("status IN ?", apOpenStatuses).Find(&invoices)
+
+	rows := map[string]*apAgingRow{}
+	for i := range invoices {
+		inv := &invoices[i]
+		open := apOpenAmount(inv)
+		if open <= 0 {
+			continue
+		}
+		var b apAgingRow
+		b.Total, b.InvoiceCount = open, 1
+		days := 0
+		if inv.DueDate != nil {
+			due := time.Date(inv.DueDate.Year(), inv.DueDate.Month(), inv.DueDate.Day(), 0, 0, 0, 0, asOf.Location())
+			days = int(asOf.Sub(due).Hours() / 24)
+		}
+		switch {
+		case days <= -31:
+			b.DueLater = open
+		case days <= -8:
+			b.DueNext30 = open
+		case days <= 0:
+			b.DueNext7 = open
+		case days <= 30:
+			b.Overdue30 = open
+		case days <= 60:
+			b.Overdue60 = open
+		case days <= 90:
+			b.Overdue90 = open
+		default:
+			b.OverdueOver90 = open
+		}
+		r, ok := rows[inv.SupplierID]
+		if !ok {
+			s := loadSupplier(db, inv.SupplierID)
+			r = &apAgingRow{SupplierID: inv.SupplierID, SupplierCode: s.Code, SupplierName: s.Name}
+			rows[inv.SupplierID] = r
+		}
+		r.add(b)
+	}
+
+	var total apAgingRow
+	out := make([]apAgingRow, 0, len(rows))
+	for _, r := range rows {
+		for _, v := range []*float64{&r.DueNext7, &r.DueNext30, &r.DueLater, &r.Overdue30, &r.Overdue60, &r.Overdue90, &r.OverdueOver90, &r.Total} {
+			*v = roundAmount(*v)
+		}
+		total.add(*r)
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Total > out[j].Total })
+	return out, total
+}
+
+// paymentRunBankFile renders a run as a bank bulk-payment import CSV (UTF-8
+// with BOM so Excel and bank portals detect the encoding), one transfer per
+// supplier. Suppliers without a bank account make the export fail.
+func paymentRunBankFile(db *gorm.DB, run *ErpPaymentRun) ([]byte, string, error) {
+	var items []ErpPaymentRunItem
+	db.Where("run_id = ? AND selected AND pay_amount > 0", run.ID).Order("supplier_id, due_date").Find(&items)
+	if len(items) == 0 {
+		return nil, "", fmt.Errorf("payment run %s has no selected items", run.Code)
+	}
+
+	type transfer struct {
+		supplier srmSupplierLite
+		amount   float64
+		invoices []string
+	}
+	var order []string
+	transfers := map[string]*transfer{}
+	for i := range items {
+		it := &items[i]
+		t, ok := transfers[it.SupplierID]
+		if !ok {
+			t = &transfer{supplier: loadSupplier(db, it.SupplierID)}
+			transfers[it.SupplierID] = t
+			order = append(order, it.SupplierID)
+		}
+		if run.Currency == "CNY" {
+			t.amount += it.PayAmount
+		} else {
+			t.amount += apItemOriginal(db, it)
+		}
+		var inv ErpAPInvoice
+		if db.First(&inv, "id = ?", it.InvoiceID).Error == nil {
+			ref := inv.InvoiceNo
+			if ref == "" {
+				ref = inv.Code
+			}
+			t.invoices = append(t.invoices, ref)
+		}
+	}
+
+	var missing []string
+	for _, id := range order {
+		if s := transfers[id].supplier; s.BankAccount == "" {
+			if s.Code == "" {
+				s.Code = id
+			}
+			missing = append(missing, s.Code)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, "", fmt.Errorf("suppliers without bank account: %s", strings.Join(missing, ", "))
+	}
+
+	payDate := time.Now()
+	if run.PaymentDate != nil {
+		payDate = *run.PaymentDate
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"付款参考号", "收款方编码", "收款方户名", "收款账号", "收款开户行", "金额", "币种", "付款日期", "用途"})
+	for n, id := range order {
+		t := transfers[id]
+		purpose := "货款 " + strings.Join(t.invoices, ",")
+		if r := []rune(purpose); len(r) > 60 {
+			purpose = string(r[:60])
+		}
+		w.Write([]string{
+			fmt.Sprintf("%s-%03d", run.Code, n+1),
+			t.supplier.Code,
+			t.supplier.Name,
+			t.supplier.BankAccount,
+			t.supplier.BankName,
+			strconv.FormatFloat(roundAmount(t.amount), 'f', 2, 64),
+			run.Currency,
+			payDate.Format("2006-01-02"),
+			purpose,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), fmt.Sprintf("payment_run_%s.csv", run.Code), nil
+}
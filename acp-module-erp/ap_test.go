@@ -0,0 +1,146 @@
+package erp
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TestPaymentTermDue SRM 自由文本付款条件推算到期日
+func TestPaymentTermDue(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	invoiced := day(2026, 3, 10)
+	cases := []struct {
+		terms   string
+		invoice time.Time
+		want    time.Time
+	}{
+		{"", invoiced, day(2026, 4, 9)},
+		{"NET30", invoiced, day(2026, 4, 9)},
+		{"net 45", invoiced, day(2026, 4, 24)},
+		{"30天", invoiced, day(2026, 4, 9)},
+		{"月结30天", invoiced, day(2026, 4, 30)},
+		{"月结", invoiced, day(2026, 4, 30)},
+		{"EOM60", invoiced, day(2026, 5, 30)},
+		{"月结30天", day(2026, 12, 15), day(2027, 1, 30)},
+		{"COD", invoiced, invoiced},
+		{"预付", invoiced, invoiced},
+		{"货到付款", invoiced, invoiced},
+	}
+	for _, tc := range cases {
+		t.Run(tc.terms+"@"+tc.invoice.Format("2006-01-02"), func(t *testing.T) {
+			if got := paymentTermDue(tc.terms, tc.invoice); !got.Equal(tc.want) {
+				t.Fatalf("paymentTermDue(%q, %s) = %s, want %s", tc.terms, tc.invoice.Format("2006-01-02"),
+					got.Format("2006-01-02"), tc.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func createAPInvoiceForTest(t *testing.T, db *gorm.DB, input map[string]any) string {
+	t.Helper()
+	input["supplier_id"] = "sup-ap"
+	out, err := cmdCreateAPInvoice(db, nil, "", "", input)
+	if err != nil {
+		t.Fatalf("create AP invoice failed: %v", err)
+	}
+	return out.(map[string]any)["invoice_id"].(string)
+}
+
+func apInvoiceForTest(t *testing.T, db *gorm.DB, id string) ErpAPInvoice {
+	t.Helper()
+	var inv ErpAPInvoice
+	if err := db.First(&inv, "id = ?", id).Error; err != nil {
+		t.Fatalf("load AP invoice failed: %v", err)
+	}
+	return inv
+}
+
+// TestAPPaymentFlow 发票按供应商付款条件定到期日，付款按到期先后冲销，超付整笔回滚
+func TestAPPaymentFlow(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.Create(&srmSupplierLite{ID: "sup-ap", Code: "SUP-AP", Name: "应付测试供应商",
+		PaymentTerms: "NET30", BankName: "测试银行", BankAccount: "6222000011112222"}).Error; err != nil {
+		t.Fatalf("seed supplier failed: %v", err)
+	}
+
+	later := createAPInvoiceForTest(t, db, map[string]any{"amount": 100, "invoice_date": "2026-03-01"})
+	if due := apInvoiceForTest(t, db, later).DueDate.Format("2006-01-02"); due != "2026-03-31" {
+		t.Fatalf("expected supplier NET30 due date 2026-03-31, got %s", due)
+	}
+	earlier := createAPInvoiceForTest(t, db, map[string]any{"amount": 50, "invoice_date": "2026-03-10", "payment_terms": "COD"})
+
+	out, err := cmdRecordAPPayment(db, nil, "", "", map[string]any{"supplier_id": "sup-ap", "amount": 80})
+	if err != nil {
+		t.Fatalf("record payment failed: %v", err)
+	}
+	if inv := apInvoiceForTest(t, db, earlier); inv.Status != apInvoicePaid {
+		t.Fatalf("expected the earlier-due invoice paid, got %s", inv.Status)
+	}
+	if inv := apInvoiceForTest(t, db, later); inv.Status != apInvoicePartiallyPaid || inv.PaidAmount != 30 {
+		t.Fatalf("expected the later invoice partially paid 30, got %s %.2f", inv.Status, inv.PaidAmount)
+	}
+	var lines []ErpJournalLine
+	db.Where("entry_id = ?", out.(map[string]any)["journal_entry_id"]).Find(&lines)
+	var debit, credit float64
+	for _, l := range lines {
+		debit += l.Debit
+		credit += l.Credit
+	}
+	if len(lines) != 2 || debit != 80 || credit != 80 {
+		t.Fatalf("expected a balanced 80 entry with 2 lines, got %d lines %.2f/%.2f", len(lines), debit, credit)
+	}
+
+	if _, err := cmdRecordAPPayment(db, nil, "", "", map[string]any{"supplier_id": "sup-ap", "amount": 100}); err == nil ||
+		!strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("expected overpayment to be rejected, got %v", err)
+	}
+	var payments int64
+	db.Model(&ErpAPPayment{}).Count(&payments)
+	if payments != 1 {
+		t.Fatalf("expected the rejected payment to roll back, got %d payments", payments)
+	}
+}
+
+// TestAPForeignPayment 外币付款分录按本位币记账，汇兑损失单独记借方
+func TestAPForeignPayment(t *testing.T) {
+	db := setupTestDB(t)
+	invoiceID := createAPInvoiceForTest(t, db, map[string]any{
+		"amount": 700, "currency": "USD", "original_amount": 100, "payment_terms": "NET30",
+	})
+
+	out, err := cmdRecordAPPayment(db, nil, "", "", map[string]any{
+		"supplier_id": "sup-ap", "amount": 700, "fx_diff": 14, "currency": "USD", "original_amount": 100,
+		"bank_account": "USD-001",
+	})
+	if err != nil {
+		t.Fatalf("record payment failed: %v", err)
+	}
+	res := out.(map[string]any)
+	if res["cash_amount"] != 714.0 {
+		t.Fatalf("expected cash amount 714, got %v", res["cash_amount"])
+	}
+	if inv := apInvoiceForTest(t, db, invoiceID); inv.Status != apInvoicePaid {
+		t.Fatalf("expected invoice paid, got %s", inv.Status)
+	}
+
+	var lines []ErpJournalLine
+	db.Where("entry_id = ?", res["journal_entry_id"]).Order("debit DESC").Find(&lines)
+	if len(lines) != 3 {
+		t.Fatalf("expected AP, FX and bank lines, got %d", len(lines))
+	}
+	var debit, credit float64
+	for _, l := range lines {
+		if l.Currency != functionalCurrency || math.Abs(l.OriginalAmount-(l.Debit+l.Credit)) > 1e-9 {
+			t.Fatalf("expected %s line with original amount equal to the booked amount, got %+v", functionalCurrency, l)
+		}
+		debit += l.Debit
+		credit += l.Credit
+	}
+	if debit != 714 || credit != 714 {
+		t.Fatalf("expected a balanced 714 entry, got %.2f/%.2f", debit, credit)
+	}
+}
@@ -9,7 +9,7 @@ import sdk "github.com/bitfantasy/acp-module-sdk"
 func erpCommands() []sdk.CommandDef {
 	return []sdk.CommandDef{
 		// ── 销售管理 (9) ─────────────────────────────────────────────
-		{Name: "create_quotation", Label: "创建报价单", Description: "为客户创建销售报价单", InputType: CreateQuotationInput{}, OutputType: QuotationOutput{}},
+		{Name: "create_quotation", Label: "创建报价单", Description: "为客户创建销售报价单，未填单价按价目表取价，低于毛利阈值需审批", InputType: CreateQuotationInput{}, OutputType: QuotationOutput{}},
 		{Name: "confirm_quotation", Label: "确认报价单", Description: "确认报价单，自动生成销售订单", InputType: ConfirmQuotationInput{}, OutputType: ConfirmQuotationOutput{}},
 		{Name: "create_sales_order", Label: "创建销售订单", Description: "直接创建销售订单（不经过报价）", InputType: CreateSalesOrderInput{}, OutputType: SalesOrderOutput{}},
 		{Name: "confirm_order", Label: "确认订单", Description: "确认销售订单（触发MRP计算）", InputType: ConfirmOrderInput{}, OutputType: ConfirmOrderOutput{}},
@@ -28,7 +28,7 @@ func erpCommands() []sdk.CommandDef {
 		{Name: "reserve_inventory", Label: "库存预留", Description: "为订单行预留库存", InputType: ReserveInventoryInput{}, OutputType: ReservationOutput{}},
 		{Name: "unreserve_inventory", Label: "取消预留", Description: "取消订单行的库存预留", InputType: UnreserveInventoryInput{}, OutputType: ReservationOutput{}},
 		{Name: "bootstrap_default_warehouses", Label: "初始化默认仓库", Description: "一次性创建 6 个标准仓库 (原料/在制品/成品/备件/质量冻结/报废)，幂等", InputType: BootstrapWarehousesInput{}, OutputType: BootstrapWarehousesOutput{}},
-		{Name: "bootstrap_chart_of_accounts", Label: "初始化默认科目表", Description: "一次性创建 9 个标准会计科目 (1002/1403/1411/1405/2202/5301/5302/6401/6603)，幂等", InputType: BootstrapWarehousesInput{}, OutputType: BootstrapWarehousesOutput{}},
+		{Name: "bootstrap_chart_of_accounts", Label: "初始化默认科目表", Description: "一次性创建 10 个标准会计科目 (1002/1403/1404/1411/1405/2202/5301/5302/6401/6603) 及默认过账规则，幂等", InputType: BootstrapWarehousesInput{}, OutputType: BootstrapWarehousesOutput{}},
 
 		// ── 盘点 (4, Sprint 3) ───────────────────────────────────────
 		{Name: "create_inventory_count", Label: "创建盘点单", Description: "创建盘点单并冻结快照（full/cycle/spot）", InputType: CreateInventoryCountInput{}, OutputType: CreateInventoryCountOutput{}},
@@ -52,13 +52,27 @@ func erpCommands() []sdk.CommandDef {
 		{Name: "report_wo_progress", Label: "报工", Description: "报告工单工序生产进度", InputType: ReportWOProgressInput{}, OutputType: WOProgressOutput{}},
 		{Name: "complete_work_order", Label: "完工入库", Description: "工单完工并成品入库", InputType: CompleteWorkOrderInput{}, OutputType: WorkOrderOutput{}},
 
-		// ── 财务管理 (6) ─────────────────────────────────────────────
+		// ── 财务管理 (9) ─────────────────────────────────────────────
 		{Name: "create_journal_entry", Label: "创建凭证", Description: "创建会计记账凭证", InputType: CreateJournalEntryInput{}, OutputType: JournalEntryOutput{}},
 		{Name: "post_journal_entry", Label: "过账凭证", Description: "凭证过账（不可逆）", InputType: PostJournalEntryInput{}, OutputType: JournalEntryOutput{}},
 		{Name: "reverse_journal_entry", Label: "冲销凭证", Description: "生成红字冲销凭证", InputType: ReverseJournalEntryInput{}, OutputType: ReverseJournalEntryOutput{}},
 		{Name: "close_period", Label: "关闭会计期间", Description: "关闭指定会计期间", InputType: ClosePeriodInput{}, OutputType: ClosePeriodOutput{}},
-		{Name: "generate_report", Label: "生成财务报表", Description: "生成试算平衡表/利润表/资产负债表", InputType: GenerateReportInput{}, OutputType: GenerateReportOutput{}},
-		{Name: "post_ap_from_settlement", Label: "结算转AP凭证", Description: "从 SRM 结算单创建应付账款会计凭证(DR 应付账款 / CR 银行存款)", InputType: PostAPFromSettlementInput{}, OutputType: PostAPFromSettlementOutput{}},
+		{Name: "generate_report", Label: "生成财务报表", Description: "生成试算平衡表/利润表/资产负债表/现金流量表(间接法), 支持环比/同比累计对比及穿透到凭证行", InputType: GenerateReportInput{}, OutputType: GenerateReportOutput{}},
+		{Name: "post_ap_from_settlement", Label: "结算转AP凭证", Description: "从 SRM 结算单登记应付发票并整单付款过账(按过账规则 ap_settlement / ap_fx_diff 取科目, 外币结算差额记汇兑损益)", InputType: PostAPFromSettlementInput{}, OutputType: PostAPFromSettlementOutput{}},
+		{Name: "create_account", Label: "新增会计科目", Description: "新增科目, 可挂在上级科目下 (编码须以上级编码开头, 上级转为汇总科目)", InputType: CreateAccountInput{}, OutputType: CreateAccountOutput{}},
+		{Name: "save_posting_rule", Label: "保存过账规则", Description: "按 (事务类型, 仓库类型, 物料类别) 配置借贷科目, 校验科目为启用末级科目", InputType: SavePostingRuleInput{}, OutputType: PostingRuleOutput{}},
+		{Name: "post_by_rule", Label: "按规则过账", Description: "按过账规则为任意业务金额生成已过账凭证 (如运费、关税)", InputType: PostByRuleInput{}, OutputType: PostByRuleOutput{}},
+
+		// ── 应付管理 (4) ─────────────────────────────────────────────
+		{Name: "create_ap_invoice", Label: "登记应付发票", Description: "从 SRM 结算单或手工登记供应商发票, 按供应商付款条件计算到期日", InputType: CreateAPInvoiceInput{}, OutputType: APInvoiceOutput{}},
+		{Name: "record_ap_payment", Label: "供应商付款", Description: "登记供应商付款(支持部分付款), 按指定或到期日先后核销应付发票并过账", InputType: RecordAPPaymentInput{}, OutputType: APPaymentOutput{}},
+		{Name: "propose_payment_run", Label: "生成付款建议", Description: "按截止日汇总到期应付生成付款批次", InputType: ProposePaymentRunInput{}, OutputType: PaymentRunOutput{}},
+		{Name: "execute_payment_run", Label: "执行付款批次", Description: "审批付款批次, 按供应商生成付款单并核销过账, 可导出银行付款文件", InputType: ExecutePaymentRunInput{}, OutputType: PaymentRunOutput{}},
+
+		// ── 成本管理 (3) ─────────────────────────────────────────────
+		{Name: "rollup_standard_cost", Label: "标准成本卷算", Description: "按 PLM 已发布 BOM 与工艺路线逐层卷算材料/人工/制费标准成本, 生成待生效版本", InputType: RollupStandardCostInput{}, OutputType: RollupStandardCostOutput{}},
+		{Name: "run_cost_revaluation", Label: "标准成本重估", Description: "使待生效标准成本生效, 按仓库重估在库存货并过账 (cost_revaluation), 物料切换为标准成本计价", InputType: RunCostRevaluationInput{}, OutputType: RunCostRevaluationOutput{}},
+		{Name: "set_cost_method", Label: "切换计价方法", Description: "在移动加权平均与标准成本之间切换物料计价方法", InputType: SetCostMethodInput{}, OutputType: SetCostMethodOutput{}},
 
 		// ── 质量管理 (6) ─────────────────────────────────────────────
 		{Name: "create_oqc", Label: "创建OQC检验", Description: "创建出货质量检验单", InputType: CreateOQCInput{}, OutputType: OQCOutput{}},
@@ -68,10 +82,25 @@ func erpCommands() []sdk.CommandDef {
 		{Name: "create_capa", Label: "创建CAPA", Description: "创建纠正/预防措施", InputType: CreateCAPAInput{}, OutputType: CAPAOutput{}},
 		{Name: "close_capa", Label: "关闭CAPA", Description: "验证并关闭CAPA", InputType: CloseCAPAInput{}, OutputType: CAPAOutput{}},
 
+		// ── 定价与毛利 (2) ───────────────────────────────────────────
+		{Name: "quote_price", Label: "报价试算", Description: "按渠道/币种价目表、客户专属价、数量阶梯和促销取价，并按标准成本/BOM成本计算毛利", InputType: QuotePriceInput{}, OutputType: QuotePriceOutput{}},
+		{Name: "approve_quotation_margin", Label: "低毛利报价审批", Description: "审批毛利率低于渠道阈值的报价单，通过后方可确认", InputType: ApproveQuotationMarginInput{}, OutputType: ApproveQuotationMarginOutput{}},
+
 		// ── 补充命令 (3) ─────────────────────────────────────────────
 		{Name: "update_shipment_status", Label: "更新发货状态", Description: "更新发货单状态（shipped/delivered等）", InputType: UpdateShipmentStatusInput{}, OutputType: UpdateShipmentStatusOutput{}},
 		{Name: "extend_quotation", Label: "延长报价有效期", Description: "延长报价单有效期指定天数", InputType: ExtendQuotationInput{}, OutputType: ExtendQuotationOutput{}},
-		{Name: "send_payment_reminder", Label: "发送催款通知", Description: "对逾期发票发送催款通知", InputType: SendPaymentReminderInput{}, OutputType: SendPaymentReminderOutput{}},
+		{Name: "send_payment_reminder", Label: "发送催款通知", Description: "对逾期发票发送催款通知，按逾期天数定级并记录催款", InputType: SendPaymentReminderInput{}, OutputType: SendPaymentReminderOutput{}},
+
+		// ── 信用控制 (2) ─────────────────────────────────────────────
+		{Name: "release_credit_hold", Label: "信用特批", Description: "审批信用冻结/拒绝的销售订单：放行则确认订单并触发MRP，驳回则退回草稿", InputType: ReleaseCreditHoldInput{}, OutputType: ConfirmOrderOutput{}},
+		{Name: "run_dunning", Label: "催款运行", Description: "按逾期天数逐级升级催款并发送通知，达到冻结级别的客户自动信用冻结，逾期清零后解冻", InputType: RunDunningInput{}, OutputType: RunDunningOutput{}},
+
+		// ── 包装与物流 (5) ─────────────────────────────────────────────
+		{Name: "create_package", Label: "新建包装件", Description: "为发货单新建纸箱或托盘并分配 SSCC，纸箱可放到托盘上", InputType: CreatePackageInput{}, OutputType: PackageOutput{}},
+		{Name: "pack_items", Label: "装箱", Description: "扫描序列号或按批次数量装入纸箱，校验属于发货单且不超发货数量", InputType: PackItemsInput{}, OutputType: PackItemsOutput{}},
+		{Name: "unpack_items", Label: "撤回装箱", Description: "从未封箱的纸箱撤回序列号或明细行", InputType: UnpackItemsInput{}, OutputType: UnpackItemsOutput{}},
+		{Name: "seal_package", Label: "封箱", Description: "封箱并录入重量尺寸，全部装箱封箱后发货单变为已包装", InputType: SealPackageInput{}, OutputType: SealPackageOutput{}},
+		{Name: "generate_customs_invoice", Label: "生成报关发票", Description: "出口订单按发货明细、订单行价格与产品报关资料生成报关发票", InputType: GenerateCustomsInvoiceInput{}, OutputType: CustomsInvoiceOutput{}},
 	}
 }
 
@@ -93,25 +122,33 @@ type DeleteOutput struct {
 // ---------------------------------------------------------------------------
 
 type QuotationItemInput struct {
-	ProductID   string  `json:"product_id" desc:"产品ID"`
-	Quantity    float64 `json:"quantity" desc:"数量"`
-	UnitPrice   float64 `json:"unit_price" desc:"单价"`
-	DiscountPct float64 `json:"discount_pct,omitempty" desc:"折扣百分比(0-100)"`
-	TaxRate     float64 `json:"tax_rate,omitempty" desc:"税率(0-100)"`
+	ProductID   string   `json:"product_id" desc:"产品ID"`
+	Quantity    float64  `json:"quantity" desc:"数量"`
+	UnitPrice   float64  `json:"unit_price,omitempty" desc:"单价, 留空按价目表取价"`
+	DiscountPct *float64 `json:"discount_pct,omitempty" desc:"折扣百分比(0-100), 留空按有效促销, 填0不享受促销"`
+	TaxRate     float64  `json:"tax_rate,omitempty" desc:"税率(0-100)"`
 }
 
 type CreateQuotationInput struct {
-	CustomerID string               `json:"customer_id" desc:"客户ID"`
-	Items      []QuotationItemInput `json:"items" desc:"报价明细行"`
+	CustomerID   string               `json:"customer_id" desc:"客户ID"`
+	Channel      string               `json:"channel,omitempty" desc:"销售渠道, 默认按客户类型推断" enum:"direct,ecommerce,dealer,retail"`
+	Currency     string               `json:"currency,omitempty" desc:"币种, 默认 CNY"`
+	ExchangeRate float64              `json:"exchange_rate,omitempty" desc:"折本位币汇率, 默认 1"`
+	Items        []QuotationItemInput `json:"items" desc:"报价明细行"`
 }
 
 type QuotationOutput struct {
-	QuotationID string  `json:"quotation_id" desc:"报价单ID"`
-	Code        string  `json:"code" desc:"报价单编号"`
-	CustomerID  string  `json:"customer_id" desc:"客户ID"`
-	Status      string  `json:"status" desc:"状态: draft/confirmed/cancelled"`
-	TotalAmount float64 `json:"total_amount" desc:"总金额"`
-	URL         string  `json:"url" desc:"报价单链接"`
+	QuotationID      string  `json:"quotation_id" desc:"报价单ID"`
+	Code             string  `json:"code" desc:"报价单编号"`
+	CustomerID       string  `json:"customer_id" desc:"客户ID"`
+	Status           string  `json:"status" desc:"状态: draft/confirmed/cancelled"`
+	TotalAmount      float64 `json:"total_amount" desc:"总金额"`
+	Channel          string  `json:"channel" desc:"销售渠道"`
+	MarginPct        float64 `json:"margin_pct" desc:"整单毛利率%"`
+	ApprovalStatus   string  `json:"approval_status" desc:"毛利审批: 空(无需)/pending/approved/rejected"`
+	BelowMarginLines int     `json:"below_margin_lines" desc:"低于毛利阈值的行数"`
+	CostUnknownLines int     `json:"cost_unknown_lines" desc:"无成本无法校验毛利的行数"`
+	URL              string  `json:"url" desc:"报价单链接"`
 }
 
 type ConfirmQuotationInput struct {
@@ -137,11 +174,14 @@ type SalesOrderItemInput struct {
 }
 
 type CreateSalesOrderInput struct {
-	CustomerID      string                `json:"customer_id" desc:"客户ID"`
-	Items           []SalesOrderItemInput `json:"items" desc:"订单明细行"`
-	ExpectedDate    string                `json:"expected_date,omitempty" desc:"期望交货日期 YYYY-MM-DD"`
-	ShippingAddress string                `json:"shipping_address,omitempty" desc:"收货地址"`
-	PaymentTerms    string                `json:"payment_terms,omitempty" desc:"付款条件" enum:"prepaid,net30,net60,cod"`
+	CustomerID         string                `json:"customer_id" desc:"客户ID"`
+	Items              []SalesOrderItemInput `json:"items" desc:"订单明细行"`
+	ExpectedDate       string                `json:"expected_date,omitempty" desc:"期望交货日期 YYYY-MM-DD"`
+	ShippingAddress    string                `json:"shipping_address,omitempty" desc:"收货地址"`
+	PaymentTerms       string                `json:"payment_terms,omitempty" desc:"付款条件" enum:"prepaid,net30,net60,cod"`
+	IsExport           bool                  `json:"is_export,omitempty" desc:"是否出口订单(发货时生成报关发票)"`
+	Incoterm           string                `json:"incoterm,omitempty" desc:"贸易术语" enum:"EXW,FCA,FOB,CFR,CIF,DAP,DDP"`
+	DestinationCountry string                `json:"destination_country,omitempty" desc:"目的国"`
 }
 
 type SalesOrderOutput struct {
@@ -158,8 +198,14 @@ type ConfirmOrderInput struct {
 }
 
 type ConfirmOrderOutput struct {
-	OrderID string `json:"order_id" desc:"销售订单ID"`
-	Status  string `json:"status" desc:"确认后状态"`
+	OrderID        string  `json:"order_id" desc:"销售订单ID"`
+	Code           string  `json:"code" desc:"订单编号"`
+	Status         string  `json:"status" desc:"确认后状态: confirmed/credit_hold"`
+	CreditStatus   string  `json:"credit_status" desc:"信用检查结果: passed/held/blocked/released"`
+	CreditExposure float64 `json:"credit_exposure" desc:"确认前客户信用占用(未清应收+未发货订单+已发货未开票)"`
+	CreditLimit    float64 `json:"credit_limit" desc:"客户信用额度(0为不限)"`
+	Reason         string  `json:"reason" desc:"信用冻结原因"`
+	MRPSuggestions int     `json:"mrp_suggestions" desc:"MRP建议数"`
 }
 
 // ---------------------------------------------------------------------------
@@ -283,6 +329,8 @@ type InventoryTransactionOutput struct {
 	Type          string  `json:"type" desc:"事务类型: receive/issue/transfer/adjust/scrap"`
 	Quantity      float64 `json:"quantity" desc:"事务数量"`
 	OnHandAfter   float64 `json:"on_hand_after" desc:"事务后库存数量"`
+	StandardCost  float64 `json:"standard_cost,omitempty" desc:"标准成本 (标准成本物料入库时)"`
+	PriceVariance float64 `json:"price_variance,omitempty" desc:"采购价格差异 = 数量 × (实际单价 - 标准成本)"`
 }
 
 // ---------------------------------------------------------------------------
@@ -517,13 +565,16 @@ type CreateWorkOrderInput struct {
 }
 
 type WorkOrderOutput struct {
-	WorkOrderID  string  `json:"work_order_id" desc:"工单ID"`
-	Code         string  `json:"code" desc:"工单编号"`
-	ProductID    string  `json:"product_id" desc:"产品ID"`
-	Status       string  `json:"status" desc:"状态: draft/released/in_progress/completed/cancelled"`
-	PlannedQty   float64 `json:"planned_qty" desc:"计划数量"`
-	CompletedQty float64 `json:"completed_qty" desc:"完成数量"`
-	URL          string  `json:"url" desc:"工单链接"`
+	WorkOrderID      string  `json:"work_order_id" desc:"工单ID"`
+	Code             string  `json:"code" desc:"工单编号"`
+	ProductID        string  `json:"product_id" desc:"产品ID"`
+	Status           string  `json:"status" desc:"状态: draft/released/in_progress/completed/cancelled"`
+	PlannedQty       float64 `json:"planned_qty" desc:"计划数量"`
+	CompletedQty     float64 `json:"completed_qty" desc:"完成数量"`
+	URL              string  `json:"url" desc:"工单链接"`
+	MaterialVariance float64 `json:"material_variance,omitempty" desc:"材料用量差异 (标准成本产品完工时, 正数为不利)"`
+	LaborVariance    float64 `json:"labor_variance,omitempty" desc:"人工效率差异 (正数为不利)"`
+	VarianceEntryID  string  `json:"variance_entry_id,omitempty" desc:"差异凭证ID"`
 }
 
 type ReleaseWorkOrderInput struct {
@@ -554,11 +605,12 @@ type IssueWOMaterialsOutput struct {
 // ---------------------------------------------------------------------------
 
 type ReportWOProgressInput struct {
-	WorkOrderID string  `json:"work_order_id" desc:"工单ID"`
-	Operation   string  `json:"operation" desc:"工序名称"`
-	GoodQty     float64 `json:"good_qty" desc:"良品数量"`
-	DefectQty   float64 `json:"defect_qty,omitempty" desc:"缺陷品数量"`
-	ScrapQty    float64 `json:"scrap_qty,omitempty" desc:"报废数量"`
+	WorkOrderID  string  `json:"work_order_id" desc:"工单ID"`
+	Operation    string  `json:"operation" desc:"工序名称"`
+	GoodQty      float64 `json:"good_qty" desc:"良品数量"`
+	DefectQty    float64 `json:"defect_qty,omitempty" desc:"缺陷品数量"`
+	ScrapQty     float64 `json:"scrap_qty,omitempty" desc:"报废数量"`
+	LaborMinutes float64 `json:"labor_minutes,omitempty" desc:"实际人工工时(分钟), 为空按起止时间计算"`
 }
 
 type WOProgressOutput struct {
@@ -622,15 +674,105 @@ type ReverseJournalEntryOutput struct {
 }
 
 type PostAPFromSettlementInput struct {
-	SettlementID string `json:"settlement_id" desc:"SRM 结算单ID"`
-	PostedBy     string `json:"posted_by,omitempty" desc:"过账人"`
+	SettlementID string   `json:"settlement_id" desc:"SRM 结算单ID"`
+	PostedBy     string   `json:"posted_by,omitempty" desc:"过账人"`
+	FXDiff       *float64 `json:"fx_diff,omitempty" desc:"已实现汇兑损益(本位币, >0 损失 <0 收益), 缺省取结算单 fx_diff"`
+	ExchangeRate *float64 `json:"exchange_rate,omitempty" desc:"结算汇率, 缺省取结算单 exchange_rate"`
 }
 
 type PostAPFromSettlementOutput struct {
-	JournalEntryID string  `json:"journal_entry_id" desc:"生成的会计凭证ID"`
+	JournalEntryID   string  `json:"journal_entry_id" desc:"生成的会计凭证ID"`
+	JournalCode      string  `json:"journal_code" desc:"凭证编号"`
+	SettlementID     string  `json:"settlement_id" desc:"源 SRM 结算单ID"`
+	APInvoiceID      string  `json:"ap_invoice_id" desc:"应付发票ID"`
+	PaymentID        string  `json:"payment_id" desc:"付款单ID"`
+	Amount           float64 `json:"amount" desc:"过账金额(本位币)"`
+	Currency         string  `json:"currency" desc:"交易币种"`
+	OriginalAmount   float64 `json:"original_amount" desc:"交易币种金额"`
+	ExchangeRate     float64 `json:"exchange_rate" desc:"结算汇率"`
+	FunctionalAmount float64 `json:"functional_amount" desc:"付款本位币金额"`
+	FXDiff           float64 `json:"fx_diff" desc:"已实现汇兑损益(本位币)"`
+}
+
+// ---------------------------------------------------------------------------
+// 应付管理
+// ---------------------------------------------------------------------------
+
+type CreateAPInvoiceInput struct {
+	SettlementID   string  `json:"settlement_id,omitempty" desc:"SRM 结算单ID (填写时金额与币种取自结算单)"`
+	SupplierID     string  `json:"supplier_id,omitempty" desc:"供应商ID (手工登记时必填)"`
+	InvoiceNo      string  `json:"invoice_no,omitempty" desc:"发票号"`
+	Amount         float64 `json:"amount,omitempty" desc:"应付金额(本位币, 手工登记时必填)"`
+	Currency       string  `json:"currency,omitempty" desc:"币种"`
+	OriginalAmount float64 `json:"original_amount,omitempty" desc:"原币金额"`
+	InvoiceDate    string  `json:"invoice_date,omitempty" desc:"发票日期 YYYY-MM-DD (默认结算期末/今天)"`
+	PaymentTerms   string  `json:"payment_terms,omitempty" desc:"付款条件 (默认取供应商, 如 NET30/月结60天/COD)"`
+	DueDate        string  `json:"due_date,omitempty" desc:"到期日 YYYY-MM-DD (覆盖付款条件)"`
+	Notes          string  `json:"notes,omitempty" desc:"备注"`
+}
+
+type APInvoiceOutput struct {
+	InvoiceID  string  `json:"invoice_id" desc:"应付发票ID"`
+	Code       string  `json:"code" desc:"应付发票编号"`
+	SupplierID string  `json:"supplier_id" desc:"供应商ID"`
+	Total      float64 `json:"total" desc:"应付金额(本位币)"`
+	OpenAmount float64 `json:"open_amount" desc:"未付金额"`
+	DueDate    string  `json:"due_date" desc:"到期日"`
+	Status     string  `json:"status" desc:"状态: open/partially_paid/paid/cancelled"`
+}
+
+type APAllocationInput struct {
+	InvoiceID string  `json:"invoice_id" desc:"应付发票ID"`
+	Amount    float64 `json:"amount" desc:"核销金额"`
+}
+
+type RecordAPPaymentInput struct {
+	SupplierID     string              `json:"supplier_id" desc:"供应商ID"`
+	Amount         float64             `json:"amount" desc:"冲减应付金额(本位币入账金额)"`
+	FXDiff         float64             `json:"fx_diff,omitempty" desc:"已实现汇兑损益 (>0 损失, <0 收益)"`
+	Currency       string              `json:"currency,omitempty" desc:"付款币种"`
+	OriginalAmount float64             `json:"original_amount,omitempty" desc:"原币付款金额"`
+	PaymentMethod  string              `json:"payment_method,omitempty" desc:"付款方式" enum:"bank_transfer,check,cash,online"`
+	BankAccount    string              `json:"bank_account,omitempty" desc:"收款账号 (默认取供应商)"`
+	ReferenceNo    string              `json:"reference_no,omitempty" desc:"银行流水号"`
+	PaidDate       string              `json:"paid_date,omitempty" desc:"付款日期 YYYY-MM-DD"`
+	Allocations    []APAllocationInput `json:"allocations,omitempty" desc:"核销明细 (为空按到期日先后核销)"`
+	PostedBy       string              `json:"posted_by,omitempty" desc:"过账人"`
+}
+
+type APPaymentOutput struct {
+	PaymentID      string  `json:"payment_id" desc:"付款单ID"`
+	Code           string  `json:"code" desc:"付款单编号"`
+	Amount         float64 `json:"amount" desc:"冲减应付金额"`
+	CashAmount     float64 `json:"cash_amount" desc:"实际付款金额(本位币)"`
+	FXDiff         float64 `json:"fx_diff" desc:"汇兑损益"`
+	Allocations    int     `json:"allocations" desc:"核销发票数"`
+	JournalEntryID string  `json:"journal_entry_id" desc:"付款凭证ID"`
 	JournalCode    string  `json:"journal_code" desc:"凭证编号"`
-	SettlementID   string  `json:"settlement_id" desc:"源 SRM 结算单ID"`
-	Amount         float64 `json:"amount" desc:"过账金额"`
+}
+
+type ProposePaymentRunInput struct {
+	CutoffDate  string   `json:"cutoff_date,omitempty" desc:"到期截止日 YYYY-MM-DD (默认今天+7天)"`
+	PaymentDate string   `json:"payment_date,omitempty" desc:"计划付款日 YYYY-MM-DD (默认今天)"`
+	Currency    string   `json:"currency,omitempty" desc:"币种 (默认CNY)"`
+	SupplierIDs []string `json:"supplier_ids,omitempty" desc:"限定供应商"`
+	Notes       string   `json:"notes,omitempty" desc:"备注"`
+}
+
+type ExecutePaymentRunInput struct {
+	RunID             string   `json:"run_id" desc:"付款批次ID"`
+	ExcludeInvoiceIDs []string `json:"exclude_invoice_ids,omitempty" desc:"本次不付的应付发票ID"`
+	ApprovedBy        string   `json:"approved_by,omitempty" desc:"审批人"`
+	ReferenceNo       string   `json:"reference_no,omitempty" desc:"银行批次号"`
+}
+
+type PaymentRunOutput struct {
+	RunID       string  `json:"run_id" desc:"付款批次ID"`
+	Code        string  `json:"code" desc:"付款批次编号"`
+	ItemCount   int     `json:"item_count,omitempty" desc:"发票数"`
+	TotalAmount float64 `json:"total_amount" desc:"付款总额"`
+	Status      string  `json:"status,omitempty" desc:"状态: proposed/paid"`
+	ExportURL   string  `json:"export_url" desc:"银行付款文件(CSV)下载链接"`
 }
 
 // ---------------------------------------------------------------------------
@@ -651,15 +793,149 @@ type ClosePeriodOutput struct {
 // ---------------------------------------------------------------------------
 
 type GenerateReportInput struct {
-	ReportType string `json:"report_type" desc:"报表类型" enum:"trial_balance,income_statement,balance_sheet"`
-	Period     string `json:"period" desc:"报表期间 (如 2026-04)"`
+	ReportType string   `json:"report_type" desc:"报表类型" enum:"trial_balance,income_statement,balance_sheet,cash_flow,drill_down"`
+	Period     string   `json:"period" desc:"报表期间 (如 2026-04)"`
+	Basis      string   `json:"basis,omitempty" desc:"利润表/现金流量表口径: month 本月, ytd 本年累计" enum:"month,ytd"`
+	Compare    string   `json:"compare,omitempty" desc:"对比: prior_month 上月, prior_ytd 上年同期累计" enum:"prior_month,prior_ytd"`
+	ShowZero   bool     `json:"show_zero,omitempty" desc:"显示零余额科目"`
+	AccountID  string   `json:"account_id,omitempty" desc:"穿透科目ID (drill_down, 含下级科目)"`
+	AccountIDs []string `json:"account_ids,omitempty" desc:"穿透科目ID列表 (drill_down)"`
+	PeriodFrom string   `json:"period_from,omitempty" desc:"穿透起始期间 (drill_down, 为空则自期初)"`
+	PeriodTo   string   `json:"period_to,omitempty" desc:"穿透截止期间 (drill_down)"`
+	Page       int      `json:"page,omitempty" desc:"穿透页码"`
+	PageSize   int      `json:"page_size,omitempty" desc:"穿透每页行数"`
 }
 
 type GenerateReportOutput struct {
-	ReportID   string `json:"report_id" desc:"报表ID"`
-	ReportType string `json:"report_type" desc:"报表类型"`
-	Period     string `json:"period" desc:"报表期间"`
-	URL        string `json:"url" desc:"报表链接"`
+	ReportID   string             `json:"report_id" desc:"报表ID"`
+	ReportType string             `json:"report_type" desc:"报表类型"`
+	Period     string             `json:"period" desc:"报表期间"`
+	URL        string             `json:"url" desc:"报表链接 (Excel 导出)"`
+	Totals     map[string]float64 `json:"totals,omitempty" desc:"报表合计数"`
+	Balanced   bool               `json:"balanced,omitempty" desc:"资产负债表是否平衡/现金流量表是否与现金科目变动一致"`
+}
+
+type CreateAccountInput struct {
+	Code          string `json:"code" desc:"科目编码"`
+	Name          string `json:"name" desc:"科目名称"`
+	Type          string `json:"type,omitempty" desc:"科目类型 (一级科目必填, 下级科目继承上级)" enum:"asset,liability,equity,revenue,expense"`
+	ParentCode    string `json:"parent_code,omitempty" desc:"上级科目编码"`
+	Currency      string `json:"currency,omitempty" desc:"核算币种 (默认 CNY)"`
+	CashFlowClass string `json:"cash_flow_class,omitempty" desc:"现金流量表分类 (下级科目默认继承上级)" enum:"cash,noncash,operating,investing,financing,profit"`
+}
+
+type CreateAccountOutput struct {
+	AccountID string `json:"account_id" desc:"科目ID"`
+	Code      string `json:"code" desc:"科目编码"`
+	Name      string `json:"name" desc:"科目名称"`
+	Type      string `json:"type" desc:"科目类型"`
+	Level     int    `json:"level" desc:"层级"`
+	ParentID  string `json:"parent_id" desc:"上级科目ID"`
+}
+
+type SavePostingRuleInput struct {
+	ID               string `json:"id,omitempty" desc:"规则ID (更新时填写)"`
+	TxType           string `json:"tx_type" desc:"事务类型 (po_receipt/sales_issue/ap_settlement/自定义如 freight_in)"`
+	WarehouseType    string `json:"warehouse_type,omitempty" desc:"仓库类型 (空=全部)"`
+	MaterialCategory string `json:"material_category,omitempty" desc:"物料类别编码 (空=全部, 含下级类别)"`
+	DebitAccount     string `json:"debit_account" desc:"借方科目编码"`
+	CreditAccount    string `json:"credit_account" desc:"贷方科目编码"`
+	Priority         int    `json:"priority,omitempty" desc:"优先级 (同等具体度时大者优先)"`
+	Description      string `json:"description,omitempty" desc:"说明"`
+	Status           string `json:"status,omitempty" desc:"状态" enum:"active,inactive"`
+}
+
+type PostingRuleOutput struct {
+	ID            string `json:"id" desc:"规则ID"`
+	TxType        string `json:"tx_type" desc:"事务类型"`
+	DebitAccount  string `json:"debit_account" desc:"借方科目编码"`
+	CreditAccount string `json:"credit_account" desc:"贷方科目编码"`
+	Status        string `json:"status" desc:"状态"`
+}
+
+type PostByRuleInput struct {
+	TxType      string  `json:"tx_type" desc:"事务类型"`
+	Amount      float64 `json:"amount" desc:"金额 (本位币)"`
+	WarehouseID string  `json:"warehouse_id,omitempty" desc:"仓库ID (用于匹配仓库类型)"`
+	MaterialID  string  `json:"material_id,omitempty" desc:"物料ID (用于匹配物料类别)"`
+	EntryDate   string  `json:"entry_date,omitempty" desc:"记账日期 (默认今天)"`
+	SourceType  string  `json:"source_type,omitempty" desc:"来源类型"`
+	SourceID    string  `json:"source_id,omitempty" desc:"来源单据ID"`
+	Description string  `json:"description,omitempty" desc:"摘要"`
+	Currency    string  `json:"currency,omitempty" desc:"原币币种"`
+	CustomerID  string  `json:"customer_id,omitempty" desc:"客户ID"`
+	SupplierID  string  `json:"supplier_id,omitempty" desc:"供应商ID"`
+	PostedBy    string  `json:"posted_by,omitempty" desc:"过账人"`
+}
+
+type PostByRuleOutput struct {
+	EntryID       string  `json:"entry_id" desc:"凭证ID"`
+	Code          string  `json:"code" desc:"凭证编号"`
+	RuleID        string  `json:"rule_id" desc:"命中的规则ID"`
+	DebitAccount  string  `json:"debit_account" desc:"借方科目编码"`
+	CreditAccount string  `json:"credit_account" desc:"贷方科目编码"`
+	Amount        float64 `json:"amount" desc:"金额"`
+}
+
+// ---------------------------------------------------------------------------
+// 成本管理 — 标准成本
+// ---------------------------------------------------------------------------
+
+type RollupStandardCostInput struct {
+	MaterialID  string   `json:"material_id,omitempty" desc:"产品物料ID"`
+	MaterialIDs []string `json:"material_ids,omitempty" desc:"产品物料ID列表 (批量卷算)"`
+	BomID       string   `json:"bom_id,omitempty" desc:"BOM ID (默认取最新已发布 BOM, 仅单个物料时可指定)"`
+	LotSize     float64  `json:"lot_size,omitempty" desc:"标准批量, 准备工时按批量分摊 (默认1)"`
+	RolledUpBy  string   `json:"rolled_up_by,omitempty" desc:"卷算人"`
+}
+
+type StandardCostOutput struct {
+	ID           string  `json:"id" desc:"标准成本版本ID"`
+	MaterialID   string  `json:"material_id" desc:"物料ID"`
+	BomID        string  `json:"bom_id" desc:"BOM ID"`
+	RouteID      string  `json:"route_id" desc:"工艺路线ID"`
+	MaterialCost float64 `json:"material_cost" desc:"单位材料成本"`
+	LaborCost    float64 `json:"labor_cost" desc:"单位人工成本"`
+	OverheadCost float64 `json:"overhead_cost" desc:"单位制造费用"`
+	TotalCost    float64 `json:"total_cost" desc:"单位标准成本"`
+	StdMinutes   float64 `json:"std_minutes" desc:"单位标准工时(分钟)"`
+	Status       string  `json:"status" desc:"状态: pending/current/superseded"`
+}
+
+type RollupStandardCostOutput struct {
+	StandardCosts []StandardCostOutput `json:"standard_costs" desc:"待生效标准成本"`
+}
+
+type RunCostRevaluationInput struct {
+	MaterialIDs []string `json:"material_ids,omitempty" desc:"物料ID列表 (默认全部待生效)"`
+	DryRun      bool     `json:"dry_run,omitempty" desc:"仅试算不过账"`
+	PostedBy    string   `json:"posted_by,omitempty" desc:"过账人"`
+}
+
+type RevaluationResultOutput struct {
+	MaterialID  string   `json:"material_id" desc:"物料ID"`
+	OldCost     float64  `json:"old_cost" desc:"原单位成本"`
+	NewCost     float64  `json:"new_cost" desc:"新标准成本"`
+	OnHandQty   float64  `json:"on_hand_qty" desc:"在库数量"`
+	Revaluation float64  `json:"revaluation" desc:"重估金额 (正数为增值)"`
+	EntryIDs    []string `json:"entry_ids" desc:"重估凭证ID (每仓库一张)"`
+}
+
+type RunCostRevaluationOutput struct {
+	DryRun           bool                      `json:"dry_run" desc:"是否试算"`
+	Results          []RevaluationResultOutput `json:"results" desc:"物料重估结果"`
+	TotalRevaluation float64                   `json:"total_revaluation" desc:"重估总额"`
+}
+
+type SetCostMethodInput struct {
+	MaterialID  string   `json:"material_id,omitempty" desc:"物料ID"`
+	MaterialIDs []string `json:"material_ids,omitempty" desc:"物料ID列表"`
+	CostMethod  string   `json:"cost_method" desc:"计价方法" enum:"moving_average,standard"`
+}
+
+type SetCostMethodOutput struct {
+	CostMethod string `json:"cost_method" desc:"计价方法"`
+	Updated    int    `json:"updated" desc:"更新物料数"`
 }
 
 // ---------------------------------------------------------------------------
@@ -782,6 +1058,51 @@ type ExtendQuotationOutput struct {
 	ValidUntil  string `json:"valid_until" desc:"新的有效截止日期 YYYY-MM-DD"`
 }
 
+// ---------------------------------------------------------------------------
+// 定价与毛利
+// ---------------------------------------------------------------------------
+
+type QuotePriceInput struct {
+	CustomerID   string  `json:"customer_id,omitempty" desc:"客户ID(匹配客户专属价)"`
+	ProductID    string  `json:"product_id" desc:"产品ID"`
+	Quantity     float64 `json:"quantity,omitempty" desc:"数量(匹配阶梯价), 默认 1"`
+	Channel      string  `json:"channel,omitempty" desc:"销售渠道, 默认按客户类型推断" enum:"direct,ecommerce,dealer,retail"`
+	Currency     string  `json:"currency,omitempty" desc:"币种, 默认 CNY"`
+	ExchangeRate float64 `json:"exchange_rate,omitempty" desc:"折本位币汇率, 默认 1"`
+}
+
+type QuotePriceOutput struct {
+	ProductID        string  `json:"product_id" desc:"产品ID"`
+	ListPrice        float64 `json:"list_price" desc:"价目价(0为无价目)"`
+	BreakQty         float64 `json:"break_qty" desc:"命中的阶梯起订量"`
+	PriceListID      string  `json:"price_list_id" desc:"价目表ID"`
+	PriceSource      string  `json:"price_source" desc:"customer/price_list"`
+	PromotionID      string  `json:"promotion_id" desc:"促销ID"`
+	PromoDiscountPct float64 `json:"promo_discount_pct" desc:"促销折扣%"`
+	NetPrice         float64 `json:"net_price" desc:"折后单价"`
+	UnitCost         float64 `json:"unit_cost" desc:"本位币单位成本"`
+	CostSource       string  `json:"cost_source" desc:"standard/average/plm/bom/none"`
+	MarginPct        float64 `json:"margin_pct" desc:"毛利率%"`
+	MinMarginPct     float64 `json:"min_margin_pct" desc:"渠道毛利阈值%"`
+	FloorPrice       float64 `json:"floor_price" desc:"满足阈值的最低折后单价"`
+	BelowMargin      bool    `json:"below_margin" desc:"是否低于阈值"`
+	CostUnknown      bool    `json:"cost_unknown" desc:"无成本, 无法校验毛利"`
+}
+
+type ApproveQuotationMarginInput struct {
+	QuotationID string `json:"quotation_id" desc:"报价单ID"`
+	Decision    string `json:"decision,omitempty" desc:"审批结果, 默认 approve" enum:"approve,reject"`
+	ApprovedBy  string `json:"approved_by" desc:"审批人"`
+	Reason      string `json:"reason,omitempty" desc:"审批意见"`
+}
+
+type ApproveQuotationMarginOutput struct {
+	QuotationID    string  `json:"quotation_id" desc:"报价单ID"`
+	Code           string  `json:"code" desc:"报价单编号"`
+	ApprovalStatus string  `json:"approval_status" desc:"approved/rejected"`
+	MarginPct      float64 `json:"margin_pct" desc:"整单毛利率%"`
+}
+
 // ---------------------------------------------------------------------------
 // 补充命令 — 发送催款通知
 // ---------------------------------------------------------------------------
@@ -793,4 +1114,132 @@ type SendPaymentReminderInput struct {
 type SendPaymentReminderOutput struct {
 	InvoiceID    string `json:"invoice_id" desc:"发票ID"`
 	ReminderSent bool   `json:"reminder_sent" desc:"是否发送成功"`
+	NoticeID     string `json:"notice_id" desc:"催款记录ID"`
+	Level        int    `json:"level" desc:"催款级别(未逾期为0)"`
+}
+
+// ---------------------------------------------------------------------------
+// 信用控制
+// ---------------------------------------------------------------------------
+
+type ReleaseCreditHoldInput struct {
+	OrderID    string `json:"order_id" desc:"销售订单ID(状态 credit_hold 或信用检查被拒的草稿)"`
+	Decision   string `json:"decision,omitempty" desc:"审批结果, 默认 approve" enum:"approve,reject"`
+	ApprovedBy string `json:"approved_by" desc:"审批人"`
+	Reason     string `json:"reason" desc:"审批意见"`
+}
+
+type RunDunningInput struct {
+	AsOf       string `json:"as_of,omitempty" desc:"催款基准日 YYYY-MM-DD, 默认今天"`
+	CustomerID string `json:"customer_id,omitempty" desc:"仅处理指定客户"`
+	DryRun     bool   `json:"dry_run,omitempty" desc:"仅预览, 不发送不更新"`
+}
+
+type DunningNoticeResult struct {
+	NoticeID      string  `json:"notice_id" desc:"催款记录ID"`
+	Code          string  `json:"code" desc:"催款编号"`
+	CustomerID    string  `json:"customer_id" desc:"客户ID"`
+	CustomerName  string  `json:"customer_name" desc:"客户名称"`
+	Level         int     `json:"level" desc:"催款级别"`
+	LevelName     string  `json:"level_name" desc:"级别名称"`
+	InvoiceCount  int     `json:"invoice_count" desc:"催款发票数"`
+	OverdueAmount float64 `json:"overdue_amount" desc:"逾期金额"`
+	CreditHold    bool    `json:"credit_hold" desc:"本次是否冻结客户信用"`
+}
+
+type RunDunningOutput struct {
+	AsOf           string                `json:"as_of" desc:"催款基准日"`
+	DryRun         bool                  `json:"dry_run" desc:"是否预览"`
+	NoticeCount    int                   `json:"notice_count" desc:"催款通知数"`
+	CreditHolds    int                   `json:"credit_holds" desc:"新冻结客户数"`
+	CreditReleased int                   `json:"credit_released" desc:"逾期清零解冻客户数"`
+	Notices        []DunningNoticeResult `json:"notices" desc:"催款明细"`
+}
+
+// ---------------------------------------------------------------------------
+// 包装与物流
+// ---------------------------------------------------------------------------
+
+type CreatePackageInput struct {
+	ShipmentID string  `json:"shipment_id" desc:"发货单ID"`
+	Type       string  `json:"type,omitempty" desc:"包装类型, 默认纸箱" enum:"carton,pallet"`
+	ParentID   string  `json:"parent_id,omitempty" desc:"所在托盘ID(仅纸箱)"`
+	Length     float64 `json:"length,omitempty" desc:"长(cm)"`
+	Width      float64 `json:"width,omitempty" desc:"宽(cm)"`
+	Height     float64 `json:"height,omitempty" desc:"高(cm)"`
+	Notes      string  `json:"notes,omitempty" desc:"备注"`
+}
+
+type PackageOutput struct {
+	PackageID string `json:"package_id" desc:"包装件ID"`
+	Code      string `json:"code" desc:"包装件编号"`
+	Type      string `json:"type" desc:"包装类型"`
+	SSCC      string `json:"sscc" desc:"18位 SSCC"`
+	Seq       int    `json:"seq" desc:"发货单内同类包装序号"`
+}
+
+type PackLineInput struct {
+	ShipmentItemID string  `json:"shipment_item_id,omitempty" desc:"发货明细ID"`
+	ProductID      string  `json:"product_id,omitempty" desc:"产品ID(未指定发货明细时匹配)"`
+	LotNumber      string  `json:"lot_number,omitempty" desc:"批次号, 默认取发货明细批次"`
+	Quantity       float64 `json:"quantity" desc:"数量"`
+}
+
+type PackItemsInput struct {
+	PackageID     string          `json:"package_id" desc:"纸箱ID"`
+	SerialNumbers []string        `json:"serial_numbers,omitempty" desc:"扫描的序列号"`
+	Items         []PackLineInput `json:"items,omitempty" desc:"非序列号物料按批次数量装箱"`
+}
+
+type PackItemsOutput struct {
+	PackageID       string  `json:"package_id" desc:"纸箱ID"`
+	Code            string  `json:"code" desc:"纸箱编号"`
+	PackedLines     int     `json:"packed_lines" desc:"本次装箱行数"`
+	PackageQuantity float64 `json:"package_quantity" desc:"箱内合计数量"`
+}
+
+type UnpackItemsInput struct {
+	PackageID     string   `json:"package_id" desc:"纸箱ID"`
+	SerialNumbers []string `json:"serial_numbers,omitempty" desc:"撤回的序列号"`
+	ContentIDs    []string `json:"content_ids,omitempty" desc:"撤回的装箱明细ID"`
+}
+
+type UnpackItemsOutput struct {
+	PackageID string `json:"package_id" desc:"纸箱ID"`
+	Code      string `json:"code" desc:"纸箱编号"`
+	Removed   int    `json:"removed" desc:"撤回行数"`
+}
+
+type SealPackageInput struct {
+	PackageID   string  `json:"package_id" desc:"包装件ID"`
+	GrossWeight float64 `json:"gross_weight,omitempty" desc:"毛重(kg), 托盘默认纸箱毛重合计加托盘自重"`
+	NetWeight   float64 `json:"net_weight,omitempty" desc:"净重(kg), 默认按报关资料单位净重计算"`
+	TareWeight  float64 `json:"tare_weight,omitempty" desc:"托盘自重(kg)"`
+	Length      float64 `json:"length,omitempty" desc:"长(cm)"`
+	Width       float64 `json:"width,omitempty" desc:"宽(cm)"`
+	Height      float64 `json:"height,omitempty" desc:"高(cm)"`
+}
+
+type SealPackageOutput struct {
+	PackageID      string  `json:"package_id" desc:"包装件ID"`
+	Code           string  `json:"code" desc:"包装件编号"`
+	SSCC           string  `json:"sscc" desc:"SSCC"`
+	GrossWeight    float64 `json:"gross_weight" desc:"毛重(kg)"`
+	NetWeight      float64 `json:"net_weight" desc:"净重(kg)"`
+	ShipmentStatus string  `json:"shipment_status" desc:"发货单状态, 全部封箱后为 packed"`
+}
+
+type GenerateCustomsInvoiceInput struct {
+	ShipmentID string `json:"shipment_id" desc:"发货单ID(出口订单)"`
+}
+
+type CustomsInvoiceOutput struct {
+	InvoiceID    string  `json:"invoice_id" desc:"报关发票ID"`
+	Code         string  `json:"code" desc:"报关发票编号"`
+	Currency     string  `json:"currency" desc:"币种"`
+	TotalAmount  float64 `json:"total_amount" desc:"发票总额"`
+	Lines        int     `json:"lines" desc:"发票行数"`
+	PackageCount int     `json:"package_count" desc:"件数"`
+	GrossWeight  float64 `json:"gross_weight" desc:"毛重(kg)"`
+	NetWeight    float64 `json:"net_weight" desc:"净重(kg)"`
 }
@@ -0,0 +1,565 @@
+package erp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	sdk "github.com/bitfantasy/acp-module-sdk"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ===========================================================================
+// 标准成本：BOM + 工艺路线卷算 → 成本重估 → 采购价格差异 / 工单差异
+//
+// 物料默认按移动加权平均计价 (updateMovingAverageCost)。重估运行把卷算出的
+// 标准成本生效并将物料切换为 standard：此后入库按标准成本计价，采购入库的
+// 实际与标准之差记 ppv，工单完工时按领料与报工工时计算材料/人工差异。
+// ===========================================================================
+
+const (
+	costMethodMovingAverage = "moving_average"
+	costMethodStandard      = "standard"
+)
+
+// Standard cost version statuses.
+const (
+	stdCostPending    = "pending"
+	stdCostCurrent    = "current"
+	stdCostSuperseded = "superseded"
+)
+
+// isStandardCosted reports whether the material is valued at standard.
+func isStandardCosted(attrs ErpMaterialInventoryAttrs) bool {
+	return attrs.CostMethod == costMethodStandard
+}
+
+// componentUnitCost is the unit cost a component contributes to a roll-up:
+// its standard when standard-costed, else the moving average, else the PLM
+// material master's standard cost.
+func componentUnitCost(db *gorm.DB, materialID string) (float64, string) {
+	var attrs ErpMaterialInventoryAttrs
+	if db.Where("material_id = ?", materialID).First(&attrs).Error == nil {
+		if isStandardCosted(attrs) && attrs.StandardCost > 0 {
+			return attrs.StandardCost, "standard"
+		}
+		if attrs.CurrentAvgCost > 0 {
+			return attrs.CurrentAvgCost, "average"
+		}
+	}
+	var plmCost float64
+	db.Table("plm_materials").Where("id = ?", materialID).Select("COALESCE(standard_cost, 0)").Row().Scan(&plmCost)
+	if plmCost > 0 {
+		return plmCost, "plm"
+	}
+	return 0, "none"
+}
+
+// ---------------------------------------------------------------------------
+// Roll-up
+// ---------------------------------------------------------------------------
+
+type costRollupLine struct {
+	MaterialID string  `json:"material_id"`
+	Quantity   float64 `json:"quantity"`
+	UnitCost   float64 `json:"unit_cost"`
+	Amount     float64 `json:"amount"`
+	Source     string  `json:"source"` // rollup / standard / average / plm / none
+}
+
+type costRollupStep struct {
+	StepNumber   int     `json:"step_number"`
+	Name         string  `json:"name"`
+	WorkCenter   string  `json:"work_center"`
+	Minutes      float64 `json:"minutes"`
+	LaborCost    float64 `json:"labor_cost"`
+	OverheadCost float64 `json:"overhead_cost"`
+}
+
+type costRollup struct {
+	MaterialID   string           `json:"material_id"`
+	BomID        string           `json:"bom_id"`
+	RouteID      string           `json:"route_id"`
+	LotSize      float64          `json:"lot_size"`
+	MaterialCost float64          `json:"material_cost"`
+	LaborCost    float64          `json:"labor_cost"`
+	OverheadCost float64          `json:"overhead_cost"`
+	TotalCost    float64          `json:"total_cost"`
+	StdMinutes   float64          `json:"std_minutes"`
+	Materials    []costRollupLine `json:"materials"`
+	Steps        []costRollupStep `json:"steps"`
+	Warnings     []string         `json:"warnings,omitempty"`
+}
+
+// releasedBOMFor returns the latest released PLM BOM of a product, or "".
+func releasedBOMFor(db *gorm.DB, productID string) string {
+	var bomID string
+	db.Table("plm_boms").Where("product_id = ? AND status = ?", productID, "released").
+		Order("updated_at DESC").Limit(1).Select("id").Row().Scan(&bomID)
+	return bomID
+}
+
+// loadCostRates indexes work-center rates; the "" key is the default rate.
+func loadCostRates(db *gorm.DB) map[string]ErpCostRate {
+	var rates []ErpCostRate
+	db.Find(&rates)
+	out := make(map[string]ErpCostRate, len(rates))
+	for _, r := range rates {
+		out[r.WorkCenter] = r
+	}
+	return out
+}
+
+// costRoller rolls standard costs bottom-up through multi-level BOMs,
+// caching sub-assemblies and rejecting BOM cycles.
+type costRoller struct {
+	db       *gorm.DB
+	lotSize  float64
+	rates    map[string]ErpCostRate
+	cache    map[string]*costRollup
+	visiting map[string]bool
+}
+
+func newCostRoller(db *gorm.DB, lotSize float64) *costRoller {
+	if lotSize <= 0 {
+		lotSize = 1
+	}
+	return &costRoller{db: db, lotSize: lotSize, rates: loadCostRates(db),
+		cache: map[string]*costRollup{}, visiting: map[string]bool{}}
+}
+
+// rollup computes the unit standard cost of a manufactured material from its
+// released BOM (or bomID when given) plus the active routing of that BOM.
+func (r *costRoller) rollup(materialID, bomID string) (*costRollup, error) {
+	if c, ok := r.cache[materialID]; ok && bomID == "" {
+		return c, nil
+	}
+	if r.visiting[materialID] {
+		return nil, fmt.Errorf("BOM cycle detected at material %s", materialID)
+	}
+	if bomID == "" {
+		bomID = releasedBOMFor(r.db, materialID)
+	}
+	if bomID == "" {
+		return nil, fmt.Errorf("material %s has no released BOM", materialID)
+	}
+	r.visiting[materialID] = true
+	defer delete(r.visiting, materialID)
+
+	res := &costRollup{MaterialID: materialID, BomID: bomID, LotSize: r.lotSize}
+
+	var items []struct {
+		MaterialID string  `gorm:"column:material_id"`
+		Quantity   float64 `gorm:"column:quantity"`
+	}
+	r.db.Table("plm_bom_items").Where("bom_id = ?", bomID).Find(&items)
+	for _, it := range items {
+		if it.MaterialID == "" || it.Quantity <= 0 {
+			continue
+		}
+		line := costRollupLine{MaterialID: it.MaterialID, Quantity: it.Quantity}
+		if releasedBOMFor(r.db, it.MaterialID) != "" {
+			sub, err := r.rollup(it.MaterialID, "")
+			if err != nil {
+				return nil, err
+			}
+			line.UnitCost, line.Source = sub.TotalCost, "rollup"
+		} else {
+			line.UnitCost, line.Source = componentUnitCost(r.db, it.MaterialID)
+		}
+		if line.Source == "none" {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("component %s has no cost", it.MaterialID))
+		}
+		line.Amount = line.UnitCost * line.Quantity
+		res.MaterialCost += line.Amount
+		res.Materials = append(res.Materials, line)
+	}
+
+	r.db.Table("plm_process_routes").Where("bom_id = ? AND status = ?", bomID, "active").
+		Order("updated_at DESC").Limit(1).Select("id").Row().Scan(&res.RouteID)
+	if res.RouteID != "" {
+		var steps []struct {
+			StepNumber     int     `gorm:"column:step_number"`
+			Name           string  `gorm:"column:name"`
+			WorkCenter     string  `gorm:"column:work_center"`
+			StdTimeMinutes float64 `gorm:"column:std_time_minutes"`
+			SetupMinutes   float64 `gorm:"column:setup_minutes"`
+		}
+		r.db.Table("plm_process_steps").Where("route_id = ?", res.RouteID).Order("step_number").Find(&steps)
+		for _, st := range steps {
+			rate, ok := r.rates[st.WorkCenter]
+			if !ok {
+				rate, ok = r.rates[""]
+			}
+			if !ok {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("no cost rate for work center %q", st.WorkCenter))
+			}
+			// 准备工时按批量分摊到单件
+			minutes := st.StdTimeMinutes + st.SetupMinutes/r.lotSize
+			step := costRollupStep{
+				StepNumber:   st.StepNumber,
+				Name:         st.Name,
+				WorkCenter:   st.WorkCenter,
+				Minutes:      minutes,
+				LaborCost:    minutes / 60 * rate.LaborRate,
+				OverheadCost: minutes / 60 * rate.OverheadRate,
+			}
+			res.StdMinutes += minutes
+			res.LaborCost += step.LaborCost
+			res.OverheadCost += step.OverheadCost
+			res.Steps = append(res.Steps, step)
+		}
+	} else {
+		res.Warnings = append(res.Warnings, "no active routing, labor and overhead are zero")
+	}
+
+	res.MaterialCost = roundCost(res.MaterialCost)
+	res.LaborCost = roundCost(res.LaborCost)
+	res.OverheadCost = roundCost(res.OverheadCost)
+	res.TotalCost = roundCost(res.MaterialCost + res.LaborCost + res.OverheadCost)
+	r.cache[materialID] = res
+	return res, nil
+}
+
+// roundCost keeps unit costs at 4 decimals like the PLM material master.
+func roundCost(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// cmdRollupStandardCost rolls up standard costs and stores them as pending
+// versions; run_cost_revaluation makes them effective.
+func cmdRollupStandardCost(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	materialIDs := getStrSlice(input, "material_ids")
+	if id := getStr(input, "material_id"); id != "" {
+		materialIDs = append(materialIDs, id)
+	}
+	if len(materialIDs) == 0 {
+		return nil, fmt.Errorf("material_id or material_ids is required")
+	}
+	bomID := getStr(input, "bom_id")
+	if bomID != "" && len(materialIDs) > 1 {
+		return nil, fmt.Errorf("bom_id can only be given for a single material")
+	}
+	roller := newCostRoller(db, getFloat(input, "lot_size"))
+
+	var results []ErpStandardCost
+	var rollups []*costRollup
+	for _, materialID := range materialIDs {
+		res, err := roller.rollup(materialID, bomID)
+		if err != nil {
+			return nil, err
+		}
+		breakdown, _ := json.Marshal(map[string]any{"materials": res.Materials, "steps": res.Steps, "warnings": res.Warnings})
+		sc := ErpStandardCost{
+			ID:           uuid.New().String(),
+			MaterialID:   materialID,
+			BomID:        res.BomID,
+			RouteID:      res.RouteID,
+			LotSize:      res.LotSize,
+			MaterialCost: res.MaterialCost,
+			LaborCost:    res.LaborCost,
+			OverheadCost: res.OverheadCost,
+			TotalCost:    res.TotalCost,
+			StdMinutes:   res.StdMinutes,
+			Breakdown:    string(breakdown),
+			Status:       stdCostPending,
+			RolledUpBy:   getStr(input, "rolled_up_by"),
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// 同一物料只保留最新一版待生效成本
+			if err := tx.Model(&ErpStandardCost{}).
+				Where("material_id = ? AND status = ?", materialID, stdCostPending).
+				Update("status", stdCostSuperseded).Error; err != nil {
+				return err
+			}
+			return tx.Create(&sc).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("save standard cost for %s failed: %w", materialID, err)
+		}
+		results = append(results, sc)
+		rollups = append(rollups, res)
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.standard_cost.rolled_up",
+		fmt.Sprintf("标准成本卷算: %d 个物料", len(results)),
+		map[string]any{"count": len(results), "material_ids": materialIDs})
+	return map[string]any{"standard_costs": results, "rollups": rollups}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Revaluation
+// ---------------------------------------------------------------------------
+
+type revaluationResult struct {
+	MaterialID  string   `json:"material_id"`
+	OldCost     float64  `json:"old_cost"`
+	NewCost     float64  `json:"new_cost"`
+	OnHandQty   float64  `json:"on_hand_qty"`
+	Revaluation float64  `json:"revaluation"`
+	EntryIDs    []string `json:"entry_ids"`
+}
+
+// revalueMaterial makes a pending standard effective: on-hand stock is
+// revalued per warehouse (qty × (new − carried unit cost)) through the
+// cost_revaluation rule, inventory rows are restated at the new standard and
+// the material switches to standard costing.
+func revalueMaterial(tx *gorm.DB, sc *ErpStandardCost, postedBy string, dryRun bool) (*revaluationResult, error) {
+	attrs := ensureInventoryAttrs(tx, sc.MaterialID, "", "")
+	res := &revaluationResult{MaterialID: sc.MaterialID, OldCost: attrs.StandardCost, NewCost: sc.TotalCost, EntryIDs: []string{}}
+	if !isStandardCosted(attrs) {
+		res.OldCost = attrs.CurrentAvgCost
+	}
+
+	var rows []struct {
+		WarehouseID string
+		Qty         float64
+		Value       float64
+	}
+	if err := tx.Table("erp_inventory").
+		Select("warehouse_id, COALESCE(SUM(quantity), 0) AS qty, COALESCE(SUM(quantity * unit_cost), 0) AS value").
+		Where("material_id = ? AND quantity > 0", sc.MaterialID).
+		Group("warehouse_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		delta := roundAmount(row.Qty*sc.TotalCost - row.Value)
+		res.OnHandQty += row.Qty
+		res.Revaluation += delta
+		if dryRun || delta == 0 {
+			continue
+		}
+		rule, err := resolvePostingRule(tx, postingContextFor(tx, postingTxCostRevaluation, row.WarehouseID, sc.MaterialID))
+		if err != nil {
+			return nil, err
+		}
+		if rule == nil {
+			return nil, fmt.Errorf("no active posting rule for %s", postingTxCostRevaluation)
+		}
+		entry := ErpJournalEntry{
+			SourceType:  "cost_revaluation",
+			SourceID:    sc.ID,
+			Description: fmt.Sprintf("标准成本重估 %s: %.4f → %.4f × %.4f", sc.MaterialID, res.OldCost, sc.TotalCost, row.Qty),
+			PostedBy:    postedBy,
+		}
+		if err := postByRule(tx, rule, delta, &entry, ErpJournalLine{Currency: "CNY", OriginalAmount: delta}); err != nil {
+			return nil, err
+		}
+		res.EntryIDs = append(res.EntryIDs, entry.ID)
+	}
+	res.Revaluation = roundAmount(res.Revaluation)
+	if dryRun {
+		return res, nil
+	}
+
+	now := time.Now()
+	if err := tx.Table("erp_inventory").Where("material_id = ?", sc.MaterialID).
+		Updates(map[string]any{"unit_cost": sc.TotalCost, "updated_at": now}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&ErpMaterialInventoryAttrs{}).Where("material_id = ?", sc.MaterialID).
+		Updates(map[string]any{
+			"standard_cost":    sc.TotalCost,
+			"current_avg_cost": sc.TotalCost,
+			"cost_method":      costMethodStandard,
+			"updated_at":       now,
+		}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&ErpStandardCost{}).
+		Where("material_id = ? AND status = ?", sc.MaterialID, stdCostCurrent).
+		Update("status", stdCostSuperseded).Error; err != nil {
+		return nil, err
+	}
+	return res, tx.Model(sc).Updates(map[string]any{"status": stdCostCurrent, "effective_at": &now}).Error
+}
+
+// cmdRunCostRevaluation activates pending standard costs (all, or the given
+// materials). dry_run reports the revaluation without posting.
+func cmdRunCostRevaluation(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	dryRun := getBool(input, "dry_run")
+	q := db.Where("status = ?", stdCostPending)
+	if ids := getStrSlice(input, "material_ids"); len(ids) > 0 {
+		q = q.Where("material_id IN ?", ids)
+	}
+	var pending []ErpStandardCost
+	if err := q.Order("created_at").Find(&pending).Error; err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, fmt.Errorf("no pending standard costs to revalue")
+	}
+
+	results := []*revaluationResult{}
+	var total float64
+	for i := range pending {
+		var res *revaluationResult
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			res, err = revalueMaterial(tx, &pending[i], getStr(input, "posted_by"), dryRun)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("revalue %s failed: %w", pending[i].MaterialID, err)
+		}
+		if !dryRun {
+			// PLM 物料主数据同步标准成本（跨模块表，失败不影响重估）
+			db.Table("plm_materials").Where("id = ?", pending[i].MaterialID).Update("standard_cost", pending[i].TotalCost)
+		}
+		total += res.Revaluation
+		results = append(results, res)
+	}
+
+	if !dryRun {
+		emitEvent(adapter, runID, stepID, "erp.standard_cost.revalued",
+			fmt.Sprintf("标准成本重估: %d 个物料, 重估金额 ¥%.2f", len(results), total),
+			map[string]any{"count": len(results), "revaluation": roundAmount(total)})
+	}
+	return map[string]any{"dry_run": dryRun, "results": results, "total_revaluation": roundAmount(total)}, nil
+}
+
+// cmdSetCostMethod switches materials between moving average and standard.
+// Switching to standard requires an effective standard cost; materials
+// without one go through rollup_standard_cost + run_cost_revaluation.
+func cmdSetCostMethod(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	method := getStr(input, "cost_method")
+	if method != costMethodMovingAverage && method != costMethodStandard {
+		return nil, fmt.Errorf("cost_method must be moving_average or standard")
+	}
+	materialIDs := getStrSlice(input, "material_ids")
+	if id := getStr(input, "material_id"); id != "" {
+		materialIDs = append(materialIDs, id)
+	}
+	if len(materialIDs) == 0 {
+		return nil, fmt.Errorf("material_id or material_ids is required")
+	}
+	for _, id := range materialIDs {
+		attrs := ensureInventoryAttrs(db, id, "", "")
+		if method == costMethodStandard && attrs.StandardCost <= 0 {
+			return nil, fmt.Errorf("material %s has no standard cost; run rollup_standard_cost and run_cost_revaluation first", id)
+		}
+	}
+	if err := db.Model(&ErpMaterialInventoryAttrs{}).Where("material_id IN ?", materialIDs).
+		Updates(map[string]any{"cost_method": method, "updated_at": time.Now()}).Error; err != nil {
+		return nil, err
+	}
+	emitEvent(adapter, runID, stepID, "erp.cost_method.changed",
+		fmt.Sprintf("计价方法切换为 %s: %d 个物料", method, len(materialIDs)),
+		map[string]any{"cost_method": method, "material_ids": materialIDs})
+	return map[string]any{"cost_method": method, "updated": len(materialIDs)}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Work order variances
+// ---------------------------------------------------------------------------
+
+// woCostVariance holds the standard vs actual cost of a work order and the
+// resulting variances. Positive variances are unfavourable.
+type woCostVariance struct {
+	StdMaterialCost    float64
+	ActualMaterialCost float64
+	MaterialVariance   float64
+	StdLaborCost       float64
+	ActualLaborCost    float64
+	LaborVariance      float64
+	EntryID            string // 差异凭证，无差异时为空
+}
+
+// woVariance computes material usage and labor efficiency variances of a
+// standard-costed work order for the completed quantity. Returns nil when
+// the product is not standard-costed.
+func woVariance(db *gorm.DB, wo *ErpWorkOrder, completedQty float64) *woCostVariance {
+	var attrs ErpMaterialInventoryAttrs
+	if db.Where("material_id = ?", wo.ProductID).First(&attrs).Error != nil || !isStandardCosted(attrs) {
+		return nil
+	}
+	var sc ErpStandardCost
+	if db.Where("material_id = ? AND status = ?", wo.ProductID, stdCostCurrent).First(&sc).Error != nil {
+		return nil
+	}
+	v := &woCostVariance{}
+
+	// 材料用量差异：(实际领用 - 完工数 × 单位定额) × 组件单位成本
+	var issues []ErpWOMaterialIssue
+	db.Where("work_order_id = ?", wo.ID).Find(&issues)
+	for _, mi := range issues {
+		if wo.PlannedQty <= 0 {
+			break
+		}
+		unitCost, _ := componentUnitCost(db, mi.MaterialID)
+		v.StdMaterialCost += mi.RequiredQty / wo.PlannedQty * completedQty * unitCost
+		v.ActualMaterialCost += mi.IssuedQty * unitCost
+	}
+
+	// 人工效率差异：(实际工时 - 完工数 × 单位标准工时) × 标准人工费率
+	var reports []ErpWOReport
+	db.Where("work_order_id = ?", wo.ID).Find(&reports)
+	var minutes float64
+	for _, r := range reports {
+		switch {
+		case r.LaborMinutes > 0:
+			minutes += r.LaborMinutes
+		case r.StartTime != nil && r.EndTime != nil && r.EndTime.After(*r.StartTime):
+			minutes += r.EndTime.Sub(*r.StartTime).Minutes()
+		}
+	}
+	if sc.StdMinutes > 0 && minutes > 0 {
+		ratePerMinute := sc.LaborCost / sc.StdMinutes
+		v.StdLaborCost = sc.LaborCost * completedQty
+		v.ActualLaborCost = minutes * ratePerMinute
+	}
+
+	v.StdMaterialCost, v.ActualMaterialCost = roundAmount(v.StdMaterialCost), roundAmount(v.ActualMaterialCost)
+	v.StdLaborCost, v.ActualLaborCost = roundAmount(v.StdLaborCost), roundAmount(v.ActualLaborCost)
+	v.MaterialVariance = roundAmount(v.ActualMaterialCost - v.StdMaterialCost)
+	v.LaborVariance = roundAmount(v.ActualLaborCost - v.StdLaborCost)
+	return v
+}
+
+// postWOVariance books the variances of a completed work order in one entry
+// via the wo_material_variance / wo_labor_variance rules. Returns "" when
+// there is nothing to post.
+func postWOVariance(tx *gorm.DB, wo *ErpWorkOrder, v *woCostVariance, postedBy string) (string, error) {
+	entry := ErpJournalEntry{
+		SourceType:  "work_order",
+		SourceID:    wo.ID,
+		Description: fmt.Sprintf("工单差异 %s", wo.Code),
+		PostedBy:    postedBy,
+	}
+	var lines []ErpJournalLine
+	for _, part := range []struct {
+		txType string
+		amount float64
+		label  string
+	}{
+		{postingTxWOMaterialVariance, v.MaterialVariance, "材料用量差异"},
+		{postingTxWOLaborVariance, v.LaborVariance, "人工效率差异"},
+	} {
+		if part.amount == 0 {
+			continue
+		}
+		rule, err := resolvePostingRule(tx, postingContextFor(tx, part.txType, wo.WarehouseID, wo.ProductID))
+		if err != nil {
+			return "", err
+		}
+		if rule == nil {
+			return "", fmt.Errorf("no active posting rule for %s", part.txType)
+		}
+		pair, err := ruleLines(tx, rule, part.amount, &entry, ErpJournalLine{
+			Currency: "CNY", OriginalAmount: part.amount, Description: wo.Code + " " + part.label,
+		})
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, pair...)
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	if err := createPostedEntry(tx, &entry, lines); err != nil {
+		return "", err
+	}
+	return entry.ID, nil
+}
@@ -0,0 +1,615 @@
+package erp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/bitfantasy/acp-module-sdk"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===========================================================================
+// 应收信用控制：信用占用 = 未清应收 + 未发货订单 + 已发货未开票
+//
+// 订单确认时按客户信用额度检查占用，超额按客户 credit_check 策略拒绝
+// (block) 或冻结待审 (hold)，冻结订单经 release_credit_hold 特批后确认。
+// 逾期应收按催款级别逐级升级催款，高级别自动冻结客户信用，逾期清零后解冻。
+// ===========================================================================
+
+// Customer credit_check policies.
+const (
+	creditCheckHold  = "hold"
+	creditCheckBlock = "block"
+	creditCheckNone  = "none"
+)
+
+// Sales order credit_status values.
+const (
+	creditPassed   = "passed"
+	creditHeld     = "held"
+	creditBlocked  = "blocked"
+	creditReleased = "released"
+)
+
+// salesOrderCreditHold is the order status while awaiting credit approval.
+const salesOrderCreditHold = "credit_hold"
+
+// dunningHoldReason prefixes credit holds set by dunning, so that clearing
+// the overdue balance only lifts holds dunning itself placed.
+const dunningHoldReason = "dunning"
+
+// arOpenStatuses are invoice statuses still carrying a receivable; invoices
+// post to 应收账款 on creation, so drafts count.
+var arOpenStatuses = []string{"draft", "issued", "partially_paid", "overdue"}
+
+// creditOrderStatuses are committed orders that consume credit until invoiced.
+var creditOrderStatuses = []string{"confirmed", "in_production", "producing", "ready", "shipped", "delivered"}
+
+func truncDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func daysOverdue(due *time.Time, asOf time.Time) int {
+	if due == nil {
+		return 0
+	}
+	return int(truncDay(asOf).Sub(truncDay(*due)).Hours() / 24)
+}
+
+// ---------------------------------------------------------------------------
+// Exposure
+// ---------------------------------------------------------------------------
+
+type creditExposure struct {
+	CustomerID         string  `json:"customer_id"`
+	CreditLimit        float64 `json:"credit_limit"`
+	OpenAR             float64 `json:"open_ar"`
+	OverdueAR          float64 `json:"overdue_ar"`
+	OpenOrders         float64 `json:"open_orders"`
+	ShippedNotInvoiced float64 `json:"shipped_not_invoiced"`
+	Exposure           float64 `json:"exposure"`
+	Available          float64 `json:"available"`
+	CreditHold         bool    `json:"credit_hold"`
+	DunningLevel       int     `json:"dunning_level"`
+}
+
+// orderCreditValue returns an order's tax-inclusive value and the part
+// already shipped, from its lines (falling back to the header total).
+func orderCreditValue(db *gorm.DB, order *ErpSalesOrder) (value, shipped float64) {
+	var items []ErpSalesOrderItem
+	db.Where("order_id = ?", order.ID).Find(&items)
+	if len(items) == 0 {
+		return order.Total, 0
+	}
+	for _, it := range items {
+		gross := it.LineTotal * (1 + it.TaxRate/100)
+		value += gross
+		if it.Quantity > 0 && it.DeliveredQty > 0 {
+			shipped += gross * min(it.DeliveredQty, it.Quantity) / it.Quantity
+		}
+	}
+	return roundAmount(value), roundAmount(shipped)
+}
+
+// customerExposure computes a customer's credit exposure as of a date.
+// Invoiced amounts are netted off each order so an invoiced shipment is
+// counted once, in open AR. excludeOrderID leaves out the order under check.
+func customerExposure(db *gorm.DB, cust *ErpCustomer, asOf time.Time, excludeOrderID string) creditExposure {
+	exp := creditExposure{CustomerID: cust.ID, CreditLimit: cust.CreditLimit, CreditHold: cust.CreditHold, DunningLevel: cust.DunningLevel}
+
+	var invoices []ErpSalesInvoice
+	db.Where("customer_id = ? AND status IN ?", cust.ID, arOpenStatuses).Find(&invoices)
+	for _, inv := range invoices {
+		open := inv.Total - inv.PaidAmount
+		if open <= 0 {
+			continue
+		}
+		exp.OpenAR += open
+		if daysOverdue(inv.DueDate, asOf) > 0 {
+			exp.OverdueAR += open
+		}
+	}
+
+	var orders []ErpSalesOrder
+	q := db.Where("customer_id = ? AND status IN ?", cust.ID, creditOrderStatuses)
+	if excludeOrderID != "" {
+		q = q.Where("id <> ?", excludeOrderID)
+	}
+	q.Find(&orders)
+	if len(orders) > 0 {
+		ids := make([]string, len(orders))
+		for i, o := range orders {
+			ids[i] = o.ID
+		}
+		var invoiced []struct {
+			OrderID string
+			Total   float64
+		}
+		db.Model(&ErpSalesInvoice{}).Select("order_id, SUM(total) AS total").
+			Where("order_id IN ? AND status <> ?", ids, "cancelled").
+			Group("order_id").Scan(&invoiced)
+		byOrder := map[string]float64{}
+		for _, r := range invoiced {
+			byOrder[r.OrderID] = r.Total
+		}
+		for i := range orders {
+			value, shipped := orderCreditValue(db, &orders[i])
+			billed := byOrder[orders[i].ID]
+			uninvoiced := max(value-billed, 0)
+			sni := max(shipped-billed, 0)
+			exp.ShippedNotInvoiced += sni
+			exp.OpenOrders += uninvoiced - sni
+		}
+	}
+
+	exp.OpenAR = roundAmount(exp.OpenAR)
+	exp.OverdueAR = roundAmount(exp.OverdueAR)
+	exp.OpenOrders = roundAmount(exp.OpenOrders)
+	exp.ShippedNotInvoiced = roundAmount(exp.ShippedNotInvoiced)
+	exp.Exposure = roundAmount(exp.OpenAR + exp.OpenOrders + exp.ShippedNotInvoiced)
+	if cust.CreditLimit > 0 {
+		exp.Available = roundAmount(cust.CreditLimit - exp.Exposure)
+	}
+	return exp
+}
+
+type creditCheckResult struct {
+	Passed      bool
+	Policy      string
+	Reason      string
+	OrderAmount float64
+	Exposure    creditExposure
+}
+
+// checkOrderCredit tests an order against its customer's credit. A zero
+// credit limit means unlimited; a credit hold fails regardless of limit.
+// The customer row is locked until the caller's transaction ends, so
+// concurrent confirmations for one customer see each other's exposure.
+func checkOrderCredit(tx *gorm.DB, order *ErpSalesOrder) (creditCheckResult, error) {
+	res := creditCheckResult{Passed: true, Policy: creditCheckNone}
+	var cust ErpCustomer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&cust, "id = ?", order.CustomerID).Error; err != nil {
+		return res, fmt.Errorf("customer %s of order %s not found", order.CustomerID, order.Code)
+	}
+	res.Policy = cust.CreditCheck
+	if res.Policy == "" {
+		res.Policy = creditCheckHold
+	}
+	if res.Policy == creditCheckNone {
+		return res, nil
+	}
+	res.OrderAmount, _ = orderCreditValue(tx, order)
+	res.Exposure = customerExposure(tx, &cust, time.Now(), order.ID)
+	switch {
+	case cust.CreditHold:
+		res.Passed = false
+		res.Reason = "客户信用冻结"
+		if cust.CreditHoldReason != "" {
+			res.Reason += ": " + cust.CreditHoldReason
+		}
+	case cust.CreditLimit > 0 && res.Exposure.Exposure+res.OrderAmount > cust.CreditLimit+0.005:
+		res.Passed = false
+		res.Reason = fmt.Sprintf("信用占用 %.2f + 本单 %.2f 超过信用额度 %.2f",
+			res.Exposure.Exposure, res.OrderAmount, cust.CreditLimit)
+	}
+	return res, nil
+}
+
+// confirmSalesOrder moves an order to confirmed; extra carries the credit
+// fields to store alongside the status change.
+func confirmSalesOrder(db *gorm.DB, order *ErpSalesOrder, extra map[string]any) error {
+	now := time.Now()
+	updates := map[string]any{"status": "confirmed", "confirmed_at": &now}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	return db.Model(order).Updates(updates).Error
+}
+
+// afterSalesOrderConfirmed runs MRP for a confirmed order. It runs after
+// the confirming transaction so MRP never holds the customer lock.
+func afterSalesOrderConfirmed(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, order *ErpSalesOrder) map[string]any {
+	// Auto-run MRP for this order's items
+	mrpResult, _ := cmdRunMRP(db, adapter, runID, stepID, map[string]any{"demand_source": "so"})
+	mrpSuggestions := 0
+	if m, ok := mrpResult.(map[string]any); ok {
+		if sc, ok := m["suggestion_count"]; ok {
+			if n, ok := sc.(int); ok {
+				mrpSuggestions = n
+			}
+		}
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.sales_order.confirmed",
+		fmt.Sprintf("销售订单确认: %s, MRP生成%d条建议", order.Code, mrpSuggestions),
+		map[string]any{"order_id": order.ID, "code": order.Code, "mrp_suggestions": mrpSuggestions})
+
+	return map[string]any{
+		"order_id": order.ID, "code": order.Code, "status": "confirmed",
+		"credit_status": order.CreditStatus, "mrp_suggestions": mrpSuggestions,
+	}
+}
+
+// cmdReleaseCreditHold — 信用特批：放行或驳回信用冻结/拒绝的订单
+func cmdReleaseCreditHold(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	orderID := getStr(input, "order_id")
+	if orderID == "" {
+		return nil, fmt.Errorf("order_id is required")
+	}
+	approvedBy := getStr(input, "approved_by")
+	if approvedBy == "" {
+		return nil, fmt.Errorf("approved_by is required")
+	}
+	reason := getStr(input, "reason")
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	decision := getStr(input, "decision")
+	if decision == "" {
+		decision = "approve"
+	}
+	if decision != "approve" && decision != "reject" {
+		return nil, fmt.Errorf("decision must be approve or reject")
+	}
+
+	var order ErpSalesOrder
+	if err := db.First(&order, "id = ?", orderID).Error; err != nil {
+		return nil, fmt.Errorf("order not found")
+	}
+	held := order.Status == salesOrderCreditHold
+	blocked := order.Status == "draft" && order.CreditStatus == creditBlocked
+	if !held && !blocked {
+		return nil, fmt.Errorf("order %s is not awaiting credit approval (status %s, credit %s)", order.Code, order.Status, order.CreditStatus)
+	}
+
+	now := time.Now()
+	newStatus := creditReleased
+	if decision == "reject" {
+		newStatus = creditBlocked
+	}
+	db.Create(&ErpAuditLog{
+		ID: uuid.New().String(), EntityType: "sales_order", EntityID: order.ID,
+		Field: "credit_status", OldValue: order.CreditStatus, NewValue: newStatus + ": " + reason,
+		UserID: approvedBy,
+	})
+
+	if decision == "reject" {
+		db.Model(&order).Updates(map[string]any{
+			"status": "draft", "credit_status": creditBlocked, "credit_note": reason,
+		})
+		emitEvent(adapter, runID, stepID, "erp.sales_order.credit_rejected",
+			fmt.Sprintf("信用特批驳回: %s (%s)", order.Code, reason),
+			map[string]any{"order_id": order.ID, "code": order.Code, "approved_by": approvedBy})
+		return map[string]any{"order_id": order.ID, "code": order.Code, "status": "draft", "credit_status": creditBlocked}, nil
+	}
+
+	order.CreditStatus = creditReleased
+	if err := confirmSalesOrder(db, &order, map[string]any{
+		"credit_status":      creditReleased,
+		"credit_released_by": approvedBy,
+		"credit_released_at": &now,
+		"credit_note":        reason,
+	}); err != nil {
+		return nil, fmt.Errorf("confirm order failed: %w", err)
+	}
+	out := afterSalesOrderConfirmed(db, adapter, runID, stepID, &order)
+	emitEvent(adapter, runID, stepID, "erp.sales_order.credit_released",
+		fmt.Sprintf("信用特批放行: %s, 审批人 %s", order.Code, approvedBy),
+		map[string]any{"order_id": order.ID, "code": order.Code, "approved_by": approvedBy, "reason": reason})
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// Dunning
+// ---------------------------------------------------------------------------
+
+var defaultDunningLevels = []ErpDunningLevel{
+	{Level: 1, Name: "付款提醒", DaysOverdue: 1, RepeatDays: 7, Template: "您好，发票 {invoices} 已到期，合计 {amount}，请安排付款。"},
+	{Level: 2, Name: "催款通知", DaysOverdue: 15, RepeatDays: 7, Template: "发票 {invoices} 已逾期 {days} 天，合计 {amount}，请尽快付款。"},
+	{Level: 3, Name: "最后通知", DaysOverdue: 30, RepeatDays: 7, HoldCredit: true, Template: "发票 {invoices} 已逾期 {days} 天，合计 {amount}。贵司信用已冻结，新订单暂停确认。"},
+	{Level: 4, Name: "移交催收", DaysOverdue: 60, RepeatDays: 14, HoldCredit: true, Template: "发票 {invoices} 已逾期 {days} 天，合计 {amount}，已移交催收处理。"},
+}
+
+// loadDunningLevels returns active levels in ascending order, seeding the
+// defaults the first time the table is empty.
+func loadDunningLevels(db *gorm.DB) []ErpDunningLevel {
+	var count int64
+	db.Model(&ErpDunningLevel{}).Count(&count)
+	if count == 0 {
+		for _, l := range defaultDunningLevels {
+			l.ID = uuid.New().String()
+			l.Status = "active"
+			db.Create(&l)
+		}
+	}
+	var levels []ErpDunningLevel
+	db.Where("status = ?", "active").Order("level ASC").Find(&levels)
+	return levels
+}
+
+// dunningLevelFor picks the highest level whose threshold the days overdue reach.
+func dunningLevelFor(levels []ErpDunningLevel, days int) *ErpDunningLevel {
+	var hit *ErpDunningLevel
+	for i := range levels {
+		if days > 0 && days >= levels[i].DaysOverdue {
+			hit = &levels[i]
+		}
+	}
+	return hit
+}
+
+type dunningCandidate struct {
+	invoice *ErpSalesInvoice
+	level   *ErpDunningLevel
+	open    float64
+	days    int
+}
+
+// createDunningNotice records a notice for a customer's invoices and
+// escalates each invoice to the level it was dunned at.
+func createDunningNotice(tx *gorm.DB, customerID string, level *ErpDunningLevel, cands []dunningCandidate, manual, hold bool, now time.Time) (ErpDunningNotice, error) {
+	ids := make([]string, len(cands))
+	var amount float64
+	maxDays := 0
+	for i, c := range cands {
+		ids[i] = c.invoice.ID
+		amount += c.open
+		maxDays = max(maxDays, c.days)
+	}
+	idsJSON, _ := json.Marshal(ids)
+	var notice ErpDunningNotice
+	_, err := autoCodeWithRetry(tx, "erp_dunning_notices", "DN", func(code string) error {
+		notice = ErpDunningNotice{
+			ID: uuid.New().String(), Code: code, CustomerID: customerID,
+			InvoiceIDs: string(idsJSON), InvoiceCount: len(cands),
+			OverdueAmount: roundAmount(amount), MaxDaysOverdue: maxDays,
+			Manual: manual, CreditHold: hold, SentAt: &now,
+		}
+		if level != nil {
+			notice.Level, notice.LevelName = level.Level, level.Name
+		}
+		return tx.Create(&notice).Error
+	})
+	if err != nil {
+		return notice, err
+	}
+	for _, c := range cands {
+		updates := map[string]any{"last_dunning_at": &now}
+		if c.level != nil && c.level.Level > c.invoice.DunningLevel {
+			updates["dunning_level"] = c.level.Level
+		}
+		if err := tx.Model(&ErpSalesInvoice{}).Where("id = ?", c.invoice.ID).Updates(updates).Error; err != nil {
+			return notice, err
+		}
+	}
+	return notice, nil
+}
+
+// cmdRunDunning — 催款运行：按逾期天数升级催款级别，发送通知并按级别冻结信用
+func cmdRunDunning(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	asOf := time.Now()
+	if d := parseDate(getStr(input, "as_of")); d != nil {
+		asOf = *d
+	}
+	day := truncDay(asOf)
+	customerID := getStr(input, "customer_id")
+	dryRun := getBool(input, "dry_run")
+
+	levels := loadDunningLevels(db)
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("no active dunning levels configured")
+	}
+
+	q := db.Where("status IN ? AND due_date < ?", arOpenStatuses, day)
+	if customerID != "" {
+		q = q.Where("customer_id = ?", customerID)
+	}
+	var invoices []ErpSalesInvoice
+	q.Order("customer_id, due_date ASC").Find(&invoices)
+
+	// 每个客户：到期应催的发票，以及逾期发票达到的最高级别
+	due := map[string][]dunningCandidate{}
+	custLevel := map[string]int{}
+	for i := range invoices {
+		inv := &invoices[i]
+		open := roundAmount(inv.Total - inv.PaidAmount)
+		if open <= 0 {
+			continue
+		}
+		days := daysOverdue(inv.DueDate, day)
+		lvl := dunningLevelFor(levels, days)
+		if lvl == nil {
+			continue
+		}
+		custLevel[inv.CustomerID] = max(custLevel[inv.CustomerID], lvl.Level)
+		repeat := lvl.Level == inv.DunningLevel &&
+			(inv.LastDunningAt == nil || day.Sub(truncDay(*inv.LastDunningAt)) >= time.Duration(lvl.RepeatDays)*24*time.Hour)
+		if lvl.Level > inv.DunningLevel || repeat {
+			due[inv.CustomerID] = append(due[inv.CustomerID], dunningCandidate{invoice: inv, level: lvl, open: open, days: days})
+		}
+	}
+
+	customerIDs := make([]string, 0, len(due))
+	for id := range due {
+		customerIDs = append(customerIDs, id)
+	}
+	sort.Strings(customerIDs)
+
+	now := time.Now()
+	notices := []map[string]any{}
+	holds := 0
+	for _, cid := range customerIDs {
+		cands := due[cid]
+		level := cands[0].level
+		for _, c := range cands {
+			if c.level.Level > level.Level {
+				level = c.level
+			}
+		}
+		var cust ErpCustomer
+		db.First(&cust, "id = ?", cid)
+		hold := level.HoldCredit && !cust.CreditHold
+
+		row := map[string]any{"customer_id": cid, "customer_name": cust.Name, "level": level.Level,
+			"level_name": level.Name, "invoice_count": len(cands), "credit_hold": hold}
+		if dryRun {
+			notices = append(notices, row)
+			continue
+		}
+
+		var notice ErpDunningNotice
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			notice, err = createDunningNotice(tx, cid, level, cands, false, hold, now)
+			if err != nil {
+				return err
+			}
+			updates := map[string]any{"dunning_level": custLevel[cid], "last_dunning_at": &now}
+			if hold {
+				updates["credit_hold"] = true
+				updates["credit_hold_reason"] = fmt.Sprintf("%s: L%d %s", dunningHoldReason, level.Level, level.Name)
+			}
+			return tx.Model(&ErpCustomer{}).Where("id = ?", cid).Updates(updates).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("dunning customer %s failed: %w", cid, err)
+		}
+		if hold {
+			holds++
+		}
+		row["notice_id"], row["code"], row["overdue_amount"] = notice.ID, notice.Code, notice.OverdueAmount
+		notices = append(notices, row)
+
+		emitEvent(adapter, runID, stepID, "erp.payment.reminder_sent",
+			fmt.Sprintf("催款通知(L%d %s)已发送: %s 逾期 ¥%.0f", level.Level, level.Name, cust.Name, notice.OverdueAmount),
+			map[string]any{"notice_id": notice.ID, "customer_id": cid, "level": level.Level,
+				"amount": notice.OverdueAmount, "invoice_ids": notice.InvoiceIDs, "template": level.Template})
+		if hold {
+			emitEvent(adapter, runID, stepID, "erp.customer.credit_hold",
+				fmt.Sprintf("客户信用冻结: %s (催款 L%d)", cust.Name, level.Level),
+				map[string]any{"customer_id": cid, "level": level.Level})
+		}
+	}
+
+	// 逾期已清的客户：级别归零，解除由催款设置的信用冻结
+	released := 0
+	var cleared []ErpCustomer
+	cq := db.Where("dunning_level > 0 OR (credit_hold AND credit_hold_reason LIKE ?)", dunningHoldReason+"%")
+	if customerID != "" {
+		cq = cq.Where("id = ?", customerID)
+	}
+	cq.Find(&cleared)
+	for _, cust := range cleared {
+		if _, overdue := custLevel[cust.ID]; overdue {
+			continue
+		}
+		release := cust.CreditHold && strings.HasPrefix(cust.CreditHoldReason, dunningHoldReason)
+		if release {
+			released++
+		}
+		if dryRun {
+			continue
+		}
+		updates := map[string]any{"dunning_level": 0}
+		if release {
+			updates["credit_hold"] = false
+			updates["credit_hold_reason"] = ""
+			emitEvent(adapter, runID, stepID, "erp.customer.credit_hold_released",
+				fmt.Sprintf("逾期已清，解除信用冻结: %s", cust.Name),
+				map[string]any{"customer_id": cust.ID})
+		}
+		db.Model(&ErpCustomer{}).Where("id = ?", cust.ID).Updates(updates)
+	}
+
+	return map[string]any{
+		"as_of": day.Format("2006-01-02"), "dry_run": dryRun,
+		"notice_count": len(notices), "credit_holds": holds, "credit_released": released,
+		"notices": notices,
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// AR aging
+// ---------------------------------------------------------------------------
+
+type arAgingRow struct {
+	CustomerID   string  `json:"customer_id"`
+	CustomerCode string  `json:"customer_code"`
+	CustomerName string  `json:"customer_name"`
+	Current      float64 `json:"current"`
+	Days30       float64 `json:"days_30"`
+	Days60       float64 `json:"days_60"`
+	Days90       float64 `json:"days_90"`
+	Over90       float64 `json:"over_90"`
+	Total        float64 `json:"total"`
+	InvoiceCount int     `json:"invoice_count"`
+	CreditLimit  float64 `json:"credit_limit"`
+	CreditHold   bool    `json:"credit_hold"`
+	DunningLevel int     `json:"dunning_level"`
+}
+
+func (r *arAgingRow) add(o arAgingRow) {
+	r.Current += o.Current
+	r.Days30 += o.Days30
+	r.Days60 += o.Days60
+	r.Days90 += o.Days90
+	r.Over90 += o.Over90
+	r.Total += o.Total
+	r.InvoiceCount += o.InvoiceCount
+}
+
+// arAging buckets open receivables per customer as of a date: not yet due
+// and overdue 1-30 / 31-60 / 61-90 / >90 days.
+func arAging(db *gorm.DB, asOf time.Time) ([]arAgingRow, arAgingRow) {
+	var invoices []ErpSalesInvoice
+	db.Where("status IN ?", arOpenStatuses).Find(&invoices)
+
+	rows := map[string]*arAgingRow{}
+	for i := range invoices {
+		inv := &invoices[i]
+		open := roundAmount(inv.Total - inv.PaidAmount)
+		if open <= 0 {
+			continue
+		}
+		var b arAgingRow
+		b.Total, b.InvoiceCount = open, 1
+		switch days := daysOverdue(inv.DueDate, asOf); {
+		case days <= 0:
+			b.Current = open
+		case days <= 30:
+			b.Days30 = open
+		case days <= 60:
+			b.Days60 = open
+		case days <= 90:
+			b.Days90 = open
+		default:
+			b.Over90 = open
+		}
+		r, ok := rows[inv.CustomerID]
+		if !ok {
+			var cust ErpCustomer
+			db.First(&cust, "id = ?", inv.CustomerID)
+			r = &arAgingRow{CustomerID: inv.CustomerID, CustomerCode: cust.Code, CustomerName: cust.Name,
+				CreditLimit: cust.CreditLimit, CreditHold: cust.CreditHold, DunningLevel: cust.DunningLevel}
+			rows[inv.CustomerID] = r
+		}
+		r.add(b)
+	}
+
+	var total arAgingRow
+	out := make([]arAgingRow, 0, len(rows))
+	for _, r := range rows {
+		for _, v := range []*float64{&r.Current, &r.Days30, &r.Days60, &r.Days90, &r.Over90, &r.Total} {
+			*v = roundAmount(*v)
+		}
+		total.add(*r)
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Total > out[j].Total })
+	return out, total
+}
@@ -53,6 +53,19 @@ func customerEntity() sdk.EntityDef {
 				Width:  90,
 			},
 			{Name: "credit_limit", Label: "信用额度", Type: "number", Precision: intPtr(2), Unit: "¥", HideInList: true},
+			{
+				Name: "credit_check", Label: "超额处理", Type: "select", Default: "hold",
+				Options: []sdk.FieldOption{
+					{Value: "hold", Label: "冻结待审", Color: "orange"},
+					{Value: "block", Label: "拒绝确认", Color: "red"},
+					{Value: "none", Label: "不检查", Color: "default"},
+				},
+				HideInList: true,
+			},
+			{Name: "credit_hold", Label: "信用冻结", Type: "boolean", Default: false, Width: 80},
+			{Name: "credit_hold_reason", Label: "冻结原因", Type: "string", HideInList: true},
+			{Name: "dunning_level", Label: "催款级别", Type: "integer", ReadOnly: true, Width: 80},
+			{Name: "last_dunning_at", Label: "最近催款", Type: "datetime", ReadOnly: true, HideInList: true},
 			{
 				Name: "currency", Label: "默认币种", Type: "select", Default: "CNY",
 				Options: []sdk.FieldOption{
@@ -75,11 +88,11 @@ func customerEntity() sdk.EntityDef {
 			{Name: "created_at", Label: "创建时间", Type: "datetime", ReadOnly: true, Width: 160},
 			{Name: "updated_at", Label: "更新时间", Type: "datetime", ReadOnly: true, HideInList: true},
 		},
-		ListColumns:  []string{"code", "name", "short_name", "type", "contact_name", "contact_phone", "payment_terms", "status", "created_at"},
+		ListColumns:  []string{"code", "name", "short_name", "type", "contact_name", "contact_phone", "payment_terms", "credit_hold", "dunning_level", "status", "created_at"},
 		DefaultSort:  "created_at",
 		DefaultOrder: "desc",
 		Searchable:   []string{"name", "code", "short_name", "contact_name"},
-		Filters:      []string{"type", "status", "payment_terms"},
+		Filters:      []string{"type", "status", "payment_terms", "credit_hold"},
 	}
 }
 
@@ -217,6 +230,34 @@ func quotationEntity() sdk.EntityDef {
 				Width:  90,
 				HideInList: true,
 			},
+			{
+				Name: "channel", Label: "渠道", Type: "select", Default: "direct",
+				Options: []sdk.FieldOption{
+					{Value: "direct", Label: "直销", Color: "blue"},
+					{Value: "ecommerce", Label: "电商", Color: "cyan"},
+					{Value: "dealer", Label: "代理", Color: "purple"},
+					{Value: "retail", Label: "门店", Color: "orange"},
+				},
+				Render: &sdk.FieldRender{Type: "tag"},
+				Width:  80,
+			},
+			{Name: "cost", Label: "成本", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "margin_pct", Label: "毛利率%", Type: "number", Precision: intPtr(2), ReadOnly: true, Width: 90, HideInForm: true},
+			{Name: "min_margin_pct", Label: "毛利阈值%", Type: "number", Precision: intPtr(2), ReadOnly: true, HideInList: true, HideInForm: true},
+			{
+				Name: "approval_status", Label: "毛利审批", Type: "select", ReadOnly: true,
+				Options: []sdk.FieldOption{
+					{Value: "pending", Label: "待审批", Color: "orange"},
+					{Value: "approved", Label: "已批准", Color: "green"},
+					{Value: "rejected", Label: "已驳回", Color: "red"},
+				},
+				Render:     &sdk.FieldRender{Type: "badge"},
+				Width:      90,
+				HideInForm: true,
+			},
+			{Name: "approved_by", Label: "审批人", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "approved_at", Label: "审批时间", Type: "datetime", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "approval_note", Label: "审批意见", Type: "text", ReadOnly: true, HideInList: true, HideInForm: true},
 			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
 			{
 				Name: "status", Label: "状态", Type: "select", Default: "draft",
@@ -233,11 +274,11 @@ func quotationEntity() sdk.EntityDef {
 			{Name: "created_by", Label: "创建人", Type: "string", ReadOnly: true, Width: 90, HideInForm: true},
 			{Name: "created_at", Label: "创建时间", Type: "datetime", ReadOnly: true, Width: 160},
 		},
-		ListColumns:  []string{"code", "customer_id", "currency", "subtotal", "total", "valid_until", "status", "created_by", "created_at"},
+		ListColumns:  []string{"code", "customer_id", "channel", "currency", "subtotal", "total", "margin_pct", "approval_status", "valid_until", "status", "created_by", "created_at"},
 		DefaultSort:  "created_at",
 		DefaultOrder: "desc",
 		Searchable:   []string{"code"},
-		Filters:      []string{"customer_id", "status", "currency"},
+		Filters:      []string{"customer_id", "status", "currency", "channel", "approval_status"},
 		Relations: []sdk.RelationDef{
 			{Name: "items", Label: "报价明细", Type: "has_many", Target: "quotation_items", ForeignKey: "quotation_id", Display: "table"},
 		},
@@ -266,9 +307,28 @@ func quotationItemEntity() sdk.EntityDef {
 			{Name: "discount_pct", Label: "折扣%", Type: "number", Precision: intPtr(2), Width: 70},
 			{Name: "tax_rate", Label: "税率%", Type: "number", Precision: intPtr(2), Width: 70},
 			{Name: "line_total", Label: "行合计", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 100},
+			{Name: "list_price", Label: "价目价", Type: "number", ReadOnly: true, Precision: intPtr(4), Unit: "¥", Width: 100, HideInForm: true},
+			{
+				Name: "price_source", Label: "取价来源", Type: "select", ReadOnly: true,
+				Options: []sdk.FieldOption{
+					{Value: "customer", Label: "客户专属价", Color: "purple"},
+					{Value: "price_list", Label: "价目表", Color: "blue"},
+					{Value: "manual", Label: "手工", Color: "orange"},
+				},
+				Render:     &sdk.FieldRender{Type: "tag"},
+				Width:      90,
+				HideInForm: true,
+			},
+			{Name: "price_list_id", Label: "价目表", Type: "relation", ReadOnly: true, RefEntity: "price_lists", RefModule: "erp", RefDisplay: "name", HideInList: true, HideInForm: true},
+			{Name: "promotion_id", Label: "促销", Type: "relation", ReadOnly: true, RefEntity: "promotions", RefModule: "erp", RefDisplay: "name", HideInList: true, HideInForm: true},
+			{Name: "unit_cost", Label: "单位成本", Type: "number", ReadOnly: true, Precision: intPtr(4), Unit: "¥", HideInList: true, HideInForm: true},
+			{Name: "cost_source", Label: "成本来源", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "margin_pct", Label: "毛利率%", Type: "number", ReadOnly: true, Precision: intPtr(2), Width: 90, HideInForm: true},
+			{Name: "below_margin", Label: "低于阈值", Type: "boolean", ReadOnly: true, Width: 80, HideInForm: true},
+			{Name: "cost_unknown", Label: "无成本", Type: "boolean", ReadOnly: true, Width: 70, HideInForm: true},
 			{Name: "sort_order", Label: "排序", Type: "integer", HideInList: true, HideInForm: true},
 		},
-		ListColumns:  []string{"product_id", "description", "quantity", "unit_price", "discount_pct", "tax_rate", "line_total"},
+		ListColumns:  []string{"product_id", "description", "quantity", "list_price", "unit_price", "discount_pct", "tax_rate", "line_total", "price_source", "margin_pct", "below_margin", "cost_unknown"},
 		DefaultSort:  "sort_order",
 		DefaultOrder: "asc",
 		Searchable:   []string{"description"},
@@ -276,6 +336,185 @@ func quotationItemEntity() sdk.EntityDef {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// priceListEntity — erp_price_lists
+// ---------------------------------------------------------------------------
+
+func priceListEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "price_lists",
+		Label:      "价目表",
+		Table:      "erp_price_lists",
+		PrimaryKey: "id",
+		Icon:       "TagsOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "价目表编码", Type: "string", Required: true, Unique: true, Width: 120},
+			{Name: "name", Label: "名称", Type: "string", Required: true, Width: 160},
+			{
+				Name: "channel", Label: "渠道", Type: "select",
+				Options: []sdk.FieldOption{
+					{Value: "direct", Label: "直销", Color: "blue"},
+					{Value: "ecommerce", Label: "电商", Color: "cyan"},
+					{Value: "dealer", Label: "代理", Color: "purple"},
+					{Value: "retail", Label: "门店", Color: "orange"},
+				},
+				Render: &sdk.FieldRender{Type: "tag"},
+				Width:  80,
+			},
+			{
+				Name: "currency", Label: "币种", Type: "select", Default: "CNY",
+				Options: []sdk.FieldOption{
+					{Value: "CNY", Label: "CNY", Color: "red"},
+					{Value: "USD", Label: "USD", Color: "green"},
+				},
+				Width: 70,
+			},
+			{Name: "customer_id", Label: "专属客户", Type: "relation", RefEntity: "customers", RefModule: "erp", RefDisplay: "name", Width: 120},
+			{Name: "priority", Label: "优先级", Type: "integer", Default: 0, Width: 70},
+			{Name: "valid_from", Label: "生效日", Type: "date", Width: 110},
+			{Name: "valid_to", Label: "失效日", Type: "date", Width: 110},
+			{
+				Name: "status", Label: "状态", Type: "select", Default: "active",
+				Options: []sdk.FieldOption{
+					{Value: "active", Label: "启用", Color: "green"},
+					{Value: "inactive", Label: "停用", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  80,
+			},
+			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
+		},
+		ListColumns:  []string{"code", "name", "channel", "currency", "customer_id", "priority", "valid_from", "valid_to", "status"},
+		DefaultSort:  "code",
+		DefaultOrder: "asc",
+		Searchable:   []string{"code", "name"},
+		Filters:      []string{"channel", "currency", "customer_id", "status"},
+		Relations: []sdk.RelationDef{
+			{Name: "items", Label: "价格明细", Type: "has_many", Target: "price_list_items", ForeignKey: "price_list_id", Display: "table"},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// priceListItemEntity — erp_price_list_items
+// ---------------------------------------------------------------------------
+
+func priceListItemEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "price_list_items",
+		Label:      "价格明细",
+		Table:      "erp_price_list_items",
+		PrimaryKey: "id",
+		Icon:       "UnorderedListOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "price_list_id", Label: "价目表", Type: "relation", Required: true, RefEntity: "price_lists", RefModule: "erp", RefDisplay: "name", Width: 140},
+			{Name: "product_id", Label: "产品", Type: "relation", Required: true, RefEntity: "products", RefModule: "plm", RefDisplay: "name", Width: 140},
+			{Name: "min_qty", Label: "起订量", Type: "number", Default: 0, Precision: intPtr(4), Width: 90},
+			{Name: "unit_price", Label: "单价", Type: "number", Required: true, Precision: intPtr(4), Unit: "¥", Width: 100},
+		},
+		ListColumns:  []string{"price_list_id", "product_id", "min_qty", "unit_price"},
+		DefaultSort:  "min_qty",
+		DefaultOrder: "asc",
+		Searchable:   []string{},
+		Filters:      []string{"price_list_id", "product_id"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// promotionEntity — erp_promotions
+// ---------------------------------------------------------------------------
+
+func promotionEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "promotions",
+		Label:      "促销折扣",
+		Table:      "erp_promotions",
+		PrimaryKey: "id",
+		Icon:       "GiftOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "促销编码", Type: "string", Required: true, Unique: true, Width: 120},
+			{Name: "name", Label: "名称", Type: "string", Required: true, Width: 160},
+			{
+				Name: "channel", Label: "渠道", Type: "select",
+				Options: []sdk.FieldOption{
+					{Value: "direct", Label: "直销", Color: "blue"},
+					{Value: "ecommerce", Label: "电商", Color: "cyan"},
+					{Value: "dealer", Label: "代理", Color: "purple"},
+					{Value: "retail", Label: "门店", Color: "orange"},
+				},
+				Render: &sdk.FieldRender{Type: "tag"},
+				Width:  80,
+			},
+			{Name: "customer_id", Label: "指定客户", Type: "relation", RefEntity: "customers", RefModule: "erp", RefDisplay: "name", Width: 120},
+			{Name: "product_id", Label: "指定产品", Type: "relation", RefEntity: "products", RefModule: "plm", RefDisplay: "name", Width: 120},
+			{Name: "min_qty", Label: "起订量", Type: "number", Default: 0, Precision: intPtr(4), Width: 90},
+			{Name: "discount_pct", Label: "折扣%", Type: "number", Required: true, Precision: intPtr(2), Width: 80},
+			{Name: "valid_from", Label: "开始日", Type: "date", Required: true, Width: 110},
+			{Name: "valid_to", Label: "结束日", Type: "date", Required: true, Width: 110},
+			{
+				Name: "status", Label: "状态", Type: "select", Default: "active",
+				Options: []sdk.FieldOption{
+					{Value: "active", Label: "启用", Color: "green"},
+					{Value: "inactive", Label: "停用", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  80,
+			},
+		},
+		ListColumns:  []string{"code", "name", "channel", "customer_id", "product_id", "min_qty", "discount_pct", "valid_from", "valid_to", "status"},
+		DefaultSort:  "valid_from",
+		DefaultOrder: "desc",
+		Searchable:   []string{"code", "name"},
+		Filters:      []string{"channel", "customer_id", "product_id", "status"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// marginRuleEntity — erp_margin_rules
+// ---------------------------------------------------------------------------
+
+func marginRuleEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "margin_rules",
+		Label:      "毛利管控",
+		Table:      "erp_margin_rules",
+		PrimaryKey: "id",
+		Icon:       "RiseOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{
+				Name: "channel", Label: "渠道(空为默认)", Type: "select", Unique: true,
+				Options: []sdk.FieldOption{
+					{Value: "direct", Label: "直销", Color: "blue"},
+					{Value: "ecommerce", Label: "电商", Color: "cyan"},
+					{Value: "dealer", Label: "代理", Color: "purple"},
+					{Value: "retail", Label: "门店", Color: "orange"},
+				},
+				Render: &sdk.FieldRender{Type: "tag"},
+				Width:  120,
+			},
+			{Name: "min_margin_pct", Label: "最低毛利率%", Type: "number", Required: true, Precision: intPtr(2), Width: 110},
+			{
+				Name: "status", Label: "状态", Type: "select", Default: "active",
+				Options: []sdk.FieldOption{
+					{Value: "active", Label: "启用", Color: "green"},
+					{Value: "inactive", Label: "停用", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  80,
+			},
+		},
+		ListColumns:  []string{"channel", "min_margin_pct", "status"},
+		DefaultSort:  "channel",
+		DefaultOrder: "asc",
+		Searchable:   []string{},
+		Filters:      []string{"status"},
+	}
+}
+
 // ---------------------------------------------------------------------------
 // salesOrderEntity — erp_sales_orders
 // ---------------------------------------------------------------------------
@@ -318,6 +557,21 @@ func salesOrderEntity() sdk.EntityDef {
 			},
 			{Name: "expected_date", Label: "期望交货日", Type: "date", Width: 110},
 			{Name: "shipping_method", Label: "物流方式", Type: "string", HideInList: true},
+			{Name: "is_export", Label: "出口订单", Type: "boolean", Default: false, HideInList: true},
+			{
+				Name: "incoterm", Label: "贸易术语", Type: "select",
+				Options: []sdk.FieldOption{
+					{Value: "EXW", Label: "EXW"},
+					{Value: "FCA", Label: "FCA"},
+					{Value: "FOB", Label: "FOB"},
+					{Value: "CFR", Label: "CFR"},
+					{Value: "CIF", Label: "CIF"},
+					{Value: "DAP", Label: "DAP"},
+					{Value: "DDP", Label: "DDP"},
+				},
+				HideInList: true,
+			},
+			{Name: "destination_country", Label: "目的国", Type: "string", HideInList: true},
 			{
 				Name: "priority", Label: "优先级", Type: "select", Default: "normal",
 				Options: []sdk.FieldOption{
@@ -332,6 +586,7 @@ func salesOrderEntity() sdk.EntityDef {
 				Name: "status", Label: "状态", Type: "select", Default: "draft",
 				Options: []sdk.FieldOption{
 					{Value: "draft", Label: "草稿", Color: "default"},
+					{Value: "credit_hold", Label: "信用冻结", Color: "warning"},
 					{Value: "confirmed", Label: "已确认", Color: "processing"},
 					{Value: "producing", Label: "生产中", Color: "blue"},
 					{Value: "ready", Label: "待发货", Color: "cyan"},
@@ -343,28 +598,45 @@ func salesOrderEntity() sdk.EntityDef {
 				Render: &sdk.FieldRender{
 					Type: "tag",
 					ColorMap: map[string]string{
-						"draft":     "#d9d9d9",
-						"confirmed": "#1677ff",
-						"producing": "#0958d9",
-						"ready":     "#13c2c2",
-						"shipped":   "#722ed1",
-						"delivered": "#52c41a",
-						"closed":    "#8c8c8c",
-						"cancelled": "#f5222d",
+						"draft":       "#d9d9d9",
+						"credit_hold": "#fa8c16",
+						"confirmed":   "#1677ff",
+						"producing":   "#0958d9",
+						"ready":       "#13c2c2",
+						"shipped":     "#722ed1",
+						"delivered":   "#52c41a",
+						"closed":      "#8c8c8c",
+						"cancelled":   "#f5222d",
 					},
 				},
 				Width: 80,
 			},
+			{
+				Name: "credit_status", Label: "信用检查", Type: "select", ReadOnly: true,
+				Options: []sdk.FieldOption{
+					{Value: "passed", Label: "通过", Color: "green"},
+					{Value: "held", Label: "冻结待审", Color: "orange"},
+					{Value: "blocked", Label: "已拒绝", Color: "red"},
+					{Value: "released", Label: "特批放行", Color: "blue"},
+				},
+				Render:     &sdk.FieldRender{Type: "tag"},
+				Width:      90,
+				HideInForm: true,
+			},
+			{Name: "credit_exposure", Label: "确认时信用占用", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "credit_released_by", Label: "放行审批人", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "credit_released_at", Label: "放行时间", Type: "datetime", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "credit_note", Label: "信用审批意见", Type: "text", ReadOnly: true, HideInList: true, HideInForm: true},
 			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
 			{Name: "created_by", Label: "创建人", Type: "string", ReadOnly: true, Width: 90, HideInForm: true},
 			{Name: "confirmed_at", Label: "确认时间", Type: "datetime", ReadOnly: true, HideInList: true},
 			{Name: "created_at", Label: "创建时间", Type: "datetime", ReadOnly: true, Width: 160},
 		},
-		ListColumns:  []string{"code", "customer_id", "total", "expected_date", "priority", "status", "created_by", "created_at"},
+		ListColumns:  []string{"code", "customer_id", "total", "expected_date", "priority", "status", "credit_status", "created_by", "created_at"},
 		DefaultSort:  "created_at",
 		DefaultOrder: "desc",
 		Searchable:   []string{"code"},
-		Filters:      []string{"customer_id", "status", "priority"},
+		Filters:      []string{"customer_id", "status", "priority", "credit_status"},
 		Relations: []sdk.RelationDef{
 			{Name: "items", Label: "订单明细", Type: "has_many", Target: "sales_order_items", ForeignKey: "order_id", Display: "table"},
 			{Name: "shipments", Label: "发货单", Type: "has_many", Target: "shipments", ForeignKey: "order_id", Display: "table"},
@@ -439,27 +711,34 @@ func shipmentEntity() sdk.EntityDef {
 			{Name: "shipping_address", Label: "收货地址", Type: "text", HideInList: true},
 			{Name: "carrier", Label: "承运商", Type: "string", Width: 100},
 			{Name: "tracking_no", Label: "物流单号", Type: "string", Width: 140},
+			{Name: "package_count", Label: "件数", Type: "integer", ReadOnly: true, HideInList: true},
+			{Name: "gross_weight", Label: "毛重", Type: "number", Precision: intPtr(2), Unit: "kg", ReadOnly: true, HideInList: true},
+			{Name: "volume", Label: "体积", Type: "number", Precision: intPtr(3), Unit: "m³", ReadOnly: true, HideInList: true},
 			{Name: "shipped_at", Label: "发货时间", Type: "datetime", Width: 160},
 			{Name: "delivered_at", Label: "签收时间", Type: "datetime", HideInList: true},
 			{
 				Name: "status", Label: "状态", Type: "select", Default: "draft",
 				Options: []sdk.FieldOption{
 					{Value: "draft", Label: "草稿", Color: "default"},
+					{Value: "pending", Label: "待发货", Color: "processing"},
 					{Value: "picking", Label: "拣货中", Color: "processing"},
 					{Value: "packed", Label: "已打包", Color: "cyan"},
 					{Value: "shipped", Label: "已发货", Color: "purple"},
 					{Value: "delivered", Label: "已签收", Color: "success"},
 					{Value: "returned", Label: "已退回", Color: "error"},
+					{Value: "cancelled", Label: "已取消", Color: "default"},
 				},
 				Render: &sdk.FieldRender{
 					Type: "tag",
 					ColorMap: map[string]string{
 						"draft":     "#d9d9d9",
+						"pending":   "#1677ff",
 						"picking":   "#1677ff",
 						"packed":    "#13c2c2",
 						"shipped":   "#722ed1",
 						"delivered": "#52c41a",
 						"returned":  "#f5222d",
+						"cancelled": "#8c8c8c",
 					},
 				},
 				Width: 80,
@@ -474,6 +753,7 @@ func shipmentEntity() sdk.EntityDef {
 		Relations: []sdk.RelationDef{
 			{Name: "items", Label: "发货明细", Type: "has_many", Target: "shipment_items", ForeignKey: "shipment_id", Display: "table"},
 			{Name: "oqc", Label: "出货检验", Type: "has_many", Target: "oqc_inspections", ForeignKey: "shipment_id", Display: "table"},
+			{Name: "packages", Label: "包装件", Type: "has_many", Target: "packages", ForeignKey: "shipment_id", Display: "table"},
 		},
 	}
 }
@@ -506,6 +786,219 @@ func shipmentItemEntity() sdk.EntityDef {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// packageEntity — erp_packages
+// ---------------------------------------------------------------------------
+
+func packageEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "packages",
+		Label:      "包装件",
+		Table:      "erp_packages",
+		PrimaryKey: "id",
+		Icon:       "InboxOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "包装编号", Type: "string", ReadOnly: true, Unique: true, Width: 140},
+			{Name: "shipment_id", Label: "发货单", Type: "relation", ReadOnly: true, RefEntity: "shipments", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "parent_id", Label: "所在托盘", Type: "relation", ReadOnly: true, RefEntity: "packages", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{
+				Name: "type", Label: "类型", Type: "select", ReadOnly: true,
+				Options: []sdk.FieldOption{
+					{Value: "carton", Label: "纸箱", Color: "blue"},
+					{Value: "pallet", Label: "托盘", Color: "purple"},
+				},
+				Render: &sdk.FieldRender{Type: "tag"},
+				Width:  70,
+			},
+			{Name: "seq", Label: "序号", Type: "integer", ReadOnly: true, Width: 60},
+			{Name: "sscc", Label: "SSCC", Type: "string", ReadOnly: true, Unique: true, Width: 180},
+			{Name: "length", Label: "长", Type: "number", Precision: intPtr(1), Unit: "cm", HideInList: true},
+			{Name: "width", Label: "宽", Type: "number", Precision: intPtr(1), Unit: "cm", HideInList: true},
+			{Name: "height", Label: "高", Type: "number", Precision: intPtr(1), Unit: "cm", HideInList: true},
+			{Name: "gross_weight", Label: "毛重", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "kg", Width: 90},
+			{Name: "net_weight", Label: "净重", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "kg", Width: 90},
+			{
+				Name: "status", Label: "状态", Type: "select", ReadOnly: true, Default: "open",
+				Options: []sdk.FieldOption{
+					{Value: "open", Label: "装箱中", Color: "processing"},
+					{Value: "sealed", Label: "已封箱", Color: "success"},
+					{Value: "void", Label: "已作废", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  80,
+			},
+			{Name: "sealed_at", Label: "封箱时间", Type: "datetime", ReadOnly: true, Width: 160},
+			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
+		},
+		ListColumns:  []string{"code", "shipment_id", "type", "seq", "sscc", "parent_id", "gross_weight", "status", "sealed_at"},
+		DefaultSort:  "created_at",
+		DefaultOrder: "desc",
+		Searchable:   []string{"code", "sscc"},
+		Filters:      []string{"shipment_id", "type", "status"},
+		Relations: []sdk.RelationDef{
+			{Name: "contents", Label: "装箱明细", Type: "has_many", Target: "package_contents", ForeignKey: "package_id", Display: "table"},
+			{Name: "children", Label: "托盘纸箱", Type: "has_many", Target: "packages", ForeignKey: "parent_id", Display: "table"},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// packageContentEntity — erp_package_contents
+// ---------------------------------------------------------------------------
+
+func packageContentEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "package_contents",
+		Label:      "装箱明细",
+		Table:      "erp_package_contents",
+		PrimaryKey: "id",
+		Icon:       "UnorderedListOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "package_id", Label: "包装件", Type: "relation", ReadOnly: true, RefEntity: "packages", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "shipment_id", Label: "发货单", Type: "relation", ReadOnly: true, RefEntity: "shipments", RefModule: "erp", RefDisplay: "code", HideInList: true},
+			{Name: "shipment_item_id", Label: "发货明细", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "product_id", Label: "产品", Type: "relation", ReadOnly: true, RefEntity: "products", RefModule: "plm", RefDisplay: "name", Width: 120},
+			{Name: "lot_number", Label: "批次号", Type: "string", ReadOnly: true, Width: 100},
+			{Name: "serial_number", Label: "序列号", Type: "string", ReadOnly: true, Width: 140},
+			{Name: "quantity", Label: "数量", Type: "number", ReadOnly: true, Precision: intPtr(4), Width: 90},
+			{Name: "released", Label: "已释放", Type: "boolean", ReadOnly: true, HideInList: true},
+			{Name: "created_at", Label: "装箱时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"package_id", "product_id", "lot_number", "serial_number", "quantity", "created_at"},
+		DefaultSort:  "created_at",
+		DefaultOrder: "asc",
+		Searchable:   []string{"serial_number", "lot_number"},
+		Filters:      []string{"package_id", "shipment_id", "product_id"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ssccConfigEntity — erp_sscc_configs
+// ---------------------------------------------------------------------------
+
+func ssccConfigEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "sscc_configs",
+		Label:      "SSCC编码",
+		Table:      "erp_sscc_configs",
+		PrimaryKey: "id",
+		Icon:       "BarcodeOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "extension_digit", Label: "扩展位", Type: "integer", Default: 0, Width: 80},
+			{Name: "company_prefix", Label: "厂商识别代码", Type: "string", Required: true, Width: 140},
+			{Name: "next_serial", Label: "下一流水号", Type: "integer", Default: 1, Width: 120},
+			{Name: "updated_at", Label: "更新时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"extension_digit", "company_prefix", "next_serial", "updated_at"},
+		DefaultSort:  "created_at",
+		DefaultOrder: "asc",
+		Searchable:   []string{"company_prefix"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// customsProductEntity — erp_customs_products
+// ---------------------------------------------------------------------------
+
+func customsProductEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "customs_products",
+		Label:      "报关资料",
+		Table:      "erp_customs_products",
+		PrimaryKey: "id",
+		Icon:       "GlobalOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "product_id", Label: "产品", Type: "relation", Required: true, Unique: true, RefEntity: "products", RefModule: "plm", RefDisplay: "name", Width: 140},
+			{Name: "hs_code", Label: "HS编码", Type: "string", Required: true, Width: 120},
+			{Name: "declared_name", Label: "申报品名", Type: "string", Width: 140},
+			{Name: "declared_name_en", Label: "英文品名", Type: "string", Width: 160},
+			{Name: "origin_country", Label: "原产国", Type: "string", Default: "CN", Width: 80},
+			{Name: "unit", Label: "申报单位", Type: "string", Default: "pcs", Width: 80},
+			{Name: "unit_net_weight", Label: "单位净重", Type: "number", Precision: intPtr(3), Unit: "kg", Width: 100},
+		},
+		ListColumns:  []string{"product_id", "hs_code", "declared_name", "declared_name_en", "origin_country", "unit", "unit_net_weight"},
+		DefaultSort:  "created_at",
+		DefaultOrder: "desc",
+		Searchable:   []string{"hs_code", "declared_name", "declared_name_en"},
+		Filters:      []string{"origin_country"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// customsInvoiceEntity — erp_customs_invoices
+// ---------------------------------------------------------------------------
+
+func customsInvoiceEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "customs_invoices",
+		Label:      "报关发票",
+		Table:      "erp_customs_invoices",
+		PrimaryKey: "id",
+		Icon:       "FileProtectOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "发票编号", Type: "string", ReadOnly: true, Unique: true, Width: 140},
+			{Name: "shipment_id", Label: "发货单", Type: "relation", ReadOnly: true, RefEntity: "shipments", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "order_id", Label: "销售订单", Type: "relation", ReadOnly: true, RefEntity: "sales_orders", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "customer_id", Label: "客户", Type: "relation", ReadOnly: true, RefEntity: "customers", RefModule: "erp", RefDisplay: "name", HideInList: true},
+			{Name: "consignee_name", Label: "收货人", Type: "string", Width: 140},
+			{Name: "consignee_address", Label: "收货地址", Type: "text", HideInList: true},
+			{Name: "destination_country", Label: "目的国", Type: "string", Width: 90},
+			{Name: "incoterm", Label: "贸易术语", Type: "string", ReadOnly: true, Width: 80},
+			{Name: "currency", Label: "币种", Type: "string", ReadOnly: true, Width: 70},
+			{Name: "total_amount", Label: "总额", Type: "number", ReadOnly: true, Precision: intPtr(2), Width: 110},
+			{Name: "package_count", Label: "件数", Type: "integer", ReadOnly: true, Width: 70},
+			{Name: "gross_weight", Label: "毛重", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "kg", Width: 90},
+			{Name: "net_weight", Label: "净重", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "kg", Width: 90},
+			{Name: "generated_at", Label: "生成时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"code", "shipment_id", "order_id", "consignee_name", "destination_country", "incoterm", "currency", "total_amount", "generated_at"},
+		DefaultSort:  "generated_at",
+		DefaultOrder: "desc",
+		Searchable:   []string{"code", "consignee_name"},
+		Filters:      []string{"customer_id", "destination_country"},
+		Relations: []sdk.RelationDef{
+			{Name: "lines", Label: "发票行", Type: "has_many", Target: "customs_invoice_lines", ForeignKey: "invoice_id", Display: "table"},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// customsInvoiceLineEntity — erp_customs_invoice_lines
+// ---------------------------------------------------------------------------
+
+func customsInvoiceLineEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "customs_invoice_lines",
+		Label:      "报关发票行",
+		Table:      "erp_customs_invoice_lines",
+		PrimaryKey: "id",
+		Icon:       "UnorderedListOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "invoice_id", Label: "报关发票", Type: "relation", ReadOnly: true, RefEntity: "customs_invoices", RefModule: "erp", RefDisplay: "code", HideInList: true},
+			{Name: "product_id", Label: "产品", Type: "relation", ReadOnly: true, RefEntity: "products", RefModule: "plm", RefDisplay: "name", Width: 120},
+			{Name: "hs_code", Label: "HS编码", Type: "string", ReadOnly: true, Width: 110},
+			{Name: "description", Label: "品名", Type: "text", ReadOnly: true, Width: 180},
+			{Name: "origin_country", Label: "原产国", Type: "string", ReadOnly: true, Width: 80},
+			{Name: "quantity", Label: "数量", Type: "number", ReadOnly: true, Precision: intPtr(4), Width: 90},
+			{Name: "unit", Label: "单位", Type: "string", ReadOnly: true, Width: 60},
+			{Name: "unit_price", Label: "单价", Type: "number", ReadOnly: true, Precision: intPtr(4), Width: 100},
+			{Name: "amount", Label: "金额", Type: "number", ReadOnly: true, Precision: intPtr(2), Width: 110},
+			{Name: "net_weight", Label: "净重", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "kg", Width: 90},
+		},
+		ListColumns:  []string{"product_id", "hs_code", "description", "origin_country", "quantity", "unit", "unit_price", "amount", "net_weight"},
+		DefaultSort:  "created_at",
+		DefaultOrder: "asc",
+		Searchable:   []string{"hs_code", "description"},
+		Filters:      []string{"invoice_id"},
+	}
+}
+
 // ---------------------------------------------------------------------------
 // returnEntity — erp_returns
 // ---------------------------------------------------------------------------
@@ -837,6 +1330,13 @@ func workOrderEntity() sdk.EntityDef {
 				Width: 80,
 			},
 			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
+			{Name: "std_material_cost", Label: "标准材料成本", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "actual_material_cost", Label: "实际材料成本", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "material_variance", Label: "材料用量差异", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "std_labor_cost", Label: "标准人工成本", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "actual_labor_cost", Label: "实际人工成本", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "labor_variance", Label: "人工效率差异", Type: "number", Precision: intPtr(2), Unit: "¥", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "variance_entry_id", Label: "差异凭证", Type: "relation", RefEntity: "journal_entries", RefModule: "erp", RefDisplay: "code", ReadOnly: true, HideInList: true, HideInForm: true},
 			{Name: "created_by", Label: "创建人", Type: "string", ReadOnly: true, Width: 90, HideInForm: true},
 			{Name: "created_at", Label: "创建时间", Type: "datetime", ReadOnly: true, Width: 160},
 		},
@@ -904,6 +1404,7 @@ func woReportEntity() sdk.EntityDef {
 			{Name: "scrap_qty", Label: "报废数量", Type: "number", Precision: intPtr(4), Width: 90},
 			{Name: "start_time", Label: "开始时间", Type: "datetime", Width: 160},
 			{Name: "end_time", Label: "结束时间", Type: "datetime", Width: 160},
+			{Name: "labor_minutes", Label: "人工工时(分钟)", Type: "number", Precision: intPtr(1), Width: 110},
 			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
 		},
 		ListColumns:  []string{"operation", "operator_id", "good_qty", "defect_qty", "scrap_qty", "start_time", "end_time"},
@@ -944,6 +1445,17 @@ func accountEntity() sdk.EntityDef {
 			{Name: "parent_id", Label: "父科目", Type: "relation", RefEntity: "accounts", RefModule: "erp", RefDisplay: "name"},
 			{Name: "level", Label: "层级", Type: "integer", Width: 60},
 			{Name: "is_leaf", Label: "末级科目", Type: "boolean"},
+			{
+				Name: "cash_flow_class", Label: "现金流量分类", Type: "select", HideInList: true,
+				Options: []sdk.FieldOption{
+					{Value: "cash", Label: "现金及等价物"},
+					{Value: "noncash", Label: "非现金费用"},
+					{Value: "operating", Label: "经营活动"},
+					{Value: "investing", Label: "投资活动"},
+					{Value: "financing", Label: "筹资活动"},
+					{Value: "profit", Label: "已结转损益"},
+				},
+			},
 			{
 				Name: "currency", Label: "核算币种", Type: "select",
 				Options: []sdk.FieldOption{
@@ -970,6 +1482,126 @@ func accountEntity() sdk.EntityDef {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// postingRuleEntity — erp_posting_rules
+// ---------------------------------------------------------------------------
+
+func postingRuleEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "posting_rules",
+		Label:      "过账规则",
+		Table:      "erp_posting_rules",
+		PrimaryKey: "id",
+		Icon:       "ApartmentOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "tx_type", Label: "事务类型", Type: "string", Required: true, Width: 140},
+			{
+				Name: "warehouse_type", Label: "仓库类型", Type: "select",
+				Options: []sdk.FieldOption{
+					{Value: "", Label: "全部", Color: "default"},
+					{Value: "raw", Label: "原料", Color: "blue"},
+					{Value: "wip", Label: "在制品", Color: "cyan"},
+					{Value: "finished", Label: "成品", Color: "green"},
+					{Value: "spare", Label: "备件", Color: "purple"},
+				},
+				Render: &sdk.FieldRender{Type: "tag"},
+				Width:  90,
+			},
+			{Name: "material_category", Label: "物料类别编码", Type: "string", Width: 110},
+			{Name: "debit_account", Label: "借方科目", Type: "string", Required: true, Width: 100},
+			{Name: "credit_account", Label: "贷方科目", Type: "string", Required: true, Width: 100},
+			{Name: "priority", Label: "优先级", Type: "integer", Default: 0, Width: 70},
+			{Name: "description", Label: "说明", Type: "text", HideInList: true},
+			{
+				Name: "status", Label: "状态", Type: "select", Default: "active",
+				Options: []sdk.FieldOption{
+					{Value: "active", Label: "启用", Color: "green"},
+					{Value: "inactive", Label: "停用", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  80,
+			},
+			{Name: "updated_at", Label: "更新时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"tx_type", "warehouse_type", "material_category", "debit_account", "credit_account", "priority", "status", "updated_at"},
+		DefaultSort:  "tx_type",
+		DefaultOrder: "asc",
+		Searchable:   []string{"tx_type", "debit_account", "credit_account"},
+		Filters:      []string{"tx_type", "warehouse_type", "status"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// costRateEntity — erp_cost_rates
+// ---------------------------------------------------------------------------
+
+func costRateEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "cost_rates",
+		Label:      "工作中心费率",
+		Table:      "erp_cost_rates",
+		PrimaryKey: "id",
+		Icon:       "FieldTimeOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "work_center", Label: "工作中心", Type: "string", Unique: true, Width: 140},
+			{Name: "labor_rate", Label: "人工费率(元/小时)", Type: "number", Required: true, Precision: intPtr(2), Unit: "¥", Width: 130},
+			{Name: "overhead_rate", Label: "制费费率(元/小时)", Type: "number", Precision: intPtr(2), Unit: "¥", Width: 130},
+			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
+			{Name: "updated_at", Label: "更新时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"work_center", "labor_rate", "overhead_rate", "updated_at"},
+		DefaultSort:  "work_center",
+		DefaultOrder: "asc",
+		Searchable:   []string{"work_center"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// standardCostEntity — erp_standard_costs
+// ---------------------------------------------------------------------------
+
+func standardCostEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "standard_costs",
+		Label:      "标准成本",
+		Table:      "erp_standard_costs",
+		PrimaryKey: "id",
+		Icon:       "CalculatorOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "material_id", Label: "物料", Type: "relation", ReadOnly: true, RefEntity: "materials", RefModule: "plm", RefDisplay: "name", Width: 140},
+			{Name: "bom_id", Label: "BOM", Type: "relation", ReadOnly: true, RefEntity: "boms", RefModule: "plm", RefDisplay: "name", HideInList: true},
+			{Name: "route_id", Label: "工艺路线", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "lot_size", Label: "标准批量", Type: "number", ReadOnly: true, Precision: intPtr(4), HideInList: true},
+			{Name: "material_cost", Label: "材料成本", Type: "number", ReadOnly: true, Precision: intPtr(4), Unit: "¥", Width: 100},
+			{Name: "labor_cost", Label: "人工成本", Type: "number", ReadOnly: true, Precision: intPtr(4), Unit: "¥", Width: 100},
+			{Name: "overhead_cost", Label: "制造费用", Type: "number", ReadOnly: true, Precision: intPtr(4), Unit: "¥", Width: 100},
+			{Name: "total_cost", Label: "标准成本", Type: "number", ReadOnly: true, Precision: intPtr(4), Unit: "¥", Width: 100},
+			{Name: "std_minutes", Label: "标准工时(分钟)", Type: "number", ReadOnly: true, Precision: intPtr(2), Width: 110},
+			{Name: "breakdown", Label: "卷算明细", Type: "json", ReadOnly: true, HideInList: true},
+			{
+				Name: "status", Label: "状态", Type: "select", ReadOnly: true,
+				Options: []sdk.FieldOption{
+					{Value: "pending", Label: "待生效", Color: "orange"},
+					{Value: "current", Label: "当前", Color: "green"},
+					{Value: "superseded", Label: "已替代", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  80,
+			},
+			{Name: "rolled_up_by", Label: "卷算人", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "effective_at", Label: "生效时间", Type: "datetime", ReadOnly: true, Width: 160},
+			{Name: "created_at", Label: "卷算时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"material_id", "material_cost", "labor_cost", "overhead_cost", "total_cost", "std_minutes", "status", "effective_at", "created_at"},
+		DefaultSort:  "created_at",
+		DefaultOrder: "desc",
+		Filters:      []string{"material_id", "status"},
+	}
+}
+
 // ---------------------------------------------------------------------------
 // journalEntryEntity — erp_journal_entries
 // ---------------------------------------------------------------------------
@@ -1121,10 +1753,12 @@ func salesInvoiceEntity() sdk.EntityDef {
 				},
 				Width: 90,
 			},
+			{Name: "dunning_level", Label: "催款级别", Type: "integer", ReadOnly: true, Width: 80},
+			{Name: "last_dunning_at", Label: "最近催款", Type: "datetime", ReadOnly: true, HideInList: true},
 			{Name: "journal_entry_id", Label: "关联凭证", Type: "relation", RefEntity: "journal_entries", RefModule: "erp", RefDisplay: "code", HideInList: true},
 			{Name: "created_at", Label: "创建时间", Type: "datetime", ReadOnly: true, Width: 160},
 		},
-		ListColumns:  []string{"code", "order_id", "customer_id", "invoice_date", "due_date", "total", "paid_amount", "balance", "status"},
+		ListColumns:  []string{"code", "order_id", "customer_id", "invoice_date", "due_date", "total", "paid_amount", "balance", "status", "dunning_level"},
 		DefaultSort:  "created_at",
 		DefaultOrder: "desc",
 		Searchable:   []string{"code"},
@@ -1222,6 +1856,274 @@ func receiptAllocationEntity() sdk.EntityDef {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// dunningLevelEntity — erp_dunning_levels
+// ---------------------------------------------------------------------------
+
+func dunningLevelEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "dunning_levels",
+		Label:      "催款级别",
+		Table:      "erp_dunning_levels",
+		PrimaryKey: "id",
+		Icon:       "AlertOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "level", Label: "级别", Type: "integer", Required: true, Unique: true, Width: 70},
+			{Name: "name", Label: "名称", Type: "string", Required: true, Width: 120},
+			{Name: "days_overdue", Label: "逾期天数≥", Type: "integer", Required: true, Width: 100},
+			{Name: "repeat_days", Label: "重复间隔(天)", Type: "integer", Default: 7, Width: 100},
+			{Name: "hold_credit", Label: "冻结信用", Type: "boolean", Default: false, Width: 90},
+			{Name: "template", Label: "通知模板", Type: "text", HideInList: true},
+			{
+				Name: "status", Label: "状态", Type: "select", Default: "active",
+				Options: []sdk.FieldOption{
+					{Value: "active", Label: "启用", Color: "green"},
+					{Value: "inactive", Label: "停用", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  80,
+			},
+		},
+		ListColumns:  []string{"level", "name", "days_overdue", "repeat_days", "hold_credit", "status"},
+		DefaultSort:  "level",
+		DefaultOrder: "asc",
+		Searchable:   []string{"name"},
+		Filters:      []string{"status"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// dunningNoticeEntity — erp_dunning_notices
+// ---------------------------------------------------------------------------
+
+func dunningNoticeEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "dunning_notices",
+		Label:      "催款记录",
+		Table:      "erp_dunning_notices",
+		PrimaryKey: "id",
+		Icon:       "NotificationOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "催款编号", Type: "string", ReadOnly: true, Unique: true, Width: 140},
+			{Name: "customer_id", Label: "客户", Type: "relation", ReadOnly: true, RefEntity: "customers", RefModule: "erp", RefDisplay: "name", Width: 120},
+			{Name: "level", Label: "级别", Type: "integer", ReadOnly: true, Width: 70},
+			{Name: "level_name", Label: "级别名称", Type: "string", ReadOnly: true, Width: 100},
+			{Name: "invoice_ids", Label: "发票", Type: "json", ReadOnly: true, HideInList: true},
+			{Name: "invoice_count", Label: "发票数", Type: "integer", ReadOnly: true, Width: 80},
+			{Name: "overdue_amount", Label: "逾期金额", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 110},
+			{Name: "max_days_overdue", Label: "最长逾期(天)", Type: "integer", ReadOnly: true, Width: 100},
+			{Name: "manual", Label: "手工催款", Type: "boolean", ReadOnly: true, Width: 80},
+			{Name: "credit_hold", Label: "触发冻结", Type: "boolean", ReadOnly: true, Width: 80},
+			{Name: "sent_at", Label: "发送时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"code", "customer_id", "level", "level_name", "invoice_count", "overdue_amount", "max_days_overdue", "credit_hold", "sent_at"},
+		DefaultSort:  "sent_at",
+		DefaultOrder: "desc",
+		Searchable:   []string{"code"},
+		Filters:      []string{"customer_id", "level"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// apInvoiceEntity — erp_ap_invoices
+// ---------------------------------------------------------------------------
+
+func apInvoiceEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "ap_invoices",
+		Label:      "应付发票",
+		Table:      "erp_ap_invoices",
+		PrimaryKey: "id",
+		Icon:       "FileTextOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "应付编号", Type: "string", ReadOnly: true, Unique: true, Width: 140},
+			{Name: "supplier_id", Label: "供应商", Type: "string", Required: true, Width: 120},
+			{Name: "settlement_id", Label: "SRM结算单", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "invoice_no", Label: "发票号", Type: "string", Width: 130},
+			{Name: "invoice_date", Label: "发票日期", Type: "date", Width: 110},
+			{Name: "payment_terms", Label: "付款条件", Type: "string", Width: 100},
+			{Name: "due_date", Label: "到期日", Type: "date", Width: 110},
+			{
+				Name: "currency", Label: "币种", Type: "select", Default: "CNY",
+				Options: []sdk.FieldOption{
+					{Value: "CNY", Label: "CNY", Color: "red"},
+					{Value: "USD", Label: "USD", Color: "green"},
+				},
+				Width: 70,
+			},
+			{Name: "original_amount", Label: "原币金额", Type: "number", Precision: intPtr(2), HideInList: true},
+			{Name: "exchange_rate", Label: "入账汇率", Type: "number", Precision: intPtr(6), HideInList: true},
+			{Name: "total", Label: "应付金额", Type: "number", Required: true, Precision: intPtr(2), Unit: "¥", Width: 110},
+			{Name: "paid_amount", Label: "已付金额", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 110},
+			{
+				Name: "status", Label: "状态", Type: "select", Default: "open", ReadOnly: true,
+				Options: []sdk.FieldOption{
+					{Value: "open", Label: "未付", Color: "orange"},
+					{Value: "partially_paid", Label: "部分付款", Color: "processing"},
+					{Value: "paid", Label: "已付清", Color: "success"},
+					{Value: "cancelled", Label: "已作废", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  90,
+			},
+			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
+			{Name: "created_at", Label: "登记时间", Type: "datetime", ReadOnly: true, HideInList: true},
+		},
+		ListColumns:  []string{"code", "supplier_id", "invoice_no", "invoice_date", "due_date", "total", "paid_amount", "status"},
+		DefaultSort:  "due_date",
+		DefaultOrder: "asc",
+		Searchable:   []string{"code", "invoice_no"},
+		Filters:      []string{"supplier_id", "status", "currency"},
+		Relations: []sdk.RelationDef{
+			{Name: "allocations", Label: "付款核销", Type: "has_many", Target: "ap_payment_allocations", ForeignKey: "invoice_id", Display: "table"},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// apPaymentEntity — erp_ap_payments
+// ---------------------------------------------------------------------------
+
+func apPaymentEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "ap_payments",
+		Label:      "付款记录",
+		Table:      "erp_ap_payments",
+		PrimaryKey: "id",
+		Icon:       "PayCircleOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "付款编号", Type: "string", ReadOnly: true, Unique: true, Width: 140},
+			{Name: "supplier_id", Label: "供应商", Type: "string", ReadOnly: true, Width: 120},
+			{Name: "payment_run_id", Label: "付款批次", Type: "relation", ReadOnly: true, RefEntity: "payment_runs", RefModule: "erp", RefDisplay: "code", HideInList: true},
+			{Name: "amount", Label: "冲减应付", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 110},
+			{Name: "cash_amount", Label: "付款金额", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 110},
+			{Name: "fx_diff", Label: "汇兑损益", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", HideInList: true},
+			{Name: "currency", Label: "币种", Type: "string", ReadOnly: true, Width: 70},
+			{Name: "original_amount", Label: "原币金额", Type: "number", ReadOnly: true, Precision: intPtr(2), HideInList: true},
+			{Name: "payment_method", Label: "付款方式", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "bank_name", Label: "收款开户行", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "bank_account", Label: "收款账号", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "reference_no", Label: "银行流水号", Type: "string", Width: 140},
+			{Name: "paid_date", Label: "付款日期", Type: "date", ReadOnly: true, Width: 110},
+			{Name: "journal_entry_id", Label: "关联凭证", Type: "relation", ReadOnly: true, RefEntity: "journal_entries", RefModule: "erp", RefDisplay: "code", HideInList: true},
+			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
+		},
+		ListColumns:  []string{"code", "supplier_id", "amount", "cash_amount", "currency", "reference_no", "paid_date"},
+		DefaultSort:  "paid_date",
+		DefaultOrder: "desc",
+		Searchable:   []string{"code", "reference_no"},
+		Filters:      []string{"supplier_id", "payment_run_id"},
+		Relations: []sdk.RelationDef{
+			{Name: "allocations", Label: "核销明细", Type: "has_many", Target: "ap_payment_allocations", ForeignKey: "payment_id", Display: "table"},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// apPaymentAllocationEntity — erp_ap_payment_allocations
+// ---------------------------------------------------------------------------
+
+func apPaymentAllocationEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "ap_payment_allocations",
+		Label:      "付款核销",
+		Table:      "erp_ap_payment_allocations",
+		PrimaryKey: "id",
+		Icon:       "LinkOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "payment_id", Label: "付款", Type: "relation", ReadOnly: true, RefEntity: "ap_payments", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "invoice_id", Label: "应付发票", Type: "relation", ReadOnly: true, RefEntity: "ap_invoices", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "amount", Label: "核销金额", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 110},
+		},
+		ListColumns:  []string{"payment_id", "invoice_id", "amount"},
+		DefaultSort:  "id",
+		DefaultOrder: "asc",
+		Searchable:   []string{},
+		Filters:      []string{"payment_id", "invoice_id"},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// paymentRunEntity — erp_payment_runs
+// ---------------------------------------------------------------------------
+
+func paymentRunEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "payment_runs",
+		Label:      "付款批次",
+		Table:      "erp_payment_runs",
+		PrimaryKey: "id",
+		Icon:       "ScheduleOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "code", Label: "批次编号", Type: "string", ReadOnly: true, Unique: true, Width: 140},
+			{Name: "cutoff_date", Label: "到期截止日", Type: "date", ReadOnly: true, Width: 110},
+			{Name: "payment_date", Label: "付款日期", Type: "date", Width: 110},
+			{Name: "currency", Label: "币种", Type: "string", ReadOnly: true, Width: 70},
+			{Name: "item_count", Label: "发票数", Type: "integer", ReadOnly: true, Width: 80},
+			{Name: "total_amount", Label: "付款总额", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 120},
+			{
+				Name: "status", Label: "状态", Type: "select", Default: "proposed",
+				Options: []sdk.FieldOption{
+					{Value: "proposed", Label: "待审批", Color: "orange"},
+					{Value: "paid", Label: "已付款", Color: "success"},
+					{Value: "cancelled", Label: "已取消", Color: "default"},
+				},
+				Render: &sdk.FieldRender{Type: "badge"},
+				Width:  90,
+			},
+			{Name: "created_by", Label: "创建人", Type: "string", ReadOnly: true, HideInList: true},
+			{Name: "approved_by", Label: "审批人", Type: "string", ReadOnly: true, Width: 90},
+			{Name: "executed_at", Label: "执行时间", Type: "datetime", ReadOnly: true, HideInList: true},
+			{Name: "notes", Label: "备注", Type: "text", HideInList: true},
+			{Name: "created_at", Label: "创建时间", Type: "datetime", ReadOnly: true, Width: 160},
+		},
+		ListColumns:  []string{"code", "cutoff_date", "payment_date", "currency", "item_count", "total_amount", "status", "approved_by", "created_at"},
+		DefaultSort:  "created_at",
+		DefaultOrder: "desc",
+		Searchable:   []string{"code"},
+		Filters:      []string{"status"},
+		Relations: []sdk.RelationDef{
+			{Name: "items", Label: "付款明细", Type: "has_many", Target: "payment_run_items", ForeignKey: "run_id", Display: "table"},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// paymentRunItemEntity — erp_payment_run_items
+// ---------------------------------------------------------------------------
+
+func paymentRunItemEntity() sdk.EntityDef {
+	return sdk.EntityDef{
+		Name:       "payment_run_items",
+		Label:      "付款批次明细",
+		Table:      "erp_payment_run_items",
+		PrimaryKey: "id",
+		Icon:       "UnorderedListOutlined",
+		Fields: []sdk.FieldDef{
+			{Name: "id", Label: "ID", Type: "string", ReadOnly: true, HideInList: true, HideInForm: true},
+			{Name: "run_id", Label: "付款批次", Type: "relation", ReadOnly: true, RefEntity: "payment_runs", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "invoice_id", Label: "应付发票", Type: "relation", ReadOnly: true, RefEntity: "ap_invoices", RefModule: "erp", RefDisplay: "code", Width: 140},
+			{Name: "supplier_id", Label: "供应商", Type: "string", ReadOnly: true, Width: 120},
+			{Name: "due_date", Label: "到期日", Type: "date", ReadOnly: true, Width: 110},
+			{Name: "open_amount", Label: "未付金额", Type: "number", ReadOnly: true, Precision: intPtr(2), Unit: "¥", Width: 110},
+			{Name: "pay_amount", Label: "本次付款", Type: "number", Precision: intPtr(2), Unit: "¥", Width: 110},
+			{Name: "selected", Label: "付款", Type: "boolean", Default: true, Width: 70},
+			{Name: "payment_id", Label: "付款单", Type: "relation", ReadOnly: true, RefEntity: "ap_payments", RefModule: "erp", RefDisplay: "code", HideInList: true},
+		},
+		ListColumns:  []string{"invoice_id", "supplier_id", "due_date", "open_amount", "pay_amount", "selected"},
+		DefaultSort:  "due_date",
+		DefaultOrder: "asc",
+		Searchable:   []string{},
+		Filters:      []string{"run_id", "supplier_id"},
+	}
+}
+
 // ---------------------------------------------------------------------------
 // oqcInspectionEntity — erp_oqc_inspections
 // ---------------------------------------------------------------------------
@@ -86,10 +86,20 @@ func (m *ERPModule) Entities() []sdk.EntityDef {
 		// 销售管理
 		quotationEntity(),
 		quotationItemEntity(),
+		priceListEntity(),
+		priceListItemEntity(),
+		promotionEntity(),
+		marginRuleEntity(),
 		salesOrderEntity(),
 		salesOrderItemEntity(),
 		shipmentEntity(),
 		shipmentItemEntity(),
+		packageEntity(),
+		packageContentEntity(),
+		ssccConfigEntity(),
+		customsProductEntity(),
+		customsInvoiceEntity(),
+		customsInvoiceLineEntity(),
 		returnEntity(),
 		// 库存管理
 		inventoryEntity(),
@@ -102,11 +112,21 @@ func (m *ERPModule) Entities() []sdk.EntityDef {
 		woReportEntity(),
 		// 财务管理
 		accountEntity(),
+		postingRuleEntity(),
+		costRateEntity(),
+		standardCostEntity(),
 		journalEntryEntity(),
 		journalLineEntity(),
 		salesInvoiceEntity(),
 		receiptEntity(),
 		receiptAllocationEntity(),
+		dunningLevelEntity(),
+		dunningNoticeEntity(),
+		apInvoiceEntity(),
+		apPaymentEntity(),
+		apPaymentAllocationEntity(),
+		paymentRunEntity(),
+		paymentRunItemEntity(),
 		// 质量管理
 		oqcInspectionEntity(),
 		ncrReportEntity(),
@@ -153,7 +173,11 @@ func (m *ERPModule) Nav() []sdk.NavEntry {
 			{Key: "/m/erp/view/sales_pipeline", Label: "销售管道", View: "sales_pipeline"},
 			{Key: "/m/erp/customers", Label: "客户", Entity: "customers"},
 			{Key: "/m/erp/view/quote_board", Label: "报价跟进", View: "quote_board"},
+			{Key: "/m/erp/price_lists", Label: "价目表", Entity: "price_lists"},
+			{Key: "/m/erp/promotions", Label: "促销折扣", Entity: "promotions"},
 			{Key: "/m/erp/view/shipping_center", Label: "发货中心", View: "shipping_center"},
+			{Key: "/m/erp/packages", Label: "包装箱", Entity: "packages"},
+			{Key: "/m/erp/customs_invoices", Label: "报关发票", Entity: "customs_invoices"},
 			{Key: "/m/erp/view/ar_workspace", Label: "收款工作台", View: "ar_workspace"},
 			{Key: "/m/erp/returns", Label: "退货", Entity: "returns"},
 		}},
@@ -176,6 +200,11 @@ func (m *ERPModule) Nav() []sdk.NavEntry {
 			{Key: "/m/erp/view/report_center", Label: "报表中心", View: "report_center"},
 			{Key: "/m/erp/sales_invoices", Label: "销售发票", Entity: "sales_invoices"},
 			{Key: "/m/erp/receipts", Label: "收款记录", Entity: "receipts"},
+			{Key: "/m/erp/ap_invoices", Label: "应付发票", Entity: "ap_invoices"},
+			{Key: "/m/erp/ap_payments", Label: "付款记录", Entity: "ap_payments"},
+			{Key: "/m/erp/payment_runs", Label: "付款批次", Entity: "payment_runs"},
+			{Key: "/m/erp/standard_costs", Label: "标准成本", Entity: "standard_costs"},
+			{Key: "/m/erp/cost_rates", Label: "工作中心费率", Entity: "cost_rates"},
 		}},
 		// 质量
 		{Key: "erp-quality", Label: "质量", Icon: "SafetyCertificateOutlined", Children: []sdk.NavEntry{
@@ -186,19 +215,27 @@ func (m *ERPModule) Nav() []sdk.NavEntry {
 		{Key: "erp-settings", Label: "设置", Icon: "SettingOutlined", Children: []sdk.NavEntry{
 			{Key: "/m/erp/warehouses", Label: "仓库", Entity: "warehouses"},
 			{Key: "/m/erp/accounts", Label: "科目表", Entity: "accounts"},
+			{Key: "/m/erp/posting_rules", Label: "过账规则", Entity: "posting_rules"},
+			{Key: "/m/erp/margin_rules", Label: "毛利管控", Entity: "margin_rules"},
+			{Key: "/m/erp/customs_products", Label: "报关资料", Entity: "customs_products"},
+			{Key: "/m/erp/sscc_configs", Label: "SSCC编码", Entity: "sscc_configs"},
 		}},
 	}
 }
 
 func (m *ERPModule) Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		// 基础数据
 		&ErpCustomer{}, &ErpWarehouse{}, &ErpLocation{},
 		// 销售管理
 		&ErpQuotation{}, &ErpQuotationItem{},
+		&ErpPriceList{}, &ErpPriceListItem{}, &ErpPromotion{}, &ErpMarginRule{},
 		&ErpSalesOrder{}, &ErpSalesOrderItem{},
 		&ErpShipment{}, &ErpShipmentItem{},
 		&ErpReturn{},
+		// 包装与报关
+		&ErpPackage{}, &ErpPackageContent{}, &ErpSSCCConfig{},
+		&ErpCustomsProduct{}, &ErpCustomsInvoice{}, &ErpCustomsInvoiceLine{},
 		// 库存管理
 		&ErpInventory{}, &ErpInventoryTransaction{}, &ErpSerialNumber{},
 		&ErpMaterialInventoryAttrs{}, &ErpInventoryAuditLog{},
@@ -206,14 +243,32 @@ func (m *ERPModule) Migrate(db *gorm.DB) error {
 		// 生产管理
 		&ErpMRPResult{}, &ErpWorkOrder{},
 		&ErpWOMaterialIssue{}, &ErpWOReport{},
+		// 成本管理
+		&ErpCostRate{}, &ErpStandardCost{},
 		// 财务管理
-		&ErpAccount{}, &ErpJournalEntry{}, &ErpJournalLine{}, &ErpPeriodLock{},
+		&ErpAccount{}, &ErpPostingRule{}, &ErpJournalEntry{}, &ErpJournalLine{}, &ErpPeriodLock{},
 		&ErpSalesInvoice{}, &ErpReceipt{}, &ErpReceiptAllocation{},
+		// 信用控制
+		&ErpDunningLevel{}, &ErpDunningNotice{},
+		// 应付管理
+		&ErpAPInvoice{}, &ErpAPPayment{}, &ErpAPPaymentAllocation{}, &ErpPaymentRun{}, &ErpPaymentRunItem{},
 		// 质量管理
 		&ErpOQCInspection{}, &ErpNCRReport{}, &ErpCAPA{},
 		// 审计日志
 		&ErpAuditLog{},
-	)
+	); err != nil {
+		return err
+	}
+	// 默认过账规则随迁移初始化，过账时不再逐笔检查
+	if _, err := ensurePostingRules(db); err != nil {
+		return err
+	}
+	if err := backfillCashFlowClasses(db); err != nil {
+		return err
+	}
+	// 同一序列号只能装在一个有效包装件里；作废包装件的明细释放后不受约束
+	return db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_erp_package_contents_active_serial
+		ON erp_package_contents (serial_number) WHERE serial_number <> '' AND NOT released`).Error
 }
 
 // Routes registers custom routes
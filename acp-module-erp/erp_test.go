@@ -0,0 +1,62 @@
+package erp
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// setupTestDB opens the test Postgres in an isolated schema and runs the
+// module migration. Tests are skipped when no database is reachable.
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	baseDSN := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "5432"), getEnv("DB_USER", "nimo"),
+		getEnv("DB_PASSWORD", "nimo123"), getEnv("DB_NAME", "nimo_plm"))
+	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+	setup, err := gorm.Open(postgres.Open(baseDSN), cfg)
+	if err != nil {
+		t.Skipf("test database not available: %v", err)
+	}
+	schema := fmt.Sprintf("test_acp_erp_%d", time.Now().UnixNano()%1000000)
+	if err := setup.Exec("CREATE SCHEMA IF NOT EXISTS " + schema).Error; err != nil {
+		t.Fatalf("create schema failed: %v", err)
+	}
+	t.Cleanup(func() {
+		setup.Exec("DROP SCHEMA IF EXISTS " + schema + " CASCADE")
+		if sqlDB, _ := setup.DB(); sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(baseDSN+" search_path="+schema), cfg)
+	if err != nil {
+		t.Fatalf("connect test schema failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, _ := db.DB(); sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+	if err := (&ERPModule{}).Migrate(db); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	// 应付读取 SRM 供应商付款条件与银行信息
+	if err := db.AutoMigrate(&srmSupplierLite{}); err != nil {
+		t.Fatalf("migrate srm_suppliers failed: %v", err)
+	}
+	return db
+}
@@ -11,6 +11,7 @@ import (
 	sdk "github.com/bitfantasy/acp-module-sdk"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ---------------------------------------------------------------------------
@@ -57,13 +58,25 @@ var commandHandlers = map[string]commandHandler{
 	"issue_wo_materials":     cmdIssueWOMaterials,
 	"report_wo_progress":     cmdReportWOProgress,
 	"complete_work_order":    cmdCompleteWorkOrder,
-	// Finance (6)
+	// Finance (9)
 	"create_journal_entry":    cmdCreateJournalEntry,
 	"post_journal_entry":      cmdPostJournalEntry,
 	"reverse_journal_entry":   cmdReverseJournalEntry,
 	"close_period":            cmdClosePeriod,
 	"generate_report":         cmdGenerateReport,
 	"post_ap_from_settlement": cmdPostAPFromSettlement,
+	"create_account":          cmdCreateAccount,
+	"save_posting_rule":       cmdSavePostingRule,
+	"post_by_rule":            cmdPostByRule,
+	// Accounts payable (4)
+	"create_ap_invoice":   cmdCreateAPInvoice,
+	"record_ap_payment":   cmdRecordAPPayment,
+	"propose_payment_run": cmdProposePaymentRun,
+	"execute_payment_run": cmdExecutePaymentRun,
+	// Costing (3)
+	"rollup_standard_cost": cmdRollupStandardCost,
+	"run_cost_revaluation": cmdRunCostRevaluation,
+	"set_cost_method":      cmdSetCostMethod,
 	// Quality (6)
 	"create_oqc":      cmdCreateOQC,
 	"complete_oqc":    cmdCompleteOQC,
@@ -71,10 +84,22 @@ var commandHandlers = map[string]commandHandler{
 	"disposition_ncr":  cmdDispositionNCR,
 	"create_capa":     cmdCreateCAPA,
 	"close_capa":      cmdCloseCAPA,
+	// Pricing (2)
+	"quote_price":              cmdQuotePrice,
+	"approve_quotation_margin": cmdApproveQuotationMargin,
 	// Additional (3)
 	"update_shipment_status": cmdUpdateShipmentStatus,
 	"extend_quotation":       cmdExtendQuotation,
 	"send_payment_reminder":  cmdSendPaymentReminder,
+	// Credit control (2)
+	"release_credit_hold": cmdReleaseCreditHold,
+	"run_dunning":         cmdRunDunning,
+	// Packing & logistics (5)
+	"create_package":           cmdCreatePackage,
+	"pack_items":               cmdPackItems,
+	"unpack_items":             cmdUnpackItems,
+	"seal_package":             cmdSealPackage,
+	"generate_customs_invoice": cmdGenerateCustomsInvoice,
 }
 
 // ---------------------------------------------------------------------------
@@ -139,6 +164,23 @@ func getFloat(input map[string]any, key string) float64 {
 	return 0
 }
 
+// getBool extracts a bool from input map; accepts JSON booleans and
+// strings such as "true"/"1".
+func getBool(input map[string]any, key string) bool {
+	if v, ok := input[key]; ok {
+		switch b := v.(type) {
+		case bool:
+			return b
+		case string:
+			parsed, _ := strconv.ParseBool(b)
+			return parsed
+		case float64:
+			return b != 0
+		}
+	}
+	return false
+}
+
 // getStrSlice extracts a string slice.
 func getStrSlice(input map[string]any, key string) []string {
 	v, ok := input[key]
@@ -251,6 +293,46 @@ func cmdCreateQuotation(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID st
 		return nil, fmt.Errorf("customer_id is required")
 	}
 
+	qc := quoteContext{
+		CustomerID:   customerID,
+		Channel:      getStr(input, "channel"),
+		Currency:     getStr(input, "currency"),
+		ExchangeRate: getFloat(input, "exchange_rate"),
+		At:           time.Now(),
+	}
+	if qc.Channel == "" {
+		qc.Channel = customerChannel(db, customerID)
+	}
+	if qc.Currency == "" {
+		qc.Currency = "CNY"
+	}
+	if qc.ExchangeRate == 0 {
+		qc.ExchangeRate = 1
+	}
+	qc.MinMarginPct = minMarginFor(db, qc.Channel)
+
+	// Price line items before creating anything so an unpriced line fails cleanly
+	items := getMapSlice(input, "items")
+	lines := make([]ErpQuotationItem, 0, len(items))
+	var subtotal, taxTotal, cost float64
+	belowMargin, costUnknown := 0, 0
+	for _, item := range items {
+		li, err := priceQuotationLine(db, &qc, item)
+		if err != nil {
+			return nil, err
+		}
+		subtotal += li.LineTotal
+		taxTotal += li.LineTotal * li.TaxRate / 100
+		cost += li.UnitCost * li.Quantity
+		if li.BelowMargin {
+			belowMargin++
+		}
+		if li.CostUnknown {
+			costUnknown++
+		}
+		lines = append(lines, li)
+	}
+
 	var quot ErpQuotation
 	code, err := autoCodeWithRetry(db, "erp_quotations", "QT", func(c string) error {
 		quot = ErpQuotation{
@@ -258,19 +340,25 @@ func cmdCreateQuotation(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID st
 			Code:         c,
 			CustomerID:   customerID,
 			ContactName:  getStr(input, "contact_name"),
-			Currency:     getStr(input, "currency"),
+			Currency:     qc.Currency,
+			ExchangeRate: qc.ExchangeRate,
+			Channel:      qc.Channel,
 			PaymentTerms: getStr(input, "payment_terms"),
 			ValidUntil:   parseDate(getStr(input, "valid_until")),
 			Notes:        getStr(input, "notes"),
 			Status:       "draft",
 			CreatedBy:    getStr(input, "created_by"),
+			Subtotal:     subtotal,
+			TaxAmount:    taxTotal,
+			Total:        subtotal + taxTotal,
+			Cost:         roundAmount(cost),
+			MinMarginPct: qc.MinMarginPct,
 		}
-		if quot.Currency == "" {
-			quot.Currency = "CNY"
+		if cost > 0 {
+			quot.MarginPct = marginPct(subtotal*qc.ExchangeRate, cost)
 		}
-		quot.ExchangeRate = getFloat(input, "exchange_rate")
-		if quot.ExchangeRate == 0 {
-			quot.ExchangeRate = 1
+		if belowMargin > 0 || costUnknown > 0 {
+			quot.ApprovalStatus = quoteApprovalPending
 		}
 		return db.Create(&quot).Error
 	})
@@ -279,46 +367,27 @@ func cmdCreateQuotation(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID st
 	}
 
 	// Create line items
-	items := getMapSlice(input, "items")
-	var subtotal, taxTotal float64
-	for i, item := range items {
-		qty := getFloat(item, "quantity")
-		price := getFloat(item, "unit_price")
-		discount := getFloat(item, "discount_pct")
-		taxRate := getFloat(item, "tax_rate")
-		lineTotal := qty * price * (1 - discount/100)
-		lineTax := lineTotal * taxRate / 100
-		subtotal += lineTotal
-		taxTotal += lineTax
-
-		li := ErpQuotationItem{
-			ID:          uuid.New().String(),
-			QuotationID: quot.ID,
-			ProductID:   getStr(item, "product_id"),
-			SkuID:       getStr(item, "sku_id"),
-			Description: getStr(item, "description"),
-			Quantity:    qty,
-			UnitPrice:   price,
-			DiscountPct: discount,
-			TaxRate:     taxRate,
-			LineTotal:   lineTotal,
-			SortOrder:   i + 1,
-		}
-		db.Create(&li)
+	for i := range lines {
+		lines[i].ID = uuid.New().String()
+		lines[i].QuotationID = quot.ID
+		lines[i].SortOrder = i + 1
+		db.Create(&lines[i])
 	}
 
-	// Update totals
-	db.Model(&quot).Updates(map[string]any{
-		"subtotal":   subtotal,
-		"tax_amount": taxTotal,
-		"total":      subtotal + taxTotal,
-	})
-
 	emitEvent(adapter, runID, stepID, "erp.quotation.created",
 		fmt.Sprintf("报价单创建: %s", code),
 		map[string]any{"quotation_id": quot.ID, "code": code})
+	if belowMargin > 0 || costUnknown > 0 {
+		emitEvent(adapter, runID, stepID, "erp.quotation.margin_approval_required",
+			fmt.Sprintf("报价单 %s 有%d行毛利率低于 %.2f%%、%d行无成本, 需审批", code, belowMargin, qc.MinMarginPct, costUnknown),
+			map[string]any{"quotation_id": quot.ID, "code": code, "margin_pct": quot.MarginPct, "below_margin_lines": belowMargin, "cost_unknown_lines": costUnknown})
+	}
 
-	return map[string]any{"quotation_id": quot.ID, "code": code}, nil
+	return map[string]any{
+		"quotation_id": quot.ID, "code": code, "customer_id": customerID, "status": quot.Status,
+		"total_amount": quot.Total, "channel": qc.Channel, "margin_pct": quot.MarginPct,
+		"approval_status": quot.ApprovalStatus, "below_margin_lines": belowMargin, "cost_unknown_lines": costUnknown,
+	}, nil
 }
 
 func cmdConfirmQuotation(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
@@ -333,6 +402,9 @@ func cmdConfirmQuotation(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 	if quot.Status != "draft" {
 		return nil, fmt.Errorf("quotation not in draft status, current: %s", quot.Status)
 	}
+	if quot.ApprovalStatus == quoteApprovalPending || quot.ApprovalStatus == quoteApprovalRejected {
+		return nil, fmt.Errorf("quotation %s margin %.2f%% is below %.2f%%, approval status: %s", quot.Code, quot.MarginPct, quot.MinMarginPct, quot.ApprovalStatus)
+	}
 
 	db.Model(&quot).Update("status", "confirmed")
 
@@ -365,6 +437,10 @@ func cmdCreateSalesOrder(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 			Notes:           getStr(input, "notes"),
 			Status:          "draft",
 			CreatedBy:       getStr(input, "created_by"),
+
+			IsExport:           getBool(input, "is_export"),
+			Incoterm:           strings.ToUpper(getStr(input, "incoterm")),
+			DestinationCountry: getStr(input, "destination_country"),
 		}
 		if order.Currency == "" {
 			order.Currency = "CNY"
@@ -428,33 +504,59 @@ func cmdConfirmOrder(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID strin
 	if orderID == "" {
 		return nil, fmt.Errorf("order_id is required")
 	}
+
+	// 信用检查：超额或客户冻结时按客户策略拒绝或冻结待审。检查与状态变更在同一事务内，
+	// 客户行锁保证同一客户的订单依次确认，信用占用不会被并发确认绕过
 	var order ErpSalesOrder
-	if err := db.First(&order, "id = ?", orderID).Error; err != nil {
-		return nil, fmt.Errorf("order not found")
-	}
-	if order.Status != "draft" {
-		return nil, fmt.Errorf("order not in draft status, current: %s", order.Status)
+	var chk creditCheckResult
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", orderID).Error; err != nil {
+			return fmt.Errorf("order not found")
+		}
+		if order.Status != "draft" {
+			return fmt.Errorf("order not in draft status, current: %s", order.Status)
+		}
+		var err error
+		if chk, err = checkOrderCredit(tx, &order); err != nil {
+			return err
+		}
+		switch {
+		case chk.Passed:
+			order.CreditStatus = creditPassed
+			return confirmSalesOrder(tx, &order, map[string]any{
+				"credit_status": creditPassed, "credit_exposure": chk.Exposure.Exposure,
+			})
+		case chk.Policy == creditCheckBlock:
+			return tx.Model(&order).Updates(map[string]any{"credit_status": creditBlocked, "credit_exposure": chk.Exposure.Exposure, "credit_note": chk.Reason}).Error
+		default:
+			return tx.Model(&order).Updates(map[string]any{"status": salesOrderCreditHold, "credit_status": creditHeld, "credit_exposure": chk.Exposure.Exposure, "credit_note": chk.Reason}).Error
+		}
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	now := time.Now()
-	db.Model(&order).Updates(map[string]any{"status": "confirmed", "confirmed_at": &now})
-
-	// Auto-run MRP for this order's items
-	mrpResult, _ := cmdRunMRP(db, adapter, runID, stepID, map[string]any{"demand_source": "so"})
-	mrpSuggestions := 0
-	if m, ok := mrpResult.(map[string]any); ok {
-		if sc, ok := m["suggestion_count"]; ok {
-			if n, ok := sc.(int); ok {
-				mrpSuggestions = n
-			}
+	if !chk.Passed {
+		payload := map[string]any{"order_id": order.ID, "code": order.Code, "customer_id": order.CustomerID,
+			"exposure": chk.Exposure.Exposure, "order_amount": chk.OrderAmount, "credit_limit": chk.Exposure.CreditLimit, "reason": chk.Reason}
+		if chk.Policy == creditCheckBlock {
+			emitEvent(adapter, runID, stepID, "erp.sales_order.credit_blocked",
+				fmt.Sprintf("销售订单信用检查未通过: %s, %s", order.Code, chk.Reason), payload)
+			return nil, fmt.Errorf("credit check failed for order %s: %s", order.Code, chk.Reason)
 		}
+		emitEvent(adapter, runID, stepID, "erp.sales_order.credit_hold",
+			fmt.Sprintf("销售订单信用冻结待审: %s, %s", order.Code, chk.Reason), payload)
+		return map[string]any{
+			"order_id": order.ID, "code": order.Code, "status": salesOrderCreditHold,
+			"credit_status": creditHeld, "credit_exposure": chk.Exposure.Exposure, "credit_limit": chk.Exposure.CreditLimit,
+			"reason": chk.Reason, "mrp_suggestions": 0,
+		}, nil
 	}
 
-	emitEvent(adapter, runID, stepID, "erp.sales_order.confirmed",
-		fmt.Sprintf("销售订单确认: %s, MRP生成%d条建议", order.Code, mrpSuggestions),
-		map[string]any{"order_id": order.ID, "code": order.Code, "mrp_suggestions": mrpSuggestions})
-
-	return map[string]any{"order_id": order.ID, "code": order.Code, "mrp_suggestions": mrpSuggestions}, nil
+	out := afterSalesOrderConfirmed(db, adapter, runID, stepID, &order)
+	out["credit_exposure"] = chk.Exposure.Exposure
+	out["credit_limit"] = chk.Exposure.CreditLimit
+	return out, nil
 }
 
 func cmdCreateShipment(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
@@ -493,13 +595,20 @@ func cmdCreateShipment(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID str
 	items := getMapSlice(input, "items")
 	for _, item := range items {
 		si := ErpShipmentItem{
-			ID:            uuid.New().String(),
-			ShipmentID:    shipment.ID,
-			OrderItemID:   getStr(item, "order_item_id"),
-			ProductID:     getStr(item, "product_id"),
-			Quantity:      getFloat(item, "quantity"),
-			LotNumber:     getStr(item, "lot_number"),
-			SerialNumbers: getStr(item, "serial_numbers"),
+			ID:          uuid.New().String(),
+			ShipmentID:  shipment.ID,
+			OrderItemID: getStr(item, "order_item_id"),
+			ProductID:   getStr(item, "product_id"),
+			Quantity:    getFloat(item, "quantity"),
+			LotNumber:   getStr(item, "lot_number"),
+		}
+		if si.ProductID == "" && si.OrderItemID != "" {
+			db.Model(&ErpSalesOrderItem{}).Where("id = ?", si.OrderItemID).Pluck("product_id", &si.ProductID)
+		}
+		// 序列号按 JSON 数组保存，装箱扫描时据此校验
+		if serials := getStrSlice(item, "serial_numbers"); len(serials) > 0 {
+			raw, _ := json.Marshal(serials)
+			si.SerialNumbers = string(raw)
 		}
 		db.Create(&si)
 	}
@@ -520,12 +629,33 @@ func cmdConfirmShipment(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID st
 	if err := db.First(&shipment, "id = ?", shipmentID).Error; err != nil {
 		return nil, fmt.Errorf("shipment not found")
 	}
-	if shipment.Status != "pending" {
+	if shipment.Status != "pending" && shipment.Status != shipmentPacked {
 		return nil, fmt.Errorf("shipment not in pending status, current: %s", shipment.Status)
 	}
 
+	// 已开始装箱的发货单必须全部封箱且数量与明细一致
+	var packageCount int64
+	db.Model(&ErpPackage{}).Where("shipment_id = ? AND status <> ?", shipmentID, packageVoid).Count(&packageCount)
+	updates := map[string]any{"status": "shipped"}
+	if packageCount > 0 {
+		pl := buildPackingList(db, &shipment)
+		if !pl.FullyPacked {
+			return nil, fmt.Errorf("shipment %s is not fully packed and sealed", shipment.Code)
+		}
+		updates["package_count"] = len(pl.Packages)
+		updates["gross_weight"] = pl.GrossWeight
+		updates["volume"] = pl.Volume
+	}
+	if v := getStr(input, "carrier"); v != "" {
+		updates["carrier"] = v
+	}
+	if v := getStr(input, "tracking_no"); v != "" {
+		updates["tracking_no"] = v
+	}
+
 	now := time.Now()
-	db.Model(&shipment).Updates(map[string]any{"status": "shipped", "shipped_at": &now})
+	updates["shipped_at"] = &now
+	db.Model(&shipment).Updates(updates)
 
 	// Update delivered qty on order items
 	var items []ErpShipmentItem
@@ -558,6 +688,15 @@ func cmdConfirmShipment(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID st
 	// Check if all order items are fully delivered → update order status
 	var order ErpSalesOrder
 	if db.First(&order, "id = ?", shipment.OrderID).Error == nil {
+		// 装箱序列号标记为已发货
+		if serials := packedSerials(db, shipment.ID); len(serials) > 0 {
+			var cust ErpCustomer
+			db.First(&cust, "id = ?", order.CustomerID)
+			db.Model(&ErpSerialNumber{}).Where("serial_number IN ?", serials).Updates(map[string]any{
+				"status": "shipped", "shipment_id": shipment.ID, "customer_id": order.CustomerID,
+				"sold_to": cust.Name, "sold_at": &now, "updated_at": now,
+			})
+		}
 		var pendingCount int64
 		db.Model(&ErpSalesOrderItem{}).
 			Where("order_id = ? AND delivered_qty < quantity", order.ID).
@@ -870,7 +1009,7 @@ func cmdReceiveInventory(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 	}
 
 	// Lazy-create material inventory attrs
-	ensureInventoryAttrs(db, materialID, getStr(input, "unit"), warehouseID)
+	attrs := ensureInventoryAttrs(db, materialID, getStr(input, "unit"), warehouseID)
 
 	var txn ErpInventoryTransaction
 	var inv ErpInventory
@@ -890,6 +1029,18 @@ func cmdReceiveInventory(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 		txnType = "production_in"
 	}
 
+	// 标准成本物料按标准成本入库；采购入库的实际与标准之差记采购价格差异
+	var priceVariance float64
+	if isStandardCosted(attrs) {
+		if attrs.StandardCost <= 0 {
+			return nil, fmt.Errorf("material %s is standard-costed but has no standard cost", materialID)
+		}
+		if txnType == "po_receipt" && unitCost > 0 {
+			priceVariance = roundAmount(qty * (unitCost - attrs.StandardCost))
+		}
+		unitCost = attrs.StandardCost
+	}
+
 	err := db.Transaction(func(tx *gorm.DB) error {
 		// Upsert inventory by composite key
 		query := tx.Where(
@@ -949,6 +1100,7 @@ func cmdReceiveInventory(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 				Quantity:       qty,
 				UnitCost:       unitCost,
 				TotalAmount:    qty * unitCost,
+				PriceVariance:  priceVariance,
 				LotNumber:      lotNumber,
 				ReferenceType:  getStr(input, "reference_type"),
 				ReferenceID:    getStr(input, "reference_id"),
@@ -998,6 +1150,7 @@ func cmdReceiveInventory(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 			"unit_cost":        unitCost,
 			"avg_cost":         newAvgCost,
 			"total_amount":     qty * unitCost,
+			"price_variance":   priceVariance,
 			"journal_entry_id": journalEntryID,
 		})
 
@@ -1009,6 +1162,8 @@ func cmdReceiveInventory(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 		"warehouse_id":     warehouseID,
 		"quantity":         qty,
 		"avg_cost":         newAvgCost,
+		"standard_cost":    attrs.StandardCost,
+		"price_variance":   priceVariance,
 		"journal_entry_id": journalEntryID,
 	}, nil
 }
@@ -1951,16 +2106,21 @@ func cmdReportWOProgress(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 	scrapQty := getFloat(input, "scrap_qty")
 
 	report := ErpWOReport{
-		ID:          uuid.New().String(),
-		WorkOrderID: woID,
-		Operation:   getStr(input, "operation"),
-		OperatorID:  getStr(input, "operator_id"),
-		GoodQty:     goodQty,
-		DefectQty:   defectQty,
-		ScrapQty:    scrapQty,
-		StartTime:   parseDate(getStr(input, "start_time")),
-		EndTime:     parseDate(getStr(input, "end_time")),
-		Notes:       getStr(input, "notes"),
+		ID:           uuid.New().String(),
+		WorkOrderID:  woID,
+		Operation:    getStr(input, "operation"),
+		OperatorID:   getStr(input, "operator_id"),
+		GoodQty:      goodQty,
+		DefectQty:    defectQty,
+		ScrapQty:     scrapQty,
+		LaborMinutes: getFloat(input, "labor_minutes"),
+		StartTime:    parseDate(getStr(input, "start_time")),
+		EndTime:      parseDate(getStr(input, "end_time")),
+		Notes:        getStr(input, "notes"),
+	}
+	// 未填工时按起止时间计算，供标准成本人工差异使用
+	if report.LaborMinutes <= 0 && report.StartTime != nil && report.EndTime != nil && report.EndTime.After(*report.StartTime) {
+		report.LaborMinutes = report.EndTime.Sub(*report.StartTime).Minutes()
 	}
 	db.Create(&report)
 
@@ -2011,12 +2171,40 @@ func cmdCompleteWorkOrder(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID
 	scrapQty := getFloat(input, "scrap_qty")
 	now := time.Now()
 
-	if err := db.Model(&wo).Updates(map[string]any{
-		"status":        "completed",
-		"completed_qty": completedQty,
-		"scrap_qty":     scrapQty,
-		"actual_end":    &now,
-	}).Error; err != nil {
+	var variance *woCostVariance
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 锁定工单后重新校验状态，防止并发完工重复过账差异
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wo, "id = ?", woID).Error; err != nil {
+			return fmt.Errorf("work order not found")
+		}
+		if wo.Status != "in_progress" && wo.Status != "released" {
+			return fmt.Errorf("work order not in active status, current: %s", wo.Status)
+		}
+		// 标准成本产品：按事务内的领料与报工计算材料用量与人工效率差异并过账
+		variance = woVariance(tx, &wo, completedQty)
+		updates := map[string]any{
+			"status":        "completed",
+			"completed_qty": completedQty,
+			"scrap_qty":     scrapQty,
+			"actual_end":    &now,
+		}
+		if variance != nil {
+			entryID, err := postWOVariance(tx, &wo, variance, getStr(input, "posted_by"))
+			if err != nil {
+				return fmt.Errorf("post work order variance: %w", err)
+			}
+			variance.EntryID = entryID
+			updates["std_material_cost"] = variance.StdMaterialCost
+			updates["actual_material_cost"] = variance.ActualMaterialCost
+			updates["material_variance"] = variance.MaterialVariance
+			updates["std_labor_cost"] = variance.StdLaborCost
+			updates["actual_labor_cost"] = variance.ActualLaborCost
+			updates["labor_variance"] = variance.LaborVariance
+			updates["variance_entry_id"] = entryID
+		}
+		return tx.Model(&wo).Updates(updates).Error
+	})
+	if err != nil {
 		return nil, fmt.Errorf("update work order failed: %w", err)
 	}
 
@@ -2029,12 +2217,18 @@ func cmdCompleteWorkOrder(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID
 			"scrap_qty":     scrapQty,
 		})
 
-	return map[string]any{
+	out := map[string]any{
 		"work_order_id": wo.ID,
 		"code":          wo.Code,
 		"completed_qty": completedQty,
 		"scrap_qty":     scrapQty,
-	}, nil
+	}
+	if variance != nil {
+		out["material_variance"] = variance.MaterialVariance
+		out["labor_variance"] = variance.LaborVariance
+		out["variance_entry_id"] = variance.EntryID
+	}
+	return out, nil
 }
 
 // ===========================================================================
@@ -2122,6 +2316,11 @@ func cmdPostJournalEntry(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID s
 	if entry.Status != "draft" {
 		return nil, fmt.Errorf("journal entry not in draft status, current: %s", entry.Status)
 	}
+	var lines []ErpJournalLine
+	db.Where("entry_id = ?", entry.ID).Find(&lines)
+	if err := validateJournalLines(db, lines); err != nil {
+		return nil, fmt.Errorf("凭证 %s 不能过账: %w", entry.Code, err)
+	}
 
 	now := time.Now()
 	db.Model(&entry).Updates(map[string]any{
@@ -2259,30 +2458,53 @@ func cmdGenerateReport(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID str
 
 		return map[string]any{"report_type": reportType, "period": period, "rows": rows}, nil
 
-	case "income_statement":
-		// Sum revenue and expense accounts
-		var revenue, expense float64
-		db.Table("erp_journal_lines jl").
-			Joins("JOIN erp_journal_entries je ON je.id = jl.entry_id").
-			Joins("JOIN erp_accounts a ON a.id = jl.account_id").
-			Where("je.status = ? AND je.period = ? AND a.type = ?", "posted", period, "revenue").
-			Select("COALESCE(SUM(jl.credit - jl.debit), 0)").Row().Scan(&revenue)
-		db.Table("erp_journal_lines jl").
-			Joins("JOIN erp_journal_entries je ON je.id = jl.entry_id").
-			Joins("JOIN erp_accounts a ON a.id = jl.account_id").
-			Where("je.status = ? AND je.period = ? AND a.type = ?", "posted", period, "expense").
-			Select("COALESCE(SUM(jl.debit - jl.credit), 0)").Row().Scan(&expense)
-
-		profit := revenue - expense
+	case "income_statement", "balance_sheet", "cash_flow":
+		opts, err := statementOptionsFromInput(input)
+		if err != nil {
+			return nil, err
+		}
+		st, err := buildStatement(db, reportType, opts)
+		if err != nil {
+			return nil, err
+		}
 
+		payload := map[string]any{"report_type": reportType, "period": st.Period, "compare": st.CompareMode, "totals": st.Totals, "balanced": st.Balanced}
 		emitEvent(adapter, runID, stepID, "erp.report.generated",
-			fmt.Sprintf("利润表生成: %s, 收入%.2f, 支出%.2f, 利润%.2f", period, revenue, expense, profit),
-			map[string]any{"report_type": reportType, "period": period, "revenue": revenue, "expense": expense, "profit": profit})
+			fmt.Sprintf("%s生成: %s, %d个分区", st.Title, st.Current.label(), len(st.Sections)), payload)
 
-		return map[string]any{"report_type": reportType, "period": period, "revenue": revenue, "expense": expense, "profit": profit}, nil
+		out := map[string]any{
+			"report_type":    reportType,
+			"period":         st.Period,
+			"statement":      st,
+			"totals":         st.Totals,
+			"compare_totals": st.CompareTotals,
+			"balanced":       st.Balanced,
+			"url":            statementExportURL(reportType, opts),
+		}
+		// 利润表保留原有的收入/支出/利润字段
+		if reportType == "income_statement" {
+			out["revenue"], out["expense"], out["profit"] = st.Totals["revenue"], st.Totals["expense"], st.Totals["profit"]
+		}
+		return out, nil
+
+	case "drill_down":
+		ids := getStrSlice(input, "account_ids")
+		if id := getStr(input, "account_id"); id != "" {
+			ids = append(ids, id)
+		}
+		r := stmtRange{From: getStr(input, "period_from"), To: getStr(input, "period_to")}
+		if r.From == "" && r.To == "" && period != "" {
+			r = stmtRange{From: period, To: period}
+		}
+		result, err := drillDownJournal(db, ids, r, getInt(input, "page", 1), getInt(input, "page_size", 50))
+		if err != nil {
+			return nil, err
+		}
+		result["report_type"] = reportType
+		return result, nil
 
 	default:
-		return nil, fmt.Errorf("unsupported report type: %s (supported: trial_balance, income_statement)", reportType)
+		return nil, fmt.Errorf("unsupported report type: %s (supported: trial_balance, income_statement, balance_sheet, cash_flow, drill_down)", reportType)
 	}
 }
 
@@ -2548,6 +2770,11 @@ func cmdUpdateShipmentStatus(db *gorm.DB, adapter sdk.EngineAdapter, runID, step
 		updates["delivered_at"] = &now
 	}
 	db.Model(&shipment).Updates(updates)
+	if newStatus == "cancelled" {
+		db.Model(&ErpPackage{}).Where("shipment_id = ? AND status <> ?", shipmentID, packageVoid).
+			Update("status", packageVoid)
+		releaseVoidContents(db)
+	}
 	emitEvent(adapter, runID, stepID, "erp.shipment.status_changed",
 		fmt.Sprintf("发货单状态变更: %s → %s", shipment.Code, newStatus),
 		map[string]any{"shipment_id": shipmentID, "status": newStatus})
@@ -2570,7 +2797,7 @@ func cmdExtendQuotation(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID st
 	return map[string]any{"quotation_id": quotationID, "valid_until": newDate.Format("2006-01-02")}, nil
 }
 
-// cmdSendPaymentReminder — 发送催款通知
+// cmdSendPaymentReminder — 发送催款通知（手工，按发票逾期天数定级并记录催款）
 func cmdSendPaymentReminder(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
 	invoiceID := getStr(input, "invoice_id")
 	if invoiceID == "" {
@@ -2580,10 +2807,19 @@ func cmdSendPaymentReminder(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepI
 	if err := db.First(&inv, "id = ?", invoiceID).Error; err != nil {
 		return nil, fmt.Errorf("invoice not found")
 	}
+	now := time.Now()
+	days := daysOverdue(inv.DueDate, now)
+	level := dunningLevelFor(loadDunningLevels(db), days)
+	cand := dunningCandidate{invoice: &inv, level: level, open: roundAmount(inv.Total - inv.PaidAmount), days: max(days, 0)}
+	notice, err := createDunningNotice(db, inv.CustomerID, level, []dunningCandidate{cand}, true, false, now)
+	if err != nil {
+		return nil, fmt.Errorf("record dunning notice failed: %w", err)
+	}
 	emitEvent(adapter, runID, stepID, "erp.payment.reminder_sent",
 		fmt.Sprintf("催款通知已发送: %s ¥%.0f", inv.Code, inv.Total),
-		map[string]any{"invoice_id": invoiceID, "amount": inv.Total, "customer_id": inv.CustomerID})
-	return map[string]any{"invoice_id": invoiceID, "reminder_sent": true}, nil
+		map[string]any{"invoice_id": invoiceID, "amount": inv.Total, "customer_id": inv.CustomerID,
+			"notice_id": notice.ID, "level": notice.Level})
+	return map[string]any{"invoice_id": invoiceID, "reminder_sent": true, "notice_id": notice.ID, "level": notice.Level}, nil
 }
 
 // ---------------------------------------------------------------------------
@@ -2601,24 +2837,38 @@ func cmdSendPaymentReminder(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepI
 //      since SRM types live in a separate Go module).
 //   2. Lazily bootstraps the minimal chart-of-accounts rows needed (2202
 //      应付账款, 1002 银行存款) when the accounts table is empty.
-//   3. Creates a posted ErpJournalEntry with source_type="srm_settlement"
-//      so finance can trace every AP entry back to its settlement.
-//   4. Writes two ErpJournalLine rows: DR 应付账款, CR 银行存款.
+//   3. Registers the settlement as an AP invoice (open item, see ap.go)
+//      and pays it in full through an AP payment, so the subledger and
+//      aging stay in step with the one-step flow.
+//   4. Creates a posted ErpJournalEntry with source_type="srm_settlement"
+//      so finance can trace every AP entry back to its settlement:
+//      DR 应付账款, CR 银行存款.
 //   5. Flips the SRM settlement status to "posted" via raw UPDATE (keeping
 //      the SRM row authoritative for the collaboration side).
+//
+// Foreign-currency settlements carry the functional amount at the
+// settlement rate and the realized FX difference against the rates locked
+// at PO approval. The AP line is relieved at the booked amount, cash is
+// credited at the settlement amount and the difference goes to 6603
+// 汇兑损益 (debit for a loss, credit for a gain). Callers may override the
+// rate / fx_diff through the input.
 
 // srmSettlementLite is a minimal shape mirroring srm_settlements — enough
 // for AP posting. We intentionally do not import the SRM package so the
 // ERP module keeps a clean dependency boundary.
 type srmSettlementLite struct {
-	ID             string
-	SettlementCode string
-	SupplierID     string
-	FinalAmount    float64
-	InvoiceNo      string
-	Status         string
-	PeriodStart    *time.Time
-	PeriodEnd      *time.Time
+	ID               string
+	SettlementCode   string
+	SupplierID       string
+	FinalAmount      float64
+	Currency         string
+	ExchangeRate     *float64
+	FunctionalAmount *float64
+	FXDiff           *float64 `gorm:"column:fx_diff"`
+	InvoiceNo        string
+	Status           string
+	PeriodStart      *time.Time
+	PeriodEnd        *time.Time
 }
 
 func (srmSettlementLite) TableName() string { return "srm_settlements" }
@@ -2628,21 +2878,15 @@ func cmdPostAPFromSettlement(db *gorm.DB, adapter sdk.EngineAdapter, runID, step
 	if settlementID == "" {
 		return nil, fmt.Errorf("settlement_id is required")
 	}
-
-	var stl srmSettlementLite
-	if err := db.Table("srm_settlements").
-		Select("id, settlement_code, supplier_id, final_amount, invoice_no, status, period_start, period_end").
-		Where("id = ?", settlementID).
-		Scan(&stl).Error; err != nil || stl.ID == "" {
-		return nil, fmt.Errorf("settlement not found: %s", settlementID)
+	stl, err := loadSettlement(db, settlementID)
+	if err != nil {
+		return nil, err
 	}
-	if stl.FinalAmount <= 0 {
-		return nil, fmt.Errorf("settlement final_amount must be positive, got %.2f", stl.FinalAmount)
+	rate, cashAmount, fxDiff, apAmount, err := settlementAmounts(stl, input)
+	if err != nil {
+		return nil, err
 	}
-
-	// Bootstrap AP + Cash accounts if they don't exist.
-	apAccount := ensureAccount(db, "2202", "应付账款", "liability")
-	cashAccount := ensureAccount(db, "1002", "银行存款", "asset")
+	currency := stl.Currency
 
 	now := time.Now()
 	period := ""
@@ -2651,57 +2895,47 @@ func cmdPostAPFromSettlement(db *gorm.DB, adapter sdk.EngineAdapter, runID, step
 	} else {
 		period = now.Format("2006-01")
 	}
-
-	var entry ErpJournalEntry
-	entryCode, err := autoCodeWithRetry(db, "erp_journal_entries", "JE", func(c string) error {
-		entry = ErpJournalEntry{
-			ID:          uuid.New().String(),
-			Code:        c,
-			Period:      period,
-			EntryDate:   &now,
-			SourceType:  "srm_settlement",
-			SourceID:    stl.ID,
-			Description: fmt.Sprintf("供应商结算 %s (发票 %s)", stl.SettlementCode, stl.InvoiceNo),
-			TotalDebit:  stl.FinalAmount,
-			TotalCredit: stl.FinalAmount,
-			Status:      "posted",
-			PostedBy:    getStr(input, "posted_by"),
-			PostedAt:    &now,
+	entry := ErpJournalEntry{
+		Period:      period,
+		EntryDate:   &now,
+		SourceType:  "srm_settlement",
+		SourceID:    stl.ID,
+		Description: fmt.Sprintf("供应商结算 %s (发票 %s)", stl.SettlementCode, stl.InvoiceNo),
+		PostedBy:    getStr(input, "posted_by"),
+	}
+
+	// 结算单先登记为应付发票未清项，再整单付款核销；已部分付款的按未清金额比例付款
+	var inv *ErpAPInvoice
+	var pay ErpAPPayment
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if inv, err = invoiceFromSettlement(tx, stl, input); err != nil {
+			return err
 		}
-		return db.Create(&entry).Error
-	})
-	if err != nil {
+		open := apOpenAmount(inv)
+		if open <= 0 {
+			return fmt.Errorf("settlement %s is already paid (%s)", stl.SettlementCode, inv.Code)
+		}
+		if open < apAmount-0.005 {
+			fxDiff = roundAmount(fxDiff * open / apAmount)
+			cashAmount = roundAmount(open + fxDiff)
+		}
+		pay = ErpAPPayment{
+			SupplierID:     stl.SupplierID,
+			Amount:         open,
+			CashAmount:     cashAmount,
+			FXDiff:         fxDiff,
+			Currency:       currency,
+			OriginalAmount: roundAmount(stl.FinalAmount * open / inv.Total),
+			PaymentMethod:  "bank_transfer",
+			ReferenceNo:    stl.SettlementCode,
+			PaidDate:       &now,
+		}
+		return applyAPPayment(tx, &pay, []ErpAPPaymentAllocation{{InvoiceID: inv.ID, Amount: open}}, &entry)
+	}); err != nil {
 		return nil, fmt.Errorf("create AP journal entry failed: %w", err)
 	}
-
-	// DR 应付账款
-	if err := db.Create(&ErpJournalLine{
-		ID:             uuid.New().String(),
-		EntryID:        entry.ID,
-		AccountID:      apAccount.ID,
-		Debit:          stl.FinalAmount,
-		Credit:         0,
-		Currency:       "CNY",
-		OriginalAmount: stl.FinalAmount,
-		Description:    fmt.Sprintf("%s 应付账款冲销", stl.SettlementCode),
-		SupplierID:     stl.SupplierID,
-	}).Error; err != nil {
-		return nil, fmt.Errorf("create DR line failed: %w", err)
-	}
-	// CR 银行存款
-	if err := db.Create(&ErpJournalLine{
-		ID:             uuid.New().String(),
-		EntryID:        entry.ID,
-		AccountID:      cashAccount.ID,
-		Debit:          0,
-		Credit:         stl.FinalAmount,
-		Currency:       "CNY",
-		OriginalAmount: stl.FinalAmount,
-		Description:    fmt.Sprintf("%s 银行付款", stl.SettlementCode),
-		SupplierID:     stl.SupplierID,
-	}).Error; err != nil {
-		return nil, fmt.Errorf("create CR line failed: %w", err)
-	}
+	entryCode := entry.Code
 
 	// Flip SRM settlement status to "posted" so the collaboration row
 	// reflects that finance has already booked it.
@@ -2711,23 +2945,41 @@ func cmdPostAPFromSettlement(db *gorm.DB, adapter sdk.EngineAdapter, runID, step
 	})
 
 	emitEvent(adapter, runID, stepID, "erp.ap.settlement_posted",
-		fmt.Sprintf("AP 过账: %s → 凭证 %s, 金额 ¥%.2f", stl.SettlementCode, entryCode, stl.FinalAmount),
+		fmt.Sprintf("AP 过账: %s → 凭证 %s, 金额 ¥%.2f (%s %.2f, 汇兑损益 ¥%.2f)", stl.SettlementCode, entryCode, cashAmount, currency, stl.FinalAmount, fxDiff),
 		map[string]any{
 			"journal_entry_id": entry.ID,
 			"journal_code":     entryCode,
 			"settlement_id":    stl.ID,
 			"supplier_id":      stl.SupplierID,
-			"amount":           stl.FinalAmount,
+			"ap_invoice_id":    inv.ID,
+			"payment_id":       pay.ID,
+			"amount":           cashAmount,
+			"currency":         currency,
+			"fx_diff":          fxDiff,
 		})
 
 	return map[string]any{
-		"journal_entry_id": entry.ID,
-		"journal_code":     entryCode,
-		"settlement_id":    stl.ID,
-		"amount":           stl.FinalAmount,
+		"journal_entry_id":  entry.ID,
+		"journal_code":      entryCode,
+		"settlement_id":     stl.ID,
+		"ap_invoice_id":     inv.ID,
+		"payment_id":        pay.ID,
+		"amount":            cashAmount,
+		"currency":          currency,
+		"original_amount":   stl.FinalAmount,
+		"exchange_rate":     rate,
+		"functional_amount": cashAmount,
+		"fx_diff":           fxDiff,
 	}, nil
 }
 
+func derefFloat(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
 // ensureAccount fetches the account with the given code, creating it with
 // the supplied name/type if missing. Used for lazy bootstrapping of the
 // minimal chart of accounts needed by AP posting.
@@ -2737,14 +2989,15 @@ func ensureAccount(db *gorm.DB, code, name, accountType string) ErpAccount {
 		return acc
 	}
 	acc = ErpAccount{
-		ID:       uuid.New().String(),
-		Code:     code,
-		Name:     name,
-		Type:     accountType,
-		Level:    1,
-		IsLeaf:   true,
-		Currency: "CNY",
-		Status:   "active",
+		ID:            uuid.New().String(),
+		Code:          code,
+		Name:          name,
+		Type:          accountType,
+		Level:         1,
+		IsLeaf:        true,
+		Currency:      "CNY",
+		Status:        "active",
+		CashFlowClass: defaultCashFlowClass(code),
 	}
 	db.Create(&acc)
 	return acc
@@ -2917,6 +3170,7 @@ type accountSpec struct {
 var defaultChartOfAccounts = []accountSpec{
 	{Code: "1002", Name: "银行存款", Type: "asset"},
 	{Code: "1403", Name: "原材料", Type: "asset"},
+	{Code: "1404", Name: "材料成本差异", Type: "asset"},
 	{Code: "1405", Name: "库存商品", Type: "asset"},
 	{Code: "1411", Name: "在制品", Type: "asset"},
 	{Code: "2202", Name: "应付账款", Type: "liability"},
@@ -2926,8 +3180,8 @@ var defaultChartOfAccounts = []accountSpec{
 	{Code: "6603", Name: "财务费用", Type: "expense"},
 }
 
-// cmdBootstrapChartOfAccounts creates the default 9 accounts if missing.
-// Idempotent.
+// cmdBootstrapChartOfAccounts creates the default 10 accounts if missing and
+// seeds the default posting rules when none exist. Idempotent.
 func cmdBootstrapChartOfAccounts(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
 	created := 0
 	existing := 0
@@ -2940,154 +3194,86 @@ func cmdBootstrapChartOfAccounts(db *gorm.DB, adapter sdk.EngineAdapter, runID,
 		ensureAccount(db, spec.Code, spec.Name, spec.Type)
 		created++
 	}
-	emitEvent(adapter, runID, stepID, "erp.account.bootstrapped",
-		fmt.Sprintf("科目表初始化: 新建 %d, 已存在 %d", created, existing),
-		map[string]any{"created": created, "existing": existing})
-	return map[string]any{"created": created, "existing": existing}, nil
-}
-
-// inventoryAccountForMaterial returns the asset account code that should
-// hold this material's value. Default rule:
-//   - finished products (warehouse type "finished") → 1405 库存商品
-//   - WIP                                            → 1411 在制品
-//   - everything else                                → 1403 原材料
-// PRD v2 may override per material category later.
-func inventoryAccountForMaterial(db *gorm.DB, warehouseID string) string {
-	var wh ErpWarehouse
-	if err := db.First(&wh, "id = ?", warehouseID).Error; err != nil {
-		return "1403"
-	}
-	switch wh.Type {
-	case "finished":
-		return "1405"
-	case "wip":
-		return "1411"
-	default:
-		return "1403"
+	rules, err := ensurePostingRules(db)
+	if err != nil {
+		return nil, err
 	}
+	emitEvent(adapter, runID, stepID, "erp.account.bootstrapped",
+		fmt.Sprintf("科目表初始化: 新建 %d, 已存在 %d, 默认过账规则 %d", created, existing, rules),
+		map[string]any{"created": created, "existing": existing, "posting_rules": rules})
+	return map[string]any{"created": created, "existing": existing, "posting_rules": rules}, nil
 }
 
+// inboundInventoryTxTypes are matched against the destination warehouse when
+// resolving posting rules; all other types use the source warehouse.
+var inboundInventoryTxTypes = map[string]bool{"po_receipt": true, "production_in": true, "adjustment_in": true}
+
 // postInventoryJournal generates a balanced ErpJournalEntry + 2 lines for an
 // inventory transaction. Returns the new entry ID. Should be called from
 // within the same DB transaction as the inventory mutation so that "失败回滚"
 // gives both directions atomicity.
 //
-// Mapping:
-//   po_receipt        DR inventoryAcct  / CR 2202 应付账款
-//   production_in     DR 1405 库存商品  / CR 1411 在制品
-//   production_issue  DR 1411 在制品    / CR 1403 原材料
-//   sales_issue       DR 6401 主营业务成本 / CR 1405 库存商品
-//   adjustment_in     DR inventoryAcct  / CR 5301 营业外收入
-//   adjustment_out    DR 5302 营业外支出 / CR inventoryAcct
-//   scrap             DR 5302 营业外支出 / CR inventoryAcct
-//   transfer_out      no posting (value-neutral)
+// Accounts come from erp_posting_rules matched on (txn.Type, warehouse type,
+// material category); see defaultPostingRules for the out-of-the-box mapping.
+// Transaction types without a rule (e.g. transfer_out) are value-neutral and
+// produce no entry.
 func postInventoryJournal(tx *gorm.DB, txn *ErpInventoryTransaction, supplierID string) (string, error) {
 	if txn.TotalAmount <= 0 {
 		return "", nil
 	}
-	var drCode, crCode string
-	switch txn.Type {
-	case "po_receipt":
-		drCode = inventoryAccountForMaterial(tx, txn.ToWarehouseID)
-		crCode = "2202"
-	case "production_in":
-		drCode = "1405"
-		crCode = "1411"
-	case "production_issue":
-		drCode = "1411"
-		crCode = "1403"
-	case "sales_issue":
-		drCode = "6401"
-		crCode = "1405"
-	case "adjustment_in":
-		drCode = inventoryAccountForMaterial(tx, txn.ToWarehouseID)
-		crCode = "5301"
-	case "adjustment_out", "scrap":
-		drCode = "5302"
-		crCode = inventoryAccountForMaterial(tx, txn.FromWarehouseID)
-	default:
-		return "", nil
+	warehouseID := txn.FromWarehouseID
+	if inboundInventoryTxTypes[txn.Type] {
+		warehouseID = txn.ToWarehouseID
 	}
-
-	dr := ensureAccount(tx, drCode, accountNameByCode(drCode), accountTypeByCode(drCode))
-	cr := ensureAccount(tx, crCode, accountNameByCode(crCode), accountTypeByCode(crCode))
-
-	now := time.Now()
-	period := now.Format("2006-01")
-	var entry ErpJournalEntry
-	code, err := autoCodeWithRetry(tx, "erp_journal_entries", "JE", func(c string) error {
-		entry = ErpJournalEntry{
-			ID:          uuid.New().String(),
-			Code:        c,
-			Period:      period,
-			EntryDate:   &now,
-			SourceType:  "inventory_txn",
-			SourceID:    txn.ID,
-			Description: fmt.Sprintf("库存事务 %s (%s)", txn.Code, txn.Type),
-			TotalDebit:  txn.TotalAmount,
-			TotalCredit: txn.TotalAmount,
-			Status:      "posted",
-			PostedAt:    &now,
-			PostedBy:    txn.OperatorID,
-		}
-		return tx.Create(&entry).Error
-	})
+	rule, err := resolvePostingRule(tx, postingContextFor(tx, txn.Type, warehouseID, txn.MaterialID))
 	if err != nil {
-		return "", fmt.Errorf("create journal entry failed: %w", err)
+		return "", err
+	}
+	if rule == nil {
+		return "", nil
 	}
-	_ = code
 
-	lines := []ErpJournalLine{
-		{
-			ID:             uuid.New().String(),
-			EntryID:        entry.ID,
-			AccountID:      dr.ID,
-			Debit:          txn.TotalAmount,
-			Credit:         0,
-			Currency:       "CNY",
-			OriginalAmount: txn.TotalAmount,
-			Description:    fmt.Sprintf("%s DR", txn.Code),
-			SupplierID:     supplierID,
-		},
-		{
-			ID:             uuid.New().String(),
-			EntryID:        entry.ID,
-			AccountID:      cr.ID,
-			Debit:          0,
-			Credit:         txn.TotalAmount,
-			Currency:       "CNY",
-			OriginalAmount: txn.TotalAmount,
-			Description:    fmt.Sprintf("%s CR", txn.Code),
-			SupplierID:     supplierID,
-		},
+	entry := ErpJournalEntry{
+		SourceType:  "inventory_txn",
+		SourceID:    txn.ID,
+		Description: fmt.Sprintf("库存事务 %s (%s)", txn.Code, txn.Type),
+		PostedBy:    txn.OperatorID,
 	}
-	for i := range lines {
-		if err := tx.Create(&lines[i]).Error; err != nil {
-			return "", fmt.Errorf("create journal line failed: %w", err)
-		}
+	line := ErpJournalLine{
+		Currency:       "CNY",
+		OriginalAmount: txn.TotalAmount,
+		Description:    txn.Code,
+		SupplierID:     supplierID,
 	}
-	return entry.ID, nil
-}
-
-// accountNameByCode / accountTypeByCode are helpers used by postInventoryJournal
-// when ensureAccount is called for an account that may not yet exist (the
-// chart of accounts is bootstrapped lazily on first reference).
-func accountNameByCode(code string) string {
-	for _, a := range defaultChartOfAccounts {
-		if a.Code == code {
-			return a.Name
+	if txn.PriceVariance == 0 {
+		if err := postByRule(tx, rule, txn.TotalAmount, &entry, line); err != nil {
+			return "", err
 		}
+		return entry.ID, nil
 	}
-	return code
-}
 
-func accountTypeByCode(code string) string {
-	for _, a := range defaultChartOfAccounts {
-		if a.Code == code {
-			return a.Type
-		}
+	// 标准成本采购入库：存货按标准成本入账，价差与存货分录合并为一张凭证
+	lines, err := ruleLines(tx, rule, txn.TotalAmount, &entry, line)
+	if err != nil {
+		return "", err
+	}
+	ppvRule, err := resolvePostingRule(tx, postingContextFor(tx, postingTxPPV, warehouseID, txn.MaterialID))
+	if err != nil {
+		return "", err
 	}
-	return "asset"
+	if ppvRule == nil {
+		return "", fmt.Errorf("no active posting rule for %s", postingTxPPV)
+	}
+	line.OriginalAmount = txn.PriceVariance
+	line.Description = txn.Code + " 采购价格差异"
+	ppvLines, err := ruleLines(tx, ppvRule, txn.PriceVariance, &entry, line)
+	if err != nil {
+		return "", err
+	}
+	if err := createPostedEntry(tx, &entry, append(lines, ppvLines...)); err != nil {
+		return "", err
+	}
+	return entry.ID, nil
 }
 
 // isPeriodLocked checks whether the current month period is closed via
@@ -0,0 +1,340 @@
+package erp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// ===========================================================================
+// 物流标签：每个包装件一张 4×6 英寸标签，条码为 GS1-128 (00) SSCC
+//
+// ZPL 直接发送到 203dpi 斑马打印机；PDF 为无外部依赖的最小实现，ASCII
+// 使用 Helvetica，中文使用 Adobe 标准 CJK 字体 STSong-Light (阅读器内置)。
+// ===========================================================================
+
+// shippingLabel 标签内容
+type shippingLabel struct {
+	SSCC         string
+	PackageCode  string
+	PackageType  string
+	Seq          int
+	Total        int
+	ShipmentCode string
+	OrderCode    string
+	CustomerName string
+	ShipTo       string
+	Carrier      string
+	TrackingNo   string
+	GrossWeight  float64
+	Contents     []string // "物料编码 × 数量"，托盘为合计
+}
+
+// buildShippingLabels 为发货单的包装件生成标签数据；packageID 为空时返回全部未作废包装件
+func buildShippingLabels(db *gorm.DB, shipment *ErpShipment, packageID string) []shippingLabel {
+	pl := buildPackingList(db, shipment)
+	total := map[string]int{packageCarton: pl.CartonCount, packagePallet: pl.PalletCount}
+
+	var ids []string
+	for _, line := range pl.Lines {
+		ids = append(ids, line.ProductID)
+	}
+	refs := loadMaterialRefs(db, ids)
+	summarize := func(n *packingListPackage) []string {
+		qty := map[string]float64{}
+		var order []string
+		var walk func(*packingListPackage)
+		walk = func(p *packingListPackage) {
+			for _, c := range p.Contents {
+				if _, ok := qty[c.ProductID]; !ok {
+					order = append(order, c.ProductID)
+				}
+				qty[c.ProductID] += c.Quantity
+			}
+			for _, child := range p.Children {
+				walk(child)
+			}
+		}
+		walk(n)
+		var out []string
+		if len(n.Children) > 0 {
+			out = append(out, fmt.Sprintf("%d CARTONS", len(n.Children)))
+		}
+		for _, id := range order {
+			code := refs[id].Code
+			if code == "" {
+				code = id
+			}
+			out = append(out, fmt.Sprintf("%s x %g", code, qty[id]))
+		}
+		return out
+	}
+
+	var labels []shippingLabel
+	var visit func(*packingListPackage)
+	visit = func(n *packingListPackage) {
+		if packageID == "" || n.ID == packageID {
+			labels = append(labels, shippingLabel{
+				SSCC: n.SSCC, PackageCode: n.Code, PackageType: n.Type, Seq: n.Seq, Total: total[n.Type],
+				ShipmentCode: shipment.Code, OrderCode: pl.OrderCode, CustomerName: pl.CustomerName,
+				ShipTo: shipment.ShippingAddress, Carrier: shipment.Carrier, TrackingNo: shipment.TrackingNo,
+				GrossWeight: n.GrossWeight, Contents: summarize(n),
+			})
+		}
+		for _, child := range n.Children {
+			visit(child)
+		}
+	}
+	for _, n := range pl.Packages {
+		visit(n)
+	}
+	return labels
+}
+
+// ssccHuman formats an SSCC for the human readable line: (00) 3 1234567 000000001 8.
+func ssccHuman(sscc string) string {
+	if len(sscc) != 18 {
+		return "(00) " + sscc
+	}
+	return fmt.Sprintf("(00) %s %s %s", sscc[:1], sscc[1:17], sscc[17:])
+}
+
+// wrapText splits s into at most maxLines lines of width units (CJK counts as 2).
+func wrapText(s string, width, maxLines int) []string {
+	var lines []string
+	var cur strings.Builder
+	w := 0
+	for _, r := range strings.Join(strings.Fields(s), " ") {
+		rw := 1
+		if r >= 0x2E80 {
+			rw = 2
+		}
+		if w+rw > width {
+			lines = append(lines, cur.String())
+			cur.Reset()
+			w = 0
+			if len(lines) == maxLines {
+				return lines
+			}
+			if r == ' ' {
+				continue
+			}
+		}
+		cur.WriteRune(r)
+		w += rw
+	}
+	if cur.Len() > 0 && len(lines) < maxLines {
+		lines = append(lines, cur.String())
+	}
+	return lines
+}
+
+// ── ZPL ──
+
+func zplField(s string) string {
+	return strings.NewReplacer("^", " ", "~", " ", "\\", "/").Replace(s)
+}
+
+// renderZPL renders 812×1218 dot labels (4×6 in at 203 dpi), one ^XA..^XZ block per package.
+func renderZPL(labels []shippingLabel) []byte {
+	var b bytes.Buffer
+	for _, l := range labels {
+		b.WriteString("^XA\n^CI28\n^PW812\n^LL1218\n")
+		fmt.Fprintf(&b, "^FO40,40^A0N,28,28^FDSHIP TO^FS\n")
+		fmt.Fprintf(&b, "^FO40,80^A0N,40,40^FD%s^FS\n", zplField(l.CustomerName))
+		for i, line := range wrapText(l.ShipTo, 46, 3) {
+			fmt.Fprintf(&b, "^FO40,%d^A0N,30,30^FD%s^FS\n", 130+i*36, zplField(line))
+		}
+		b.WriteString("^FO30,250^GB752,3,3^FS\n")
+		fmt.Fprintf(&b, "^FO40,275^A0N,30,30^FDORDER: %s^FS\n", zplField(l.OrderCode))
+		fmt.Fprintf(&b, "^FO420,275^A0N,30,30^FDSHIPMENT: %s^FS\n", zplField(l.ShipmentCode))
+		fmt.Fprintf(&b, "^FO40,320^A0N,30,30^FDCARRIER: %s^FS\n", zplField(l.Carrier))
+		fmt.Fprintf(&b, "^FO40,365^A0N,30,30^FDTRACKING: %s^FS\n", zplField(l.TrackingNo))
+		b.WriteString("^FO30,420^GB752,3,3^FS\n")
+		fmt.Fprintf(&b, "^FO40,450^A0N,50,50^FD%s %d OF %d^FS\n", strings.ToUpper(l.PackageType), l.Seq, l.Total)
+		fmt.Fprintf(&b, "^FO500,460^A0N,36,36^FDGW %.2f KG^FS\n", l.GrossWeight)
+		fmt.Fprintf(&b, "^FO40,520^A0N,26,26^FD%s^FS\n", zplField(l.PackageCode))
+		for i, line := range l.Contents {
+			if i == 4 {
+				break
+			}
+			fmt.Fprintf(&b, "^FO40,%d^A0N,28,28^FD%s^FS\n", 560+i*34, zplField(line))
+		}
+		b.WriteString("^FO30,710^GB752,3,3^FS\n")
+		b.WriteString("^FO40,735^A0N,28,28^FDSSCC^FS\n")
+		fmt.Fprintf(&b, "^BY3^FO100,780^BCN,260,N,N,N^FD>;>800%s^FS\n", l.SSCC)
+		fmt.Fprintf(&b, "^FO40,1070^A0N,44,44^FD%s^FS\n", ssccHuman(l.SSCC))
+		b.WriteString("^XZ\n")
+	}
+	return b.Bytes()
+}
+
+// ── GS1-128 ──
+
+// code128Patterns bar/space module widths of symbol values 0-106 (106 = stop).
+var code128Patterns = strings.Fields(`
+212222 222122 222221 121223 121322 131222 122213 122312 132212 221213
+221312 231212 112232 122132 122231 113222 123122 123221 223211 221132
+221231 213212 223112 312131 311222 321122 321221 312212 322112 322211
+212123 212321 232121 111323 131123 131321 112313 132113 132311 211313
+231113 231311 112133 112331 132131 113123 113321 133121 313121 211331
+231131 213113 213311 213131 311123 311321 331121 312113 312311 332111
+314111 221411 431111 111224 111422 121124 121421 141122 141221 112214
+112412 122114 122411 142112 142211 241211 221114 413111 241112 134111
+111242 121142 121241 114212 124112 124211 411212 421112 421211 212141
+214121 412121 111143 111341 131141 114113 114311 411113 411311 113141
+114131 311141 411131 211412 211214 211232 2331112`)
+
+// gs1128Modules encodes an all-numeric GS1 element string (even length) in code set C
+// and returns alternating bar/space widths, starting with a bar.
+func gs1128Modules(digits string) []int {
+	const startC, fnc1, stop = 105, 102, 106
+	symbols := []int{startC, fnc1}
+	for i := 0; i+1 < len(digits); i += 2 {
+		symbols = append(symbols, int(digits[i]-'0')*10+int(digits[i+1]-'0'))
+	}
+	sum := symbols[0]
+	for i, v := range symbols[1:] {
+		sum += v * (i + 1)
+	}
+	symbols = append(symbols, sum%103, stop)
+
+	var widths []int
+	for _, s := range symbols {
+		for _, ch := range code128Patterns[s] {
+			widths = append(widths, int(ch-'0'))
+		}
+	}
+	return widths
+}
+
+// ── PDF ──
+
+const (
+	labelPageWidth  = 288 // 4 in
+	labelPageHeight = 432 // 6 in
+)
+
+// pdfText writes s at (x, y); ASCII runs use Helvetica (F1), others STSong-Light (F2).
+func pdfText(b *bytes.Buffer, x, y, size float64, s string) {
+	fmt.Fprintf(b, "BT %.2f %.2f Td ", x, y)
+	for len(s) > 0 {
+		ascii := s[0] < utf8.RuneSelf
+		n := 0
+		for n < len(s) && (s[n] < utf8.RuneSelf) == ascii {
+			if ascii {
+				n++
+			} else {
+				_, w := utf8.DecodeRuneInString(s[n:])
+				n += w
+			}
+		}
+		run := s[:n]
+		s = s[n:]
+		if ascii {
+			esc := strings.NewReplacer("\\", "\\\\", "(", "\\(", ")", "\\)").Replace(run)
+			fmt.Fprintf(b, "/F1 %.1f Tf (%s) Tj ", size, esc)
+			continue
+		}
+		fmt.Fprintf(b, "/F2 %.1f Tf <", size)
+		for _, r := range run {
+			if r > 0xFFFF {
+				r = '?'
+			}
+			fmt.Fprintf(b, "%04X", r)
+		}
+		b.WriteString("> Tj ")
+	}
+	b.WriteString("ET\n")
+}
+
+func labelPageContent(l shippingLabel) []byte {
+	var b bytes.Buffer
+	const left = 14.0
+	pdfText(&b, left, 410, 8, "SHIP TO")
+	pdfText(&b, left, 394, 13, l.CustomerName)
+	for i, line := range wrapText(l.ShipTo, 52, 3) {
+		pdfText(&b, left, 380-float64(i)*12, 9, line)
+	}
+	b.WriteString("0.8 w 10 338 m 278 338 l S\n")
+	pdfText(&b, left, 324, 9, "ORDER: "+l.OrderCode)
+	pdfText(&b, 150, 324, 9, "SHIPMENT: "+l.ShipmentCode)
+	pdfText(&b, left, 310, 9, "CARRIER: "+l.Carrier)
+	pdfText(&b, left, 296, 9, "TRACKING: "+l.TrackingNo)
+	b.WriteString("10 286 m 278 286 l S\n")
+	pdfText(&b, left, 264, 16, fmt.Sprintf("%s %d OF %d", strings.ToUpper(l.PackageType), l.Seq, l.Total))
+	pdfText(&b, 180, 266, 11, fmt.Sprintf("GW %.2f KG", l.GrossWeight))
+	pdfText(&b, left, 250, 8, l.PackageCode)
+	for i, line := range l.Contents {
+		if i == 4 {
+			break
+		}
+		pdfText(&b, left, 234-float64(i)*12, 9, line)
+	}
+	b.WriteString("10 176 m 278 176 l S\n")
+	pdfText(&b, left, 164, 8, "SSCC")
+
+	// 条码居中，模块宽 1.4pt，高 90pt
+	const module, barHeight, barY = 1.4, 90.0, 60.0
+	widths := gs1128Modules("00" + l.SSCC)
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	x := (labelPageWidth - float64(total)*module) / 2
+	for i, w := range widths {
+		if i%2 == 0 {
+			fmt.Fprintf(&b, "%.2f %.2f %.2f %.2f re ", x, barY, float64(w)*module, barHeight)
+		}
+		x += float64(w) * module
+	}
+	b.WriteString("f\n")
+	pdfText(&b, 50, 38, 13, ssccHuman(l.SSCC))
+	return b.Bytes()
+}
+
+// renderLabelPDF renders one 4×6 in page per label.
+func renderLabelPDF(labels []shippingLabel) []byte {
+	var objs [][]byte
+	add := func(s string) int {
+		objs = append(objs, []byte(s))
+		return len(objs)
+	}
+	add("<< /Type /Catalog /Pages 2 0 R >>")
+	add("") // pages, filled below
+	add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	add("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [5 0 R] >>")
+	add("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 6 0 R /DW 1000 >>")
+	add("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+
+	var kids []string
+	for _, l := range labels {
+		content := labelPageContent(l)
+		stream := add(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			labelPageWidth, labelPageHeight, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objs[1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return b.Bytes()
+}
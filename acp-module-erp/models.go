@@ -11,23 +11,28 @@ import "time"
 
 // ErpCustomer 客户主数据
 type ErpCustomer struct {
-	ID              string    `gorm:"primaryKey;size:100" json:"id"`
-	Code            string    `gorm:"size:50;uniqueIndex" json:"code"`
-	Name            string    `gorm:"size:200;not null" json:"name"`
-	Type            string    `gorm:"size:30" json:"type"`
-	TaxID           string    `gorm:"size:50" json:"tax_id"`
-	ContactName     string    `gorm:"size:100" json:"contact_name"`
-	ContactPhone    string    `gorm:"size:50" json:"contact_phone"`
-	ContactEmail    string    `gorm:"size:200" json:"contact_email"`
-	BillingAddress  string    `gorm:"type:text" json:"billing_address"`
-	ShippingAddress string    `gorm:"type:text" json:"shipping_address"`
-	PaymentTerms    string    `gorm:"size:50" json:"payment_terms"`
-	CreditLimit     float64   `json:"credit_limit"`
-	Currency        string    `gorm:"size:10;default:CNY" json:"currency"`
-	Status          string    `gorm:"size:30;default:active" json:"status"`
-	Tags            string    `gorm:"type:jsonb;default:'[]'" json:"tags"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID               string     `gorm:"primaryKey;size:100" json:"id"`
+	Code             string     `gorm:"size:50;uniqueIndex" json:"code"`
+	Name             string     `gorm:"size:200;not null" json:"name"`
+	Type             string     `gorm:"size:30" json:"type"`
+	TaxID            string     `gorm:"size:50" json:"tax_id"`
+	ContactName      string     `gorm:"size:100" json:"contact_name"`
+	ContactPhone     string     `gorm:"size:50" json:"contact_phone"`
+	ContactEmail     string     `gorm:"size:200" json:"contact_email"`
+	BillingAddress   string     `gorm:"type:text" json:"billing_address"`
+	ShippingAddress  string     `gorm:"type:text" json:"shipping_address"`
+	PaymentTerms     string     `gorm:"size:50" json:"payment_terms"`
+	CreditLimit      float64    `json:"credit_limit"`
+	CreditCheck      string     `gorm:"size:20;default:hold" json:"credit_check"` // block/hold/none
+	CreditHold       bool       `gorm:"default:false" json:"credit_hold"`
+	CreditHoldReason string     `gorm:"size:200" json:"credit_hold_reason"`
+	DunningLevel     int        `gorm:"default:0" json:"dunning_level"`
+	LastDunningAt    *time.Time `json:"last_dunning_at"`
+	Currency         string     `gorm:"size:10;default:CNY" json:"currency"`
+	Status           string     `gorm:"size:30;default:active" json:"status"`
+	Tags             string     `gorm:"type:jsonb;default:'[]'" json:"tags"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
 func (ErpCustomer) TableName() string { return "erp_customers" }
@@ -67,22 +72,30 @@ func (ErpLocation) TableName() string { return "erp_locations" }
 
 // ErpQuotation 销售报价单
 type ErpQuotation struct {
-	ID           string     `gorm:"primaryKey;size:100" json:"id"`
-	Code         string     `gorm:"size:50;uniqueIndex" json:"code"`
-	CustomerID   string     `gorm:"size:100;not null;index" json:"customer_id"`
-	ContactName  string     `gorm:"size:100" json:"contact_name"`
-	Currency     string     `gorm:"size:10;default:CNY" json:"currency"`
-	ExchangeRate float64    `gorm:"default:1" json:"exchange_rate"`
-	Subtotal     float64    `json:"subtotal"`
-	TaxAmount    float64    `json:"tax_amount"`
-	Total        float64    `json:"total"`
-	ValidUntil   *time.Time `json:"valid_until"`
-	PaymentTerms string     `gorm:"size:50" json:"payment_terms"`
-	Notes        string     `gorm:"type:text" json:"notes"`
-	Status       string     `gorm:"size:30;default:draft" json:"status"`
-	CreatedBy    string     `gorm:"size:100" json:"created_by"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID             string     `gorm:"primaryKey;size:100" json:"id"`
+	Code           string     `gorm:"size:50;uniqueIndex" json:"code"`
+	CustomerID     string     `gorm:"size:100;not null;index" json:"customer_id"`
+	ContactName    string     `gorm:"size:100" json:"contact_name"`
+	Currency       string     `gorm:"size:10;default:CNY" json:"currency"`
+	ExchangeRate   float64    `gorm:"default:1" json:"exchange_rate"`
+	Subtotal       float64    `json:"subtotal"`
+	TaxAmount      float64    `json:"tax_amount"`
+	Total          float64    `json:"total"`
+	ValidUntil     *time.Time `json:"valid_until"`
+	PaymentTerms   string     `gorm:"size:50" json:"payment_terms"`
+	Channel        string     `gorm:"size:20;default:direct" json:"channel"` // direct/ecommerce/dealer/retail
+	Cost           float64    `gorm:"default:0" json:"cost"`                 // 本位币成本合计
+	MarginPct      float64    `gorm:"default:0" json:"margin_pct"`
+	MinMarginPct   float64    `gorm:"default:0" json:"min_margin_pct"`
+	ApprovalStatus string     `gorm:"size:20" json:"approval_status"` // ""(无需审批)/pending/approved/rejected
+	ApprovedBy     string     `gorm:"size:100" json:"approved_by"`
+	ApprovedAt     *time.Time `json:"approved_at"`
+	ApprovalNote   string     `gorm:"type:text" json:"approval_note"`
+	Notes          string     `gorm:"type:text" json:"notes"`
+	Status         string     `gorm:"size:30;default:draft" json:"status"`
+	CreatedBy      string     `gorm:"size:100" json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 func (ErpQuotation) TableName() string { return "erp_quotations" }
@@ -99,6 +112,15 @@ type ErpQuotationItem struct {
 	DiscountPct float64   `gorm:"default:0" json:"discount_pct"`
 	TaxRate     float64   `gorm:"default:0" json:"tax_rate"`
 	LineTotal   float64   `json:"line_total"`
+	ListPrice   float64   `gorm:"default:0" json:"list_price"`
+	PriceSource string    `gorm:"size:20" json:"price_source"` // customer/price_list/manual
+	PriceListID string    `gorm:"size:100" json:"price_list_id"`
+	PromotionID string    `gorm:"size:100" json:"promotion_id"`
+	UnitCost    float64   `gorm:"default:0" json:"unit_cost"` // 本位币单位成本
+	CostSource  string    `gorm:"size:20" json:"cost_source"`
+	MarginPct   float64   `gorm:"default:0" json:"margin_pct"`
+	BelowMargin bool      `gorm:"default:false" json:"below_margin"`
+	CostUnknown bool      `gorm:"default:false" json:"cost_unknown"` // 无成本无法校验毛利，需审批
 	SortOrder   int       `gorm:"default:0" json:"sort_order"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -106,27 +128,100 @@ type ErpQuotationItem struct {
 
 func (ErpQuotationItem) TableName() string { return "erp_quotation_items" }
 
+// ── Pricing ──
+
+// ErpPriceList 价目表（按渠道+币种，可指定客户专属价；空渠道/空客户为通用）
+type ErpPriceList struct {
+	ID         string     `gorm:"primaryKey;size:100" json:"id"`
+	Code       string     `gorm:"size:50;uniqueIndex" json:"code"`
+	Name       string     `gorm:"size:200;not null" json:"name"`
+	Channel    string     `gorm:"size:20;index" json:"channel"`
+	Currency   string     `gorm:"size:10;default:CNY" json:"currency"`
+	CustomerID string     `gorm:"size:100;index" json:"customer_id"`
+	Priority   int        `gorm:"default:0" json:"priority"`
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidTo    *time.Time `json:"valid_to"`
+	Status     string     `gorm:"size:20;default:active" json:"status"`
+	Notes      string     `gorm:"type:text" json:"notes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (ErpPriceList) TableName() string { return "erp_price_lists" }
+
+// ErpPriceListItem 价目表明细（同一产品多行即数量阶梯价，取 min_qty 不超过订购量的最高档）
+type ErpPriceListItem struct {
+	ID          string    `gorm:"primaryKey;size:100" json:"id"`
+	PriceListID string    `gorm:"size:100;not null;index" json:"price_list_id"`
+	ProductID   string    `gorm:"size:100;not null;index" json:"product_id"`
+	MinQty      float64   `gorm:"default:0" json:"min_qty"`
+	UnitPrice   float64   `json:"unit_price"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (ErpPriceListItem) TableName() string { return "erp_price_list_items" }
+
+// ErpPromotion 促销折扣（有效期内按渠道/客户/产品匹配，取折扣最大者）
+type ErpPromotion struct {
+	ID          string     `gorm:"primaryKey;size:100" json:"id"`
+	Code        string     `gorm:"size:50;uniqueIndex" json:"code"`
+	Name        string     `gorm:"size:200;not null" json:"name"`
+	Channel     string     `gorm:"size:20" json:"channel"`
+	CustomerID  string     `gorm:"size:100" json:"customer_id"`
+	ProductID   string     `gorm:"size:100;index" json:"product_id"`
+	MinQty      float64    `gorm:"default:0" json:"min_qty"`
+	DiscountPct float64    `json:"discount_pct"`
+	ValidFrom   *time.Time `json:"valid_from"`
+	ValidTo     *time.Time `json:"valid_to"`
+	Status      string     `gorm:"size:20;default:active" json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (ErpPromotion) TableName() string { return "erp_promotions" }
+
+// ErpMarginRule 毛利管控：报价行毛利率低于阈值需审批（空渠道为默认规则）
+type ErpMarginRule struct {
+	ID           string    `gorm:"primaryKey;size:100" json:"id"`
+	Channel      string    `gorm:"size:20;uniqueIndex" json:"channel"`
+	MinMarginPct float64   `json:"min_margin_pct"`
+	Status       string    `gorm:"size:20;default:active" json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (ErpMarginRule) TableName() string { return "erp_margin_rules" }
+
 // ErpSalesOrder 销售订单
 type ErpSalesOrder struct {
-	ID              string     `gorm:"primaryKey;size:100" json:"id"`
-	Code            string     `gorm:"size:50;uniqueIndex" json:"code"`
-	QuotationID     string     `gorm:"size:100;index" json:"quotation_id"`
-	CustomerID      string     `gorm:"size:100;not null;index" json:"customer_id"`
-	ShippingAddress string     `gorm:"type:text" json:"shipping_address"`
-	Currency        string     `gorm:"size:10;default:CNY" json:"currency"`
-	Subtotal        float64    `json:"subtotal"`
-	TaxAmount       float64    `json:"tax_amount"`
-	Total           float64    `json:"total"`
-	PaymentTerms    string     `gorm:"size:50" json:"payment_terms"`
-	ExpectedDate    *time.Time `json:"expected_date"`
-	ShippingMethod  string     `gorm:"size:50" json:"shipping_method"`
-	Priority        string     `gorm:"size:20;default:normal" json:"priority"`
-	Status          string     `gorm:"size:30;default:draft" json:"status"`
-	Notes           string     `gorm:"type:text" json:"notes"`
-	CreatedBy       string     `gorm:"size:100" json:"created_by"`
-	ConfirmedAt     *time.Time `json:"confirmed_at"`
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
+	ID                 string     `gorm:"primaryKey;size:100" json:"id"`
+	Code               string     `gorm:"size:50;uniqueIndex" json:"code"`
+	QuotationID        string     `gorm:"size:100;index" json:"quotation_id"`
+	CustomerID         string     `gorm:"size:100;not null;index" json:"customer_id"`
+	ShippingAddress    string     `gorm:"type:text" json:"shipping_address"`
+	Currency           string     `gorm:"size:10;default:CNY" json:"currency"`
+	Subtotal           float64    `json:"subtotal"`
+	TaxAmount          float64    `json:"tax_amount"`
+	Total              float64    `json:"total"`
+	PaymentTerms       string     `gorm:"size:50" json:"payment_terms"`
+	ExpectedDate       *time.Time `json:"expected_date"`
+	ShippingMethod     string     `gorm:"size:50" json:"shipping_method"`
+	IsExport           bool       `gorm:"default:false" json:"is_export"`     // 出口订单，发货需生成报关发票
+	Incoterm           string     `gorm:"size:10" json:"incoterm"`            // 贸易术语 EXW/FOB/CIF/DAP ...
+	DestinationCountry string     `gorm:"size:50" json:"destination_country"` // 目的国
+	Priority           string     `gorm:"size:20;default:normal" json:"priority"`
+	Status             string     `gorm:"size:30;default:draft" json:"status"`
+	CreditStatus       string     `gorm:"size:20" json:"credit_status"` // passed/held/blocked/released
+	CreditExposure     float64    `gorm:"default:0" json:"credit_exposure"`
+	CreditReleasedBy   string     `gorm:"size:100" json:"credit_released_by"`
+	CreditReleasedAt   *time.Time `json:"credit_released_at"`
+	CreditNote         string     `gorm:"type:text" json:"credit_note"`
+	Notes              string     `gorm:"type:text" json:"notes"`
+	CreatedBy          string     `gorm:"size:100" json:"created_by"`
+	ConfirmedAt        *time.Time `json:"confirmed_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 func (ErpSalesOrder) TableName() string { return "erp_sales_orders" }
@@ -164,6 +259,9 @@ type ErpShipment struct {
 	ShippingAddress string     `gorm:"type:text" json:"shipping_address"`
 	Carrier         string     `gorm:"size:100" json:"carrier"`
 	TrackingNo      string     `gorm:"size:100" json:"tracking_no"`
+	PackageCount    int        `gorm:"default:0" json:"package_count"` // 发货时的外层包装件数（托盘+散箱）
+	GrossWeight     float64    `gorm:"default:0" json:"gross_weight"`  // kg
+	Volume          float64    `gorm:"default:0" json:"volume"`        // m³
 	ShippedAt       *time.Time `json:"shipped_at"`
 	DeliveredAt     *time.Time `json:"delivered_at"`
 	Status          string     `gorm:"size:30;default:pending" json:"status"`
@@ -189,6 +287,117 @@ type ErpShipmentItem struct {
 
 func (ErpShipmentItem) TableName() string { return "erp_shipment_items" }
 
+// ErpPackage 包装件：纸箱或托盘，纸箱可码放在托盘上，每件分配 SSCC
+type ErpPackage struct {
+	ID          string     `gorm:"primaryKey;size:100" json:"id"`
+	Code        string     `gorm:"size:50;uniqueIndex" json:"code"`
+	ShipmentID  string     `gorm:"size:100;not null;index" json:"shipment_id"`
+	ParentID    string     `gorm:"size:100;index" json:"parent_id"` // 所在托盘
+	Type        string     `gorm:"size:20;not null" json:"type"`    // carton / pallet
+	Seq         int        `gorm:"default:0" json:"seq"`            // 同类型包装件在发货单内的序号
+	SSCC        string     `gorm:"size:18;uniqueIndex" json:"sscc"`
+	Length      float64    `gorm:"default:0" json:"length"` // cm
+	Width       float64    `gorm:"default:0" json:"width"`
+	Height      float64    `gorm:"default:0" json:"height"`
+	GrossWeight float64    `gorm:"default:0" json:"gross_weight"` // kg
+	NetWeight   float64    `gorm:"default:0" json:"net_weight"`
+	Status      string     `gorm:"size:20;default:open" json:"status"` // open / sealed / void
+	SealedAt    *time.Time `json:"sealed_at"`
+	Notes       string     `gorm:"type:text" json:"notes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (ErpPackage) TableName() string { return "erp_packages" }
+
+// ErpPackageContent 纸箱装箱明细：序列号逐个扫描，非序列号物料按批次数量
+type ErpPackageContent struct {
+	ID             string    `gorm:"primaryKey;size:100" json:"id"`
+	PackageID      string    `gorm:"size:100;not null;index" json:"package_id"`
+	ShipmentID     string    `gorm:"size:100;not null;index" json:"shipment_id"`
+	ShipmentItemID string    `gorm:"size:100;index" json:"shipment_item_id"`
+	ProductID      string    `gorm:"size:100;index" json:"product_id"`
+	LotNumber      string    `gorm:"size:100" json:"lot_number"`
+	SerialNumber   string    `gorm:"size:100;index" json:"serial_number"`
+	Quantity       float64   `json:"quantity"`
+	Released       bool      `gorm:"default:false" json:"released"` // 所在包装件已作废，序列号可重新装箱
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (ErpPackageContent) TableName() string { return "erp_package_contents" }
+
+// ErpSSCCConfig GS1 SSCC 编码配置：扩展位 + 厂商识别代码 + 流水号 + 校验位 共 18 位
+type ErpSSCCConfig struct {
+	ID             string    `gorm:"primaryKey;size:100" json:"id"`
+	ExtensionDigit int       `gorm:"default:0" json:"extension_digit"`
+	CompanyPrefix  string    `gorm:"size:10;not null" json:"company_prefix"` // GS1 厂商识别代码，7-10 位
+	NextSerial     int64     `gorm:"default:1" json:"next_serial"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (ErpSSCCConfig) TableName() string { return "erp_sscc_configs" }
+
+// ── Customs ──
+
+// ErpCustomsProduct 产品报关资料
+type ErpCustomsProduct struct {
+	ID             string    `gorm:"primaryKey;size:100" json:"id"`
+	ProductID      string    `gorm:"size:100;uniqueIndex" json:"product_id"`
+	HSCode         string    `gorm:"size:20;not null" json:"hs_code"`
+	DeclaredName   string    `gorm:"size:200" json:"declared_name"`    // 申报品名
+	DeclaredNameEn string    `gorm:"size:200" json:"declared_name_en"` // 英文品名
+	OriginCountry  string    `gorm:"size:50;default:CN" json:"origin_country"`
+	Unit           string    `gorm:"size:20;default:pcs" json:"unit"`
+	UnitNetWeight  float64   `gorm:"default:0" json:"unit_net_weight"` // kg
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (ErpCustomsProduct) TableName() string { return "erp_customs_products" }
+
+// ErpCustomsInvoice 报关发票：出口订单发货单按订单行价格生成
+type ErpCustomsInvoice struct {
+	ID                 string     `gorm:"primaryKey;size:100" json:"id"`
+	Code               string     `gorm:"size:50;uniqueIndex" json:"code"`
+	ShipmentID         string     `gorm:"size:100;uniqueIndex" json:"shipment_id"`
+	OrderID            string     `gorm:"size:100;index" json:"order_id"`
+	CustomerID         string     `gorm:"size:100;index" json:"customer_id"`
+	ConsigneeName      string     `gorm:"size:200" json:"consignee_name"`
+	ConsigneeAddress   string     `gorm:"type:text" json:"consignee_address"`
+	DestinationCountry string     `gorm:"size:50" json:"destination_country"`
+	Incoterm           string     `gorm:"size:10" json:"incoterm"`
+	Currency           string     `gorm:"size:10" json:"currency"`
+	TotalAmount        float64    `json:"total_amount"`
+	PackageCount       int        `json:"package_count"`
+	GrossWeight        float64    `json:"gross_weight"`
+	NetWeight          float64    `json:"net_weight"`
+	GeneratedAt        *time.Time `json:"generated_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+func (ErpCustomsInvoice) TableName() string { return "erp_customs_invoices" }
+
+// ErpCustomsInvoiceLine 报关发票行：同一订单行合并
+type ErpCustomsInvoiceLine struct {
+	ID            string    `gorm:"primaryKey;size:100" json:"id"`
+	InvoiceID     string    `gorm:"size:100;not null;index" json:"invoice_id"`
+	OrderItemID   string    `gorm:"size:100" json:"order_item_id"`
+	ProductID     string    `gorm:"size:100" json:"product_id"`
+	HSCode        string    `gorm:"size:20" json:"hs_code"`
+	Description   string    `gorm:"type:text" json:"description"`
+	OriginCountry string    `gorm:"size:50" json:"origin_country"`
+	Quantity      float64   `json:"quantity"`
+	Unit          string    `gorm:"size:20" json:"unit"`
+	UnitPrice     float64   `json:"unit_price"`
+	Amount        float64   `json:"amount"`
+	NetWeight     float64   `json:"net_weight"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (ErpCustomsInvoiceLine) TableName() string { return "erp_customs_invoice_lines" }
+
 // ErpReturn 退货单 (RMA)
 type ErpReturn struct {
 	ID          string    `gorm:"primaryKey;size:100" json:"id"`
@@ -257,6 +466,7 @@ type ErpInventoryTransaction struct {
 	ReferenceType   string    `gorm:"size:30;index" json:"reference_type"`            // po / work_order / sales_order / count / adjustment / scrap
 	ReferenceID     string    `gorm:"size:100;index" json:"reference_id"`
 	JournalEntryID  string    `gorm:"size:100;index" json:"journal_entry_id"`         // 关联 Sprint 2 生成的会计凭证
+	PriceVariance   float64   `gorm:"default:0" json:"price_variance"`                // 标准成本物料采购入库: 实际金额 - 标准金额
 	Reason          string    `gorm:"type:text" json:"reason"`
 	Notes           string    `gorm:"type:text" json:"notes"`
 	OperatorID      string    `gorm:"size:100;index" json:"operator_id"`
@@ -286,6 +496,7 @@ type ErpMaterialInventoryAttrs struct {
 	DefaultWarehouse string     `gorm:"size:100" json:"default_warehouse"`
 	ShelfLifeDays    int        `gorm:"default:0" json:"shelf_life_days"`
 	StandardCost     float64    `gorm:"default:0" json:"standard_cost"`
+	CostMethod       string     `gorm:"size:20;default:moving_average" json:"cost_method"` // moving_average / standard
 	CurrentAvgCost   float64    `gorm:"default:0" json:"current_avg_cost"` // 移动加权平均成本（Sprint 2 计算）
 	LastReceivedAt   *time.Time `json:"last_received_at"`
 	LastIssuedAt     *time.Time `json:"last_issued_at"`
@@ -462,8 +673,16 @@ type ErpWorkOrder struct {
 	Status       string     `gorm:"size:30;default:draft" json:"status"`
 	Notes        string     `gorm:"type:text" json:"notes"`
 	CreatedBy    string     `gorm:"size:100" json:"created_by"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	// 标准成本差异（完工时计算，正数为不利差异）
+	StdMaterialCost    float64   `gorm:"default:0" json:"std_material_cost"`
+	ActualMaterialCost float64   `gorm:"default:0" json:"actual_material_cost"`
+	MaterialVariance   float64   `gorm:"default:0" json:"material_variance"`
+	StdLaborCost       float64   `gorm:"default:0" json:"std_labor_cost"`
+	ActualLaborCost    float64   `gorm:"default:0" json:"actual_labor_cost"`
+	LaborVariance      float64   `gorm:"default:0" json:"labor_variance"`
+	VarianceEntryID    string    `gorm:"size:100" json:"variance_entry_id"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 func (ErpWorkOrder) TableName() string { return "erp_work_orders" }
@@ -488,41 +707,100 @@ func (ErpWOMaterialIssue) TableName() string { return "erp_wo_material_issues" }
 
 // ErpWOReport 工单报工
 type ErpWOReport struct {
-	ID          string     `gorm:"primaryKey;size:100" json:"id"`
-	WorkOrderID string     `gorm:"size:100;not null;index" json:"work_order_id"`
-	Operation   string     `gorm:"size:100" json:"operation"`
-	OperatorID  string     `gorm:"size:100" json:"operator_id"`
-	GoodQty     float64    `json:"good_qty"`
-	DefectQty   float64    `gorm:"default:0" json:"defect_qty"`
-	ScrapQty    float64    `gorm:"default:0" json:"scrap_qty"`
-	StartTime   *time.Time `json:"start_time"`
-	EndTime     *time.Time `json:"end_time"`
-	Notes       string     `gorm:"type:text" json:"notes"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID           string     `gorm:"primaryKey;size:100" json:"id"`
+	WorkOrderID  string     `gorm:"size:100;not null;index" json:"work_order_id"`
+	Operation    string     `gorm:"size:100" json:"operation"`
+	OperatorID   string     `gorm:"size:100" json:"operator_id"`
+	GoodQty      float64    `json:"good_qty"`
+	DefectQty    float64    `gorm:"default:0" json:"defect_qty"`
+	ScrapQty     float64    `gorm:"default:0" json:"scrap_qty"`
+	LaborMinutes float64    `gorm:"default:0" json:"labor_minutes"` // 实际人工工时（分钟），为空按起止时间计算
+	StartTime    *time.Time `json:"start_time"`
+	EndTime      *time.Time `json:"end_time"`
+	Notes        string     `gorm:"type:text" json:"notes"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
 func (ErpWOReport) TableName() string { return "erp_wo_reports" }
 
+// ── Costing ──
+
+// ErpCostRate 工作中心费率：人工与制造费用按小时计，WorkCenter 为空为默认费率
+type ErpCostRate struct {
+	ID           string    `gorm:"primaryKey;size:100" json:"id"`
+	WorkCenter   string    `gorm:"size:64;uniqueIndex" json:"work_center"`
+	LaborRate    float64   `gorm:"default:0" json:"labor_rate"`    // 元/小时
+	OverheadRate float64   `gorm:"default:0" json:"overhead_rate"` // 元/小时
+	Notes        string    `gorm:"type:text" json:"notes"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (ErpCostRate) TableName() string { return "erp_cost_rates" }
+
+// ErpStandardCost 标准成本版本：卷算生成 pending，重估运行后成为 current，
+// 旧版本转为 superseded
+type ErpStandardCost struct {
+	ID           string     `gorm:"primaryKey;size:100" json:"id"`
+	MaterialID   string     `gorm:"size:100;not null;index" json:"material_id"`
+	BomID        string     `gorm:"size:100" json:"bom_id"`
+	RouteID      string     `gorm:"size:100" json:"route_id"`
+	LotSize      float64    `gorm:"default:1" json:"lot_size"`
+	MaterialCost float64    `gorm:"default:0" json:"material_cost"`
+	LaborCost    float64    `gorm:"default:0" json:"labor_cost"`
+	OverheadCost float64    `gorm:"default:0" json:"overhead_cost"`
+	TotalCost    float64    `gorm:"default:0" json:"total_cost"`
+	StdMinutes   float64    `gorm:"default:0" json:"std_minutes"` // 单位产品标准工时
+	Breakdown    string     `gorm:"type:jsonb;default:'[]'" json:"breakdown"`
+	Status       string     `gorm:"size:20;default:pending;index" json:"status"` // pending / current / superseded
+	RolledUpBy   string     `gorm:"size:100" json:"rolled_up_by"`
+	EffectiveAt  *time.Time `json:"effective_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (ErpStandardCost) TableName() string { return "erp_standard_costs" }
+
 // ── Finance ──
 
 // ErpAccount 会计科目
 type ErpAccount struct {
-	ID       string    `gorm:"primaryKey;size:100" json:"id"`
-	Code     string    `gorm:"size:50;uniqueIndex" json:"code"`
-	Name     string    `gorm:"size:200;not null" json:"name"`
-	Type     string    `gorm:"size:30;not null" json:"type"`
-	ParentID string    `gorm:"size:100;index" json:"parent_id"`
-	Level    int       `gorm:"default:1" json:"level"`
-	IsLeaf   bool      `gorm:"default:true" json:"is_leaf"`
-	Currency string    `gorm:"size:10;default:CNY" json:"currency"`
-	Status   string    `gorm:"size:30;default:active" json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            string    `gorm:"primaryKey;size:100" json:"id"`
+	Code          string    `gorm:"size:50;uniqueIndex" json:"code"`
+	Name          string    `gorm:"size:200;not null" json:"name"`
+	Type          string    `gorm:"size:30;not null" json:"type"`
+	ParentID      string    `gorm:"size:100;index" json:"parent_id"`
+	Level         int       `gorm:"default:1" json:"level"`
+	IsLeaf        bool      `gorm:"default:true" json:"is_leaf"`
+	Currency      string    `gorm:"size:10;default:CNY" json:"currency"`
+	Status        string    `gorm:"size:30;default:active" json:"status"`
+	CashFlowClass string    `gorm:"size:20" json:"cash_flow_class"` // 现金流量表分类，空则继承上级科目，一级科目为空按经营活动
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 func (ErpAccount) TableName() string { return "erp_accounts" }
 
+// ErpPostingRule 过账规则：按 (事务类型, 仓库类型, 物料类别) 决定借贷科目。
+// WarehouseType / MaterialCategory 为空表示通配，匹配时越具体的规则优先，
+// 同等具体度按 Priority 从大到小。科目以编码引用，须为启用的末级科目。
+type ErpPostingRule struct {
+	ID               string    `gorm:"primaryKey;size:100" json:"id"`
+	TxType           string    `gorm:"size:50;not null;index" json:"tx_type"`
+	WarehouseType    string    `gorm:"size:30" json:"warehouse_type"`
+	MaterialCategory string    `gorm:"size:50" json:"material_category"` // material_categories.code，含下级类别
+	DebitAccount     string    `gorm:"size:50;not null" json:"debit_account"`
+	CreditAccount    string    `gorm:"size:50;not null" json:"credit_account"`
+	Priority         int       `gorm:"default:0" json:"priority"`
+	Description      string    `gorm:"type:text" json:"description"`
+	Status           string    `gorm:"size:30;default:active" json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (ErpPostingRule) TableName() string { return "erp_posting_rules" }
+
 // ErpJournalEntry 会计凭证
 type ErpJournalEntry struct {
 	ID          string     `gorm:"primaryKey;size:100" json:"id"`
@@ -589,6 +867,8 @@ type ErpSalesInvoice struct {
 	Total          float64    `json:"total"`
 	PaidAmount     float64    `gorm:"default:0" json:"paid_amount"`
 	Status         string     `gorm:"size:30;default:draft" json:"status"`
+	DunningLevel   int        `gorm:"default:0" json:"dunning_level"`
+	LastDunningAt  *time.Time `json:"last_dunning_at"`
 	JournalEntryID string     `gorm:"size:100" json:"journal_entry_id"`
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
@@ -628,6 +908,143 @@ type ErpReceiptAllocation struct {
 
 func (ErpReceiptAllocation) TableName() string { return "erp_receipt_allocations" }
 
+// ── Credit Control & Dunning ──
+
+// ErpDunningLevel 催款级别：逾期天数达到阈值即升级，可触发信用冻结
+type ErpDunningLevel struct {
+	ID          string    `gorm:"primaryKey;size:100" json:"id"`
+	Level       int       `gorm:"uniqueIndex" json:"level"`
+	Name        string    `gorm:"size:100" json:"name"`
+	DaysOverdue int       `json:"days_overdue"`
+	RepeatDays  int       `gorm:"default:7" json:"repeat_days"`
+	HoldCredit  bool      `gorm:"default:false" json:"hold_credit"`
+	Template    string    `gorm:"type:text" json:"template"`
+	Status      string    `gorm:"size:20;default:active" json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (ErpDunningLevel) TableName() string { return "erp_dunning_levels" }
+
+// ErpDunningNotice 催款记录（每次催款一条，按客户汇总逾期发票）
+type ErpDunningNotice struct {
+	ID             string     `gorm:"primaryKey;size:100" json:"id"`
+	Code           string     `gorm:"size:50;uniqueIndex" json:"code"`
+	CustomerID     string     `gorm:"size:100;not null;index" json:"customer_id"`
+	Level          int        `json:"level"`
+	LevelName      string     `gorm:"size:100" json:"level_name"`
+	InvoiceIDs     string     `gorm:"type:jsonb;default:'[]'" json:"invoice_ids"`
+	InvoiceCount   int        `json:"invoice_count"`
+	OverdueAmount  float64    `json:"overdue_amount"`
+	MaxDaysOverdue int        `json:"max_days_overdue"`
+	Manual         bool       `gorm:"default:false" json:"manual"`
+	CreditHold     bool       `gorm:"default:false" json:"credit_hold"`
+	SentAt         *time.Time `json:"sent_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (ErpDunningNotice) TableName() string { return "erp_dunning_notices" }
+
+// ── Accounts Payable ──
+
+// ErpAPInvoice 应付发票（应付明细账未清项）。金额为本位币入账金额，负债在采购入库时已确认
+type ErpAPInvoice struct {
+	ID             string     `gorm:"primaryKey;size:100" json:"id"`
+	Code           string     `gorm:"size:50;uniqueIndex" json:"code"`
+	SupplierID     string     `gorm:"size:100;not null;index" json:"supplier_id"`
+	SettlementID   string     `gorm:"size:100;index" json:"settlement_id"`
+	InvoiceNo      string     `gorm:"size:100" json:"invoice_no"`
+	InvoiceDate    *time.Time `json:"invoice_date"`
+	PaymentTerms   string     `gorm:"size:100" json:"payment_terms"`
+	DueDate        *time.Time `gorm:"index" json:"due_date"`
+	Currency       string     `gorm:"size:10;default:CNY" json:"currency"`
+	ExchangeRate   float64    `gorm:"default:1" json:"exchange_rate"`
+	OriginalAmount float64    `json:"original_amount"`
+	Total          float64    `json:"total"`
+	PaidAmount     float64    `gorm:"default:0" json:"paid_amount"`
+	Status         string     `gorm:"size:30;default:open;index" json:"status"` // open/partially_paid/paid/cancelled
+	Notes          string     `gorm:"type:text" json:"notes"`
+	CreatedBy      string     `gorm:"size:100" json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (ErpAPInvoice) TableName() string { return "erp_ap_invoices" }
+
+// ErpAPPayment 付款单：Amount 为冲减应付的入账金额，CashAmount 为实际付款本位币金额，差额为汇兑损益
+type ErpAPPayment struct {
+	ID             string     `gorm:"primaryKey;size:100" json:"id"`
+	Code           string     `gorm:"size:50;uniqueIndex" json:"code"`
+	SupplierID     string     `gorm:"size:100;not null;index" json:"supplier_id"`
+	PaymentRunID   string     `gorm:"size:100;index" json:"payment_run_id"`
+	Amount         float64    `json:"amount"`
+	CashAmount     float64    `json:"cash_amount"`
+	FXDiff         float64    `gorm:"column:fx_diff;default:0" json:"fx_diff"`
+	Currency       string     `gorm:"size:10;default:CNY" json:"currency"`
+	OriginalAmount float64    `json:"original_amount"`
+	PaymentMethod  string     `gorm:"size:30;default:bank_transfer" json:"payment_method"`
+	BankName       string     `gorm:"size:200" json:"bank_name"`
+	BankAccount    string     `gorm:"size:100" json:"bank_account"`
+	ReferenceNo    string     `gorm:"size:100" json:"reference_no"`
+	PaidDate       *time.Time `json:"paid_date"`
+	Status         string     `gorm:"size:30;default:posted" json:"status"`
+	JournalEntryID string     `gorm:"size:100" json:"journal_entry_id"`
+	Notes          string     `gorm:"type:text" json:"notes"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (ErpAPPayment) TableName() string { return "erp_ap_payments" }
+
+// ErpAPPaymentAllocation 付款核销
+type ErpAPPaymentAllocation struct {
+	ID        string    `gorm:"primaryKey;size:100" json:"id"`
+	PaymentID string    `gorm:"size:100;not null;index" json:"payment_id"`
+	InvoiceID string    `gorm:"size:100;not null;index" json:"invoice_id"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ErpAPPaymentAllocation) TableName() string { return "erp_ap_payment_allocations" }
+
+// ErpPaymentRun 付款批次：按截止日建议到期应付，审批执行后生成付款单并导出银行付款文件
+type ErpPaymentRun struct {
+	ID          string     `gorm:"primaryKey;size:100" json:"id"`
+	Code        string     `gorm:"size:50;uniqueIndex" json:"code"`
+	CutoffDate  *time.Time `json:"cutoff_date"`
+	PaymentDate *time.Time `json:"payment_date"`
+	Currency    string     `gorm:"size:10;default:CNY" json:"currency"`
+	TotalAmount float64    `json:"total_amount"`
+	ItemCount   int        `json:"item_count"`
+	Status      string     `gorm:"size:30;default:proposed" json:"status"` // proposed/paid/cancelled
+	CreatedBy   string     `gorm:"size:100" json:"created_by"`
+	ApprovedBy  string     `gorm:"size:100" json:"approved_by"`
+	ExecutedAt  *time.Time `json:"executed_at"`
+	Notes       string     `gorm:"type:text" json:"notes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (ErpPaymentRun) TableName() string { return "erp_payment_runs" }
+
+// ErpPaymentRunItem 付款批次明细（每张到期发票一行）
+type ErpPaymentRunItem struct {
+	ID         string     `gorm:"primaryKey;size:100" json:"id"`
+	RunID      string     `gorm:"size:100;not null;index" json:"run_id"`
+	InvoiceID  string     `gorm:"size:100;not null;index" json:"invoice_id"`
+	SupplierID string     `gorm:"size:100;not null;index" json:"supplier_id"`
+	DueDate    *time.Time `json:"due_date"`
+	OpenAmount float64    `json:"open_amount"`
+	PayAmount  float64    `json:"pay_amount"`
+	Selected   bool       `gorm:"default:true" json:"selected"`
+	PaymentID  string     `gorm:"size:100" json:"payment_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (ErpPaymentRunItem) TableName() string { return "erp_payment_run_items" }
+
 // ── Quality Management ──
 
 // ErpOQCInspection OQC 出货检验
@@ -0,0 +1,849 @@
+package erp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/bitfantasy/acp-module-sdk"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===========================================================================
+// 出库包装：发货单 → 托盘 → 纸箱 → 序列号/批次
+//
+// 每个包装件创建时分配 GS1 SSCC；纸箱逐个扫描序列号装箱(非序列号物料按
+// 批次数量)，封箱时录入重量尺寸。发货单一旦开始装箱，必须全部封箱且数量
+// 与发货明细一致才能确认发货；发货时装箱序列号标记为已发货。出口订单按
+// 订单行价格和产品报关资料生成报关发票。
+// ===========================================================================
+
+// Package types and statuses.
+const (
+	packageCarton = "carton"
+	packagePallet = "pallet"
+
+	packageOpen   = "open"
+	packageSealed = "sealed"
+	packageVoid   = "void"
+)
+
+// unpackableSerialStatus 序列号档案处于这些状态时不能装箱
+var unpackableSerialStatus = map[string]bool{"shipped": true, "delivered": true, "scrapped": true}
+
+// shipmentPacked is set once every shipment line is packed into sealed packages.
+const shipmentPacked = "packed"
+
+// ── SSCC ──
+
+// ssccCheckDigit GS1 mod-10 check digit over the 17 data digits.
+func ssccCheckDigit(data string) int {
+	sum := 0
+	for i := len(data) - 1; i >= 0; i-- {
+		d := int(data[i] - '0')
+		if (len(data)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// allocateSSCC takes the next serial reference from erp_sscc_configs atomically.
+func allocateSSCC(db *gorm.DB) (string, error) {
+	var cfg ErpSSCCConfig
+	if err := db.Order("created_at").First(&cfg).Error; err != nil {
+		return "", fmt.Errorf("SSCC is not configured, set the GS1 company prefix in sscc_configs first")
+	}
+	prefix := strings.TrimSpace(cfg.CompanyPrefix)
+	if len(prefix) < 7 || len(prefix) > 10 || strings.Trim(prefix, "0123456789") != "" {
+		return "", fmt.Errorf("invalid GS1 company prefix %q, expected 7-10 digits", prefix)
+	}
+	if cfg.ExtensionDigit < 0 || cfg.ExtensionDigit > 9 {
+		return "", fmt.Errorf("invalid SSCC extension digit %d", cfg.ExtensionDigit)
+	}
+
+	var serial int64
+	if err := db.Raw("UPDATE erp_sscc_configs SET next_serial = next_serial + 1, updated_at = ? WHERE id = ? RETURNING next_serial - 1",
+		time.Now(), cfg.ID).Scan(&serial).Error; err != nil {
+		return "", fmt.Errorf("allocate SSCC failed: %w", err)
+	}
+	refLen := 16 - len(prefix)
+	if serial <= 0 || serial >= int64(math.Pow10(refLen)) {
+		return "", fmt.Errorf("SSCC serial range exhausted for company prefix %s", prefix)
+	}
+	data := fmt.Sprintf("%d%s%0*d", cfg.ExtensionDigit, prefix, refLen, serial)
+	return data + strconv.Itoa(ssccCheckDigit(data)), nil
+}
+
+// ── Shipment helpers ──
+
+// shipmentItemSerials parses erp_shipment_items.serial_numbers. New rows store a
+// JSON array; older rows may hold "[a b]" or comma separated lists.
+func shipmentItemSerials(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return nil
+	}
+	var list []string
+	if json.Unmarshal([]byte(raw), &list) == nil {
+		return list
+	}
+	return strings.FieldsFunc(strings.Trim(raw, "[]"), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	})
+}
+
+func loadPackableShipment(db *gorm.DB, shipmentID string) (*ErpShipment, error) {
+	var shipment ErpShipment
+	if err := db.First(&shipment, "id = ?", shipmentID).Error; err != nil {
+		return nil, fmt.Errorf("shipment not found")
+	}
+	if shipment.Status != "pending" && shipment.Status != shipmentPacked {
+		return nil, fmt.Errorf("shipment %s is %s, packing is only allowed before shipping", shipment.Code, shipment.Status)
+	}
+	return &shipment, nil
+}
+
+func loadOpenCarton(db *gorm.DB, packageID string) (*ErpPackage, error) {
+	var pkg ErpPackage
+	if err := db.First(&pkg, "id = ?", packageID).Error; err != nil {
+		return nil, fmt.Errorf("package not found")
+	}
+	if pkg.Type != packageCarton {
+		return nil, fmt.Errorf("package %s is a pallet, items are packed into cartons", pkg.Code)
+	}
+	if pkg.Status != packageOpen {
+		return nil, fmt.Errorf("package %s is %s", pkg.Code, pkg.Status)
+	}
+	return &pkg, nil
+}
+
+// packedQtyByItem sums packed quantities per shipment item over live packages.
+func packedQtyByItem(db *gorm.DB, shipmentID string) map[string]float64 {
+	var rows []struct {
+		ShipmentItemID string
+		Qty            float64
+	}
+	db.Table("erp_package_contents c").
+		Select("c.shipment_item_id, SUM(c.quantity) AS qty").
+		Joins("JOIN erp_packages p ON p.id = c.package_id").
+		Where("c.shipment_id = ? AND p.status <> ?", shipmentID, packageVoid).
+		Group("c.shipment_item_id").Scan(&rows)
+	out := make(map[string]float64, len(rows))
+	for _, r := range rows {
+		out[r.ShipmentItemID] = r.Qty
+	}
+	return out
+}
+
+// releaseVoidContents flags contents of void packages so their serials can be packed again.
+// The partial unique index on erp_package_contents.serial_number skips released rows.
+func releaseVoidContents(db *gorm.DB) error {
+	err := db.Model(&ErpPackageContent{}).
+		Where("released = ? AND package_id IN (?)", false, db.Model(&ErpPackage{}).Select("id").Where("status = ?", packageVoid)).
+		Update("released", true).Error
+	if err != nil {
+		return fmt.Errorf("release void package contents failed: %w", err)
+	}
+	return nil
+}
+
+// ── Commands ──
+
+// cmdCreatePackage — 新建纸箱/托盘并分配 SSCC；纸箱可指定所在托盘
+func cmdCreatePackage(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	shipment, err := loadPackableShipment(db, getStr(input, "shipment_id"))
+	if err != nil {
+		return nil, err
+	}
+	pkgType := getStr(input, "type")
+	if pkgType == "" {
+		pkgType = packageCarton
+	}
+	if pkgType != packageCarton && pkgType != packagePallet {
+		return nil, fmt.Errorf("invalid package type: %s", pkgType)
+	}
+	parentID := getStr(input, "parent_id")
+	if parentID != "" {
+		if pkgType != packageCarton {
+			return nil, fmt.Errorf("only cartons can be placed on a pallet")
+		}
+		var parent ErpPackage
+		if err := db.First(&parent, "id = ?", parentID).Error; err != nil {
+			return nil, fmt.Errorf("pallet not found")
+		}
+		if parent.ShipmentID != shipment.ID || parent.Type != packagePallet || parent.Status != packageOpen {
+			return nil, fmt.Errorf("package %s is not an open pallet of shipment %s", parent.Code, shipment.Code)
+		}
+	}
+
+	sscc, err := allocateSSCC(db)
+	if err != nil {
+		return nil, err
+	}
+	var seq int64
+	db.Model(&ErpPackage{}).Where("shipment_id = ? AND type = ? AND status <> ?", shipment.ID, pkgType, packageVoid).
+		Select("COALESCE(MAX(seq), 0)").Row().Scan(&seq)
+
+	var pkg ErpPackage
+	code, err := autoCodeWithRetry(db, "erp_packages", "PKG", func(c string) error {
+		pkg = ErpPackage{
+			ID:         uuid.New().String(),
+			Code:       c,
+			ShipmentID: shipment.ID,
+			ParentID:   parentID,
+			Type:       pkgType,
+			Seq:        int(seq) + 1,
+			SSCC:       sscc,
+			Length:     getFloat(input, "length"),
+			Width:      getFloat(input, "width"),
+			Height:     getFloat(input, "height"),
+			Status:     packageOpen,
+			Notes:      getStr(input, "notes"),
+		}
+		return db.Create(&pkg).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create package failed: %w", err)
+	}
+	if shipment.Status == shipmentPacked {
+		db.Model(shipment).Update("status", "pending")
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.package.created",
+		fmt.Sprintf("包装件创建: %s (%s) SSCC %s", code, pkgType, sscc),
+		map[string]any{"package_id": pkg.ID, "code": code, "shipment_id": shipment.ID, "sscc": sscc})
+
+	return map[string]any{"package_id": pkg.ID, "code": code, "type": pkgType, "sscc": sscc, "seq": pkg.Seq}, nil
+}
+
+// cmdPackItems — 扫描序列号或按批次数量装入纸箱，校验属于发货单且不超发货数量
+func cmdPackItems(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	pkg, err := loadOpenCarton(db, getStr(input, "package_id"))
+	if err != nil {
+		return nil, err
+	}
+	shipment, err := loadPackableShipment(db, pkg.ShipmentID)
+	if err != nil {
+		return nil, err
+	}
+	serials := getStrSlice(input, "serial_numbers")
+	lines := getMapSlice(input, "items")
+	if len(serials) == 0 && len(lines) == 0 {
+		return nil, fmt.Errorf("serial_numbers or items is required")
+	}
+
+	var contents []ErpPackageContent
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 锁住发货单，同一发货单的装箱串行执行，超装与重复扫描校验才可靠
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ErpShipment{}, "id = ?", shipment.ID).Error; err != nil {
+			return fmt.Errorf("shipment not found")
+		}
+		if pkg, err = loadOpenCarton(tx, pkg.ID); err != nil {
+			return err
+		}
+		// 作废包装件里的序列号释放出来，可重新装箱（唯一索引只约束未释放的明细）
+		if err := releaseVoidContents(tx); err != nil {
+			return err
+		}
+
+		var items []ErpShipmentItem
+		tx.Where("shipment_id = ?", shipment.ID).Find(&items)
+		itemByID := make(map[string]*ErpShipmentItem, len(items))
+		listed := make(map[string]*ErpShipmentItem) // 发货明细已指定的序列号
+		for i := range items {
+			itemByID[items[i].ID] = &items[i]
+			for _, sn := range shipmentItemSerials(items[i].SerialNumbers) {
+				listed[sn] = &items[i]
+			}
+		}
+		packed := packedQtyByItem(tx, shipment.ID)
+
+		now := time.Now()
+		add := func(item *ErpShipmentItem, lot, serial string, qty float64) error {
+			if packed[item.ID]+qty > item.Quantity+1e-9 {
+				return fmt.Errorf("shipment line %s would be over-packed: %.4g packed + %.4g > %.4g", item.ProductID, packed[item.ID], qty, item.Quantity)
+			}
+			packed[item.ID] += qty
+			contents = append(contents, ErpPackageContent{
+				ID: uuid.New().String(), PackageID: pkg.ID, ShipmentID: shipment.ID, ShipmentItemID: item.ID,
+				ProductID: item.ProductID, LotNumber: lot, SerialNumber: serial, Quantity: qty, CreatedAt: now,
+			})
+			return nil
+		}
+
+		seen := make(map[string]bool, len(serials))
+		for _, sn := range serials {
+			sn = strings.TrimSpace(sn)
+			if sn == "" || seen[sn] {
+				continue
+			}
+			seen[sn] = true
+			var existing []string
+			tx.Table("erp_package_contents c").Select("p.code").
+				Joins("JOIN erp_packages p ON p.id = c.package_id").
+				Where("c.serial_number = ? AND p.status <> ?", sn, packageVoid).Scan(&existing)
+			if len(existing) > 0 {
+				return fmt.Errorf("serial %s is already packed in %s", sn, existing[0])
+			}
+
+			// 发货明细指定的序列号若有档案也要校验状态，已报废或已发出的不能再装箱
+			var rec ErpSerialNumber
+			found := tx.First(&rec, "serial_number = ?", sn).Error == nil
+			if found && unpackableSerialStatus[rec.Status] {
+				return fmt.Errorf("serial %s is %s", sn, rec.Status)
+			}
+
+			item, lot := listed[sn], ""
+			if item == nil {
+				if len(listed) > 0 {
+					return fmt.Errorf("serial %s is not on shipment %s", sn, shipment.Code)
+				}
+				if !found {
+					return fmt.Errorf("serial %s not found", sn)
+				}
+				if rec.Status != "in_stock" {
+					return fmt.Errorf("serial %s is %s", sn, rec.Status)
+				}
+				for i := range items {
+					if items[i].ProductID != "" && (items[i].ProductID == rec.ProductID || items[i].ProductID == rec.MaterialID) {
+						item = &items[i]
+						break
+					}
+				}
+				if item == nil {
+					return fmt.Errorf("serial %s does not match any product on shipment %s", sn, shipment.Code)
+				}
+				lot = rec.LotNumber
+			}
+			if lot == "" {
+				lot = item.LotNumber
+			}
+			if err := add(item, lot, sn, 1); err != nil {
+				return err
+			}
+		}
+
+		for _, line := range lines {
+			qty := getFloat(line, "quantity")
+			if qty <= 0 {
+				return fmt.Errorf("quantity must be positive")
+			}
+			item := itemByID[getStr(line, "shipment_item_id")]
+			if item == nil {
+				productID := getStr(line, "product_id")
+				for i := range items {
+					if productID != "" && items[i].ProductID == productID {
+						item = &items[i]
+						break
+					}
+				}
+			}
+			if item == nil {
+				return fmt.Errorf("shipment_item_id or product_id must match a line of shipment %s", shipment.Code)
+			}
+			lot := getStr(line, "lot_number")
+			if lot == "" {
+				lot = item.LotNumber
+			}
+			if err := add(item, lot, "", qty); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.CreateInBatches(&contents, 200).Error; err != nil {
+			return fmt.Errorf("pack items failed: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var total float64
+	db.Model(&ErpPackageContent{}).Where("package_id = ?", pkg.ID).Select("COALESCE(SUM(quantity), 0)").Row().Scan(&total)
+	emitEvent(adapter, runID, stepID, "erp.package.packed",
+		fmt.Sprintf("装箱: %s +%d 行", pkg.Code, len(contents)),
+		map[string]any{"package_id": pkg.ID, "shipment_id": shipment.ID, "lines": len(contents)})
+
+	return map[string]any{"package_id": pkg.ID, "code": pkg.Code, "packed_lines": len(contents), "package_quantity": total}, nil
+}
+
+// cmdUnpackItems — 从未封箱的纸箱撤回误扫的序列号或明细行
+func cmdUnpackItems(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	pkg, err := loadOpenCarton(db, getStr(input, "package_id"))
+	if err != nil {
+		return nil, err
+	}
+	serials := getStrSlice(input, "serial_numbers")
+	contentIDs := getStrSlice(input, "content_ids")
+	if len(serials) == 0 && len(contentIDs) == 0 {
+		return nil, fmt.Errorf("serial_numbers or content_ids is required")
+	}
+	q := db.Where("package_id = ?", pkg.ID)
+	switch {
+	case len(serials) > 0 && len(contentIDs) > 0:
+		q = q.Where("serial_number IN ? OR id IN ?", serials, contentIDs)
+	case len(serials) > 0:
+		q = q.Where("serial_number IN ?", serials)
+	default:
+		q = q.Where("id IN ?", contentIDs)
+	}
+	res := q.Delete(&ErpPackageContent{})
+	if res.Error != nil {
+		return nil, fmt.Errorf("unpack failed: %w", res.Error)
+	}
+	emitEvent(adapter, runID, stepID, "erp.package.unpacked",
+		fmt.Sprintf("撤回装箱: %s -%d 行", pkg.Code, res.RowsAffected),
+		map[string]any{"package_id": pkg.ID, "removed": res.RowsAffected})
+	return map[string]any{"package_id": pkg.ID, "code": pkg.Code, "removed": res.RowsAffected}, nil
+}
+
+// cmdSealPackage — 封箱并录入重量尺寸。托盘需其上纸箱全部封箱，毛重默认为纸箱毛重合计加托盘自重
+func cmdSealPackage(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	var pkg ErpPackage
+	if err := db.First(&pkg, "id = ?", getStr(input, "package_id")).Error; err != nil {
+		return nil, fmt.Errorf("package not found")
+	}
+	if pkg.Status != packageOpen {
+		return nil, fmt.Errorf("package %s is %s", pkg.Code, pkg.Status)
+	}
+	shipment, err := loadPackableShipment(db, pkg.ShipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]any{}
+	for _, dim := range []string{"length", "width", "height"} {
+		if v := getFloat(input, dim); v > 0 {
+			updates[dim] = v
+		}
+	}
+	gross, net := getFloat(input, "gross_weight"), getFloat(input, "net_weight")
+
+	if pkg.Type == packagePallet {
+		var cartons []ErpPackage
+		db.Where("parent_id = ? AND status <> ?", pkg.ID, packageVoid).Find(&cartons)
+		if len(cartons) == 0 {
+			return nil, fmt.Errorf("pallet %s has no cartons", pkg.Code)
+		}
+		var sumGross, sumNet float64
+		for _, c := range cartons {
+			if c.Status != packageSealed {
+				return nil, fmt.Errorf("carton %s on pallet %s is not sealed", c.Code, pkg.Code)
+			}
+			sumGross += c.GrossWeight
+			sumNet += c.NetWeight
+		}
+		if gross <= 0 {
+			gross = sumGross + getFloat(input, "tare_weight")
+		}
+		if net <= 0 {
+			net = sumNet
+		}
+	} else {
+		var contents []ErpPackageContent
+		db.Where("package_id = ?", pkg.ID).Find(&contents)
+		if len(contents) == 0 {
+			return nil, fmt.Errorf("carton %s is empty", pkg.Code)
+		}
+		if net <= 0 {
+			net = contentsNetWeight(db, contents)
+		}
+	}
+	if gross <= 0 {
+		return nil, fmt.Errorf("gross_weight is required")
+	}
+
+	now := time.Now()
+	updates["gross_weight"] = gross
+	updates["net_weight"] = roundAmount(net)
+	updates["status"] = packageSealed
+	updates["sealed_at"] = &now
+	if err := db.Model(&pkg).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("seal package failed: %w", err)
+	}
+
+	status := shipment.Status
+	if pl := buildPackingList(db, shipment); pl.FullyPacked {
+		status = shipmentPacked
+		db.Model(shipment).Update("status", status)
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.package.sealed",
+		fmt.Sprintf("封箱: %s 毛重 %.2fkg", pkg.Code, gross),
+		map[string]any{"package_id": pkg.ID, "shipment_id": shipment.ID, "gross_weight": gross})
+
+	return map[string]any{"package_id": pkg.ID, "code": pkg.Code, "sscc": pkg.SSCC, "gross_weight": gross,
+		"net_weight": roundAmount(net), "shipment_status": status}, nil
+}
+
+// contentsNetWeight estimates net weight from customs unit weights.
+func contentsNetWeight(db *gorm.DB, contents []ErpPackageContent) float64 {
+	ids := make([]string, 0, len(contents))
+	for _, c := range contents {
+		ids = append(ids, c.ProductID)
+	}
+	var products []ErpCustomsProduct
+	db.Where("product_id IN ?", ids).Find(&products)
+	unit := make(map[string]float64, len(products))
+	for _, p := range products {
+		unit[p.ProductID] = p.UnitNetWeight
+	}
+	var net float64
+	for _, c := range contents {
+		net += unit[c.ProductID] * c.Quantity
+	}
+	return net
+}
+
+// ── Packing list ──
+
+type packingListPackage struct {
+	ErpPackage
+	Contents []ErpPackageContent   `json:"contents,omitempty"`
+	Children []*packingListPackage `json:"children,omitempty"`
+}
+
+type packingListLine struct {
+	ShipmentItemID string  `json:"shipment_item_id"`
+	ProductID      string  `json:"product_id"`
+	ProductCode    string  `json:"product_code"`
+	ProductName    string  `json:"product_name"`
+	LotNumber      string  `json:"lot_number"`
+	Quantity       float64 `json:"quantity"`
+	PackedQty      float64 `json:"packed_qty"`
+}
+
+type packingList struct {
+	Shipment        ErpShipment           `json:"shipment"`
+	OrderCode       string                `json:"order_code"`
+	CustomerName    string                `json:"customer_name"`
+	ShippingAddress string                `json:"shipping_address"`
+	Packages        []*packingListPackage `json:"packages"` // 托盘与散箱，纸箱挂在所在托盘下
+	Lines           []packingListLine     `json:"lines"`
+	PalletCount     int                   `json:"pallet_count"`
+	CartonCount     int                   `json:"carton_count"`
+	GrossWeight     float64               `json:"gross_weight"`
+	NetWeight       float64               `json:"net_weight"`
+	Volume          float64               `json:"volume"`       // m³，按外层包装尺寸
+	FullyPacked     bool                  `json:"fully_packed"` // 明细已全部装入已封箱包装
+}
+
+type materialRef struct {
+	ID   string
+	Code string
+	Name string
+}
+
+func loadMaterialRefs(db *gorm.DB, ids []string) map[string]materialRef {
+	out := make(map[string]materialRef)
+	if len(ids) == 0 {
+		return out
+	}
+	var rows []materialRef
+	db.Table("plm_materials").Select("id, code, name").Where("id IN ?", ids).Scan(&rows)
+	for _, r := range rows {
+		out[r.ID] = r
+	}
+	return out
+}
+
+// buildPackingList assembles the pallet → carton → contents tree of a shipment.
+func buildPackingList(db *gorm.DB, shipment *ErpShipment) *packingList {
+	pl := &packingList{Shipment: *shipment, ShippingAddress: shipment.ShippingAddress, Packages: []*packingListPackage{}}
+	var order ErpSalesOrder
+	if db.First(&order, "id = ?", shipment.OrderID).Error == nil {
+		pl.OrderCode = order.Code
+		var cust ErpCustomer
+		if db.First(&cust, "id = ?", order.CustomerID).Error == nil {
+			pl.CustomerName = cust.Name
+		}
+	}
+
+	var pkgs []ErpPackage
+	db.Where("shipment_id = ? AND status <> ?", shipment.ID, packageVoid).Order("type DESC, seq").Find(&pkgs)
+	var contents []ErpPackageContent
+	db.Where("shipment_id = ?", shipment.ID).Order("created_at").Find(&contents)
+
+	nodes := make(map[string]*packingListPackage, len(pkgs))
+	for _, p := range pkgs {
+		nodes[p.ID] = &packingListPackage{ErpPackage: p}
+	}
+	sealed := make(map[string]bool)
+	for _, c := range contents {
+		if n := nodes[c.PackageID]; n != nil {
+			n.Contents = append(n.Contents, c)
+		}
+	}
+	for _, p := range pkgs {
+		n := nodes[p.ID]
+		// 按有效包装件重新编号，作废后标签仍是连续的 "n OF 总数"
+		if p.Type == packagePallet {
+			pl.PalletCount++
+			n.Seq = pl.PalletCount
+		} else {
+			pl.CartonCount++
+			n.Seq = pl.CartonCount
+		}
+		sealed[p.ID] = p.Status == packageSealed
+		if parent := nodes[p.ParentID]; parent != nil {
+			parent.Children = append(parent.Children, n)
+			continue
+		}
+		pl.Packages = append(pl.Packages, n)
+		pl.GrossWeight += p.GrossWeight
+		pl.NetWeight += p.NetWeight
+		pl.Volume += p.Length * p.Width * p.Height / 1e6
+	}
+	pl.GrossWeight = roundAmount(pl.GrossWeight)
+	pl.NetWeight = roundAmount(pl.NetWeight)
+	pl.Volume = math.Round(pl.Volume*1000) / 1000
+
+	var items []ErpShipmentItem
+	db.Where("shipment_id = ?", shipment.ID).Order("created_at").Find(&items)
+	ids := make([]string, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, it.ProductID)
+	}
+	refs := loadMaterialRefs(db, ids)
+	packedSealed := make(map[string]float64)
+	for _, c := range contents {
+		if sealed[c.PackageID] {
+			packedSealed[c.ShipmentItemID] += c.Quantity
+		}
+	}
+	packed := packedQtyByItem(db, shipment.ID)
+	pl.FullyPacked = len(items) > 0 && len(pkgs) > 0
+	for _, it := range items {
+		ref := refs[it.ProductID]
+		pl.Lines = append(pl.Lines, packingListLine{
+			ShipmentItemID: it.ID, ProductID: it.ProductID, ProductCode: ref.Code, ProductName: ref.Name,
+			LotNumber: it.LotNumber, Quantity: it.Quantity, PackedQty: packed[it.ID],
+		})
+		if math.Abs(packedSealed[it.ID]-it.Quantity) > 1e-9 {
+			pl.FullyPacked = false
+		}
+	}
+	for _, p := range pkgs {
+		if p.Status != packageSealed {
+			pl.FullyPacked = false
+		}
+	}
+	return pl
+}
+
+// packedSerials lists serials packed into live packages of a shipment.
+func packedSerials(db *gorm.DB, shipmentID string) []string {
+	var serials []string
+	db.Table("erp_package_contents c").Select("c.serial_number").
+		Joins("JOIN erp_packages p ON p.id = c.package_id").
+		Where("c.shipment_id = ? AND c.serial_number <> '' AND p.status <> ?", shipmentID, packageVoid).
+		Scan(&serials)
+	return serials
+}
+
+// serialShipmentRef 序列号所在包装与发货单
+type serialShipmentRef struct {
+	SerialNumber string     `json:"serial_number"`
+	ProductID    string     `json:"product_id"`
+	LotNumber    string     `json:"lot_number"`
+	PackageID    string     `json:"package_id"`
+	PackageCode  string     `json:"package_code"`
+	CartonSSCC   string     `json:"carton_sscc"`
+	PalletCode   string     `json:"pallet_code"`
+	PalletSSCC   string     `json:"pallet_sscc"`
+	ShipmentID   string     `json:"shipment_id"`
+	ShipmentCode string     `json:"shipment_code"`
+	Status       string     `json:"status"`
+	Carrier      string     `json:"carrier"`
+	TrackingNo   string     `json:"tracking_no"`
+	ShippedAt    *time.Time `json:"shipped_at"`
+	OrderID      string     `json:"order_id"`
+	OrderCode    string     `json:"order_code"`
+	CustomerID   string     `json:"customer_id"`
+	CustomerName string     `json:"customer_name"`
+}
+
+// findShipmentsBySerial 按序列号查装箱与发货记录，最近的在前
+func findShipmentsBySerial(db *gorm.DB, serial string) []serialShipmentRef {
+	var rows []serialShipmentRef
+	db.Raw(`
+		SELECT c.serial_number, c.product_id, c.lot_number,
+			p.id AS package_id, p.code AS package_code, p.sscc AS carton_sscc,
+			COALESCE(pp.code, '') AS pallet_code, COALESCE(pp.sscc, '') AS pallet_sscc,
+			s.id AS shipment_id, s.code AS shipment_code, s.status, s.carrier, s.tracking_no, s.shipped_at,
+			o.id AS order_id, o.code AS order_code, o.customer_id, COALESCE(cu.name, '') AS customer_name
+		FROM erp_package_contents c
+		JOIN erp_packages p ON p.id = c.package_id
+		LEFT JOIN erp_packages pp ON pp.id = p.parent_id
+		JOIN erp_shipments s ON s.id = c.shipment_id
+		LEFT JOIN erp_sales_orders o ON o.id = s.order_id
+		LEFT JOIN erp_customers cu ON cu.id = o.customer_id
+		WHERE c.serial_number = ? AND p.status <> ?
+		ORDER BY c.created_at DESC
+	`, serial, packageVoid).Scan(&rows)
+	return rows
+}
+
+// ── Customs invoice ──
+
+// cmdGenerateCustomsInvoice — 出口订单按发货明细与订单行折后单价生成报关发票，重复生成覆盖原发票行
+func cmdGenerateCustomsInvoice(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	var shipment ErpShipment
+	if err := db.First(&shipment, "id = ?", getStr(input, "shipment_id")).Error; err != nil {
+		return nil, fmt.Errorf("shipment not found")
+	}
+	var order ErpSalesOrder
+	if err := db.First(&order, "id = ?", shipment.OrderID).Error; err != nil {
+		return nil, fmt.Errorf("order not found")
+	}
+	if !order.IsExport {
+		return nil, fmt.Errorf("order %s is not an export order", order.Code)
+	}
+
+	var items []ErpShipmentItem
+	db.Where("shipment_id = ?", shipment.ID).Order("created_at").Find(&items)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("shipment %s has no items", shipment.Code)
+	}
+	orderItemIDs := make([]string, 0, len(items))
+	productIDs := make([]string, 0, len(items))
+	for _, it := range items {
+		orderItemIDs = append(orderItemIDs, it.OrderItemID)
+		productIDs = append(productIDs, it.ProductID)
+	}
+	var soItems []ErpSalesOrderItem
+	db.Where("id IN ?", orderItemIDs).Find(&soItems)
+	soItemByID := make(map[string]ErpSalesOrderItem, len(soItems))
+	for _, it := range soItems {
+		soItemByID[it.ID] = it
+	}
+	var products []ErpCustomsProduct
+	db.Where("product_id IN ?", productIDs).Find(&products)
+	customsByProduct := make(map[string]ErpCustomsProduct, len(products))
+	for _, p := range products {
+		customsByProduct[p.ProductID] = p
+	}
+	refs := loadMaterialRefs(db, productIDs)
+
+	// 同一订单行的多条发货明细合并为一行
+	var missing []string
+	lineByKey := make(map[string]*ErpCustomsInvoiceLine)
+	var keys []string
+	for _, it := range items {
+		cp, ok := customsByProduct[it.ProductID]
+		if !ok || cp.HSCode == "" {
+			name := refs[it.ProductID].Code
+			if name == "" {
+				name = it.ProductID
+			}
+			missing = append(missing, name)
+			continue
+		}
+		so := soItemByID[it.OrderItemID]
+		key := it.OrderItemID + "|" + it.ProductID
+		line := lineByKey[key]
+		if line == nil {
+			desc := cp.DeclaredNameEn
+			if desc == "" {
+				desc = cp.DeclaredName
+			}
+			if desc == "" {
+				desc = so.Description
+			}
+			if desc == "" {
+				desc = refs[it.ProductID].Name
+			}
+			line = &ErpCustomsInvoiceLine{
+				ID: uuid.New().String(), OrderItemID: it.OrderItemID, ProductID: it.ProductID,
+				HSCode: cp.HSCode, Description: desc, OriginCountry: cp.OriginCountry, Unit: cp.Unit,
+				UnitPrice: roundAmount(so.UnitPrice * (1 - so.DiscountPct/100)),
+			}
+			lineByKey[key] = line
+			keys = append(keys, key)
+		}
+		line.Quantity += it.Quantity
+		line.NetWeight += cp.UnitNetWeight * it.Quantity
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("customs data (HS code) missing for products: %s", strings.Join(missing, ", "))
+	}
+
+	pl := buildPackingList(db, &shipment)
+	var cust ErpCustomer
+	db.First(&cust, "id = ?", order.CustomerID)
+	now := time.Now()
+
+	var inv ErpCustomsInvoice
+	db.Where("shipment_id = ?", shipment.ID).Limit(1).Find(&inv)
+	inv.ShipmentID = shipment.ID
+	inv.OrderID = order.ID
+	inv.CustomerID = order.CustomerID
+	inv.ConsigneeName = cust.Name
+	inv.ConsigneeAddress = shipment.ShippingAddress
+	inv.DestinationCountry = order.DestinationCountry
+	inv.Incoterm = order.Incoterm
+	inv.Currency = order.Currency
+	inv.PackageCount = len(pl.Packages)
+	inv.GrossWeight = pl.GrossWeight
+	inv.GeneratedAt = &now
+	inv.TotalAmount, inv.NetWeight = 0, 0
+	lines := make([]ErpCustomsInvoiceLine, 0, len(keys))
+	for _, k := range keys {
+		line := lineByKey[k]
+		line.Amount = roundAmount(line.UnitPrice * line.Quantity)
+		line.NetWeight = roundAmount(line.NetWeight)
+		line.CreatedAt = now
+		inv.TotalAmount += line.Amount
+		inv.NetWeight += line.NetWeight
+		lines = append(lines, *line)
+	}
+	inv.TotalAmount = roundAmount(inv.TotalAmount)
+	inv.NetWeight = roundAmount(inv.NetWeight)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if inv.ID == "" {
+			code, err := autoCodeWithRetry(tx, "erp_customs_invoices", "CI", func(c string) error {
+				inv.ID = uuid.New().String()
+				inv.Code = c
+				return tx.Create(&inv).Error
+			})
+			if err != nil {
+				return err
+			}
+			inv.Code = code
+		} else {
+			if err := tx.Save(&inv).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("invoice_id = ?", inv.ID).Delete(&ErpCustomsInvoiceLine{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range lines {
+			lines[i].InvoiceID = inv.ID
+		}
+		return tx.Create(&lines).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("generate customs invoice failed: %w", err)
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.customs_invoice.generated",
+		fmt.Sprintf("报关发票生成: %s %s %.2f", inv.Code, inv.Currency, inv.TotalAmount),
+		map[string]any{"invoice_id": inv.ID, "code": inv.Code, "shipment_id": shipment.ID})
+
+	return map[string]any{
+		"invoice_id": inv.ID, "code": inv.Code, "currency": inv.Currency, "total_amount": inv.TotalAmount,
+		"lines": len(lines), "package_count": inv.PackageCount, "gross_weight": inv.GrossWeight, "net_weight": inv.NetWeight,
+	}, nil
+}
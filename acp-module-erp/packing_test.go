@@ -0,0 +1,116 @@
+package erp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TestSSCCCheckDigit GS1 mod-10 校验位，权重从右起 3/1 交替
+func TestSSCCCheckDigit(t *testing.T) {
+	cases := []struct {
+		data string
+		want int
+	}{
+		{"00614141123456789", 0}, // GS1 通用规范示例 SSCC
+		{"10614141123456789", 7},
+		{"00614141000000001", 2},
+		{"00000000000000000", 0},
+		{"400638133393", 1}, // GTIN-13 同样适用
+		{"03600029145", 2},  // GTIN-12
+	}
+	for _, tc := range cases {
+		t.Run(tc.data, func(t *testing.T) {
+			if got := ssccCheckDigit(tc.data); got != tc.want {
+				t.Fatalf("ssccCheckDigit(%s) = %d, want %d", tc.data, got, tc.want)
+			}
+		})
+	}
+}
+
+// seedPackingShipment 待发货单一行两台序列号产品，SSCC 厂商代码 0614141
+func seedPackingShipment(t *testing.T, db *gorm.DB) *ErpShipment {
+	t.Helper()
+	shipment := &ErpShipment{ID: uuid.New().String(), Code: "SHP-PACK-1", OrderID: uuid.New().String(), Status: "pending"}
+	for _, seed := range []any{
+		&ErpSSCCConfig{ID: uuid.New().String(), CompanyPrefix: "0614141", NextSerial: 1},
+		shipment,
+		&ErpShipmentItem{ID: uuid.New().String(), ShipmentID: shipment.ID, ProductID: "prod-pack",
+			Quantity: 2, SerialNumbers: `["SN-PACK-1","SN-PACK-2"]`},
+	} {
+		if err := db.Create(seed).Error; err != nil {
+			t.Fatalf("seed %T failed: %v", seed, err)
+		}
+	}
+	return shipment
+}
+
+func createCartonForTest(t *testing.T, db *gorm.DB, shipmentID string) map[string]any {
+	t.Helper()
+	out, err := cmdCreatePackage(db, nil, "", "", map[string]any{"shipment_id": shipmentID})
+	if err != nil {
+		t.Fatalf("create package failed: %v", err)
+	}
+	return out.(map[string]any)
+}
+
+// TestPackingFlow 建箱分配 SSCC → 扫描装箱（重复、报废序列号拒绝）→ 作废后重装 → 封箱完成装箱
+func TestPackingFlow(t *testing.T) {
+	db := setupTestDB(t)
+	shipment := seedPackingShipment(t, db)
+
+	first := createCartonForTest(t, db, shipment.ID)
+	if first["sscc"] != "006141410000000012" || first["seq"] != 1 {
+		t.Fatalf("expected SSCC 006141410000000012 seq 1, got %v seq %v", first["sscc"], first["seq"])
+	}
+	cartonID := first["package_id"].(string)
+
+	if _, err := cmdPackItems(db, nil, "", "", map[string]any{
+		"package_id": cartonID, "serial_numbers": []any{"SN-PACK-1"},
+	}); err != nil {
+		t.Fatalf("pack failed: %v", err)
+	}
+	if _, err := cmdPackItems(db, nil, "", "", map[string]any{
+		"package_id": cartonID, "serial_numbers": []any{"SN-PACK-1"},
+	}); err == nil || !strings.Contains(err.Error(), "already packed") {
+		t.Fatalf("expected duplicate serial to be rejected, got %v", err)
+	}
+
+	scrapped := &ErpSerialNumber{ID: uuid.New().String(), SerialNumber: "SN-PACK-2", ProductID: "prod-pack", Status: "scrapped"}
+	if err := db.Create(scrapped).Error; err != nil {
+		t.Fatalf("seed serial failed: %v", err)
+	}
+	if _, err := cmdPackItems(db, nil, "", "", map[string]any{
+		"package_id": cartonID, "serial_numbers": []any{"SN-PACK-2"},
+	}); err == nil || !strings.Contains(err.Error(), "scrapped") {
+		t.Fatalf("expected scrapped serial to be rejected, got %v", err)
+	}
+
+	// 作废纸箱后序列号释放，新纸箱按有效包装件重新编号
+	if err := db.Model(&ErpPackage{}).Where("id = ?", cartonID).Update("status", packageVoid).Error; err != nil {
+		t.Fatalf("void package failed: %v", err)
+	}
+	second := createCartonForTest(t, db, shipment.ID)
+	if second["seq"] != 1 {
+		t.Fatalf("expected the replacement carton to be seq 1, got %v", second["seq"])
+	}
+	db.Model(scrapped).Update("status", "in_stock")
+	if _, err := cmdPackItems(db, nil, "", "", map[string]any{
+		"package_id": second["package_id"], "serial_numbers": []any{"SN-PACK-1", "SN-PACK-2"},
+	}); err != nil {
+		t.Fatalf("repack after void failed: %v", err)
+	}
+
+	out, err := cmdSealPackage(db, nil, "", "", map[string]any{"package_id": second["package_id"], "gross_weight": 3.5})
+	if err != nil {
+		t.Fatalf("seal failed: %v", err)
+	}
+	if status := out.(map[string]any)["shipment_status"]; status != shipmentPacked {
+		t.Fatalf("expected shipment %s after sealing, got %v", shipmentPacked, status)
+	}
+	if serials := packedSerials(db, shipment.ID); len(serials) != 2 {
+		t.Fatalf("expected 2 packed serials, got %v", serials)
+	}
+}
@@ -0,0 +1,551 @@
+package erp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	sdk "github.com/bitfantasy/acp-module-sdk"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ===========================================================================
+// 科目层级 + 过账规则
+//
+// 凭证科目不再写死在代码里：postInventoryJournal / cmdPostAPFromSettlement /
+// post_by_rule 都通过 erp_posting_rules 解析借贷科目，财务可自行新增科目
+// （如关税、进项运费）和规则。所有自动凭证在落库前统一校验借贷平衡。
+// ===========================================================================
+
+// Posting-rule transaction types used by the built-in postings. Inventory
+// transactions use their own txn.Type (po_receipt, sales_issue, ...).
+const (
+	postingTxAPSettlement = "ap_settlement"
+	// ap_fx_diff 为单边规则：汇兑损失记借方科目，汇兑收益记贷方科目
+	postingTxAPFXDiff           = "ap_fx_diff"
+	postingTxPPV                = "ppv"
+	postingTxWOMaterialVariance = "wo_material_variance"
+	postingTxWOLaborVariance    = "wo_labor_variance"
+	postingTxCostRevaluation    = "cost_revaluation"
+)
+
+// singleSidedPostingTypes may use the same account on both sides because
+// only one side is posted per entry.
+var singleSidedPostingTypes = map[string]bool{postingTxAPFXDiff: true}
+
+// defaultPostingRules reproduces the original hard-coded mapping. Outbound
+// transactions match the source warehouse type, inbound the destination.
+var defaultPostingRules = []ErpPostingRule{
+	{TxType: "po_receipt", DebitAccount: "1403", CreditAccount: "2202", Description: "采购入库 DR 原材料 / CR 应付账款"},
+	{TxType: "po_receipt", WarehouseType: "finished", DebitAccount: "1405", CreditAccount: "2202", Description: "采购入库(成品库) DR 库存商品 / CR 应付账款"},
+	{TxType: "po_receipt", WarehouseType: "wip", DebitAccount: "1411", CreditAccount: "2202", Description: "采购入库(在制品库) DR 在制品 / CR 应付账款"},
+	{TxType: "production_in", DebitAccount: "1405", CreditAccount: "1411", Description: "完工入库 DR 库存商品 / CR 在制品"},
+	{TxType: "production_issue", DebitAccount: "1411", CreditAccount: "1403", Description: "生产领料 DR 在制品 / CR 原材料"},
+	{TxType: "sales_issue", DebitAccount: "6401", CreditAccount: "1405", Description: "销售出库 DR 主营业务成本 / CR 库存商品"},
+	{TxType: "adjustment_in", DebitAccount: "1403", CreditAccount: "5301", Description: "盘盈 DR 原材料 / CR 营业外收入"},
+	{TxType: "adjustment_in", WarehouseType: "finished", DebitAccount: "1405", CreditAccount: "5301", Description: "盘盈(成品库) DR 库存商品 / CR 营业外收入"},
+	{TxType: "adjustment_in", WarehouseType: "wip", DebitAccount: "1411", CreditAccount: "5301", Description: "盘盈(在制品库) DR 在制品 / CR 营业外收入"},
+	{TxType: "adjustment_out", DebitAccount: "5302", CreditAccount: "1403", Description: "盘亏 DR 营业外支出 / CR 原材料"},
+	{TxType: "adjustment_out", WarehouseType: "finished", DebitAccount: "5302", CreditAccount: "1405", Description: "盘亏(成品库) DR 营业外支出 / CR 库存商品"},
+	{TxType: "adjustment_out", WarehouseType: "wip", DebitAccount: "5302", CreditAccount: "1411", Description: "盘亏(在制品库) DR 营业外支出 / CR 在制品"},
+	{TxType: "scrap", DebitAccount: "5302", CreditAccount: "1403", Description: "报废 DR 营业外支出 / CR 原材料"},
+	{TxType: "scrap", WarehouseType: "finished", DebitAccount: "5302", CreditAccount: "1405", Description: "报废(成品库) DR 营业外支出 / CR 库存商品"},
+	{TxType: "scrap", WarehouseType: "wip", DebitAccount: "5302", CreditAccount: "1411", Description: "报废(在制品库) DR 营业外支出 / CR 在制品"},
+	{TxType: postingTxAPSettlement, DebitAccount: "2202", CreditAccount: "1002", Description: "供应商结算付款 DR 应付账款 / CR 银行存款"},
+	{TxType: postingTxAPFXDiff, DebitAccount: "6603", CreditAccount: "6603", Description: "已实现汇兑损益：损失记借方，收益记贷方"},
+	// 标准成本：规则按不利差异/升值方向配置，金额为负时借贷互换
+	{TxType: postingTxPPV, DebitAccount: "1404", CreditAccount: "2202", Description: "采购价格差异 DR 材料成本差异 / CR 应付账款"},
+	{TxType: postingTxWOMaterialVariance, DebitAccount: "6401", CreditAccount: "1411", Description: "工单材料用量差异 DR 主营业务成本 / CR 在制品"},
+	{TxType: postingTxWOLaborVariance, DebitAccount: "6401", CreditAccount: "1411", Description: "工单人工效率差异 DR 主营业务成本 / CR 在制品"},
+	{TxType: postingTxCostRevaluation, DebitAccount: "1403", CreditAccount: "1404", Description: "标准成本重估 DR 原材料 / CR 材料成本差异"},
+	{TxType: postingTxCostRevaluation, WarehouseType: "finished", DebitAccount: "1405", CreditAccount: "1404", Description: "标准成本重估(成品库) DR 库存商品 / CR 材料成本差异"},
+	{TxType: postingTxCostRevaluation, WarehouseType: "wip", DebitAccount: "1411", CreditAccount: "1404", Description: "标准成本重估(在制品库) DR 在制品 / CR 材料成本差异"},
+}
+
+// ensurePostingRules seeds the default rules of every transaction type that
+// has no rule yet (active or not), bootstrapping the default chart of
+// accounts first. Rules finance has edited are left alone. Idempotent; run
+// by Migrate, so rule types added in a release are seeded on upgrade.
+func ensurePostingRules(db *gorm.DB) (int, error) {
+	var present []string
+	if err := db.Model(&ErpPostingRule{}).Distinct().Pluck("tx_type", &present).Error; err != nil {
+		return 0, err
+	}
+	have := make(map[string]bool, len(present))
+	for _, t := range present {
+		have[t] = true
+	}
+	created := 0
+	for _, r := range defaultPostingRules {
+		if have[r.TxType] {
+			continue
+		}
+		if created == 0 {
+			for _, spec := range defaultChartOfAccounts {
+				ensureAccount(db, spec.Code, spec.Name, spec.Type)
+			}
+		}
+		rule := r
+		rule.ID = uuid.New().String()
+		rule.Status = "active"
+		if err := db.Create(&rule).Error; err != nil {
+			return created, fmt.Errorf("create posting rule %s failed: %w", rule.TxType, err)
+		}
+		created++
+	}
+	return created, nil
+}
+
+// ---------------------------------------------------------------------------
+// Account hierarchy
+// ---------------------------------------------------------------------------
+
+// createAccount adds an account to the chart. A child account's code must
+// extend its parent's code (1403 → 140301), inherits the parent's type and
+// turns the parent into a summary account, which is only allowed while the
+// parent has no journal lines of its own.
+func createAccount(db *gorm.DB, code, name, accountType, parentCode, currency, cashFlowClass string) (*ErpAccount, error) {
+	code, name = strings.TrimSpace(code), strings.TrimSpace(name)
+	if code == "" || name == "" {
+		return nil, fmt.Errorf("code and name are required")
+	}
+	if cashFlowClass != "" && !cashFlowClasses[cashFlowClass] {
+		return nil, fmt.Errorf("unsupported cash flow class: %s", cashFlowClass)
+	}
+	var exists int64
+	db.Model(&ErpAccount{}).Where("code = ?", code).Count(&exists)
+	if exists > 0 {
+		return nil, fmt.Errorf("account code already exists: %s", code)
+	}
+	if currency == "" {
+		currency = "CNY"
+	}
+	acc := ErpAccount{
+		ID:            uuid.New().String(),
+		Code:          code,
+		Name:          name,
+		Type:          accountType,
+		Level:         1,
+		IsLeaf:        true,
+		Currency:      currency,
+		Status:        "active",
+		CashFlowClass: cashFlowClass,
+	}
+	if parentCode == "" && cashFlowClass == "" {
+		acc.CashFlowClass = defaultCashFlowClass(code)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if parentCode != "" {
+			var parent ErpAccount
+			if err := tx.Where("code = ?", parentCode).First(&parent).Error; err != nil {
+				return fmt.Errorf("parent account not found: %s", parentCode)
+			}
+			if !strings.HasPrefix(code, parent.Code) || code == parent.Code {
+				return fmt.Errorf("sub-account code %s must extend parent code %s", code, parent.Code)
+			}
+			if accountType != "" && accountType != parent.Type {
+				return fmt.Errorf("sub-account type %s differs from parent type %s", accountType, parent.Type)
+			}
+			if parent.IsLeaf {
+				var lines int64
+				tx.Model(&ErpJournalLine{}).Where("account_id = ?", parent.ID).Count(&lines)
+				if lines > 0 {
+					return fmt.Errorf("parent account %s already has %d journal lines, cannot add sub-accounts", parent.Code, lines)
+				}
+				if err := tx.Model(&parent).Update("is_leaf", false).Error; err != nil {
+					return err
+				}
+			}
+			acc.ParentID, acc.Level, acc.Type = parent.ID, parent.Level+1, parent.Type
+		}
+		if acc.Type == "" {
+			return fmt.Errorf("type is required for top-level accounts")
+		}
+		switch statementClass(acc.Type) {
+		case "asset", "liability", "equity", "revenue", "expense":
+		default:
+			return fmt.Errorf("unsupported account type: %s", acc.Type)
+		}
+		return tx.Create(&acc).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// postableAccount loads an account by code and checks it can take postings.
+func postableAccount(db *gorm.DB, code string) (*ErpAccount, error) {
+	var acc ErpAccount
+	if err := db.Where("code = ?", code).First(&acc).Error; err != nil {
+		return nil, fmt.Errorf("account not found: %s", code)
+	}
+	if acc.Status != "" && acc.Status != "active" {
+		return nil, fmt.Errorf("account %s %s is %s", acc.Code, acc.Name, acc.Status)
+	}
+	if !acc.IsLeaf {
+		return nil, fmt.Errorf("account %s %s has sub-accounts, post to a leaf account", acc.Code, acc.Name)
+	}
+	return &acc, nil
+}
+
+// ---------------------------------------------------------------------------
+// Posting rules
+// ---------------------------------------------------------------------------
+
+// validatePostingRule checks that a rule can produce a balanced two-line
+// entry: both accounts exist, are active leaves, and differ.
+func validatePostingRule(db *gorm.DB, rule *ErpPostingRule) error {
+	if strings.TrimSpace(rule.TxType) == "" {
+		return fmt.Errorf("tx_type is required")
+	}
+	if rule.DebitAccount == "" || rule.CreditAccount == "" {
+		return fmt.Errorf("debit_account and credit_account are required")
+	}
+	if rule.DebitAccount == rule.CreditAccount && !singleSidedPostingTypes[rule.TxType] {
+		return fmt.Errorf("debit and credit account are both %s", rule.DebitAccount)
+	}
+	if _, err := postableAccount(db, rule.DebitAccount); err != nil {
+		return fmt.Errorf("debit account: %w", err)
+	}
+	if _, err := postableAccount(db, rule.CreditAccount); err != nil {
+		return fmt.Errorf("credit account: %w", err)
+	}
+	return nil
+}
+
+// savePostingRule validates and upserts a rule. A new rule may not duplicate
+// the match key of another active rule at the same priority.
+func savePostingRule(db *gorm.DB, rule *ErpPostingRule) error {
+	if rule.Status == "" {
+		rule.Status = "active"
+	}
+	if err := validatePostingRule(db, rule); err != nil {
+		return err
+	}
+	if rule.Status == "active" {
+		var dup int64
+		q := db.Model(&ErpPostingRule{}).
+			Where("tx_type = ? AND warehouse_type = ? AND material_category = ? AND priority = ? AND status = ?",
+				rule.TxType, rule.WarehouseType, rule.MaterialCategory, rule.Priority, "active")
+		if rule.ID != "" {
+			q = q.Where("id <> ?", rule.ID)
+		}
+		q.Count(&dup)
+		if dup > 0 {
+			return fmt.Errorf("an active rule for %s / %s / %s at priority %d already exists",
+				rule.TxType, orAny(rule.WarehouseType), orAny(rule.MaterialCategory), rule.Priority)
+		}
+	}
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+		return db.Create(rule).Error
+	}
+	return db.Save(rule).Error
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+// postingContext is what a rule is matched against.
+type postingContext struct {
+	TxType        string `json:"tx_type"`
+	WarehouseType string `json:"warehouse_type"`
+	// CategoryCodes is the material's category and its ancestors, nearest first.
+	CategoryCodes []string `json:"category_codes"`
+}
+
+// postingContextFor resolves the warehouse type and material category chain.
+func postingContextFor(db *gorm.DB, txType, warehouseID, materialID string) postingContext {
+	pc := postingContext{TxType: txType}
+	if warehouseID != "" {
+		var wh ErpWarehouse
+		if err := db.First(&wh, "id = ?", warehouseID).Error; err == nil {
+			pc.WarehouseType = wh.Type
+		}
+	}
+	if materialID != "" {
+		var categoryID string
+		db.Table("plm_materials").Where("id = ?", materialID).Select("category_id").Row().Scan(&categoryID)
+		for depth := 0; categoryID != "" && depth < 10; depth++ {
+			var cat struct {
+				Code     string
+				ParentID string
+			}
+			if db.Table("material_categories").Where("id = ?", categoryID).Select("code, parent_id").Scan(&cat).Error != nil || cat.Code == "" {
+				break
+			}
+			pc.CategoryCodes = append(pc.CategoryCodes, cat.Code)
+			categoryID = cat.ParentID
+		}
+	}
+	return pc
+}
+
+// resolvePostingRule picks the most specific active rule: a category match
+// outranks a warehouse-type match, which outranks a wildcard; nearer
+// categories outrank ancestors; ties go to the higher priority. Returns nil
+// when no rule covers the transaction type.
+func resolvePostingRule(db *gorm.DB, pc postingContext) (*ErpPostingRule, error) {
+	var rules []ErpPostingRule
+	if err := db.Where("tx_type = ? AND status = ?", pc.TxType, "active").
+		Order("priority DESC, created_at").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	var best *ErpPostingRule
+	bestScore := -1
+	for i := range rules {
+		r := &rules[i]
+		score := 0
+		if r.WarehouseType != "" {
+			if r.WarehouseType != pc.WarehouseType {
+				continue
+			}
+			score++
+		}
+		if r.MaterialCategory != "" {
+			idx := -1
+			for j, code := range pc.CategoryCodes {
+				if code == r.MaterialCategory {
+					idx = j
+					break
+				}
+			}
+			if idx < 0 {
+				continue
+			}
+			score += 100 - idx
+		}
+		// rules are priority-ordered, so the first of equal score wins
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best, nil
+}
+
+// ---------------------------------------------------------------------------
+// Balanced entry creation
+// ---------------------------------------------------------------------------
+
+// createPostedEntry validates and writes a posted journal entry. Every line
+// must hit a postable account and total debit must equal total credit.
+func createPostedEntry(tx *gorm.DB, entry *ErpJournalEntry, lines []ErpJournalLine) error {
+	if err := validateJournalLines(tx, lines); err != nil {
+		return err
+	}
+	var debit, credit float64
+	for _, l := range lines {
+		debit += l.Debit
+		credit += l.Credit
+	}
+	now := time.Now()
+	if entry.EntryDate == nil {
+		entry.EntryDate = &now
+	}
+	if entry.Period == "" {
+		entry.Period = entry.EntryDate.Format("2006-01")
+	}
+	if isPeriodLocked(tx, entry.Period) {
+		return fmt.Errorf("会计期间 %s 已关闭", entry.Period)
+	}
+	entry.TotalDebit, entry.TotalCredit = roundAmount(debit), roundAmount(credit)
+	entry.Status, entry.PostedAt = "posted", &now
+
+	_, err := autoCodeWithRetry(tx, "erp_journal_entries", "JE", func(c string) error {
+		entry.ID = uuid.New().String()
+		entry.Code = c
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return fmt.Errorf("create journal entry failed: %w", err)
+	}
+	for i := range lines {
+		lines[i].ID = uuid.New().String()
+		lines[i].EntryID = entry.ID
+		if lines[i].Currency == "" {
+			lines[i].Currency = "CNY"
+		}
+		if err := tx.Create(&lines[i]).Error; err != nil {
+			return fmt.Errorf("create journal line failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateJournalLines enforces 借贷平衡 and leaf/active accounts.
+func validateJournalLines(db *gorm.DB, lines []ErpJournalLine) error {
+	if len(lines) < 2 {
+		return fmt.Errorf("a journal entry needs at least two lines")
+	}
+	var debit, credit float64
+	for _, l := range lines {
+		if l.Debit < 0 || l.Credit < 0 || (l.Debit > 0 && l.Credit > 0) {
+			return fmt.Errorf("line %q must carry a positive debit or credit, not both", l.Description)
+		}
+		var acc ErpAccount
+		if err := db.First(&acc, "id = ?", l.AccountID).Error; err != nil {
+			return fmt.Errorf("account not found: %s", l.AccountID)
+		}
+		if _, err := postableAccount(db, acc.Code); err != nil {
+			return err
+		}
+		debit += l.Debit
+		credit += l.Credit
+	}
+	if math.Abs(roundAmount(debit)-roundAmount(credit)) >= 0.005 {
+		return fmt.Errorf("借贷不平衡: 借方%.2f != 贷方%.2f", debit, credit)
+	}
+	return nil
+}
+
+// postByRule posts a two-line entry DR rule.debit / CR rule.credit.
+func postByRule(tx *gorm.DB, rule *ErpPostingRule, amount float64, entry *ErpJournalEntry, line ErpJournalLine) error {
+	lines, err := ruleLines(tx, rule, amount, entry, line)
+	if err != nil {
+		return err
+	}
+	return createPostedEntry(tx, entry, lines)
+}
+
+// ruleLines builds the DR/CR line pair of a rule. A negative amount swaps
+// the sides, which is how favourable variances and write-downs reverse the
+// direction the rule is configured for.
+func ruleLines(tx *gorm.DB, rule *ErpPostingRule, amount float64, entry *ErpJournalEntry, line ErpJournalLine) ([]ErpJournalLine, error) {
+	dr, err := postableAccount(tx, rule.DebitAccount)
+	if err != nil {
+		return nil, fmt.Errorf("posting rule %s: %w", rule.TxType, err)
+	}
+	cr, err := postableAccount(tx, rule.CreditAccount)
+	if err != nil {
+		return nil, fmt.Errorf("posting rule %s: %w", rule.TxType, err)
+	}
+	if amount < 0 {
+		dr, cr, amount = cr, dr, -amount
+	}
+	amount = roundAmount(amount)
+	drLine, crLine := line, line
+	drLine.AccountID, drLine.Debit, drLine.Credit = dr.ID, amount, 0
+	crLine.AccountID, crLine.Debit, crLine.Credit = cr.ID, 0, amount
+	base := line.Description
+	if base == "" {
+		base = entry.Description
+	}
+	drLine.Description, crLine.Description = base+" DR", base+" CR"
+	return []ErpJournalLine{drLine, crLine}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Commands
+// ---------------------------------------------------------------------------
+
+// cmdCreateAccount adds an account (optionally under a parent) to the chart.
+func cmdCreateAccount(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	acc, err := createAccount(db, getStr(input, "code"), getStr(input, "name"), getStr(input, "type"),
+		getStr(input, "parent_code"), getStr(input, "currency"), getStr(input, "cash_flow_class"))
+	if err != nil {
+		return nil, err
+	}
+	emitEvent(adapter, runID, stepID, "erp.account.created",
+		fmt.Sprintf("科目创建: %s %s (%s, 第%d级)", acc.Code, acc.Name, acc.Type, acc.Level),
+		map[string]any{"account_id": acc.ID, "code": acc.Code, "parent_id": acc.ParentID})
+	return map[string]any{"account_id": acc.ID, "code": acc.Code, "name": acc.Name, "type": acc.Type, "level": acc.Level, "parent_id": acc.ParentID}, nil
+}
+
+// cmdSavePostingRule creates or updates a posting rule after validation.
+func cmdSavePostingRule(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	rule := ErpPostingRule{}
+	if id := getStr(input, "id"); id != "" {
+		if err := db.First(&rule, "id = ?", id).Error; err != nil {
+			return nil, fmt.Errorf("posting rule not found: %s", id)
+		}
+	}
+	for key, dst := range map[string]*string{
+		"tx_type":           &rule.TxType,
+		"warehouse_type":    &rule.WarehouseType,
+		"material_category": &rule.MaterialCategory,
+		"debit_account":     &rule.DebitAccount,
+		"credit_account":    &rule.CreditAccount,
+		"description":       &rule.Description,
+		"status":            &rule.Status,
+	} {
+		if _, ok := input[key]; ok {
+			*dst = getStr(input, key)
+		}
+	}
+	if _, ok := input["priority"]; ok {
+		rule.Priority = getInt(input, "priority", 0)
+	}
+	if err := savePostingRule(db, &rule); err != nil {
+		return nil, err
+	}
+	emitEvent(adapter, runID, stepID, "erp.posting_rule.saved",
+		fmt.Sprintf("过账规则: %s [%s/%s] DR %s / CR %s", rule.TxType, orAny(rule.WarehouseType), orAny(rule.MaterialCategory), rule.DebitAccount, rule.CreditAccount),
+		map[string]any{"rule_id": rule.ID, "tx_type": rule.TxType})
+	return rule, nil
+}
+
+// cmdPostByRule books an arbitrary business amount through the rule table,
+// e.g. tx_type=freight_in or customs_duty configured by finance.
+func cmdPostByRule(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	txType := getStr(input, "tx_type")
+	amount := getFloat(input, "amount")
+	if txType == "" {
+		return nil, fmt.Errorf("tx_type is required")
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	pc := postingContextFor(db, txType, getStr(input, "warehouse_id"), getStr(input, "material_id"))
+	rule, err := resolvePostingRule(db, pc)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, fmt.Errorf("no active posting rule for tx_type %s", txType)
+	}
+
+	entry := ErpJournalEntry{
+		EntryDate:   parseDate(getStr(input, "entry_date")),
+		SourceType:  getStr(input, "source_type"),
+		SourceID:    getStr(input, "source_id"),
+		Description: getStr(input, "description"),
+		PostedBy:    getStr(input, "posted_by"),
+	}
+	if entry.SourceType == "" {
+		entry.SourceType = txType
+	}
+	if entry.Description == "" {
+		entry.Description = fmt.Sprintf("%s %.2f", txType, amount)
+	}
+	line := ErpJournalLine{
+		Currency:       getStr(input, "currency"),
+		OriginalAmount: amount,
+		CustomerID:     getStr(input, "customer_id"),
+		SupplierID:     getStr(input, "supplier_id"),
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return postByRule(tx, rule, amount, &entry, line)
+	}); err != nil {
+		return nil, err
+	}
+
+	emitEvent(adapter, runID, stepID, "erp.journal_entry.posted",
+		fmt.Sprintf("规则过账: %s %s DR %s / CR %s ¥%.2f", entry.Code, txType, rule.DebitAccount, rule.CreditAccount, amount),
+		map[string]any{"entry_id": entry.ID, "code": entry.Code, "rule_id": rule.ID})
+	return map[string]any{
+		"entry_id":       entry.ID,
+		"code":           entry.Code,
+		"rule_id":        rule.ID,
+		"debit_account":  rule.DebitAccount,
+		"credit_account": rule.CreditAccount,
+		"amount":         roundAmount(amount),
+	}, nil
+}
@@ -0,0 +1,325 @@
+package erp
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	sdk "github.com/bitfantasy/acp-module-sdk"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ===========================================================================
+// 报价定价：价目表(渠道+币种, 客户专属价, 数量阶梯) → 促销折扣 → 毛利管控
+//
+// 报价行未给单价时按价目表取价，手工单价保留价目价以便对比；行毛利按
+// 产品当前标准成本(否则移动平均/PLM 成本, 否则 BOM 卷算)计算，低于渠道
+// 毛利阈值的报价需 approve_quotation_margin 审批后才能确认。
+// ===========================================================================
+
+// Sales channels (mirrors the main ERP SalesOrder channels, lower-cased).
+const (
+	channelDirect    = "direct"
+	channelEcommerce = "ecommerce"
+	channelDealer    = "dealer"
+	channelRetail    = "retail"
+)
+
+// Quotation line price sources.
+const (
+	priceSourceCustomer = "customer"
+	priceSourceList     = "price_list"
+	priceSourceManual   = "manual"
+)
+
+// Quotation margin approval statuses; "" means no approval needed.
+const (
+	quoteApprovalPending  = "pending"
+	quoteApprovalApproved = "approved"
+	quoteApprovalRejected = "rejected"
+)
+
+// customerChannel derives the default channel from the customer type.
+func customerChannel(db *gorm.DB, customerID string) string {
+	var cust ErpCustomer
+	if db.First(&cust, "id = ?", customerID).Error != nil {
+		return channelDirect
+	}
+	switch cust.Type {
+	case "distributor":
+		return channelDealer
+	case "individual":
+		return channelRetail
+	}
+	return channelDirect
+}
+
+// activeAt reports whether a validity window (nil = open) covers the date.
+func activeAt(from, to *time.Time, at time.Time) bool {
+	day := truncDay(at)
+	if from != nil && day.Before(truncDay(*from)) {
+		return false
+	}
+	if to != nil && day.After(truncDay(*to)) {
+		return false
+	}
+	return true
+}
+
+type priceQuote struct {
+	ProductID        string  `json:"product_id"`
+	Quantity         float64 `json:"quantity"`
+	ListPrice        float64 `json:"list_price"`
+	BreakQty         float64 `json:"break_qty"`
+	PriceListID      string  `json:"price_list_id"`
+	PriceSource      string  `json:"price_source"`
+	PromotionID      string  `json:"promotion_id"`
+	PromoDiscountPct float64 `json:"promo_discount_pct"`
+}
+
+// resolvePrice finds the list price and best promotion for a product.
+// Customer-specific lists win over channel lists, which win over general
+// lists; ties go to the higher priority. Within a list the highest quantity
+// break not above qty applies.
+func resolvePrice(db *gorm.DB, customerID, channel, currency, productID string, qty float64, at time.Time) priceQuote {
+	pq := priceQuote{ProductID: productID, Quantity: qty}
+
+	var lists []ErpPriceList
+	db.Where("status = ? AND currency = ? AND (channel = ? OR channel = '') AND (customer_id = ? OR customer_id = '')",
+		"active", currency, channel, customerID).Find(&lists)
+	valid := lists[:0]
+	for _, l := range lists {
+		if activeAt(l.ValidFrom, l.ValidTo, at) {
+			valid = append(valid, l)
+		}
+	}
+	sort.SliceStable(valid, func(i, j int) bool {
+		a, b := valid[i], valid[j]
+		if (a.CustomerID != "") != (b.CustomerID != "") {
+			return a.CustomerID != ""
+		}
+		if (a.Channel != "") != (b.Channel != "") {
+			return a.Channel != ""
+		}
+		return a.Priority > b.Priority
+	})
+	for _, l := range valid {
+		var item ErpPriceListItem
+		if db.Where("price_list_id = ? AND product_id = ? AND min_qty <= ?", l.ID, productID, qty).
+			Order("min_qty DESC").First(&item).Error != nil {
+			continue
+		}
+		pq.ListPrice, pq.BreakQty, pq.PriceListID = item.UnitPrice, item.MinQty, l.ID
+		pq.PriceSource = priceSourceList
+		if l.CustomerID != "" {
+			pq.PriceSource = priceSourceCustomer
+		}
+		break
+	}
+
+	var promos []ErpPromotion
+	db.Where("status = ? AND (channel = ? OR channel = '') AND (customer_id = ? OR customer_id = '') AND (product_id = ? OR product_id = '') AND min_qty <= ?",
+		"active", channel, customerID, productID, qty).Find(&promos)
+	for _, p := range promos {
+		if activeAt(p.ValidFrom, p.ValidTo, at) && p.DiscountPct > pq.PromoDiscountPct {
+			pq.PromotionID, pq.PromoDiscountPct = p.ID, p.DiscountPct
+		}
+	}
+	return pq
+}
+
+// productUnitCost is the functional unit cost margins are measured against:
+// the current standard cost version, else the inventory/PLM cost, else a
+// roll-up of the released BOM.
+func productUnitCost(db *gorm.DB, productID string) (float64, string) {
+	var sc ErpStandardCost
+	if db.Where("material_id = ? AND status = ?", productID, stdCostCurrent).
+		Order("effective_at DESC").First(&sc).Error == nil && sc.TotalCost > 0 {
+		return sc.TotalCost, "standard"
+	}
+	if cost, src := componentUnitCost(db, productID); cost > 0 {
+		return cost, src
+	}
+	if releasedBOMFor(db, productID) != "" {
+		if r, err := newCostRoller(db, 1).rollup(productID, ""); err == nil && r.TotalCost > 0 {
+			return r.TotalCost, "bom"
+		}
+	}
+	return 0, "none"
+}
+
+// minMarginFor returns the channel's margin threshold, falling back to the
+// default ("" channel) rule, then 0 so quoting below cost always needs approval.
+func minMarginFor(db *gorm.DB, channel string) float64 {
+	var rules []ErpMarginRule
+	db.Where("status = ? AND channel IN ?", "active", []string{channel, ""}).Find(&rules)
+	fallback := 0.0
+	for _, r := range rules {
+		if r.Channel == channel {
+			return r.MinMarginPct
+		}
+		fallback = r.MinMarginPct
+	}
+	return fallback
+}
+
+func marginPct(revenue, cost float64) float64 {
+	if revenue <= 0 {
+		return -100
+	}
+	return roundAmount((revenue - cost) / revenue * 100)
+}
+
+type quoteContext struct {
+	CustomerID   string
+	Channel      string
+	Currency     string
+	ExchangeRate float64
+	MinMarginPct float64
+	At           time.Time
+}
+
+// priceQuotationLine prices one input line: list price when unit_price is
+// omitted, promotion discount when discount_pct is omitted (an explicit 0
+// quotes without the promotion), and the line's functional margin against
+// product cost. A line without a known cost cannot be margin-checked and is
+// flagged CostUnknown so the quotation goes to approval.
+func priceQuotationLine(db *gorm.DB, qc *quoteContext, item map[string]any) (ErpQuotationItem, error) {
+	productID := getStr(item, "product_id")
+	qty := getFloat(item, "quantity")
+	pq := resolvePrice(db, qc.CustomerID, qc.Channel, qc.Currency, productID, qty, qc.At)
+
+	li := ErpQuotationItem{
+		ProductID:   productID,
+		SkuID:       getStr(item, "sku_id"),
+		Description: getStr(item, "description"),
+		Quantity:    qty,
+		TaxRate:     getFloat(item, "tax_rate"),
+		ListPrice:   pq.ListPrice,
+		PriceListID: pq.PriceListID,
+		PriceSource: pq.PriceSource,
+	}
+	li.UnitPrice = getFloat(item, "unit_price")
+	switch {
+	case li.UnitPrice > 0:
+		if li.UnitPrice != pq.ListPrice {
+			li.PriceSource = priceSourceManual
+		}
+	case pq.ListPrice > 0:
+		li.UnitPrice = pq.ListPrice
+	default:
+		return li, fmt.Errorf("no %s %s price for product %s: give unit_price or maintain a price list", qc.Channel, qc.Currency, productID)
+	}
+	if v, ok := item["discount_pct"]; ok && v != nil {
+		li.DiscountPct = getFloat(item, "discount_pct")
+	} else if pq.PromoDiscountPct > 0 {
+		li.DiscountPct, li.PromotionID = pq.PromoDiscountPct, pq.PromotionID
+	}
+	li.LineTotal = qty * li.UnitPrice * (1 - li.DiscountPct/100)
+
+	li.UnitCost, li.CostSource = productUnitCost(db, productID)
+	switch {
+	case li.UnitCost <= 0:
+		li.CostUnknown = true
+	case qty > 0:
+		li.MarginPct = marginPct(li.LineTotal*qc.ExchangeRate, li.UnitCost*qty)
+		li.BelowMargin = li.MarginPct < qc.MinMarginPct
+	}
+	return li, nil
+}
+
+// cmdApproveQuotationMargin — 低毛利报价审批
+func cmdApproveQuotationMargin(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	quotID := getStr(input, "quotation_id")
+	if quotID == "" {
+		return nil, fmt.Errorf("quotation_id is required")
+	}
+	approvedBy := getStr(input, "approved_by")
+	if approvedBy == "" {
+		return nil, fmt.Errorf("approved_by is required")
+	}
+	decision := getStr(input, "decision")
+	if decision == "" {
+		decision = "approve"
+	}
+	if decision != "approve" && decision != "reject" {
+		return nil, fmt.Errorf("decision must be approve or reject")
+	}
+	var quot ErpQuotation
+	if err := db.First(&quot, "id = ?", quotID).Error; err != nil {
+		return nil, fmt.Errorf("quotation not found")
+	}
+	if quot.ApprovalStatus != quoteApprovalPending {
+		return nil, fmt.Errorf("quotation %s is not awaiting margin approval (approval status %q)", quot.Code, quot.ApprovalStatus)
+	}
+
+	status, label := quoteApprovalApproved, "通过"
+	if decision == "reject" {
+		status, label = quoteApprovalRejected, "驳回"
+	}
+	now := time.Now()
+	note := getStr(input, "reason")
+	db.Model(&quot).Updates(map[string]any{
+		"approval_status": status, "approved_by": approvedBy, "approved_at": &now, "approval_note": note,
+	})
+	db.Create(&ErpAuditLog{
+		ID: uuid.New().String(), EntityType: "quotation", EntityID: quot.ID,
+		Field: "approval_status", OldValue: quoteApprovalPending, NewValue: status, UserID: approvedBy,
+	})
+
+	emitEvent(adapter, runID, stepID, "erp.quotation.margin_"+status,
+		fmt.Sprintf("报价毛利审批%s: %s 毛利率 %.2f%% (阈值 %.2f%%)", label, quot.Code, quot.MarginPct, quot.MinMarginPct),
+		map[string]any{"quotation_id": quot.ID, "code": quot.Code, "approved_by": approvedBy, "margin_pct": quot.MarginPct})
+
+	return map[string]any{"quotation_id": quot.ID, "code": quot.Code, "approval_status": status, "margin_pct": quot.MarginPct}, nil
+}
+
+// cmdQuotePrice — 报价试算：按价目表/促销取价并计算毛利，不生成报价单
+func cmdQuotePrice(db *gorm.DB, adapter sdk.EngineAdapter, runID, stepID string, input map[string]any) (any, error) {
+	customerID := getStr(input, "customer_id")
+	productID := getStr(input, "product_id")
+	if productID == "" {
+		return nil, fmt.Errorf("product_id is required")
+	}
+	return quotePrice(db, customerID, getStr(input, "channel"), getStr(input, "currency"), productID,
+		getFloat(input, "quantity"), getFloat(input, "exchange_rate")), nil
+}
+
+// quotePrice previews the price, promotion and margin of one product; rate
+// converts the quote currency to functional currency for the margin.
+func quotePrice(db *gorm.DB, customerID, channel, currency, productID string, qty, rate float64) map[string]any {
+	if channel == "" {
+		channel = customerChannel(db, customerID)
+	}
+	if currency == "" {
+		currency = "CNY"
+	}
+	if qty <= 0 {
+		qty = 1
+	}
+	if rate <= 0 {
+		rate = 1
+	}
+	pq := resolvePrice(db, customerID, channel, currency, productID, qty, time.Now())
+	net := pq.ListPrice * (1 - pq.PromoDiscountPct/100)
+	cost, costSource := productUnitCost(db, productID)
+	minMargin := minMarginFor(db, channel)
+	out := map[string]any{
+		"product_id": productID, "quantity": qty, "channel": channel, "currency": currency,
+		"list_price": pq.ListPrice, "break_qty": pq.BreakQty, "price_list_id": pq.PriceListID, "price_source": pq.PriceSource,
+		"promotion_id": pq.PromotionID, "promo_discount_pct": pq.PromoDiscountPct, "net_price": roundAmount(net),
+		"unit_cost": cost, "cost_source": costSource, "min_margin_pct": minMargin,
+	}
+	if cost <= 0 {
+		out["cost_unknown"] = true
+	} else if net > 0 {
+		m := marginPct(net*rate, cost)
+		out["margin_pct"] = m
+		out["below_margin"] = m < minMargin
+		if minMargin < 100 {
+			out["floor_price"] = roundAmount(cost / (1 - minMargin/100) / rate)
+		}
+	}
+	return out
+}
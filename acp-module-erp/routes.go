@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ---------------------------------------------------------------------------
@@ -83,6 +84,64 @@ func registerRoutes(m *ERPModule, rg *gin.RouterGroup) {
 		})
 	})
 
+	// GET /shipments/:id/packing-list — 装箱单（托盘 → 纸箱 → 序列号/批次，含毛净重体积）
+	rg.GET("/shipments/:id/packing-list", func(c *gin.Context) {
+		var shipment ErpShipment
+		if err := db.First(&shipment, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shipment not found"})
+			return
+		}
+		c.JSON(http.StatusOK, buildPackingList(db, &shipment))
+	})
+
+	// GET /shipments/:id/labels?format=zpl|pdf — 发货单全部包装件标签
+	rg.GET("/shipments/:id/labels", func(c *gin.Context) {
+		var shipment ErpShipment
+		if err := db.First(&shipment, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shipment not found"})
+			return
+		}
+		writeShippingLabels(c, buildShippingLabels(db, &shipment, ""), shipment.Code)
+	})
+
+	// GET /packages/:id/label?format=zpl|pdf — 单个包装件 SSCC 标签
+	rg.GET("/packages/:id/label", func(c *gin.Context) {
+		var pkg ErpPackage
+		if err := db.First(&pkg, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "package not found"})
+			return
+		}
+		if pkg.Status == packageVoid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "package is void"})
+			return
+		}
+		var shipment ErpShipment
+		db.First(&shipment, "id = ?", pkg.ShipmentID)
+		writeShippingLabels(c, buildShippingLabels(db, &shipment, pkg.ID), pkg.SSCC)
+	})
+
+	// GET /serial-shipments/:serial — 按序列号查所在包装件与发货单
+	rg.GET("/serial-shipments/:serial", func(c *gin.Context) {
+		rows := findShipmentsBySerial(db, c.Param("serial"))
+		if len(rows) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "serial number not packed in any shipment"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": rows, "total": len(rows)})
+	})
+
+	// GET /shipments/:id/customs-invoice — 报关发票（由 generate_customs_invoice 生成）
+	rg.GET("/shipments/:id/customs-invoice", func(c *gin.Context) {
+		var inv ErpCustomsInvoice
+		if err := db.First(&inv, "shipment_id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "customs invoice not generated"})
+			return
+		}
+		var lines []ErpCustomsInvoiceLine
+		db.Where("invoice_id = ?", inv.ID).Order("created_at").Find(&lines)
+		c.JSON(http.StatusOK, gin.H{"invoice": inv, "lines": lines})
+	})
+
 	// ─── Inventory ───
 
 	// GET /stock-summary — 库存汇总（按物料/仓库），含 PLM 物料名 + 仓库名 join
@@ -846,6 +905,9 @@ func registerRoutes(m *ERPModule, rg *gin.RouterGroup) {
 			db.First(&ret, "id = ?", sn.ReturnID)
 		}
 
+		// Packages
+		packages := findShipmentsBySerial(db, serial)
+
 		// Related transactions (same lot, same material)
 		var txns []ErpInventoryTransaction
 		if sn.LotNumber != "" {
@@ -894,6 +956,7 @@ func registerRoutes(m *ERPModule, rg *gin.RouterGroup) {
 			"shipment":     shipment,
 			"customer":     customer,
 			"return":       ret,
+			"packages":     packages,
 			"transactions": txns,
 			"timeline":     timeline,
 		})
@@ -970,104 +1033,205 @@ func registerRoutes(m *ERPModule, rg *gin.RouterGroup) {
 		c.JSON(http.StatusOK, gin.H{"items": results, "period": period})
 	})
 
-	// GET /income-statement — 利润表
+	// GET /income-statement — 利润表（?compare=prior_month|prior_ytd&basis=month|ytd）
 	rg.GET("/income-statement", func(c *gin.Context) {
-		period := c.Query("period")
-		if period == "" {
-			period = time.Now().Format("2006-01")
-		}
-		type AccountSum struct {
-			Type    string  `json:"type"`
-			Amount  float64 `json:"amount"`
+		st, ok := statementFromQuery(c, db, "income_statement")
+		if !ok {
+			return
 		}
-		var results []AccountSum
-		db.Raw(`
-			SELECT a.type,
-				CASE WHEN a.type = 'revenue' THEN COALESCE(SUM(jl.credit - jl.debit), 0)
-				     ELSE COALESCE(SUM(jl.debit - jl.credit), 0) END as amount
-			FROM erp_accounts a
-			JOIN erp_journal_lines jl ON jl.account_id = a.id
-			JOIN erp_journal_entries je ON je.id = jl.entry_id AND je.status = 'posted'
-				AND je.period = ?
-			WHERE a.type IN ('revenue', 'expense')
-			GROUP BY a.type
-		`, period).Scan(&results)
+		c.JSON(http.StatusOK, gin.H{
+			"period":    st.Period,
+			"revenue":   st.Totals["revenue"],
+			"expense":   st.Totals["expense"],
+			"profit":    st.Totals["profit"],
+			"statement": st,
+		})
+	})
 
-		var revenue, expense float64
-		for _, r := range results {
-			if r.Type == "revenue" {
-				revenue = r.Amount
-			} else {
-				expense = r.Amount
-			}
+	// GET /balance-sheet — 资产负债表（按科目层级，?compare=prior_month|prior_ytd）
+	rg.GET("/balance-sheet", func(c *gin.Context) {
+		st, ok := statementFromQuery(c, db, "balance_sheet")
+		if !ok {
+			return
 		}
-
 		c.JSON(http.StatusOK, gin.H{
-			"period":  period,
-			"revenue": revenue,
-			"expense": expense,
-			"profit":  revenue - expense,
+			"period":    st.Period,
+			"assets":    st.Totals["assets"],
+			"liability": st.Totals["liabilities"],
+			"equity":    st.Totals["equity"],
+			"balanced":  st.Balanced,
+			"statement": st,
 		})
 	})
 
-	// GET /balance-sheet — 资产负债表
-	rg.GET("/balance-sheet", func(c *gin.Context) {
-		period := c.Query("period")
-		if period == "" {
-			period = time.Now().Format("2006-01")
+	// GET /statements/drill-down — 报表数字穿透到凭证行
+	// ?account_id=a&account_id=b&from=2026-01&to=2026-03&page=1&page_size=50
+	rg.GET("/statements/drill-down", func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+		result, err := drillDownJournal(db, c.QueryArray("account_id"),
+			stmtRange{From: c.Query("from"), To: c.Query("to")}, page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	// GET /statements/export — 报表导出 Excel（参数同 /statements/:type）
+	rg.GET("/statements/export", func(c *gin.Context) {
+		st, ok := statementFromQuery(c, db, c.Query("report_type"))
+		if !ok {
+			return
 		}
-		type TypeSum struct {
-			Type   string  `json:"type"`
-			Amount float64 `json:"amount"`
+		f, filename, err := exportStatementExcel(st)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-		var results []TypeSum
-		db.Raw(`
-			SELECT a.type,
-				CASE WHEN a.type IN ('asset', 'expense') THEN COALESCE(SUM(jl.debit - jl.credit), 0)
-				     ELSE COALESCE(SUM(jl.credit - jl.debit), 0) END as amount
-			FROM erp_accounts a
-			JOIN erp_journal_lines jl ON jl.account_id = a.id
-			JOIN erp_journal_entries je ON je.id = jl.entry_id AND je.status = 'posted'
-				AND je.period <= ?
-			WHERE a.type IN ('asset', 'liability', 'equity')
-			GROUP BY a.type
-		`, period).Scan(&results)
+		defer f.Close()
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		c.Header("Content-Transfer-Encoding", "binary")
+		if err := f.Write(c.Writer); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "write excel: " + err.Error()})
+		}
+	})
 
-		data := map[string]float64{}
-		for _, r := range results {
-			data[r.Type] = r.Amount
+	// GET /statements/:type — balance_sheet / income_statement / cash_flow 完整报表
+	rg.GET("/statements/:type", func(c *gin.Context) {
+		st, ok := statementFromQuery(c, db, c.Param("type"))
+		if !ok {
+			return
 		}
+		c.JSON(http.StatusOK, st)
+	})
 
-		c.JSON(http.StatusOK, gin.H{
-			"period":    period,
-			"assets":    data["asset"],
-			"liability": data["liability"],
-			"equity":    data["equity"],
-		})
+	// GET /accounts/tree — 科目树（父子层级）
+	rg.GET("/accounts/tree", func(c *gin.Context) {
+		tree := loadAccountTree(db)
+		type node struct {
+			ErpAccount
+			Children []*node `json:"children,omitempty"`
+		}
+		var build func(id string) *node
+		build = func(id string) *node {
+			n := &node{ErpAccount: *tree.byID[id]}
+			for _, child := range tree.children[id] {
+				n.Children = append(n.Children, build(child))
+			}
+			return n
+		}
+		roots := []*node{}
+		for i := range tree.accounts {
+			if tree.isRoot(&tree.accounts[i]) {
+				roots = append(roots, build(tree.accounts[i].ID))
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"items": roots})
+	})
+
+	// GET /posting-rules/resolve?tx_type=&warehouse_id=&material_id= — 预览命中的过账规则
+	rg.GET("/posting-rules/resolve", func(c *gin.Context) {
+		pc := postingContextFor(db, c.Query("tx_type"), c.Query("warehouse_id"), c.Query("material_id"))
+		rule, err := resolvePostingRule(db, pc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"context": pc, "rule": rule})
+	})
+
+	// GET /posting-rules/validate — 校验全部启用规则（科目存在、启用、末级）
+	rg.GET("/posting-rules/validate", func(c *gin.Context) {
+		var rules []ErpPostingRule
+		db.Where("status = ?", "active").Order("tx_type, priority DESC").Find(&rules)
+		issues := []gin.H{}
+		for i := range rules {
+			if err := validatePostingRule(db, &rules[i]); err != nil {
+				issues = append(issues, gin.H{"rule_id": rules[i].ID, "tx_type": rules[i].TxType, "error": err.Error()})
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"checked": len(rules), "valid": len(issues) == 0, "issues": issues})
 	})
 
-	// GET /ar-aging — 应收账龄
+	// GET /costing/rollup-preview?material_id=&bom_id=&lot_size= — 标准成本卷算试算（不落库）
+	rg.GET("/costing/rollup-preview", func(c *gin.Context) {
+		materialID := c.Query("material_id")
+		if materialID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "material_id is required"})
+			return
+		}
+		lotSize, _ := strconv.ParseFloat(c.Query("lot_size"), 64)
+		res, err := newCostRoller(db, lotSize).rollup(materialID, c.Query("bom_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var current ErpStandardCost
+		var currentCost float64
+		if db.Where("material_id = ? AND status = ?", materialID, stdCostCurrent).First(&current).Error == nil {
+			currentCost = current.TotalCost
+		}
+		c.JSON(http.StatusOK, gin.H{"rollup": res, "current_cost": currentCost, "change": roundCost(res.TotalCost - currentCost)})
+	})
+
+	// GET /pricing/quote?product_id=&customer_id=&quantity=&channel=&currency= — 报价试算
+	rg.GET("/pricing/quote", func(c *gin.Context) {
+		productID := c.Query("product_id")
+		if productID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+			return
+		}
+		qty, _ := strconv.ParseFloat(c.Query("quantity"), 64)
+		rate, _ := strconv.ParseFloat(c.Query("exchange_rate"), 64)
+		c.JSON(http.StatusOK, quotePrice(db, c.Query("customer_id"), c.Query("channel"), c.Query("currency"), productID, qty, rate))
+	})
+
+	// GET /ar-aging?as_of=YYYY-MM-DD — 应收账龄（未到期、逾期 30/60/90/90+ 天），按客户
 	rg.GET("/ar-aging", func(c *gin.Context) {
-		var results []struct {
-			CustomerID string  `json:"customer_id"`
-			Current    float64 `json:"current"`
-			Days30     float64 `json:"days_30"`
-			Days60     float64 `json:"days_60"`
-			Days90     float64 `json:"days_90"`
-			Over90     float64 `json:"over_90"`
+		asOf := time.Now()
+		if d := parseDate(c.Query("as_of")); d != nil {
+			asOf = *d
 		}
-		db.Raw(`
-			SELECT customer_id,
-				SUM(CASE WHEN due_date >= CURRENT_DATE THEN total - paid_amount ELSE 0 END) as current,
-				SUM(CASE WHEN due_date < CURRENT_DATE AND due_date >= CURRENT_DATE - 30 THEN total - paid_amount ELSE 0 END) as days_30,
-				SUM(CASE WHEN due_date < CURRENT_DATE - 30 AND due_date >= CURRENT_DATE - 60 THEN total - paid_amount ELSE 0 END) as days_60,
-				SUM(CASE WHEN due_date < CURRENT_DATE - 60 AND due_date >= CURRENT_DATE - 90 THEN total - paid_amount ELSE 0 END) as days_90,
-				SUM(CASE WHEN due_date < CURRENT_DATE - 90 THEN total - paid_amount ELSE 0 END) as over_90
-			FROM erp_sales_invoices
-			WHERE status NOT IN ('cancelled', 'paid')
-			GROUP BY customer_id
-		`).Scan(&results)
-		c.JSON(http.StatusOK, gin.H{"items": results})
+		items, total := arAging(db, asOf)
+		c.JSON(http.StatusOK, gin.H{"as_of": asOf.Format("2006-01-02"), "items": items, "total": total})
+	})
+
+	// GET /customers/:id/credit — 客户信用占用（未清应收 + 未发货订单 + 已发货未开票）
+	rg.GET("/customers/:id/credit", func(c *gin.Context) {
+		var cust ErpCustomer
+		if err := db.First(&cust, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, customerExposure(db, &cust, time.Now(), ""))
+	})
+
+	// GET /ap-aging?as_of=YYYY-MM-DD — 应付账龄（未到期 7/30 天内、逾期分段），按供应商
+	rg.GET("/ap-aging", func(c *gin.Context) {
+		asOf := time.Now()
+		if d := parseDate(c.Query("as_of")); d != nil {
+			asOf = *d
+		}
+		items, total := apAging(db, asOf)
+		c.JSON(http.StatusOK, gin.H{"as_of": asOf.Format("2006-01-02"), "items": items, "total": total})
+	})
+
+	// GET /payment-runs/:id/export — 付款批次导出银行批量付款 CSV
+	rg.GET("/payment-runs/:id/export", func(c *gin.Context) {
+		var run ErpPaymentRun
+		if err := db.First(&run, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "payment run not found"})
+			return
+		}
+		data, filename, err := paymentRunBankFile(db, &run)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
 	})
 
 	// GET /cost-analysis/:product_id — 产品成本分析
@@ -1291,49 +1455,20 @@ func registerRoutes(m *ERPModule, rg *gin.RouterGroup) {
 
 	// ─── Cash Flow ───
 
-	// GET /cash-flow — 现金流量表
+	// GET /cash-flow — 现金流量表（间接法，?compare=prior_month|prior_ytd&basis=month|ytd）
 	rg.GET("/cash-flow", func(c *gin.Context) {
-		period := c.Query("period")
-		if period == "" {
-			period = time.Now().Format("2006-01")
+		st, ok := statementFromQuery(c, db, "cash_flow")
+		if !ok {
+			return
 		}
-
-		// Operating: sales receipts - purchase payments
-		var salesReceipts float64
-		db.Table("erp_receipts").
-			Where("status = 'confirmed'").
-			Where("TO_CHAR(received_date, 'YYYY-MM') = ?", period).
-			Select("COALESCE(SUM(amount), 0)").Row().Scan(&salesReceipts)
-
-		// For AP payments, estimate from journal entries
-		var apPayments float64
-		db.Raw(`
-			SELECT COALESCE(SUM(jl.debit), 0) FROM erp_journal_lines jl
-			JOIN erp_journal_entries je ON je.id = jl.entry_id
-			JOIN erp_accounts a ON a.id = jl.account_id
-			WHERE je.status = 'posted' AND je.period = ? AND a.code = '2202'
-		`, period).Scan(&apPayments)
-
-		operatingNet := salesReceipts - apPayments
-
-		// Cash balance from bank account (1002)
-		var cashBalance float64
-		db.Raw(`
-			SELECT COALESCE(SUM(jl.debit - jl.credit), 0) FROM erp_journal_lines jl
-			JOIN erp_journal_entries je ON je.id = jl.entry_id
-			JOIN erp_accounts a ON a.id = jl.account_id
-			WHERE je.status = 'posted' AND je.period <= ? AND a.code = '1002'
-		`, period).Scan(&cashBalance)
-
 		c.JSON(http.StatusOK, gin.H{
-			"period":         period,
-			"operating_in":   salesReceipts,
-			"operating_out":  apPayments,
-			"operating_net":  operatingNet,
-			"investing_net":  0, // placeholder
-			"financing_net":  0, // placeholder
-			"net_change":     operatingNet,
-			"ending_balance": cashBalance,
+			"period":         st.Period,
+			"operating_net":  st.Totals["operating_net"],
+			"investing_net":  st.Totals["investing_net"],
+			"financing_net":  st.Totals["financing_net"],
+			"net_change":     st.Totals["net_change"],
+			"ending_balance": st.Totals["ending_cash"],
+			"statement":      st,
 		})
 	})
 
@@ -1351,3 +1486,37 @@ func registerRoutes(m *ERPModule, rg *gin.RouterGroup) {
 
 	_ = strings.TrimSpace // suppress unused import
 }
+
+// statementFromQuery builds a statement from ?period=&basis=&compare=&show_zero=
+// and writes a 400 response on invalid input.
+func statementFromQuery(c *gin.Context, db *gorm.DB, reportType string) (*financialStatement, bool) {
+	opts, err := statementOptionsFromInput(map[string]any{
+		"period":    c.Query("period"),
+		"basis":     c.Query("basis"),
+		"compare":   c.Query("compare"),
+		"show_zero": c.Query("show_zero"),
+	})
+	if err == nil {
+		var st *financialStatement
+		if st, err = buildStatement(db, reportType, opts); err == nil {
+			return st, true
+		}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	return nil, false
+}
+
+// writeShippingLabels 按 format 输出 ZPL（默认）或 PDF
+func writeShippingLabels(c *gin.Context, labels []shippingLabel, name string) {
+	if len(labels) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no packages to label"})
+		return
+	}
+	if c.DefaultQuery("format", "zpl") == "pdf" {
+		c.Header("Content-Disposition", "attachment; filename=\""+name+".pdf\"")
+		c.Data(http.StatusOK, "application/pdf", renderLabelPDF(labels))
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=\""+name+".zpl\"")
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", renderZPL(labels))
+}
@@ -0,0 +1,912 @@
+package erp
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// ===========================================================================
+// 财务报表引擎：资产负债表 / 利润表 / 现金流量表（间接法）+ 多期对比 + 穿透
+// ===========================================================================
+
+// Statement compare modes.
+const (
+	compareNone       = ""
+	comparePriorMonth = "prior_month" // 本月 vs 上月
+	comparePriorYTD   = "prior_ytd"   // 本年累计 vs 上年同期累计
+)
+
+// Statement bases for flow statements (income / cash flow).
+const (
+	basisMonth = "month"
+	basisYTD   = "ytd"
+)
+
+// stmtRange is an inclusive period range (YYYY-MM). An empty From means
+// "since inception", which is how balance sheet balances are accumulated.
+type stmtRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (r stmtRange) label() string {
+	if r.From == "" {
+		return r.To + " 期末"
+	}
+	if r.From == r.To {
+		return r.To
+	}
+	return r.From + "~" + r.To
+}
+
+// stmtDrill tells the caller which journal lines make up a figure. Account
+// IDs are expanded to their descendants by drillDownJournal.
+type stmtDrill struct {
+	AccountIDs []string `json:"account_ids"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+}
+
+type statementLine struct {
+	Key       string     `json:"key"`
+	AccountID string     `json:"account_id,omitempty"`
+	Code      string     `json:"code,omitempty"`
+	Name      string     `json:"name"`
+	Level     int        `json:"level"`
+	Amount    float64    `json:"amount"`
+	Compare   *float64   `json:"compare,omitempty"`
+	Change    *float64   `json:"change,omitempty"`
+	ChangePct *float64   `json:"change_pct,omitempty"`
+	Drill     *stmtDrill `json:"drill,omitempty"`
+}
+
+type statementSection struct {
+	Key          string          `json:"key"`
+	Title        string          `json:"title"`
+	Lines        []statementLine `json:"lines"`
+	Total        float64         `json:"total"`
+	CompareTotal *float64        `json:"compare_total,omitempty"`
+}
+
+type financialStatement struct {
+	ReportType    string             `json:"report_type"`
+	Title         string             `json:"title"`
+	Period        string             `json:"period"`
+	Basis         string             `json:"basis,omitempty"`
+	CompareMode   string             `json:"compare_mode,omitempty"`
+	Current       stmtRange          `json:"current"`
+	CompareRange  *stmtRange         `json:"compare_range,omitempty"`
+	Sections      []statementSection `json:"sections"`
+	Totals        map[string]float64 `json:"totals"`
+	CompareTotals map[string]float64 `json:"compare_totals,omitempty"`
+	Balanced      bool               `json:"balanced"`
+}
+
+// statementOptions are the shared knobs of all statements.
+type statementOptions struct {
+	Period   string
+	Basis    string
+	Compare  string
+	ShowZero bool
+}
+
+func statementOptionsFromInput(input map[string]any) (statementOptions, error) {
+	opts := statementOptions{
+		Period:   getStr(input, "period"),
+		Basis:    getStr(input, "basis"),
+		Compare:  getStr(input, "compare"),
+		ShowZero: getBool(input, "show_zero"),
+	}
+	if opts.Period == "" {
+		opts.Period = time.Now().Format("2006-01")
+	}
+	if _, err := time.Parse("2006-01", opts.Period); err != nil {
+		return opts, fmt.Errorf("invalid period %q, expected YYYY-MM", opts.Period)
+	}
+	switch opts.Compare {
+	case compareNone, comparePriorMonth, comparePriorYTD:
+	default:
+		return opts, fmt.Errorf("unsupported compare mode: %s (supported: prior_month, prior_ytd)", opts.Compare)
+	}
+	if opts.Basis == "" {
+		opts.Basis = basisMonth
+		if opts.Compare == comparePriorYTD {
+			opts.Basis = basisYTD
+		}
+	}
+	if opts.Basis != basisMonth && opts.Basis != basisYTD {
+		return opts, fmt.Errorf("unsupported basis: %s (supported: month, ytd)", opts.Basis)
+	}
+	return opts, nil
+}
+
+// shiftPeriod moves a YYYY-MM period by the given number of months.
+func shiftPeriod(period string, months int) string {
+	t, err := time.Parse("2006-01", period)
+	if err != nil {
+		return period
+	}
+	return t.AddDate(0, months, 0).Format("2006-01")
+}
+
+// flowRanges returns the current and comparison ranges of a flow statement.
+func flowRanges(opts statementOptions) (stmtRange, *stmtRange) {
+	cur := stmtRange{From: opts.Period, To: opts.Period}
+	if opts.Basis == basisYTD {
+		cur.From = opts.Period[:4] + "-01"
+	}
+	switch opts.Compare {
+	case comparePriorMonth:
+		prev := shiftPeriod(opts.Period, -1)
+		cmp := stmtRange{From: prev, To: prev}
+		if opts.Basis == basisYTD {
+			cmp.From = prev[:4] + "-01"
+		}
+		return cur, &cmp
+	case comparePriorYTD:
+		cmp := stmtRange{From: shiftPeriod(cur.From, -12), To: shiftPeriod(cur.To, -12)}
+		return cur, &cmp
+	}
+	return cur, nil
+}
+
+// pointRanges returns the balance-as-of ranges of the balance sheet.
+func pointRanges(opts statementOptions) (stmtRange, *stmtRange) {
+	cur := stmtRange{To: opts.Period}
+	switch opts.Compare {
+	case comparePriorMonth:
+		return cur, &stmtRange{To: shiftPeriod(opts.Period, -1)}
+	case comparePriorYTD:
+		return cur, &stmtRange{To: shiftPeriod(opts.Period, -12)}
+	}
+	return cur, nil
+}
+
+// ---------------------------------------------------------------------------
+// Account balances & hierarchy
+// ---------------------------------------------------------------------------
+
+type accountMovement struct {
+	AccountID string
+	Debit     float64
+	Credit    float64
+}
+
+// sumJournal totals posted journal lines per account for the range.
+func sumJournal(db *gorm.DB, r stmtRange) (map[string]accountMovement, error) {
+	var rows []accountMovement
+	q := db.Table("erp_journal_lines jl").
+		Joins("JOIN erp_journal_entries je ON je.id = jl.entry_id").
+		Where("je.status = ?", "posted").
+		Where("je.period <= ?", r.To).
+		Select("jl.account_id, COALESCE(SUM(jl.debit), 0) as debit, COALESCE(SUM(jl.credit), 0) as credit").
+		Group("jl.account_id")
+	if r.From != "" {
+		q = q.Where("je.period >= ?", r.From)
+	}
+	if err := q.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("sum journal lines failed: %w", err)
+	}
+	out := make(map[string]accountMovement, len(rows))
+	for _, row := range rows {
+		out[row.AccountID] = row
+	}
+	return out, nil
+}
+
+// statementClass maps an account type onto the statement element it belongs
+// to. The default chart uses "income" for 营业外收入, treated as revenue.
+func statementClass(accountType string) string {
+	switch accountType {
+	case "income":
+		return "revenue"
+	case "cost":
+		return "expense"
+	}
+	return accountType
+}
+
+// creditNormal reports whether the statement class carries a credit balance.
+func creditNormal(class string) bool {
+	return class == "liability" || class == "equity" || class == "revenue"
+}
+
+// accountTree is the chart of accounts indexed for roll-up.
+type accountTree struct {
+	accounts []ErpAccount
+	byID     map[string]*ErpAccount
+	children map[string][]string
+}
+
+func loadAccountTree(db *gorm.DB) *accountTree {
+	t := &accountTree{byID: map[string]*ErpAccount{}, children: map[string][]string{}}
+	db.Order("code").Find(&t.accounts)
+	for i := range t.accounts {
+		t.byID[t.accounts[i].ID] = &t.accounts[i]
+	}
+	for _, a := range t.accounts {
+		if a.ParentID != "" && t.byID[a.ParentID] != nil {
+			t.children[a.ParentID] = append(t.children[a.ParentID], a.ID)
+		}
+	}
+	return t
+}
+
+func (t *accountTree) isRoot(a *ErpAccount) bool {
+	return a.ParentID == "" || t.byID[a.ParentID] == nil
+}
+
+// descendants returns the account and all its sub-accounts.
+func (t *accountTree) descendants(id string) []string {
+	out := []string{id}
+	for _, child := range t.children[id] {
+		out = append(out, t.descendants(child)...)
+	}
+	return out
+}
+
+// rollup returns each account's natural-sign balance including its
+// sub-accounts. Lines posted directly to a parent account are kept.
+func (t *accountTree) rollup(mv map[string]accountMovement) map[string]float64 {
+	out := make(map[string]float64, len(t.accounts))
+	var walk func(id string) float64
+	walk = func(id string) float64 {
+		if v, ok := out[id]; ok {
+			return v
+		}
+		a := t.byID[id]
+		m := mv[id]
+		v := m.Debit - m.Credit
+		if creditNormal(statementClass(a.Type)) {
+			v = -v
+		}
+		for _, child := range t.children[id] {
+			v += walk(child)
+		}
+		out[id] = v
+		return v
+	}
+	for _, a := range t.accounts {
+		walk(a.ID)
+	}
+	return out
+}
+
+// rootsOf returns the top-level accounts of the given statement classes.
+func (t *accountTree) rootsOf(classes ...string) []string {
+	var ids []string
+	for i := range t.accounts {
+		a := &t.accounts[i]
+		if !t.isRoot(a) {
+			continue
+		}
+		for _, c := range classes {
+			if statementClass(a.Type) == c {
+				ids = append(ids, a.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// hierarchyLines renders the account tree of the given classes depth-first.
+// Zero lines are dropped unless showZero, except when the comparison side
+// carries an amount.
+func (t *accountTree) hierarchyLines(bal, cmp map[string]float64, r stmtRange, showZero bool, classes ...string) ([]statementLine, float64) {
+	var lines []statementLine
+	var total float64
+	var walk func(id string, level int)
+	walk = func(id string, level int) {
+		a := t.byID[id]
+		amount := bal[id]
+		if !showZero && math.Abs(amount) < 0.005 && (cmp == nil || math.Abs(cmp[id]) < 0.005) {
+			return
+		}
+		lines = append(lines, statementLine{
+			Key: a.Code, AccountID: a.ID, Code: a.Code, Name: a.Name, Level: level,
+			Amount: roundAmount(amount),
+			Drill:  &stmtDrill{AccountIDs: []string{a.ID}, From: r.From, To: r.To},
+		})
+		for _, child := range t.children[id] {
+			walk(child, level+1)
+		}
+	}
+	for _, id := range t.rootsOf(classes...) {
+		total += bal[id]
+		walk(id, 1)
+	}
+	return lines, roundAmount(total)
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ---------------------------------------------------------------------------
+// Balance sheet
+// ---------------------------------------------------------------------------
+
+func buildBalanceSheet(db *gorm.DB, opts statementOptions) (*financialStatement, error) {
+	tree := loadAccountTree(db)
+	var sumErr error
+	sum := func(r stmtRange) map[string]accountMovement {
+		mv, err := sumJournal(db, r)
+		if err != nil && sumErr == nil {
+			sumErr = err
+		}
+		return mv
+	}
+	cur, cmpRange := pointRanges(opts)
+
+	build := func(r stmtRange, cmpBal map[string]float64) ([]statementSection, map[string]float64, map[string]float64) {
+		bal := tree.rollup(sum(r))
+		assets, totalAssets := tree.hierarchyLines(bal, cmpBal, r, opts.ShowZero, "asset")
+		liabs, totalLiabs := tree.hierarchyLines(bal, cmpBal, r, opts.ShowZero, "liability")
+		equity, totalEquity := tree.hierarchyLines(bal, cmpBal, r, opts.ShowZero, "equity")
+
+		// 损益类科目未结转到权益前，以"未结转损益"并入所有者权益，保证报表平衡
+		var unclosed float64
+		for _, id := range tree.rootsOf("revenue") {
+			unclosed += bal[id]
+		}
+		for _, id := range tree.rootsOf("expense") {
+			unclosed -= bal[id]
+		}
+		unclosed = roundAmount(unclosed)
+		if opts.ShowZero || math.Abs(unclosed) >= 0.005 {
+			equity = append(equity, statementLine{
+				Key: "unclosed_profit", Name: "未结转损益", Level: 1, Amount: unclosed,
+				Drill: &stmtDrill{AccountIDs: tree.rootsOf("revenue", "expense"), From: r.From, To: r.To},
+			})
+		}
+		totalEquity = roundAmount(totalEquity + unclosed)
+
+		sections := []statementSection{
+			{Key: "assets", Title: "资产", Lines: assets, Total: totalAssets},
+			{Key: "liabilities", Title: "负债", Lines: liabs, Total: totalLiabs},
+			{Key: "equity", Title: "所有者权益", Lines: equity, Total: totalEquity},
+		}
+		totals := map[string]float64{
+			"assets":                 totalAssets,
+			"liabilities":            totalLiabs,
+			"equity":                 totalEquity,
+			"liabilities_and_equity": roundAmount(totalLiabs + totalEquity),
+			"difference":             roundAmount(totalAssets - totalLiabs - totalEquity),
+		}
+		return sections, totals, bal
+	}
+
+	st := &financialStatement{
+		ReportType: "balance_sheet", Title: "资产负债表", Period: opts.Period,
+		CompareMode: opts.Compare, Current: cur, CompareRange: cmpRange,
+	}
+	var cmpBal map[string]float64
+	var cmpSections []statementSection
+	if cmpRange != nil {
+		cmpSections, st.CompareTotals, cmpBal = build(*cmpRange, nil)
+	}
+	st.Sections, st.Totals, _ = build(cur, cmpBal)
+	mergeCompare(st.Sections, cmpSections)
+	st.Balanced = math.Abs(st.Totals["difference"]) < 0.01
+	if sumErr != nil {
+		return nil, sumErr
+	}
+	return st, nil
+}
+
+// ---------------------------------------------------------------------------
+// Income statement
+// ---------------------------------------------------------------------------
+
+func buildIncomeStatement(db *gorm.DB, opts statementOptions) (*financialStatement, error) {
+	tree := loadAccountTree(db)
+	var sumErr error
+	sum := func(r stmtRange) map[string]accountMovement {
+		mv, err := sumJournal(db, r)
+		if err != nil && sumErr == nil {
+			sumErr = err
+		}
+		return mv
+	}
+	cur, cmpRange := flowRanges(opts)
+
+	build := func(r stmtRange, cmpBal map[string]float64) ([]statementSection, map[string]float64, map[string]float64) {
+		bal := tree.rollup(sum(r))
+		revenue, totalRevenue := tree.hierarchyLines(bal, cmpBal, r, opts.ShowZero, "revenue")
+		expense, totalExpense := tree.hierarchyLines(bal, cmpBal, r, opts.ShowZero, "expense")
+		profit := roundAmount(totalRevenue - totalExpense)
+		sections := []statementSection{
+			{Key: "revenue", Title: "收入", Lines: revenue, Total: totalRevenue},
+			{Key: "expense", Title: "成本费用", Lines: expense, Total: totalExpense},
+			{Key: "profit", Title: "利润", Lines: []statementLine{{
+				Key: "net_profit", Name: "净利润", Level: 1, Amount: profit,
+				Drill: &stmtDrill{AccountIDs: tree.rootsOf("revenue", "expense"), From: r.From, To: r.To},
+			}}, Total: profit},
+		}
+		totals := map[string]float64{"revenue": totalRevenue, "expense": totalExpense, "profit": profit}
+		return sections, totals, bal
+	}
+
+	st := &financialStatement{
+		ReportType: "income_statement", Title: "利润表", Period: opts.Period, Basis: opts.Basis,
+		CompareMode: opts.Compare, Current: cur, CompareRange: cmpRange, Balanced: true,
+	}
+	var cmpBal map[string]float64
+	var cmpSections []statementSection
+	if cmpRange != nil {
+		cmpSections, st.CompareTotals, cmpBal = build(*cmpRange, nil)
+	}
+	st.Sections, st.Totals, _ = build(cur, cmpBal)
+	mergeCompare(st.Sections, cmpSections)
+	if sumErr != nil {
+		return nil, sumErr
+	}
+	return st, nil
+}
+
+// ---------------------------------------------------------------------------
+// Cash flow statement (indirect method)
+// ---------------------------------------------------------------------------
+
+// Cash flow classes of balance sheet accounts (ErpAccount.CashFlowClass).
+// Revenue and expense accounts always roll into net profit.
+const (
+	cashFlowCash      = "cash"
+	cashFlowNoncash   = "noncash" // 备抵科目的变动是非现金费用（折旧、摊销、减值），在经营活动中加回
+	cashFlowOperating = "operating"
+	cashFlowInvesting = "investing"
+	cashFlowFinancing = "financing"
+	cashFlowProfit    = "profit" // 本年利润、利润分配的变动是已结转的损益，并入净利润
+)
+
+var cashFlowClasses = map[string]bool{
+	cashFlowCash: true, cashFlowNoncash: true, cashFlowOperating: true,
+	cashFlowInvesting: true, cashFlowFinancing: true, cashFlowProfit: true,
+}
+
+// defaultCashFlowClasses pre-fills the class of top-level accounts of the
+// default chart (企业会计准则科目编码) when they are created. Finance can
+// change the class on the account afterwards; other charts set it directly.
+var defaultCashFlowClasses = []struct {
+	Prefixes []string
+	Class    string
+}{
+	{[]string{"1001", "1002", "1012"}, cashFlowCash},
+	{[]string{"4103", "4104"}, cashFlowProfit},
+	{[]string{"1231", "1471", "1602", "1603", "1702", "1703"}, cashFlowNoncash},
+	{[]string{"1101", "15", "1601", "1604", "1605", "1606", "1701", "1711"}, cashFlowInvesting},
+	{[]string{"2001", "2232", "25", "4001", "4002", "4101"}, cashFlowFinancing},
+}
+
+// defaultCashFlowClass is the class suggested for a new top-level account,
+// empty when the code is not in the default chart.
+func defaultCashFlowClass(code string) string {
+	for _, d := range defaultCashFlowClasses {
+		for _, p := range d.Prefixes {
+			if strings.HasPrefix(code, p) {
+				return d.Class
+			}
+		}
+	}
+	return ""
+}
+
+// backfillCashFlowClasses fills the class of top-level accounts created
+// before accounts carried one. Accounts with a class are left alone.
+func backfillCashFlowClasses(db *gorm.DB) error {
+	var roots []ErpAccount
+	if err := db.Where("(parent_id = '' OR parent_id IS NULL) AND (cash_flow_class = '' OR cash_flow_class IS NULL)").
+		Find(&roots).Error; err != nil {
+		return err
+	}
+	for _, a := range roots {
+		if class := defaultCashFlowClass(a.Code); class != "" {
+			if err := db.Model(&ErpAccount{}).Where("id = ?", a.ID).Update("cash_flow_class", class).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cashFlowClass classifies a posting account for the indirect method: the
+// account's own class, else the nearest ancestor's, else working capital.
+func (t *accountTree) cashFlowClass(a *ErpAccount) string {
+	class := statementClass(a.Type)
+	if class == "revenue" || class == "expense" {
+		return cashFlowProfit
+	}
+	for acc := a; acc != nil; acc = t.byID[acc.ParentID] {
+		if acc.CashFlowClass != "" {
+			return acc.CashFlowClass
+		}
+	}
+	return cashFlowOperating
+}
+
+// buildCashFlow derives cash flows from balance movements: because every
+// entry balances, net profit plus the cash effect (credit - debit) of every
+// non-cash balance sheet account equals the change in cash.
+func buildCashFlow(db *gorm.DB, opts statementOptions) (*financialStatement, error) {
+	tree := loadAccountTree(db)
+	var sumErr error
+	sum := func(r stmtRange) map[string]accountMovement {
+		mv, err := sumJournal(db, r)
+		if err != nil && sumErr == nil {
+			sumErr = err
+		}
+		return mv
+	}
+	cur, cmpRange := flowRanges(opts)
+
+	build := func(r stmtRange, cmpBal map[string]float64) ([]statementSection, map[string]float64, map[string]float64) {
+		mv := sum(r)
+		effect := make(map[string]float64, len(mv))
+		var profit, cashChange float64
+		var profitIDs, cashIDs []string
+		for i := range tree.accounts {
+			a := &tree.accounts[i]
+			m, ok := mv[a.ID]
+			if !ok {
+				continue
+			}
+			switch tree.cashFlowClass(a) {
+			case cashFlowProfit:
+				profit += m.Credit - m.Debit
+				profitIDs = append(profitIDs, a.ID)
+			case cashFlowCash:
+				cashChange += m.Debit - m.Credit
+				cashIDs = append(cashIDs, a.ID)
+			default:
+				effect[a.ID] = m.Credit - m.Debit
+			}
+		}
+
+		accountLines := func(class string, level int) ([]statementLine, float64) {
+			var lines []statementLine
+			var total float64
+			for i := range tree.accounts {
+				a := &tree.accounts[i]
+				if tree.cashFlowClass(a) != class {
+					continue
+				}
+				v, ok := effect[a.ID]
+				if !opts.ShowZero && (!ok || math.Abs(v) < 0.005) && (cmpBal == nil || math.Abs(cmpBal[a.ID]) < 0.005) {
+					continue
+				}
+				total += v
+				lines = append(lines, statementLine{
+					Key: a.Code, AccountID: a.ID, Code: a.Code, Name: a.Name, Level: level,
+					Amount: roundAmount(v),
+					Drill:  &stmtDrill{AccountIDs: []string{a.ID}, From: r.From, To: r.To},
+				})
+			}
+			return lines, total
+		}
+
+		profit = roundAmount(profit)
+		operating := []statementLine{{
+			Key: "net_profit", Name: "净利润", Level: 1, Amount: profit,
+			Drill: &stmtDrill{AccountIDs: profitIDs, From: r.From, To: r.To},
+		}}
+		noncash, noncashTotal := accountLines(cashFlowNoncash, 2)
+		if len(noncash) > 0 {
+			operating = append(operating, statementLine{Key: "noncash_adjustments", Name: "加：非现金费用调整", Level: 1, Amount: roundAmount(noncashTotal)})
+			operating = append(operating, noncash...)
+		}
+		wc, wcTotal := accountLines(cashFlowOperating, 2)
+		if len(wc) > 0 {
+			operating = append(operating, statementLine{Key: "working_capital", Name: "营运资本变动", Level: 1, Amount: roundAmount(wcTotal)})
+			operating = append(operating, wc...)
+		}
+		operatingNet := roundAmount(profit + noncashTotal + wcTotal)
+		investing, investingNet := accountLines(cashFlowInvesting, 1)
+		financing, financingNet := accountLines(cashFlowFinancing, 1)
+		investingNet, financingNet = roundAmount(investingNet), roundAmount(financingNet)
+		netChange := roundAmount(operatingNet + investingNet + financingNet)
+
+		var opening float64
+		if r.From != "" {
+			before := sum(stmtRange{To: shiftPeriod(r.From, -1)})
+			for i := range tree.accounts {
+				a := &tree.accounts[i]
+				if tree.cashFlowClass(a) == cashFlowCash {
+					opening += before[a.ID].Debit - before[a.ID].Credit
+				}
+			}
+		}
+		opening = roundAmount(opening)
+		ending := roundAmount(opening + cashChange)
+
+		sections := []statementSection{
+			{Key: "operating", Title: "经营活动产生的现金流量", Lines: operating, Total: operatingNet},
+			{Key: "investing", Title: "投资活动产生的现金流量", Lines: investing, Total: investingNet},
+			{Key: "financing", Title: "筹资活动产生的现金流量", Lines: financing, Total: financingNet},
+			{Key: "cash", Title: "现金及现金等价物", Lines: []statementLine{
+				{Key: "net_change", Name: "现金净增加额", Level: 1, Amount: netChange,
+					Drill: &stmtDrill{AccountIDs: cashIDs, From: r.From, To: r.To}},
+				{Key: "opening_cash", Name: "期初现金余额", Level: 1, Amount: opening},
+				{Key: "ending_cash", Name: "期末现金余额", Level: 1, Amount: ending},
+			}, Total: ending},
+		}
+		totals := map[string]float64{
+			"net_profit":    profit,
+			"operating_net": operatingNet,
+			"investing_net": investingNet,
+			"financing_net": financingNet,
+			"net_change":    netChange,
+			"opening_cash":  opening,
+			"ending_cash":   ending,
+			"cash_change":   roundAmount(cashChange),
+			"difference":    roundAmount(netChange - cashChange),
+		}
+		return sections, totals, effect
+	}
+
+	st := &financialStatement{
+		ReportType: "cash_flow", Title: "现金流量表", Period: opts.Period, Basis: opts.Basis,
+		CompareMode: opts.Compare, Current: cur, CompareRange: cmpRange,
+	}
+	var cmpEffect map[string]float64
+	var cmpSections []statementSection
+	if cmpRange != nil {
+		cmpSections, st.CompareTotals, cmpEffect = build(*cmpRange, nil)
+	}
+	st.Sections, st.Totals, _ = build(cur, cmpEffect)
+	mergeCompare(st.Sections, cmpSections)
+	st.Balanced = math.Abs(st.Totals["difference"]) < 0.01
+	if sumErr != nil {
+		return nil, sumErr
+	}
+	return st, nil
+}
+
+// ---------------------------------------------------------------------------
+// Comparison
+// ---------------------------------------------------------------------------
+
+// mergeCompare fills Compare/Change on the current sections from the
+// comparison sections, matching lines by key. Lines that only exist in the
+// comparison period are appended with a zero current amount.
+func mergeCompare(cur, cmp []statementSection) {
+	if cmp == nil {
+		return
+	}
+	for i := range cur {
+		var other *statementSection
+		for j := range cmp {
+			if cmp[j].Key == cur[i].Key {
+				other = &cmp[j]
+				break
+			}
+		}
+		if other == nil {
+			continue
+		}
+		seen := make(map[string]bool, len(cur[i].Lines))
+		for k := range cur[i].Lines {
+			line := &cur[i].Lines[k]
+			seen[line.Key] = true
+			var amount float64
+			for _, o := range other.Lines {
+				if o.Key == line.Key {
+					amount = o.Amount
+					break
+				}
+			}
+			setCompare(line, amount)
+		}
+		for _, o := range other.Lines {
+			if seen[o.Key] {
+				continue
+			}
+			line := o
+			line.Amount, line.Drill = 0, nil
+			setCompare(&line, o.Amount)
+			cur[i].Lines = append(cur[i].Lines, line)
+		}
+		total := other.Total
+		cur[i].CompareTotal = &total
+	}
+}
+
+func setCompare(line *statementLine, compare float64) {
+	change := roundAmount(line.Amount - compare)
+	line.Compare, line.Change = &compare, &change
+	if math.Abs(compare) >= 0.005 {
+		pct := math.Round(change/math.Abs(compare)*10000) / 100
+		line.ChangePct = &pct
+	}
+}
+
+// buildStatement dispatches on report type.
+func buildStatement(db *gorm.DB, reportType string, opts statementOptions) (*financialStatement, error) {
+	switch reportType {
+	case "balance_sheet":
+		return buildBalanceSheet(db, opts)
+	case "income_statement":
+		return buildIncomeStatement(db, opts)
+	case "cash_flow":
+		return buildCashFlow(db, opts)
+	}
+	return nil, fmt.Errorf("unsupported statement type: %s (supported: balance_sheet, income_statement, cash_flow)", reportType)
+}
+
+// statementExportURL is the Excel download link of a generated statement.
+func statementExportURL(reportType string, opts statementOptions) string {
+	q := url.Values{}
+	q.Set("report_type", reportType)
+	q.Set("period", opts.Period)
+	q.Set("basis", opts.Basis)
+	if opts.Compare != "" {
+		q.Set("compare", opts.Compare)
+	}
+	return "/api/m/erp/statements/export?" + q.Encode()
+}
+
+// ---------------------------------------------------------------------------
+// Drill-down
+// ---------------------------------------------------------------------------
+
+type drillLine struct {
+	EntryID     string     `json:"entry_id"`
+	EntryCode   string     `json:"entry_code"`
+	EntryDate   *time.Time `json:"entry_date"`
+	Period      string     `json:"period"`
+	SourceType  string     `json:"source_type"`
+	SourceID    string     `json:"source_id"`
+	LineID      string     `json:"line_id"`
+	AccountID   string     `json:"account_id"`
+	AccountCode string     `json:"account_code"`
+	AccountName string     `json:"account_name"`
+	Debit       float64    `json:"debit"`
+	Credit      float64    `json:"credit"`
+	Description string     `json:"description"`
+	CustomerID  string     `json:"customer_id"`
+	SupplierID  string     `json:"supplier_id"`
+}
+
+// drillDownJournal lists the posted journal lines behind a statement figure.
+// Accounts are expanded to their sub-accounts; an empty From means all
+// periods up to To.
+func drillDownJournal(db *gorm.DB, accountIDs []string, r stmtRange, page, pageSize int) (map[string]any, error) {
+	if len(accountIDs) == 0 {
+		return nil, fmt.Errorf("account_id is required")
+	}
+	if r.To == "" {
+		r.To = time.Now().Format("2006-01")
+	}
+	tree := loadAccountTree(db)
+	var ids []string
+	for _, id := range accountIDs {
+		if tree.byID[id] == nil {
+			return nil, fmt.Errorf("account not found: %s", id)
+		}
+		ids = append(ids, tree.descendants(id)...)
+	}
+	sort.Strings(ids)
+
+	base := func() *gorm.DB {
+		q := db.Table("erp_journal_lines jl").
+			Joins("JOIN erp_journal_entries je ON je.id = jl.entry_id").
+			Joins("JOIN erp_accounts a ON a.id = jl.account_id").
+			Where("je.status = ? AND jl.account_id IN ? AND je.period <= ?", "posted", ids, r.To)
+		if r.From != "" {
+			q = q.Where("je.period >= ?", r.From)
+		}
+		return q
+	}
+
+	var total int64
+	var debit, credit float64
+	base().Count(&total)
+	base().Select("COALESCE(SUM(jl.debit), 0), COALESCE(SUM(jl.credit), 0)").Row().Scan(&debit, &credit)
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+	var lines []drillLine
+	base().Select(`je.id as entry_id, je.code as entry_code, je.entry_date, je.period, je.source_type, je.source_id,
+			jl.id as line_id, jl.account_id, a.code as account_code, a.name as account_name,
+			jl.debit, jl.credit, jl.description, jl.customer_id, jl.supplier_id`).
+		Order("je.period, je.entry_date, je.code, a.code").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Scan(&lines)
+
+	return map[string]any{
+		"account_ids":  ids,
+		"range":        r,
+		"total":        total,
+		"page":         page,
+		"page_size":    pageSize,
+		"total_debit":  roundAmount(debit),
+		"total_credit": roundAmount(credit),
+		"net":          roundAmount(debit - credit),
+		"lines":        lines,
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Excel export
+// ---------------------------------------------------------------------------
+
+// exportStatementExcel renders a statement as a single-sheet workbook.
+func exportStatementExcel(st *financialStatement) (*excelize.File, string, error) {
+	f := excelize.NewFile()
+	sheet := st.Title
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return nil, "", err
+	}
+
+	titleStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true, Size: 11},
+		Fill:   excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#D9E1F2"}},
+		Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}},
+	})
+	sectionStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, NumFmt: 4})
+	amountStyle, _ := f.NewStyle(&excelize.Style{NumFmt: 4})
+
+	f.SetCellValue(sheet, "A1", st.Title)
+	f.SetCellStyle(sheet, "A1", "A1", titleStyle)
+	f.SetCellValue(sheet, "A2", "期间: "+st.Current.label())
+
+	headers := []string{"项目", "科目编码", "本期"}
+	if st.CompareRange != nil {
+		f.SetCellValue(sheet, "C2", "比较期间: "+st.CompareRange.label())
+		headers = append(headers, "比较期", "变动", "变动%")
+	}
+	lastCol, _ := excelize.ColumnNumberToName(len(headers))
+	for i, h := range headers {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		f.SetCellValue(sheet, col+"4", h)
+	}
+	f.SetCellStyle(sheet, "A4", lastCol+"4", headerStyle)
+
+	row := 5
+	for _, sec := range st.Sections {
+		for _, line := range sec.Lines {
+			f.SetCellValue(sheet, fmt.Sprintf("A%d", row), strings.Repeat("  ", line.Level-1)+line.Name)
+			f.SetCellValue(sheet, fmt.Sprintf("B%d", row), line.Code)
+			f.SetCellValue(sheet, fmt.Sprintf("C%d", row), line.Amount)
+			if line.Compare != nil {
+				f.SetCellValue(sheet, fmt.Sprintf("D%d", row), *line.Compare)
+				f.SetCellValue(sheet, fmt.Sprintf("E%d", row), *line.Change)
+			}
+			if line.ChangePct != nil {
+				f.SetCellValue(sheet, fmt.Sprintf("F%d", row), *line.ChangePct)
+			}
+			f.SetCellStyle(sheet, fmt.Sprintf("C%d", row), fmt.Sprintf("E%d", row), amountStyle)
+			row++
+		}
+		f.SetCellValue(sheet, fmt.Sprintf("A%d", row), sec.Title+"合计")
+		f.SetCellValue(sheet, fmt.Sprintf("C%d", row), sec.Total)
+		if sec.CompareTotal != nil {
+			f.SetCellValue(sheet, fmt.Sprintf("D%d", row), *sec.CompareTotal)
+			f.SetCellValue(sheet, fmt.Sprintf("E%d", row), roundAmount(sec.Total-*sec.CompareTotal))
+		}
+		f.SetCellStyle(sheet, fmt.Sprintf("A%d", row), fmt.Sprintf("%s%d", lastCol, row), sectionStyle)
+		row += 2
+	}
+
+	f.SetColWidth(sheet, "A", "A", 36)
+	f.SetColWidth(sheet, "B", "B", 12)
+	f.SetColWidth(sheet, "C", lastCol, 16)
+
+	filename := fmt.Sprintf("%s_%s.xlsx", st.ReportType, st.Period)
+	if st.CompareMode != "" {
+		filename = fmt.Sprintf("%s_%s_%s.xlsx", st.ReportType, st.Period, st.CompareMode)
+	}
+	return f, filename, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/bitfantasy/nimo/internal/config"
 	erpEntity "github.com/bitfantasy/nimo/internal/erp/entity"
@@ -69,6 +70,12 @@ func main() {
 	services := erpService.NewServices(repos, db)
 	handlers := erpHandler.NewHandlers(services)
 
+	// 库存对账定时任务
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	services.Inventory.StartReconcileScheduler(schedulerCtx, 24*time.Hour)
+	services.CycleCount.StartScheduler(schedulerCtx, 24*time.Hour)
+
 	// 确定端口
 	port := os.Getenv("ERP_PORT")
 	if port == "" {
@@ -129,6 +136,18 @@ func main() {
 			suppliers.PUT("/:id/score", handlers.Supplier.UpdateScore)
 		}
 
+		// 仓库与库位
+		warehouses := v1.Group("/warehouses")
+		{
+			warehouses.GET("", handlers.Warehouse.List)
+			warehouses.GET("/:id/layout", handlers.Warehouse.GetLayout)
+			warehouses.POST("/:id/zones", handlers.Warehouse.CreateZone)
+			warehouses.POST("/:id/zones/:zone_id/locations", handlers.Warehouse.CreateLocation)
+			warehouses.GET("/:id/putaway-rules", handlers.Warehouse.ListPutawayRules)
+			warehouses.POST("/:id/putaway-rules", handlers.Warehouse.CreatePutawayRule)
+			warehouses.DELETE("/:id/putaway-rules/:rule_id", handlers.Warehouse.DeletePutawayRule)
+		}
+
 		// 采购需求
 		prs := v1.Group("/purchase-requisitions")
 		{
@@ -160,6 +179,35 @@ func main() {
 			inventory.POST("/adjust", handlers.Inventory.Adjust)
 			inventory.GET("/alerts", handlers.Inventory.Alerts)
 			inventory.GET("/transactions", handlers.Inventory.Transactions)
+			inventory.GET("/reservations", handlers.Inventory.ListReservations)
+			inventory.POST("/reservations", handlers.Inventory.Reserve)
+			inventory.POST("/reservations/:id/release", handlers.Inventory.ReleaseReservation)
+			inventory.POST("/reconcile", handlers.Inventory.Reconcile)
+			inventory.GET("/reconciliations", handlers.Inventory.ListReconciliations)
+			inventory.GET("/migrations", handlers.Inventory.ListMigrations)
+			inventory.GET("/lots", handlers.Warehouse.ListLots)
+			inventory.POST("/putaway-suggestions", handlers.Warehouse.SuggestPutaway)
+			inventory.GET("/pick-suggestions", handlers.Warehouse.SuggestPick)
+			inventory.POST("/bin-moves", handlers.Warehouse.MoveBin)
+			inventory.GET("/abc", handlers.CycleCount.ListABC)
+			inventory.POST("/abc/classify", handlers.CycleCount.ClassifyABC)
+		}
+
+		// 循环盘点
+		cycleCounts := v1.Group("/cycle-counts")
+		{
+			cycleCounts.GET("", handlers.CycleCount.List)
+			cycleCounts.POST("", handlers.CycleCount.Create)
+			cycleCounts.POST("/generate", handlers.CycleCount.Generate)
+			cycleCounts.GET("/policies", handlers.CycleCount.ListPolicies)
+			cycleCounts.PUT("/policies", handlers.CycleCount.SavePolicies)
+			cycleCounts.GET("/accuracy", handlers.CycleCount.Accuracy)
+			cycleCounts.GET("/:id", handlers.CycleCount.Get)
+			cycleCounts.POST("/:id/start", handlers.CycleCount.Start)
+			cycleCounts.POST("/:id/results", handlers.CycleCount.SubmitResults)
+			cycleCounts.POST("/:id/approve", handlers.CycleCount.Approve)
+			cycleCounts.POST("/:id/post", handlers.CycleCount.Post)
+			cycleCounts.POST("/:id/cancel", handlers.CycleCount.Cancel)
 		}
 
 		// MRP
@@ -168,19 +216,60 @@ func main() {
 			mrp.POST("/run", handlers.MRP.Run)
 			mrp.GET("/result", handlers.MRP.GetResult)
 			mrp.GET("/runs", handlers.MRP.ListRuns)
+			mrp.GET("/compare", handlers.MRP.CompareRuns)
 			mrp.POST("/apply", handlers.MRP.Apply)
+			mrp.GET("/results/:id/pegging", handlers.MRP.GetPegging)
+			mrp.GET("/planning-params", handlers.MRP.ListPlanningParams)
+			mrp.PUT("/planning-params", handlers.MRP.SavePlanningParams)
+		}
+
+		// 主生产计划
+		mps := v1.Group("/mps")
+		{
+			mps.GET("/forecasts", handlers.MPS.ListForecasts)
+			mps.POST("/forecasts", handlers.MPS.SaveForecast)
+			mps.POST("/forecasts/import", handlers.MPS.ImportForecasts)
+			mps.DELETE("/forecasts/:id", handlers.MPS.DeleteForecast)
+			mps.POST("/generate", handlers.MPS.Generate)
+			mps.GET("/entries", handlers.MPS.ListEntries)
+			mps.PUT("/entries/:id/firm", handlers.MPS.FirmEntry)
 		}
 
 		// 生产管理
 		workOrders := v1.Group("/work-orders")
 		{
 			workOrders.GET("", handlers.Manufacturing.List)
+			workOrders.GET("/issue-methods", handlers.Manufacturing.ListIssueMethods)
+			workOrders.PUT("/issue-methods", handlers.Manufacturing.SaveIssueMethod)
 			workOrders.POST("", handlers.Manufacturing.Create)
 			workOrders.GET("/:id", handlers.Manufacturing.Get)
 			workOrders.POST("/:id/release", handlers.Manufacturing.Release)
+			workOrders.GET("/:id/pick-list", handlers.Warehouse.WorkOrderPickList)
 			workOrders.POST("/:id/pick", handlers.Manufacturing.Pick)
 			workOrders.POST("/:id/report", handlers.Manufacturing.Report)
 			workOrders.POST("/:id/complete", handlers.Manufacturing.Complete)
+			workOrders.POST("/:id/operations/:op_id/report", handlers.Manufacturing.ReportOperation)
+			workOrders.GET("/:id/operation-reports", handlers.Manufacturing.ListOperationReports)
+			workOrders.PUT("/:id/materials/:material_id/issue-method", handlers.Manufacturing.SetMaterialIssueMethod)
+			workOrders.POST("/:id/return", handlers.Manufacturing.ReturnMaterials)
+			workOrders.GET("/:id/closeout-report", handlers.Manufacturing.CloseoutReport)
+			workOrders.POST("/:id/close", handlers.Manufacturing.Close)
+		}
+
+		// 工作中心
+		workCenters := v1.Group("/work-centers")
+		{
+			workCenters.GET("", handlers.WorkCenter.List)
+			workCenters.POST("", handlers.WorkCenter.Create)
+			workCenters.GET("/load", handlers.WorkCenter.Load)
+			workCenters.PUT("/:id", handlers.WorkCenter.Update)
+		}
+
+		// 批次/序列号追溯
+		trace := v1.Group("/trace")
+		{
+			trace.GET("/backward", handlers.Genealogy.TraceBackward)
+			trace.GET("/forward", handlers.Genealogy.TraceForward)
 		}
 
 		// 客户管理
@@ -189,6 +278,8 @@ func main() {
 			customers.GET("", handlers.Sales.ListCustomers)
 			customers.POST("", handlers.Sales.CreateCustomer)
 			customers.GET("/:id", handlers.Sales.GetCustomer)
+			customers.GET("/:id/credit", handlers.Sales.GetCustomerCredit)
+			customers.PUT("/:id/credit-hold", handlers.Sales.SetCustomerCreditHold)
 			customers.DELETE("/:id", handlers.Sales.DeleteCustomer)
 		}
 
@@ -197,8 +288,12 @@ func main() {
 		{
 			salesOrders.GET("", handlers.Sales.ListSOs)
 			salesOrders.POST("", handlers.Sales.CreateSO)
+			salesOrders.GET("/atp", handlers.Sales.CheckATP)
 			salesOrders.GET("/:id", handlers.Sales.GetSO)
 			salesOrders.POST("/:id/confirm", handlers.Sales.ConfirmSO)
+			salesOrders.POST("/:id/credit-release", handlers.Sales.ReleaseCreditHold)
+			salesOrders.POST("/:id/promise", handlers.Sales.PromiseSO)
+			salesOrders.GET("/:id/pick-list", handlers.Warehouse.SalesOrderPickList)
 			salesOrders.POST("/:id/ship", handlers.Sales.ShipSO)
 			salesOrders.POST("/:id/cancel", handlers.Sales.CancelSO)
 		}
@@ -212,6 +307,42 @@ func main() {
 			serviceOrders.POST("/:id/assign", handlers.Sales.AssignServiceOrder)
 			serviceOrders.POST("/:id/complete", handlers.Sales.CompleteServiceOrder)
 		}
+
+		// 退货授权
+		rmas := v1.Group("/rmas")
+		{
+			rmas.GET("", handlers.RMA.List)
+			rmas.POST("", handlers.RMA.Issue)
+			rmas.GET("/warranty", handlers.RMA.Warranty)
+			rmas.GET("/trace", handlers.RMA.Trace)
+			rmas.GET("/warranty-policies", handlers.RMA.ListWarrantyPolicies)
+			rmas.PUT("/warranty-policies", handlers.RMA.SaveWarrantyPolicy)
+			rmas.GET("/:id", handlers.RMA.Get)
+			rmas.POST("/:id/receive", handlers.RMA.Receive)
+			rmas.POST("/:id/triage", handlers.RMA.Triage)
+			rmas.POST("/:id/close", handlers.RMA.Close)
+		}
+
+		// 故障代码
+		failureCodes := v1.Group("/failure-codes")
+		{
+			failureCodes.GET("", handlers.Warranty.ListFailureCodes)
+			failureCodes.POST("", handlers.Warranty.CreateFailureCode)
+			failureCodes.PUT("/:id", handlers.Warranty.UpdateFailureCode)
+		}
+
+		// 保修索赔与现场故障分析
+		warrantyClaims := v1.Group("/warranty-claims")
+		{
+			warrantyClaims.GET("", handlers.Warranty.ListClaims)
+			warrantyClaims.POST("", handlers.Warranty.CreateClaim)
+			warrantyClaims.GET("/analytics/return-rate", handlers.Warranty.FieldReturnRates)
+			warrantyClaims.GET("/analytics/failure-pareto", handlers.Warranty.FailurePareto)
+			warrantyClaims.GET("/:id", handlers.Warranty.GetClaim)
+			warrantyClaims.POST("/:id/attribute", handlers.Warranty.AttributeClaim)
+			warrantyClaims.POST("/:id/review", handlers.Warranty.ReviewClaim)
+			warrantyClaims.POST("/:id/chargeback", handlers.Warranty.Chargeback)
+		}
 	}
 
 	// 创建HTTP服务器
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -261,14 +262,61 @@ func (s *MCPServer) GetTools() []Tool {
 		},
 
 		// ==================== MRP ====================
+		{
+			Name:        "erp_putaway_suggestion",
+			Description: "按上架规则和库位容量给出上架库位建议",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"material_id":    {Type: "string", Description: "物料ID"},
+				"warehouse_id":   {Type: "string", Description: "仓库ID"},
+				"inventory_type": {Type: "string", Description: "库存类型 RAW/WIP/FG/SPARE"},
+				"quantity":       {Type: "number", Description: "数量"},
+			}, Required: []string{"material_id", "warehouse_id", "quantity"}},
+		},
+		{
+			Name:        "erp_pick_list",
+			Description: "生成工单或销售订单的拣货单（按库区路径顺序，FEFO/FIFO）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"reference_type": {Type: "string", Description: "WO=工单, SO=销售订单"},
+				"id":             {Type: "string", Description: "工单或销售订单ID"},
+				"warehouse_id":   {Type: "string", Description: "仓库ID"},
+				"strategy":       {Type: "string", Description: "FEFO（默认）或 FIFO"},
+			}, Required: []string{"reference_type", "id", "warehouse_id"}},
+		},
+		{
+			Name:        "erp_move_bin",
+			Description: "库位间移库",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"material_id":      {Type: "string", Description: "物料ID"},
+				"warehouse_id":     {Type: "string", Description: "仓库ID"},
+				"from_location_id": {Type: "string", Description: "源库位ID"},
+				"to_location_id":   {Type: "string", Description: "目标库位ID"},
+				"batch_no":         {Type: "string", Description: "批次号（可选）"},
+				"quantity":         {Type: "number", Description: "数量"},
+			}, Required: []string{"material_id", "warehouse_id", "from_location_id", "to_location_id", "quantity"}},
+		},
+		{
+			Name:        "erp_generate_cycle_counts",
+			Description: "按ABC分类排期生成到期的循环盘点任务（每仓库一张）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{}},
+		},
+		{
+			Name:        "erp_inventory_accuracy",
+			Description: "查询各仓库库存准确率（基于已过账的循环盘点）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"from": {Type: "string", Description: "开始日期 YYYY-MM-DD，默认30天前"},
+				"to":   {Type: "string", Description: "结束日期 YYYY-MM-DD，默认今天"},
+			}},
+		},
 		{
 			Name:        "erp_run_mrp",
-			Description: "执行MRP计算（物料需求计划）",
+			Description: "执行MRP计算（物料需求计划，按时间段净算）",
 			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
-				"product_id": {Type: "string", Description: "产品ID"},
-				"quantity":   {Type: "number", Description: "需求数量"},
-				"due_date":   {Type: "string", Description: "需求日期 YYYY-MM-DD"},
-			}, Required: []string{"product_id", "quantity", "due_date"}},
+				"product_id":       {Type: "string", Description: "产品ID，空=全部产品"},
+				"planning_horizon": {Type: "number", Description: "计划范围（天），默认30"},
+				"bucket_type":      {Type: "string", Description: "时间段: DAY/WEEK，默认WEEK"},
+				"run_type":         {Type: "string", Description: "运行类型: REGENERATIVE(全重排)/NET_CHANGE(净变更)/SIMULATION(模拟，不可应用)"},
+				"scenario":         {Type: "object", Description: "模拟场景: demand_overrides[{so_id,so_item_id,product_id,factor,quantity,due_date}], lead_time_overrides{物料ID:天}, stock_overrides{物料ID:数量}"},
+			}},
 		},
 		{
 			Name:        "erp_get_mrp_result",
@@ -277,6 +325,13 @@ func (s *MCPServer) GetTools() []Tool {
 				"run_id": {Type: "string", Description: "MRP运行ID"},
 			}, Required: []string{"run_id"}},
 		},
+		{
+			Name:        "erp_get_mrp_pegging",
+			Description: "查询MRP计划订单的需求追溯（来源销售订单行）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"result_id": {Type: "string", Description: "MRP结果ID"},
+			}, Required: []string{"result_id"}},
+		},
 		{
 			Name:        "erp_apply_mrp",
 			Description: "确认MRP结果（自动创建采购需求和工单）",
@@ -289,6 +344,30 @@ func (s *MCPServer) GetTools() []Tool {
 			Description: "查询MRP运行历史",
 			InputSchema: InputSchema{Type: "object"},
 		},
+		{
+			Name:        "erp_compare_mrp_runs",
+			Description: "对比两次MRP运行（如模拟与实际）的计划订单差异",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"run_a":        {Type: "string", Description: "基准运行ID"},
+				"run_b":        {Type: "string", Description: "对比运行ID"},
+				"changed_only": {Type: "boolean", Description: "仅显示有差异的物料"},
+			}, Required: []string{"run_a", "run_b"}},
+		},
+		{
+			Name:        "erp_generate_mps",
+			Description: "按需求预测和销售订单生成主生产计划（订单冲减预测，时界内计划冻结）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"product_id": {Type: "string", Description: "产品ID（可选，空=全部）"},
+				"horizon":    {Type: "number", Description: "计划范围天数（可选，默认90）"},
+			}},
+		},
+		{
+			Name:        "erp_list_mps",
+			Description: "查询主生产计划",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"product_id": {Type: "string", Description: "产品ID（可选）"},
+			}},
+		},
 
 		// ==================== 生产管理 ====================
 		{
@@ -333,6 +412,45 @@ func (s *MCPServer) GetTools() []Tool {
 				"notes":             {Type: "string", Description: "备注"},
 			}, Required: []string{"id", "quantity_completed"}},
 		},
+		{
+			Name:        "erp_report_operation",
+			Description: "工序报工（良品/报废/返工数量及人工工时）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"id":            {Type: "string", Description: "工单ID"},
+				"operation_id":  {Type: "string", Description: "工序ID"},
+				"good_qty":      {Type: "number", Description: "良品数量"},
+				"scrap_qty":     {Type: "number", Description: "报废数量"},
+				"rework_qty":    {Type: "number", Description: "返工数量"},
+				"setup_minutes": {Type: "number", Description: "准备工时（分钟）"},
+				"labor_minutes": {Type: "number", Description: "人工工时（分钟）"},
+				"notes":         {Type: "string", Description: "备注"},
+			}, Required: []string{"id", "operation_id"}},
+		},
+		{
+			Name:        "erp_work_order_variance",
+			Description: "查询工单结案差异报告（物料用量、报废、产出差异）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"id": {Type: "string", Description: "工单ID"},
+			}, Required: []string{"id"}},
+		},
+		{
+			Name:        "erp_close_work_order",
+			Description: "工单结案（可自动退回剩余组件）",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"id":               {Type: "string", Description: "工单ID"},
+				"return_leftovers": {Type: "boolean", Description: "是否退回超出理论用量的剩余组件"},
+				"warehouse_id":     {Type: "string", Description: "退料仓库ID，空=领料仓库"},
+			}, Required: []string{"id"}},
+		},
+		{
+			Name:        "erp_work_center_load",
+			Description: "查询工作中心产能负荷与瓶颈",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"from":           {Type: "string", Description: "开始日期 YYYY-MM-DD，默认今天"},
+				"days":           {Type: "number", Description: "天数，默认28"},
+				"work_center_id": {Type: "string", Description: "工作中心ID，空=全部"},
+			}},
+		},
 		{
 			Name:        "erp_complete_work_order",
 			Description: "完工（结束生产）",
@@ -341,6 +459,26 @@ func (s *MCPServer) GetTools() []Tool {
 			}, Required: []string{"id"}},
 		},
 
+		// ==================== 批次追溯 ====================
+		{
+			Name:        "erp_trace_backward",
+			Description: "反向追溯：由成品序列号或批次查到组件批次、供应商和来料检验",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"serial_no":   {Type: "string", Description: "成品序列号"},
+				"material_id": {Type: "string", Description: "物料ID（按批次追溯时使用）"},
+				"lot_no":      {Type: "string", Description: "批次号"},
+			}},
+		},
+		{
+			Name:        "erp_trace_forward",
+			Description: "正向追溯：由可疑供应商批次查到受影响的成品序列号、发货和客户",
+			InputSchema: InputSchema{Type: "object", Properties: map[string]Property{
+				"material_id": {Type: "string", Description: "物料ID"},
+				"lot_no":      {Type: "string", Description: "批次号"},
+				"supplier_id": {Type: "string", Description: "供应商ID（不指定批次时追溯该供应商全部批次）"},
+			}},
+		},
+
 		// ==================== 销售管理 ====================
 		{
 			Name:        "erp_list_sales_orders",
@@ -519,6 +657,41 @@ func (s *MCPServer) CallTool(name string, args map[string]interface{}) (string,
 		return string(resp), err
 
 	// ==================== MRP ====================
+	case "erp_putaway_suggestion":
+		resp, err := s.erp.Request("POST", apiPrefix+"/inventory/putaway-suggestions", args)
+		return string(resp), err
+
+	case "erp_pick_list":
+		group := "/work-orders/"
+		if refType, _ := args["reference_type"].(string); refType == "SO" {
+			group = "/sales-orders/"
+		}
+		query := url.Values{}
+		query.Set("warehouse_id", args["warehouse_id"].(string))
+		if strategy, ok := args["strategy"].(string); ok && strategy != "" {
+			query.Set("strategy", strategy)
+		}
+		resp, err := s.erp.Request("GET", apiPrefix+group+args["id"].(string)+"/pick-list?"+query.Encode(), nil)
+		return string(resp), err
+
+	case "erp_move_bin":
+		resp, err := s.erp.Request("POST", apiPrefix+"/inventory/bin-moves", args)
+		return string(resp), err
+
+	case "erp_generate_cycle_counts":
+		resp, err := s.erp.Request("POST", apiPrefix+"/cycle-counts/generate", nil)
+		return string(resp), err
+
+	case "erp_inventory_accuracy":
+		query := url.Values{}
+		for _, k := range []string{"from", "to"} {
+			if v, ok := args[k].(string); ok && v != "" {
+				query.Set(k, v)
+			}
+		}
+		resp, err := s.erp.Request("GET", apiPrefix+"/cycle-counts/accuracy?"+query.Encode(), nil)
+		return string(resp), err
+
 	case "erp_run_mrp":
 		resp, err := s.erp.Request("POST", apiPrefix+"/mrp/run", args)
 		return string(resp), err
@@ -528,6 +701,11 @@ func (s *MCPServer) CallTool(name string, args map[string]interface{}) (string,
 		resp, err := s.erp.Request("GET", apiPrefix+"/mrp/result?run_id="+runID, nil)
 		return string(resp), err
 
+	case "erp_get_mrp_pegging":
+		resultID := args["result_id"].(string)
+		resp, err := s.erp.Request("GET", apiPrefix+"/mrp/results/"+resultID+"/pegging", nil)
+		return string(resp), err
+
 	case "erp_apply_mrp":
 		resp, err := s.erp.Request("POST", apiPrefix+"/mrp/apply", args)
 		return string(resp), err
@@ -536,6 +714,26 @@ func (s *MCPServer) CallTool(name string, args map[string]interface{}) (string,
 		resp, err := s.erp.Request("GET", apiPrefix+"/mrp/runs", nil)
 		return string(resp), err
 
+	case "erp_compare_mrp_runs":
+		path := apiPrefix + "/mrp/compare?run_a=" + args["run_a"].(string) + "&run_b=" + args["run_b"].(string)
+		if changedOnly, ok := args["changed_only"].(bool); ok && changedOnly {
+			path += "&changed_only=true"
+		}
+		resp, err := s.erp.Request("GET", path, nil)
+		return string(resp), err
+
+	case "erp_generate_mps":
+		resp, err := s.erp.Request("POST", apiPrefix+"/mps/generate", args)
+		return string(resp), err
+
+	case "erp_list_mps":
+		path := apiPrefix + "/mps/entries"
+		if productID, ok := args["product_id"].(string); ok && productID != "" {
+			path += "?product_id=" + productID
+		}
+		resp, err := s.erp.Request("GET", path, nil)
+		return string(resp), err
+
 	// ==================== 生产管理 ====================
 	case "erp_list_work_orders":
 		path := apiPrefix + "/work-orders"
@@ -571,11 +769,64 @@ func (s *MCPServer) CallTool(name string, args map[string]interface{}) (string,
 		resp, err := s.erp.Request("POST", apiPrefix+"/work-orders/"+id+"/report", body)
 		return string(resp), err
 
+	case "erp_report_operation":
+		id := args["id"].(string)
+		opID := args["operation_id"].(string)
+		delete(args, "id")
+		delete(args, "operation_id")
+		resp, err := s.erp.Request("POST", apiPrefix+"/work-orders/"+id+"/operations/"+opID+"/report", args)
+		return string(resp), err
+
+	case "erp_work_order_variance":
+		id := args["id"].(string)
+		resp, err := s.erp.Request("GET", apiPrefix+"/work-orders/"+id+"/closeout-report", nil)
+		return string(resp), err
+
+	case "erp_close_work_order":
+		id := args["id"].(string)
+		delete(args, "id")
+		resp, err := s.erp.Request("POST", apiPrefix+"/work-orders/"+id+"/close", args)
+		return string(resp), err
+
+	case "erp_work_center_load":
+		query := url.Values{}
+		for _, k := range []string{"from", "work_center_id"} {
+			if v, ok := args[k].(string); ok && v != "" {
+				query.Set(k, v)
+			}
+		}
+		if days, ok := args["days"].(float64); ok && days > 0 {
+			query.Set("days", fmt.Sprintf("%d", int(days)))
+		}
+		resp, err := s.erp.Request("GET", apiPrefix+"/work-centers/load?"+query.Encode(), nil)
+		return string(resp), err
+
 	case "erp_complete_work_order":
 		id := args["id"].(string)
 		resp, err := s.erp.Request("POST", apiPrefix+"/work-orders/"+id+"/complete", nil)
 		return string(resp), err
 
+	// ==================== 批次追溯 ====================
+	case "erp_trace_backward":
+		query := url.Values{}
+		for _, k := range []string{"serial_no", "material_id", "lot_no"} {
+			if v, ok := args[k].(string); ok && v != "" {
+				query.Set(k, v)
+			}
+		}
+		resp, err := s.erp.Request("GET", apiPrefix+"/trace/backward?"+query.Encode(), nil)
+		return string(resp), err
+
+	case "erp_trace_forward":
+		query := url.Values{}
+		for _, k := range []string{"material_id", "lot_no", "supplier_id"} {
+			if v, ok := args[k].(string); ok && v != "" {
+				query.Set(k, v)
+			}
+		}
+		resp, err := s.erp.Request("GET", apiPrefix+"/trace/forward?"+query.Encode(), nil)
+		return string(resp), err
+
 	// ==================== 销售管理 ====================
 	case "erp_list_sales_orders":
 		path := apiPrefix + "/sales-orders"
@@ -9,8 +9,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/bitfantasy/nimo/internal/config"
+	erpentity "github.com/bitfantasy/nimo/internal/erp/entity"
+	erprepo "github.com/bitfantasy/nimo/internal/erp/repository"
+	erpsvc "github.com/bitfantasy/nimo/internal/erp/service"
 	"github.com/bitfantasy/nimo/internal/middleware"
 	"github.com/bitfantasy/nimo/internal/plm/entity"
 	"github.com/bitfantasy/nimo/internal/plm/handler"
@@ -769,10 +773,33 @@ func main() {
 		&srmentity.InspectionItem{},
 		&srmentity.InventoryRecord{},
 		&srmentity.InventoryTransaction{},
+		&srmentity.DefectCode{},
+		&srmentity.SettlementLine{},
+		&srmentity.SettlementInvoiceLine{},
+		&srmentity.MatchTolerance{},
+		&srmentity.ExchangeRate{},
+		&srmentity.Scorecard{},
+		&srmentity.ScorecardKPI{},
+		&srmentity.EvaluationKPIResult{},
+		&srmentity.ScorecardRun{},
+		&srmentity.SupplierQualification{},
+		&srmentity.SupplierCertificate{},
 	); err != nil {
 		zapLogger.Warn("AutoMigrate SRM tables warning", zap.Error(err))
 	}
 
+	// 统一库存台账：SRM 出入库与 ERP 共用 erp_inventory 系列表
+	if err := db.AutoMigrate(
+		&erpentity.Warehouse{},
+		&erpentity.Inventory{},
+		&erpentity.InventoryTransaction{},
+		&erpentity.InventoryReservation{},
+		&erpentity.InventoryReconciliation{},
+		&erpentity.InventoryMigration{},
+	); err != nil {
+		zapLogger.Warn("AutoMigrate inventory ledger tables warning", zap.Error(err))
+	}
+
 	// V14: PRItem增加物料分类/展示增强/治具字段
 	v14SQL := []string{
 		"ALTER TABLE srm_pr_items ADD COLUMN IF NOT EXISTS source_bom_type VARCHAR(20)",
@@ -851,14 +878,79 @@ func main() {
 			zapLogger.Warn("V17 migration warning", zap.String("sql", sql), zap.Error(err))
 		}
 	}
-	zapLogger.Info("SRM database migration completed (including V17)")
+	// V18: 缺陷代码关联检验行项与8D
+	v18SQL := []string{
+		"ALTER TABLE srm_inspection_items ADD COLUMN IF NOT EXISTS defect_code_id VARCHAR(32)",
+		"ALTER TABLE srm_corrective_actions ADD COLUMN IF NOT EXISTS defect_code_id VARCHAR(32)",
+		"CREATE INDEX IF NOT EXISTS idx_srm_inspection_items_defect_code ON srm_inspection_items(defect_code_id)",
+		"CREATE INDEX IF NOT EXISTS idx_srm_corrective_actions_defect_code ON srm_corrective_actions(defect_code_id)",
+	}
+	for _, sql := range v18SQL {
+		if err := db.Exec(sql).Error; err != nil {
+			zapLogger.Warn("V18 migration warning", zap.String("sql", sql), zap.Error(err))
+		}
+	}
+	// V19: 三单匹配 — 入库流水记录PO行项，差异关联对账行
+	v19SQL := []string{
+		"ALTER TABLE srm_inventory_transactions ADD COLUMN IF NOT EXISTS po_item_id VARCHAR(32)",
+		"CREATE INDEX IF NOT EXISTS idx_srm_inventory_transactions_po_item_id ON srm_inventory_transactions(po_item_id)",
+		"ALTER TABLE srm_settlement_disputes ADD COLUMN IF NOT EXISTS settlement_line_id VARCHAR(32)",
+		"ALTER TABLE srm_settlement_disputes ADD COLUMN IF NOT EXISTS source VARCHAR(20) DEFAULT 'manual'",
+		"CREATE INDEX IF NOT EXISTS idx_srm_settlement_disputes_settlement_line_id ON srm_settlement_disputes(settlement_line_id)",
+	}
+	for _, sql := range v19SQL {
+		if err := db.Exec(sql).Error; err != nil {
+			zapLogger.Warn("V19 migration warning", zap.String("sql", sql), zap.Error(err))
+		}
+	}
+	// V20: 多币种 — PO审批锁定汇率，对账单本位币金额与汇兑损益
+	v20SQL := []string{
+		"ALTER TABLE srm_purchase_orders ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(18,8)",
+		"ALTER TABLE srm_purchase_orders ADD COLUMN IF NOT EXISTS rate_locked_at TIMESTAMPTZ",
+		"ALTER TABLE srm_settlements ADD COLUMN IF NOT EXISTS functional_currency VARCHAR(10) DEFAULT 'CNY'",
+		"ALTER TABLE srm_settlements ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(18,8)",
+		"ALTER TABLE srm_settlements ADD COLUMN IF NOT EXISTS functional_amount DECIMAL(15,2)",
+		"ALTER TABLE srm_settlements ADD COLUMN IF NOT EXISTS booked_functional_amount DECIMAL(15,2)",
+		"ALTER TABLE srm_settlements ADD COLUMN IF NOT EXISTS fx_diff DECIMAL(15,2)",
+		"ALTER TABLE srm_settlement_lines ADD COLUMN IF NOT EXISTS locked_rate DECIMAL(18,8) DEFAULT 1",
+		"ALTER TABLE srm_settlement_lines ADD COLUMN IF NOT EXISTS booked_amount DECIMAL(15,2)",
+		"UPDATE srm_purchase_orders SET exchange_rate = 1, rate_locked_at = approved_at WHERE exchange_rate IS NULL AND COALESCE(currency, 'CNY') = 'CNY' AND approved_at IS NOT NULL",
+	}
+	for _, sql := range v20SQL {
+		if err := db.Exec(sql).Error; err != nil {
+			zapLogger.Warn("V20 migration warning", zap.String("sql", sql), zap.Error(err))
+		}
+	}
+	// V21: 评分卡 — 评估单关联评分卡与上期等级
+	v21SQL := []string{
+		"ALTER TABLE srm_supplier_evaluations ADD COLUMN IF NOT EXISTS scorecard_id VARCHAR(32)",
+		"ALTER TABLE srm_supplier_evaluations ADD COLUMN IF NOT EXISTS prev_grade VARCHAR(10)",
+		"CREATE INDEX IF NOT EXISTS idx_srm_supplier_evaluations_period ON srm_supplier_evaluations(supplier_id, period, eval_type)",
+	}
+	for _, sql := range v21SQL {
+		if err := db.Exec(sql).Error; err != nil {
+			zapLogger.Warn("V21 migration warning", zap.String("sql", sql), zap.Error(err))
+		}
+	}
+	// V22: 供应商准入 — 存量已启用供应商视为已准入
+	v22SQL := []string{
+		"UPDATE srm_suppliers SET level = 'qualified' WHERE status = 'active' AND (level IS NULL OR level = '' OR level = 'potential')",
+		"CREATE INDEX IF NOT EXISTS idx_srm_supplier_certificates_supplier_type ON srm_supplier_certificates(supplier_id, cert_type)",
+	}
+	for _, sql := range v22SQL {
+		if err := db.Exec(sql).Error; err != nil {
+			zapLogger.Warn("V22 migration warning", zap.String("sql", sql), zap.Error(err))
+		}
+	}
+	zapLogger.Info("SRM database migration completed (including V22)")
 
 	// SRM仓库和服务
 	srmRepos := srmrepo.NewRepositories(db)
 	srmSupplierSvc := srmsvc.NewSupplierService(srmRepos.Supplier)
 	srmProcurementSvc := srmsvc.NewProcurementService(srmRepos.PR, srmRepos.PO, db)
 	srmInspectionSvc := srmsvc.NewInspectionService(srmRepos.Inspection, srmRepos.PR)
-	srmInventorySvc := srmsvc.NewInventoryService(srmRepos.Inventory)
+	stockSvc := erpsvc.NewInventoryService(erprepo.NewInventoryRepository(db))
+	srmInventorySvc := srmsvc.NewInventoryService(srmRepos.Inventory, stockSvc)
 	srmInspectionSvc.SetPORepo(srmRepos.PO)
 	srmInspectionSvc.SetInventoryService(srmInventorySvc)
 	srmDashboardSvc := srmsvc.NewDashboardService(db)
@@ -874,14 +966,49 @@ func main() {
 	srmHandlers := srmhandler.NewHandlers(srmSupplierSvc, srmProcurementSvc, srmInspectionSvc, srmDashboardSvc, srmRepos.PO, srmProjectSvc, srmSettlementSvc, srmCorrectiveActionSvc, srmEvaluationSvc, srmEquipmentSvc, srmRFQSvc, srmPRItemSvc, srmSamplingSvc)
 	srmHandlers.Inventory = srmhandler.NewInventoryHandler(srmInventorySvc)
 
+	// 缺陷代码与质量分析
+	srmDefectSvc := srmsvc.NewDefectService(srmRepos.Defect)
+	srmQualitySvc := srmsvc.NewQualityAnalyticsService(srmRepos.Defect)
+	srmInspectionSvc.SetDefectService(srmDefectSvc)
+	srmCorrectiveActionSvc.SetDefectService(srmDefectSvc)
+	srmEvaluationSvc.SetQualityAnalyticsService(srmQualitySvc)
+	srmHandlers.Quality = srmhandler.NewQualityHandler(srmDefectSvc, srmQualitySvc)
+	srmExchangeRateSvc := srmsvc.NewExchangeRateService(srmRepos.ExchangeRate)
+	srmProcurementSvc.SetExchangeRateService(srmExchangeRateSvc)
+	srmSettlementSvc.SetExchangeRateService(srmExchangeRateSvc)
+	srmHandlers.ExchangeRate = srmhandler.NewExchangeRateHandler(srmExchangeRateSvc)
+	srmScorecardSvc := srmsvc.NewScorecardService(srmRepos.Scorecard, srmRepos.Evaluation, srmRepos.Supplier)
+	srmScorecardSvc.SetQualityAnalyticsService(srmQualitySvc)
+	srmScorecardSvc.SetActivityLogRepo(srmRepos.ActivityLog)
+	srmEvaluationSvc.SetScorecardService(srmScorecardSvc)
+	srmHandlers.Scorecard = srmhandler.NewScorecardHandler(srmScorecardSvc)
+	srmQualificationSvc := srmsvc.NewQualificationService(srmRepos.Qualification, srmRepos.Supplier, srmRepos.Sampling)
+	srmQualificationSvc.SetActivityLogRepo(srmRepos.ActivityLog)
+	srmSupplierSvc.SetQualificationRepo(srmRepos.Qualification)
+	srmSupplierSvc.SetActivityLogRepo(srmRepos.ActivityLog)
+	srmProcurementSvc.SetSupplierRepo(srmRepos.Supplier)
+	srmSamplingSvc.SetQualificationService(srmQualificationSvc)
+	srmHandlers.Qualification = srmhandler.NewQualificationHandler(srmQualificationSvc)
+
 	// SRM→飞书：注入飞书客户端到SRM各服务
 	if feishuWorkflowClient != nil {
 		srmProcurementSvc.SetFeishuClient(feishuWorkflowClient)
 		srmInspectionSvc.SetFeishuClient(feishuWorkflowClient)
 		srmCorrectiveActionSvc.SetFeishuClient(feishuWorkflowClient)
 		srmSamplingSvc.SetFeishuClient(feishuWorkflowClient)
+		srmScorecardSvc.SetFeishuClient(feishuWorkflowClient)
+		srmQualificationSvc.SetFeishuClient(feishuWorkflowClient)
 	}
 
+	// 供应商评分卡周期评估（每小时检查上一个完整月/季度是否已评估）
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	srmScorecardSvc.StartScheduler(schedulerCtx, time.Hour)
+	// 供应商证书到期检查（临期提醒、过期自动暂停）
+	srmQualificationSvc.StartScheduler(schedulerCtx, time.Hour)
+	// 库存结存与流水每日核对
+	srmInventorySvc.StartReconcileScheduler(schedulerCtx, 24*time.Hour)
+
 	// 设置Gin模式
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -1372,8 +1499,25 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 					suppliers.GET("/:id/contacts", srmH.Supplier.ListContacts)
 					suppliers.POST("/:id/contacts", srmH.Supplier.CreateContact)
 					suppliers.DELETE("/:id/contacts/:contactId", srmH.Supplier.DeleteContact)
+					suppliers.POST("/:id/status", srmH.Supplier.ChangeStatus)
+					suppliers.GET("/:id/qualifications", srmH.Qualification.ListQualifications)
+					suppliers.POST("/:id/qualifications", srmH.Qualification.StartQualification)
+					suppliers.GET("/:id/certificates", srmH.Qualification.ListCertificates)
+					suppliers.POST("/:id/certificates", srmH.Qualification.CreateCertificate)
+					suppliers.DELETE("/:id/certificates/:certId", srmH.Qualification.DeleteCertificate)
 				}
 
+				// 供应商准入审核
+				quals := srmGroup.Group("/qualifications")
+				{
+					quals.GET("/:id", srmH.Qualification.GetQualification)
+					quals.POST("/:id/questionnaire", srmH.Qualification.SubmitQuestionnaire)
+					quals.POST("/:id/audit", srmH.Qualification.RecordAudit)
+					quals.POST("/:id/sample", srmH.Qualification.LinkSample)
+					quals.POST("/:id/signoff", srmH.Qualification.SignOff)
+				}
+				srmGroup.GET("/certificates/expiring", srmH.Qualification.ListExpiringCertificates)
+
 				// 采购需求
 				prs := srmGroup.Group("/purchase-requests")
 				{
@@ -1421,6 +1565,9 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 					inventory.POST("/in", srmH.Inventory.StockIn)
 					inventory.POST("/out", srmH.Inventory.StockOut)
 					inventory.POST("/adjust", srmH.Inventory.StockAdjust)
+					inventory.POST("/reconcile", srmH.Inventory.Reconcile)
+					inventory.POST("/migrate", srmH.Inventory.MigrateLegacyStock)
+					inventory.GET("/migrations", srmH.Inventory.ListMigrations)
 				}
 
 				// 看板
@@ -1459,6 +1606,16 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 					delays.POST("/:id/reject", srmH.Project.RejectDelayRequest)
 				}
 
+				// 汇率
+				exchangeRates := srmGroup.Group("/exchange-rates")
+				{
+					exchangeRates.GET("", srmH.ExchangeRate.ListExchangeRates)
+					exchangeRates.GET("/effective", srmH.ExchangeRate.GetEffectiveRate)
+					exchangeRates.POST("", srmH.ExchangeRate.CreateExchangeRate)
+					exchangeRates.POST("/import", srmH.ExchangeRate.ImportExchangeRates)
+					exchangeRates.DELETE("/:id", srmH.ExchangeRate.DeleteExchangeRate)
+				}
+
 				// 对账结算
 				settlements := srmGroup.Group("/settlements")
 				{
@@ -1466,6 +1623,8 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 					settlements.GET("/export", srmH.Settlement.ExportSettlements)
 					settlements.POST("", srmH.Settlement.CreateSettlement)
 					settlements.POST("/generate", srmH.Settlement.GenerateSettlement)
+					settlements.GET("/match-tolerances", srmH.Settlement.ListMatchTolerances)
+					settlements.PUT("/match-tolerances", srmH.Settlement.SaveMatchTolerance)
 					settlements.GET("/:id", srmH.Settlement.GetSettlement)
 					settlements.PUT("/:id", srmH.Settlement.UpdateSettlement)
 					settlements.DELETE("/:id", srmH.Settlement.DeleteSettlement)
@@ -1473,6 +1632,9 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 					settlements.POST("/:id/confirm-supplier", srmH.Settlement.ConfirmBySupplier)
 					settlements.POST("/:id/disputes", srmH.Settlement.AddDispute)
 					settlements.PUT("/:id/disputes/:disputeId", srmH.Settlement.UpdateDispute)
+					settlements.POST("/:id/invoice-lines", srmH.Settlement.AddInvoiceLines)
+					settlements.POST("/:id/match", srmH.Settlement.MatchSettlement)
+					settlements.POST("/:id/lines/:lineId/waive", srmH.Settlement.WaiveLine)
 				}
 
 				// 8D改进
@@ -1487,17 +1649,48 @@ func registerRoutes(r *gin.Engine, h *handler.Handlers, svc *service.Services, c
 					cas.POST("/:id/close", srmH.CorrectiveAction.Close)
 				}
 
+				// 缺陷代码
+				defectCodes := srmGroup.Group("/defect-codes")
+				{
+					defectCodes.GET("", srmH.Quality.ListDefectCodes)
+					defectCodes.GET("/tree", srmH.Quality.GetDefectCodeTree)
+					defectCodes.POST("", srmH.Quality.CreateDefectCode)
+					defectCodes.PUT("/:id", srmH.Quality.UpdateDefectCode)
+					defectCodes.DELETE("/:id", srmH.Quality.DeleteDefectCode)
+				}
+
+				// 质量分析
+				quality := srmGroup.Group("/quality")
+				{
+					quality.GET("/pareto", srmH.Quality.GetPareto)
+					quality.GET("/ppm", srmH.Quality.GetSupplierPPM)
+					quality.GET("/trend", srmH.Quality.GetTrend)
+				}
+
 				// 供应商评价
 				evals := srmGroup.Group("/evaluations")
 				{
 					evals.GET("", srmH.Evaluation.ListEvaluations)
 					evals.POST("", srmH.Evaluation.CreateEvaluation)
 					evals.POST("/auto-generate", srmH.Evaluation.AutoGenerate)
+					evals.POST("/run", srmH.Scorecard.RunPeriod)
+					evals.GET("/runs", srmH.Scorecard.ListRuns)
 					evals.GET("/supplier/:supplierId", srmH.Evaluation.GetSupplierHistory)
 					evals.GET("/:id", srmH.Evaluation.GetEvaluation)
 					evals.PUT("/:id", srmH.Evaluation.UpdateEvaluation)
 					evals.POST("/:id/submit", srmH.Evaluation.Submit)
 					evals.POST("/:id/approve", srmH.Evaluation.Approve)
+					evals.PUT("/:id/kpi-results/:resultId", srmH.Scorecard.UpdateKPIScore)
+				}
+
+				// 评分卡模型
+				scorecards := srmGroup.Group("/scorecards")
+				{
+					scorecards.GET("", srmH.Scorecard.ListScorecards)
+					scorecards.POST("", srmH.Scorecard.CreateScorecard)
+					scorecards.GET("/:id", srmH.Scorecard.GetScorecard)
+					scorecards.PUT("/:id", srmH.Scorecard.UpdateScorecard)
+					scorecards.DELETE("/:id", srmH.Scorecard.DeleteScorecard)
 				}
 
 				// 询价单 RFQ
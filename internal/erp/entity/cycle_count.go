@@ -0,0 +1,139 @@
+package entity
+
+import (
+	"time"
+)
+
+// ABC 分类
+const (
+	ABCClassA = "A"
+	ABCClassB = "B"
+	ABCClassC = "C"
+)
+
+// MaterialABC 物料 ABC 分类：按消耗金额累计占比划分，决定循环盘点频率
+type MaterialABC struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	MaterialID    string     `json:"material_id" gorm:"size:32;not null;uniqueIndex"`
+	MaterialCode  string     `json:"material_code" gorm:"size:64"`
+	MaterialName  string     `json:"material_name" gorm:"size:128"`
+	ABCClass      string     `json:"abc_class" gorm:"size:1;not null;index"`
+	UsageQty      float64    `json:"usage_qty" gorm:"type:decimal(14,4);default:0"`   // 统计期内消耗数量
+	UsageValue    float64    `json:"usage_value" gorm:"type:decimal(14,2);default:0"` // 统计期内消耗金额
+	CumulativePct float64    `json:"cumulative_pct" gorm:"type:decimal(7,4);default:0"`
+	ClassifiedAt  time.Time  `json:"classified_at"`
+	LastCountedAt *time.Time `json:"last_counted_at"`
+	NextCountDate *time.Time `json:"next_count_date" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (MaterialABC) TableName() string {
+	return "erp_material_abc"
+}
+
+// CycleCountPolicy 各 ABC 类别的盘点频率与差异容差
+type CycleCountPolicy struct {
+	ABCClass       string    `json:"abc_class" gorm:"primaryKey;size:1"`
+	FrequencyDays  int       `json:"frequency_days" gorm:"not null"`                      // 盘点周期（天）
+	TolerancePct   float64   `json:"tolerance_pct" gorm:"type:decimal(7,4);default:0"`    // 数量差异容差（%）
+	ToleranceValue float64   `json:"tolerance_value" gorm:"type:decimal(14,2);default:0"` // 金额差异容差
+	UpdatedBy      string    `json:"updated_by" gorm:"size:64"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (CycleCountPolicy) TableName() string {
+	return "erp_cycle_count_policies"
+}
+
+// CycleCountStatus 盘点任务状态
+const (
+	CycleCountStatusOpen      = "OPEN"      // 已生成，未开始
+	CycleCountStatusCounting  = "COUNTING"  // 盘点中，库位冻结
+	CycleCountStatusReview    = "REVIEW"    // 存在超容差差异，待审批
+	CycleCountStatusPosted    = "POSTED"    // 差异已过账，库位解冻
+	CycleCountStatusCancelled = "CANCELLED" // 已取消，库位解冻
+)
+
+// CycleCount 循环盘点任务
+type CycleCount struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Code          string     `json:"code" gorm:"size:50;not null;uniqueIndex"`
+	WarehouseID   string     `json:"warehouse_id" gorm:"type:uuid;not null;index"`
+	CountDate     time.Time  `json:"count_date" gorm:"type:date;index"`
+	Trigger       string     `json:"trigger" gorm:"size:20"` // SCHEDULED, MANUAL
+	Status        string     `json:"status" gorm:"size:20;not null;default:OPEN;index"`
+	TotalLines    int        `json:"total_lines"`
+	CountedLines  int        `json:"counted_lines"`
+	VarianceLines int        `json:"variance_lines"`
+	StartedAt     *time.Time `json:"started_at"`
+	PostedAt      *time.Time `json:"posted_at"`
+	PostedBy      string     `json:"posted_by" gorm:"size:64"`
+	Notes         string     `json:"notes" gorm:"type:text"`
+	CreatedBy     string     `json:"created_by" gorm:"size:64;not null"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	Lines []CycleCountLine `json:"lines,omitempty" gorm:"foreignKey:CountID"`
+}
+
+func (CycleCount) TableName() string {
+	return "erp_cycle_counts"
+}
+
+// CycleCountLineStatus 盘点行状态
+const (
+	CountLinePending         = "PENDING"          // 待录入实盘
+	CountLineMatched         = "MATCHED"          // 无差异
+	CountLineWithinTolerance = "WITHIN_TOLERANCE" // 差异在容差内，过账时自动调整
+	CountLineNeedsApproval   = "NEEDS_APPROVAL"   // 差异超容差，需审批
+	CountLineApproved        = "APPROVED"         // 已批准调整
+	CountLineRejected        = "REJECTED"         // 驳回，不调整（需复盘）
+)
+
+// CycleCountLine 盘点行：物料在某库位某批次的快照与实盘
+type CycleCountLine struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CountID        string     `json:"count_id" gorm:"type:uuid;not null;index"`
+	MaterialID     string     `json:"material_id" gorm:"size:32;not null"`
+	MaterialCode   string     `json:"material_code" gorm:"size:64"`
+	MaterialName   string     `json:"material_name" gorm:"size:128"`
+	ABCClass       string     `json:"abc_class" gorm:"size:1"`
+	LocationID     string     `json:"location_id" gorm:"size:64"`
+	BatchNo        string     `json:"batch_no" gorm:"size:50"`
+	SnapshotQty    float64    `json:"snapshot_qty" gorm:"type:decimal(12,4);default:0"`
+	CountedQty     *float64   `json:"counted_qty" gorm:"type:decimal(12,4)"`
+	VarianceQty    float64    `json:"variance_qty" gorm:"type:decimal(12,4);default:0"`
+	VariancePct    float64    `json:"variance_pct" gorm:"type:decimal(9,4);default:0"`
+	UnitCost       float64    `json:"unit_cost" gorm:"type:decimal(12,4);default:0"`
+	VarianceValue  float64    `json:"variance_value" gorm:"type:decimal(14,2);default:0"`
+	Status         string     `json:"status" gorm:"size:20;not null;default:PENDING"`
+	CountedBy      string     `json:"counted_by" gorm:"size:64"`
+	CountedAt      *time.Time `json:"counted_at"`
+	ApprovedBy     string     `json:"approved_by" gorm:"size:64"`
+	ApprovedAt     *time.Time `json:"approved_at"`
+	AdjustmentTxID string     `json:"adjustment_tx_id" gorm:"size:64"`
+	Notes          string     `json:"notes" gorm:"type:text"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (CycleCountLine) TableName() string {
+	return "erp_cycle_count_lines"
+}
+
+// BinFreeze 盘点期间冻结的库位，冻结期间不允许出入库和移库。
+// 库位为空时冻结该物料在仓库内未上架的库存
+type BinFreeze struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CountID     string     `json:"count_id" gorm:"type:uuid;not null;index"`
+	WarehouseID string     `json:"warehouse_id" gorm:"type:uuid;not null;index"`
+	LocationID  string     `json:"location_id" gorm:"size:64"`
+	MaterialID  string     `json:"material_id" gorm:"size:32"` // 空=整个库位
+	CreatedAt   time.Time  `json:"created_at"`
+	ReleasedAt  *time.Time `json:"released_at" gorm:"index"`
+}
+
+func (BinFreeze) TableName() string {
+	return "erp_bin_freezes"
+}
@@ -9,6 +9,7 @@ func AutoMigrate(db *gorm.DB) error {
 		&Warehouse{},
 		&WarehouseZone{},
 		&WarehouseLocation{},
+		&PutawayRule{},
 		&Supplier{},
 		&Customer{},
 
@@ -19,23 +20,45 @@ func AutoMigrate(db *gorm.DB) error {
 
 		// 库存
 		&Inventory{},
+		&InventoryReservation{},
+		&InventoryReconciliation{},
+		&InventoryMigration{},
 		&InventoryTransaction{},
+		&MaterialABC{},
+		&CycleCountPolicy{},
+		&CycleCount{},
+		&CycleCountLine{},
+		&BinFreeze{},
 
 		// 生产
 		&WorkOrder{},
 		&WorkOrderMaterial{},
 		&WorkOrderReport{},
+		&MaterialIssueMethod{},
+		&WorkCenter{},
+		&WorkOrderOperation{},
+		&OperationReport{},
+		&LotGenealogy{},
 
 		// 销售
 		&SalesOrder{},
 		&SOItem{},
+		&ShipmentTrace{},
 
 		// 售后
 		&ServiceOrder{},
+		&RMA{},
+		&WarrantyPolicy{},
+		&FailureCode{},
+		&WarrantyClaim{},
 
 		// MRP
 		&MRPRun{},
 		&MRPResult{},
+		&MRPPegging{},
+		&MaterialPlanning{},
+		&DemandForecast{},
+		&MPSEntry{},
 
 		// 财务
 		&FinanceRecord{},
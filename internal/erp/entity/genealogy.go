@@ -0,0 +1,53 @@
+package entity
+
+import "time"
+
+// 批次谱系关系类型
+const (
+	GenealogyConsume = "CONSUME" // 工单消耗的组件批次
+	GenealogyProduce = "PRODUCE" // 工单产出的成品批次/序列号
+)
+
+// LotGenealogy 批次谱系：以工单为节点，连接消耗的组件批次与产出的成品批次/序列号
+type LotGenealogy struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WorkOrderID   string    `json:"work_order_id" gorm:"type:uuid;not null;index"`
+	WOCode        string    `json:"wo_code" gorm:"size:50"`
+	LinkType      string    `json:"link_type" gorm:"size:10;not null"` // CONSUME, PRODUCE
+	MaterialID    string    `json:"material_id" gorm:"size:32;not null;index:idx_erp_lot_genealogy_lot"`
+	MaterialCode  string    `json:"material_code" gorm:"size:64"`
+	MaterialName  string    `json:"material_name" gorm:"size:128"`
+	LotNo         string    `json:"lot_no" gorm:"size:50;index:idx_erp_lot_genealogy_lot"`
+	SerialNo      string    `json:"serial_no" gorm:"size:100;index"`
+	Quantity      float64   `json:"quantity" gorm:"type:decimal(12,4);not null"`
+	SupplierID    string    `json:"supplier_id" gorm:"size:64;index"` // 组件批次的来料供应商
+	TransactionID string    `json:"transaction_id" gorm:"size:64"`    // 对应的库存流水
+	CreatedBy     string    `json:"created_by" gorm:"size:64"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (LotGenealogy) TableName() string {
+	return "erp_lot_genealogy"
+}
+
+// ShipmentTrace 发货追溯：销售订单发出的序列号/批次及客户
+type ShipmentTrace struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	SOID         string    `json:"so_id" gorm:"type:uuid;not null;index"`
+	SOCode       string    `json:"so_code" gorm:"size:50"`
+	CustomerID   string    `json:"customer_id" gorm:"type:uuid;index"`
+	CustomerName string    `json:"customer_name" gorm:"size:200"`
+	ProductID    string    `json:"product_id" gorm:"size:32;index"`
+	ProductCode  string    `json:"product_code" gorm:"size:64"`
+	SerialNo     string    `json:"serial_no" gorm:"size:100;index"`
+	LotNo        string    `json:"lot_no" gorm:"size:50;index"`
+	Quantity     float64   `json:"quantity" gorm:"type:decimal(12,4);not null;default:1"`
+	TrackingNo   string    `json:"tracking_no" gorm:"size:100"`
+	ShippedAt    time.Time `json:"shipped_at"`
+	CreatedBy    string    `json:"created_by" gorm:"size:64"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (ShipmentTrace) TableName() string {
+	return "erp_shipment_traces"
+}
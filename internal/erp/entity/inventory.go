@@ -18,10 +18,27 @@ const (
 	TxTypeProductionIn  = "PRODUCTION_IN"  // 生产入库
 	TxTypeReturnIn      = "RETURN_IN"      // 退货入库
 	TxTypeProductionOut = "PRODUCTION_OUT" // 生产领料
+	TxTypeProductionRet = "PRODUCTION_RET" // 生产退料
 	TxTypeSalesOut      = "SALES_OUT"      // 销售出库
 	TxTypeScrapOut      = "SCRAP_OUT"      // 报废出库
 	TxTypeAdjust        = "ADJUST"         // 库存调整
 	TxTypeTransfer      = "TRANSFER"       // 库存调拨
+	TxTypeMigrationIn   = "MIGRATION_IN"   // 历史库存迁入
+	TxTypeBinMove       = "BIN_MOVE"       // 库位间移库
+)
+
+// 拣货策略
+const (
+	PickStrategyFEFO = "FEFO" // 先到期先出，无有效期时按先进先出
+	PickStrategyFIFO = "FIFO" // 先进先出
+)
+
+// 来源模块的库存参考类型（ERP 单据沿用 PO/WO/SO）
+const (
+	InventoryRefSRMInspection = "SRM_INSPECTION" // SRM 质检合格入库
+	InventoryRefSRMManual     = "SRM_MANUAL"     // SRM 手工出入库
+	InventoryRefSRMAdjust     = "SRM_ADJUST"     // SRM 库存调整
+	InventoryRefMigration     = "MIGRATION"      // 历史库存迁移
 )
 
 // Inventory 库存记录
@@ -30,14 +47,16 @@ type Inventory struct {
 	MaterialID    string     `json:"material_id" gorm:"size:32;not null;index"`
 	MaterialCode  string     `json:"material_code" gorm:"size:64"`
 	MaterialName  string     `json:"material_name" gorm:"size:128"`
+	MPN           string     `json:"mpn" gorm:"size:100"`
 	WarehouseID   string     `json:"warehouse_id" gorm:"type:uuid;not null;index"`
-	LocationID    string     `json:"location_id" gorm:"type:uuid"`
+	LocationID    string     `json:"location_id" gorm:"size:64"` // 默认库位，实际库位分布见流水
 	BatchNo       string     `json:"batch_no" gorm:"size:50;index"`
 	SerialNo      string     `json:"serial_no" gorm:"size:100"`
 	InventoryType string     `json:"inventory_type" gorm:"size:10;not null;default:RAW"`
 	Quantity      float64    `json:"quantity" gorm:"type:decimal(12,4);not null;default:0"`
 	ReservedQty   float64    `json:"reserved_qty" gorm:"type:decimal(12,4);default:0"`
-	AvailableQty  float64    `json:"available_qty" gorm:"type:decimal(12,4);default:0"`
+	AvailableQty  float64    `json:"available_qty" gorm:"type:decimal(12,4);default:0"` // = Quantity - ReservedQty
+	Version       int        `json:"version" gorm:"not null;default:0"`                 // 乐观锁版本，每次变动+1
 	UnitCost      float64    `json:"unit_cost" gorm:"type:decimal(12,4);default:0"`
 	Unit          string     `json:"unit" gorm:"size:20;not null;default:pcs"`
 	SafetyStock   float64    `json:"safety_stock" gorm:"type:decimal(12,4);default:0"`
@@ -57,24 +76,123 @@ func (Inventory) TableName() string {
 
 // InventoryTransaction 库存交易记录
 type InventoryTransaction struct {
-	ID              string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	MaterialID      string    `json:"material_id" gorm:"size:32;not null;index"`
-	MaterialCode    string    `json:"material_code" gorm:"size:64"`
-	MaterialName    string    `json:"material_name" gorm:"size:128"`
-	WarehouseID     string    `json:"warehouse_id" gorm:"type:uuid;not null;index"`
-	TransactionType string    `json:"transaction_type" gorm:"size:20;not null"`
-	Quantity        float64   `json:"quantity" gorm:"type:decimal(12,4);not null"` // 正=入，负=出
-	BatchNo         string    `json:"batch_no" gorm:"size:50"`
-	SerialNo        string    `json:"serial_no" gorm:"size:100"`
-	UnitCost        float64   `json:"unit_cost" gorm:"type:decimal(12,4);default:0"`
-	ReferenceType   string    `json:"reference_type" gorm:"size:50;not null"` // PO, WO, SO
-	ReferenceID     string    `json:"reference_id" gorm:"size:64;not null"`
-	ReferenceCode   string    `json:"reference_code" gorm:"size:50"`
-	Notes           string    `json:"notes" gorm:"type:text"`
-	CreatedBy       string    `json:"created_by" gorm:"size:64;not null"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	MaterialID      string     `json:"material_id" gorm:"size:32;not null;index"`
+	MaterialCode    string     `json:"material_code" gorm:"size:64"`
+	MaterialName    string     `json:"material_name" gorm:"size:128"`
+	WarehouseID     string     `json:"warehouse_id" gorm:"type:uuid;not null;index"`
+	TransactionType string     `json:"transaction_type" gorm:"size:20;not null"`
+	Quantity        float64    `json:"quantity" gorm:"type:decimal(12,4);not null"` // 正=入，负=出
+	InventoryID     string     `json:"inventory_id" gorm:"size:64;index"`
+	BalanceAfter    float64    `json:"balance_after" gorm:"type:decimal(12,4);default:0"` // 过账后结存
+	BatchNo         string     `json:"batch_no" gorm:"size:50"`
+	LocationID      string     `json:"location_id" gorm:"size:64;index"` // 库位
+	ExpiryDate      *time.Time `json:"expiry_date"`                      // 批次有效期（入库时记录）
+	SerialNo        string     `json:"serial_no" gorm:"size:100"`
+	UnitCost        float64    `json:"unit_cost" gorm:"type:decimal(12,4);default:0"`
+	ReferenceType   string     `json:"reference_type" gorm:"size:50;not null"` // PO, WO, SO
+	ReferenceID     string     `json:"reference_id" gorm:"size:64;not null"`
+	ReferenceCode   string     `json:"reference_code" gorm:"size:50"`
+	SupplierID      string     `json:"supplier_id" gorm:"size:64;index"` // 来料供应商（SRM/ERP 采购入库）
+	POItemID        string     `json:"po_item_id" gorm:"size:32;index"`  // SRM 采购订单行项（三单匹配）
+	Notes           string     `json:"notes" gorm:"type:text"`
+	CreatedBy       string     `json:"created_by" gorm:"size:64;not null"`
+	CreatedAt       time.Time  `json:"created_at"`
 }
 
 func (InventoryTransaction) TableName() string {
 	return "erp_inventory_transactions"
 }
+
+// 库存预留状态
+const (
+	ReservationStatusActive   = "ACTIVE"
+	ReservationStatusConsumed = "CONSUMED"
+	ReservationStatusReleased = "RELEASED"
+)
+
+// InventoryReservation 库存预留（销售订单/工单占用），占用期间不计入可用库存
+type InventoryReservation struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	InventoryID   string     `json:"inventory_id" gorm:"type:uuid;not null;index"`
+	MaterialID    string     `json:"material_id" gorm:"size:32;not null;index"`
+	WarehouseID   string     `json:"warehouse_id" gorm:"type:uuid;not null"`
+	Quantity      float64    `json:"quantity" gorm:"type:decimal(12,4);not null"`
+	ConsumedQty   float64    `json:"consumed_qty" gorm:"type:decimal(12,4);default:0"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:ACTIVE;index"`
+	ReferenceType string     `json:"reference_type" gorm:"size:50;not null"` // SO, WO
+	ReferenceID   string     `json:"reference_id" gorm:"size:64;not null;index"`
+	ReferenceCode string     `json:"reference_code" gorm:"size:50"`
+	Notes         string     `json:"notes" gorm:"type:text"`
+	CreatedBy     string     `json:"created_by" gorm:"size:64;not null"`
+	ReleasedAt    *time.Time `json:"released_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (InventoryReservation) TableName() string {
+	return "erp_inventory_reservations"
+}
+
+// Remaining 未消耗的预留数量
+func (r InventoryReservation) Remaining() float64 {
+	return r.Quantity - r.ConsumedQty
+}
+
+// InventoryReconciliation 库存对账记录：按流水重算结存并记录差异
+type InventoryReconciliation struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Checked   int       `json:"checked"`                  // 核对的物料/仓库组合数
+	Drifted   int       `json:"drifted"`                  // 存在差异的组合数
+	Fixed     bool      `json:"fixed"`                    // 是否已按流水重建结存
+	Details   string    `json:"details" gorm:"type:text"` // 差异明细（JSON）
+	Trigger   string    `json:"trigger" gorm:"size:20"`   // MANUAL, SCHEDULED
+	CreatedBy string    `json:"created_by" gorm:"size:64"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (InventoryReconciliation) TableName() string {
+	return "erp_inventory_reconciliations"
+}
+
+// 库存迁移状态
+const (
+	MigrationStatusMerged   = "MERGED"
+	MigrationStatusConflict = "CONFLICT"
+)
+
+// 库存迁移冲突类型
+const (
+	MigrationConflictMaterialNotFound  = "MATERIAL_NOT_FOUND"  // 物料主数据中找不到
+	MigrationConflictMaterialAmbiguous = "MATERIAL_AMBIGUOUS"  // 无编码且按名称（及制造商料号）匹配到多个物料
+	MigrationConflictWarehouseNotFound = "WAREHOUSE_NOT_FOUND" // 仓库名称无法对应到ERP仓库
+	MigrationConflictUnitMismatch      = "UNIT_MISMATCH"       // 单位与物料主数据不一致
+	MigrationConflictNegativeQty       = "NEGATIVE_QTY"        // 历史结存为负
+	MigrationConflictBalanceExists     = "BALANCE_EXISTS"      // ERP 已有同物料同仓库结存，合并会重复计数
+)
+
+// InventoryMigration 历史库存迁移记录：每条来源记录一行，已合并的不会重复迁移
+type InventoryMigration struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	SourceType   string    `json:"source_type" gorm:"size:20;not null;uniqueIndex:idx_erp_inv_migration_source"` // SRM
+	SourceID     string    `json:"source_id" gorm:"size:64;not null;uniqueIndex:idx_erp_inv_migration_source"`
+	MaterialCode string    `json:"material_code" gorm:"size:64"`
+	MaterialName string    `json:"material_name" gorm:"size:200"`
+	SupplierID   string    `json:"supplier_id" gorm:"size:32"`
+	Warehouse    string    `json:"warehouse" gorm:"size:100"` // 来源仓库名称
+	Quantity     float64   `json:"quantity" gorm:"type:decimal(12,4)"`
+	Unit         string    `json:"unit" gorm:"size:20"`
+	MaterialID   string    `json:"material_id" gorm:"size:32"`
+	WarehouseID  string    `json:"warehouse_id" gorm:"size:64"`
+	InventoryID  string    `json:"inventory_id" gorm:"size:64"`
+	Status       string    `json:"status" gorm:"size:20;not null;index"`
+	ConflictType string    `json:"conflict_type" gorm:"size:30"`
+	Detail       string    `json:"detail" gorm:"type:text"`
+	MigratedBy   string    `json:"migrated_by" gorm:"size:64"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (InventoryMigration) TableName() string {
+	return "erp_inventory_migrations"
+}
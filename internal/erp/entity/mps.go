@@ -0,0 +1,68 @@
+package entity
+
+import "time"
+
+// 预测周期类型
+const (
+	ForecastPeriodWeek  = "WEEK"
+	ForecastPeriodMonth = "MONTH"
+)
+
+// 预测来源
+const (
+	ForecastSourceManual = "MANUAL"
+	ForecastSourceImport = "IMPORT"
+)
+
+// DemandForecast 产品需求预测
+type DemandForecast struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ProductID   string    `json:"product_id" gorm:"size:32;not null;uniqueIndex:idx_forecast_product_period"`
+	ProductCode string    `json:"product_code" gorm:"size:64"`
+	PeriodType  string    `json:"period_type" gorm:"size:10;not null;default:MONTH"`
+	PeriodStart time.Time `json:"period_start" gorm:"type:date;not null;uniqueIndex:idx_forecast_product_period"`
+	Quantity    float64   `json:"quantity" gorm:"type:decimal(12,4);not null"`
+	Source      string    `json:"source" gorm:"size:20;default:MANUAL"`
+	Notes       string    `json:"notes" gorm:"type:text"`
+	CreatedBy   string    `json:"created_by" gorm:"size:64"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (DemandForecast) TableName() string {
+	return "erp_demand_forecasts"
+}
+
+// PeriodEnd 预测周期结束日（含）
+func (f DemandForecast) PeriodEnd() time.Time {
+	if f.PeriodType == ForecastPeriodWeek {
+		return f.PeriodStart.AddDate(0, 0, 6)
+	}
+	return f.PeriodStart.AddDate(0, 1, -1)
+}
+
+// MPSEntry 主生产计划（按产品、周）
+type MPSEntry struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ProductID       string     `json:"product_id" gorm:"size:32;not null;uniqueIndex:idx_mps_product_period"`
+	ProductCode     string     `json:"product_code" gorm:"size:64"`
+	ProductName     string     `json:"product_name" gorm:"size:128"`
+	PeriodStart     time.Time  `json:"period_start" gorm:"type:date;not null;uniqueIndex:idx_mps_product_period"`
+	PeriodEnd       time.Time  `json:"period_end" gorm:"type:date;not null"`
+	ForecastQty     float64    `json:"forecast_qty" gorm:"type:decimal(12,4);default:0"` // 本期预测
+	ConsumedQty     float64    `json:"consumed_qty" gorm:"type:decimal(12,4);default:0"` // 被订单冲减的预测
+	OrderQty        float64    `json:"order_qty" gorm:"type:decimal(12,4);default:0"`    // 本期实际订单
+	Quantity        float64    `json:"quantity" gorm:"type:decimal(12,4);not null"`      // 计划生产数量（驱动MRP）
+	InDemandFence   bool       `json:"in_demand_fence" gorm:"default:false"`             // 需求时界内：只计订单
+	InPlanningFence bool       `json:"in_planning_fence" gorm:"default:false"`           // 计划时界内：冻结
+	Firm            bool       `json:"firm" gorm:"default:false"`                        // 确认计划，重新生成时不覆盖
+	FirmedBy        string     `json:"firmed_by" gorm:"size:64"`
+	FirmedAt        *time.Time `json:"firmed_at"`
+	GeneratedAt     time.Time  `json:"generated_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (MPSEntry) TableName() string {
+	return "erp_mps_entries"
+}
@@ -12,6 +12,19 @@ const (
 	MRPStatusApplied   = "APPLIED"
 )
 
+// MRP运行类型
+const (
+	MRPRunRegenerative = "REGENERATIVE" // 全重排
+	MRPRunNetChange    = "NET_CHANGE"   // 净变更：仅重算上次运行后有变动的物料
+	MRPRunSimulation   = "SIMULATION"   // 模拟：覆盖需求/提前期/库存，不可应用
+)
+
+// MRP独立需求来源
+const (
+	MRPDemandSourceSO  = "SO"  // 销售订单
+	MRPDemandSourceMPS = "MPS" // 主生产计划（订单+未冲减预测）
+)
+
 // MRPRun MRP运行记录
 type MRPRun struct {
 	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -19,6 +32,13 @@ type MRPRun struct {
 	Status        string     `json:"status" gorm:"size:20;not null;default:RUNNING"`
 	ProductID     string     `json:"product_id" gorm:"size:32"` // 空表示全部产品
 	PlanningHorizon int     `json:"planning_horizon" gorm:"default:30"` // 计划范围（天）
+	BucketType    string     `json:"bucket_type" gorm:"size:10;default:WEEK"` // DAY, WEEK
+	RunType       string     `json:"run_type" gorm:"size:20;default:REGENERATIVE"` // REGENERATIVE, NET_CHANGE, SIMULATION
+	BaseRunID     string     `json:"base_run_id" gorm:"size:64"` // 净变更基准运行
+	Scenario      string     `json:"scenario" gorm:"type:text"` // 模拟场景（JSON）
+	Description   string     `json:"description" gorm:"size:200"`
+	ChangedItems  int        `json:"changed_items" gorm:"default:0"` // 净变更重算物料数
+	DemandSource  string     `json:"demand_source" gorm:"size:10;default:SO"` // SO, MPS
 	TotalItems    int        `json:"total_items" gorm:"default:0"`
 	PRsGenerated  int        `json:"prs_generated" gorm:"default:0"`
 	WOsGenerated  int        `json:"wos_generated" gorm:"default:0"`
@@ -53,7 +73,15 @@ type MRPResult struct {
 	LeadTimeDays    int       `json:"lead_time_days" gorm:"default:0"`
 	OrderDate       *time.Time `json:"order_date"` // RequiredDate - LeadTime
 	Unit            string    `json:"unit" gorm:"size:20;default:pcs"`
+	LowLevelCode    int       `json:"low_level_code" gorm:"default:0"` // 低层码（0=成品）
+	BucketStart     *time.Time `json:"bucket_start"`
+	BucketEnd       *time.Time `json:"bucket_end"`
+	ScheduledReceipt float64  `json:"scheduled_receipt" gorm:"type:decimal(12,4);default:0"` // 本期计划接收（在途+在制）
+	ProjectedOnHand float64   `json:"projected_on_hand" gorm:"type:decimal(12,4);default:0"` // 期末预计可用
+	LotSizingRule   string    `json:"lot_sizing_rule" gorm:"size:20"`
+	PastDue         bool      `json:"past_due" gorm:"default:false"` // 下单日期已过，需加急
 	Applied         bool      `json:"applied" gorm:"default:false"`
+	Carried         bool      `json:"carried" gorm:"default:false"` // 净变更中沿用基准运行的结果
 	CreatedAt       time.Time `json:"created_at"`
 }
 
@@ -61,12 +89,19 @@ func (MRPResult) TableName() string {
 	return "erp_mrp_results"
 }
 
-// FinanceRecord 财务记录（应付/应收）
+// FinanceRecordType 财务记录类型
+const (
+	FinanceRecordPayable    = "PAYABLE"
+	FinanceRecordReceivable = "RECEIVABLE"
+	FinanceRecordCreditNote = "CREDIT_NOTE" // 贷项通知（退货退款）
+)
+
+// FinanceRecord 财务记录（应付/应收/贷项通知）
 type FinanceRecord struct {
 	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	RecordCode    string     `json:"record_code" gorm:"size:50;not null;uniqueIndex"`
-	RecordType    string     `json:"record_type" gorm:"size:20;not null"` // PAYABLE, RECEIVABLE
-	ReferenceType string     `json:"reference_type" gorm:"size:20;not null"` // PO, SO
+	RecordType    string     `json:"record_type" gorm:"size:20;not null"` // PAYABLE, RECEIVABLE, CREDIT_NOTE
+	ReferenceType string     `json:"reference_type" gorm:"size:20;not null"` // PO, SO, RMA
 	ReferenceID   string     `json:"reference_id" gorm:"size:64;not null"`
 	ReferenceCode string     `json:"reference_code" gorm:"size:50"`
 	CounterpartyID string    `json:"counterparty_id" gorm:"size:64;not null"` // 供应商或客户ID
@@ -0,0 +1,117 @@
+package entity
+
+import (
+	"math"
+	"time"
+)
+
+// MRP时间段类型
+const (
+	MRPBucketDay  = "DAY"
+	MRPBucketWeek = "WEEK"
+)
+
+// 批量规则
+const (
+	LotSizingLFL      = "LFL"      // 按需（Lot-for-Lot）
+	LotSizingFixed    = "FIXED"    // 固定批量
+	LotSizingEOQ      = "EOQ"      // 经济批量
+	LotSizingMOQ      = "MOQ"      // 最小订货量
+	LotSizingMultiple = "MULTIPLE" // 整倍数
+)
+
+// MaterialPlanning 物料计划参数（ERP侧维护，物料主数据仍来自PLM）
+type MaterialPlanning struct {
+	ID              string  `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	MaterialID      string  `json:"material_id" gorm:"size:32;not null;uniqueIndex"` // 物料或成品ID
+	LotSizingRule   string  `json:"lot_sizing_rule" gorm:"size:20;not null;default:LFL"`
+	FixedLotQty     float64 `json:"fixed_lot_qty" gorm:"type:decimal(12,4);default:0"`
+	OrderMultiple   float64 `json:"order_multiple" gorm:"type:decimal(12,4);default:0"`
+	OrderingCost    float64 `json:"ordering_cost" gorm:"type:decimal(12,2);default:0"`    // 单次订货成本（EOQ）
+	HoldingCostRate float64 `json:"holding_cost_rate" gorm:"type:decimal(6,4);default:0"` // 年持有成本率（EOQ）
+	LeadTimeDays    *int    `json:"lead_time_days"`                                       // 覆盖PLM提前期，成品生产周期在此维护
+
+	// 主生产计划（仅成品）
+	DemandTimeFenceDays     int       `json:"demand_time_fence_days" gorm:"default:0"`   // 需求时界：时界内只计实际订单
+	PlanningTimeFenceDays   int       `json:"planning_time_fence_days" gorm:"default:0"` // 计划时界：时界内MPS冻结
+	ConsumptionBackwardDays *int      `json:"consumption_backward_days"`                 // 订单向前冲减预测的天数
+	ConsumptionForwardDays  *int      `json:"consumption_forward_days"`                  // 订单向后冲减预测的天数
+	UpdatedBy               string    `json:"updated_by" gorm:"size:64"`
+	CreatedAt               time.Time `json:"created_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+func (MaterialPlanning) TableName() string {
+	return "erp_material_planning"
+}
+
+// LotSizingParams 批量计算参数
+type LotSizingParams struct {
+	Rule          string
+	MinOrderQty   float64
+	FixedLotQty   float64
+	OrderMultiple float64
+	EOQ           float64
+}
+
+// EconomicOrderQty 经济批量 sqrt(2DS/H)，参数不全时返回0
+func EconomicOrderQty(annualDemand, orderingCost, unitCost, holdingRate float64) float64 {
+	holding := unitCost * holdingRate
+	if annualDemand <= 0 || orderingCost <= 0 || holding <= 0 {
+		return 0
+	}
+	return math.Sqrt(2 * annualDemand * orderingCost / holding)
+}
+
+// LotSize 按批量规则计算计划订单数量；MOQ与整倍数对所有规则生效
+func LotSize(net float64, p LotSizingParams) float64 {
+	if net <= 0 {
+		return 0
+	}
+	qty := net
+	switch p.Rule {
+	case LotSizingFixed:
+		if p.FixedLotQty > 0 {
+			qty = math.Ceil(net/p.FixedLotQty) * p.FixedLotQty
+		}
+	case LotSizingEOQ:
+		if p.EOQ > qty {
+			qty = p.EOQ
+		}
+	}
+	if p.MinOrderQty > 0 && qty < p.MinOrderQty {
+		qty = p.MinOrderQty
+	}
+	if p.OrderMultiple > 0 {
+		qty = math.Ceil(qty/p.OrderMultiple-1e-9) * p.OrderMultiple
+	}
+	return qty
+}
+
+// 需求追溯类型
+const (
+	DemandTypeSO        = "SO"
+	DemandTypeForecast  = "FORECAST"
+	DemandTypeReplenish = "REPLENISH" // 批量放大/安全库存补充，无销售来源
+)
+
+// MRPPegging MRP需求追溯：计划订单满足的需求来源
+type MRPPegging struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	MRPRunID       string     `json:"mrp_run_id" gorm:"type:uuid;not null;index"`
+	MRPResultID    string     `json:"mrp_result_id" gorm:"type:uuid;not null;index"`
+	MaterialID     string     `json:"material_id" gorm:"size:32;not null"`
+	Quantity       float64    `json:"quantity" gorm:"type:decimal(12,4);not null"`
+	DemandDate     *time.Time `json:"demand_date"`
+	SOID           string     `json:"so_id" gorm:"size:64"`
+	SOCode         string     `json:"so_code" gorm:"size:50"`
+	SOItemID       string     `json:"so_item_id" gorm:"size:64;index"`
+	DemandType     string     `json:"demand_type" gorm:"size:20;default:SO"` // SO, FORECAST, REPLENISH
+	MPSEntryID     string     `json:"mps_entry_id" gorm:"size:64"`
+	ParentResultID string     `json:"parent_result_id" gorm:"size:64"` // 上层计划订单（相关需求）
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (MRPPegging) TableName() string {
+	return "erp_mrp_peggings"
+}
@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"math"
+	"testing"
+)
+
+// TestLotSize 各批量规则及最小订货量/整倍数的叠加
+func TestLotSize(t *testing.T) {
+	cases := []struct {
+		name string
+		net  float64
+		p    LotSizingParams
+		want float64
+	}{
+		{"无净需求", 0, LotSizingParams{Rule: LotSizingLFL}, 0},
+		{"按需", 37, LotSizingParams{Rule: LotSizingLFL}, 37},
+		{"按需受最小订货量约束", 37, LotSizingParams{Rule: LotSizingLFL, MinOrderQty: 50}, 50},
+		{"固定批量向上取整", 37, LotSizingParams{Rule: LotSizingFixed, FixedLotQty: 25}, 50},
+		{"固定批量恰好整批", 50, LotSizingParams{Rule: LotSizingFixed, FixedLotQty: 25}, 50},
+		{"固定批量未设置按需", 37, LotSizingParams{Rule: LotSizingFixed}, 37},
+		{"经济批量大于净需求", 37, LotSizingParams{Rule: LotSizingEOQ, EOQ: 120}, 120},
+		{"净需求大于经济批量", 150, LotSizingParams{Rule: LotSizingEOQ, EOQ: 120}, 150},
+		{"最小订货量规则", 10, LotSizingParams{Rule: LotSizingMOQ, MinOrderQty: 100}, 100},
+		{"整倍数", 37, LotSizingParams{Rule: LotSizingMultiple, OrderMultiple: 10}, 40},
+		{"整倍数恰好整除", 40, LotSizingParams{Rule: LotSizingMultiple, OrderMultiple: 10}, 40},
+		{"最小订货量后再取整倍数", 37, LotSizingParams{Rule: LotSizingLFL, MinOrderQty: 45, OrderMultiple: 20}, 60},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := LotSize(tc.net, tc.p); math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("LotSize(%v, %+v) = %v, want %v", tc.net, tc.p, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestEconomicOrderQty 经济批量公式，参数不全时返回0
+func TestEconomicOrderQty(t *testing.T) {
+	cases := []struct {
+		name                          string
+		annual, ordering, cost, ratio float64
+		want                          float64
+	}{
+		{"标准", 1000, 50, 10, 0.25, math.Sqrt(2 * 1000 * 50 / 2.5)},
+		{"无需求", 0, 50, 10, 0.25, 0},
+		{"无订货成本", 1000, 0, 10, 0.25, 0},
+		{"无持有成本", 1000, 50, 10, 0, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := EconomicOrderQty(tc.annual, tc.ordering, tc.cost, tc.ratio); math.Abs(got-tc.want) > 1e-9 {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"time"
+)
+
+// RMAStatus 退货授权状态
+const (
+	RMAStatusIssued    = "ISSUED"    // 已授权，待客户寄回
+	RMAStatusReceived  = "RECEIVED"  // 已收货，在隔离区待判定
+	RMAStatusRepairing = "REPAIRING" // 判定维修，维修工单处理中
+	RMAStatusResolved  = "RESOLVED"  // 已换货/退款
+	RMAStatusClosed    = "CLOSED"
+	RMAStatusRejected  = "REJECTED" // 判定不予受理
+)
+
+// RMADisposition 退货判定结果
+const (
+	RMADispositionRepair  = "REPAIR"  // 维修：生成维修工单
+	RMADispositionReplace = "REPLACE" // 换货：生成换货发货单
+	RMADispositionRefund  = "REFUND"  // 退款：生成贷项通知
+	RMADispositionReject  = "REJECT"  // 不予受理
+)
+
+// RMA 相关单据与库存参考类型
+const (
+	RMAReferenceType      = "RMA"
+	RMASourceType         = "RMA"    // 维修工单/换货订单来源
+	RMAQuarantineWHCode   = "WH-RMA" // 默认退货隔离仓
+	RMAQuarantineWHName   = "退货隔离仓"
+	DefaultWarrantyMonths = 12 // 未配置保修政策时的保修月数
+)
+
+// RMA 退货授权：由服务工单发起，串联退货收货、隔离判定与维修/换货/退款结果
+type RMA struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	RMACode        string     `json:"rma_code" gorm:"size:50;not null;uniqueIndex"`
+	ServiceOrderID string     `json:"service_order_id" gorm:"type:uuid;not null;index"`
+	ServiceCode    string     `json:"service_code" gorm:"size:50"`
+	CustomerID     string     `json:"customer_id" gorm:"type:uuid;not null;index"`
+	SerialNo       string     `json:"serial_no" gorm:"size:100;not null;index"`
+	ProductID      string     `json:"product_id" gorm:"size:32;not null;index"`
+	ProductCode    string     `json:"product_code" gorm:"size:64"`
+	LotNo          string     `json:"lot_no" gorm:"size:50"`
+	SOID           string     `json:"so_id" gorm:"size:64;index"` // 原发货订单
+	SOCode         string     `json:"so_code" gorm:"size:50"`
+	ShippedAt      *time.Time `json:"shipped_at"`
+	WarrantyUntil  *time.Time `json:"warranty_until"`
+	InWarranty     bool       `json:"in_warranty"`
+	Reason         string     `json:"reason" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"size:20;not null;default:ISSUED;index"`
+
+	// 收货隔离
+	WarehouseID   string     `json:"warehouse_id" gorm:"size:64"`
+	LocationID    string     `json:"location_id" gorm:"size:64"`
+	ReservationID string     `json:"reservation_id" gorm:"size:64"` // 隔离期间占用，不计入可用库存
+	ReceivedAt    *time.Time `json:"received_at"`
+	ReceivedBy    string     `json:"received_by" gorm:"size:64"`
+
+	// 判定与结果
+	Disposition       string     `json:"disposition" gorm:"size:20"`
+	TriageNote        string     `json:"triage_note" gorm:"type:text"`
+	TriagedBy         string     `json:"triaged_by" gorm:"size:64"`
+	TriagedAt         *time.Time `json:"triaged_at"`
+	RepairWOID        string     `json:"repair_wo_id" gorm:"size:64;index"`
+	RepairWOCode      string     `json:"repair_wo_code" gorm:"size:50"`
+	ReplacementSOID   string     `json:"replacement_so_id" gorm:"size:64;index"`
+	ReplacementSOCode string     `json:"replacement_so_code" gorm:"size:50"`
+	CreditNoteID      string     `json:"credit_note_id" gorm:"size:64"`
+	CreditNoteCode    string     `json:"credit_note_code" gorm:"size:50"`
+	CreditAmount      float64    `json:"credit_amount" gorm:"type:decimal(12,2);default:0"`
+	ClosedAt          *time.Time `json:"closed_at"`
+
+	CreatedBy string    `json:"created_by" gorm:"size:64;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Customer *Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+}
+
+func (RMA) TableName() string {
+	return "erp_rmas"
+}
+
+// WarrantyPolicy 产品保修政策，保修期自发货日起算
+type WarrantyPolicy struct {
+	ID             string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ProductID      string    `json:"product_id" gorm:"size:32;not null;uniqueIndex"`
+	WarrantyMonths int       `json:"warranty_months" gorm:"not null"`
+	Notes          string    `json:"notes" gorm:"type:text"`
+	UpdatedBy      string    `json:"updated_by" gorm:"size:64"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (WarrantyPolicy) TableName() string {
+	return "erp_warranty_policies"
+}
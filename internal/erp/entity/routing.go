@@ -0,0 +1,121 @@
+package entity
+
+import (
+	"time"
+)
+
+// WorkCenter 工作中心：工序的加工资源及其每日可用产能
+type WorkCenter struct {
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Code          string     `json:"code" gorm:"size:64;not null;uniqueIndex"`
+	Name          string     `json:"name" gorm:"size:128;not null"`
+	HoursPerShift float64    `json:"hours_per_shift" gorm:"type:decimal(5,2);default:8"`
+	Shifts        int        `json:"shifts" gorm:"default:1"`                             // 每日班次
+	Units         int        `json:"units" gorm:"default:1"`                              // 并行设备/工位数
+	EfficiencyPct float64    `json:"efficiency_pct" gorm:"type:decimal(5,2);default:100"` // 综合效率（%）
+	WorkDays      int        `json:"work_days" gorm:"default:5"`                          // 每周工作天数（周一起）
+	CostRate      float64    `json:"cost_rate" gorm:"type:decimal(12,4);default:0"`       // 每小时费率
+	Status        string     `json:"status" gorm:"size:20;not null;default:ACTIVE"`
+	Notes         string     `json:"notes" gorm:"type:text"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at" gorm:"index"`
+}
+
+func (WorkCenter) TableName() string {
+	return "erp_work_centers"
+}
+
+// DailyMinutes 工作日每日可用产能（分钟）
+func (w WorkCenter) DailyMinutes() float64 {
+	return w.HoursPerShift * 60 * float64(w.Shifts) * float64(w.Units) * w.EfficiencyPct / 100
+}
+
+// IsWorkDay 是否工作日：每周从周一起连续 WorkDays 天
+func (w WorkCenter) IsWorkDay(d time.Time) bool {
+	days := w.WorkDays
+	if days <= 0 || days > 7 {
+		days = 5
+	}
+	weekday := (int(d.Weekday()) + 6) % 7 // 周一=0
+	return weekday < days
+}
+
+// OperationStatus 工序状态
+const (
+	OpStatusPending    = "PENDING"
+	OpStatusInProgress = "IN_PROGRESS"
+	OpStatusCompleted  = "COMPLETED"
+)
+
+// WorkOrderOperation 工单工序：下达时从已发布工艺路线复制，标准工时按工单数量展开
+type WorkOrderOperation struct {
+	ID                string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WorkOrderID       string     `json:"work_order_id" gorm:"type:uuid;not null;index"`
+	Sequence          int        `json:"sequence" gorm:"not null"`
+	StepID            string     `json:"step_id" gorm:"size:32"` // PLM 工艺步骤
+	Name              string     `json:"name" gorm:"size:128;not null"`
+	WorkCenterID      string     `json:"work_center_id" gorm:"size:64;index"`
+	WorkCenterCode    string     `json:"work_center_code" gorm:"size:64"`
+	SetupMinutes      float64    `json:"setup_minutes" gorm:"type:decimal(10,2);default:0"`
+	RunMinutesPerUnit float64    `json:"run_minutes_per_unit" gorm:"type:decimal(10,4);default:0"`
+	PlannedQty        float64    `json:"planned_qty" gorm:"type:decimal(12,4);not null"`
+	GoodQty           float64    `json:"good_qty" gorm:"type:decimal(12,4);default:0"`
+	ScrapQty          float64    `json:"scrap_qty" gorm:"type:decimal(12,4);default:0"`
+	ReworkQty         float64    `json:"rework_qty" gorm:"type:decimal(12,4);default:0"` // 累计返工数量
+	SetupActualMin    float64    `json:"setup_actual_min" gorm:"type:decimal(10,2);default:0"`
+	LaborMinutes      float64    `json:"labor_minutes" gorm:"type:decimal(12,2);default:0"`
+	Status            string     `json:"status" gorm:"size:20;not null;default:PENDING"`
+	PlannedStart      *time.Time `json:"planned_start"`
+	PlannedEnd        *time.Time `json:"planned_end"`
+	ActualStart       *time.Time `json:"actual_start"`
+	ActualEnd         *time.Time `json:"actual_end"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (WorkOrderOperation) TableName() string {
+	return "erp_work_order_operations"
+}
+
+// StdMinutes 按计划数量的标准工时（准备+加工）
+func (o WorkOrderOperation) StdMinutes() float64 {
+	return o.SetupMinutes + o.RunMinutesPerUnit*o.PlannedQty
+}
+
+// RemainingMinutes 剩余标准工时：未开工含准备时间，加工按未完成数量计
+func (o WorkOrderOperation) RemainingMinutes() float64 {
+	if o.Status == OpStatusCompleted {
+		return 0
+	}
+	remaining := o.PlannedQty - o.GoodQty - o.ScrapQty
+	if remaining < 0 {
+		remaining = 0
+	}
+	minutes := o.RunMinutesPerUnit * remaining
+	if o.Status == OpStatusPending {
+		minutes += o.SetupMinutes
+	}
+	return minutes
+}
+
+// OperationReport 工序报工：良品/报废/返工数量及人工工时
+type OperationReport struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WorkOrderID  string    `json:"work_order_id" gorm:"type:uuid;not null;index"`
+	OperationID  string    `json:"operation_id" gorm:"type:uuid;not null;index"`
+	WorkCenterID string    `json:"work_center_id" gorm:"size:64;index"`
+	GoodQty      float64   `json:"good_qty" gorm:"type:decimal(12,4);default:0"`
+	ScrapQty     float64   `json:"scrap_qty" gorm:"type:decimal(12,4);default:0"`
+	ReworkQty    float64   `json:"rework_qty" gorm:"type:decimal(12,4);default:0"`
+	SetupMinutes float64   `json:"setup_minutes" gorm:"type:decimal(10,2);default:0"`
+	LaborMinutes float64   `json:"labor_minutes" gorm:"type:decimal(12,2);default:0"`
+	Notes        string    `json:"notes" gorm:"type:text"`
+	ReportedBy   string    `json:"reported_by" gorm:"size:64;not null"`
+	ReportedAt   time.Time `json:"reported_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OperationReport) TableName() string {
+	return "erp_operation_reports"
+}
@@ -6,13 +6,22 @@ import (
 
 // SalesOrderStatus 销售订单状态
 const (
-	SOStatusPending   = "PENDING"
-	SOStatusConfirmed = "CONFIRMED"
-	SOStatusPicking   = "PICKING"
-	SOStatusShipped   = "SHIPPED"
-	SOStatusDelivered = "DELIVERED"
-	SOStatusCompleted = "COMPLETED"
-	SOStatusCancelled = "CANCELLED"
+	SOStatusPending    = "PENDING"
+	SOStatusCreditHold = "CREDIT_HOLD" // 信用冻结待审批
+	SOStatusConfirmed  = "CONFIRMED"
+	SOStatusPicking    = "PICKING"
+	SOStatusShipped    = "SHIPPED"
+	SOStatusDelivered  = "DELIVERED"
+	SOStatusCompleted  = "COMPLETED"
+	SOStatusCancelled  = "CANCELLED"
+)
+
+// SOCreditStatus 订单信用检查结果
+const (
+	SOCreditPassed   = "PASSED"
+	SOCreditHeld     = "HELD"
+	SOCreditReleased = "RELEASED" // 特批放行
+	SOCreditRejected = "REJECTED" // 特批驳回
 )
 
 // SalesChannel 销售渠道
@@ -25,26 +34,31 @@ const (
 
 // SalesOrder 销售订单
 type SalesOrder struct {
-	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	SOCode          string     `json:"so_code" gorm:"size:50;not null;uniqueIndex"`
-	CustomerID      string     `json:"customer_id" gorm:"type:uuid;not null;index"`
-	Channel         string     `json:"channel" gorm:"size:20;not null;default:DIRECT"`
-	Status          string     `json:"status" gorm:"size:20;not null;default:PENDING"`
-	TotalAmount     float64    `json:"total_amount" gorm:"type:decimal(12,2);default:0"`
-	Currency        string     `json:"currency" gorm:"size:10;not null;default:CNY"`
-	OrderDate       *time.Time `json:"order_date"`
-	ShippingDate    *time.Time `json:"shipping_date"`
-	DeliveredDate   *time.Time `json:"delivered_date"`
-	ShippingAddress string     `json:"shipping_address" gorm:"size:500"`
-	TrackingNo      string     `json:"tracking_no" gorm:"size:100"`
-	Notes           string     `json:"notes" gorm:"type:text"`
-	CreatedBy       string     `json:"created_by" gorm:"size:64;not null"`
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
-	DeletedAt       *time.Time `json:"deleted_at" gorm:"index"`
+	ID               string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	SOCode           string     `json:"so_code" gorm:"size:50;not null;uniqueIndex"`
+	CustomerID       string     `json:"customer_id" gorm:"type:uuid;not null;index"`
+	Channel          string     `json:"channel" gorm:"size:20;not null;default:DIRECT"`
+	Status           string     `json:"status" gorm:"size:20;not null;default:PENDING"`
+	TotalAmount      float64    `json:"total_amount" gorm:"type:decimal(12,2);default:0"`
+	Currency         string     `json:"currency" gorm:"size:10;not null;default:CNY"`
+	OrderDate        *time.Time `json:"order_date"`
+	ShippingDate     *time.Time `json:"shipping_date"`
+	DeliveredDate    *time.Time `json:"delivered_date"`
+	ShippingAddress  string     `json:"shipping_address" gorm:"size:500"`
+	TrackingNo       string     `json:"tracking_no" gorm:"size:100"`
+	CreditStatus     string     `json:"credit_status" gorm:"size:20"`
+	CreditExposure   float64    `json:"credit_exposure" gorm:"type:decimal(12,2);default:0"` // 确认时客户信用占用（不含本单）
+	CreditApprovedBy string     `json:"credit_approved_by" gorm:"size:64"`
+	CreditApprovedAt *time.Time `json:"credit_approved_at"`
+	CreditNote       string     `json:"credit_note" gorm:"type:text"`
+	Notes            string     `json:"notes" gorm:"type:text"`
+	CreatedBy        string     `json:"created_by" gorm:"size:64;not null"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DeletedAt        *time.Time `json:"deleted_at" gorm:"index"`
 
-	Customer *Customer   `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
-	Items    []SOItem    `json:"items,omitempty" gorm:"foreignKey:SOID"`
+	Customer *Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	Items    []SOItem  `json:"items,omitempty" gorm:"foreignKey:SOID"`
 }
 
 func (SalesOrder) TableName() string {
@@ -59,6 +73,19 @@ const (
 	SOItemStatusClosed   = "CLOSED"
 )
 
+// 订单行交期承诺方式
+const (
+	PromiseModeATP = "ATP" // 可承诺量：现有库存 + 计划接收 - 已承诺需求
+	PromiseModeCTP = "CTP" // 可生产承诺：ATP 不足部分展开 BOM 检查物料与提前期
+)
+
+// 订单行交期承诺结果
+const (
+	PromiseStatusOK          = "OK"          // 要求交期可满足
+	PromiseStatusLate        = "LATE"        // 只能晚于要求交期发货
+	PromiseStatusUnavailable = "UNAVAILABLE" // 计划范围内无法承诺
+)
+
 // SOItem 销售订单明细
 type SOItem struct {
 	ID          string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -71,8 +98,17 @@ type SOItem struct {
 	Amount      float64    `json:"amount" gorm:"type:decimal(12,2);not null"`
 	ShippedQty  float64    `json:"shipped_qty" gorm:"type:decimal(12,4);default:0"`
 	Status      string     `json:"status" gorm:"size:20;not null;default:OPEN"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	DueDate     *time.Time `json:"due_date"` // 要求交期（MRP需求日期）
+
+	PromiseMode   string     `json:"promise_mode" gorm:"size:10"`
+	PromiseStatus string     `json:"promise_status" gorm:"size:20"`
+	ATPQty        float64    `json:"atp_qty" gorm:"type:decimal(12,4);default:0"` // 要求交期的可承诺数量
+	PromisedDate  *time.Time `json:"promised_date"`                               // 最早可发货日期
+	PromiseNote   string     `json:"promise_note" gorm:"size:500"`
+	PromisedAt    *time.Time `json:"promised_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	SalesOrder *SalesOrder `json:"sales_order,omitempty" gorm:"foreignKey:SOID"`
 }
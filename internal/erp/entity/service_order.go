@@ -12,6 +12,13 @@ const (
 	ServiceTypeInquiry  = "INQUIRY"  // 咨询
 )
 
+// 服务工单保修状态
+const (
+	WarrantyStateIn      = "IN"      // 保内
+	WarrantyStateOut     = "OUT"     // 保外
+	WarrantyStateUnknown = "UNKNOWN" // 序列号无发货追溯，发放退货授权时再校验
+)
+
 // ServiceOrderStatus 服务工单状态
 const (
 	SvcStatusCreated      = "CREATED"
@@ -24,23 +31,32 @@ const (
 
 // ServiceOrder 服务工单
 type ServiceOrder struct {
-	ID           string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	ServiceCode  string     `json:"service_code" gorm:"size:50;not null;uniqueIndex"`
-	CustomerID   string     `json:"customer_id" gorm:"type:uuid;not null;index"`
-	ProductSN    string     `json:"product_sn" gorm:"size:100;not null"`
-	ServiceType  string     `json:"service_type" gorm:"size:20;not null"`
-	Status       string     `json:"status" gorm:"size:20;not null;default:CREATED"`
-	Priority     int        `json:"priority" gorm:"default:0"`
-	Description  string     `json:"description" gorm:"type:text;not null"`
-	Solution     string     `json:"solution" gorm:"type:text"`
-	AssigneeID   string     `json:"assignee_id" gorm:"size:64"`
-	AssigneeName string     `json:"assignee_name" gorm:"size:100"`
-	Notes        string     `json:"notes" gorm:"type:text"`
-	CreatedBy    string     `json:"created_by" gorm:"size:64;not null"`
-	CompletedAt  *time.Time `json:"completed_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
-	DeletedAt    *time.Time `json:"deleted_at" gorm:"index"`
+	ID            string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ServiceCode   string     `json:"service_code" gorm:"size:50;not null;uniqueIndex"`
+	CustomerID    string     `json:"customer_id" gorm:"type:uuid;not null;index"`
+	ProductSN     string     `json:"product_sn" gorm:"size:100;not null"`
+	ProductID     string     `json:"product_id" gorm:"size:32;index"`
+	ShippedSOID   string     `json:"shipped_so_id" gorm:"size:64"` // 序列号原发货订单
+	ShippedAt     *time.Time `json:"shipped_at"`
+	WarrantyUntil *time.Time `json:"warranty_until"`
+	InWarranty    bool       `json:"in_warranty"`
+	WarrantyState string     `json:"warranty_state" gorm:"size:10"` // IN, OUT, UNKNOWN
+	RMAID         string     `json:"rma_id" gorm:"size:64;index"`
+	RMACode       string     `json:"rma_code" gorm:"size:50"`
+	ServiceType   string     `json:"service_type" gorm:"size:20;not null"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:CREATED"`
+	Priority      int        `json:"priority" gorm:"default:0"`
+	Description   string     `json:"description" gorm:"type:text;not null"`
+	Solution      string     `json:"solution" gorm:"type:text"`
+	FailureCode   string     `json:"failure_code" gorm:"size:32;index"` // 标准故障代码，见 FailureCode
+	AssigneeID    string     `json:"assignee_id" gorm:"size:64"`
+	AssigneeName  string     `json:"assignee_name" gorm:"size:100"`
+	Notes         string     `json:"notes" gorm:"type:text"`
+	CreatedBy     string     `json:"created_by" gorm:"size:64;not null"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at" gorm:"index"`
 
 	Customer *Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
 }
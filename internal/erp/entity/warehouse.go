@@ -36,13 +36,14 @@ type WarehouseZone struct {
 	WarehouseID string     `json:"warehouse_id" gorm:"type:uuid;not null;index"`
 	Code        string     `json:"code" gorm:"size:50;not null"`
 	Name        string     `json:"name" gorm:"size:100;not null"`
-	ZoneType    string     `json:"zone_type" gorm:"size:20"` // RAW, WIP, FG, SPARE
+	ZoneType    string     `json:"zone_type" gorm:"size:20"`  // RAW, WIP, FG, SPARE
+	Sequence    int        `json:"sequence" gorm:"default:0"` // 拣货路径顺序
 	Status      string     `json:"status" gorm:"size:20;not null;default:ACTIVE"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 	DeletedAt   *time.Time `json:"deleted_at" gorm:"index"`
 
-	Warehouse *Warehouse         `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
+	Warehouse *Warehouse          `json:"warehouse,omitempty" gorm:"foreignKey:WarehouseID"`
 	Locations []WarehouseLocation `json:"locations,omitempty" gorm:"foreignKey:ZoneID"`
 }
 
@@ -56,7 +57,8 @@ type WarehouseLocation struct {
 	ZoneID    string     `json:"zone_id" gorm:"type:uuid;not null;index"`
 	Code      string     `json:"code" gorm:"size:50;not null"`
 	Name      string     `json:"name" gorm:"size:100"`
-	Capacity  float64    `json:"capacity" gorm:"type:decimal(12,4);default:0"`
+	Capacity  float64    `json:"capacity" gorm:"type:decimal(12,4);default:0"` // 0=不限
+	Sequence  int        `json:"sequence" gorm:"default:0"`                    // 库区内拣货路径顺序
 	Status    string     `json:"status" gorm:"size:20;not null;default:ACTIVE"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
@@ -68,3 +70,24 @@ type WarehouseLocation struct {
 func (WarehouseLocation) TableName() string {
 	return "erp_warehouse_locations"
 }
+
+// PutawayRule 上架规则：按物料类别/库存类型指定目标库区，按优先级匹配
+type PutawayRule struct {
+	ID                 string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	WarehouseID        string     `json:"warehouse_id" gorm:"type:uuid;not null;index"`
+	Priority           int        `json:"priority" gorm:"not null;default:0"`  // 数值越小越优先
+	MaterialCategoryID string     `json:"material_category_id" gorm:"size:32"` // 空=任意类别
+	InventoryType      string     `json:"inventory_type" gorm:"size:10"`       // 空=任意类型
+	ZoneID             string     `json:"zone_id" gorm:"size:64"`              // 目标库区，空则按库区类型
+	ZoneType           string     `json:"zone_type" gorm:"size:20"`            // 目标库区类型 RAW, WIP, FG, SPARE
+	Status             string     `json:"status" gorm:"size:20;not null;default:ACTIVE"`
+	Notes              string     `json:"notes" gorm:"type:text"`
+	CreatedBy          string     `json:"created_by" gorm:"size:64"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	DeletedAt          *time.Time `json:"deleted_at" gorm:"index"`
+}
+
+func (PutawayRule) TableName() string {
+	return "erp_putaway_rules"
+}
@@ -0,0 +1,87 @@
+package entity
+
+import (
+	"time"
+)
+
+// FailureCodeStatus 故障代码状态
+const (
+	FailureCodeActive   = "ACTIVE"
+	FailureCodeInactive = "INACTIVE"
+)
+
+// FailureCode 故障代码：服务工单与保修索赔的标准化故障分类
+type FailureCode struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Code        string    `json:"code" gorm:"size:32;not null;uniqueIndex"`
+	Name        string    `json:"name" gorm:"size:100;not null"`
+	Category    string    `json:"category" gorm:"size:50;index"` // 电气、结构、软件、外观 ...
+	Description string    `json:"description" gorm:"type:text"`
+	Status      string    `json:"status" gorm:"size:20;not null;default:ACTIVE"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (FailureCode) TableName() string {
+	return "erp_failure_codes"
+}
+
+// WarrantyClaimStatus 保修索赔状态
+const (
+	ClaimStatusOpen        = "OPEN"
+	ClaimStatusApproved    = "APPROVED"
+	ClaimStatusRejected    = "REJECTED"
+	ClaimStatusChargedBack = "CHARGED_BACK" // 已向供应商索赔
+)
+
+// WarrantyClaimReferenceType 供应商索赔财务记录的参考类型
+const WarrantyClaimReferenceType = "WARRANTY_CLAIM"
+
+// WarrantyClaim 保修索赔：服务工单的现场故障，关联序列号的生产工单、BOM 版本与责任组件批次
+type WarrantyClaim struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ClaimCode      string     `json:"claim_code" gorm:"size:50;not null;uniqueIndex"`
+	ServiceOrderID string     `json:"service_order_id" gorm:"type:uuid;not null;index"`
+	ServiceCode    string     `json:"service_code" gorm:"size:50"`
+	RMAID          string     `json:"rma_id" gorm:"size:64"`
+	RMACode        string     `json:"rma_code" gorm:"size:50"`
+	CustomerID     string     `json:"customer_id" gorm:"type:uuid;index"`
+	SerialNo       string     `json:"serial_no" gorm:"size:100;not null;index"`
+	ProductID      string     `json:"product_id" gorm:"size:32;index"`
+	ProductCode    string     `json:"product_code" gorm:"size:64"`
+	FailureCode    string     `json:"failure_code" gorm:"size:32;not null;index"`
+	Description    string     `json:"description" gorm:"type:text"`
+	InWarranty     bool       `json:"in_warranty"`
+	ShippedAt      *time.Time `json:"shipped_at"`
+	FailedAt       time.Time  `json:"failed_at"`     // 服务工单报修时间
+	DaysInField    int        `json:"days_in_field"` // 发货到报修的天数
+
+	// 序列号生产谱系
+	BuildWOID   string `json:"build_wo_id" gorm:"size:64;index"`
+	BuildWOCode string `json:"build_wo_code" gorm:"size:50"`
+	BOMID       string `json:"bom_id" gorm:"size:32"`
+	BOMVersion  string `json:"bom_version" gorm:"size:16;index"`
+
+	// 责任组件：工程分析后归因到具体组件批次与供应商
+	ComponentMaterialID string `json:"component_material_id" gorm:"size:32;index"`
+	ComponentCode       string `json:"component_code" gorm:"size:64"`
+	ComponentLotNo      string `json:"component_lot_no" gorm:"size:50;index"`
+	SupplierID          string `json:"supplier_id" gorm:"size:64;index"`
+	SupplierName        string `json:"supplier_name" gorm:"size:200"`
+
+	ClaimCost        float64    `json:"claim_cost" gorm:"type:decimal(12,2);default:0"` // 维修/换货/退款成本
+	Status           string     `json:"status" gorm:"size:20;not null;default:OPEN"`
+	ReviewNote       string     `json:"review_note" gorm:"type:text"`
+	ReviewedBy       string     `json:"reviewed_by" gorm:"size:64"`
+	ReviewedAt       *time.Time `json:"reviewed_at"`
+	ChargebackAmount float64    `json:"chargeback_amount" gorm:"type:decimal(12,2);default:0"`
+	ChargebackID     string     `json:"chargeback_id" gorm:"size:64"` // 供应商应收财务记录
+	ChargebackCode   string     `json:"chargeback_code" gorm:"size:50"`
+	CreatedBy        string     `json:"created_by" gorm:"size:64;not null"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func (WarrantyClaim) TableName() string {
+	return "erp_warranty_claims"
+}
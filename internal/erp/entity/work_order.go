@@ -14,6 +14,13 @@ const (
 	WOStatusClosed     = "CLOSED"
 )
 
+// IssueMethod 组件发料方式
+const (
+	IssueMethodManual              = "MANUAL"               // 手工领料
+	IssueMethodBackflushOperation  = "BACKFLUSH_OPERATION"  // 工序报工时倒冲
+	IssueMethodBackflushCompletion = "BACKFLUSH_COMPLETION" // 完工时倒冲
+)
+
 // WorkOrder 生产工单
 type WorkOrder struct {
 	ID           string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -23,6 +30,8 @@ type WorkOrder struct {
 	ProductName  string     `json:"product_name" gorm:"size:128"`
 	BOMID        string     `json:"bom_id" gorm:"size:32;not null"`
 	BOMVersion   string     `json:"bom_version" gorm:"size:16"`
+	RouteID      string     `json:"route_id" gorm:"size:32"` // PLM 工艺路线
+	RouteVersion string     `json:"route_version" gorm:"size:16"`
 	PlannedQty   float64    `json:"planned_qty" gorm:"type:decimal(12,4);not null"`
 	CompletedQty float64    `json:"completed_qty" gorm:"type:decimal(12,4);default:0"`
 	ScrapQty     float64    `json:"scrap_qty" gorm:"type:decimal(12,4);default:0"`
@@ -32,7 +41,10 @@ type WorkOrder struct {
 	PlannedEnd   *time.Time `json:"planned_end"`
 	ActualStart  *time.Time `json:"actual_start"`
 	ActualEnd    *time.Time `json:"actual_end"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	ClosedBy     string     `json:"closed_by" gorm:"size:64"`
 	WarehouseID  string     `json:"warehouse_id" gorm:"type:uuid"` // 成品入库仓库
+	IssueWHID    string     `json:"issue_wh_id" gorm:"size:64"`    // 领料/倒冲仓库
 	SourceType   string     `json:"source_type" gorm:"size:20"`    // MRP, MANUAL
 	SourceID     string     `json:"source_id" gorm:"size:64"`
 	Notes        string     `json:"notes" gorm:"type:text"`
@@ -41,8 +53,9 @@ type WorkOrder struct {
 	UpdatedAt    time.Time  `json:"updated_at"`
 	DeletedAt    *time.Time `json:"deleted_at" gorm:"index"`
 
-	Materials []WorkOrderMaterial `json:"materials,omitempty" gorm:"foreignKey:WorkOrderID"`
-	Reports   []WorkOrderReport   `json:"reports,omitempty" gorm:"foreignKey:WorkOrderID"`
+	Materials  []WorkOrderMaterial  `json:"materials,omitempty" gorm:"foreignKey:WorkOrderID"`
+	Reports    []WorkOrderReport    `json:"reports,omitempty" gorm:"foreignKey:WorkOrderID"`
+	Operations []WorkOrderOperation `json:"operations,omitempty" gorm:"foreignKey:WorkOrderID"`
 }
 
 func (WorkOrder) TableName() string {
@@ -57,8 +70,14 @@ type WorkOrderMaterial struct {
 	MaterialCode string    `json:"material_code" gorm:"size:64"`
 	MaterialName string    `json:"material_name" gorm:"size:128"`
 	RequiredQty  float64   `json:"required_qty" gorm:"type:decimal(12,4);not null"`
+	QtyPer       float64   `json:"qty_per" gorm:"type:decimal(12,6);default:0"` // 单位用量
 	IssuedQty    float64   `json:"issued_qty" gorm:"type:decimal(12,4);default:0"`
+	ReturnedQty  float64   `json:"returned_qty" gorm:"type:decimal(12,4);default:0"`
+	IssueMethod  string    `json:"issue_method" gorm:"size:30;not null;default:MANUAL"`
+	VarianceQty  float64   `json:"variance_qty" gorm:"type:decimal(12,4);default:0"` // 关闭时的用量差异（净发料-理论用量）
 	Unit         string    `json:"unit" gorm:"size:20;not null;default:pcs"`
+	OperationID  string    `json:"operation_id" gorm:"size:64;index"` // 领料工序，空=工单级
+	OperationSeq int       `json:"operation_seq"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -82,3 +101,15 @@ type WorkOrderReport struct {
 func (WorkOrderReport) TableName() string {
 	return "erp_work_order_reports"
 }
+
+// MaterialIssueMethod 物料默认发料方式，创建工单时带入
+type MaterialIssueMethod struct {
+	MaterialID  string    `json:"material_id" gorm:"primaryKey;size:32"`
+	IssueMethod string    `json:"issue_method" gorm:"size:30;not null"`
+	UpdatedBy   string    `json:"updated_by" gorm:"size:64"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (MaterialIssueMethod) TableName() string {
+	return "erp_material_issue_methods"
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CycleCountHandler struct {
+	svc *service.CycleCountService
+}
+
+func NewCycleCountHandler(svc *service.CycleCountService) *CycleCountHandler {
+	return &CycleCountHandler{svc: svc}
+}
+
+// ClassifyABC 重新计算物料 ABC 分类
+func (h *CycleCountHandler) ClassifyABC(c *gin.Context) {
+	var req service.ClassifyRequest
+	_ = c.ShouldBindJSON(&req)
+	result, err := h.svc.ClassifyABC(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": result})
+}
+
+func (h *CycleCountHandler) ListABC(c *gin.Context) {
+	items, err := h.svc.ListABC(c.Query("abc_class"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *CycleCountHandler) ListPolicies(c *gin.Context) {
+	items, err := h.svc.ListPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *CycleCountHandler) SavePolicies(c *gin.Context) {
+	var req []service.SavePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	items, err := h.svc.SavePolicies(req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+// Generate 立即按 ABC 排期生成到期盘点任务
+func (h *CycleCountHandler) Generate(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	counts, err := h.svc.GenerateDue(time.Now(), "MANUAL", userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": counts})
+}
+
+func (h *CycleCountHandler) Create(c *gin.Context) {
+	var req service.CreateCycleCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	count, err := h.svc.Create(req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": count})
+}
+
+func (h *CycleCountHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	items, total, err := h.svc.List(repository.CycleCountListParams{
+		WarehouseID: c.Query("warehouse_id"),
+		Status:      c.Query("status"),
+		Page:        page,
+		Size:        size,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": gin.H{"items": items, "total": total, "page": page, "size": size}})
+}
+
+func (h *CycleCountHandler) Get(c *gin.Context) {
+	count, err := h.svc.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": "盘点任务不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": count})
+}
+
+// Start 开始盘点并冻结库位
+func (h *CycleCountHandler) Start(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	count, err := h.svc.Start(c.Param("id"), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": count})
+}
+
+// SubmitResults 录入实盘数量
+func (h *CycleCountHandler) SubmitResults(c *gin.Context) {
+	var req service.SubmitCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	count, err := h.svc.SubmitResults(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": count})
+}
+
+// Approve 审批超容差差异
+func (h *CycleCountHandler) Approve(c *gin.Context) {
+	var req service.ApproveCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	count, err := h.svc.Approve(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": count})
+}
+
+// Post 过账盘点差异
+func (h *CycleCountHandler) Post(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	count, err := h.svc.Post(c.Param("id"), userID.(string))
+	if err != nil {
+		inventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": count})
+}
+
+func (h *CycleCountHandler) Cancel(c *gin.Context) {
+	if err := h.svc.Cancel(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+}
+
+// Accuracy 库存准确率，默认最近30天
+func (h *CycleCountHandler) Accuracy(c *gin.Context) {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "from 日期格式应为 YYYY-MM-DD"})
+			return
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "to 日期格式应为 YYYY-MM-DD"})
+			return
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	items, err := h.svc.Accuracy(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/gin-gonic/gin"
+)
+
+type GenealogyHandler struct {
+	svc *service.GenealogyService
+}
+
+func NewGenealogyHandler(svc *service.GenealogyService) *GenealogyHandler {
+	return &GenealogyHandler{svc: svc}
+}
+
+// TraceBackward 反向追溯 ?serial_no= 或 ?material_id=&lot_no=
+func (h *GenealogyHandler) TraceBackward(c *gin.Context) {
+	var req service.BackwardTraceRequest
+	c.ShouldBindQuery(&req)
+	node, err := h.svc.TraceBackward(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": node})
+}
+
+// TraceForward 正向追溯 ?lot_no=&material_id=&supplier_id=
+func (h *GenealogyHandler) TraceForward(c *gin.Context) {
+	var req service.ForwardTraceRequest
+	c.ShouldBindQuery(&req)
+	result, err := h.svc.TraceForward(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": result})
+}
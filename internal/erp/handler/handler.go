@@ -9,7 +9,14 @@ type Handlers struct {
 	Inventory     *InventoryHandler
 	Manufacturing *ManufacturingHandler
 	MRP           *MRPHandler
+	MPS           *MPSHandler
 	Sales         *SalesHandler
+	Genealogy     *GenealogyHandler
+	Warehouse     *WarehouseHandler
+	CycleCount    *CycleCountHandler
+	WorkCenter    *WorkCenterHandler
+	RMA           *RMAHandler
+	Warranty      *WarrantyHandler
 }
 
 func NewHandlers(services *service.Services) *Handlers {
@@ -19,6 +26,13 @@ func NewHandlers(services *service.Services) *Handlers {
 		Inventory:     NewInventoryHandler(services.Inventory),
 		Manufacturing: NewManufacturingHandler(services.Manufacturing),
 		MRP:           NewMRPHandler(services.MRP),
+		MPS:           NewMPSHandler(services.MPS),
 		Sales:         NewSalesHandler(services.Sales),
+		Genealogy:     NewGenealogyHandler(services.Genealogy),
+		Warehouse:     NewWarehouseHandler(services.Warehouse),
+		CycleCount:    NewCycleCountHandler(services.CycleCount),
+		WorkCenter:    NewWorkCenterHandler(services.WorkCenter),
+		RMA:           NewRMAHandler(services.RMA),
+		Warranty:      NewWarrantyHandler(services.Warranty),
 	}
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -55,7 +56,7 @@ func (h *InventoryHandler) Inbound(c *gin.Context) {
 	}
 	userID, _ := c.Get("user_id")
 	if err := h.svc.Inbound(req, userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		inventoryError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
@@ -69,7 +70,7 @@ func (h *InventoryHandler) Outbound(c *gin.Context) {
 	}
 	userID, _ := c.Get("user_id")
 	if err := h.svc.Outbound(req, userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		inventoryError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
@@ -83,7 +84,7 @@ func (h *InventoryHandler) Adjust(c *gin.Context) {
 	}
 	userID, _ := c.Get("user_id")
 	if err := h.svc.Adjust(req, userID.(string)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		inventoryError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
@@ -109,3 +110,82 @@ func (h *InventoryHandler) Transactions(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": gin.H{"items": txs, "total": total, "page": page, "size": size}})
 }
+
+// inventoryError 库存不足返回400，并发修改冲突、库位盘点冻结返回409
+func inventoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrInventoryConflict), errors.Is(err, repository.ErrBinFrozen):
+		c.JSON(http.StatusConflict, gin.H{"code": 10004, "message": err.Error()})
+	case errors.Is(err, repository.ErrInsufficientStock):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+	}
+}
+
+func (h *InventoryHandler) Reserve(c *gin.Context) {
+	var req service.ReserveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	res, err := h.svc.Reserve(req, userID.(string))
+	if err != nil {
+		inventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": res})
+}
+
+func (h *InventoryHandler) ReleaseReservation(c *gin.Context) {
+	res, err := h.svc.ReleaseReservation(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": res})
+}
+
+func (h *InventoryHandler) ListReservations(c *gin.Context) {
+	items, err := h.svc.ListReservations(c.Query("material_id"), c.Query("reference_id"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *InventoryHandler) Reconcile(c *gin.Context) {
+	var req struct {
+		Fix bool `json:"fix"` // 按流水重建有差异的结存
+	}
+	c.ShouldBindJSON(&req)
+	userID, _ := c.Get("user_id")
+	result, err := h.svc.Reconcile(req.Fix, "MANUAL", userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": result})
+}
+
+func (h *InventoryHandler) ListReconciliations(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	items, err := h.svc.ListReconciliations(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+// ListMigrations 历史库存迁移记录（?status=CONFLICT 为冲突报告）
+func (h *InventoryHandler) ListMigrations(c *gin.Context) {
+	items, err := h.svc.ListMigrations(c.Query("source_type"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
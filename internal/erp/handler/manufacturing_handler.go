@@ -68,15 +68,13 @@ func (h *ManufacturingHandler) Release(c *gin.Context) {
 }
 
 func (h *ManufacturingHandler) Pick(c *gin.Context) {
-	var req struct {
-		WarehouseID string `json:"warehouse_id" binding:"required"`
-	}
+	var req service.PickRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
 		return
 	}
 	userID, _ := c.Get("user_id")
-	if err := h.svc.Pick(c.Param("id"), req.WarehouseID, userID.(string)); err != nil {
+	if err := h.svc.Pick(c.Param("id"), req, userID.(string)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
 		return
 	}
@@ -98,14 +96,115 @@ func (h *ManufacturingHandler) Report(c *gin.Context) {
 }
 
 func (h *ManufacturingHandler) Complete(c *gin.Context) {
-	var req struct {
-		WarehouseID string `json:"warehouse_id"`
-	}
+	var req service.CompleteRequest
 	c.ShouldBindJSON(&req)
 	userID, _ := c.Get("user_id")
-	if err := h.svc.Complete(c.Param("id"), req.WarehouseID, userID.(string)); err != nil {
+	if err := h.svc.Complete(c.Param("id"), req, userID.(string)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+}
+
+// ReportOperation 工序报工
+func (h *ManufacturingHandler) ReportOperation(c *gin.Context) {
+	var req service.OperationReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	op, err := h.svc.ReportOperation(c.Param("id"), c.Param("op_id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": op})
+}
+
+func (h *ManufacturingHandler) ListOperationReports(c *gin.Context) {
+	reports, err := h.svc.ListOperationReports(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": reports})
+}
+
+// SetMaterialIssueMethod 修改工单物料的发料方式
+func (h *ManufacturingHandler) SetMaterialIssueMethod(c *gin.Context) {
+	var req service.SetIssueMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	mat, err := h.svc.SetMaterialIssueMethod(c.Param("id"), c.Param("material_id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": mat})
+}
+
+func (h *ManufacturingHandler) ListIssueMethods(c *gin.Context) {
+	items, err := h.svc.ListDefaultIssueMethods()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+// SaveIssueMethod 设置物料默认发料方式
+func (h *ManufacturingHandler) SaveIssueMethod(c *gin.Context) {
+	var req service.SaveIssueMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	item, err := h.svc.SaveDefaultIssueMethod(req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": item})
+}
+
+// ReturnMaterials 生产退料
+func (h *ManufacturingHandler) ReturnMaterials(c *gin.Context) {
+	var req service.ReturnMaterialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	if err := h.svc.ReturnMaterials(c.Param("id"), req, userID.(string)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
 }
+
+// CloseoutReport 工单物料、报废与产出差异
+func (h *ManufacturingHandler) CloseoutReport(c *gin.Context) {
+	report, err := h.svc.CloseoutReport(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": report})
+}
+
+// Close 工单结案
+func (h *ManufacturingHandler) Close(c *gin.Context) {
+	var req service.CloseWorkOrderRequest
+	c.ShouldBindJSON(&req)
+	userID, _ := c.Get("user_id")
+	report, err := h.svc.Close(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": report})
+}
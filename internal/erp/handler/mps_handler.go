@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/gin-gonic/gin"
+)
+
+type MPSHandler struct {
+	svc *service.MPSService
+}
+
+func NewMPSHandler(svc *service.MPSService) *MPSHandler {
+	return &MPSHandler{svc: svc}
+}
+
+func (h *MPSHandler) ListForecasts(c *gin.Context) {
+	params := repository.ForecastListParams{ProductID: c.Query("product_id")}
+	if from, err := time.ParseInLocation("2006-01-02", c.Query("from"), time.Local); err == nil {
+		params.From = &from
+	}
+	if to, err := time.ParseInLocation("2006-01-02", c.Query("to"), time.Local); err == nil {
+		params.To = &to
+	}
+	items, err := h.svc.ListForecasts(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *MPSHandler) SaveForecast(c *gin.Context) {
+	var req service.SaveForecastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	f, err := h.svc.SaveForecast(req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": f})
+}
+
+func (h *MPSHandler) ImportForecasts(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "请上传CSV文件"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	defer f.Close()
+	userID, _ := c.Get("user_id")
+	result, err := h.svc.ImportForecastCSV(f, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": result})
+}
+
+func (h *MPSHandler) DeleteForecast(c *gin.Context) {
+	if err := h.svc.DeleteForecast(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+}
+
+func (h *MPSHandler) Generate(c *gin.Context) {
+	var req service.GenerateMPSRequest
+	c.ShouldBindJSON(&req)
+	result, err := h.svc.Generate(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": result})
+}
+
+func (h *MPSHandler) ListEntries(c *gin.Context) {
+	horizon, _ := strconv.Atoi(c.Query("horizon"))
+	items, err := h.svc.ListEntries(c.Query("product_id"), horizon)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *MPSHandler) FirmEntry(c *gin.Context) {
+	var req service.FirmMPSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	entry, err := h.svc.FirmEntry(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": entry})
+}
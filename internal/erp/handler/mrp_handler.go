@@ -73,3 +73,50 @@ func (h *MRPHandler) Apply(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
 }
+
+func (h *MRPHandler) CompareRuns(c *gin.Context) {
+	runA, runB := c.Query("run_a"), c.Query("run_b")
+	if runA == "" || runB == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "需指定run_a和run_b"})
+		return
+	}
+	cmp, err := h.svc.CompareRuns(runA, runB, c.Query("changed_only") == "true")
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": cmp})
+}
+
+func (h *MRPHandler) GetPegging(c *gin.Context) {
+	view, err := h.svc.GetPegging(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": view})
+}
+
+func (h *MRPHandler) ListPlanningParams(c *gin.Context) {
+	items, err := h.svc.ListPlanningParams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *MRPHandler) SavePlanningParams(c *gin.Context) {
+	var req service.SavePlanningParamsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	p, err := h.svc.SavePlanningParams(req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": p})
+}
@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	plmEntity "github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/google/uuid"
+)
+
+const (
+	mrpTestProduct  = "prod-mrp"
+	mrpTestMaterial = "mat-mrp"
+)
+
+// setupMRPTest 成品 FG-MRP 由 2 个 RM-MRP 组成，原料库存 5，已确认订单需求 10
+func setupMRPTest(t *testing.T) *testutil.TestEnv {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	if err := db.AutoMigrate(
+		&plmEntity.Product{},
+		&plmEntity.Material{},
+		&plmEntity.BOMHeader{},
+		&plmEntity.BOMItem{},
+		&plmEntity.ProcessRoute{},
+		&plmEntity.ProcessStep{},
+		&plmEntity.ProcessStepMaterial{},
+		&entity.Customer{},
+		&entity.SalesOrder{},
+		&entity.SOItem{},
+		&entity.MRPRun{},
+		&entity.MRPResult{},
+		&entity.MRPPegging{},
+		&entity.MaterialPlanning{},
+		&entity.MPSEntry{},
+		&entity.Inventory{},
+		&entity.InventoryTransaction{},
+		&entity.PurchaseRequisition{},
+		&entity.PurchaseOrder{},
+		&entity.POItem{},
+		&entity.WorkOrder{},
+		&entity.WorkOrderMaterial{},
+		&entity.WorkOrderOperation{},
+		&entity.MaterialIssueMethod{},
+	); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
+	}
+
+	bomID := "bom-mrp"
+	due := time.Now().AddDate(0, 0, 14)
+	soID := uuid.New().String()
+	seeds := []interface{}{
+		&plmEntity.Product{ID: mrpTestProduct, Code: "FG-MRP", Name: "计划测试成品", CategoryID: "cat", Status: "active", CreatedBy: "test"},
+		&plmEntity.Material{ID: mrpTestMaterial, Code: "RM-MRP", Name: "计划测试原料", CategoryID: "cat", Unit: "pcs", LeadTimeDays: 5, CreatedBy: "test"},
+		&plmEntity.BOMHeader{ID: bomID, ProductID: mrpTestProduct, Version: "1.0", Status: plmEntity.BOMStatusReleased, CreatedBy: "test"},
+		&plmEntity.BOMItem{ID: "bom-mrp-1", BOMHeaderID: bomID, MaterialID: mrpTestMaterial, Level: 1, Sequence: 1, Quantity: 2, Unit: "pcs"},
+		&entity.SalesOrder{ID: soID, SOCode: "SO-MRP-001", CustomerID: uuid.New().String(), Status: entity.SOStatusConfirmed, CreatedBy: "test"},
+		&entity.SOItem{ID: uuid.New().String(), SOID: soID, ProductID: mrpTestProduct, ProductCode: "FG-MRP", Quantity: 10, UnitPrice: 100, Amount: 1000, DueDate: &due},
+		&entity.Inventory{ID: uuid.New().String(), MaterialID: mrpTestMaterial, WarehouseID: uuid.New().String(), Quantity: 5, AvailableQty: 5},
+	}
+	for _, seed := range seeds {
+		if err := db.Create(seed).Error; err != nil {
+			t.Fatalf("Failed to seed %T: %v", seed, err)
+		}
+	}
+
+	handlers := NewHandlers(service.NewServices(repository.NewRepositories(db), db))
+	router := testutil.SetupRouter()
+	api := testutil.AuthGroup(router, "/api/v1/erp")
+	api.POST("/mrp/run", handlers.MRP.Run)
+	api.GET("/mrp/result", handlers.MRP.GetResult)
+	api.POST("/mrp/apply", handlers.MRP.Apply)
+	api.GET("/mrp/compare", handlers.MRP.CompareRuns)
+
+	return &testutil.TestEnv{DB: db, Router: router, T: t}
+}
+
+func runMRPForTest(t *testing.T, env *testutil.TestEnv, body map[string]interface{}) string {
+	t.Helper()
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/mrp/run", body, testutil.DefaultTestToken())
+	if w.Code != http.StatusOK {
+		t.Fatalf("run: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	run := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	if run["status"] != entity.MRPStatusCompleted {
+		t.Fatalf("expected run COMPLETED, got %v: %v", run["status"], run["error_message"])
+	}
+	return run["id"].(string)
+}
+
+// plannedQty 某次运行中物料的计划订单数量合计
+func plannedQty(env *testutil.TestEnv, runID, materialID string) float64 {
+	var total float64
+	env.DB.Model(&entity.MRPResult{}).Where("mrp_run_id = ? AND material_id = ?", runID, materialID).
+		Select("COALESCE(SUM(planned_order_qty), 0)").Scan(&total)
+	return total
+}
+
+// TestMRPRunExplodesBOM 成品按订单需求下达，原料按BOM用量展开并扣减库存，需求追溯到销售订单
+func TestMRPRunExplodesBOM(t *testing.T) {
+	env := setupMRPTest(t)
+	runID := runMRPForTest(t, env, map[string]interface{}{"bucket_type": entity.MRPBucketDay})
+
+	if got := plannedQty(env, runID, mrpTestProduct); got != 10 {
+		t.Fatalf("expected 10 planned for the product, got %v", got)
+	}
+	if got := plannedQty(env, runID, mrpTestMaterial); got != 15 {
+		t.Fatalf("expected 15 planned for the material (20 gross - 5 on hand), got %v", got)
+	}
+
+	var pegs []entity.MRPPegging
+	env.DB.Where("mrp_run_id = ?", runID).Find(&pegs)
+	if len(pegs) == 0 {
+		t.Fatal("expected peggings for the run")
+	}
+	for _, p := range pegs {
+		if p.SOCode != "SO-MRP-001" {
+			t.Fatalf("expected pegging to SO-MRP-001, got %q", p.SOCode)
+		}
+	}
+
+	w := testutil.DoRequest(env.Router, http.MethodGet, "/api/v1/erp/mrp/result?run_id="+runID, nil, testutil.DefaultTestToken())
+	if w.Code != http.StatusOK {
+		t.Fatalf("result: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if rows := testutil.ParseResponse(w)["data"].([]interface{}); len(rows) == 0 {
+		t.Fatal("expected result rows")
+	}
+}
+
+// TestMRPApply 应用后原料生成采购需求、成品生成工单；同一运行不能重复应用，模拟运行不能应用
+func TestMRPApply(t *testing.T) {
+	env := setupMRPTest(t)
+	token := testutil.DefaultTestToken()
+	runID := runMRPForTest(t, env, map[string]interface{}{})
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/mrp/apply", map[string]interface{}{"run_id": runID}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("apply: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var prQty float64
+	env.DB.Model(&entity.PurchaseRequisition{}).Where("source_id = ? AND material_id = ?", runID, mrpTestMaterial).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&prQty)
+	if prQty != 15 {
+		t.Fatalf("expected PR quantity 15, got %v", prQty)
+	}
+	var wos []entity.WorkOrder
+	env.DB.Where("source_id = ?", runID).Find(&wos)
+	if len(wos) != 1 || wos[0].ProductID != mrpTestProduct || wos[0].PlannedQty != 10 {
+		t.Fatalf("expected one work order for 10 %s, got %+v", mrpTestProduct, wos)
+	}
+	var run entity.MRPRun
+	env.DB.Where("id = ?", runID).First(&run)
+	if run.Status != entity.MRPStatusApplied || run.WOsGenerated != 1 {
+		t.Fatalf("expected APPLIED with 1 work order, got %s / %d", run.Status, run.WOsGenerated)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/mrp/apply", map[string]interface{}{"run_id": runID}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 on second apply, got %d: %s", w.Code, w.Body.String())
+	}
+
+	simID := runMRPForTest(t, env, map[string]interface{}{"run_type": entity.MRPRunSimulation})
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/mrp/apply", map[string]interface{}{"run_id": simID}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when applying a simulation, got %d: %s", w.Code, w.Body.String())
+	}
+	var woCount int64
+	env.DB.Model(&entity.WorkOrder{}).Count(&woCount)
+	if woCount != 1 {
+		t.Fatalf("expected 1 work order in total, got %d", woCount)
+	}
+}
+
+// TestMRPNetChange 净变更沿用基准运行结果，只重算有变动的物料及其下层
+func TestMRPNetChange(t *testing.T) {
+	env := setupMRPTest(t)
+	netChange := map[string]interface{}{"run_type": entity.MRPRunNetChange}
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/mrp/run", netChange, testutil.DefaultTestToken())
+	if w.Code == http.StatusOK {
+		t.Fatalf("expected net change without a base run to fail, got %s", w.Body.String())
+	}
+	var runs int64
+	env.DB.Model(&entity.MRPRun{}).Count(&runs)
+	if runs != 0 {
+		t.Fatalf("expected no run record, got %d", runs)
+	}
+
+	baseID := runMRPForTest(t, env, map[string]interface{}{})
+	unchangedID := runMRPForTest(t, env, netChange)
+	var unchanged entity.MRPRun
+	env.DB.Where("id = ?", unchangedID).First(&unchanged)
+	if unchanged.BaseRunID != baseID || unchanged.ChangedItems != 0 {
+		t.Fatalf("expected base %s with 0 changed items, got %s / %d", baseID, unchanged.BaseRunID, unchanged.ChangedItems)
+	}
+	var fresh int64
+	env.DB.Model(&entity.MRPResult{}).Where("mrp_run_id = ? AND NOT carried", unchangedID).Count(&fresh)
+	if fresh != 0 {
+		t.Fatalf("expected every result carried from the base run, got %d recomputed", fresh)
+	}
+	if got := plannedQty(env, unchangedID, mrpTestMaterial); got != 15 {
+		t.Fatalf("expected carried material plan 15, got %v", got)
+	}
+
+	// 原料入库后只重算原料，成品沿用基准结果
+	var inv entity.Inventory
+	env.DB.Where("material_id = ?", mrpTestMaterial).First(&inv)
+	env.DB.Model(&inv).Updates(map[string]interface{}{"quantity": 25, "available_qty": 25})
+	if err := env.DB.Create(&entity.InventoryTransaction{
+		ID: uuid.New().String(), MaterialID: mrpTestMaterial, WarehouseID: inv.WarehouseID,
+		TransactionType: "IN", Quantity: 20, InventoryID: inv.ID, BalanceAfter: 25,
+	}).Error; err != nil {
+		t.Fatalf("Failed to seed transaction: %v", err)
+	}
+	changedID := runMRPForTest(t, env, netChange)
+	var changed entity.MRPRun
+	env.DB.Where("id = ?", changedID).First(&changed)
+	if changed.ChangedItems != 1 {
+		t.Fatalf("expected 1 changed item, got %d", changed.ChangedItems)
+	}
+	if got := plannedQty(env, changedID, mrpTestMaterial); got != 0 {
+		t.Fatalf("expected no material order after receipt, got %v", got)
+	}
+	var carriedProduct int64
+	env.DB.Model(&entity.MRPResult{}).Where("mrp_run_id = ? AND material_id = ? AND carried", changedID, mrpTestProduct).Count(&carriedProduct)
+	if carriedProduct == 0 || plannedQty(env, changedID, mrpTestProduct) != 10 {
+		t.Fatal("expected the product plan carried from the base run")
+	}
+}
+
+// TestMRPSimulationCompare 模拟场景覆盖需求、库存和提前期，与实际运行对比差异
+func TestMRPSimulationCompare(t *testing.T) {
+	env := setupMRPTest(t)
+	token := testutil.DefaultTestToken()
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/mrp/run", map[string]interface{}{
+		"scenario": map[string]interface{}{"stock_overrides": map[string]interface{}{mrpTestMaterial: 0}},
+	}, token)
+	if w.Code == http.StatusOK {
+		t.Fatalf("expected a scenario on a regenerative run to be rejected, got %s", w.Body.String())
+	}
+
+	actualID := runMRPForTest(t, env, map[string]interface{}{})
+	simID := runMRPForTest(t, env, map[string]interface{}{
+		"run_type": entity.MRPRunSimulation,
+		"scenario": map[string]interface{}{
+			"demand_overrides":    []interface{}{map[string]interface{}{"product_id": mrpTestProduct, "factor": 2}},
+			"stock_overrides":     map[string]interface{}{mrpTestMaterial: 0},
+			"lead_time_overrides": map[string]interface{}{mrpTestMaterial: 0},
+		},
+	})
+	if got := plannedQty(env, simID, mrpTestProduct); got != 20 {
+		t.Fatalf("expected doubled product plan 20, got %v", got)
+	}
+	if got := plannedQty(env, simID, mrpTestMaterial); got != 40 {
+		t.Fatalf("expected material plan 40 without stock, got %v", got)
+	}
+	var inv entity.Inventory
+	env.DB.Where("material_id = ?", mrpTestMaterial).First(&inv)
+	if inv.AvailableQty != 5 {
+		t.Fatalf("simulation must not touch real stock, got %v", inv.AvailableQty)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodGet, "/api/v1/erp/mrp/compare?run_a="+actualID+"&run_b="+simID, nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("compare: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	deltas := map[string]float64{}
+	for _, raw := range data["items"].([]interface{}) {
+		item := raw.(map[string]interface{})
+		if item["status"] != "CHANGED" {
+			t.Fatalf("expected %v CHANGED, got %v", item["material_id"], item["status"])
+		}
+		deltas[item["material_id"].(string)] = item["planned_delta"].(float64)
+	}
+	if deltas[mrpTestProduct] != 10 || deltas[mrpTestMaterial] != 25 {
+		t.Fatalf("expected planned deltas 10 / 25, got %v", deltas)
+	}
+	if total := data["summary"].(map[string]interface{})["planned_delta"].(float64); total != 35 {
+		t.Fatalf("expected total planned delta 35, got %v", total)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodGet, "/api/v1/erp/mrp/compare?changed_only=true&run_a="+actualID+"&run_b="+actualID, nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("compare: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if items := testutil.ParseResponse(w)["data"].(map[string]interface{})["items"].([]interface{}); len(items) != 0 {
+		t.Fatalf("expected no changes comparing a run with itself, got %d", len(items))
+	}
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/gin-gonic/gin"
+)
+
+type RMAHandler struct {
+	svc *service.RMAService
+}
+
+func NewRMAHandler(svc *service.RMAService) *RMAHandler {
+	return &RMAHandler{svc: svc}
+}
+
+func (h *RMAHandler) Issue(c *gin.Context) {
+	var req service.IssueRMARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	rma, err := h.svc.Issue(req, userID.(string))
+	if errors.Is(err, service.ErrOutOfWarranty) {
+		c.JSON(http.StatusConflict, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rma})
+}
+
+func (h *RMAHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	items, total, err := h.svc.List(repository.RMAListParams{
+		Status:     c.Query("status"),
+		CustomerID: c.Query("customer_id"),
+		SerialNo:   c.Query("serial_no"),
+		Page:       page,
+		Size:       size,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": gin.H{"items": items, "total": total, "page": page, "size": size}})
+}
+
+func (h *RMAHandler) Get(c *gin.Context) {
+	rma, err := h.svc.GetByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": "退货授权不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rma})
+}
+
+func (h *RMAHandler) Receive(c *gin.Context) {
+	var req service.ReceiveRMARequest
+	c.ShouldBindJSON(&req)
+	userID, _ := c.Get("user_id")
+	rma, err := h.svc.Receive(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rma})
+}
+
+func (h *RMAHandler) Triage(c *gin.Context) {
+	var req service.TriageRMARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	rma, err := h.svc.Triage(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rma})
+}
+
+func (h *RMAHandler) Close(c *gin.Context) {
+	rma, err := h.svc.Close(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rma})
+}
+
+// Warranty GET /rmas/warranty?serial_no= 序列号保修校验
+func (h *RMAHandler) Warranty(c *gin.Context) {
+	w, err := h.svc.VerifySerial(c.Query("serial_no"))
+	if errors.Is(err, service.ErrSerialNotShipped) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": w})
+}
+
+// Trace GET /rmas/trace?serial_no= 序列号售后全链路
+func (h *RMAHandler) Trace(c *gin.Context) {
+	serialNo := c.Query("serial_no")
+	if serialNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "serial_no 必填"})
+		return
+	}
+	trace, err := h.svc.TraceSerial(serialNo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": trace})
+}
+
+func (h *RMAHandler) ListWarrantyPolicies(c *gin.Context) {
+	items, err := h.svc.ListWarrantyPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *RMAHandler) SaveWarrantyPolicy(c *gin.Context) {
+	var req service.SaveWarrantyPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	p, err := h.svc.SaveWarrantyPolicy(req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": p})
+}
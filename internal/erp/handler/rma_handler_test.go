@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/google/uuid"
+)
+
+func setupRMATest(t *testing.T) (*testutil.TestEnv, *entity.Customer) {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	if err := db.AutoMigrate(
+		&entity.Customer{},
+		&entity.SalesOrder{},
+		&entity.SOItem{},
+		&entity.ServiceOrder{},
+		&entity.FailureCode{},
+		&entity.RMA{},
+		&entity.WarrantyPolicy{},
+		&entity.ShipmentTrace{},
+		&entity.LotGenealogy{},
+		&entity.Warehouse{},
+		&entity.Inventory{},
+		&entity.InventoryTransaction{},
+		&entity.InventoryReservation{},
+		&entity.BinFreeze{},
+		&entity.FinanceRecord{},
+		&entity.WorkOrder{},
+		&entity.WorkOrderMaterial{},
+		&entity.WorkOrderOperation{},
+	); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
+	}
+
+	customer := &entity.Customer{ID: uuid.New().String(), CustomerCode: "CUS-RMA", Name: "售后测试客户"}
+	if err := db.Create(customer).Error; err != nil {
+		t.Fatalf("Failed to seed customer: %v", err)
+	}
+
+	handlers := NewHandlers(service.NewServices(repository.NewRepositories(db), db))
+	router := testutil.SetupRouter()
+	api := testutil.AuthGroup(router, "/api/v1/erp")
+	api.POST("/service-orders", handlers.Sales.CreateServiceOrder)
+	api.POST("/rmas", handlers.RMA.Issue)
+	api.POST("/rmas/:id/receive", handlers.RMA.Receive)
+	api.POST("/rmas/:id/triage", handlers.RMA.Triage)
+
+	return &testutil.TestEnv{DB: db, Router: router, T: t}, customer
+}
+
+// seedShipment 序列号发货追溯（发货给指定客户）
+func seedShipment(t *testing.T, env *testutil.TestEnv, customer *entity.Customer, serialNo string, shippedAt time.Time) {
+	t.Helper()
+	if err := env.DB.Create(&entity.ShipmentTrace{
+		ID: uuid.New().String(), SOID: uuid.New().String(), SOCode: "SO-RMA-001",
+		CustomerID: customer.ID, CustomerName: customer.Name,
+		ProductID: "prod-rma", ProductCode: "FG-RMA", SerialNo: serialNo, LotNo: "LOT-1",
+		Quantity: 1, ShippedAt: shippedAt,
+	}).Error; err != nil {
+		t.Fatalf("Failed to seed shipment: %v", err)
+	}
+}
+
+func createServiceOrderForTest(t *testing.T, env *testutil.TestEnv, customer *entity.Customer, serialNo, serviceType string) map[string]interface{} {
+	t.Helper()
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/service-orders", map[string]interface{}{
+		"customer_id":  customer.ID,
+		"product_sn":   serialNo,
+		"service_type": serviceType,
+		"description":  "无法开机",
+	}, testutil.DefaultTestToken())
+	if w.Code != http.StatusOK {
+		t.Fatalf("create service order: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return testutil.ParseResponse(w)["data"].(map[string]interface{})
+}
+
+// receivedRMAForTest 发货→服务工单→退货授权→隔离收货
+func receivedRMAForTest(t *testing.T, env *testutil.TestEnv, customer *entity.Customer, serialNo string) string {
+	t.Helper()
+	token := testutil.DefaultTestToken()
+	seedShipment(t, env, customer, serialNo, time.Now().AddDate(0, -1, 0))
+	svc := createServiceOrderForTest(t, env, customer, serialNo, entity.ServiceTypeReturn)
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/rmas", map[string]interface{}{
+		"service_order_id": svc["id"],
+	}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("issue: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	rmaID := testutil.ParseResponse(w)["data"].(map[string]interface{})["id"].(string)
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/rmas/"+rmaID+"/receive", map[string]interface{}{}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("receive: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return rmaID
+}
+
+// TestServiceOrderUntracedSerial 无发货追溯的序列号可以受理，保修状态未知；发放退货授权时才要求追溯
+func TestServiceOrderUntracedSerial(t *testing.T) {
+	env, customer := setupRMATest(t)
+
+	svc := createServiceOrderForTest(t, env, customer, "SN-LEGACY-1", entity.ServiceTypeRepair)
+	if svc["warranty_state"] != entity.WarrantyStateUnknown {
+		t.Fatalf("expected warranty state UNKNOWN, got %v", svc["warranty_state"])
+	}
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/rmas", map[string]interface{}{
+		"service_order_id": svc["id"],
+	}, testutil.DefaultTestToken())
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for untraced serial, got %d: %s", w.Code, w.Body.String())
+	}
+	var count int64
+	env.DB.Model(&entity.RMA{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no RMA, got %d", count)
+	}
+}
+
+// TestRMAReceiveQuarantine 退货入隔离仓并整件占用，不计入可用库存
+func TestRMAReceiveQuarantine(t *testing.T) {
+	env, customer := setupRMATest(t)
+	rmaID := receivedRMAForTest(t, env, customer, "SN-RMA-1")
+
+	var rma entity.RMA
+	env.DB.Where("id = ?", rmaID).First(&rma)
+	if rma.Status != entity.RMAStatusReceived || rma.ReservationID == "" {
+		t.Fatalf("expected RECEIVED with reservation, got %s / %q", rma.Status, rma.ReservationID)
+	}
+	var inv entity.Inventory
+	if err := env.DB.Where("material_id = ? AND warehouse_id = ?", "prod-rma", rma.WarehouseID).First(&inv).Error; err != nil {
+		t.Fatalf("expected quarantine balance: %v", err)
+	}
+	if inv.Quantity != 1 || inv.AvailableQty != 0 {
+		t.Fatalf("expected quantity 1 and available 0, got %v / %v", inv.Quantity, inv.AvailableQty)
+	}
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/rmas/"+rmaID+"/receive", map[string]interface{}{}, testutil.DefaultTestToken())
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 on second receive, got %d: %s", w.Code, w.Body.String())
+	}
+	var txCount int64
+	env.DB.Model(&entity.InventoryTransaction{}).Where("reference_id = ?", rmaID).Count(&txCount)
+	if txCount != 1 {
+		t.Fatalf("expected 1 return transaction, got %d", txCount)
+	}
+}
+
+// TestRMATriageRefundRollback 退款金额无法确定时判定失败，状态与单据均不落库；补填金额后生成贷项通知
+func TestRMATriageRefundRollback(t *testing.T) {
+	env, customer := setupRMATest(t)
+	token := testutil.DefaultTestToken()
+	rmaID := receivedRMAForTest(t, env, customer, "SN-RMA-2")
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/rmas/"+rmaID+"/triage", map[string]interface{}{
+		"disposition": entity.RMADispositionRefund,
+	}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without credit amount, got %d: %s", w.Code, w.Body.String())
+	}
+	var rma entity.RMA
+	env.DB.Where("id = ?", rmaID).First(&rma)
+	if rma.Status != entity.RMAStatusReceived {
+		t.Fatalf("expected status RECEIVED after failed triage, got %s", rma.Status)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/rmas/"+rmaID+"/triage", map[string]interface{}{
+		"disposition":   entity.RMADispositionRefund,
+		"credit_amount": 99,
+	}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	env.DB.Where("id = ?", rmaID).First(&rma)
+	if rma.Status != entity.RMAStatusResolved || rma.CreditNoteID == "" {
+		t.Fatalf("expected RESOLVED with credit note, got %s / %q", rma.Status, rma.CreditNoteID)
+	}
+	var notes int64
+	env.DB.Model(&entity.FinanceRecord{}).Where("reference_id = ?", rmaID).Count(&notes)
+	if notes != 1 {
+		t.Fatalf("expected 1 credit note, got %d", notes)
+	}
+}
+
+// TestRMATriageRepair 维修判定生成维修工单，退回件从隔离占用发到工单
+func TestRMATriageRepair(t *testing.T) {
+	env, customer := setupRMATest(t)
+	rmaID := receivedRMAForTest(t, env, customer, "SN-RMA-3")
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/erp/rmas/"+rmaID+"/triage", map[string]interface{}{
+		"disposition": entity.RMADispositionRepair,
+	}, testutil.DefaultTestToken())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var rma entity.RMA
+	env.DB.Where("id = ?", rmaID).First(&rma)
+	if rma.Status != entity.RMAStatusRepairing || rma.RepairWOID == "" {
+		t.Fatalf("expected REPAIRING with work order, got %s / %q", rma.Status, rma.RepairWOID)
+	}
+	var res entity.InventoryReservation
+	env.DB.Where("id = ?", rma.ReservationID).First(&res)
+	if res.Status != entity.ReservationStatusConsumed {
+		t.Fatalf("expected reservation consumed, got %s", res.Status)
+	}
+	var inv entity.Inventory
+	env.DB.Where("material_id = ? AND warehouse_id = ?", "prod-rma", rma.WarehouseID).First(&inv)
+	if inv.Quantity != 0 || inv.ReservedQty != 0 {
+		t.Fatalf("expected quarantine emptied, got quantity %v reserved %v", inv.Quantity, inv.ReservedQty)
+	}
+}
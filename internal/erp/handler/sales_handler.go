@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/bitfantasy/nimo/internal/erp/repository"
 	"github.com/bitfantasy/nimo/internal/erp/service"
@@ -61,6 +63,31 @@ func (h *SalesHandler) ListCustomers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": gin.H{"items": customers, "total": total, "page": page, "size": size}})
 }
 
+func (h *SalesHandler) GetCustomerCredit(c *gin.Context) {
+	exposure, err := h.svc.GetCreditExposure(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": "客户不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": exposure})
+}
+
+func (h *SalesHandler) SetCustomerCreditHold(c *gin.Context) {
+	var req struct {
+		Hold   bool   `json:"hold"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	if err := h.svc.SetCustomerCreditHold(c.Param("id"), req.Hold, req.Reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+}
+
 func (h *SalesHandler) DeleteCustomer(c *gin.Context) {
 	if err := h.svc.DeleteCustomer(c.Param("id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
@@ -116,19 +143,70 @@ func (h *SalesHandler) ListSOs(c *gin.Context) {
 
 func (h *SalesHandler) ConfirmSO(c *gin.Context) {
 	if err := h.svc.ConfirmSO(c.Param("id")); err != nil {
+		if errors.Is(err, service.ErrCreditHold) || errors.Is(err, service.ErrCannotPromise) {
+			c.JSON(http.StatusConflict, gin.H{"code": 10004, "message": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
 }
 
-func (h *SalesHandler) ShipSO(c *gin.Context) {
-	var req struct {
-		TrackingNo string `json:"tracking_no"`
+func (h *SalesHandler) ReleaseCreditHold(c *gin.Context) {
+	var req service.ReleaseCreditHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
 	}
+	userID, _ := c.Get("user_id")
+	if err := h.svc.ReleaseCreditHold(c.Param("id"), req, userID.(string)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+}
+
+// CheckATP GET /sales-orders/atp?product_id=&quantity=&date=&mode=
+func (h *SalesHandler) CheckATP(c *gin.Context) {
+	qty, _ := strconv.ParseFloat(c.Query("quantity"), 64)
+	req := service.ATPRequest{ProductID: c.Query("product_id"), Quantity: qty, Mode: c.Query("mode")}
+	if req.ProductID == "" || req.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "product_id 和 quantity 必填"})
+		return
+	}
+	if v := c.Query("date"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "date 格式应为 YYYY-MM-DD"})
+			return
+		}
+		req.RequestDate = &d
+	}
+	res, err := h.svc.CheckATP(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": res})
+}
+
+func (h *SalesHandler) PromiseSO(c *gin.Context) {
+	var req service.PromiseSORequest
+	c.ShouldBindJSON(&req)
+	so, err := h.svc.PromiseSO(c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": so})
+}
+
+func (h *SalesHandler) ShipSO(c *gin.Context) {
+	var req service.ShipSORequest
 	c.ShouldBindJSON(&req)
 	userID, _ := c.Get("user_id")
-	if err := h.svc.ShipSO(c.Param("id"), req.TrackingNo, userID.(string)); err != nil {
+	if err := h.svc.ShipSO(c.Param("id"), req, userID.(string)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
 		return
 	}
@@ -153,6 +231,11 @@ func (h *SalesHandler) CreateServiceOrder(c *gin.Context) {
 	}
 	userID, _ := c.Get("user_id")
 	so, err := h.svc.CreateServiceOrder(req, userID.(string))
+	if errors.Is(err, service.ErrSerialNotShipped) || errors.Is(err, service.ErrSerialCustomerMismatch) ||
+		errors.Is(err, service.ErrUnknownFailureCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
 		return
@@ -177,6 +260,7 @@ func (h *SalesHandler) ListServiceOrders(c *gin.Context) {
 		ServiceType: c.Query("service_type"),
 		CustomerID:  c.Query("customer_id"),
 		AssigneeID:  c.Query("assignee_id"),
+		FailureCode: c.Query("failure_code"),
 		Keyword:     c.Query("keyword"),
 		Page:        page,
 		Size:        size,
@@ -207,10 +291,11 @@ func (h *SalesHandler) AssignServiceOrder(c *gin.Context) {
 
 func (h *SalesHandler) CompleteServiceOrder(c *gin.Context) {
 	var req struct {
-		Solution string `json:"solution"`
+		Solution    string `json:"solution"`
+		FailureCode string `json:"failure_code"`
 	}
 	c.ShouldBindJSON(&req)
-	if err := h.svc.CompleteServiceOrder(c.Param("id"), req.Solution); err != nil {
+	if err := h.svc.CompleteServiceOrder(c.Param("id"), req.Solution, req.FailureCode); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
 		return
 	}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/gin-gonic/gin"
+)
+
+type WarehouseHandler struct {
+	svc *service.WarehouseService
+}
+
+func NewWarehouseHandler(svc *service.WarehouseService) *WarehouseHandler {
+	return &WarehouseHandler{svc: svc}
+}
+
+func (h *WarehouseHandler) List(c *gin.Context) {
+	items, err := h.svc.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+// GetLayout 库区/库位结构及库位占用
+func (h *WarehouseHandler) GetLayout(c *gin.Context) {
+	layout, err := h.svc.GetLayout(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": layout})
+}
+
+func (h *WarehouseHandler) CreateZone(c *gin.Context) {
+	var req service.CreateZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	zone, err := h.svc.CreateZone(c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": zone})
+}
+
+func (h *WarehouseHandler) CreateLocation(c *gin.Context) {
+	var req service.CreateLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	loc, err := h.svc.CreateLocation(c.Param("id"), c.Param("zone_id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": loc})
+}
+
+func (h *WarehouseHandler) ListPutawayRules(c *gin.Context) {
+	rules, err := h.svc.ListPutawayRules(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rules})
+}
+
+func (h *WarehouseHandler) CreatePutawayRule(c *gin.Context) {
+	var req service.CreatePutawayRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	rule, err := h.svc.CreatePutawayRule(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rule})
+}
+
+func (h *WarehouseHandler) DeletePutawayRule(c *gin.Context) {
+	if err := h.svc.DeletePutawayRule(c.Param("rule_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+}
+
+// SuggestPutaway 上架建议
+func (h *WarehouseHandler) SuggestPutaway(c *gin.Context) {
+	var req service.PutawayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	plan, err := h.svc.SuggestPutaway(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": plan})
+}
+
+// SuggestPick 拣货建议 ?material_id=&warehouse_id=&quantity=&strategy=FEFO|FIFO
+func (h *WarehouseHandler) SuggestPick(c *gin.Context) {
+	qty, _ := strconv.ParseFloat(c.Query("quantity"), 64)
+	if c.Query("material_id") == "" || qty <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "material_id 和 quantity 必填"})
+		return
+	}
+	list, err := h.svc.SuggestPick(c.Query("material_id"), c.Query("warehouse_id"), qty, c.Query("strategy"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": list})
+}
+
+// ListLots 物料在仓库内的批次/库位余额
+func (h *WarehouseHandler) ListLots(c *gin.Context) {
+	lots, err := h.svc.ListLotBalances(c.Query("material_id"), c.Query("warehouse_id"), c.Query("strategy"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": lots})
+}
+
+// MoveBin 库位间移库
+func (h *WarehouseHandler) MoveBin(c *gin.Context) {
+	var req service.MoveBinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	txs, err := h.svc.MoveBin(req, userID.(string))
+	if err != nil {
+		inventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": txs})
+}
+
+// WorkOrderPickList 工单拣货单 ?warehouse_id=&strategy=
+func (h *WarehouseHandler) WorkOrderPickList(c *gin.Context) {
+	list, err := h.svc.WorkOrderPickList(c.Param("id"), c.Query("warehouse_id"), c.Query("strategy"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": list})
+}
+
+// SalesOrderPickList 销售订单拣货单 ?warehouse_id=&strategy=
+func (h *WarehouseHandler) SalesOrderPickList(c *gin.Context) {
+	list, err := h.svc.SalesOrderPickList(c.Param("id"), c.Query("warehouse_id"), c.Query("strategy"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": list})
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/gin-gonic/gin"
+)
+
+type WarrantyHandler struct {
+	svc *service.WarrantyService
+}
+
+func NewWarrantyHandler(svc *service.WarrantyService) *WarrantyHandler {
+	return &WarrantyHandler{svc: svc}
+}
+
+// --- Failure Code ---
+
+func (h *WarrantyHandler) ListFailureCodes(c *gin.Context) {
+	items, err := h.svc.ListFailureCodes(c.Query("category"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *WarrantyHandler) CreateFailureCode(c *gin.Context) {
+	var req service.SaveFailureCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	fc, err := h.svc.CreateFailureCode(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": fc})
+}
+
+func (h *WarrantyHandler) UpdateFailureCode(c *gin.Context) {
+	var req service.SaveFailureCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	fc, err := h.svc.UpdateFailureCode(c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": fc})
+}
+
+// --- Warranty Claim ---
+
+func (h *WarrantyHandler) CreateClaim(c *gin.Context) {
+	var req service.CreateWarrantyClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	claim, err := h.svc.CreateClaim(req, userID.(string))
+	if errors.Is(err, service.ErrClaimExists) {
+		c.JSON(http.StatusConflict, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": claim})
+}
+
+func (h *WarrantyHandler) ListClaims(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	items, total, err := h.svc.ListClaims(repository.WarrantyClaimListParams{
+		Status:      c.Query("status"),
+		ProductID:   c.Query("product_id"),
+		BOMVersion:  c.Query("bom_version"),
+		SupplierID:  c.Query("supplier_id"),
+		FailureCode: c.Query("failure_code"),
+		SerialNo:    c.Query("serial_no"),
+		Page:        page,
+		Size:        size,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": gin.H{"items": items, "total": total, "page": page, "size": size}})
+}
+
+func (h *WarrantyHandler) GetClaim(c *gin.Context) {
+	claim, err := h.svc.GetClaim(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 10002, "message": "保修索赔不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": claim})
+}
+
+func (h *WarrantyHandler) AttributeClaim(c *gin.Context) {
+	var req service.AttributeClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	claim, err := h.svc.AttributeClaim(c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": claim})
+}
+
+func (h *WarrantyHandler) ReviewClaim(c *gin.Context) {
+	var req service.ReviewWarrantyClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	userID, _ := c.Get("user_id")
+	claim, err := h.svc.ReviewClaim(c.Param("id"), req, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": claim})
+}
+
+func (h *WarrantyHandler) Chargeback(c *gin.Context) {
+	var req service.ChargebackRequest
+	c.ShouldBindJSON(&req)
+	userID, _ := c.Get("user_id")
+	claim, err := h.svc.Chargeback(c.Param("id"), req, userID.(string))
+	if errors.Is(err, service.ErrClaimNotAttributed) {
+		c.JSON(http.StatusConflict, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": claim})
+}
+
+// --- Analytics ---
+
+// parseDateRange 解析 from/to 查询参数（YYYY-MM-DD），to 含当天
+func parseDateRange(c *gin.Context) (from, to *time.Time, ok bool) {
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "from 日期格式应为 YYYY-MM-DD"})
+			return nil, nil, false
+		}
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": "to 日期格式应为 YYYY-MM-DD"})
+			return nil, nil, false
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	return from, to, true
+}
+
+// FieldReturnRates GET /warranty-claims/analytics/return-rate?group_by=product|bom_version|component_lot|supplier
+// 时间范围按序列号首次发货日期
+func (h *WarrantyHandler) FieldReturnRates(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	rows, err := h.svc.FieldReturnRates(repository.FieldReturnParams{
+		GroupBy:             c.Query("group_by"),
+		ProductID:           c.Query("product_id"),
+		ComponentMaterialID: c.Query("component_material_id"),
+		From:                from,
+		To:                  to,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rows})
+}
+
+// FailurePareto GET /warranty-claims/analytics/failure-pareto 时间范围按报修日期
+func (h *WarrantyHandler) FailurePareto(c *gin.Context) {
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	rows, err := h.svc.FailurePareto(repository.FailureParetoParams{
+		ProductID:  c.Query("product_id"),
+		BOMVersion: c.Query("bom_version"),
+		SupplierID: c.Query("supplier_id"),
+		From:       from,
+		To:         to,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": rows})
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bitfantasy/nimo/internal/erp/service"
+	"github.com/gin-gonic/gin"
+)
+
+type WorkCenterHandler struct {
+	svc *service.WorkCenterService
+}
+
+func NewWorkCenterHandler(svc *service.WorkCenterService) *WorkCenterHandler {
+	return &WorkCenterHandler{svc: svc}
+}
+
+func (h *WorkCenterHandler) List(c *gin.Context) {
+	items, err := h.svc.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 50001, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": items})
+}
+
+func (h *WorkCenterHandler) Create(c *gin.Context) {
+	var req service.SaveWorkCenterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	wc, err := h.svc.Create(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": wc})
+}
+
+func (h *WorkCenterHandler) Update(c *gin.Context) {
+	var req service.SaveWorkCenterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	wc, err := h.svc.Update(c.Param("id"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": wc})
+}
+
+// Load 工作中心产能负荷及瓶颈
+func (h *WorkCenterHandler) Load(c *gin.Context) {
+	var req service.CapacityLoadRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10001, "message": err.Error()})
+		return
+	}
+	result, err := h.svc.CapacityLoad(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 10004, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success", "data": result})
+}
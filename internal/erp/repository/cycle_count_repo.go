@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CycleCountRepository struct {
+	db *gorm.DB
+}
+
+func NewCycleCountRepository(db *gorm.DB) *CycleCountRepository {
+	return &CycleCountRepository{db: db}
+}
+
+// MaterialConsumption 物料在统计期内的消耗
+type MaterialConsumption struct {
+	MaterialID   string  `json:"material_id"`
+	MaterialCode string  `json:"material_code"`
+	MaterialName string  `json:"material_name"`
+	UsageQty     float64 `json:"usage_qty"`
+	UsageValue   float64 `json:"usage_value"`
+}
+
+// ConsumptionSince 有结存的物料自 since 起的消耗数量与金额（按结存单位成本计价），无消耗的记为0
+func (r *CycleCountRepository) ConsumptionSince(since time.Time, txTypes []string) ([]MaterialConsumption, error) {
+	var items []MaterialConsumption
+	err := r.db.Raw(`
+		SELECT m.material_id, m.material_code, m.material_name,
+			COALESCE(u.usage_qty, 0) AS usage_qty,
+			COALESCE(u.usage_qty, 0) * m.unit_cost AS usage_value
+		FROM (
+			SELECT material_id, MAX(material_code) AS material_code, MAX(material_name) AS material_name,
+				MAX(unit_cost) AS unit_cost
+			FROM erp_inventory WHERE deleted_at IS NULL
+			GROUP BY material_id
+		) m
+		LEFT JOIN (
+			SELECT material_id, SUM(-quantity) AS usage_qty
+			FROM erp_inventory_transactions
+			WHERE quantity < 0 AND transaction_type IN ? AND created_at >= ?
+			GROUP BY material_id
+		) u ON u.material_id = m.material_id
+	`, txTypes, since).Scan(&items).Error
+	return items, err
+}
+
+// SaveABC 按物料覆盖 ABC 分类结果
+func (r *CycleCountRepository) SaveABC(items []entity.MaterialABC) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "material_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"material_code", "material_name", "abc_class", "usage_qty", "usage_value",
+			"cumulative_pct", "classified_at", "next_count_date", "updated_at",
+		}),
+	}).CreateInBatches(&items, 200).Error
+}
+
+func (r *CycleCountRepository) ListABC(class string) ([]entity.MaterialABC, error) {
+	query := r.db.Model(&entity.MaterialABC{})
+	if class != "" {
+		query = query.Where("abc_class = ?", class)
+	}
+	var items []entity.MaterialABC
+	err := query.Order("usage_value DESC, material_code").Find(&items).Error
+	return items, err
+}
+
+// DueMaterials 到期应盘的物料
+func (r *CycleCountRepository) DueMaterials(date time.Time) ([]entity.MaterialABC, error) {
+	var items []entity.MaterialABC
+	err := r.db.Where("next_count_date IS NULL OR next_count_date <= ?", date).
+		Order("abc_class, next_count_date").Find(&items).Error
+	return items, err
+}
+
+// MarkCounted 记录物料盘点时间和下次盘点日期
+func (r *CycleCountRepository) MarkCounted(materialID string, countedAt, next time.Time) error {
+	return r.db.Model(&entity.MaterialABC{}).Where("material_id = ?", materialID).
+		Updates(map[string]interface{}{"last_counted_at": countedAt, "next_count_date": next, "updated_at": time.Now()}).Error
+}
+
+func (r *CycleCountRepository) ListPolicies() ([]entity.CycleCountPolicy, error) {
+	var items []entity.CycleCountPolicy
+	err := r.db.Order("abc_class").Find(&items).Error
+	return items, err
+}
+
+func (r *CycleCountRepository) SavePolicy(p *entity.CycleCountPolicy) error {
+	return r.db.Save(p).Error
+}
+
+// StockedMaterials 有库存的物料与仓库组合
+func (r *CycleCountRepository) StockedMaterials(materialIDs []string) ([]entity.Inventory, error) {
+	var items []entity.Inventory
+	err := r.db.Where("material_id IN ? AND quantity > 0 AND deleted_at IS NULL", materialIDs).
+		Order("warehouse_id, material_code").Find(&items).Error
+	return items, err
+}
+
+// OpenCountMaterials 仓库内未结束的盘点任务已包含的物料
+func (r *CycleCountRepository) OpenCountMaterials(warehouseID string) (map[string]bool, error) {
+	var ids []string
+	err := r.db.Model(&entity.CycleCountLine{}).
+		Joins("JOIN erp_cycle_counts c ON c.id = erp_cycle_count_lines.count_id").
+		Where("c.warehouse_id = ? AND c.status IN ?", warehouseID, []string{
+			entity.CycleCountStatusOpen, entity.CycleCountStatusCounting, entity.CycleCountStatusReview,
+		}).Distinct().Pluck("erp_cycle_count_lines.material_id", &ids).Error
+	open := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		open[id] = true
+	}
+	return open, err
+}
+
+func (r *CycleCountRepository) CreateCount(count *entity.CycleCount) error {
+	return r.db.Create(count).Error
+}
+
+func (r *CycleCountRepository) GetCount(id string) (*entity.CycleCount, error) {
+	var count entity.CycleCount
+	err := r.db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("location_id, material_code, batch_no")
+	}).Where("id = ?", id).First(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// LockCount 事务内行锁读取盘点任务，防止并发重复过账
+func (r *CycleCountRepository) LockCount(id string) (*entity.CycleCount, error) {
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", id).First(&entity.CycleCount{}).Error; err != nil {
+		return nil, err
+	}
+	return r.GetCount(id)
+}
+
+// DB 返回底层db用于事务
+func (r *CycleCountRepository) DB() *gorm.DB {
+	return r.db
+}
+
+type CycleCountListParams struct {
+	WarehouseID string
+	Status      string
+	Page        int
+	Size        int
+}
+
+func (r *CycleCountRepository) ListCounts(params CycleCountListParams) ([]entity.CycleCount, int64, error) {
+	query := r.db.Model(&entity.CycleCount{})
+	if params.WarehouseID != "" {
+		query = query.Where("warehouse_id = ?", params.WarehouseID)
+	}
+	if params.Status != "" {
+		query = query.Where("status = ?", params.Status)
+	}
+	var total int64
+	query.Count(&total)
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Size <= 0 {
+		params.Size = 20
+	}
+	var items []entity.CycleCount
+	err := query.Order("count_date DESC, created_at DESC").
+		Offset((params.Page - 1) * params.Size).Limit(params.Size).Find(&items).Error
+	return items, total, err
+}
+
+// SaveCount 更新盘点任务头（不含明细）
+func (r *CycleCountRepository) SaveCount(count *entity.CycleCount) error {
+	return r.db.Omit("Lines").Save(count).Error
+}
+
+func (r *CycleCountRepository) SaveLine(line *entity.CycleCountLine) error {
+	return r.db.Save(line).Error
+}
+
+func (r *CycleCountRepository) CreateLines(lines []entity.CycleCountLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	return r.db.Create(&lines).Error
+}
+
+// WarehouseAccuracy 仓库库存准确率
+type WarehouseAccuracy struct {
+	WarehouseID      string  `json:"warehouse_id"`
+	WarehouseName    string  `json:"warehouse_name"`
+	Counts           int     `json:"counts"`
+	Lines            int     `json:"lines"`
+	AccurateLines    int     `json:"accurate_lines"` // 无差异或差异在容差内
+	AccuracyPct      float64 `json:"accuracy_pct"`
+	SnapshotValue    float64 `json:"snapshot_value"`
+	AbsVarianceValue float64 `json:"abs_variance_value"`
+	NetVarianceValue float64 `json:"net_variance_value"`
+	ValueAccuracyPct float64 `json:"value_accuracy_pct"` // 1 - 差异金额绝对值/账面金额
+}
+
+// Accuracy 已过账盘点的分仓库准确率
+func (r *CycleCountRepository) Accuracy(from, to time.Time) ([]WarehouseAccuracy, error) {
+	var items []WarehouseAccuracy
+	err := r.db.Raw(`
+		SELECT c.warehouse_id, COALESCE(MAX(w.name), '') AS warehouse_name,
+			COUNT(DISTINCT c.id) AS counts,
+			COUNT(l.id) AS lines,
+			SUM(CASE WHEN l.status IN (?, ?) THEN 1 ELSE 0 END) AS accurate_lines,
+			COALESCE(SUM(l.snapshot_qty * l.unit_cost), 0) AS snapshot_value,
+			COALESCE(SUM(ABS(l.variance_value)), 0) AS abs_variance_value,
+			COALESCE(SUM(l.variance_value), 0) AS net_variance_value
+		FROM erp_cycle_counts c
+		JOIN erp_cycle_count_lines l ON l.count_id = c.id
+		LEFT JOIN erp_warehouses w ON w.id = c.warehouse_id
+		WHERE c.status = ? AND c.posted_at >= ? AND c.posted_at < ?
+		GROUP BY c.warehouse_id
+		ORDER BY warehouse_name
+	`, entity.CountLineMatched, entity.CountLineWithinTolerance, entity.CycleCountStatusPosted, from, to).
+		Scan(&items).Error
+	return items, err
+}
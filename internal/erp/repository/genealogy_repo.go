@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"gorm.io/gorm"
+)
+
+type GenealogyRepository struct {
+	db *gorm.DB
+}
+
+func NewGenealogyRepository(db *gorm.DB) *GenealogyRepository {
+	return &GenealogyRepository{db: db}
+}
+
+func (r *GenealogyRepository) CreateLinks(links []entity.LotGenealogy) error {
+	if len(links) == 0 {
+		return nil
+	}
+	return r.db.Create(&links).Error
+}
+
+// FindProduced 查询产出记录：按序列号，或按物料+批次
+func (r *GenealogyRepository) FindProduced(materialID, lotNo, serialNo string) ([]entity.LotGenealogy, error) {
+	query := r.db.Where("link_type = ?", entity.GenealogyProduce)
+	if serialNo != "" {
+		query = query.Where("serial_no = ?", serialNo)
+	} else {
+		query = query.Where("lot_no = ?", lotNo)
+	}
+	if materialID != "" {
+		query = query.Where("material_id = ?", materialID)
+	}
+	var items []entity.LotGenealogy
+	err := query.Order("created_at").Find(&items).Error
+	return items, err
+}
+
+// FindByWorkOrder 工单的消耗或产出记录
+func (r *GenealogyRepository) FindByWorkOrder(woID, linkType string) ([]entity.LotGenealogy, error) {
+	var items []entity.LotGenealogy
+	err := r.db.Where("work_order_id = ? AND link_type = ?", woID, linkType).
+		Order("material_code, lot_no, serial_no").Find(&items).Error
+	return items, err
+}
+
+// FindConsumers 消耗了指定批次的记录（可按供应商过滤，lotNo 为空时取该供应商全部批次）
+func (r *GenealogyRepository) FindConsumers(materialID, lotNo, supplierID string) ([]entity.LotGenealogy, error) {
+	query := r.db.Where("link_type = ?", entity.GenealogyConsume)
+	if materialID != "" {
+		query = query.Where("material_id = ?", materialID)
+	}
+	if lotNo != "" {
+		query = query.Where("lot_no = ?", lotNo)
+	}
+	if supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	var items []entity.LotGenealogy
+	err := query.Order("created_at").Find(&items).Error
+	return items, err
+}
+
+// FindProducedSerials 已产出的序列号（用于查重）
+func (r *GenealogyRepository) FindProducedSerials(serials []string) ([]string, error) {
+	var found []string
+	if len(serials) == 0 {
+		return found, nil
+	}
+	err := r.db.Model(&entity.LotGenealogy{}).
+		Where("link_type = ? AND serial_no IN ?", entity.GenealogyProduce, serials).
+		Pluck("serial_no", &found).Error
+	return found, err
+}
+
+func (r *GenealogyRepository) CreateShipments(items []entity.ShipmentTrace) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.db.Create(&items).Error
+}
+
+// FindShipments 按序列号或物料+批次查询发货记录
+func (r *GenealogyRepository) FindShipments(productID, lotNo, serialNo string) ([]entity.ShipmentTrace, error) {
+	query := r.db.Model(&entity.ShipmentTrace{})
+	if serialNo != "" {
+		query = query.Where("serial_no = ?", serialNo)
+	} else {
+		query = query.Where("product_id = ? AND lot_no = ?", productID, lotNo)
+	}
+	var items []entity.ShipmentTrace
+	err := query.Order("shipped_at").Find(&items).Error
+	return items, err
+}
+
+// FindShipmentsBySO 销售订单发出的序列号/批次
+func (r *GenealogyRepository) FindShipmentsBySO(soID string) ([]entity.ShipmentTrace, error) {
+	var items []entity.ShipmentTrace
+	err := r.db.Where("so_id = ?", soID).Order("shipped_at").Find(&items).Error
+	return items, err
+}
+
+// InspectionRef 来料检验摘要
+type InspectionRef struct {
+	ID             string     `json:"id"`
+	InspectionCode string     `json:"inspection_code"`
+	Result         string     `json:"result"`
+	InspectedAt    *time.Time `json:"inspected_at"`
+}
+
+// LotOrigin 外购批次的来源：首笔入库流水、供应商与来料检验
+type LotOrigin struct {
+	ReferenceType string         `json:"reference_type"` // PO, SRM_INSPECTION, MIGRATION ...
+	ReferenceID   string         `json:"reference_id"`
+	ReferenceCode string         `json:"reference_code"`
+	SupplierID    string         `json:"supplier_id"`
+	SupplierName  string         `json:"supplier_name"`
+	ReceivedQty   float64        `json:"received_qty"`
+	ReceivedAt    time.Time      `json:"received_at"`
+	Inspection    *InspectionRef `json:"inspection,omitempty"`
+}
+
+// GetLotOrigin 批次的入库来源，无入库流水时返回 nil
+func (r *GenealogyRepository) GetLotOrigin(materialID, lotNo string) (*LotOrigin, error) {
+	var rows []LotOrigin
+	err := r.db.Raw(`
+		SELECT reference_type, reference_id, reference_code, supplier_id,
+			quantity AS received_qty, created_at AS received_at
+		FROM erp_inventory_transactions
+		WHERE material_id = ? AND batch_no = ? AND quantity > 0
+		ORDER BY created_at LIMIT 1
+	`, materialID, lotNo).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	origin := &rows[0]
+
+	if origin.SupplierID == "" && origin.ReferenceType == "PO" {
+		r.db.Raw("SELECT supplier_id::text FROM erp_purchase_orders WHERE id::text = ?", origin.ReferenceID).
+			Scan(&origin.SupplierID)
+	}
+	origin.SupplierName = r.GetSupplierName(origin.SupplierID)
+	if origin.ReferenceType == entity.InventoryRefSRMInspection {
+		var insp []InspectionRef
+		if err := r.db.Raw("SELECT id, inspection_code, result, inspected_at FROM srm_inspections WHERE id = ?",
+			origin.ReferenceID).Scan(&insp).Error; err == nil && len(insp) > 0 {
+			origin.Inspection = &insp[0]
+		}
+	}
+	return origin, nil
+}
+
+// GetLotCurrency 外购批次的结算币种：优先取入库来源采购订单的币种，
+// 其次取该供应商最近一张采购订单的币种，都没有时返回空
+func (r *GenealogyRepository) GetLotCurrency(materialID, lotNo, supplierID string) string {
+	var currency string
+	if lotNo != "" {
+		var refs []struct{ ReferenceType, ReferenceID string }
+		r.db.Raw(`
+			SELECT reference_type, reference_id FROM erp_inventory_transactions
+			WHERE material_id = ? AND batch_no = ? AND quantity > 0
+			ORDER BY created_at LIMIT 1
+		`, materialID, lotNo).Scan(&refs)
+		if len(refs) > 0 {
+			switch refs[0].ReferenceType {
+			case "PO":
+				r.db.Raw("SELECT currency FROM erp_purchase_orders WHERE id::text = ?", refs[0].ReferenceID).Scan(&currency)
+			case entity.InventoryRefSRMInspection:
+				r.db.Raw(`SELECT po.currency FROM srm_inspections i
+					JOIN srm_purchase_orders po ON po.id = i.po_id WHERE i.id = ?`, refs[0].ReferenceID).Scan(&currency)
+			}
+		}
+	}
+	if currency == "" && supplierID != "" {
+		r.db.Raw("SELECT currency FROM erp_purchase_orders WHERE supplier_id::text = ? ORDER BY created_at DESC LIMIT 1", supplierID).
+			Scan(&currency)
+		if currency == "" {
+			r.db.Raw("SELECT currency FROM srm_purchase_orders WHERE supplier_id = ? ORDER BY created_at DESC LIMIT 1", supplierID).
+				Scan(&currency)
+		}
+	}
+	return currency
+}
+
+// GetSupplierName 供应商名称，供应商可能来自 ERP 或 SRM 主数据
+func (r *GenealogyRepository) GetSupplierName(supplierID string) string {
+	if supplierID == "" {
+		return ""
+	}
+	var name string
+	r.db.Raw("SELECT name FROM erp_suppliers WHERE id::text = ?", supplierID).Scan(&name)
+	if name == "" {
+		r.db.Raw("SELECT name FROM srm_suppliers WHERE id = ?", supplierID).Scan(&name)
+	}
+	return name
+}
@@ -1,7 +1,15 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
 	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -33,27 +41,12 @@ func (r *InventoryRepository) GetTotalStock(materialID string) (float64, error)
 	return result.Total, err
 }
 
-// UpsertInventory 更新或创建库存记录
-func (r *InventoryRepository) UpsertInventory(inv *entity.Inventory) error {
-	return r.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "material_id"}, {Name: "warehouse_id"}, {Name: "batch_no"}},
-		DoUpdates: clause.AssignmentColumns([]string{"quantity", "available_qty", "reserved_qty", "unit_cost", "last_moved_at", "updated_at"}),
-	}).Create(inv).Error
-}
-
-func (r *InventoryRepository) Update(inv *entity.Inventory) error {
-	return r.db.Save(inv).Error
-}
-
-func (r *InventoryRepository) CreateTransaction(tx *entity.InventoryTransaction) error {
-	return r.db.Create(tx).Error
-}
-
 type InventoryListParams struct {
 	MaterialID    string
 	WarehouseID   string
 	InventoryType string
 	Keyword       string
+	SupplierID    string // 有该供应商入库流水的物料
 	LowStock      bool
 	Page          int
 	Size          int
@@ -74,6 +67,9 @@ func (r *InventoryRepository) List(params InventoryListParams) ([]entity.Invento
 		kw := "%" + params.Keyword + "%"
 		query = query.Where("material_code ILIKE ? OR material_name ILIKE ?", kw, kw)
 	}
+	if params.SupplierID != "" {
+		query = query.Where("material_id IN (SELECT material_id FROM erp_inventory_transactions WHERE supplier_id = ?)", params.SupplierID)
+	}
 	if params.LowStock {
 		query = query.Where("available_qty < safety_stock AND safety_stock > 0")
 	}
@@ -101,6 +97,29 @@ func (r *InventoryRepository) ListTransactions(materialID string, page, size int
 	return txs, total, err
 }
 
+// ListTransactionsByInventory 按结存行查询流水
+func (r *InventoryRepository) ListTransactionsByInventory(inventoryID string, page, size int) ([]entity.InventoryTransaction, int64, error) {
+	query := r.db.Model(&entity.InventoryTransaction{}).Where("inventory_id = ?", inventoryID)
+	var total int64
+	query.Count(&total)
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = 20
+	}
+	var txs []entity.InventoryTransaction
+	err := query.Order("created_at DESC").Offset((page - 1) * size).Limit(size).Find(&txs).Error
+	return txs, total, err
+}
+
+// GetByID 获取结存行
+func (r *InventoryRepository) GetByID(id string) (*entity.Inventory, error) {
+	var inv entity.Inventory
+	err := r.db.Preload("Warehouse").Where("id = ? AND deleted_at IS NULL", id).First(&inv).Error
+	return &inv, err
+}
+
 // GetAlerts 获取库存预警列表
 func (r *InventoryRepository) GetAlerts() ([]entity.Inventory, error) {
 	var alerts []entity.Inventory
@@ -122,3 +141,835 @@ func (r *InventoryRepository) GetByMaterial(materialID string) ([]entity.Invento
 func (r *InventoryRepository) DB() *gorm.DB {
 	return r.db
 }
+
+// --- 库存台账 ---
+
+var (
+	ErrInsufficientStock = errors.New("可用库存不足")
+	ErrInventoryConflict = errors.New("库存已被其他操作修改，请刷新后重试")
+	ErrBinFrozen         = errors.New("库位盘点冻结中")
+)
+
+// StockMovement 一笔库存变动：流水与结存在同一事务内过账
+type StockMovement struct {
+	Tx              *entity.InventoryTransaction // 流水，数量正=入、负=出
+	CreateIfMissing bool                         // 结存不存在时新建（入库）
+	InventoryType   string                       // 新建结存的库存类型
+	Unit            string                       // 新建结存的单位
+	ReservationID   string                       // 出库时消耗的预留
+	ExpectedVersion *int                         // 乐观锁：结存版本不一致时拒绝
+	MPN             string                       // 新建结存的制造商料号
+	AllocateLots    bool                         // 出库时按拣货策略拆分到各批次/库位，每批次每库位一笔流水
+	PickStrategy    string                       // FEFO（默认）或 FIFO
+	IgnoreFreeze    bool                         // 盘点差异过账，不受库位冻结限制
+	AllowExpired    bool                         // 拆分批次时可分配已过期批次（报废出库）
+
+	Lots []entity.InventoryTransaction // 过账结果：实际写入的流水（按批次拆分后）
+}
+
+func inventoryLockKey(materialID, warehouseID string) string {
+	return "erp_inventory:" + materialID + ":" + warehouseID
+}
+
+// lockBalances 按固定顺序获取物料+仓库的事务级咨询锁，防止并发新建结存及死锁
+func lockBalances(tx *gorm.DB, keys []string) error {
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i > 0 && keys[i-1] == k {
+			continue
+		}
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", k).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockBalance 行锁读取物料在仓库的结存（SELECT ... FOR UPDATE）
+func lockBalance(tx *gorm.DB, materialID, warehouseID string) (*entity.Inventory, error) {
+	var inv entity.Inventory
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("material_id = ? AND warehouse_id = ? AND deleted_at IS NULL", materialID, warehouseID).
+		Order("created_at").First(&inv).Error
+	return &inv, err
+}
+
+// saveBalance 按版本号更新结存，版本不一致说明被并发修改
+func saveBalance(tx *gorm.DB, inv *entity.Inventory) error {
+	now := time.Now()
+	inv.AvailableQty = inv.Quantity - inv.ReservedQty
+	res := tx.Model(&entity.Inventory{}).Where("id = ? AND version = ?", inv.ID, inv.Version).
+		Updates(map[string]interface{}{
+			"quantity":      inv.Quantity,
+			"reserved_qty":  inv.ReservedQty,
+			"available_qty": inv.AvailableQty,
+			"last_moved_at": inv.LastMovedAt,
+			"location_id":   inv.LocationID,
+			"expiry_date":   inv.ExpiryDate,
+			"version":       inv.Version + 1,
+			"updated_at":    now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInventoryConflict
+	}
+	inv.Version++
+	inv.UpdatedAt = now
+	return nil
+}
+
+// PostMovements 在同一事务内过账多笔库存变动，任一失败全部回滚
+func (r *InventoryRepository) PostMovements(moves []StockMovement) ([]entity.Inventory, error) {
+	balances := make([]entity.Inventory, len(moves))
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		keys := make([]string, 0, len(moves))
+		for _, m := range moves {
+			keys = append(keys, inventoryLockKey(m.Tx.MaterialID, m.Tx.WarehouseID))
+		}
+		if err := lockBalances(tx, keys); err != nil {
+			return err
+		}
+		for i := range moves {
+			inv, err := postMovement(tx, &moves[i])
+			if err != nil {
+				return err
+			}
+			balances[i] = *inv
+		}
+		return nil
+	})
+	return balances, err
+}
+
+func postMovement(tx *gorm.DB, m *StockMovement) (*entity.Inventory, error) {
+	t := m.Tx
+	now := time.Now()
+	inv, err := lockBalance(tx, t.MaterialID, t.WarehouseID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !m.CreateIfMissing || t.Quantity < 0 {
+			return nil, fmt.Errorf("%w: 物料 %s 在该仓库无库存", ErrInsufficientStock, t.MaterialCode)
+		}
+		inv = &entity.Inventory{
+			ID:            uuid.New().String(),
+			MaterialID:    t.MaterialID,
+			MaterialCode:  t.MaterialCode,
+			MaterialName:  t.MaterialName,
+			MPN:           m.MPN,
+			WarehouseID:   t.WarehouseID,
+			LocationID:    t.LocationID,
+			BatchNo:       t.BatchNo,
+			InventoryType: m.InventoryType,
+			UnitCost:      t.UnitCost,
+			Unit:          m.Unit,
+		}
+		if inv.InventoryType == "" {
+			inv.InventoryType = entity.InventoryTypeRaw
+		}
+		if inv.Unit == "" {
+			inv.Unit = "pcs"
+		}
+		if err := tx.Create(inv).Error; err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if m.ExpectedVersion != nil && *m.ExpectedVersion != inv.Version {
+		return nil, ErrInventoryConflict
+	}
+
+	if m.ReservationID != "" {
+		if t.Quantity >= 0 {
+			return nil, fmt.Errorf("预留只能用于出库")
+		}
+		var res entity.InventoryReservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", m.ReservationID).First(&res).Error; err != nil {
+			return nil, fmt.Errorf("预留不存在: %w", err)
+		}
+		if res.Status != entity.ReservationStatusActive || res.InventoryID != inv.ID {
+			return nil, fmt.Errorf("预留不可用于该库存")
+		}
+		need := -t.Quantity
+		if need > res.Remaining()+1e-9 {
+			return nil, fmt.Errorf("%w: 出库数量%.4f超过预留剩余%.4f", ErrInsufficientStock, need, res.Remaining())
+		}
+		res.ConsumedQty += need
+		if res.Remaining() <= 1e-9 {
+			res.Status = entity.ReservationStatusConsumed
+		}
+		if err := tx.Save(&res).Error; err != nil {
+			return nil, err
+		}
+		inv.ReservedQty = math.Max(inv.ReservedQty-need, 0)
+	} else if t.Quantity < 0 && inv.Quantity-inv.ReservedQty < -t.Quantity-1e-9 {
+		return nil, fmt.Errorf("%w: 物料 %s 需要%.4f, 可用%.4f", ErrInsufficientStock, t.MaterialCode, -t.Quantity, inv.Quantity-inv.ReservedQty)
+	}
+
+	frozen := map[string]bool{}
+	if !m.IgnoreFreeze {
+		if frozen, err = frozenLocations(tx, t.WarehouseID, t.MaterialID); err != nil {
+			return nil, err
+		}
+	}
+	txs := []*entity.InventoryTransaction{t}
+	if m.AllocateLots && t.Quantity < 0 {
+		// 批次余额需在结存锁内读取，保证并发出库不会重复分配同一批次/库位
+		if txs, err = allocateLots(tx, t, m.PickStrategy, frozen, m.AllowExpired); err != nil {
+			return nil, err
+		}
+	} else if frozen[t.LocationID] {
+		return nil, fmt.Errorf("%w: 物料 %s", ErrBinFrozen, t.MaterialCode)
+	} else if t.Quantity < 0 && t.LocationID == "" && len(frozen) > 0 {
+		// 不拆批次的出库同样不能动用冻结库位内的库存
+		held, err := frozenQty(tx, t.MaterialID, t.WarehouseID, frozen)
+		if err != nil {
+			return nil, err
+		}
+		if free := inv.Quantity - inv.ReservedQty - held; free < -t.Quantity-1e-9 {
+			return nil, fmt.Errorf("%w: 物料 %s 未冻结库存%.4f，不足%.4f", ErrBinFrozen, t.MaterialCode, math.Max(free, 0), -t.Quantity)
+		}
+	}
+
+	balance := inv.Quantity
+	inv.Quantity += t.Quantity
+	inv.LastMovedAt = &now
+	if t.Quantity > 0 {
+		// 结存上的库位/有效期只作摘要：默认库位取首个上架库位，有效期取最早到期
+		if inv.LocationID == "" {
+			inv.LocationID = t.LocationID
+		}
+		if t.ExpiryDate != nil && (inv.ExpiryDate == nil || t.ExpiryDate.Before(*inv.ExpiryDate)) {
+			inv.ExpiryDate = t.ExpiryDate
+		}
+	}
+	if err := saveBalance(tx, inv); err != nil {
+		return nil, err
+	}
+
+	m.Lots = m.Lots[:0]
+	for _, lt := range txs {
+		if lt.ID == "" {
+			lt.ID = uuid.New().String()
+		}
+		balance += lt.Quantity
+		lt.InventoryID = inv.ID
+		lt.BalanceAfter = balance
+		if lt.MaterialCode == "" {
+			lt.MaterialCode, lt.MaterialName = inv.MaterialCode, inv.MaterialName
+		}
+		if err := tx.Create(lt).Error; err != nil {
+			return nil, err
+		}
+		m.Lots = append(m.Lots, *lt)
+	}
+	return inv, nil
+}
+
+// LotBalance 物料在仓库中某批次、某库位的余额
+type LotBalance struct {
+	BatchNo     string     `json:"batch_no"`
+	LocationID  string     `json:"location_id"`
+	Quantity    float64    `json:"quantity"`
+	SupplierID  string     `json:"supplier_id"`
+	ExpiryDate  *time.Time `json:"expiry_date"`
+	FirstIn     time.Time  `json:"first_in"`
+	LastMovedAt time.Time  `json:"last_moved_at"`
+}
+
+// lotBalances 按拣货策略排序的批次/库位余额：FEFO 先到期先出，无有效期或同有效期按先进先出，
+// 同一批次分布在多个库位时优先最久未动的库位
+func lotBalances(db *gorm.DB, materialID, warehouseID, locationID, strategy string) ([]LotBalance, error) {
+	order := "MIN(created_at), MAX(created_at), batch_no, location_id"
+	if strategy != entity.PickStrategyFIFO {
+		order = "MIN(expiry_date) NULLS LAST, " + order
+	}
+	query := db.Model(&entity.InventoryTransaction{}).
+		Select(`batch_no, location_id, SUM(quantity) AS quantity, MAX(supplier_id) AS supplier_id,
+			MIN(expiry_date) AS expiry_date, MIN(created_at) AS first_in, MAX(created_at) AS last_moved_at`).
+		Where("material_id = ? AND warehouse_id = ?", materialID, warehouseID)
+	if locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	var lots []LotBalance
+	err := query.Group("batch_no, location_id").Having("SUM(quantity) > 0.000001").Order(order).Scan(&lots).Error
+	return lots, err
+}
+
+// ListLotBalances 物料在仓库内各批次/库位的余额（拣货建议，不加锁）
+func (r *InventoryRepository) ListLotBalances(materialID, warehouseID, strategy string) ([]LotBalance, error) {
+	return lotBalances(r.db, materialID, warehouseID, "", strategy)
+}
+
+// frozenQty 物料在冻结库位内的余额合计
+func frozenQty(tx *gorm.DB, materialID, warehouseID string, frozen map[string]bool) (float64, error) {
+	lots, err := lotBalances(tx, materialID, warehouseID, "", entity.PickStrategyFIFO)
+	if err != nil {
+		return 0, err
+	}
+	var held float64
+	for _, l := range lots {
+		if frozen[l.LocationID] {
+			held += l.Quantity
+		}
+	}
+	return held, nil
+}
+
+// allocateLots 按拣货策略将出库流水拆分到批次和库位，指定库位时只从该库位出，跳过盘点冻结的库位；
+// 已过期批次不参与分配（报废除外）；未冻结库存不足时报冻结错误，批次余额与结存不一致的差额记在无批次名下
+func allocateLots(tx *gorm.DB, t *entity.InventoryTransaction, strategy string, frozen map[string]bool, allowExpired bool) ([]*entity.InventoryTransaction, error) {
+	if frozen[t.LocationID] {
+		return nil, fmt.Errorf("%w: 物料 %s", ErrBinFrozen, t.MaterialCode)
+	}
+	lots, err := lotBalances(tx, t.MaterialID, t.WarehouseID, t.LocationID, strategy)
+	if err != nil {
+		return nil, err
+	}
+	remaining := -t.Quantity
+	var out []*entity.InventoryTransaction
+	var skipped, expired float64
+	now := time.Now()
+	for _, l := range lots {
+		if remaining <= 1e-9 {
+			break
+		}
+		if frozen[l.LocationID] {
+			skipped += l.Quantity
+			continue
+		}
+		if !allowExpired && l.ExpiryDate != nil && l.ExpiryDate.Before(now) {
+			expired += l.Quantity
+			continue
+		}
+		take := math.Min(l.Quantity, remaining)
+		lt := *t
+		lt.ID = ""
+		if len(out) == 0 {
+			lt.ID = t.ID
+		}
+		lt.Quantity = -take
+		lt.BatchNo = l.BatchNo
+		lt.LocationID = l.LocationID
+		lt.SupplierID = l.SupplierID
+		lt.ExpiryDate = l.ExpiryDate
+		out = append(out, &lt)
+		remaining -= take
+	}
+	if remaining > 1e-9 && t.LocationID != "" {
+		return nil, fmt.Errorf("%w: 库位余额不足，还差%.4f", ErrInsufficientStock, remaining)
+	}
+	if remaining > 1e-9 && (frozen[""] || skipped > 1e-9) {
+		return nil, fmt.Errorf("%w: 物料 %s 未冻结库存不足，还差%.4f", ErrBinFrozen, t.MaterialCode, remaining)
+	}
+	if remaining > 1e-9 && expired > 1e-9 {
+		return nil, fmt.Errorf("%w: 物料 %s 有%.4f已过期，有效批次还差%.4f", ErrInsufficientStock, t.MaterialCode, expired, remaining)
+	}
+	if remaining > 1e-9 || len(out) == 0 {
+		lt := *t
+		lt.ID = ""
+		if len(out) == 0 {
+			lt.ID = t.ID
+		}
+		lt.Quantity = -remaining
+		lt.BatchNo = ""
+		out = append(out, &lt)
+	}
+	return out, nil
+}
+
+// LocationUsage 库位当前占用数量（按流水汇总），key 为库位ID
+func (r *InventoryRepository) LocationUsage(warehouseID string) (map[string]float64, error) {
+	return locationUsage(r.db, warehouseID, "")
+}
+
+func locationUsage(db *gorm.DB, warehouseID, locationID string) (map[string]float64, error) {
+	query := db.Model(&entity.InventoryTransaction{}).
+		Select("location_id, SUM(quantity) AS quantity").
+		Where("warehouse_id = ? AND location_id <> ''", warehouseID)
+	if locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	var rows []struct {
+		LocationID string
+		Quantity   float64
+	}
+	if err := query.Group("location_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	usage := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		usage[row.LocationID] = row.Quantity
+	}
+	return usage, nil
+}
+
+// BinContent 库位上某物料某批次的余额
+type BinContent struct {
+	MaterialID   string  `json:"material_id"`
+	MaterialCode string  `json:"material_code"`
+	MaterialName string  `json:"material_name"`
+	LocationID   string  `json:"location_id"`
+	BatchNo      string  `json:"batch_no"`
+	Quantity     float64 `json:"quantity"`
+}
+
+// BinContents 仓库内指定物料和/或库位的批次余额
+func (r *InventoryRepository) BinContents(warehouseID string, materialIDs, locationIDs []string) ([]BinContent, error) {
+	query := r.db.Model(&entity.InventoryTransaction{}).
+		Select("material_id, MAX(material_code) AS material_code, MAX(material_name) AS material_name, location_id, batch_no, SUM(quantity) AS quantity").
+		Where("warehouse_id = ?", warehouseID)
+	if len(materialIDs) > 0 {
+		query = query.Where("material_id IN ?", materialIDs)
+	}
+	if len(locationIDs) > 0 {
+		query = query.Where("location_id IN ?", locationIDs)
+	}
+	var items []BinContent
+	err := query.Group("material_id, location_id, batch_no").Having("SUM(quantity) > 0.000001").
+		Order("location_id, material_id, batch_no").Scan(&items).Error
+	return items, err
+}
+
+// frozenLocations 物料在仓库内被盘点冻结的库位（含整库位冻结），"" 表示未上架库存被冻结
+func frozenLocations(db *gorm.DB, warehouseID, materialID string) (map[string]bool, error) {
+	var locs []string
+	err := db.Model(&entity.BinFreeze{}).
+		Where("warehouse_id = ? AND released_at IS NULL AND (material_id = '' OR material_id = ?)", warehouseID, materialID).
+		Pluck("location_id", &locs).Error
+	frozen := make(map[string]bool, len(locs))
+	for _, l := range locs {
+		frozen[l] = true
+	}
+	return frozen, err
+}
+
+// FrozenLocations 物料在仓库内被盘点冻结的库位
+func (r *InventoryRepository) FrozenLocations(warehouseID, materialID string) (map[string]bool, error) {
+	return frozenLocations(r.db, warehouseID, materialID)
+}
+
+// FreezeBins 盘点开始时冻结库位
+func (r *InventoryRepository) FreezeBins(freezes []entity.BinFreeze) error {
+	if len(freezes) == 0 {
+		return nil
+	}
+	return r.db.Create(&freezes).Error
+}
+
+// ReleaseBins 解冻盘点任务冻结的库位
+func (r *InventoryRepository) ReleaseBins(countID string) error {
+	return r.db.Model(&entity.BinFreeze{}).Where("count_id = ? AND released_at IS NULL", countID).
+		Update("released_at", time.Now()).Error
+}
+
+// BinMove 库位间移库：结存数量不变，源库位出、目标库位入各一笔流水
+type BinMove struct {
+	MaterialID     string
+	WarehouseID    string
+	FromLocationID string
+	ToLocationID   string
+	ToCapacity     float64 // 目标库位容量，0=不限
+	BatchNo        string  // 空=按拣货策略从源库位各批次移出
+	Quantity       float64
+	Notes          string
+	CreatedBy      string
+}
+
+// MoveBin 在结存锁内校验源库位余额与目标库位容量后过账移库
+func (r *InventoryRepository) MoveBin(mv BinMove) ([]entity.InventoryTransaction, error) {
+	var out []entity.InventoryTransaction
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBalances(tx, []string{inventoryLockKey(mv.MaterialID, mv.WarehouseID)}); err != nil {
+			return err
+		}
+		inv, err := lockBalance(tx, mv.MaterialID, mv.WarehouseID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: 该仓库无此物料库存", ErrInsufficientStock)
+		} else if err != nil {
+			return err
+		}
+
+		frozen, err := frozenLocations(tx, mv.WarehouseID, mv.MaterialID)
+		if err != nil {
+			return err
+		}
+		if frozen[mv.FromLocationID] || frozen[mv.ToLocationID] {
+			return ErrBinFrozen
+		}
+
+		if mv.ToCapacity > 0 {
+			usage, err := locationUsage(tx, mv.WarehouseID, mv.ToLocationID)
+			if err != nil {
+				return err
+			}
+			if used := usage[mv.ToLocationID]; used+mv.Quantity > mv.ToCapacity+1e-9 {
+				return fmt.Errorf("目标库位容量不足: 容量%.4f, 已占用%.4f", mv.ToCapacity, used)
+			}
+		}
+
+		lots, err := lotBalances(tx, mv.MaterialID, mv.WarehouseID, mv.FromLocationID, entity.PickStrategyFEFO)
+		if err != nil {
+			return err
+		}
+		ref := uuid.New().String()
+		remaining := mv.Quantity
+		balance := inv.Quantity
+		for _, l := range lots {
+			if remaining <= 1e-9 {
+				break
+			}
+			if mv.BatchNo != "" && l.BatchNo != mv.BatchNo {
+				continue
+			}
+			take := math.Min(l.Quantity, remaining)
+			for _, leg := range []struct {
+				location string
+				qty      float64
+			}{{mv.FromLocationID, -take}, {mv.ToLocationID, take}} {
+				t := entity.InventoryTransaction{
+					ID:              uuid.New().String(),
+					MaterialID:      inv.MaterialID,
+					MaterialCode:    inv.MaterialCode,
+					MaterialName:    inv.MaterialName,
+					WarehouseID:     inv.WarehouseID,
+					TransactionType: entity.TxTypeBinMove,
+					Quantity:        leg.qty,
+					InventoryID:     inv.ID,
+					BalanceAfter:    balance,
+					BatchNo:         l.BatchNo,
+					LocationID:      leg.location,
+					ExpiryDate:      l.ExpiryDate,
+					SupplierID:      l.SupplierID,
+					ReferenceType:   entity.TxTypeBinMove,
+					ReferenceID:     ref,
+					Notes:           mv.Notes,
+					CreatedBy:       mv.CreatedBy,
+				}
+				if err := tx.Create(&t).Error; err != nil {
+					return err
+				}
+				out = append(out, t)
+			}
+			remaining -= take
+		}
+		if remaining > 1e-9 {
+			return fmt.Errorf("%w: 源库位余额不足，还差%.4f", ErrInsufficientStock, remaining)
+		}
+
+		now := time.Now()
+		inv.LastMovedAt = &now
+		return saveBalance(tx, inv)
+	})
+	return out, err
+}
+
+// Reserve 预留库存：占用可用数量，不产生流水
+func (r *InventoryRepository) Reserve(res *entity.InventoryReservation) (*entity.Inventory, error) {
+	var inv *entity.Inventory
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBalances(tx, []string{inventoryLockKey(res.MaterialID, res.WarehouseID)}); err != nil {
+			return err
+		}
+		var err error
+		inv, err = lockBalance(tx, res.MaterialID, res.WarehouseID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: 该仓库无此物料库存", ErrInsufficientStock)
+		} else if err != nil {
+			return err
+		}
+		if available := inv.Quantity - inv.ReservedQty; available < res.Quantity-1e-9 {
+			return fmt.Errorf("%w: 需要%.4f, 可用%.4f", ErrInsufficientStock, res.Quantity, available)
+		}
+		inv.ReservedQty += res.Quantity
+		if err := saveBalance(tx, inv); err != nil {
+			return err
+		}
+		res.InventoryID = inv.ID
+		res.Status = entity.ReservationStatusActive
+		return tx.Create(res).Error
+	})
+	return inv, err
+}
+
+// ReleaseReservation 释放预留剩余数量
+func (r *InventoryRepository) ReleaseReservation(id string) (*entity.InventoryReservation, error) {
+	var res entity.InventoryReservation
+	if err := r.db.Where("id = ?", id).First(&res).Error; err != nil {
+		return nil, err
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBalances(tx, []string{inventoryLockKey(res.MaterialID, res.WarehouseID)}); err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&res).Error; err != nil {
+			return err
+		}
+		if res.Status != entity.ReservationStatusActive {
+			return fmt.Errorf("预留状态不允许释放: %s", res.Status)
+		}
+		inv, err := lockBalance(tx, res.MaterialID, res.WarehouseID)
+		if err != nil {
+			return err
+		}
+		inv.ReservedQty = math.Max(inv.ReservedQty-res.Remaining(), 0)
+		if err := saveBalance(tx, inv); err != nil {
+			return err
+		}
+		now := time.Now()
+		res.Status = entity.ReservationStatusReleased
+		res.ReleasedAt = &now
+		return tx.Save(&res).Error
+	})
+	return &res, err
+}
+
+func (r *InventoryRepository) GetReservation(id string) (*entity.InventoryReservation, error) {
+	var res entity.InventoryReservation
+	err := r.db.Where("id = ?", id).First(&res).Error
+	return &res, err
+}
+
+func (r *InventoryRepository) ListReservations(materialID, referenceID, status string) ([]entity.InventoryReservation, error) {
+	query := r.db.Model(&entity.InventoryReservation{})
+	if materialID != "" {
+		query = query.Where("material_id = ?", materialID)
+	}
+	if referenceID != "" {
+		query = query.Where("reference_id = ?", referenceID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var items []entity.InventoryReservation
+	err := query.Order("created_at DESC").Find(&items).Error
+	return items, err
+}
+
+// GetOrderReservedQty 获取销售订单/工单对物料的有效预留剩余数量（这部分需求已按单据计入ATP）
+func (r *InventoryRepository) GetOrderReservedQty(materialID string) (float64, error) {
+	var result struct{ Total float64 }
+	err := r.db.Raw(`
+		SELECT COALESCE(SUM(quantity - consumed_qty), 0) AS total
+		FROM erp_inventory_reservations
+		WHERE material_id = ? AND status = 'ACTIVE' AND reference_type IN ('SO', 'WO')
+	`, materialID).Scan(&result).Error
+	return result.Total, err
+}
+
+// BalanceCheck 物料+仓库的结存与流水/预留汇总
+type BalanceCheck struct {
+	MaterialID     string  `json:"material_id"`
+	WarehouseID    string  `json:"warehouse_id"`
+	MaterialCode   string  `json:"material_code"`
+	BalanceQty     float64 `json:"balance_qty"`
+	LedgerQty      float64 `json:"ledger_qty"`
+	ReservedQty    float64 `json:"reserved_qty"`
+	ReservationQty float64 `json:"reservation_qty"`
+	AvailableQty   float64 `json:"available_qty"`
+}
+
+// GetBalanceChecks 按物料+仓库汇总结存、流水合计与有效预留
+func (r *InventoryRepository) GetBalanceChecks() ([]BalanceCheck, error) {
+	var rows []BalanceCheck
+	err := r.db.Raw(`
+		WITH bal AS (
+			SELECT material_id, warehouse_id::text AS wh, MAX(material_code) AS code,
+				SUM(quantity) AS qty, SUM(reserved_qty) AS reserved, SUM(available_qty) AS avail
+			FROM erp_inventory WHERE deleted_at IS NULL GROUP BY 1, 2
+		), led AS (
+			SELECT material_id, warehouse_id::text AS wh, MAX(material_code) AS code, SUM(quantity) AS qty
+			FROM erp_inventory_transactions GROUP BY 1, 2
+		), res AS (
+			SELECT material_id, warehouse_id::text AS wh, SUM(quantity - consumed_qty) AS qty
+			FROM erp_inventory_reservations WHERE status = ? GROUP BY 1, 2
+		)
+		SELECT COALESCE(b.material_id, l.material_id) AS material_id,
+			COALESCE(b.wh, l.wh) AS warehouse_id,
+			COALESCE(b.code, l.code) AS material_code,
+			COALESCE(b.qty, 0) AS balance_qty,
+			COALESCE(l.qty, 0) AS ledger_qty,
+			COALESCE(b.reserved, 0) AS reserved_qty,
+			COALESCE(rs.qty, 0) AS reservation_qty,
+			COALESCE(b.avail, 0) AS available_qty
+		FROM bal b
+		FULL OUTER JOIN led l ON l.material_id = b.material_id AND l.wh = b.wh
+		LEFT JOIN res rs ON rs.material_id = COALESCE(b.material_id, l.material_id) AND rs.wh = COALESCE(b.wh, l.wh)
+		ORDER BY 3, 2
+	`, entity.ReservationStatusActive).Scan(&rows).Error
+	return rows, err
+}
+
+// RebuildBalance 以流水合计和有效预留重建结存（重复的结存行合并到最早一行）
+func (r *InventoryRepository) RebuildBalance(materialID, warehouseID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBalances(tx, []string{inventoryLockKey(materialID, warehouseID)}); err != nil {
+			return err
+		}
+		var sums struct {
+			Ledger   float64
+			Reserved float64
+		}
+		if err := tx.Raw(`
+			SELECT
+				(SELECT COALESCE(SUM(quantity), 0) FROM erp_inventory_transactions WHERE material_id = @m AND warehouse_id = @w) AS ledger,
+				(SELECT COALESCE(SUM(quantity - consumed_qty), 0) FROM erp_inventory_reservations
+					WHERE material_id = @m AND warehouse_id = @w AND status = @s) AS reserved
+		`, sql.Named("m", materialID), sql.Named("w", warehouseID), sql.Named("s", entity.ReservationStatusActive)).
+			Scan(&sums).Error; err != nil {
+			return err
+		}
+
+		var rows []entity.Inventory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("material_id = ? AND warehouse_id = ? AND deleted_at IS NULL", materialID, warehouseID).
+			Order("created_at").Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			if math.Abs(sums.Ledger) < 1e-9 {
+				return nil
+			}
+			var last entity.InventoryTransaction
+			if err := tx.Where("material_id = ? AND warehouse_id = ?", materialID, warehouseID).
+				Order("created_at DESC").First(&last).Error; err != nil {
+				return err
+			}
+			return tx.Create(&entity.Inventory{
+				ID:            uuid.New().String(),
+				MaterialID:    materialID,
+				MaterialCode:  last.MaterialCode,
+				MaterialName:  last.MaterialName,
+				WarehouseID:   warehouseID,
+				InventoryType: entity.InventoryTypeRaw,
+				Quantity:      sums.Ledger,
+				ReservedQty:   sums.Reserved,
+				AvailableQty:  sums.Ledger - sums.Reserved,
+				Unit:          "pcs",
+			}).Error
+		}
+		for i := range rows {
+			inv := &rows[i]
+			inv.Quantity, inv.ReservedQty = 0, 0
+			if i == 0 {
+				inv.Quantity, inv.ReservedQty = sums.Ledger, sums.Reserved
+			}
+			if err := saveBalance(tx, inv); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *InventoryRepository) CreateReconciliation(rec *entity.InventoryReconciliation) error {
+	return r.db.Create(rec).Error
+}
+
+func (r *InventoryRepository) ListReconciliations(limit int) ([]entity.InventoryReconciliation, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	var items []entity.InventoryReconciliation
+	err := r.db.Order("created_at DESC").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+// --- 物料/仓库识别（跨模块入库时将编码、名称解析为主数据） ---
+
+// MaterialRef 物料主数据摘要
+type MaterialRef struct {
+	ID   string `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Unit string `json:"unit"`
+}
+
+// FindMaterialsByCode 按物料编码查找物料主数据
+func (r *InventoryRepository) FindMaterialsByCode(code string) ([]MaterialRef, error) {
+	var items []MaterialRef
+	err := r.db.Raw("SELECT id, code, name, unit FROM materials WHERE code = ? AND deleted_at IS NULL", code).
+		Scan(&items).Error
+	return items, err
+}
+
+// FindMaterialsByName 按物料名称精确查找物料主数据
+func (r *InventoryRepository) FindMaterialsByName(name string) ([]MaterialRef, error) {
+	var items []MaterialRef
+	err := r.db.Raw("SELECT id, code, name, unit FROM materials WHERE name = ? AND deleted_at IS NULL", name).
+		Scan(&items).Error
+	return items, err
+}
+
+// FilterMaterialsByMPN 在候选物料中筛选 BOM 行登记了该制造商料号的物料
+func (r *InventoryRepository) FilterMaterialsByMPN(materialIDs []string, mpn string) ([]MaterialRef, error) {
+	var items []MaterialRef
+	err := r.db.Raw(`SELECT id, code, name, unit FROM materials m
+		WHERE m.id IN ? AND m.deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM project_bom_items i WHERE i.material_id = m.id AND i.mpn = ?)`, materialIDs, mpn).
+		Scan(&items).Error
+	return items, err
+}
+
+// FindWarehouse 按ID、编码或名称查找仓库
+func (r *InventoryRepository) FindWarehouse(key string) (*entity.Warehouse, error) {
+	var wh entity.Warehouse
+	err := r.db.Where("(id::text = ? OR code = ? OR name = ?) AND deleted_at IS NULL", key, key, key).
+		Order("created_at").First(&wh).Error
+	return &wh, err
+}
+
+// EnsureWarehouse 获取指定编码的仓库，不存在时创建
+func (r *InventoryRepository) EnsureWarehouse(code, name string) (*entity.Warehouse, error) {
+	wh := entity.Warehouse{Code: code}
+	err := r.db.Where("code = ?", code).
+		Attrs(entity.Warehouse{ID: uuid.New().String(), Name: name, Status: entity.WarehouseStatusActive}).
+		FirstOrCreate(&wh).Error
+	return &wh, err
+}
+
+// GetNonMigratedQty 物料在仓库中非迁移来源的流水合计，用于判断迁移是否会重复计数
+func (r *InventoryRepository) GetNonMigratedQty(materialID, warehouseID string) (float64, error) {
+	var qty float64
+	err := r.db.Raw(`
+		SELECT COALESCE(SUM(quantity), 0) FROM erp_inventory_transactions
+		WHERE material_id = ? AND warehouse_id = ? AND reference_type <> ?
+	`, materialID, warehouseID, entity.InventoryRefMigration).Scan(&qty).Error
+	return qty, err
+}
+
+// --- 历史库存迁移记录 ---
+
+// GetMigration 获取来源记录的迁移结果
+func (r *InventoryRepository) GetMigration(sourceType, sourceID string) (*entity.InventoryMigration, error) {
+	var m entity.InventoryMigration
+	err := r.db.Where("source_type = ? AND source_id = ?", sourceType, sourceID).First(&m).Error
+	return &m, err
+}
+
+// SaveMigration 按来源记录写入或覆盖迁移结果
+func (r *InventoryRepository) SaveMigration(m *entity.InventoryMigration) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "source_type"}, {Name: "source_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"material_code", "material_name", "supplier_id", "warehouse", "quantity", "unit",
+			"material_id", "warehouse_id", "inventory_id", "status", "conflict_type", "detail",
+			"migrated_by", "updated_at",
+		}),
+	}).Create(m).Error
+}
+
+// ListMigrations 迁移记录（冲突报告）
+func (r *InventoryRepository) ListMigrations(sourceType, status string) ([]entity.InventoryMigration, error) {
+	query := r.db.Model(&entity.InventoryMigration{})
+	if sourceType != "" {
+		query = query.Where("source_type = ?", sourceType)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var items []entity.InventoryMigration
+	err := query.Order("status, material_code").Find(&items).Error
+	return items, err
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MPSRepository struct {
+	db *gorm.DB
+}
+
+func NewMPSRepository(db *gorm.DB) *MPSRepository {
+	return &MPSRepository{db: db}
+}
+
+// --- Forecast ---
+
+// UpsertForecast 按产品+周期覆盖预测
+func (r *MPSRepository) UpsertForecast(f *entity.DemandForecast) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "period_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"product_code", "period_type", "quantity", "source", "notes", "updated_at"}),
+	}).Create(f).Error
+}
+
+func (r *MPSRepository) DeleteForecast(id string) error {
+	return r.db.Where("id = ?", id).Delete(&entity.DemandForecast{}).Error
+}
+
+type ForecastListParams struct {
+	ProductID string
+	From      *time.Time
+	To        *time.Time
+}
+
+func (r *MPSRepository) ListForecasts(params ForecastListParams) ([]entity.DemandForecast, error) {
+	query := r.db.Model(&entity.DemandForecast{})
+	if params.ProductID != "" {
+		query = query.Where("product_id = ?", params.ProductID)
+	}
+	if params.From != nil {
+		query = query.Where("period_start >= ?", *params.From)
+	}
+	if params.To != nil {
+		query = query.Where("period_start <= ?", *params.To)
+	}
+	var items []entity.DemandForecast
+	err := query.Order("product_code, period_start").Find(&items).Error
+	return items, err
+}
+
+// --- MPS ---
+
+// GetEntriesInRange 获取周期起始日落在区间内的MPS
+func (r *MPSRepository) GetEntriesInRange(productID string, from, to time.Time) ([]entity.MPSEntry, error) {
+	query := r.db.Where("period_end >= ? AND period_start <= ?", from, to)
+	if productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	var items []entity.MPSEntry
+	err := query.Order("product_code, period_start").Find(&items).Error
+	return items, err
+}
+
+func (r *MPSRepository) GetEntryByID(id string) (*entity.MPSEntry, error) {
+	var e entity.MPSEntry
+	err := r.db.Where("id = ?", id).First(&e).Error
+	return &e, err
+}
+
+func (r *MPSRepository) UpdateEntry(e *entity.MPSEntry) error {
+	return r.db.Save(e).Error
+}
+
+// ReplaceEntries 重新生成产品MPS：删除区间内未保留的计划后写入新计划
+func (r *MPSRepository) ReplaceEntries(productID string, from time.Time, keepIDs []string, entries []entity.MPSEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		del := tx.Where("product_id = ? AND period_end >= ?", productID, from)
+		if len(keepIDs) > 0 {
+			del = del.Where("id NOT IN ?", keepIDs)
+		}
+		if err := del.Delete(&entity.MPSEntry{}).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.Create(&entries).Error
+	})
+}
@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/bitfantasy/nimo/internal/erp/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MRPRepository struct {
@@ -29,10 +33,42 @@ func (r *MRPRepository) UpdateRun(run *entity.MRPRun) error {
 
 func (r *MRPRepository) GetLatestRun() (*entity.MRPRun, error) {
 	var run entity.MRPRun
-	err := r.db.Order("created_at DESC").First(&run).Error
+	err := r.db.Where("run_type IS NULL OR run_type <> ?", entity.MRPRunSimulation).
+		Order("created_at DESC").First(&run).Error
 	return &run, err
 }
 
+// GetLatestBaseRun 最近一次可作为净变更基准的运行（同范围、同时间段设置，非模拟）
+func (r *MRPRepository) GetLatestBaseRun(productID, bucketType string, horizon int) (*entity.MRPRun, error) {
+	var run entity.MRPRun
+	err := r.db.Where("status IN ? AND (run_type IS NULL OR run_type <> ?)",
+		[]string{entity.MRPStatusCompleted, entity.MRPStatusApplied}, entity.MRPRunSimulation).
+		Where("product_id = ? AND bucket_type = ? AND planning_horizon = ?", productID, bucketType, horizon).
+		Order("created_at DESC").First(&run).Error
+	return &run, err
+}
+
+// GetChangedItemIDs 指定时间后有变动的物料/成品：销售订单、库存流水、采购订单、工单、BOM、物料主数据、计划参数、MPS
+func (r *MRPRepository) GetChangedItemIDs(since time.Time) ([]string, error) {
+	var ids []string
+	err := r.db.Raw(`
+		SELECT si.product_id FROM erp_so_items si JOIN erp_sales_orders so ON so.id = si.so_id
+		WHERE so.updated_at > @since OR si.updated_at > @since
+		UNION SELECT material_id FROM erp_inventory_transactions WHERE created_at > @since
+		UNION SELECT pi.material_id FROM erp_po_items pi JOIN erp_purchase_orders po ON po.id = pi.po_id
+		WHERE po.updated_at > @since OR pi.updated_at > @since
+		UNION SELECT product_id FROM erp_work_orders WHERE updated_at > @since
+		UNION SELECT product_id FROM bom_headers WHERE updated_at > @since
+		UNION SELECT bh.product_id FROM bom_items bi JOIN bom_headers bh ON bh.id = bi.bom_header_id
+		WHERE bi.updated_at > @since
+		UNION SELECT id FROM products WHERE updated_at > @since
+		UNION SELECT id FROM materials WHERE updated_at > @since
+		UNION SELECT material_id FROM erp_material_planning WHERE updated_at > @since
+		UNION SELECT product_id FROM erp_mps_entries WHERE updated_at > @since
+	`, sql.Named("since", since)).Scan(&ids).Error
+	return ids, err
+}
+
 func (r *MRPRepository) ListRuns(page, size int) ([]entity.MRPRun, int64, error) {
 	var total int64
 	r.db.Model(&entity.MRPRun{}).Count(&total)
@@ -47,7 +83,7 @@ func (r *MRPRepository) BatchCreateResults(results []entity.MRPResult) error {
 	if len(results) == 0 {
 		return nil
 	}
-	return r.db.Create(&results).Error
+	return r.db.CreateInBatches(&results, 500).Error
 }
 
 func (r *MRPRepository) GetResultsByRunID(runID string) ([]entity.MRPResult, error) {
@@ -66,6 +102,64 @@ func (r *MRPRepository) MarkResultsApplied(runID string) error {
 	return r.db.Model(&entity.MRPResult{}).Where("mrp_run_id = ?", runID).Update("applied", true).Error
 }
 
+func (r *MRPRepository) GetResultByID(id string) (*entity.MRPResult, error) {
+	var result entity.MRPResult
+	err := r.db.Where("id = ?", id).First(&result).Error
+	return &result, err
+}
+
+// --- Pegging ---
+
+func (r *MRPRepository) BatchCreatePeggings(pegs []entity.MRPPegging) error {
+	if len(pegs) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(&pegs, 500).Error
+}
+
+func (r *MRPRepository) GetPeggingsByRunID(runID string) ([]entity.MRPPegging, error) {
+	var pegs []entity.MRPPegging
+	err := r.db.Where("mrp_run_id = ?", runID).Find(&pegs).Error
+	return pegs, err
+}
+
+func (r *MRPRepository) GetPeggingsByResultID(resultID string) ([]entity.MRPPegging, error) {
+	var pegs []entity.MRPPegging
+	err := r.db.Where("mrp_result_id = ?", resultID).Order("demand_date, so_code").Find(&pegs).Error
+	return pegs, err
+}
+
+// --- Material Planning ---
+
+// GetPlanningParams 批量获取物料计划参数，按物料ID索引
+func (r *MRPRepository) GetPlanningParams(materialIDs []string) (map[string]entity.MaterialPlanning, error) {
+	params := make(map[string]entity.MaterialPlanning)
+	if len(materialIDs) == 0 {
+		return params, nil
+	}
+	var rows []entity.MaterialPlanning
+	if err := r.db.Where("material_id IN ?", materialIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		params[row.MaterialID] = row
+	}
+	return params, nil
+}
+
+func (r *MRPRepository) ListPlanningParams() ([]entity.MaterialPlanning, error) {
+	var rows []entity.MaterialPlanning
+	err := r.db.Order("material_id").Find(&rows).Error
+	return rows, err
+}
+
+func (r *MRPRepository) UpsertPlanningParams(p *entity.MaterialPlanning) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "material_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"lot_sizing_rule", "fixed_lot_qty", "order_multiple", "ordering_cost", "holding_cost_rate", "lead_time_days", "demand_time_fence_days", "planning_time_fence_days", "consumption_backward_days", "consumption_forward_days", "updated_by", "updated_at"}),
+	}).Create(p).Error
+}
+
 // --- Finance ---
 
 func (r *MRPRepository) CreateFinanceRecord(record *entity.FinanceRecord) error {
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/bitfantasy/nimo/internal/erp/entity"
 	"gorm.io/gorm"
 )
@@ -111,6 +113,29 @@ func (r *PurchaseRepository) GetPOItemsByPOID(poID string) ([]entity.POItem, err
 }
 
 // GetInTransitQty 获取物料在途数量（已批准的PO中未完成收货的数量）
+// ScheduledReceipt 计划接收（在途PO或在制工单），按预计到货/完工日期汇总
+type ScheduledReceipt struct {
+	Date     *time.Time
+	Quantity float64
+}
+
+// GetScheduledReceipts 按预计到货日期汇总物料的采购在途
+func (r *PurchaseRepository) GetScheduledReceipts(materialID string) ([]ScheduledReceipt, error) {
+	var rows []ScheduledReceipt
+	err := r.db.Raw(`
+		SELECT po.expected_date AS date, COALESCE(SUM(i.quantity - i.received_qty), 0) AS quantity
+		FROM erp_po_items i
+		JOIN erp_purchase_orders po ON po.id = i.po_id
+		WHERE i.material_id = ?
+		AND po.status IN ('APPROVED', 'SENT', 'PARTIAL')
+		AND po.deleted_at IS NULL
+		AND i.status != 'CLOSED'
+		GROUP BY po.expected_date
+		HAVING SUM(i.quantity - i.received_qty) > 0
+	`, materialID).Scan(&rows).Error
+	return rows, err
+}
+
 func (r *PurchaseRepository) GetInTransitQty(materialID string) (float64, error) {
 	var result struct{ Total float64 }
 	err := r.db.Raw(`
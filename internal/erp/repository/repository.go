@@ -4,21 +4,35 @@ import "gorm.io/gorm"
 
 // Repositories ERP 仓库集合
 type Repositories struct {
-	Supplier  *SupplierRepository
-	Purchase  *PurchaseRepository
-	Inventory *InventoryRepository
-	WorkOrder *WorkOrderRepository
-	Sales     *SalesRepository
-	MRP       *MRPRepository
+	Supplier   *SupplierRepository
+	Purchase   *PurchaseRepository
+	Inventory  *InventoryRepository
+	WorkOrder  *WorkOrderRepository
+	Sales      *SalesRepository
+	MRP        *MRPRepository
+	MPS        *MPSRepository
+	Genealogy  *GenealogyRepository
+	Warehouse  *WarehouseRepository
+	CycleCount *CycleCountRepository
+	WorkCenter *WorkCenterRepository
+	RMA        *RMARepository
+	Warranty   *WarrantyRepository
 }
 
 func NewRepositories(db *gorm.DB) *Repositories {
 	return &Repositories{
-		Supplier:  NewSupplierRepository(db),
-		Purchase:  NewPurchaseRepository(db),
-		Inventory: NewInventoryRepository(db),
-		WorkOrder: NewWorkOrderRepository(db),
-		Sales:     NewSalesRepository(db),
-		MRP:       NewMRPRepository(db),
+		Supplier:   NewSupplierRepository(db),
+		Purchase:   NewPurchaseRepository(db),
+		Inventory:  NewInventoryRepository(db),
+		WorkOrder:  NewWorkOrderRepository(db),
+		Sales:      NewSalesRepository(db),
+		MRP:        NewMRPRepository(db),
+		MPS:        NewMPSRepository(db),
+		Genealogy:  NewGenealogyRepository(db),
+		Warehouse:  NewWarehouseRepository(db),
+		CycleCount: NewCycleCountRepository(db),
+		WorkCenter: NewWorkCenterRepository(db),
+		RMA:        NewRMARepository(db),
+		Warranty:   NewWarrantyRepository(db),
 	}
 }
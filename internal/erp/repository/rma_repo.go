@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RMARepository struct {
+	db *gorm.DB
+}
+
+func NewRMARepository(db *gorm.DB) *RMARepository {
+	return &RMARepository{db: db}
+}
+
+func (r *RMARepository) Create(rma *entity.RMA) error {
+	return r.db.Create(rma).Error
+}
+
+func (r *RMARepository) GetByID(id string) (*entity.RMA, error) {
+	var rma entity.RMA
+	err := r.db.Preload("Customer").Where("id = ?", id).First(&rma).Error
+	return &rma, err
+}
+
+// LockByID 事务内行锁读取退货授权，防止并发重复收货或判定
+func (r *RMARepository) LockByID(id string) (*entity.RMA, error) {
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", id).First(&entity.RMA{}).Error; err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// DB 返回底层db用于事务
+func (r *RMARepository) DB() *gorm.DB {
+	return r.db
+}
+
+func (r *RMARepository) Update(rma *entity.RMA) error {
+	return r.db.Omit("Customer").Save(rma).Error
+}
+
+type RMAListParams struct {
+	Status     string
+	CustomerID string
+	SerialNo   string
+	Page       int
+	Size       int
+}
+
+func (r *RMARepository) List(params RMAListParams) ([]entity.RMA, int64, error) {
+	query := r.db.Model(&entity.RMA{})
+	if params.Status != "" {
+		query = query.Where("status = ?", params.Status)
+	}
+	if params.CustomerID != "" {
+		query = query.Where("customer_id = ?", params.CustomerID)
+	}
+	if params.SerialNo != "" {
+		query = query.Where("serial_no = ?", params.SerialNo)
+	}
+	var total int64
+	query.Count(&total)
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Size <= 0 {
+		params.Size = 20
+	}
+	var items []entity.RMA
+	err := query.Preload("Customer").Order("created_at DESC").
+		Offset((params.Page - 1) * params.Size).Limit(params.Size).Find(&items).Error
+	return items, total, err
+}
+
+// FindOpenBySerial 序列号未结案的退货授权
+func (r *RMARepository) FindOpenBySerial(serialNo string) (*entity.RMA, error) {
+	var rma entity.RMA
+	err := r.db.Where("serial_no = ? AND status IN ?", serialNo,
+		[]string{entity.RMAStatusIssued, entity.RMAStatusReceived, entity.RMAStatusRepairing}).
+		First(&rma).Error
+	return &rma, err
+}
+
+// FindBySerial 序列号的全部退货授权，按时间先后
+func (r *RMARepository) FindBySerial(serialNo string) ([]entity.RMA, error) {
+	var items []entity.RMA
+	err := r.db.Where("serial_no = ?", serialNo).Order("created_at").Find(&items).Error
+	return items, err
+}
+
+// FindServiceOrdersBySerial 序列号的服务工单，按时间先后
+func (r *RMARepository) FindServiceOrdersBySerial(serialNo string) ([]entity.ServiceOrder, error) {
+	var items []entity.ServiceOrder
+	err := r.db.Where("product_sn = ? AND deleted_at IS NULL", serialNo).Order("created_at").Find(&items).Error
+	return items, err
+}
+
+// GetWarrantyMonths 产品保修月数，未配置时 ok 为 false
+func (r *RMARepository) GetWarrantyMonths(productID string) (months int, ok bool, err error) {
+	var p entity.WarrantyPolicy
+	err = r.db.Where("product_id = ?", productID).Limit(1).Find(&p).Error
+	return p.WarrantyMonths, p.ID != "", err
+}
+
+func (r *RMARepository) ListWarrantyPolicies() ([]entity.WarrantyPolicy, error) {
+	var items []entity.WarrantyPolicy
+	err := r.db.Order("product_id").Find(&items).Error
+	return items, err
+}
+
+func (r *RMARepository) UpsertWarrantyPolicy(p *entity.WarrantyPolicy) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"warranty_months", "notes", "updated_by", "updated_at"}),
+	}).Create(p).Error
+}
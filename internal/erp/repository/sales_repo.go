@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/bitfantasy/nimo/internal/erp/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SalesRepository struct {
@@ -62,6 +65,54 @@ func (r *SalesRepository) ListCustomers(params CustomerListParams) ([]entity.Cus
 	return customers, total, err
 }
 
+// LockCustomer 事务内行锁客户，串行化同一客户的信用检查，防止并发确认共同突破额度
+func (r *SalesRepository) LockCustomer(id string) error {
+	return r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id = ? AND deleted_at IS NULL", id).First(&entity.Customer{}).Error
+}
+
+// CreditExposure 客户信用占用。本模块不开票：已签收未完成(DELIVERED)视为未清应收，
+// 已发货未签收视为已发货未开票，已确认/拣货中订单的未发货部分计入未完成订单
+type CreditExposure struct {
+	CustomerID         string  `json:"customer_id"`
+	CreditLimit        float64 `json:"credit_limit"`
+	CreditHold         bool    `json:"credit_hold"`
+	OpenAR             float64 `json:"open_ar"`
+	OpenOrders         float64 `json:"open_orders"`
+	ShippedNotInvoiced float64 `json:"shipped_not_invoiced"`
+	Exposure           float64 `json:"exposure"`
+	Available          float64 `json:"available"` // 额度为 0 表示不限，此时为 0
+}
+
+// GetCreditExposure 计算客户信用占用，excludeSOID 非空时排除该订单（确认前检查用）
+func (r *SalesRepository) GetCreditExposure(customerID, excludeSOID string) (*CreditExposure, error) {
+	customer, err := r.GetCustomerByID(customerID)
+	if err != nil {
+		return nil, err
+	}
+	exp := &CreditExposure{CustomerID: customerID, CreditLimit: customer.CreditLimit, CreditHold: customer.CreditHold}
+	query := r.db.Table("erp_so_items AS i").
+		Joins("JOIN erp_sales_orders so ON so.id = i.so_id").
+		Select(`
+			COALESCE(SUM(CASE WHEN so.status = 'DELIVERED' THEN i.amount ELSE 0 END), 0) AS open_ar,
+			COALESCE(SUM(CASE WHEN so.status IN ('CONFIRMED', 'PICKING') AND i.status != 'CLOSED'
+				THEN GREATEST(i.quantity - i.shipped_qty, 0) * i.unit_price ELSE 0 END), 0) AS open_orders,
+			COALESCE(SUM(CASE WHEN so.status = 'SHIPPED' THEN i.amount
+				WHEN so.status IN ('CONFIRMED', 'PICKING') THEN i.shipped_qty * i.unit_price ELSE 0 END), 0) AS shipped_not_invoiced`).
+		Where("so.customer_id = ? AND so.deleted_at IS NULL", customerID)
+	if excludeSOID != "" {
+		query = query.Where("so.id <> ?", excludeSOID)
+	}
+	if err := query.Scan(exp).Error; err != nil {
+		return nil, err
+	}
+	exp.Exposure = exp.OpenAR + exp.OpenOrders + exp.ShippedNotInvoiced
+	if exp.CreditLimit > 0 {
+		exp.Available = exp.CreditLimit - exp.Exposure
+	}
+	return exp, nil
+}
+
 // --- Sales Order ---
 
 func (r *SalesRepository) CreateSO(so *entity.SalesOrder) error {
@@ -79,6 +130,11 @@ func (r *SalesRepository) UpdateSO(so *entity.SalesOrder) error {
 	return r.db.Save(so).Error
 }
 
+// DB 返回底层db用于事务
+func (r *SalesRepository) DB() *gorm.DB {
+	return r.db
+}
+
 type SOListParams struct {
 	Status     string
 	CustomerID string
@@ -147,6 +203,54 @@ func (r *SalesRepository) GetPendingDemand() (map[string]float64, error) {
 	return demand, nil
 }
 
+// DemandLine 未发货的销售订单行（MRP独立需求）
+type DemandLine struct {
+	SOID      string
+	SOCode    string
+	SOItemID  string
+	ProductID string
+	Quantity  float64
+	DueDate   *time.Time
+}
+
+// GetOpenDemandLines 按行获取未发货的销售需求，交期取行交期，其次订单发货日期、下单日期
+func (r *SalesRepository) GetOpenDemandLines() ([]DemandLine, error) {
+	var rows []DemandLine
+	err := r.db.Raw(`
+		SELECT so.id AS so_id, so.so_code, i.id AS so_item_id, i.product_id,
+			i.quantity - i.shipped_qty AS quantity,
+			COALESCE(i.due_date, so.shipping_date, so.order_date) AS due_date
+		FROM erp_so_items i
+		JOIN erp_sales_orders so ON so.id = i.so_id
+		WHERE so.status IN ('PENDING', 'CONFIRMED', 'PICKING')
+		AND so.deleted_at IS NULL
+		AND i.status != 'CLOSED'
+		AND i.quantity > i.shipped_qty
+		ORDER BY due_date ASC NULLS FIRST, so.so_code, i.id
+	`).Scan(&rows).Error
+	return rows, err
+}
+
+// GetProductDemandLines 获取产品已承诺的未发货销售需求（ATP占用），可排除指定订单
+func (r *SalesRepository) GetProductDemandLines(productID, excludeSOID string) ([]DemandLine, error) {
+	var rows []DemandLine
+	err := r.db.Raw(`
+		SELECT so.id AS so_id, so.so_code, i.id AS so_item_id, i.product_id,
+			i.quantity - i.shipped_qty AS quantity,
+			COALESCE(i.due_date, i.promised_date, so.shipping_date, so.order_date) AS due_date
+		FROM erp_so_items i
+		JOIN erp_sales_orders so ON so.id = i.so_id
+		WHERE i.product_id = ?
+		AND so.id::text != ?
+		AND so.status IN ('PENDING', 'CREDIT_HOLD', 'CONFIRMED', 'PICKING')
+		AND so.deleted_at IS NULL
+		AND i.status != 'CLOSED'
+		AND i.quantity > i.shipped_qty
+		ORDER BY due_date ASC NULLS FIRST
+	`, productID, excludeSOID).Scan(&rows).Error
+	return rows, err
+}
+
 // --- Service Order ---
 
 func (r *SalesRepository) CreateServiceOrder(so *entity.ServiceOrder) error {
@@ -169,6 +273,7 @@ type ServiceOrderListParams struct {
 	ServiceType string
 	CustomerID  string
 	AssigneeID  string
+	FailureCode string
 	Keyword     string
 	Page        int
 	Size        int
@@ -188,6 +293,9 @@ func (r *SalesRepository) ListServiceOrders(params ServiceOrderListParams) ([]en
 	if params.AssigneeID != "" {
 		query = query.Where("assignee_id = ?", params.AssigneeID)
 	}
+	if params.FailureCode != "" {
+		query = query.Where("failure_code = ?", params.FailureCode)
+	}
 	if params.Keyword != "" {
 		kw := "%" + params.Keyword + "%"
 		query = query.Where("service_code ILIKE ? OR product_sn ILIKE ?", kw, kw)
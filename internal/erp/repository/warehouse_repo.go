@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"gorm.io/gorm"
+)
+
+type WarehouseRepository struct {
+	db *gorm.DB
+}
+
+func NewWarehouseRepository(db *gorm.DB) *WarehouseRepository {
+	return &WarehouseRepository{db: db}
+}
+
+func (r *WarehouseRepository) List() ([]entity.Warehouse, error) {
+	var items []entity.Warehouse
+	err := r.db.Where("deleted_at IS NULL").Order("code").Find(&items).Error
+	return items, err
+}
+
+func (r *WarehouseRepository) GetByID(id string) (*entity.Warehouse, error) {
+	var wh entity.Warehouse
+	err := r.db.Where("id = ? AND deleted_at IS NULL", id).First(&wh).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wh, nil
+}
+
+// ListZones 仓库的库区及库位，按拣货路径顺序
+func (r *WarehouseRepository) ListZones(warehouseID string) ([]entity.WarehouseZone, error) {
+	var zones []entity.WarehouseZone
+	err := r.db.Preload("Locations", func(db *gorm.DB) *gorm.DB {
+		return db.Where("deleted_at IS NULL").Order("sequence, code")
+	}).Where("warehouse_id = ? AND deleted_at IS NULL", warehouseID).
+		Order("sequence, code").Find(&zones).Error
+	return zones, err
+}
+
+func (r *WarehouseRepository) GetZone(id string) (*entity.WarehouseZone, error) {
+	var zone entity.WarehouseZone
+	err := r.db.Where("id = ? AND deleted_at IS NULL", id).First(&zone).Error
+	if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (r *WarehouseRepository) CreateZone(zone *entity.WarehouseZone) error {
+	return r.db.Create(zone).Error
+}
+
+// GetLocation 获取库位（含所属库区）
+func (r *WarehouseRepository) GetLocation(id string) (*entity.WarehouseLocation, error) {
+	var loc entity.WarehouseLocation
+	err := r.db.Preload("Zone").Where("id = ? AND deleted_at IS NULL", id).First(&loc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+func (r *WarehouseRepository) CreateLocation(loc *entity.WarehouseLocation) error {
+	return r.db.Create(loc).Error
+}
+
+// ListPutawayRules 仓库的有效上架规则，按优先级
+func (r *WarehouseRepository) ListPutawayRules(warehouseID string) ([]entity.PutawayRule, error) {
+	var rules []entity.PutawayRule
+	err := r.db.Where("warehouse_id = ? AND status = ? AND deleted_at IS NULL", warehouseID, entity.WarehouseStatusActive).
+		Order("priority, created_at").Find(&rules).Error
+	return rules, err
+}
+
+func (r *WarehouseRepository) CreatePutawayRule(rule *entity.PutawayRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *WarehouseRepository) DeletePutawayRule(id string) error {
+	return r.db.Where("id = ?", id).Delete(&entity.PutawayRule{}).Error
+}
+
+// GetMaterialCategory 物料的类别ID（PLM 物料主数据）
+func (r *WarehouseRepository) GetMaterialCategory(materialID string) (string, error) {
+	var categoryID string
+	err := r.db.Raw("SELECT category_id FROM materials WHERE id = ?", materialID).Scan(&categoryID).Error
+	return categoryID, err
+}
@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WarrantyRepository struct {
+	db *gorm.DB
+}
+
+func NewWarrantyRepository(db *gorm.DB) *WarrantyRepository {
+	return &WarrantyRepository{db: db}
+}
+
+// --- Failure Code ---
+
+func (r *WarrantyRepository) ListFailureCodes(category, status string) ([]entity.FailureCode, error) {
+	query := r.db.Model(&entity.FailureCode{})
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var items []entity.FailureCode
+	err := query.Order("category, code").Find(&items).Error
+	return items, err
+}
+
+func (r *WarrantyRepository) GetFailureCode(code string) (*entity.FailureCode, error) {
+	var fc entity.FailureCode
+	err := r.db.Where("code = ?", code).First(&fc).Error
+	return &fc, err
+}
+
+func (r *WarrantyRepository) GetFailureCodeByID(id string) (*entity.FailureCode, error) {
+	var fc entity.FailureCode
+	err := r.db.Where("id = ?", id).First(&fc).Error
+	return &fc, err
+}
+
+func (r *WarrantyRepository) CreateFailureCode(fc *entity.FailureCode) error {
+	return r.db.Create(fc).Error
+}
+
+func (r *WarrantyRepository) UpdateFailureCode(fc *entity.FailureCode) error {
+	return r.db.Save(fc).Error
+}
+
+// --- Warranty Claim ---
+
+func (r *WarrantyRepository) CreateClaim(claim *entity.WarrantyClaim) error {
+	return r.db.Create(claim).Error
+}
+
+func (r *WarrantyRepository) GetClaimByID(id string) (*entity.WarrantyClaim, error) {
+	var claim entity.WarrantyClaim
+	err := r.db.Where("id = ?", id).First(&claim).Error
+	return &claim, err
+}
+
+// LockClaimByID 在事务内锁定索赔行
+func (r *WarrantyRepository) LockClaimByID(id string) (*entity.WarrantyClaim, error) {
+	var claim entity.WarrantyClaim
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&claim).Error
+	return &claim, err
+}
+
+// DB 返回底层db用于事务
+func (r *WarrantyRepository) DB() *gorm.DB {
+	return r.db
+}
+
+func (r *WarrantyRepository) UpdateClaim(claim *entity.WarrantyClaim) error {
+	return r.db.Save(claim).Error
+}
+
+// FindActiveClaimByServiceOrder 服务工单未驳回的索赔，不存在时返回 nil
+func (r *WarrantyRepository) FindActiveClaimByServiceOrder(serviceOrderID string) (*entity.WarrantyClaim, error) {
+	var items []entity.WarrantyClaim
+	err := r.db.Where("service_order_id = ? AND status <> ?", serviceOrderID, entity.ClaimStatusRejected).
+		Limit(1).Find(&items).Error
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+type WarrantyClaimListParams struct {
+	Status      string
+	ProductID   string
+	BOMVersion  string
+	SupplierID  string
+	FailureCode string
+	SerialNo    string
+	Page        int
+	Size        int
+}
+
+func (r *WarrantyRepository) ListClaims(params WarrantyClaimListParams) ([]entity.WarrantyClaim, int64, error) {
+	query := r.db.Model(&entity.WarrantyClaim{})
+	if params.Status != "" {
+		query = query.Where("status = ?", params.Status)
+	}
+	if params.ProductID != "" {
+		query = query.Where("product_id = ?", params.ProductID)
+	}
+	if params.BOMVersion != "" {
+		query = query.Where("bom_version = ?", params.BOMVersion)
+	}
+	if params.SupplierID != "" {
+		query = query.Where("supplier_id = ?", params.SupplierID)
+	}
+	if params.FailureCode != "" {
+		query = query.Where("failure_code = ?", params.FailureCode)
+	}
+	if params.SerialNo != "" {
+		query = query.Where("serial_no = ?", params.SerialNo)
+	}
+	var total int64
+	query.Count(&total)
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Size <= 0 {
+		params.Size = 20
+	}
+	var items []entity.WarrantyClaim
+	err := query.Order("created_at DESC").
+		Offset((params.Page - 1) * params.Size).Limit(params.Size).Find(&items).Error
+	return items, total, err
+}
+
+// --- Analytics ---
+
+// 现场返修率的统计维度
+const (
+	FieldReturnByProduct      = "product"
+	FieldReturnByBOMVersion   = "bom_version"
+	FieldReturnByComponentLot = "component_lot"
+	FieldReturnBySupplier     = "supplier"
+)
+
+type FieldReturnParams struct {
+	GroupBy             string
+	ProductID           string
+	ComponentMaterialID string // 仅 component_lot 维度
+	From                *time.Time
+	To                  *time.Time
+}
+
+// FieldReturnRate 一个统计维度的发货量、返修量与索赔成本
+type FieldReturnRate struct {
+	ProductID           string  `json:"product_id,omitempty"`
+	ProductCode         string  `json:"product_code,omitempty"`
+	BOMID               string  `json:"bom_id,omitempty"`
+	BOMVersion          string  `json:"bom_version,omitempty"`
+	ComponentMaterialID string  `json:"component_material_id,omitempty"`
+	ComponentCode       string  `json:"component_code,omitempty"`
+	ComponentLotNo      string  `json:"component_lot_no,omitempty"`
+	SupplierID          string  `json:"supplier_id,omitempty"`
+	SupplierName        string  `json:"supplier_name,omitempty"`
+	ShippedUnits        int64   `json:"shipped_units"`  // 发货序列号数
+	ReturnedUnits       int64   `json:"returned_units"` // 有索赔的序列号数
+	Claims              int64   `json:"claims"`
+	ClaimCost           float64 `json:"claim_cost"`
+	AttributedClaims    int64   `json:"attributed_claims"` // 归因到该组件批次/供应商的索赔
+	AttributedCost      float64 `json:"attributed_cost"`
+	ChargebackAmount    float64 `json:"chargeback_amount"`
+	ReturnRate          float64 `json:"return_rate"` // returned_units / shipped_units
+}
+
+// fieldReturnDim 统计维度对应的分组列与关联
+type fieldReturnDim struct {
+	columns string
+	groupBy string
+	joins   string
+}
+
+// 组件维度只展开生产工单直接消耗的组件批次；归因索赔按序列号预先汇总，避免关联放大
+var fieldReturnDims = map[string]fieldReturnDim{
+	FieldReturnByProduct: {
+		columns: "s.product_id, MAX(s.product_code) AS product_code",
+		groupBy: "s.product_id",
+	},
+	FieldReturnByBOMVersion: {
+		columns: `s.product_id, MAX(s.product_code) AS product_code,
+			COALESCE(w.bom_id, '') AS bom_id, COALESCE(w.bom_version, '') AS bom_version`,
+		groupBy: "s.product_id, COALESCE(w.bom_id, ''), COALESCE(w.bom_version, '')",
+	},
+	FieldReturnByComponentLot: {
+		columns: `c.material_id AS component_material_id, MAX(c.material_code) AS component_code,
+			c.lot_no AS component_lot_no, MAX(c.supplier_id) AS supplier_id`,
+		groupBy: "c.material_id, c.lot_no",
+		joins: `
+		JOIN (SELECT DISTINCT work_order_id, material_id, material_code, lot_no, supplier_id
+			FROM erp_lot_genealogy WHERE link_type = 'CONSUME' AND lot_no <> '') c ON c.work_order_id = b.work_order_id
+		LEFT JOIN (SELECT serial_no, component_material_id, component_lot_no, COUNT(*) AS n,
+				SUM(claim_cost) AS cost, SUM(chargeback_amount) AS chargeback
+			FROM erp_warranty_claims WHERE status <> 'REJECTED' AND component_lot_no <> ''
+			GROUP BY serial_no, component_material_id, component_lot_no) a
+			ON a.serial_no = s.serial_no AND a.component_material_id = c.material_id AND a.component_lot_no = c.lot_no`,
+	},
+	FieldReturnBySupplier: {
+		columns: "c.supplier_id",
+		groupBy: "c.supplier_id",
+		joins: `
+		JOIN (SELECT DISTINCT work_order_id, supplier_id
+			FROM erp_lot_genealogy WHERE link_type = 'CONSUME' AND supplier_id <> '') c ON c.work_order_id = b.work_order_id
+		LEFT JOIN (SELECT serial_no, supplier_id, COUNT(*) AS n,
+				SUM(claim_cost) AS cost, SUM(chargeback_amount) AS chargeback
+			FROM erp_warranty_claims WHERE status <> 'REJECTED' AND supplier_id <> ''
+			GROUP BY serial_no, supplier_id) a
+			ON a.serial_no = s.serial_no AND a.supplier_id = c.supplier_id`,
+	},
+}
+
+// GetFieldReturnRates 按维度统计现场返修率。发货量以序列号首次发货计，
+// 序列号的 BOM 版本与组件批次取其最早的生产工单
+func (r *WarrantyRepository) GetFieldReturnRates(params FieldReturnParams) ([]FieldReturnRate, error) {
+	dim, ok := fieldReturnDims[params.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("不支持的统计维度: %s", params.GroupBy)
+	}
+	attributed := "0 AS attributed_claims, 0 AS attributed_cost, 0 AS chargeback_amount"
+	if dim.joins != "" {
+		attributed = `COALESCE(SUM(a.n), 0) AS attributed_claims, COALESCE(SUM(a.cost), 0) AS attributed_cost,
+			COALESCE(SUM(a.chargeback), 0) AS chargeback_amount`
+	}
+
+	where := "WHERE 1 = 1"
+	var args []any
+	if params.ProductID != "" {
+		where += " AND s.product_id = ?"
+		args = append(args, params.ProductID)
+	}
+	if params.ComponentMaterialID != "" && params.GroupBy == FieldReturnByComponentLot {
+		where += " AND c.material_id = ?"
+		args = append(args, params.ComponentMaterialID)
+	}
+	if params.From != nil {
+		where += " AND s.shipped_at >= ?"
+		args = append(args, *params.From)
+	}
+	if params.To != nil {
+		where += " AND s.shipped_at < ?"
+		args = append(args, *params.To)
+	}
+
+	sql := fmt.Sprintf(`
+		WITH shipped AS (
+			SELECT DISTINCT ON (serial_no) serial_no, product_id, product_code, shipped_at
+			FROM erp_shipment_traces WHERE serial_no <> ''
+			ORDER BY serial_no, shipped_at
+		), built AS (
+			SELECT DISTINCT ON (serial_no) serial_no, work_order_id
+			FROM erp_lot_genealogy WHERE link_type = 'PRODUCE' AND serial_no <> ''
+			ORDER BY serial_no, created_at
+		), claimed AS (
+			SELECT serial_no, COUNT(*) AS n, SUM(claim_cost) AS cost
+			FROM erp_warranty_claims WHERE status <> 'REJECTED'
+			GROUP BY serial_no
+		)
+		SELECT %s,
+			COUNT(DISTINCT s.serial_no) AS shipped_units,
+			COUNT(DISTINCT cl.serial_no) AS returned_units,
+			COALESCE(SUM(cl.n), 0) AS claims,
+			COALESCE(SUM(cl.cost), 0) AS claim_cost,
+			%s
+		FROM shipped s
+		LEFT JOIN built b ON b.serial_no = s.serial_no
+		LEFT JOIN erp_work_orders w ON w.id = b.work_order_id
+		%s
+		LEFT JOIN claimed cl ON cl.serial_no = s.serial_no
+		%s
+		GROUP BY %s
+		ORDER BY COUNT(DISTINCT cl.serial_no)::float / COUNT(DISTINCT s.serial_no) DESC, COUNT(DISTINCT s.serial_no) DESC
+	`, dim.columns, attributed, dim.joins, where, dim.groupBy)
+
+	var rows []FieldReturnRate
+	if err := r.db.Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if rows[i].ShippedUnits > 0 {
+			rows[i].ReturnRate = float64(rows[i].ReturnedUnits) / float64(rows[i].ShippedUnits)
+		}
+	}
+	return rows, nil
+}
+
+type FailureParetoParams struct {
+	ProductID  string
+	BOMVersion string
+	SupplierID string
+	From       *time.Time
+	To         *time.Time
+}
+
+// FailurePareto 故障代码排列：各故障代码的索赔数、序列号数与成本
+type FailurePareto struct {
+	FailureCode string  `json:"failure_code"`
+	FailureName string  `json:"failure_name"`
+	Category    string  `json:"category"`
+	Claims      int64   `json:"claims"`
+	Units       int64   `json:"units"`
+	ClaimCost   float64 `json:"claim_cost"`
+	Share       float64 `json:"share"`      // 占全部索赔比例
+	Cumulative  float64 `json:"cumulative"` // 累计占比
+}
+
+func (r *WarrantyRepository) GetFailurePareto(params FailureParetoParams) ([]FailurePareto, error) {
+	query := r.db.Table("erp_warranty_claims c").
+		Select(`c.failure_code, COALESCE(MAX(f.name), '') AS failure_name, COALESCE(MAX(f.category), '') AS category,
+			COUNT(*) AS claims, COUNT(DISTINCT c.serial_no) AS units, COALESCE(SUM(c.claim_cost), 0) AS claim_cost`).
+		Joins("LEFT JOIN erp_failure_codes f ON f.code = c.failure_code").
+		Where("c.status <> ?", entity.ClaimStatusRejected)
+	if params.ProductID != "" {
+		query = query.Where("c.product_id = ?", params.ProductID)
+	}
+	if params.BOMVersion != "" {
+		query = query.Where("c.bom_version = ?", params.BOMVersion)
+	}
+	if params.SupplierID != "" {
+		query = query.Where("c.supplier_id = ?", params.SupplierID)
+	}
+	if params.From != nil {
+		query = query.Where("c.failed_at >= ?", *params.From)
+	}
+	if params.To != nil {
+		query = query.Where("c.failed_at < ?", *params.To)
+	}
+
+	var rows []FailurePareto
+	if err := query.Group("c.failure_code").Order("claims DESC, claim_cost DESC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	var total, cum int64
+	for _, row := range rows {
+		total += row.Claims
+	}
+	for i := range rows {
+		if total > 0 {
+			cum += rows[i].Claims
+			rows[i].Share = float64(rows[i].Claims) / float64(total)
+			rows[i].Cumulative = float64(cum) / float64(total)
+		}
+	}
+	return rows, nil
+}
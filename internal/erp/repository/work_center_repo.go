@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"gorm.io/gorm"
+)
+
+type WorkCenterRepository struct {
+	db *gorm.DB
+}
+
+func NewWorkCenterRepository(db *gorm.DB) *WorkCenterRepository {
+	return &WorkCenterRepository{db: db}
+}
+
+func (r *WorkCenterRepository) List() ([]entity.WorkCenter, error) {
+	var items []entity.WorkCenter
+	err := r.db.Where("deleted_at IS NULL").Order("code").Find(&items).Error
+	return items, err
+}
+
+func (r *WorkCenterRepository) GetByID(id string) (*entity.WorkCenter, error) {
+	var wc entity.WorkCenter
+	err := r.db.Where("id = ? AND deleted_at IS NULL", id).First(&wc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wc, nil
+}
+
+// FindByKey 按编码或名称查找工作中心（PLM 工艺步骤只记录工作中心文本）
+func (r *WorkCenterRepository) FindByKey(key string) (*entity.WorkCenter, error) {
+	var wc entity.WorkCenter
+	err := r.db.Where("(code = ? OR name = ?) AND deleted_at IS NULL", key, key).
+		Order(gorm.Expr("code = ? DESC", key)).First(&wc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wc, nil
+}
+
+func (r *WorkCenterRepository) Create(wc *entity.WorkCenter) error {
+	return r.db.Create(wc).Error
+}
+
+func (r *WorkCenterRepository) Update(wc *entity.WorkCenter) error {
+	return r.db.Save(wc).Error
+}
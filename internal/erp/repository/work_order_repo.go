@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/bitfantasy/nimo/internal/erp/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WorkOrderRepository struct {
@@ -20,10 +23,20 @@ func (r *WorkOrderRepository) Create(wo *entity.WorkOrder) error {
 func (r *WorkOrderRepository) GetByID(id string) (*entity.WorkOrder, error) {
 	var wo entity.WorkOrder
 	err := r.db.Preload("Materials").Preload("Reports").
+		Preload("Operations", func(db *gorm.DB) *gorm.DB { return db.Order("sequence") }).
 		Where("id = ? AND deleted_at IS NULL", id).First(&wo).Error
 	return &wo, err
 }
 
+// LockByID 事务内行锁读取工单，防止并发领料、报工或完工
+func (r *WorkOrderRepository) LockByID(id string) (*entity.WorkOrder, error) {
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id = ? AND deleted_at IS NULL", id).First(&entity.WorkOrder{}).Error; err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
 func (r *WorkOrderRepository) Update(wo *entity.WorkOrder) error {
 	return r.db.Save(wo).Error
 }
@@ -76,11 +89,77 @@ func (r *WorkOrderRepository) GetMaterialsByWOID(woID string) ([]entity.WorkOrde
 	return materials, err
 }
 
+// GetIssueMethods 物料默认发料方式
+func (r *WorkOrderRepository) GetIssueMethods(materialIDs []string) (map[string]string, error) {
+	methods := make(map[string]string)
+	if len(materialIDs) == 0 {
+		return methods, nil
+	}
+	var items []entity.MaterialIssueMethod
+	if err := r.db.Where("material_id IN ?", materialIDs).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		methods[item.MaterialID] = item.IssueMethod
+	}
+	return methods, nil
+}
+
+func (r *WorkOrderRepository) ListIssueMethods() ([]entity.MaterialIssueMethod, error) {
+	var items []entity.MaterialIssueMethod
+	err := r.db.Order("material_id").Find(&items).Error
+	return items, err
+}
+
+func (r *WorkOrderRepository) SaveIssueMethod(m *entity.MaterialIssueMethod) error {
+	return r.db.Save(m).Error
+}
+
 // CreateReport 创建报工记录
 func (r *WorkOrderRepository) CreateReport(report *entity.WorkOrderReport) error {
 	return r.db.Create(report).Error
 }
 
+func (r *WorkOrderRepository) UpdateOperation(op *entity.WorkOrderOperation) error {
+	return r.db.Save(op).Error
+}
+
+// CreateOperationReport 创建工序报工记录
+func (r *WorkOrderRepository) CreateOperationReport(report *entity.OperationReport) error {
+	return r.db.Create(report).Error
+}
+
+func (r *WorkOrderRepository) ListOperationReports(woID string) ([]entity.OperationReport, error) {
+	var reports []entity.OperationReport
+	err := r.db.Where("work_order_id = ?", woID).Order("reported_at").Find(&reports).Error
+	return reports, err
+}
+
+// OpenOperation 未完工工序及所属工单的排程信息
+type OpenOperation struct {
+	entity.WorkOrderOperation
+	WOCode       string     `json:"wo_code"`
+	ProductCode  string     `json:"product_code"`
+	Priority     int        `json:"priority"`
+	WOPlannedEnd *time.Time `json:"wo_planned_end"`
+}
+
+// ListOpenOperations 在制工单的未完工工序，按工单优先级、计划开始和工序顺序排列
+func (r *WorkOrderRepository) ListOpenOperations(workCenterID string) ([]OpenOperation, error) {
+	query := r.db.Table("erp_work_order_operations o").
+		Select("o.*, w.wo_code, w.product_code, w.priority, w.planned_end AS wo_planned_end").
+		Joins("JOIN erp_work_orders w ON w.id = o.work_order_id").
+		Where("w.deleted_at IS NULL AND w.status IN ? AND o.status <> ?",
+			[]string{entity.WOStatusCreated, entity.WOStatusPlanned, entity.WOStatusReleased, entity.WOStatusInProgress},
+			entity.OpStatusCompleted)
+	if workCenterID != "" {
+		query = query.Where("o.work_center_id = ?", workCenterID)
+	}
+	var ops []OpenOperation
+	err := query.Order("w.priority DESC, o.planned_start NULLS LAST, w.wo_code, o.sequence").Scan(&ops).Error
+	return ops, err
+}
+
 // GetInProductionQty 获取物料在制数量（活跃工单中的计划数量 - 已完成数量）
 func (r *WorkOrderRepository) GetInProductionQty(productID string) (float64, error) {
 	var result struct{ Total float64 }
@@ -94,6 +173,38 @@ func (r *WorkOrderRepository) GetInProductionQty(productID string) (float64, err
 	return result.Total, err
 }
 
+// GetScheduledCompletions 按计划完工日期汇总在制工单的未完工数量
+func (r *WorkOrderRepository) GetScheduledCompletions(productID string) ([]ScheduledReceipt, error) {
+	var rows []ScheduledReceipt
+	err := r.db.Raw(`
+		SELECT planned_end AS date, COALESCE(SUM(planned_qty - completed_qty), 0) AS quantity
+		FROM erp_work_orders
+		WHERE product_id = ?
+		AND status IN ('CREATED', 'PLANNED', 'RELEASED', 'IN_PROGRESS')
+		AND deleted_at IS NULL
+		GROUP BY planned_end
+		HAVING SUM(planned_qty - completed_qty) > 0
+	`, productID).Scan(&rows).Error
+	return rows, err
+}
+
+// GetOpenMaterialDemand 按计划开工日期汇总活跃工单对物料的未发料需求
+func (r *WorkOrderRepository) GetOpenMaterialDemand(materialID string) ([]ScheduledReceipt, error) {
+	var rows []ScheduledReceipt
+	err := r.db.Raw(`
+		SELECT wo.planned_start AS date,
+			COALESCE(SUM(m.required_qty - m.issued_qty + m.returned_qty), 0) AS quantity
+		FROM erp_work_order_materials m
+		JOIN erp_work_orders wo ON wo.id = m.work_order_id
+		WHERE m.material_id = ?
+		AND wo.status IN ('CREATED', 'PLANNED', 'RELEASED', 'IN_PROGRESS')
+		AND wo.deleted_at IS NULL
+		GROUP BY wo.planned_start
+		HAVING SUM(m.required_qty - m.issued_qty + m.returned_qty) > 0
+	`, materialID).Scan(&rows).Error
+	return rows, err
+}
+
 // DB 返回底层db用于事务
 func (r *WorkOrderRepository) DB() *gorm.DB {
 	return r.db
@@ -0,0 +1,433 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	plmEntity "github.com/bitfantasy/nimo/internal/plm/entity"
+	"gorm.io/gorm"
+)
+
+// ATPService 可承诺量（ATP）与可生产承诺（CTP）计算
+type ATPService struct {
+	inventoryRepo *repository.InventoryRepository
+	purchaseRepo  *repository.PurchaseRepository
+	woRepo        *repository.WorkOrderRepository
+	salesRepo     *repository.SalesRepository
+	mrpRepo       *repository.MRPRepository
+	db            *gorm.DB // 直接访问PLM数据
+}
+
+func NewATPService(
+	inventoryRepo *repository.InventoryRepository,
+	purchaseRepo *repository.PurchaseRepository,
+	woRepo *repository.WorkOrderRepository,
+	salesRepo *repository.SalesRepository,
+	mrpRepo *repository.MRPRepository,
+	db *gorm.DB,
+) *ATPService {
+	return &ATPService{
+		inventoryRepo: inventoryRepo,
+		purchaseRepo:  purchaseRepo,
+		woRepo:        woRepo,
+		salesRepo:     salesRepo,
+		mrpRepo:       mrpRepo,
+		db:            db,
+	}
+}
+
+type ATPRequest struct {
+	ProductID   string     `json:"product_id" binding:"required"`
+	Quantity    float64    `json:"quantity" binding:"required,gt=0"`
+	RequestDate *time.Time `json:"request_date"` // 要求交期，空为今天
+	Mode        string     `json:"mode"`         // ATP（默认）, CTP
+	ExcludeSOID string     `json:"-"`            // 重算已有订单时排除其自身需求
+}
+
+// ATPBucket 按日汇总的供需与可承诺量
+type ATPBucket struct {
+	Date       time.Time `json:"date"`
+	Supply     float64   `json:"supply"`
+	Demand     float64   `json:"demand"`
+	Cumulative float64   `json:"cumulative"` // 累计预计可用
+	ATPQty     float64   `json:"atp_qty"`    // 当日起可承诺数量（不挤占后续已承诺需求）
+}
+
+// CTPComponent CTP 展开的物料检查结果
+type CTPComponent struct {
+	MaterialID   string    `json:"material_id"`
+	MaterialCode string    `json:"material_code"`
+	MaterialName string    `json:"material_name"`
+	Level        int       `json:"level"`
+	RequiredQty  float64   `json:"required_qty"`
+	Source       string    `json:"source"` // STOCK 库存/计划接收, PRODUCE 自制, PURCHASE 采购
+	LeadTimeDays int       `json:"lead_time_days"`
+	ReadyDate    time.Time `json:"ready_date"`
+}
+
+// CTP 物料来源
+const (
+	ctpSourceStock    = "STOCK"
+	ctpSourceProduce  = "PRODUCE"
+	ctpSourcePurchase = "PURCHASE"
+)
+
+type ATPResult struct {
+	ProductID    string         `json:"product_id"`
+	Quantity     float64        `json:"quantity"`
+	RequestDate  time.Time      `json:"request_date"`
+	Mode         string         `json:"mode"`
+	OnHand       float64        `json:"on_hand"`
+	ATPQty       float64        `json:"atp_qty"`       // 要求交期可承诺数量
+	ATPDate      *time.Time     `json:"atp_date"`      // 仅靠现有供应的最早可发货日
+	CTPDate      *time.Time     `json:"ctp_date"`      // 补产不足部分的最早可发货日
+	PromisedDate *time.Time     `json:"promised_date"` // 最早可发货日
+	Status       string         `json:"status"`
+	Note         string         `json:"note"`
+	Profile      []ATPBucket    `json:"profile"`
+	Components   []CTPComponent `json:"components,omitempty"`
+}
+
+// Check 计算产品在要求交期的可承诺量与最早可发货日期
+func (s *ATPService) Check(req ATPRequest) (*ATPResult, error) {
+	today := truncDay(time.Now())
+	requestDate := today
+	if req.RequestDate != nil && truncDay(*req.RequestDate).After(today) {
+		requestDate = truncDay(*req.RequestDate)
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = entity.PromiseModeATP
+	}
+	if mode != entity.PromiseModeATP && mode != entity.PromiseModeCTP {
+		return nil, fmt.Errorf("不支持的承诺方式: %s", mode)
+	}
+
+	profile, onHand, err := s.profile(req.ProductID, req.ExcludeSOID, today)
+	if err != nil {
+		return nil, err
+	}
+	res := &ATPResult{
+		ProductID:   req.ProductID,
+		Quantity:    req.Quantity,
+		RequestDate: requestDate,
+		Mode:        mode,
+		OnHand:      onHand,
+		ATPQty:      atpAt(profile, requestDate),
+		ATPDate:     earliestATP(profile, req.Quantity),
+		Profile:     profile,
+	}
+	res.PromisedDate = res.ATPDate
+
+	if mode == entity.PromiseModeCTP && (res.ATPDate == nil || res.ATPDate.After(requestDate)) {
+		// 要求交期可承诺部分照常发出，不足部分按 BOM 补产
+		short := req.Quantity - max(res.ATPQty, 0)
+		ready, comps, err := s.capable(req.ProductID, short, today)
+		if err != nil {
+			return nil, err
+		}
+		if ready.Before(requestDate) {
+			ready = requestDate
+		}
+		res.CTPDate = &ready
+		res.Components = comps
+		if res.PromisedDate == nil || ready.Before(*res.PromisedDate) {
+			res.PromisedDate = &ready
+		}
+	}
+
+	switch {
+	case res.PromisedDate == nil:
+		res.Status = entity.PromiseStatusUnavailable
+		res.Note = fmt.Sprintf("计划供应不足，可承诺 %.4f / 需求 %.4f", max(res.ATPQty, 0), req.Quantity)
+	case res.PromisedDate.After(requestDate):
+		res.Status = entity.PromiseStatusLate
+		res.Note = fmt.Sprintf("最早 %s 可发货，晚于要求交期 %s", res.PromisedDate.Format("2006-01-02"), requestDate.Format("2006-01-02"))
+	default:
+		res.Status = entity.PromiseStatusOK
+	}
+	return res, nil
+}
+
+// profile 按日汇总物料供需：现有可用（加回订单预留）+ 采购在途 + 在制工单 - 已承诺销售需求 - 工单未发料需求。
+// 早于今天或无日期的供需计入今天
+func (s *ATPService) profile(materialID, excludeSOID string, today time.Time) ([]ATPBucket, float64, error) {
+	available, err := s.inventoryRepo.GetTotalStock(materialID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取库存失败: %w", err)
+	}
+	reserved, err := s.inventoryRepo.GetOrderReservedQty(materialID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取库存预留失败: %w", err)
+	}
+	onHand := available + reserved
+
+	byDay := map[time.Time]*ATPBucket{today: {Date: today}}
+	bucket := func(d *time.Time) *ATPBucket {
+		day := today
+		if d != nil && truncDay(*d).After(today) {
+			day = truncDay(*d)
+		}
+		if byDay[day] == nil {
+			byDay[day] = &ATPBucket{Date: day}
+		}
+		return byDay[day]
+	}
+
+	receipts, err := s.purchaseRepo.GetScheduledReceipts(materialID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取采购在途失败: %w", err)
+	}
+	completions, err := s.woRepo.GetScheduledCompletions(materialID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取在制工单失败: %w", err)
+	}
+	for _, r := range append(receipts, completions...) {
+		bucket(r.Date).Supply += r.Quantity
+	}
+	lines, err := s.salesRepo.GetProductDemandLines(materialID, excludeSOID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取销售需求失败: %w", err)
+	}
+	for _, l := range lines {
+		bucket(l.DueDate).Demand += l.Quantity
+	}
+	woDemand, err := s.woRepo.GetOpenMaterialDemand(materialID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("获取工单用料需求失败: %w", err)
+	}
+	for _, d := range woDemand {
+		bucket(d.Date).Demand += d.Quantity
+	}
+
+	profile := make([]ATPBucket, 0, len(byDay))
+	for _, b := range byDay {
+		profile = append(profile, *b)
+	}
+	return buildATPProfile(profile, onHand), onHand, nil
+}
+
+// buildATPProfile 按日期排序后计算各日累计可用与可承诺数量。
+// 某日的可承诺量 = 该日及以后累计可用的最小值，保证不挤占后续已承诺的需求
+func buildATPProfile(profile []ATPBucket, onHand float64) []ATPBucket {
+	if len(profile) == 0 {
+		return profile
+	}
+	sort.Slice(profile, func(i, j int) bool { return profile[i].Date.Before(profile[j].Date) })
+	cum := onHand
+	for i := range profile {
+		cum += profile[i].Supply - profile[i].Demand
+		profile[i].Cumulative = cum
+	}
+	floor := profile[len(profile)-1].Cumulative
+	for i := len(profile) - 1; i >= 0; i-- {
+		floor = min(floor, profile[i].Cumulative)
+		profile[i].ATPQty = floor
+	}
+	return profile
+}
+
+// atpAt 指定日期的可承诺数量
+func atpAt(profile []ATPBucket, day time.Time) float64 {
+	qty := profile[0].ATPQty
+	for _, b := range profile {
+		if b.Date.After(day) {
+			break
+		}
+		qty = b.ATPQty
+	}
+	return qty
+}
+
+// earliestATP 可承诺数量满足需求的最早日期，计划范围内无法满足时为 nil
+func earliestATP(profile []ATPBucket, qty float64) *time.Time {
+	for _, b := range profile {
+		if b.ATPQty >= qty-1e-9 {
+			d := b.Date
+			return &d
+		}
+	}
+	return nil
+}
+
+// capable 补产指定数量的最早完工日期：逐层展开已发布BOM，物料按ATP、
+// 下层自制件按CTP、采购件按提前期确定齐套日期，再加上本层生产周期
+func (s *ATPService) capable(productID string, qty float64, today time.Time) (time.Time, []CTPComponent, error) {
+	edges, err := s.bomEdges(productID)
+	if err != nil {
+		return today, nil, err
+	}
+	leadTimes, mats, err := s.leadTimes(productID, edges)
+	if err != nil {
+		return today, nil, err
+	}
+
+	e := newCTPExplosion(edges, leadTimes, mats, today, func(materialID string) ([]ATPBucket, error) {
+		profile, _, err := s.profile(materialID, "", today)
+		return profile, err
+	})
+	ready, err := e.explode(productID, qty, 1)
+	if err != nil {
+		return today, nil, err
+	}
+	sort.SliceStable(e.comps, func(i, j int) bool { return e.comps[i].Level < e.comps[j].Level })
+	return ready.AddDate(0, 0, leadTimes[productID]), e.comps, nil
+}
+
+// ctpExplosion 能力承诺的BOM展开。同一物料作为多个父项的组件或在多层重复出现时共用一份可承诺量，
+// allocated 记录本次展开已占用的数量，后续需求只能使用剩余部分
+type ctpExplosion struct {
+	edges     map[string]map[string]float64
+	leadTimes map[string]int
+	mats      map[string]plmEntity.Material
+	today     time.Time
+	profileOf func(materialID string) ([]ATPBucket, error)
+
+	profiles  map[string][]ATPBucket
+	allocated map[string]float64
+	comps     []CTPComponent
+}
+
+func newCTPExplosion(edges map[string]map[string]float64, leadTimes map[string]int, mats map[string]plmEntity.Material,
+	today time.Time, profileOf func(materialID string) ([]ATPBucket, error)) *ctpExplosion {
+	return &ctpExplosion{
+		edges: edges, leadTimes: leadTimes, mats: mats, today: today, profileOf: profileOf,
+		profiles: make(map[string][]ATPBucket), allocated: make(map[string]float64),
+	}
+}
+
+func (e *ctpExplosion) profile(materialID string) ([]ATPBucket, error) {
+	if p, ok := e.profiles[materialID]; ok {
+		return p, nil
+	}
+	p, err := e.profileOf(materialID)
+	if err != nil {
+		return nil, err
+	}
+	e.profiles[materialID] = p
+	return p, nil
+}
+
+// explode 展开 parent 的下层组件，返回齐套日期。组件按物料ID顺序占用可承诺量，结果可复现
+func (e *ctpExplosion) explode(parent string, qty float64, level int) (time.Time, error) {
+	if level > maxBOMDepth {
+		return e.today, fmt.Errorf("BOM层级超过%d层，可能存在循环引用", maxBOMDepth)
+	}
+	children := make([]string, 0, len(e.edges[parent]))
+	for childID := range e.edges[parent] {
+		children = append(children, childID)
+	}
+	sort.Strings(children)
+
+	ready := e.today
+	for _, childID := range children {
+		need := qty * e.edges[parent][childID]
+		comp := CTPComponent{MaterialID: childID, Level: level, RequiredQty: need, LeadTimeDays: e.leadTimes[childID]}
+		if m, ok := e.mats[childID]; ok {
+			comp.MaterialCode, comp.MaterialName = m.Code, m.Name
+		}
+		profile, err := e.profile(childID)
+		if err != nil {
+			return e.today, err
+		}
+		used := e.allocated[childID]
+		idx := len(e.comps)
+		e.comps = append(e.comps, comp)
+		if d := earliestATP(profile, used+need); d != nil {
+			e.allocated[childID] = used + need
+			e.comps[idx].Source = ctpSourceStock
+			e.comps[idx].ReadyDate = *d
+		} else if len(e.edges[childID]) > 0 {
+			// 现有可承诺部分先占用，差额向下展开补产
+			onHand := max(atpAt(profile, e.today)-used, 0)
+			e.allocated[childID] = used + onHand
+			sub, err := e.explode(childID, need-onHand, level+1)
+			if err != nil {
+				return e.today, err
+			}
+			e.comps[idx].Source = ctpSourceProduce
+			e.comps[idx].ReadyDate = sub.AddDate(0, 0, e.leadTimes[childID])
+		} else {
+			e.comps[idx].Source = ctpSourcePurchase
+			e.comps[idx].ReadyDate = e.today.AddDate(0, 0, e.leadTimes[childID])
+		}
+		if e.comps[idx].ReadyDate.After(ready) {
+			ready = e.comps[idx].ReadyDate
+		}
+	}
+	return ready, nil
+}
+
+// bomEdges 读取产品最新已发布BOM，构建父项 → 子物料 → 单位用量（半成品按BOM行层级展开）
+func (s *ATPService) bomEdges(productID string) (map[string]map[string]float64, error) {
+	edges := make(map[string]map[string]float64)
+	var header plmEntity.BOMHeader
+	if err := s.db.Where("product_id = ? AND status = 'released'", productID).
+		Order("created_at DESC").First(&header).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return edges, nil // 没有已发布BOM，只能按提前期承诺
+		}
+		return nil, fmt.Errorf("读取BOM失败: %w", err)
+	}
+	var items []plmEntity.BOMItem
+	if err := s.db.Where("bom_header_id = ?", header.ID).Order("level, sequence").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("读取BOM失败: %w", err)
+	}
+	byID := make(map[string]plmEntity.BOMItem, len(items))
+	for _, it := range items {
+		byID[it.ID] = it
+	}
+	for _, it := range items {
+		parent := productID
+		if it.ParentItemID != "" {
+			p, ok := byID[it.ParentItemID]
+			if !ok {
+				continue
+			}
+			parent = p.MaterialID
+		}
+		if edges[parent] == nil {
+			edges[parent] = make(map[string]float64)
+		}
+		edges[parent][it.MaterialID] += it.Quantity
+	}
+	return edges, nil
+}
+
+// leadTimes 物料提前期取PLM物料主数据，计划参数维护的提前期优先（成品生产周期在计划参数中维护）
+func (s *ATPService) leadTimes(productID string, edges map[string]map[string]float64) (map[string]int, map[string]plmEntity.Material, error) {
+	ids := []string{productID}
+	for _, children := range edges {
+		for id := range children {
+			ids = append(ids, id)
+		}
+	}
+	var list []plmEntity.Material
+	if err := s.db.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, nil, fmt.Errorf("读取物料主数据失败: %w", err)
+	}
+	mats := make(map[string]plmEntity.Material, len(list))
+	leadTimes := make(map[string]int, len(ids))
+	for _, m := range list {
+		mats[m.ID] = m
+		leadTimes[m.ID] = m.LeadTimeDays
+	}
+	params, err := s.mrpRepo.GetPlanningParams(ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取物料计划参数失败: %w", err)
+	}
+	for id, p := range params {
+		if p.LeadTimeDays != nil {
+			leadTimes[id] = *p.LeadTimeDays
+		}
+	}
+	return leadTimes, mats, nil
+}
+
+func truncDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	plmEntity "github.com/bitfantasy/nimo/internal/plm/entity"
+)
+
+// explodeForTest 以固定的今日可承诺量展开BOM，stock 为各物料今日起的可承诺数量
+func explodeForTest(t *testing.T, edges map[string]map[string]float64, stock map[string]float64, qty float64) (time.Time, map[string][]CTPComponent) {
+	t.Helper()
+	today := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	leadTimes := map[string]int{"FG": 1, "SA": 3, "A": 5, "B": 7}
+	e := newCTPExplosion(edges, leadTimes, map[string]plmEntity.Material{}, today, func(materialID string) ([]ATPBucket, error) {
+		q := stock[materialID]
+		return []ATPBucket{{Date: today, Cumulative: q, ATPQty: q}}, nil
+	})
+	ready, err := e.explode("FG", qty, 1)
+	if err != nil {
+		t.Fatalf("explode: %v", err)
+	}
+	byMaterial := make(map[string][]CTPComponent)
+	for _, c := range e.comps {
+		byMaterial[c.MaterialID] = append(byMaterial[c.MaterialID], c)
+	}
+	return ready, byMaterial
+}
+
+// TestCTPExplosionNetsSharedStock 同一组件在多处出现时共用库存，不重复计入
+func TestCTPExplosionNetsSharedStock(t *testing.T) {
+	today := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+
+	t.Run("sibling and sub-assembly share a component", func(t *testing.T) {
+		// FG = A + SA, SA = A；A 库存只够一处
+		edges := map[string]map[string]float64{
+			"FG": {"A": 1, "SA": 1},
+			"SA": {"A": 1},
+		}
+		ready, comps := explodeForTest(t, edges, map[string]float64{"A": 1}, 1)
+		if len(comps["A"]) != 2 {
+			t.Fatalf("expected A twice, got %+v", comps["A"])
+		}
+		if comps["A"][0].Source != ctpSourceStock {
+			t.Fatalf("expected first A from stock, got %s", comps["A"][0].Source)
+		}
+		if comps["A"][1].Source != ctpSourcePurchase {
+			t.Fatalf("expected second A to be purchased once stock is used, got %s", comps["A"][1].Source)
+		}
+		// A 采购5天 + SA 生产3天
+		if want := today.AddDate(0, 0, 8); !ready.Equal(want) {
+			t.Fatalf("expected kit ready %s, got %s", want.Format("2006-01-02"), ready.Format("2006-01-02"))
+		}
+	})
+
+	t.Run("partial stock of a sub-assembly is used before producing the rest", func(t *testing.T) {
+		// FG = 3×SA，SA 有2件库存，补产1件只需1个B
+		edges := map[string]map[string]float64{
+			"FG": {"SA": 3},
+			"SA": {"B": 1},
+		}
+		_, comps := explodeForTest(t, edges, map[string]float64{"SA": 2, "B": 1}, 1)
+		if comps["SA"][0].Source != ctpSourceProduce {
+			t.Fatalf("expected SA produced, got %s", comps["SA"][0].Source)
+		}
+		if b := comps["B"][0]; b.RequiredQty != 1 || b.Source != ctpSourceStock {
+			t.Fatalf("expected 1 B from stock, got %+v", b)
+		}
+	})
+
+	t.Run("enough stock for every use", func(t *testing.T) {
+		edges := map[string]map[string]float64{
+			"FG": {"A": 2, "SA": 1},
+			"SA": {"A": 1},
+		}
+		ready, comps := explodeForTest(t, edges, map[string]float64{"A": 3, "SA": 0}, 1)
+		for _, c := range comps["A"] {
+			if c.Source != ctpSourceStock {
+				t.Fatalf("expected all A from stock, got %+v", comps["A"])
+			}
+		}
+		if want := today.AddDate(0, 0, 3); !ready.Equal(want) {
+			t.Fatalf("expected kit ready %s, got %s", want.Format("2006-01-02"), ready.Format("2006-01-02"))
+		}
+	})
+}
+
+// TestBuildATPProfile 累计可用按日期滚动，可承诺量取该日及以后累计可用的最小值
+func TestBuildATPProfile(t *testing.T) {
+	d := func(day int) time.Time { return time.Date(2026, 3, day, 0, 0, 0, 0, time.Local) }
+	cases := []struct {
+		name    string
+		onHand  float64
+		buckets []ATPBucket
+		wantCum []float64
+		wantATP []float64
+	}{
+		{
+			name:    "only stock",
+			onHand:  10,
+			buckets: []ATPBucket{{Date: d(2)}},
+			wantCum: []float64{10},
+			wantATP: []float64{10},
+		},
+		{
+			name:   "later demand reserves today's stock",
+			onHand: 10,
+			buckets: []ATPBucket{
+				{Date: d(2)},
+				{Date: d(5), Demand: 8},
+				{Date: d(9), Supply: 20},
+			},
+			wantCum: []float64{10, 2, 22},
+			wantATP: []float64{2, 2, 22},
+		},
+		{
+			name:   "unsorted buckets are ordered by date",
+			onHand: 0,
+			buckets: []ATPBucket{
+				{Date: d(9), Demand: 5},
+				{Date: d(2), Supply: 3},
+				{Date: d(5), Supply: 4},
+			},
+			wantCum: []float64{3, 7, 2},
+			wantATP: []float64{2, 2, 2},
+		},
+		{
+			name:   "oversold horizon goes negative",
+			onHand: 5,
+			buckets: []ATPBucket{
+				{Date: d(2), Demand: 2},
+				{Date: d(5), Demand: 6},
+				{Date: d(9), Supply: 10},
+			},
+			wantCum: []float64{3, -3, 7},
+			wantATP: []float64{-3, -3, 7},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := buildATPProfile(tc.buckets, tc.onHand)
+			for i, b := range got {
+				if i > 0 && !b.Date.After(got[i-1].Date) {
+					t.Fatalf("buckets not sorted: %v", got)
+				}
+				if b.Cumulative != tc.wantCum[i] || b.ATPQty != tc.wantATP[i] {
+					t.Fatalf("bucket %s: cumulative %v atp %v, want %v / %v",
+						b.Date.Format("01-02"), b.Cumulative, b.ATPQty, tc.wantCum[i], tc.wantATP[i])
+				}
+			}
+		})
+	}
+
+	if got := buildATPProfile(nil, 10); len(got) != 0 {
+		t.Fatalf("expected empty profile, got %v", got)
+	}
+}
+
+// TestEarliestATP 满足数量的最早可承诺日期
+func TestEarliestATP(t *testing.T) {
+	d := func(day int) time.Time { return time.Date(2026, 3, day, 0, 0, 0, 0, time.Local) }
+	profile := buildATPProfile([]ATPBucket{
+		{Date: d(2)},
+		{Date: d(5), Demand: 8},
+		{Date: d(9), Supply: 20},
+	}, 10)
+	cases := []struct {
+		qty  float64
+		want *time.Time
+	}{
+		{2, &[]time.Time{d(2)}[0]},
+		{3, &[]time.Time{d(9)}[0]},
+		{22, &[]time.Time{d(9)}[0]},
+		{23, nil},
+	}
+	for _, tc := range cases {
+		got := earliestATP(profile, tc.qty)
+		switch {
+		case tc.want == nil && got != nil:
+			t.Errorf("qty %v: expected no date, got %s", tc.qty, got.Format("01-02"))
+		case tc.want != nil && (got == nil || !got.Equal(*tc.want)):
+			t.Errorf("qty %v: expected %s, got %v", tc.qty, tc.want.Format("01-02"), got)
+		}
+	}
+	if q := atpAt(profile, d(6)); q != 2 {
+		t.Errorf("atpAt 03-06: expected 2, got %v", q)
+	}
+}
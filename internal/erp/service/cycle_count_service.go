@@ -0,0 +1,819 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultCountPolicies 未配置策略时的默认盘点频率与容差
+var defaultCountPolicies = map[string]entity.CycleCountPolicy{
+	entity.ABCClassA: {ABCClass: entity.ABCClassA, FrequencyDays: 30, TolerancePct: 1},
+	entity.ABCClassB: {ABCClass: entity.ABCClassB, FrequencyDays: 90, TolerancePct: 2},
+	entity.ABCClassC: {ABCClass: entity.ABCClassC, FrequencyDays: 180, TolerancePct: 5},
+}
+
+// abcUsageTxTypes 计入 ABC 消耗的流水类型
+var abcUsageTxTypes = []string{entity.TxTypeProductionOut, entity.TxTypeSalesOut}
+
+type CycleCountService struct {
+	repo    *repository.CycleCountRepository
+	invRepo *repository.InventoryRepository
+}
+
+func NewCycleCountService(repo *repository.CycleCountRepository, invRepo *repository.InventoryRepository) *CycleCountService {
+	return &CycleCountService{repo: repo, invRepo: invRepo}
+}
+
+// withTx 返回各仓储绑定到同一事务的服务副本，多表写入在事务内完成
+func (s *CycleCountService) withTx(tx *gorm.DB) *CycleCountService {
+	return &CycleCountService{
+		repo:    repository.NewCycleCountRepository(tx),
+		invRepo: repository.NewInventoryRepository(tx),
+	}
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// ===== ABC 分类 =====
+
+type ClassifyRequest struct {
+	Days int     `json:"days"`  // 消耗统计天数，默认365
+	APct float64 `json:"a_pct"` // A 类累计金额占比上限，默认80
+	BPct float64 `json:"b_pct"` // B 类累计金额占比上限，默认95
+}
+
+type ClassifyResult struct {
+	Total      int                  `json:"total"`
+	TotalValue float64              `json:"total_value"`
+	Counts     map[string]int       `json:"counts"`
+	Items      []entity.MaterialABC `json:"items"`
+}
+
+// ClassifyABC 按统计期消耗金额降序累计占比划分 ABC；无消耗的物料归 C 类。
+// 已盘点过的物料按新类别频率从上次盘点日重算下次盘点日，从未盘点的物料在首个周期内均匀分布
+func (s *CycleCountService) ClassifyABC(req ClassifyRequest) (*ClassifyResult, error) {
+	if req.Days <= 0 {
+		req.Days = 365
+	}
+	if req.APct <= 0 {
+		req.APct = 80
+	}
+	if req.BPct <= req.APct {
+		req.BPct = math.Max(95, req.APct)
+	}
+	now := time.Now()
+	usage, err := s.repo.ConsumptionSince(now.AddDate(0, 0, -req.Days), abcUsageTxTypes)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		if usage[i].UsageValue != usage[j].UsageValue {
+			return usage[i].UsageValue > usage[j].UsageValue
+		}
+		return usage[i].MaterialCode < usage[j].MaterialCode
+	})
+
+	existing, err := s.repo.ListABC("")
+	if err != nil {
+		return nil, err
+	}
+	prev := make(map[string]entity.MaterialABC, len(existing))
+	for _, e := range existing {
+		prev[e.MaterialID] = e
+	}
+	policies, err := s.policyMap()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ClassifyResult{Counts: map[string]int{entity.ABCClassA: 0, entity.ABCClassB: 0, entity.ABCClassC: 0}}
+	for _, u := range usage {
+		result.TotalValue += u.UsageValue
+	}
+	items := make([]entity.MaterialABC, 0, len(usage))
+	cumulative := 0.0
+	for _, u := range usage {
+		item := entity.MaterialABC{
+			ID:           uuid.New().String(),
+			MaterialID:   u.MaterialID,
+			MaterialCode: u.MaterialCode,
+			MaterialName: u.MaterialName,
+			UsageQty:     u.UsageQty,
+			UsageValue:   u.UsageValue,
+			ClassifiedAt: now,
+			ABCClass:     entity.ABCClassC,
+		}
+		if result.TotalValue > 0 && u.UsageValue > 0 {
+			// 按进入该物料前的累计占比判定，保证首个物料总是 A 类
+			if cumulative*100/result.TotalValue < req.APct {
+				item.ABCClass = entity.ABCClassA
+			} else if cumulative*100/result.TotalValue < req.BPct {
+				item.ABCClass = entity.ABCClassB
+			}
+			cumulative += u.UsageValue
+			item.CumulativePct = math.Round(cumulative*1000000/result.TotalValue) / 10000
+		}
+		result.Counts[item.ABCClass]++
+		items = append(items, item)
+	}
+
+	// 从未盘点的物料按类别在首个周期内均匀排期，避免首日集中生成
+	uncounted := make(map[string]int)
+	seq := make(map[string]int)
+	for _, item := range items {
+		if p, ok := prev[item.MaterialID]; !ok || p.LastCountedAt == nil {
+			uncounted[item.ABCClass]++
+		}
+	}
+	today := dateOnly(now)
+	for i := range items {
+		item := &items[i]
+		freq := policies[item.ABCClass].FrequencyDays
+		p, ok := prev[item.MaterialID]
+		var next time.Time
+		switch {
+		case ok && p.LastCountedAt != nil:
+			next = dateOnly(p.LastCountedAt.AddDate(0, 0, freq))
+		case ok && p.ABCClass == item.ABCClass && p.NextCountDate != nil:
+			next = *p.NextCountDate
+		default:
+			next = today.AddDate(0, 0, seq[item.ABCClass]*freq/uncounted[item.ABCClass])
+			seq[item.ABCClass]++
+		}
+		item.NextCountDate = &next
+	}
+
+	if err := s.repo.SaveABC(items); err != nil {
+		return nil, err
+	}
+	result.Total = len(items)
+	result.Items = items
+	return result, nil
+}
+
+func (s *CycleCountService) ListABC(class string) ([]entity.MaterialABC, error) {
+	return s.repo.ListABC(class)
+}
+
+// ===== 盘点策略 =====
+
+func (s *CycleCountService) policyMap() (map[string]entity.CycleCountPolicy, error) {
+	policies := make(map[string]entity.CycleCountPolicy, len(defaultCountPolicies))
+	for k, p := range defaultCountPolicies {
+		policies[k] = p
+	}
+	saved, err := s.repo.ListPolicies()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range saved {
+		policies[p.ABCClass] = p
+	}
+	return policies, nil
+}
+
+// ListPolicies 各类别盘点策略（未配置的取默认值）
+func (s *CycleCountService) ListPolicies() ([]entity.CycleCountPolicy, error) {
+	policies, err := s.policyMap()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]entity.CycleCountPolicy, 0, len(policies))
+	for _, class := range []string{entity.ABCClassA, entity.ABCClassB, entity.ABCClassC} {
+		items = append(items, policies[class])
+	}
+	return items, nil
+}
+
+type SavePolicyRequest struct {
+	ABCClass       string  `json:"abc_class" binding:"required,oneof=A B C"`
+	FrequencyDays  int     `json:"frequency_days" binding:"required,gt=0"`
+	TolerancePct   float64 `json:"tolerance_pct" binding:"gte=0"`
+	ToleranceValue float64 `json:"tolerance_value" binding:"gte=0"`
+}
+
+func (s *CycleCountService) SavePolicies(reqs []SavePolicyRequest, userID string) ([]entity.CycleCountPolicy, error) {
+	for _, req := range reqs {
+		p := &entity.CycleCountPolicy{
+			ABCClass:       req.ABCClass,
+			FrequencyDays:  req.FrequencyDays,
+			TolerancePct:   req.TolerancePct,
+			ToleranceValue: req.ToleranceValue,
+			UpdatedBy:      userID,
+		}
+		if err := s.repo.SavePolicy(p); err != nil {
+			return nil, err
+		}
+	}
+	return s.ListPolicies()
+}
+
+// withinTolerance 差异同时满足数量百分比容差和金额容差（金额容差为0时不校验）
+func withinTolerance(p entity.CycleCountPolicy, variancePct, varianceValue float64) bool {
+	if math.Abs(variancePct) > p.TolerancePct+1e-9 {
+		return false
+	}
+	return p.ToleranceValue <= 0 || math.Abs(varianceValue) <= p.ToleranceValue+1e-9
+}
+
+// ===== 盘点任务 =====
+
+func newCycleCountCode(now time.Time) string {
+	return fmt.Sprintf("CC-%s-%04d", now.Format("20060102"), now.UnixNano()%10000)
+}
+
+// buildCountLines 按物料在仓库内各库位/批次余额生成盘点行
+func (s *CycleCountService) buildCountLines(warehouseID string, materialIDs, locationIDs []string, classes map[string]string) ([]entity.CycleCountLine, error) {
+	contents, err := s.invRepo.BinContents(warehouseID, materialIDs, locationIDs)
+	if err != nil {
+		return nil, err
+	}
+	costs := make(map[string]float64)
+	lines := make([]entity.CycleCountLine, 0, len(contents))
+	for _, c := range contents {
+		cost, ok := costs[c.MaterialID]
+		if !ok {
+			if inv, err := s.invRepo.GetByMaterialAndWarehouse(c.MaterialID, warehouseID); err == nil {
+				cost = inv.UnitCost
+			}
+			costs[c.MaterialID] = cost
+		}
+		class := classes[c.MaterialID]
+		if class == "" {
+			class = entity.ABCClassC
+		}
+		lines = append(lines, entity.CycleCountLine{
+			ID:           uuid.New().String(),
+			MaterialID:   c.MaterialID,
+			MaterialCode: c.MaterialCode,
+			MaterialName: c.MaterialName,
+			ABCClass:     class,
+			LocationID:   c.LocationID,
+			BatchNo:      c.BatchNo,
+			SnapshotQty:  c.Quantity,
+			UnitCost:     cost,
+			Status:       entity.CountLinePending,
+		})
+	}
+	return lines, nil
+}
+
+func (s *CycleCountService) abcClasses() (map[string]string, error) {
+	items, err := s.repo.ListABC("")
+	if err != nil {
+		return nil, err
+	}
+	classes := make(map[string]string, len(items))
+	for _, item := range items {
+		classes[item.MaterialID] = item.ABCClass
+	}
+	return classes, nil
+}
+
+type CreateCycleCountRequest struct {
+	WarehouseID string   `json:"warehouse_id" binding:"required"`
+	MaterialIDs []string `json:"material_ids"`
+	LocationIDs []string `json:"location_ids"`
+	Notes       string   `json:"notes"`
+}
+
+// Create 手工创建盘点任务：按物料和/或库位圈定范围
+func (s *CycleCountService) Create(req CreateCycleCountRequest, userID string) (*entity.CycleCount, error) {
+	if len(req.MaterialIDs) == 0 && len(req.LocationIDs) == 0 {
+		return nil, fmt.Errorf("请指定盘点物料或库位")
+	}
+	classes, err := s.abcClasses()
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.buildCountLines(req.WarehouseID, req.MaterialIDs, req.LocationIDs, classes)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("盘点范围内没有库存")
+	}
+	return s.createCount(req.WarehouseID, "MANUAL", req.Notes, lines, userID)
+}
+
+func (s *CycleCountService) createCount(warehouseID, trigger, notes string, lines []entity.CycleCountLine, userID string) (*entity.CycleCount, error) {
+	now := time.Now()
+	count := &entity.CycleCount{
+		ID:          uuid.New().String(),
+		Code:        newCycleCountCode(now),
+		WarehouseID: warehouseID,
+		CountDate:   dateOnly(now),
+		Trigger:     trigger,
+		Status:      entity.CycleCountStatusOpen,
+		TotalLines:  len(lines),
+		Notes:       notes,
+		CreatedBy:   userID,
+		Lines:       lines,
+	}
+	for i := range count.Lines {
+		count.Lines[i].CountID = count.ID
+	}
+	if err := s.repo.CreateCount(count); err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+// GenerateDue 按 ABC 排期为到期物料生成盘点任务，每个仓库一张；已在未结束盘点中的物料跳过
+func (s *CycleCountService) GenerateDue(date time.Time, trigger, userID string) ([]entity.CycleCount, error) {
+	due, err := s.repo.DueMaterials(dateOnly(date))
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]entity.CycleCount, 0)
+	if len(due) == 0 {
+		return counts, nil
+	}
+	classes := make(map[string]string, len(due))
+	ids := make([]string, 0, len(due))
+	for _, d := range due {
+		classes[d.MaterialID] = d.ABCClass
+		ids = append(ids, d.MaterialID)
+	}
+	stocked, err := s.repo.StockedMaterials(ids)
+	if err != nil {
+		return nil, err
+	}
+	byWarehouse := make(map[string][]string)
+	var warehouses []string
+	for _, inv := range stocked {
+		if _, ok := byWarehouse[inv.WarehouseID]; !ok {
+			warehouses = append(warehouses, inv.WarehouseID)
+		}
+		byWarehouse[inv.WarehouseID] = append(byWarehouse[inv.WarehouseID], inv.MaterialID)
+	}
+
+	for _, wh := range warehouses {
+		open, err := s.repo.OpenCountMaterials(wh)
+		if err != nil {
+			return nil, err
+		}
+		var materials []string
+		for _, id := range byWarehouse[wh] {
+			if !open[id] {
+				materials = append(materials, id)
+			}
+		}
+		if len(materials) == 0 {
+			continue
+		}
+		lines, err := s.buildCountLines(wh, materials, nil, classes)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		count, err := s.createCount(wh, trigger, "", lines, userID)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, *count)
+	}
+	return counts, nil
+}
+
+func (s *CycleCountService) Get(id string) (*entity.CycleCount, error) {
+	return s.repo.GetCount(id)
+}
+
+func (s *CycleCountService) List(params repository.CycleCountListParams) ([]entity.CycleCount, int64, error) {
+	return s.repo.ListCounts(params)
+}
+
+// Start 开始盘点：冻结涉及的库位并按当前余额刷新快照。
+// 有库位的整库位冻结，未上架库存只冻结该物料
+func (s *CycleCountService) Start(id, userID string) (*entity.CycleCount, error) {
+	count, err := s.repo.GetCount(id)
+	if err != nil {
+		return nil, err
+	}
+	if count.Status != entity.CycleCountStatusOpen {
+		return nil, fmt.Errorf("只有待盘点的任务可以开始")
+	}
+
+	var freezes []entity.BinFreeze
+	seen := make(map[string]bool)
+	materialIDs := make([]string, 0)
+	seenMaterial := make(map[string]bool)
+	for _, l := range count.Lines {
+		key := l.LocationID
+		if key == "" {
+			key = "material:" + l.MaterialID
+		}
+		if !seen[key] {
+			seen[key] = true
+			f := entity.BinFreeze{ID: uuid.New().String(), CountID: count.ID, WarehouseID: count.WarehouseID, LocationID: l.LocationID}
+			if l.LocationID == "" {
+				f.MaterialID = l.MaterialID
+			}
+			freezes = append(freezes, f)
+		}
+		if !seenMaterial[l.MaterialID] {
+			seenMaterial[l.MaterialID] = true
+			materialIDs = append(materialIDs, l.MaterialID)
+		}
+	}
+	// 冻结库位、快照账面数与任务状态一并提交，避免库位已冻结而任务仍为待盘点
+	err = s.invRepo.DB().Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewCycleCountRepository(tx)
+		invRepo := repository.NewInventoryRepository(tx)
+		var locked entity.CycleCount
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", count.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		if locked.Status != entity.CycleCountStatusOpen {
+			return fmt.Errorf("只有待盘点的任务可以开始")
+		}
+		if err := invRepo.FreezeBins(freezes); err != nil {
+			return err
+		}
+
+		// 冻结后余额不再变化，以此刻余额作为账面数；冻结库位内新出现的批次补充盘点行
+		contents, err := invRepo.BinContents(count.WarehouseID, materialIDs, nil)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]float64, len(contents))
+		for _, c := range contents {
+			current[c.MaterialID+"|"+c.LocationID+"|"+c.BatchNo] = c.Quantity
+		}
+		lineKeys := make(map[string]bool, len(count.Lines))
+		for i := range count.Lines {
+			l := &count.Lines[i]
+			key := l.MaterialID + "|" + l.LocationID + "|" + l.BatchNo
+			lineKeys[key] = true
+			if l.SnapshotQty != current[key] {
+				l.SnapshotQty = current[key]
+				if err := repo.SaveLine(l); err != nil {
+					return err
+				}
+			}
+		}
+		var added []entity.CycleCountLine
+		for _, c := range contents {
+			key := c.MaterialID + "|" + c.LocationID + "|" + c.BatchNo
+			bin := c.LocationID
+			if bin == "" {
+				bin = "material:" + c.MaterialID
+			}
+			if lineKeys[key] || !seen[bin] {
+				continue
+			}
+			ref := count.Lines[0]
+			for _, l := range count.Lines {
+				if l.MaterialID == c.MaterialID {
+					ref = l
+					break
+				}
+			}
+			added = append(added, entity.CycleCountLine{
+				ID:           uuid.New().String(),
+				CountID:      count.ID,
+				MaterialID:   c.MaterialID,
+				MaterialCode: c.MaterialCode,
+				MaterialName: c.MaterialName,
+				ABCClass:     ref.ABCClass,
+				LocationID:   c.LocationID,
+				BatchNo:      c.BatchNo,
+				SnapshotQty:  c.Quantity,
+				UnitCost:     ref.UnitCost,
+				Status:       entity.CountLinePending,
+			})
+		}
+		if err := repo.CreateLines(added); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		count.Status = entity.CycleCountStatusCounting
+		count.StartedAt = &now
+		count.TotalLines += len(added)
+		return repo.SaveCount(count)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetCount(id)
+}
+
+type CountResult struct {
+	LineID     string  `json:"line_id" binding:"required"`
+	CountedQty float64 `json:"counted_qty" binding:"gte=0"`
+	Notes      string  `json:"notes"`
+}
+
+type SubmitCountRequest struct {
+	Results []CountResult `json:"results" binding:"required,min=1,dive"`
+}
+
+// SubmitResults 录入实盘数量并按类别容差判定差异；驳回的行可重新录入（复盘）
+func (s *CycleCountService) SubmitResults(id string, req SubmitCountRequest, userID string) (*entity.CycleCount, error) {
+	count, err := s.repo.GetCount(id)
+	if err != nil {
+		return nil, err
+	}
+	if count.Status != entity.CycleCountStatusCounting && count.Status != entity.CycleCountStatusReview {
+		return nil, fmt.Errorf("盘点任务未在盘点中")
+	}
+	policies, err := s.policyMap()
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[string]*entity.CycleCountLine, len(count.Lines))
+	for i := range count.Lines {
+		lines[count.Lines[i].ID] = &count.Lines[i]
+	}
+
+	now := time.Now()
+	for _, r := range req.Results {
+		l, ok := lines[r.LineID]
+		if !ok {
+			return nil, fmt.Errorf("盘点行 %s 不属于该任务", r.LineID)
+		}
+		if l.Status == entity.CountLineApproved {
+			return nil, fmt.Errorf("物料 %s 的差异已批准，不能重新录入", l.MaterialCode)
+		}
+		qty := r.CountedQty
+		l.CountedQty = &qty
+		l.VarianceQty = qty - l.SnapshotQty
+		l.VarianceValue = math.Round(l.VarianceQty*l.UnitCost*100) / 100
+		switch {
+		case l.SnapshotQty != 0:
+			l.VariancePct = l.VarianceQty / l.SnapshotQty * 100
+		case l.VarianceQty != 0:
+			l.VariancePct = 100
+		default:
+			l.VariancePct = 0
+		}
+		l.CountedBy, l.CountedAt = userID, &now
+		l.ApprovedBy, l.ApprovedAt = "", nil
+		if r.Notes != "" {
+			l.Notes = r.Notes
+		}
+		switch {
+		case math.Abs(l.VarianceQty) < 1e-9:
+			l.Status = entity.CountLineMatched
+		case withinTolerance(policies[l.ABCClass], l.VariancePct, l.VarianceValue):
+			l.Status = entity.CountLineWithinTolerance
+		default:
+			l.Status = entity.CountLineNeedsApproval
+		}
+		if err := s.repo.SaveLine(l); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.refreshCountStatus(count); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCount(id)
+}
+
+// refreshCountStatus 汇总行状态：全部录入且存在待审批差异时进入审批
+func (s *CycleCountService) refreshCountStatus(count *entity.CycleCount) error {
+	count.CountedLines, count.VarianceLines = 0, 0
+	pendingApproval := false
+	for _, l := range count.Lines {
+		if l.Status == entity.CountLinePending {
+			continue
+		}
+		count.CountedLines++
+		if l.Status != entity.CountLineMatched {
+			count.VarianceLines++
+		}
+		if l.Status == entity.CountLineNeedsApproval {
+			pendingApproval = true
+		}
+	}
+	count.Status = entity.CycleCountStatusCounting
+	if count.CountedLines == len(count.Lines) && pendingApproval {
+		count.Status = entity.CycleCountStatusReview
+	}
+	return s.repo.SaveCount(count)
+}
+
+type ApproveCountRequest struct {
+	LineIDs []string `json:"line_ids" binding:"required,min=1"`
+	Approve bool     `json:"approve"`
+	Notes   string   `json:"notes"`
+}
+
+// Approve 审批超容差差异：批准则过账时调整，驳回则需复盘。审批人不能是盘点人
+func (s *CycleCountService) Approve(id string, req ApproveCountRequest, userID string) (*entity.CycleCount, error) {
+	count, err := s.repo.GetCount(id)
+	if err != nil {
+		return nil, err
+	}
+	if count.Status != entity.CycleCountStatusCounting && count.Status != entity.CycleCountStatusReview {
+		return nil, fmt.Errorf("盘点任务当前状态不能审批")
+	}
+	lines := make(map[string]*entity.CycleCountLine, len(count.Lines))
+	for i := range count.Lines {
+		lines[count.Lines[i].ID] = &count.Lines[i]
+	}
+	now := time.Now()
+	for _, lineID := range req.LineIDs {
+		l, ok := lines[lineID]
+		if !ok {
+			return nil, fmt.Errorf("盘点行 %s 不属于该任务", lineID)
+		}
+		if l.Status != entity.CountLineNeedsApproval {
+			return nil, fmt.Errorf("物料 %s 的盘点行无需审批", l.MaterialCode)
+		}
+		if l.CountedBy == userID {
+			return nil, fmt.Errorf("差异审批人不能是盘点人")
+		}
+		l.Status = entity.CountLineRejected
+		if req.Approve {
+			l.Status = entity.CountLineApproved
+		}
+		l.ApprovedBy, l.ApprovedAt = userID, &now
+		if req.Notes != "" {
+			l.Notes = req.Notes
+		}
+		if err := s.repo.SaveLine(l); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.refreshCountStatus(count); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCount(id)
+}
+
+// Post 过账盘点差异：容差内和已批准的差异生成库存调整流水，驳回的物料次日复盘；
+// 随后解冻库位并按类别频率排定下次盘点
+func (s *CycleCountService) Post(id, userID string) (*entity.CycleCount, error) {
+	err := s.repo.DB().Transaction(func(tx *gorm.DB) error {
+		return s.withTx(tx).post(id, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetCount(id)
+}
+
+// post 在事务内锁定盘点任务后过账差异、解冻库位、排定下次盘点
+func (s *CycleCountService) post(id, userID string) error {
+	count, err := s.repo.LockCount(id)
+	if err != nil {
+		return err
+	}
+	if count.Status != entity.CycleCountStatusCounting && count.Status != entity.CycleCountStatusReview {
+		return fmt.Errorf("盘点任务当前状态不能过账")
+	}
+	var moves []repository.StockMovement
+	var adjusted []*entity.CycleCountLine
+	for i := range count.Lines {
+		l := &count.Lines[i]
+		switch l.Status {
+		case entity.CountLinePending:
+			return fmt.Errorf("物料 %s 尚未录入实盘数量", l.MaterialCode)
+		case entity.CountLineNeedsApproval:
+			return fmt.Errorf("物料 %s 的差异待审批", l.MaterialCode)
+		case entity.CountLineWithinTolerance, entity.CountLineApproved:
+			if math.Abs(l.VarianceQty) < 1e-9 {
+				continue
+			}
+			moves = append(moves, repository.StockMovement{
+				Tx: &entity.InventoryTransaction{
+					ID:              uuid.New().String(),
+					MaterialID:      l.MaterialID,
+					MaterialCode:    l.MaterialCode,
+					MaterialName:    l.MaterialName,
+					WarehouseID:     count.WarehouseID,
+					LocationID:      l.LocationID,
+					BatchNo:         l.BatchNo,
+					TransactionType: entity.TxTypeAdjust,
+					Quantity:        l.VarianceQty,
+					UnitCost:        l.UnitCost,
+					ReferenceType:   "CYCLE_COUNT",
+					ReferenceID:     count.ID,
+					ReferenceCode:   count.Code,
+					Notes:           l.Notes,
+					CreatedBy:       userID,
+				},
+				IgnoreFreeze: true,
+			})
+			adjusted = append(adjusted, l)
+		}
+	}
+	if len(moves) > 0 {
+		if _, err := s.invRepo.PostMovements(moves); err != nil {
+			return fmt.Errorf("盘点差异过账失败: %w", err)
+		}
+		for i, l := range adjusted {
+			l.AdjustmentTxID = moves[i].Tx.ID
+			if err := s.repo.SaveLine(l); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.invRepo.ReleaseBins(count.ID); err != nil {
+		return err
+	}
+
+	policies, err := s.policyMap()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	recount := make(map[string]bool)
+	classes := make(map[string]string)
+	for _, l := range count.Lines {
+		classes[l.MaterialID] = l.ABCClass
+		if l.Status == entity.CountLineRejected {
+			recount[l.MaterialID] = true
+		}
+	}
+	for materialID, class := range classes {
+		next := dateOnly(now).AddDate(0, 0, policies[class].FrequencyDays)
+		if recount[materialID] {
+			next = dateOnly(now).AddDate(0, 0, 1)
+		}
+		if err := s.repo.MarkCounted(materialID, now, next); err != nil {
+			return err
+		}
+	}
+
+	count.Status = entity.CycleCountStatusPosted
+	count.PostedAt = &now
+	count.PostedBy = userID
+	return s.repo.SaveCount(count)
+}
+
+// Cancel 取消盘点并解冻库位
+func (s *CycleCountService) Cancel(id string) error {
+	count, err := s.repo.GetCount(id)
+	if err != nil {
+		return err
+	}
+	if count.Status == entity.CycleCountStatusPosted || count.Status == entity.CycleCountStatusCancelled {
+		return fmt.Errorf("盘点任务已结束")
+	}
+	if err := s.invRepo.ReleaseBins(count.ID); err != nil {
+		return err
+	}
+	count.Status = entity.CycleCountStatusCancelled
+	return s.repo.SaveCount(count)
+}
+
+// Accuracy 区间内已过账盘点的分仓库库存准确率
+func (s *CycleCountService) Accuracy(from, to time.Time) ([]repository.WarehouseAccuracy, error) {
+	items, err := s.repo.Accuracy(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		a := &items[i]
+		if a.Lines > 0 {
+			a.AccuracyPct = math.Round(float64(a.AccurateLines)*10000/float64(a.Lines)) / 100
+		}
+		if a.SnapshotValue > 0 {
+			a.ValueAccuracyPct = math.Round(math.Max(1-a.AbsVarianceValue/a.SnapshotValue, 0)*10000) / 100
+		}
+	}
+	return items, nil
+}
+
+// StartScheduler 每日按 ABC 排期生成盘点任务
+func (s *CycleCountService) StartScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				counts, err := s.GenerateDue(time.Now(), "SCHEDULED", "system")
+				if err != nil {
+					log.Printf("[ERP] 生成循环盘点任务失败: %v", err)
+					continue
+				}
+				if len(counts) > 0 {
+					log.Printf("[ERP] 已生成 %d 张循环盘点任务", len(counts))
+				}
+			}
+		}
+	}()
+}
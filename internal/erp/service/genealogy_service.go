@@ -0,0 +1,300 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+)
+
+// maxTraceDepth 多级BOM追溯的最大层数，防止异常数据造成循环
+const maxTraceDepth = 10
+
+type GenealogyService struct {
+	repo *repository.GenealogyRepository
+}
+
+func NewGenealogyService(repo *repository.GenealogyRepository) *GenealogyService {
+	return &GenealogyService{repo: repo}
+}
+
+// BackwardNode 反向追溯节点：自制批次展开到组件批次，外购批次给出供应商与来料检验
+type BackwardNode struct {
+	MaterialID   string                `json:"material_id"`
+	MaterialCode string                `json:"material_code"`
+	MaterialName string                `json:"material_name"`
+	LotNo        string                `json:"lot_no"`
+	SerialNo     string                `json:"serial_no,omitempty"`
+	Quantity     float64               `json:"quantity"`
+	WorkOrderIDs []string              `json:"work_order_ids,omitempty"` // 生产该批次的工单
+	WOCodes      []string              `json:"wo_codes,omitempty"`
+	Origin       *repository.LotOrigin `json:"origin,omitempty"`
+	Components   []*BackwardNode       `json:"components,omitempty"`
+}
+
+type BackwardTraceRequest struct {
+	SerialNo   string `form:"serial_no"`
+	MaterialID string `form:"material_id"`
+	LotNo      string `form:"lot_no"`
+}
+
+// TraceBackward 反向追溯：成品序列号/批次 → 组件批次 → 供应商与来料检验
+func (s *GenealogyService) TraceBackward(req BackwardTraceRequest) (*BackwardNode, error) {
+	if req.SerialNo == "" && req.LotNo == "" {
+		return nil, fmt.Errorf("请指定序列号或批次号")
+	}
+	produced, err := s.repo.FindProduced(req.MaterialID, req.LotNo, req.SerialNo)
+	if err != nil {
+		return nil, err
+	}
+	root := &BackwardNode{MaterialID: req.MaterialID, LotNo: req.LotNo, SerialNo: req.SerialNo}
+	if len(produced) == 0 {
+		if req.SerialNo != "" {
+			return nil, fmt.Errorf("序列号 %s 没有生产记录", req.SerialNo)
+		}
+		if req.MaterialID == "" {
+			return nil, fmt.Errorf("批次 %s 没有生产记录，外购批次请同时指定物料", req.LotNo)
+		}
+		origin, err := s.repo.GetLotOrigin(req.MaterialID, req.LotNo)
+		if err != nil {
+			return nil, err
+		}
+		if origin == nil {
+			return nil, fmt.Errorf("批次 %s 没有入库记录", req.LotNo)
+		}
+		root.Origin = origin
+		return root, nil
+	}
+
+	p := produced[0]
+	root.MaterialID, root.MaterialCode, root.MaterialName, root.LotNo = p.MaterialID, p.MaterialCode, p.MaterialName, p.LotNo
+	for _, l := range produced {
+		root.Quantity += l.Quantity
+	}
+	visited := map[string]bool{root.MaterialID + ":" + root.LotNo: true}
+	if err := s.expandBackward(root, produced, visited, 0); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (s *GenealogyService) expandBackward(node *BackwardNode, produced []entity.LotGenealogy, visited map[string]bool, depth int) error {
+	seenWO := make(map[string]bool)
+	children := make(map[string]*BackwardNode)
+	var order []string
+	for _, p := range produced {
+		if seenWO[p.WorkOrderID] {
+			continue
+		}
+		seenWO[p.WorkOrderID] = true
+		node.WorkOrderIDs = append(node.WorkOrderIDs, p.WorkOrderID)
+		node.WOCodes = append(node.WOCodes, p.WOCode)
+
+		consumed, err := s.repo.FindByWorkOrder(p.WorkOrderID, entity.GenealogyConsume)
+		if err != nil {
+			return err
+		}
+		for _, c := range consumed {
+			key := c.MaterialID + ":" + c.LotNo
+			child, ok := children[key]
+			if !ok {
+				child = &BackwardNode{MaterialID: c.MaterialID, MaterialCode: c.MaterialCode, MaterialName: c.MaterialName, LotNo: c.LotNo}
+				children[key] = child
+				order = append(order, key)
+			}
+			child.Quantity += c.Quantity
+		}
+	}
+
+	for _, key := range order {
+		child := children[key]
+		node.Components = append(node.Components, child)
+		// 无批次的消耗（历史库存未分批）无法继续追溯
+		if child.LotNo == "" || visited[key] || depth >= maxTraceDepth {
+			continue
+		}
+		visited[key] = true
+
+		sub, err := s.repo.FindProduced(child.MaterialID, child.LotNo, "")
+		if err != nil {
+			return err
+		}
+		if len(sub) > 0 {
+			if err := s.expandBackward(child, sub, visited, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if child.Origin, err = s.repo.GetLotOrigin(child.MaterialID, child.LotNo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForwardNode 正向追溯节点：批次被哪些工单消耗、产出了哪些成品批次/序列号、发给了谁
+type ForwardNode struct {
+	MaterialID   string                 `json:"material_id"`
+	MaterialCode string                 `json:"material_code"`
+	MaterialName string                 `json:"material_name"`
+	LotNo        string                 `json:"lot_no"`
+	SerialNo     string                 `json:"serial_no,omitempty"`
+	Quantity     float64                `json:"quantity"`
+	SupplierID   string                 `json:"supplier_id,omitempty"`
+	WorkOrderID  string                 `json:"work_order_id,omitempty"` // 产出该节点的工单
+	WOCode       string                 `json:"wo_code,omitempty"`
+	Shipments    []entity.ShipmentTrace `json:"shipments,omitempty"`
+	Products     []*ForwardNode         `json:"products,omitempty"`
+}
+
+// TraceCustomer 受影响客户汇总
+type TraceCustomer struct {
+	CustomerID   string   `json:"customer_id"`
+	CustomerName string   `json:"customer_name"`
+	SOCodes      []string `json:"so_codes"`
+	SerialNos    []string `json:"serial_nos"`
+	Quantity     float64  `json:"quantity"`
+}
+
+type ForwardTraceResult struct {
+	Roots          []*ForwardNode         `json:"roots"`
+	ShippedSerials []string               `json:"shipped_serials"`
+	Customers      []TraceCustomer        `json:"customers"`
+	Shipments      []entity.ShipmentTrace `json:"shipments"`
+}
+
+type ForwardTraceRequest struct {
+	MaterialID string `form:"material_id"`
+	LotNo      string `form:"lot_no"`
+	SupplierID string `form:"supplier_id"`
+}
+
+// TraceForward 正向追溯：可疑供应商批次 → 消耗工单 → 成品批次/序列号 → 发货客户
+func (s *GenealogyService) TraceForward(req ForwardTraceRequest) (*ForwardTraceResult, error) {
+	if req.LotNo == "" && req.SupplierID == "" {
+		return nil, fmt.Errorf("请指定批次号或供应商")
+	}
+	consumers, err := s.repo.FindConsumers(req.MaterialID, req.LotNo, req.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ForwardTraceResult{Roots: make([]*ForwardNode, 0)}
+	roots := make(map[string]*ForwardNode)
+	rootWOs := make(map[string][]string)
+	for _, c := range consumers {
+		key := c.MaterialID + ":" + c.LotNo
+		root, ok := roots[key]
+		if !ok {
+			root = &ForwardNode{MaterialID: c.MaterialID, MaterialCode: c.MaterialCode, MaterialName: c.MaterialName, LotNo: c.LotNo, SupplierID: c.SupplierID}
+			roots[key] = root
+			result.Roots = append(result.Roots, root)
+		}
+		root.Quantity += c.Quantity
+		rootWOs[key] = append(rootWOs[key], c.WorkOrderID)
+	}
+	if req.LotNo != "" && req.MaterialID != "" && len(result.Roots) == 0 {
+		result.Roots = append(result.Roots, &ForwardNode{MaterialID: req.MaterialID, LotNo: req.LotNo})
+	}
+
+	visited := make(map[string]bool)
+	for _, root := range result.Roots {
+		key := root.MaterialID + ":" + root.LotNo
+		visited[key] = true
+		// 批次本身也可能直接发货（如备件）
+		if root.Shipments, err = s.repo.FindShipments(root.MaterialID, root.LotNo, ""); err != nil {
+			return nil, err
+		}
+		if err := s.expandForward(root, rootWOs[key], visited, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	collectShipments(result, result.Roots)
+	return result, nil
+}
+
+func (s *GenealogyService) expandForward(node *ForwardNode, woIDs []string, visited map[string]bool, depth int) error {
+	seenWO := make(map[string]bool)
+	for _, woID := range woIDs {
+		if seenWO[woID] {
+			continue
+		}
+		seenWO[woID] = true
+		produced, err := s.repo.FindByWorkOrder(woID, entity.GenealogyProduce)
+		if err != nil {
+			return err
+		}
+		for _, p := range produced {
+			child := &ForwardNode{
+				MaterialID: p.MaterialID, MaterialCode: p.MaterialCode, MaterialName: p.MaterialName,
+				LotNo: p.LotNo, SerialNo: p.SerialNo, Quantity: p.Quantity,
+				WorkOrderID: p.WorkOrderID, WOCode: p.WOCode,
+			}
+			if child.Shipments, err = s.repo.FindShipments(p.MaterialID, p.LotNo, p.SerialNo); err != nil {
+				return err
+			}
+			node.Products = append(node.Products, child)
+
+			// 产出批次再被上层工单消耗（多级BOM），同一批次只展开一次
+			key := p.MaterialID + ":" + p.LotNo
+			if p.LotNo == "" || visited[key] || depth >= maxTraceDepth {
+				continue
+			}
+			visited[key] = true
+			next, err := s.repo.FindConsumers(p.MaterialID, p.LotNo, "")
+			if err != nil {
+				return err
+			}
+			nextWOs := make([]string, 0, len(next))
+			for _, n := range next {
+				nextWOs = append(nextWOs, n.WorkOrderID)
+			}
+			if err := s.expandForward(child, nextWOs, visited, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// collectShipments 汇总所有节点的发货记录、序列号与客户
+func collectShipments(result *ForwardTraceResult, nodes []*ForwardNode) {
+	seen := make(map[string]bool)
+	customers := make(map[string]*TraceCustomer)
+	var walk func([]*ForwardNode)
+	walk = func(nodes []*ForwardNode) {
+		for _, n := range nodes {
+			for _, sh := range n.Shipments {
+				if seen[sh.ID] {
+					continue
+				}
+				seen[sh.ID] = true
+				result.Shipments = append(result.Shipments, sh)
+				c, ok := customers[sh.CustomerID]
+				if !ok {
+					c = &TraceCustomer{CustomerID: sh.CustomerID, CustomerName: sh.CustomerName}
+					customers[sh.CustomerID] = c
+				}
+				c.Quantity += sh.Quantity
+				if len(c.SOCodes) == 0 || c.SOCodes[len(c.SOCodes)-1] != sh.SOCode {
+					c.SOCodes = append(c.SOCodes, sh.SOCode)
+				}
+				if sh.SerialNo != "" {
+					c.SerialNos = append(c.SerialNos, sh.SerialNo)
+					result.ShippedSerials = append(result.ShippedSerials, sh.SerialNo)
+				}
+			}
+			walk(n.Products)
+		}
+	}
+	walk(nodes)
+
+	result.Customers = make([]TraceCustomer, 0, len(customers))
+	for _, c := range customers {
+		result.Customers = append(result.Customers, *c)
+	}
+	sort.Slice(result.Customers, func(i, j int) bool { return result.Customers[i].CustomerName < result.Customers[j].CustomerName })
+	sort.Strings(result.ShippedSerials)
+}
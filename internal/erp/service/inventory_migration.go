@@ -0,0 +1,340 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultReceivingWarehouseCode 来源未指定仓库时使用的收货仓
+const DefaultReceivingWarehouseCode = "SRM-RCV"
+
+var (
+	ErrMaterialNotFound  = errors.New("物料主数据中找不到该物料")
+	ErrMaterialAmbiguous = errors.New("匹配到多个物料，请填写物料编码")
+	ErrWarehouseNotFound = errors.New("仓库不存在")
+)
+
+// GetByID 获取结存行
+func (s *InventoryService) GetByID(id string) (*entity.Inventory, error) {
+	return s.repo.GetByID(id)
+}
+
+// ListTransactionsByInventory 结存行的流水
+func (s *InventoryService) ListTransactionsByInventory(inventoryID string, page, size int) ([]entity.InventoryTransaction, int64, error) {
+	return s.repo.ListTransactionsByInventory(inventoryID, page, size)
+}
+
+// Post 过账库存变动，供其他模块（SRM 收货等）直接写入统一台账
+func (s *InventoryService) Post(moves []repository.StockMovement) ([]entity.Inventory, error) {
+	return s.repo.PostMovements(moves)
+}
+
+// ResolveMaterial 按编码（优先）或名称识别物料主数据；名称匹配到多个物料时，
+// 用制造商料号（非空时）在其中进一步筛选
+func (s *InventoryService) ResolveMaterial(code, name, mpn string) (*repository.MaterialRef, error) {
+	if code != "" {
+		items, err := s.repo.FindMaterialsByCode(code)
+		if err != nil {
+			return nil, err
+		}
+		return uniqueMaterial(items, "物料编码 "+code)
+	}
+	items, err := s.repo.FindMaterialsByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > 1 && mpn != "" {
+		ids := make([]string, len(items))
+		for i, m := range items {
+			ids[i] = m.ID
+		}
+		narrowed, err := s.repo.FilterMaterialsByMPN(ids, mpn)
+		if err != nil {
+			return nil, err
+		}
+		if len(narrowed) > 0 {
+			return uniqueMaterial(narrowed, fmt.Sprintf("物料名称 %s、制造商料号 %s", name, mpn))
+		}
+	}
+	return uniqueMaterial(items, "物料名称 "+name)
+}
+
+// uniqueMaterial 候选物料恰为一个时返回，否则按实际使用的识别键报错
+func uniqueMaterial(items []repository.MaterialRef, key string) (*repository.MaterialRef, error) {
+	switch len(items) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrMaterialNotFound, key)
+	case 1:
+		return &items[0], nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrMaterialAmbiguous, key)
+	}
+}
+
+// ResolveWarehouse 按ID/编码/名称识别仓库，未指定时使用默认收货仓
+func (s *InventoryService) ResolveWarehouse(key string) (*entity.Warehouse, error) {
+	if strings.TrimSpace(key) == "" {
+		return s.repo.EnsureWarehouse(DefaultReceivingWarehouseCode, "SRM收货仓")
+	}
+	wh, err := s.repo.FindWarehouse(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrWarehouseNotFound, key)
+	}
+	return wh, err
+}
+
+// lookupWarehouse 与 ResolveWarehouse 相同但不创建默认收货仓，供试运行使用
+func (s *InventoryService) lookupWarehouse(key string) (*entity.Warehouse, error) {
+	if strings.TrimSpace(key) != "" {
+		return s.ResolveWarehouse(key)
+	}
+	wh, err := s.repo.FindWarehouse(DefaultReceivingWarehouseCode)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 收货仓尚未创建，正式迁移时才会建立
+		return &entity.Warehouse{Code: DefaultReceivingWarehouseCode}, nil
+	}
+	return wh, err
+}
+
+// LegacyStockRecord 待并入统一台账的历史库存记录
+type LegacyStockRecord struct {
+	SourceID     string
+	MaterialCode string
+	MaterialName string
+	MPN          string
+	SupplierID   string
+	Warehouse    string
+	Quantity     float64
+	Unit         string
+}
+
+// StockMigrationReport 历史库存合并报告
+type StockMigrationReport struct {
+	DryRun    bool                        `json:"dry_run"`
+	Total     int                         `json:"total"`
+	Merged    int                         `json:"merged"`
+	Skipped   int                         `json:"skipped"` // 之前已合并
+	Conflicts int                         `json:"conflicts"`
+	Items     []entity.InventoryMigration `json:"items"`
+}
+
+// MergeLegacyStock 将历史库存按物料+仓库合并到统一台账。
+// 同一物料同一仓库的多条来源记录（如不同供应商）合并为一条结存，每条来源记录各自一笔迁入流水；
+// 无法识别或会重复计数的记录写入冲突报告，不过账，处理后可再次执行。
+func (s *InventoryService) MergeLegacyStock(sourceType string, records []LegacyStockRecord, dryRun bool, userID string) (*StockMigrationReport, error) {
+	report := &StockMigrationReport{DryRun: dryRun, Total: len(records), Items: make([]entity.InventoryMigration, 0)}
+
+	type group struct {
+		key   string
+		items []*entity.InventoryMigration
+		recs  []LegacyStockRecord
+		mats  []*repository.MaterialRef
+	}
+	groups := make(map[string]*group)
+	var order []string
+	var items []*entity.InventoryMigration
+
+	for _, rec := range records {
+		if prev, err := s.repo.GetMigration(sourceType, rec.SourceID); err == nil && prev.Status == entity.MigrationStatusMerged {
+			report.Skipped++
+			continue
+		}
+		m := &entity.InventoryMigration{
+			ID:           uuid.New().String(),
+			SourceType:   sourceType,
+			SourceID:     rec.SourceID,
+			MaterialCode: rec.MaterialCode,
+			MaterialName: rec.MaterialName,
+			SupplierID:   rec.SupplierID,
+			Warehouse:    rec.Warehouse,
+			Quantity:     rec.Quantity,
+			Unit:         rec.Unit,
+			Status:       entity.MigrationStatusMerged,
+			MigratedBy:   userID,
+		}
+
+		mat, err := s.ResolveMaterial(rec.MaterialCode, rec.MaterialName, rec.MPN)
+		switch {
+		case errors.Is(err, ErrMaterialNotFound):
+			markMigrationConflict(m, entity.MigrationConflictMaterialNotFound, err.Error())
+		case errors.Is(err, ErrMaterialAmbiguous):
+			markMigrationConflict(m, entity.MigrationConflictMaterialAmbiguous, err.Error())
+		case err != nil:
+			return nil, err
+		}
+		if mat != nil {
+			m.MaterialID = mat.ID
+			if m.MaterialCode == "" {
+				m.MaterialCode = mat.Code
+			}
+			if rec.Unit != "" && mat.Unit != "" && !strings.EqualFold(rec.Unit, mat.Unit) {
+				markMigrationConflict(m, entity.MigrationConflictUnitMismatch,
+					fmt.Sprintf("来源单位 %s 与物料主数据单位 %s 不一致", rec.Unit, mat.Unit))
+			} else if rec.MaterialName != "" && rec.MaterialName != mat.Name {
+				m.Detail = fmt.Sprintf("名称按物料主数据统一为 %s（原名称 %s）", mat.Name, rec.MaterialName)
+			}
+		}
+
+		resolve := s.ResolveWarehouse
+		if dryRun {
+			resolve = s.lookupWarehouse
+		}
+		wh, err := resolve(rec.Warehouse)
+		switch {
+		case errors.Is(err, ErrWarehouseNotFound):
+			markMigrationConflict(m, entity.MigrationConflictWarehouseNotFound, err.Error())
+		case err != nil:
+			return nil, err
+		default:
+			m.WarehouseID = wh.ID
+		}
+
+		if rec.Quantity < 0 {
+			markMigrationConflict(m, entity.MigrationConflictNegativeQty, fmt.Sprintf("历史结存为负数 %.4f", rec.Quantity))
+		}
+
+		items = append(items, m)
+		if m.Status == entity.MigrationStatusConflict {
+			continue
+		}
+		key := m.MaterialID + ":" + m.WarehouseID
+		g, ok := groups[key]
+		if !ok {
+			g = &group{key: key}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.items = append(g.items, m)
+		g.recs = append(g.recs, rec)
+		g.mats = append(g.mats, mat)
+	}
+
+	sort.Strings(order)
+	for _, key := range order {
+		g := groups[key]
+		first := g.items[0]
+
+		// ERP 已有非迁移来源的结存，说明两边记录的可能是同一批实物，合并会重复计数
+		var existing float64
+		if first.WarehouseID != "" { // 试运行时收货仓可能尚未创建，自然没有结存
+			qty, err := s.repo.GetNonMigratedQty(first.MaterialID, first.WarehouseID)
+			if err != nil {
+				return nil, err
+			}
+			existing = qty
+		}
+		if math.Abs(existing) > 1e-9 {
+			for _, m := range g.items {
+				markMigrationConflict(m, entity.MigrationConflictBalanceExists,
+					fmt.Sprintf("ERP 已有该物料在该仓库的结存 %.4f，请盘点确认后调整", existing))
+			}
+			continue
+		}
+
+		if len(g.items) > 1 {
+			suppliers := make([]string, 0, len(g.items))
+			for _, m := range g.items {
+				suppliers = append(suppliers, firstNonEmpty(m.SupplierID, "-"))
+			}
+			note := fmt.Sprintf("与 %d 条记录合并为同一结存（供应商: %s）", len(g.items), strings.Join(suppliers, ", "))
+			for _, m := range g.items {
+				m.Detail = strings.TrimPrefix(m.Detail+"；"+note, "；")
+			}
+		}
+
+		if dryRun {
+			continue
+		}
+		moves := make([]repository.StockMovement, 0, len(g.items))
+		for i, m := range g.items {
+			if m.Quantity == 0 {
+				continue
+			}
+			rec, mat := g.recs[i], g.mats[i]
+			moves = append(moves, repository.StockMovement{
+				Tx: &entity.InventoryTransaction{
+					ID:              uuid.New().String(),
+					MaterialID:      m.MaterialID,
+					MaterialCode:    mat.Code,
+					MaterialName:    mat.Name,
+					WarehouseID:     m.WarehouseID,
+					TransactionType: entity.TxTypeMigrationIn,
+					Quantity:        m.Quantity,
+					ReferenceType:   entity.InventoryRefMigration,
+					ReferenceID:     m.SourceID,
+					ReferenceCode:   sourceType,
+					SupplierID:      m.SupplierID,
+					Notes:           "历史库存迁入",
+					CreatedBy:       userID,
+				},
+				CreateIfMissing: true,
+				Unit:            firstNonEmpty(mat.Unit, rec.Unit),
+				MPN:             rec.MPN,
+			})
+		}
+		if len(moves) > 0 {
+			balances, err := s.repo.PostMovements(moves)
+			if err != nil {
+				return nil, fmt.Errorf("合并物料 %s 失败: %w", first.MaterialCode, err)
+			}
+			for _, m := range g.items {
+				m.InventoryID = balances[0].ID
+			}
+		}
+		// 过账后立即记录，避免重复执行时再次迁入
+		for _, m := range g.items {
+			if err := s.repo.SaveMigration(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, m := range items {
+		report.Items = append(report.Items, *m)
+		if m.Status == entity.MigrationStatusConflict {
+			report.Conflicts++
+		} else {
+			report.Merged++
+		}
+		if dryRun || m.Status != entity.MigrationStatusConflict {
+			continue
+		}
+		if err := s.repo.SaveMigration(m); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// ListMigrations 迁移记录，status=CONFLICT 即冲突报告
+func (s *InventoryService) ListMigrations(sourceType, status string) ([]entity.InventoryMigration, error) {
+	return s.repo.ListMigrations(sourceType, status)
+}
+
+// markMigrationConflict 标记冲突，保留最先发现的冲突类型
+func markMigrationConflict(m *entity.InventoryMigration, conflictType, detail string) {
+	if m.Status != entity.MigrationStatusConflict {
+		m.Status = entity.MigrationStatusConflict
+		m.ConflictType = conflictType
+		m.Detail = detail
+		return
+	}
+	m.Detail += "；" + detail
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -1,7 +1,11 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/erp/entity"
@@ -10,13 +14,19 @@ import (
 )
 
 type InventoryService struct {
-	repo *repository.InventoryRepository
+	repo    *repository.InventoryRepository
+	putaway *WarehouseService // 可选：入库未指定库位时按上架规则分配
 }
 
 func NewInventoryService(repo *repository.InventoryRepository) *InventoryService {
 	return &InventoryService{repo: repo}
 }
 
+// SetPutaway 设置上架分配（可选依赖）
+func (s *InventoryService) SetPutaway(putaway *WarehouseService) {
+	s.putaway = putaway
+}
+
 func (s *InventoryService) List(params repository.InventoryListParams) ([]entity.Inventory, int64, error) {
 	return s.repo.List(params)
 }
@@ -33,6 +43,21 @@ func (s *InventoryService) ListTransactions(materialID string, page, size int) (
 	return s.repo.ListTransactions(materialID, page, size)
 }
 
+// GenerateBatchNo 入库未指定批次时生成的批次号（日期+序号）
+func GenerateBatchNo() string {
+	now := time.Now()
+	return fmt.Sprintf("%s%03d", now.Format("20060102"), now.UnixNano()%1000)
+}
+
+// parseExpiryDate 解析 YYYY-MM-DD 格式的有效期，空或格式错误返回 nil
+func parseExpiryDate(s string) *time.Time {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
 type InboundRequest struct {
 	MaterialID    string  `json:"material_id" binding:"required"`
 	MaterialCode  string  `json:"material_code"`
@@ -40,6 +65,8 @@ type InboundRequest struct {
 	WarehouseID   string  `json:"warehouse_id" binding:"required"`
 	Quantity      float64 `json:"quantity" binding:"required,gt=0"`
 	BatchNo       string  `json:"batch_no"`
+	LocationID    string  `json:"location_id"` // 空=按上架规则分配
+	ExpiryDate    string  `json:"expiry_date"` // 批次有效期 YYYY-MM-DD
 	UnitCost      float64 `json:"unit_cost"`
 	Unit          string  `json:"unit"`
 	InventoryType string  `json:"inventory_type"`
@@ -50,10 +77,9 @@ type InboundRequest struct {
 }
 
 func (s *InventoryService) Inbound(req InboundRequest, userID string) error {
-	now := time.Now()
 	batchNo := req.BatchNo
 	if batchNo == "" {
-		batchNo = fmt.Sprintf("%s%03d", now.Format("20060102"), now.UnixNano()%1000)
+		batchNo = GenerateBatchNo()
 	}
 	unit := req.Unit
 	if unit == "" {
@@ -64,36 +90,6 @@ func (s *InventoryService) Inbound(req InboundRequest, userID string) error {
 		invType = entity.InventoryTypeRaw
 	}
 
-	// 更新库存
-	existingInv, err := s.repo.GetByMaterialAndWarehouse(req.MaterialID, req.WarehouseID)
-	if err == nil && existingInv != nil {
-		existingInv.Quantity += req.Quantity
-		existingInv.AvailableQty += req.Quantity
-		existingInv.LastMovedAt = &now
-		if err := s.repo.Update(existingInv); err != nil {
-			return fmt.Errorf("更新库存失败: %w", err)
-		}
-	} else {
-		inv := &entity.Inventory{
-			ID:            uuid.New().String(),
-			MaterialID:    req.MaterialID,
-			MaterialCode:  req.MaterialCode,
-			MaterialName:  req.MaterialName,
-			WarehouseID:   req.WarehouseID,
-			BatchNo:       batchNo,
-			InventoryType: invType,
-			Quantity:      req.Quantity,
-			AvailableQty:  req.Quantity,
-			UnitCost:      req.UnitCost,
-			Unit:          unit,
-			LastMovedAt:   &now,
-		}
-		if err := s.repo.UpsertInventory(inv); err != nil {
-			return fmt.Errorf("创建库存失败: %w", err)
-		}
-	}
-
-	// 交易记录
 	txType := entity.TxTypePurchaseIn
 	switch req.ReferenceType {
 	case "WO":
@@ -102,23 +98,47 @@ func (s *InventoryService) Inbound(req InboundRequest, userID string) error {
 		txType = entity.TxTypeReturnIn
 	}
 
-	tx := &entity.InventoryTransaction{
-		ID:              uuid.New().String(),
-		MaterialID:      req.MaterialID,
-		MaterialCode:    req.MaterialCode,
-		MaterialName:    req.MaterialName,
-		WarehouseID:     req.WarehouseID,
-		TransactionType: txType,
-		Quantity:        req.Quantity,
-		BatchNo:         batchNo,
-		UnitCost:        req.UnitCost,
-		ReferenceType:   req.ReferenceType,
-		ReferenceID:     req.ReferenceID,
-		ReferenceCode:   req.ReferenceCode,
-		Notes:           req.Notes,
-		CreatedBy:       userID,
+	if req.LocationID != "" && s.putaway != nil {
+		if _, err := s.putaway.warehouseLocation(req.LocationID, req.WarehouseID); err != nil {
+			return err
+		}
 	}
-	return s.repo.CreateTransaction(tx)
+
+	moves := []repository.StockMovement{{
+		Tx: &entity.InventoryTransaction{
+			ID:              uuid.New().String(),
+			MaterialID:      req.MaterialID,
+			MaterialCode:    req.MaterialCode,
+			MaterialName:    req.MaterialName,
+			WarehouseID:     req.WarehouseID,
+			TransactionType: txType,
+			Quantity:        req.Quantity,
+			BatchNo:         batchNo,
+			LocationID:      req.LocationID,
+			ExpiryDate:      parseExpiryDate(req.ExpiryDate),
+			UnitCost:        req.UnitCost,
+			ReferenceType:   req.ReferenceType,
+			ReferenceID:     req.ReferenceID,
+			ReferenceCode:   req.ReferenceCode,
+			Notes:           req.Notes,
+			CreatedBy:       userID,
+		},
+		CreateIfMissing: true,
+		InventoryType:   invType,
+		Unit:            unit,
+	}}
+	if s.putaway != nil {
+		var err error
+		if moves, err = s.putaway.PlaceInbound(moves); err != nil {
+			return fmt.Errorf("上架分配失败: %w", err)
+		}
+	}
+
+	// 流水与结存同一事务过账
+	if _, err := s.repo.PostMovements(moves); err != nil {
+		return fmt.Errorf("入库失败: %w", err)
+	}
+	return nil
 }
 
 type OutboundRequest struct {
@@ -130,28 +150,13 @@ type OutboundRequest struct {
 	ReferenceType string  `json:"reference_type" binding:"required"` // WO, SO, SCRAP
 	ReferenceID   string  `json:"reference_id" binding:"required"`
 	ReferenceCode string  `json:"reference_code"`
+	ReservationID string  `json:"reservation_id"` // 消耗预留出库
+	LocationID    string  `json:"location_id"`    // 指定库位出库，空=按拣货策略
+	PickStrategy  string  `json:"pick_strategy"`  // FEFO（默认）, FIFO
 	Notes         string  `json:"notes"`
 }
 
 func (s *InventoryService) Outbound(req OutboundRequest, userID string) error {
-	now := time.Now()
-
-	// 查找库存
-	inv, err := s.repo.GetByMaterialAndWarehouse(req.MaterialID, req.WarehouseID)
-	if err != nil {
-		return fmt.Errorf("库存记录不存在: %w", err)
-	}
-	if inv.AvailableQty < req.Quantity {
-		return fmt.Errorf("可用库存不足: 需要%.4f, 可用%.4f", req.Quantity, inv.AvailableQty)
-	}
-
-	inv.Quantity -= req.Quantity
-	inv.AvailableQty -= req.Quantity
-	inv.LastMovedAt = &now
-	if err := s.repo.Update(inv); err != nil {
-		return fmt.Errorf("更新库存失败: %w", err)
-	}
-
 	txType := entity.TxTypeSalesOut
 	switch req.ReferenceType {
 	case "WO":
@@ -160,60 +165,184 @@ func (s *InventoryService) Outbound(req OutboundRequest, userID string) error {
 		txType = entity.TxTypeScrapOut
 	}
 
-	tx := &entity.InventoryTransaction{
-		ID:              uuid.New().String(),
-		MaterialID:      req.MaterialID,
-		MaterialCode:    req.MaterialCode,
-		MaterialName:    req.MaterialName,
-		WarehouseID:     req.WarehouseID,
-		TransactionType: txType,
-		Quantity:        -req.Quantity, // 负数表示出库
-		ReferenceType:   req.ReferenceType,
-		ReferenceID:     req.ReferenceID,
-		ReferenceCode:   req.ReferenceCode,
-		Notes:           req.Notes,
-		CreatedBy:       userID,
+	// 行锁校验可用库存（或预留剩余）后扣减
+	_, err := s.repo.PostMovements([]repository.StockMovement{{
+		Tx: &entity.InventoryTransaction{
+			ID:              uuid.New().String(),
+			MaterialID:      req.MaterialID,
+			MaterialCode:    req.MaterialCode,
+			MaterialName:    req.MaterialName,
+			WarehouseID:     req.WarehouseID,
+			TransactionType: txType,
+			Quantity:        -req.Quantity, // 负数表示出库
+			LocationID:      req.LocationID,
+			ReferenceType:   req.ReferenceType,
+			ReferenceID:     req.ReferenceID,
+			ReferenceCode:   req.ReferenceCode,
+			Notes:           req.Notes,
+			CreatedBy:       userID,
+		},
+		ReservationID: req.ReservationID,
+		AllocateLots:  true,
+		PickStrategy:  req.PickStrategy,
+		AllowExpired:  req.ReferenceType == "SCRAP",
+	}})
+	if err != nil {
+		return fmt.Errorf("出库失败: %w", err)
 	}
-	return s.repo.CreateTransaction(tx)
+	return nil
 }
 
 type AdjustRequest struct {
+	MaterialID  string  `json:"material_id" binding:"required"`
+	WarehouseID string  `json:"warehouse_id" binding:"required"`
+	AdjustQty   float64 `json:"adjust_qty" binding:"required"` // 正数增加，负数减少
+	Reason      string  `json:"reason" binding:"required"`
+	Version     *int    `json:"version"` // 页面读取时的结存版本，不一致则拒绝
+}
+
+func (s *InventoryService) Adjust(req AdjustRequest, userID string) error {
+	_, err := s.repo.PostMovements([]repository.StockMovement{{
+		Tx: &entity.InventoryTransaction{
+			ID:              uuid.New().String(),
+			MaterialID:      req.MaterialID,
+			WarehouseID:     req.WarehouseID,
+			TransactionType: entity.TxTypeAdjust,
+			Quantity:        req.AdjustQty,
+			ReferenceType:   "ADJUST",
+			ReferenceID:     uuid.New().String(),
+			Notes:           req.Reason,
+			CreatedBy:       userID,
+		},
+		ExpectedVersion: req.Version,
+	}})
+	if err != nil {
+		return fmt.Errorf("库存调整失败: %w", err)
+	}
+	return nil
+}
+
+type ReserveRequest struct {
 	MaterialID    string  `json:"material_id" binding:"required"`
 	WarehouseID   string  `json:"warehouse_id" binding:"required"`
-	AdjustQty     float64 `json:"adjust_qty" binding:"required"` // 正数增加，负数减少
-	Reason        string  `json:"reason" binding:"required"`
+	Quantity      float64 `json:"quantity" binding:"required,gt=0"`
+	ReferenceType string  `json:"reference_type" binding:"required"` // SO, WO
+	ReferenceID   string  `json:"reference_id" binding:"required"`
+	ReferenceCode string  `json:"reference_code"`
+	Notes         string  `json:"notes"`
 }
 
-func (s *InventoryService) Adjust(req AdjustRequest, userID string) error {
-	now := time.Now()
+// Reserve 预留库存
+func (s *InventoryService) Reserve(req ReserveRequest, userID string) (*entity.InventoryReservation, error) {
+	res := &entity.InventoryReservation{
+		ID:            uuid.New().String(),
+		MaterialID:    req.MaterialID,
+		WarehouseID:   req.WarehouseID,
+		Quantity:      req.Quantity,
+		ReferenceType: req.ReferenceType,
+		ReferenceID:   req.ReferenceID,
+		ReferenceCode: req.ReferenceCode,
+		Notes:         req.Notes,
+		CreatedBy:     userID,
+	}
+	if _, err := s.repo.Reserve(res); err != nil {
+		return nil, fmt.Errorf("预留库存失败: %w", err)
+	}
+	return res, nil
+}
 
-	inv, err := s.repo.GetByMaterialAndWarehouse(req.MaterialID, req.WarehouseID)
+// ReleaseReservation 释放预留
+func (s *InventoryService) ReleaseReservation(id string) (*entity.InventoryReservation, error) {
+	res, err := s.repo.ReleaseReservation(id)
 	if err != nil {
-		return fmt.Errorf("库存记录不存在: %w", err)
+		return nil, fmt.Errorf("释放预留失败: %w", err)
 	}
+	return res, nil
+}
+
+func (s *InventoryService) ListReservations(materialID, referenceID, status string) ([]entity.InventoryReservation, error) {
+	return s.repo.ListReservations(materialID, referenceID, status)
+}
 
-	inv.Quantity += req.AdjustQty
-	inv.AvailableQty += req.AdjustQty
-	inv.LastMovedAt = &now
-	if inv.AvailableQty < 0 {
-		return fmt.Errorf("调整后可用库存不能为负数")
+// InventoryDrift 结存与流水/预留的差异
+type InventoryDrift struct {
+	repository.BalanceCheck
+	QtyDrift      float64 `json:"qty_drift"`      // 结存 - 流水合计
+	ReservedDrift float64 `json:"reserved_drift"` // 结存预留 - 有效预留合计
+}
+
+type ReconcileResult struct {
+	Reconciliation *entity.InventoryReconciliation `json:"reconciliation"`
+	Drifts         []InventoryDrift                `json:"drifts"`
+}
+
+// Reconcile 库存对账：以流水为准核对结存，fix=true 时按流水重建有差异的结存
+func (s *InventoryService) Reconcile(fix bool, trigger, userID string) (*ReconcileResult, error) {
+	checks, err := s.repo.GetBalanceChecks()
+	if err != nil {
+		return nil, fmt.Errorf("汇总库存失败: %w", err)
+	}
+	drifts := make([]InventoryDrift, 0)
+	for _, c := range checks {
+		d := InventoryDrift{
+			BalanceCheck:  c,
+			QtyDrift:      c.BalanceQty - c.LedgerQty,
+			ReservedDrift: c.ReservedQty - c.ReservationQty,
+		}
+		if math.Abs(d.QtyDrift) < 1e-6 && math.Abs(d.ReservedDrift) < 1e-6 &&
+			math.Abs(c.AvailableQty-(c.BalanceQty-c.ReservedQty)) < 1e-6 {
+			continue
+		}
+		drifts = append(drifts, d)
 	}
-	if err := s.repo.Update(inv); err != nil {
-		return fmt.Errorf("更新库存失败: %w", err)
+
+	if fix {
+		for _, d := range drifts {
+			if err := s.repo.RebuildBalance(d.MaterialID, d.WarehouseID); err != nil {
+				return nil, fmt.Errorf("重建物料 %s 结存失败: %w", d.MaterialCode, err)
+			}
+		}
 	}
 
-	tx := &entity.InventoryTransaction{
-		ID:              uuid.New().String(),
-		MaterialID:      req.MaterialID,
-		MaterialCode:    inv.MaterialCode,
-		MaterialName:    inv.MaterialName,
-		WarehouseID:     req.WarehouseID,
-		TransactionType: entity.TxTypeAdjust,
-		Quantity:        req.AdjustQty,
-		ReferenceType:   "ADJUST",
-		ReferenceID:     uuid.New().String(),
-		Notes:           req.Reason,
-		CreatedBy:       userID,
+	details, _ := json.Marshal(drifts)
+	rec := &entity.InventoryReconciliation{
+		ID:        uuid.New().String(),
+		Checked:   len(checks),
+		Drifted:   len(drifts),
+		Fixed:     fix && len(drifts) > 0,
+		Details:   string(details),
+		Trigger:   trigger,
+		CreatedBy: userID,
 	}
-	return s.repo.CreateTransaction(tx)
+	if err := s.repo.CreateReconciliation(rec); err != nil {
+		return nil, err
+	}
+	return &ReconcileResult{Reconciliation: rec, Drifts: drifts}, nil
+}
+
+func (s *InventoryService) ListReconciliations(limit int) ([]entity.InventoryReconciliation, error) {
+	return s.repo.ListReconciliations(limit)
+}
+
+// StartReconcileScheduler 定时对账（只报告差异，不自动修正）
+func (s *InventoryService) StartReconcileScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				result, err := s.Reconcile(false, "SCHEDULED", "system")
+				if err != nil {
+					log.Printf("[ERP] 库存对账失败: %v", err)
+					continue
+				}
+				if n := result.Reconciliation.Drifted; n > 0 {
+					log.Printf("[ERP] 库存对账发现 %d 项结存与流水不一致", n)
+				}
+			}
+		}
+	}()
 }
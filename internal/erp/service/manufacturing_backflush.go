@@ -0,0 +1,532 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/google/uuid"
+)
+
+// materialIssue 一笔工单物料发料
+type materialIssue struct {
+	mat *entity.WorkOrderMaterial
+	qty float64
+}
+
+func validIssueMethod(method string) bool {
+	switch method {
+	case entity.IssueMethodManual, entity.IssueMethodBackflushOperation, entity.IssueMethodBackflushCompletion:
+		return true
+	}
+	return false
+}
+
+// qtyPer 单位用量，历史工单未记录时按需求数量折算
+func qtyPer(m *entity.WorkOrderMaterial, wo *entity.WorkOrder) float64 {
+	if m.QtyPer > 0 {
+		return m.QtyPer
+	}
+	if wo.PlannedQty > 0 {
+		return m.RequiredQty / wo.PlannedQty
+	}
+	return 0
+}
+
+// issueWarehouse 领料/倒冲仓库，未指定时取成品仓库
+func issueWarehouse(wo *entity.WorkOrder) string {
+	if wo.IssueWHID != "" {
+		return wo.IssueWHID
+	}
+	return wo.WarehouseID
+}
+
+// applyIssueMethods 按物料默认发料方式设置工单物料，未配置的为手工领料
+func (s *ManufacturingService) applyIssueMethods(wo *entity.WorkOrder) error {
+	ids := make([]string, 0, len(wo.Materials))
+	for _, m := range wo.Materials {
+		ids = append(ids, m.MaterialID)
+	}
+	methods, err := s.woRepo.GetIssueMethods(ids)
+	if err != nil {
+		return err
+	}
+	for i := range wo.Materials {
+		wo.Materials[i].IssueMethod = entity.IssueMethodManual
+		if method, ok := methods[wo.Materials[i].MaterialID]; ok {
+			wo.Materials[i].IssueMethod = method
+		}
+	}
+	return nil
+}
+
+// woLotLink 由一笔出入库流水生成工单批次谱系记录，qty 为谱系数量（消耗为正，退料冲减为负）
+func woLotLink(wo *entity.WorkOrder, linkType string, tx *entity.InventoryTransaction, qty float64, userID string) entity.LotGenealogy {
+	return entity.LotGenealogy{
+		ID:            uuid.New().String(),
+		WorkOrderID:   wo.ID,
+		WOCode:        wo.WOCode,
+		LinkType:      linkType,
+		MaterialID:    tx.MaterialID,
+		MaterialCode:  tx.MaterialCode,
+		MaterialName:  tx.MaterialName,
+		LotNo:         tx.BatchNo,
+		SerialNo:      tx.SerialNo,
+		Quantity:      qty,
+		SupplierID:    tx.SupplierID,
+		TransactionID: tx.ID,
+		CreatedBy:     userID,
+	}
+}
+
+// issueMaterials 在同一事务内行锁校验并出库，按拣货策略拆分到批次，记录批次谱系并更新已发料数量
+func (s *ManufacturingService) issueMaterials(wo *entity.WorkOrder, warehouseID string, issues []materialIssue, userID string) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	if warehouseID == "" {
+		return fmt.Errorf("未指定领料仓库")
+	}
+	moves := make([]repository.StockMovement, 0, len(issues))
+	for _, is := range issues {
+		moves = append(moves, repository.StockMovement{Tx: &entity.InventoryTransaction{
+			ID:              uuid.New().String(),
+			MaterialID:      is.mat.MaterialID,
+			MaterialCode:    is.mat.MaterialCode,
+			MaterialName:    is.mat.MaterialName,
+			WarehouseID:     warehouseID,
+			TransactionType: entity.TxTypeProductionOut,
+			Quantity:        -is.qty,
+			ReferenceType:   "WO",
+			ReferenceID:     wo.ID,
+			ReferenceCode:   wo.WOCode,
+			CreatedBy:       userID,
+		}, AllocateLots: true})
+	}
+	if _, err := s.inventoryRepo.PostMovements(moves); err != nil {
+		return err
+	}
+
+	// 记录批次谱系：工单消耗了哪些组件批次
+	var links []entity.LotGenealogy
+	for _, m := range moves {
+		for i := range m.Lots {
+			links = append(links, woLotLink(wo, entity.GenealogyConsume, &m.Lots[i], -m.Lots[i].Quantity, userID))
+		}
+	}
+	if err := s.genealogyRepo.CreateLinks(links); err != nil {
+		return fmt.Errorf("记录批次谱系失败: %w", err)
+	}
+
+	for _, is := range issues {
+		is.mat.IssuedQty += is.qty
+		if err := s.woRepo.UpdateMaterial(is.mat); err != nil {
+			return fmt.Errorf("更新物料发料数量失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// backflush 倒冲扣料：把符合条件的物料补发到 units 对应的理论用量（已发料多于理论用量时不扣）
+func (s *ManufacturingService) backflush(wo *entity.WorkOrder, match func(m *entity.WorkOrderMaterial) bool, units float64, userID string) error {
+	var issues []materialIssue
+	for i := range wo.Materials {
+		m := &wo.Materials[i]
+		if !match(m) {
+			continue
+		}
+		qty := qtyPer(m, wo)*units - (m.IssuedQty - m.ReturnedQty)
+		if qty > 1e-9 {
+			issues = append(issues, materialIssue{mat: m, qty: qty})
+		}
+	}
+	if err := s.issueMaterials(wo, issueWarehouse(wo), issues, userID); err != nil {
+		return fmt.Errorf("倒冲扣料失败: %w", err)
+	}
+	return nil
+}
+
+// backflushOperation 工序报工倒冲：opID 为空（无工艺路线的工单）时倒冲全部工序倒冲物料
+func (s *ManufacturingService) backflushOperation(wo *entity.WorkOrder, opID string, units float64, userID string) error {
+	return s.backflush(wo, func(m *entity.WorkOrderMaterial) bool {
+		return m.IssueMethod == entity.IssueMethodBackflushOperation && (opID == "" || m.OperationID == opID)
+	}, units, userID)
+}
+
+// backflushCompletion 完工倒冲，按良品加报废数量计算理论用量
+func (s *ManufacturingService) backflushCompletion(wo *entity.WorkOrder, userID string) error {
+	return s.backflush(wo, func(m *entity.WorkOrderMaterial) bool {
+		return m.IssueMethod == entity.IssueMethodBackflushCompletion
+	}, wo.CompletedQty+wo.ScrapQty, userID)
+}
+
+type SetIssueMethodRequest struct {
+	IssueMethod string `json:"issue_method" binding:"required"`
+}
+
+// SetMaterialIssueMethod 修改工单物料的发料方式
+func (s *ManufacturingService) SetMaterialIssueMethod(woID, materialID string, req SetIssueMethodRequest) (*entity.WorkOrderMaterial, error) {
+	if !validIssueMethod(req.IssueMethod) {
+		return nil, fmt.Errorf("无效的发料方式: %s", req.IssueMethod)
+	}
+	wo, err := s.woRepo.GetByID(woID)
+	if err != nil {
+		return nil, fmt.Errorf("工单不存在: %w", err)
+	}
+	if wo.Status == entity.WOStatusCompleted || wo.Status == entity.WOStatusClosed {
+		return nil, fmt.Errorf("工单已完工，不能修改发料方式")
+	}
+	for i := range wo.Materials {
+		m := &wo.Materials[i]
+		if m.ID != materialID {
+			continue
+		}
+		m.IssueMethod = req.IssueMethod
+		if err := s.woRepo.UpdateMaterial(m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("工单物料不存在")
+}
+
+type SaveIssueMethodRequest struct {
+	MaterialID  string `json:"material_id" binding:"required"`
+	IssueMethod string `json:"issue_method" binding:"required"`
+}
+
+// SaveDefaultIssueMethod 设置物料默认发料方式，对之后创建的工单生效
+func (s *ManufacturingService) SaveDefaultIssueMethod(req SaveIssueMethodRequest, userID string) (*entity.MaterialIssueMethod, error) {
+	if !validIssueMethod(req.IssueMethod) {
+		return nil, fmt.Errorf("无效的发料方式: %s", req.IssueMethod)
+	}
+	m := &entity.MaterialIssueMethod{MaterialID: req.MaterialID, IssueMethod: req.IssueMethod, UpdatedBy: userID}
+	if err := s.woRepo.SaveIssueMethod(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *ManufacturingService) ListDefaultIssueMethods() ([]entity.MaterialIssueMethod, error) {
+	return s.woRepo.ListIssueMethods()
+}
+
+type ReturnItem struct {
+	WOMaterialID string  `json:"wo_material_id" binding:"required"`
+	Quantity     float64 `json:"quantity" binding:"required,gt=0"`
+	BatchNo      string  `json:"batch_no"`
+	LocationID   string  `json:"location_id"`
+}
+
+type ReturnMaterialsRequest struct {
+	WarehouseID string       `json:"warehouse_id"` // 空=领料仓库
+	Items       []ReturnItem `json:"items" binding:"required,min=1,dive"`
+	Notes       string       `json:"notes"`
+}
+
+// ReturnMaterials 生产退料：退回数量不超过净发料数量
+func (s *ManufacturingService) ReturnMaterials(woID string, req ReturnMaterialsRequest, userID string) error {
+	return s.inTx(woID, func(t *ManufacturingService, wo *entity.WorkOrder) error {
+		return t.returnRequested(wo, req, userID)
+	})
+}
+
+func (s *ManufacturingService) returnRequested(wo *entity.WorkOrder, req ReturnMaterialsRequest, userID string) error {
+	if wo.Status != entity.WOStatusInProgress && wo.Status != entity.WOStatusCompleted {
+		return fmt.Errorf("工单状态不允许退料: %s", wo.Status)
+	}
+	mats := make(map[string]*entity.WorkOrderMaterial, len(wo.Materials))
+	for i := range wo.Materials {
+		mats[wo.Materials[i].ID] = &wo.Materials[i]
+	}
+	var returns []materialIssue
+	batches := make([]ReturnItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		m, ok := mats[item.WOMaterialID]
+		if !ok {
+			return fmt.Errorf("工单物料 %s 不存在", item.WOMaterialID)
+		}
+		returns = append(returns, materialIssue{mat: m, qty: item.Quantity})
+		batches = append(batches, item)
+	}
+	wh := req.WarehouseID
+	if wh == "" {
+		wh = issueWarehouse(wo)
+	}
+	return s.returnMaterials(wo, wh, returns, batches, req.Notes, userID)
+}
+
+func (s *ManufacturingService) returnMaterials(wo *entity.WorkOrder, warehouseID string, returns []materialIssue, items []ReturnItem, notes, userID string) error {
+	if len(returns) == 0 {
+		return nil
+	}
+	if warehouseID == "" {
+		return fmt.Errorf("未指定退料仓库")
+	}
+	pending := make(map[string]float64)
+	moves := make([]repository.StockMovement, 0, len(returns))
+	for i, r := range returns {
+		pending[r.mat.ID] += r.qty
+		if net := r.mat.IssuedQty - r.mat.ReturnedQty; pending[r.mat.ID] > net+1e-9 {
+			return fmt.Errorf("物料 %s 退料数量超过净发料数量%.4f", r.mat.MaterialCode, net)
+		}
+		var item ReturnItem
+		if i < len(items) {
+			item = items[i]
+		}
+		moves = append(moves, repository.StockMovement{
+			Tx: &entity.InventoryTransaction{
+				ID:              uuid.New().String(),
+				MaterialID:      r.mat.MaterialID,
+				MaterialCode:    r.mat.MaterialCode,
+				MaterialName:    r.mat.MaterialName,
+				WarehouseID:     warehouseID,
+				LocationID:      item.LocationID,
+				BatchNo:         item.BatchNo,
+				TransactionType: entity.TxTypeProductionRet,
+				Quantity:        r.qty,
+				ReferenceType:   "WO",
+				ReferenceID:     wo.ID,
+				ReferenceCode:   wo.WOCode,
+				Notes:           notes,
+				CreatedBy:       userID,
+			},
+			CreateIfMissing: true,
+			Unit:            r.mat.Unit,
+		})
+	}
+	placed, err := s.warehouse.PlaceInbound(moves)
+	if err != nil {
+		return fmt.Errorf("退料上架失败: %w", err)
+	}
+	if _, err := s.inventoryRepo.PostMovements(placed); err != nil {
+		return fmt.Errorf("退料入库失败: %w", err)
+	}
+
+	// 退回的已知批次冲减批次谱系中的消耗
+	var links []entity.LotGenealogy
+	for _, m := range placed {
+		if m.Tx.BatchNo == "" {
+			continue
+		}
+		links = append(links, woLotLink(wo, entity.GenealogyConsume, m.Tx, -m.Tx.Quantity, userID))
+	}
+	if err := s.genealogyRepo.CreateLinks(links); err != nil {
+		return fmt.Errorf("记录批次谱系失败: %w", err)
+	}
+
+	for _, r := range returns {
+		r.mat.ReturnedQty += r.qty
+		if err := s.woRepo.UpdateMaterial(r.mat); err != nil {
+			return fmt.Errorf("更新物料退料数量失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// MaterialVariance 工单物料用量差异
+type MaterialVariance struct {
+	WOMaterialID   string  `json:"wo_material_id"`
+	MaterialID     string  `json:"material_id"`
+	MaterialCode   string  `json:"material_code"`
+	MaterialName   string  `json:"material_name"`
+	IssueMethod    string  `json:"issue_method"`
+	QtyPer         float64 `json:"qty_per"`
+	StandardQty    float64 `json:"standard_qty"`    // 计划数量的标准用量
+	TheoreticalQty float64 `json:"theoretical_qty"` // 实际投入（良品+报废）的理论用量
+	IssuedQty      float64 `json:"issued_qty"`
+	ReturnedQty    float64 `json:"returned_qty"`
+	NetIssuedQty   float64 `json:"net_issued_qty"`
+	VarianceQty    float64 `json:"variance_qty"` // 净发料-理论用量，正数为超耗
+	UnitCost       float64 `json:"unit_cost"`
+	VarianceValue  float64 `json:"variance_value"`
+	ScrapQty       float64 `json:"scrap_qty"` // 随报废品损失的组件数量
+	ScrapValue     float64 `json:"scrap_value"`
+}
+
+// OperationYield 工序良率与工时效率
+type OperationYield struct {
+	OperationID    string  `json:"operation_id"`
+	Sequence       int     `json:"sequence"`
+	Name           string  `json:"name"`
+	WorkCenterCode string  `json:"work_center_code"`
+	InputQty       float64 `json:"input_qty"`
+	GoodQty        float64 `json:"good_qty"`
+	ScrapQty       float64 `json:"scrap_qty"`
+	ReworkQty      float64 `json:"rework_qty"`
+	YieldPct       float64 `json:"yield_pct"`
+	StdMinutes     float64 `json:"std_minutes"` // 按实际加工数量的标准工时
+	ActualMinutes  float64 `json:"actual_minutes"`
+	EfficiencyPct  float64 `json:"efficiency_pct"` // 标准工时/实际工时
+}
+
+// WOCloseoutReport 工单结案报告：物料、报废与产出差异
+type WOCloseoutReport struct {
+	WorkOrderID           string             `json:"work_order_id"`
+	WOCode                string             `json:"wo_code"`
+	ProductCode           string             `json:"product_code"`
+	ProductName           string             `json:"product_name"`
+	Status                string             `json:"status"`
+	PlannedQty            float64            `json:"planned_qty"`
+	CompletedQty          float64            `json:"completed_qty"`
+	ScrapQty              float64            `json:"scrap_qty"`
+	YieldPct              float64            `json:"yield_pct"`        // 良品/(良品+报废)
+	RolledYieldPct        float64            `json:"rolled_yield_pct"` // 各工序良率之积
+	StdMaterialCost       float64            `json:"std_material_cost"`
+	MaterialVarianceValue float64            `json:"material_variance_value"`
+	ScrapVarianceValue    float64            `json:"scrap_variance_value"`
+	YieldVarianceQty      float64            `json:"yield_variance_qty"` // 良品-计划数量
+	YieldVarianceValue    float64            `json:"yield_variance_value"`
+	Materials             []MaterialVariance `json:"materials"`
+	Operations            []OperationYield   `json:"operations,omitempty"`
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// materialUnitCost 物料单位成本：优先取领料仓库结存成本
+func (s *ManufacturingService) materialUnitCost(materialID, warehouseID string) float64 {
+	if warehouseID != "" {
+		if inv, err := s.inventoryRepo.GetByMaterialAndWarehouse(materialID, warehouseID); err == nil && inv.UnitCost > 0 {
+			return inv.UnitCost
+		}
+	}
+	invs, _ := s.inventoryRepo.GetByMaterial(materialID)
+	for _, inv := range invs {
+		if inv.UnitCost > 0 {
+			return inv.UnitCost
+		}
+	}
+	return 0
+}
+
+// CloseoutReport 工单差异报告，已关闭和未关闭的工单均可查询
+func (s *ManufacturingService) CloseoutReport(woID string) (*WOCloseoutReport, error) {
+	wo, err := s.woRepo.GetByID(woID)
+	if err != nil {
+		return nil, fmt.Errorf("工单不存在: %w", err)
+	}
+	return s.closeoutReport(wo), nil
+}
+
+func (s *ManufacturingService) closeoutReport(wo *entity.WorkOrder) *WOCloseoutReport {
+	r := &WOCloseoutReport{
+		WorkOrderID: wo.ID, WOCode: wo.WOCode, ProductCode: wo.ProductCode, ProductName: wo.ProductName,
+		Status: wo.Status, PlannedQty: wo.PlannedQty, CompletedQty: wo.CompletedQty, ScrapQty: wo.ScrapQty,
+		Materials: make([]MaterialVariance, 0, len(wo.Materials)),
+	}
+	processed := wo.CompletedQty + wo.ScrapQty
+	if processed > 0 {
+		r.YieldPct = math.Round(wo.CompletedQty*10000/processed) / 100
+	}
+	wh := issueWarehouse(wo)
+	for i := range wo.Materials {
+		m := &wo.Materials[i]
+		per := qtyPer(m, wo)
+		v := MaterialVariance{
+			WOMaterialID: m.ID, MaterialID: m.MaterialID, MaterialCode: m.MaterialCode, MaterialName: m.MaterialName,
+			IssueMethod: m.IssueMethod, QtyPer: per,
+			StandardQty:    round4(per * wo.PlannedQty),
+			TheoreticalQty: round4(per * processed),
+			IssuedQty:      m.IssuedQty,
+			ReturnedQty:    m.ReturnedQty,
+			NetIssuedQty:   round4(m.IssuedQty - m.ReturnedQty),
+			ScrapQty:       round4(per * wo.ScrapQty),
+			UnitCost:       s.materialUnitCost(m.MaterialID, wh),
+		}
+		v.VarianceQty = round4(v.NetIssuedQty - v.TheoreticalQty)
+		v.VarianceValue = math.Round(v.VarianceQty*v.UnitCost*100) / 100
+		v.ScrapValue = math.Round(v.ScrapQty*v.UnitCost*100) / 100
+		r.StdMaterialCost += per * v.UnitCost
+		r.MaterialVarianceValue += v.VarianceValue
+		r.ScrapVarianceValue += v.ScrapValue
+		r.Materials = append(r.Materials, v)
+	}
+	r.StdMaterialCost = round4(r.StdMaterialCost)
+	r.MaterialVarianceValue = math.Round(r.MaterialVarianceValue*100) / 100
+	r.ScrapVarianceValue = math.Round(r.ScrapVarianceValue*100) / 100
+	r.YieldVarianceQty = round4(wo.CompletedQty - wo.PlannedQty)
+	r.YieldVarianceValue = math.Round(r.YieldVarianceQty*r.StdMaterialCost*100) / 100
+
+	if len(wo.Operations) > 0 {
+		rolled := 1.0
+		for i, op := range wo.Operations {
+			y := OperationYield{
+				OperationID: op.ID, Sequence: op.Sequence, Name: op.Name, WorkCenterCode: op.WorkCenterCode,
+				InputQty: operationInput(wo, i), GoodQty: op.GoodQty, ScrapQty: op.ScrapQty, ReworkQty: op.ReworkQty,
+				StdMinutes:    round4(op.SetupMinutes + op.RunMinutesPerUnit*(op.GoodQty+op.ScrapQty)),
+				ActualMinutes: op.SetupActualMin + op.LaborMinutes,
+			}
+			if done := op.GoodQty + op.ScrapQty; done > 0 {
+				y.YieldPct = math.Round(op.GoodQty*10000/done) / 100
+				rolled *= op.GoodQty / done
+			}
+			if y.ActualMinutes > 0 {
+				y.EfficiencyPct = math.Round(y.StdMinutes*10000/y.ActualMinutes) / 100
+			}
+			r.Operations = append(r.Operations, y)
+		}
+		r.RolledYieldPct = math.Round(rolled*10000) / 100
+	} else {
+		r.RolledYieldPct = r.YieldPct
+	}
+	return r
+}
+
+type CloseWorkOrderRequest struct {
+	ReturnLeftovers bool   `json:"return_leftovers"` // 超出理论用量的净发料自动退回
+	WarehouseID     string `json:"warehouse_id"`     // 退料仓库，空=领料仓库
+}
+
+// Close 工单结案：可选退回剩余组件，记录各物料用量差异后关闭
+func (s *ManufacturingService) Close(woID string, req CloseWorkOrderRequest, userID string) (*WOCloseoutReport, error) {
+	var report *WOCloseoutReport
+	err := s.inTx(woID, func(t *ManufacturingService, wo *entity.WorkOrder) error {
+		var err error
+		report, err = t.close(wo, req, userID)
+		return err
+	})
+	return report, err
+}
+
+func (s *ManufacturingService) close(wo *entity.WorkOrder, req CloseWorkOrderRequest, userID string) (*WOCloseoutReport, error) {
+	if wo.Status != entity.WOStatusCompleted {
+		return nil, fmt.Errorf("只有已完工的工单可以结案: %s", wo.Status)
+	}
+	if req.ReturnLeftovers {
+		processed := wo.CompletedQty + wo.ScrapQty
+		var returns []materialIssue
+		for i := range wo.Materials {
+			m := &wo.Materials[i]
+			if leftover := round4((m.IssuedQty - m.ReturnedQty) - qtyPer(m, wo)*processed); leftover > 0 {
+				returns = append(returns, materialIssue{mat: m, qty: leftover})
+			}
+		}
+		wh := req.WarehouseID
+		if wh == "" {
+			wh = issueWarehouse(wo)
+		}
+		if err := s.returnMaterials(wo, wh, returns, nil, "工单结案退料", userID); err != nil {
+			return nil, err
+		}
+	}
+
+	report := s.closeoutReport(wo)
+	for i := range wo.Materials {
+		wo.Materials[i].VarianceQty = report.Materials[i].VarianceQty
+		if err := s.woRepo.UpdateMaterial(&wo.Materials[i]); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	wo.Status = entity.WOStatusClosed
+	wo.ClosedAt, wo.ClosedBy = &now, userID
+	if err := s.woRepo.Update(wo); err != nil {
+		return nil, err
+	}
+	report.Status = wo.Status
+	return report, nil
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	plmEntity "github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// defaultDailyMinutes 工作中心未配置时按单班8小时估算工序工期
+const defaultDailyMinutes = 480
+
+// routeStatusActive PLM 工艺路线已发布状态
+const routeStatusActive = "active"
+
+// loadRoute 读取工单使用的工艺路线：指定路线须已发布；未指定时取 BOM 最新发布的路线，没有则返回 nil
+func (s *ManufacturingService) loadRoute(routeID, bomID string) (*plmEntity.ProcessRoute, error) {
+	var route plmEntity.ProcessRoute
+	query := s.db.Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order, step_number") }).
+		Preload("Steps.Materials")
+	if routeID != "" {
+		if err := query.Where("id = ?", routeID).First(&route).Error; err != nil {
+			return nil, fmt.Errorf("工艺路线不存在: %w", err)
+		}
+		if route.Status != routeStatusActive {
+			return nil, fmt.Errorf("工艺路线 %s 未发布", route.Name)
+		}
+		return &route, nil
+	}
+	var routes []plmEntity.ProcessRoute
+	if err := query.Where("bom_id = ? AND status = ?", bomID, routeStatusActive).
+		Order("updated_at DESC").Limit(1).Find(&routes).Error; err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, nil
+	}
+	return &routes[0], nil
+}
+
+// applyRoute 将工艺路线复制为工单工序，并把工序物料挂到对应工序：
+// 工序物料与 BOM 组件相同时由该工序领料，工序专用的消耗品追加为工单物料，其余组件在首道工序领料
+func (s *ManufacturingService) applyRoute(wo *entity.WorkOrder, route *plmEntity.ProcessRoute) error {
+	wo.RouteID, wo.RouteVersion = route.ID, route.Version
+	byMaterial := make(map[string]*entity.WorkOrderMaterial, len(wo.Materials))
+	for i := range wo.Materials {
+		byMaterial[wo.Materials[i].MaterialID] = &wo.Materials[i]
+	}
+
+	var extra []entity.WorkOrderMaterial
+	for i, step := range route.Steps {
+		seq := step.StepNumber
+		if seq <= 0 {
+			seq = (i + 1) * 10
+		}
+		op := entity.WorkOrderOperation{
+			ID:                uuid.New().String(),
+			WorkOrderID:       wo.ID,
+			Sequence:          seq,
+			StepID:            step.ID,
+			Name:              step.Name,
+			WorkCenterCode:    step.WorkCenter,
+			SetupMinutes:      step.SetupMinutes,
+			RunMinutesPerUnit: step.StdTimeMinutes,
+			PlannedQty:        wo.PlannedQty,
+			Status:            entity.OpStatusPending,
+		}
+		if step.WorkCenter != "" && s.wcRepo != nil {
+			if wc, err := s.wcRepo.FindByKey(step.WorkCenter); err == nil {
+				op.WorkCenterID, op.WorkCenterCode = wc.ID, wc.Code
+			}
+		}
+		wo.Operations = append(wo.Operations, op)
+
+		for _, sm := range step.Materials {
+			if sm.MaterialID == "" {
+				continue
+			}
+			if m, ok := byMaterial[sm.MaterialID]; ok {
+				if m.OperationID == "" {
+					m.OperationID, m.OperationSeq = op.ID, op.Sequence
+				}
+				continue
+			}
+			// 工装、服务不走库存
+			if sm.Category != "consumable" {
+				continue
+			}
+			var mat plmEntity.Material
+			if err := s.db.Where("id = ?", sm.MaterialID).First(&mat).Error; err != nil {
+				return fmt.Errorf("工序 %d 物料 %s 不存在: %w", seq, sm.MaterialID, err)
+			}
+			extra = append(extra, entity.WorkOrderMaterial{
+				ID:           uuid.New().String(),
+				WorkOrderID:  wo.ID,
+				MaterialID:   sm.MaterialID,
+				MaterialCode: mat.Code,
+				MaterialName: mat.Name,
+				QtyPer:       sm.Quantity,
+				RequiredQty:  sm.Quantity * wo.PlannedQty,
+				Unit:         sm.Unit,
+				OperationID:  op.ID,
+				OperationSeq: op.Sequence,
+			})
+		}
+	}
+	if len(wo.Operations) > 0 {
+		first := wo.Operations[0]
+		for i := range wo.Materials {
+			if wo.Materials[i].OperationID == "" {
+				wo.Materials[i].OperationID, wo.Materials[i].OperationSeq = first.ID, first.Sequence
+			}
+		}
+	}
+	wo.Materials = append(wo.Materials, extra...)
+	s.scheduleOperations(wo)
+	return nil
+}
+
+// scheduleOperations 按工作中心日历无限产能顺排工序计划日期，从工单计划开工日（默认今天）起
+func (s *ManufacturingService) scheduleOperations(wo *entity.WorkOrder) {
+	start := dateOnly(time.Now())
+	if wo.PlannedStart != nil {
+		start = dateOnly(*wo.PlannedStart)
+	}
+	centers := make(map[string]*entity.WorkCenter)
+	for i := range wo.Operations {
+		op := &wo.Operations[i]
+		wc := entity.WorkCenter{HoursPerShift: 8, Shifts: 1, Units: 1, EfficiencyPct: 100, WorkDays: 5}
+		if op.WorkCenterID != "" && s.wcRepo != nil {
+			c, ok := centers[op.WorkCenterID]
+			if !ok {
+				c, _ = s.wcRepo.GetByID(op.WorkCenterID)
+				centers[op.WorkCenterID] = c
+			}
+			if c != nil {
+				wc = *c
+			}
+		}
+		daily := wc.DailyMinutes()
+		if daily <= 0 {
+			daily = defaultDailyMinutes
+		}
+		for !wc.IsWorkDay(start) {
+			start = start.AddDate(0, 0, 1)
+		}
+		end := start
+		for days := int(math.Ceil(op.StdMinutes()/daily)) - 1; days > 0; {
+			end = end.AddDate(0, 0, 1)
+			if wc.IsWorkDay(end) {
+				days--
+			}
+		}
+		opStart, opEnd := start, end
+		op.PlannedStart, op.PlannedEnd = &opStart, &opEnd
+		// 下道工序次日开工
+		start = end.AddDate(0, 0, 1)
+	}
+}
+
+type OperationReportRequest struct {
+	GoodQty      float64 `json:"good_qty" binding:"gte=0"`
+	ScrapQty     float64 `json:"scrap_qty" binding:"gte=0"`
+	ReworkQty    float64 `json:"rework_qty" binding:"gte=0"`
+	SetupMinutes float64 `json:"setup_minutes" binding:"gte=0"`
+	LaborMinutes float64 `json:"labor_minutes" binding:"gte=0"`
+	Notes        string  `json:"notes"`
+}
+
+// ReportOperation 工序报工：良品与报废累计不超过上道工序良品数（首道为计划数），
+// 返工数量仅记录，返工后的结果再按良品或报废报工。末道工序良品计入工单完工数量
+// 倒冲、报工记录与工序、工单更新在同一事务内
+func (s *ManufacturingService) ReportOperation(woID, opID string, req OperationReportRequest, userID string) (*entity.WorkOrderOperation, error) {
+	var op *entity.WorkOrderOperation
+	err := s.inTx(woID, func(t *ManufacturingService, wo *entity.WorkOrder) error {
+		var err error
+		op, err = t.reportOperation(wo, opID, req, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (s *ManufacturingService) reportOperation(wo *entity.WorkOrder, opID string, req OperationReportRequest, userID string) (*entity.WorkOrderOperation, error) {
+	if wo.Status != entity.WOStatusInProgress && wo.Status != entity.WOStatusReleased {
+		return nil, fmt.Errorf("工单状态不允许报工: %s", wo.Status)
+	}
+	if req.GoodQty+req.ScrapQty+req.ReworkQty+req.SetupMinutes+req.LaborMinutes <= 0 {
+		return nil, fmt.Errorf("报工数量和工时不能全为0")
+	}
+	idx := -1
+	for i := range wo.Operations {
+		if wo.Operations[i].ID == opID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("工序不属于该工单")
+	}
+	op := &wo.Operations[idx]
+	if op.Status == entity.OpStatusCompleted && req.GoodQty+req.ScrapQty > 0 {
+		return nil, fmt.Errorf("工序 %d %s 已完工", op.Sequence, op.Name)
+	}
+	input := operationInput(wo, idx)
+	if op.GoodQty+op.ScrapQty+req.GoodQty+req.ScrapQty > input+1e-9 {
+		return nil, fmt.Errorf("工序 %d 报工数量超过可加工数量%.4f（已报良品%.4f、报废%.4f）",
+			op.Sequence, input, op.GoodQty, op.ScrapQty)
+	}
+	if err := s.backflushOperation(wo, op.ID, op.GoodQty+op.ScrapQty+req.GoodQty+req.ScrapQty, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &entity.OperationReport{
+		ID:           uuid.New().String(),
+		WorkOrderID:  wo.ID,
+		OperationID:  op.ID,
+		WorkCenterID: op.WorkCenterID,
+		GoodQty:      req.GoodQty,
+		ScrapQty:     req.ScrapQty,
+		ReworkQty:    req.ReworkQty,
+		SetupMinutes: req.SetupMinutes,
+		LaborMinutes: req.LaborMinutes,
+		Notes:        req.Notes,
+		ReportedBy:   userID,
+		ReportedAt:   now,
+	}
+	if err := s.woRepo.CreateOperationReport(report); err != nil {
+		return nil, fmt.Errorf("创建工序报工记录失败: %w", err)
+	}
+
+	op.GoodQty += req.GoodQty
+	op.ScrapQty += req.ScrapQty
+	op.ReworkQty += req.ReworkQty
+	op.SetupActualMin += req.SetupMinutes
+	op.LaborMinutes += req.LaborMinutes
+	if op.Status == entity.OpStatusPending {
+		op.Status = entity.OpStatusInProgress
+		op.ActualStart = &now
+	}
+	// 本工序及后续工序在上道完工且数量报齐时完工
+	for i := idx; i < len(wo.Operations); i++ {
+		o := &wo.Operations[i]
+		if o.Status == entity.OpStatusCompleted {
+			continue
+		}
+		if i > 0 && wo.Operations[i-1].Status != entity.OpStatusCompleted {
+			break
+		}
+		if o.Status == entity.OpStatusPending || o.GoodQty+o.ScrapQty < operationInput(wo, i)-1e-9 {
+			break
+		}
+		o.Status = entity.OpStatusCompleted
+		o.ActualEnd = &now
+		if i != idx {
+			if err := s.woRepo.UpdateOperation(o); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := s.woRepo.UpdateOperation(op); err != nil {
+		return nil, fmt.Errorf("更新工序失败: %w", err)
+	}
+
+	wo.ScrapQty += req.ScrapQty
+	if idx == len(wo.Operations)-1 {
+		wo.CompletedQty += req.GoodQty
+	}
+	if wo.Status == entity.WOStatusReleased {
+		wo.Status = entity.WOStatusInProgress
+		wo.ActualStart = &now
+	}
+	if err := s.woRepo.Update(wo); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// operationInput 工序可加工数量：首道为工单计划数，其余为上道良品数
+func operationInput(wo *entity.WorkOrder, idx int) float64 {
+	if idx == 0 {
+		return wo.PlannedQty
+	}
+	return wo.Operations[idx-1].GoodQty
+}
+
+func (s *ManufacturingService) ListOperationReports(woID string) ([]entity.OperationReport, error) {
+	return s.woRepo.ListOperationReports(woID)
+}
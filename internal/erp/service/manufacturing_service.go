@@ -14,25 +14,59 @@ import (
 type ManufacturingService struct {
 	woRepo        *repository.WorkOrderRepository
 	inventoryRepo *repository.InventoryRepository
-	db            *gorm.DB // 直接读取PLM数据
+	genealogyRepo *repository.GenealogyRepository
+	wcRepo        *repository.WorkCenterRepository
+	warehouse     *WarehouseService // 完工上架
+	db            *gorm.DB          // 直接读取PLM数据
 }
 
-func NewManufacturingService(woRepo *repository.WorkOrderRepository, invRepo *repository.InventoryRepository, db *gorm.DB) *ManufacturingService {
-	return &ManufacturingService{woRepo: woRepo, inventoryRepo: invRepo, db: db}
+func NewManufacturingService(woRepo *repository.WorkOrderRepository, invRepo *repository.InventoryRepository, genealogyRepo *repository.GenealogyRepository, wcRepo *repository.WorkCenterRepository, warehouse *WarehouseService, db *gorm.DB) *ManufacturingService {
+	return &ManufacturingService{woRepo: woRepo, inventoryRepo: invRepo, genealogyRepo: genealogyRepo, wcRepo: wcRepo, warehouse: warehouse, db: db}
+}
+
+// withTx 返回各仓储绑定到同一事务的服务副本，出入库、批次谱系与工单更新在事务内完成
+func (s *ManufacturingService) withTx(tx *gorm.DB) *ManufacturingService {
+	return &ManufacturingService{
+		woRepo:        repository.NewWorkOrderRepository(tx),
+		inventoryRepo: repository.NewInventoryRepository(tx),
+		genealogyRepo: repository.NewGenealogyRepository(tx),
+		wcRepo:        repository.NewWorkCenterRepository(tx),
+		warehouse:     s.warehouse,
+		db:            tx,
+	}
+}
+
+// inTx 在事务内行锁读取工单后执行 fn
+func (s *ManufacturingService) inTx(id string, fn func(t *ManufacturingService, wo *entity.WorkOrder) error) error {
+	return s.woRepo.DB().Transaction(func(tx *gorm.DB) error {
+		t := s.withTx(tx)
+		wo, err := t.woRepo.LockByID(id)
+		if err != nil {
+			return fmt.Errorf("工单不存在: %w", err)
+		}
+		return fn(t, wo)
+	})
 }
 
 type CreateWorkOrderRequest struct {
 	ProductID   string  `json:"product_id" binding:"required"`
 	BOMID       string  `json:"bom_id" binding:"required"`
+	RouteID     string  `json:"route_id"` // 工艺路线，空=取BOM最新发布的路线
 	PlannedQty  float64 `json:"planned_qty" binding:"required,gt=0"`
 	Priority    int     `json:"priority"`
 	PlannedStart string `json:"planned_start"` // YYYY-MM-DD
 	PlannedEnd   string `json:"planned_end"`
 	WarehouseID  string `json:"warehouse_id"`
+	IssueWHID    string `json:"issue_wh_id"` // 领料/倒冲仓库，空=首次领料的仓库
 	Notes        string `json:"notes"`
 }
 
 func (s *ManufacturingService) Create(req CreateWorkOrderRequest, userID string) (*entity.WorkOrder, error) {
+	return s.create(req, "MANUAL", "", userID)
+}
+
+// create 创建工单并按BOM/工艺路线生成物料需求和工序，sourceType 标记来源（MANUAL/MRP）
+func (s *ManufacturingService) create(req CreateWorkOrderRequest, sourceType, sourceID, userID string) (*entity.WorkOrder, error) {
 	// 查询PLM产品信息
 	var product plmEntity.Product
 	if err := s.db.Where("id = ?", req.ProductID).First(&product).Error; err != nil {
@@ -59,7 +93,9 @@ func (s *ManufacturingService) Create(req CreateWorkOrderRequest, userID string)
 		Status:      entity.WOStatusCreated,
 		Priority:    req.Priority,
 		WarehouseID: req.WarehouseID,
-		SourceType:  "MANUAL",
+		IssueWHID:   req.IssueWHID,
+		SourceType:  sourceType,
+		SourceID:    sourceID,
 		Notes:       req.Notes,
 		CreatedBy:   userID,
 	}
@@ -94,12 +130,26 @@ func (s *ManufacturingService) Create(req CreateWorkOrderRequest, userID string)
 			MaterialID:   item.MaterialID,
 			MaterialCode: mat.Code,
 			MaterialName: mat.Name,
+			QtyPer:       item.Quantity,
 			RequiredQty:  item.Quantity * req.PlannedQty,
 			Unit:         item.Unit,
 		})
 	}
 	wo.Materials = materials
 
+	route, err := s.loadRoute(req.RouteID, req.BOMID)
+	if err != nil {
+		return nil, err
+	}
+	if route != nil {
+		if err := s.applyRoute(wo, route); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.applyIssueMethods(wo); err != nil {
+		return nil, err
+	}
+
 	if err := s.woRepo.Create(wo); err != nil {
 		return nil, fmt.Errorf("创建工单失败: %w", err)
 	}
@@ -127,63 +177,43 @@ func (s *ManufacturingService) Release(id string) error {
 	return s.woRepo.Update(wo)
 }
 
-// Pick 领料 - 根据BOM计算需求，从库存出库
-func (s *ManufacturingService) Pick(id, warehouseID, userID string) error {
-	wo, err := s.woRepo.GetByID(id)
-	if err != nil {
-		return fmt.Errorf("工单不存在: %w", err)
-	}
+type PickRequest struct {
+	WarehouseID string `json:"warehouse_id" binding:"required"`
+	OperationID string `json:"operation_id"` // 只领该工序的物料，空=全部
+}
+
+// Pick 领料 - 根据BOM计算需求，从库存出库。倒冲物料不在此领料，由报工或完工自动扣料
+func (s *ManufacturingService) Pick(id string, req PickRequest, userID string) error {
+	return s.inTx(id, func(t *ManufacturingService, wo *entity.WorkOrder) error {
+		return t.pick(wo, req, userID)
+	})
+}
+
+func (s *ManufacturingService) pick(wo *entity.WorkOrder, req PickRequest, userID string) error {
 	if wo.Status != entity.WOStatusReleased && wo.Status != entity.WOStatusInProgress {
 		return fmt.Errorf("工单状态不允许领料: %s", wo.Status)
 	}
 
-	// 遍历物料需求，执行出库
+	var issues []materialIssue
 	for i := range wo.Materials {
 		mat := &wo.Materials[i]
-		needQty := mat.RequiredQty - mat.IssuedQty
-		if needQty <= 0 {
+		if req.OperationID != "" && mat.OperationID != req.OperationID {
 			continue
 		}
-
-		// 查找库存
-		inv, invErr := s.inventoryRepo.GetByMaterialAndWarehouse(mat.MaterialID, warehouseID)
-		if invErr != nil {
-			return fmt.Errorf("物料 %s 在仓库中无库存", mat.MaterialCode)
-		}
-		if inv.AvailableQty < needQty {
-			return fmt.Errorf("物料 %s 库存不足: 需要%.4f, 可用%.4f", mat.MaterialCode, needQty, inv.AvailableQty)
-		}
-
-		// 扣减库存
-		now := time.Now()
-		inv.Quantity -= needQty
-		inv.AvailableQty -= needQty
-		inv.LastMovedAt = &now
-		if err := s.inventoryRepo.Update(inv); err != nil {
-			return fmt.Errorf("扣减库存失败: %w", err)
-		}
-
-		// 更新已发料数量
-		mat.IssuedQty += needQty
-		if err := s.woRepo.UpdateMaterial(mat); err != nil {
-			return fmt.Errorf("更新物料发料数量失败: %w", err)
+		if mat.IssueMethod != "" && mat.IssueMethod != entity.IssueMethodManual {
+			continue
 		}
-
-		// 记录库存交易
-		tx := &entity.InventoryTransaction{
-			ID:              uuid.New().String(),
-			MaterialID:      mat.MaterialID,
-			MaterialCode:    mat.MaterialCode,
-			MaterialName:    mat.MaterialName,
-			WarehouseID:     warehouseID,
-			TransactionType: entity.TxTypeProductionOut,
-			Quantity:        -needQty,
-			ReferenceType:   "WO",
-			ReferenceID:     wo.ID,
-			ReferenceCode:   wo.WOCode,
-			CreatedBy:       userID,
+		needQty := mat.RequiredQty - (mat.IssuedQty - mat.ReturnedQty)
+		if needQty <= 0 {
+			continue
 		}
-		s.inventoryRepo.CreateTransaction(tx)
+		issues = append(issues, materialIssue{mat: mat, qty: needQty})
+	}
+	if wo.IssueWHID == "" {
+		wo.IssueWHID = req.WarehouseID
+	}
+	if err := s.issueMaterials(wo, req.WarehouseID, issues, userID); err != nil {
+		return fmt.Errorf("领料失败: %w", err)
 	}
 
 	if wo.Status == entity.WOStatusReleased {
@@ -202,13 +232,21 @@ type ReportRequest struct {
 
 // Report 报工
 func (s *ManufacturingService) Report(id string, req ReportRequest, userID string) error {
-	wo, err := s.woRepo.GetByID(id)
-	if err != nil {
-		return fmt.Errorf("工单不存在: %w", err)
-	}
+	return s.inTx(id, func(t *ManufacturingService, wo *entity.WorkOrder) error {
+		return t.report(wo, req, userID)
+	})
+}
+
+func (s *ManufacturingService) report(wo *entity.WorkOrder, req ReportRequest, userID string) error {
 	if wo.Status != entity.WOStatusInProgress && wo.Status != entity.WOStatusReleased {
 		return fmt.Errorf("工单状态不允许报工: %s", wo.Status)
 	}
+	if len(wo.Operations) > 0 {
+		return fmt.Errorf("工单按工艺路线生产，请按工序报工")
+	}
+	if err := s.backflushOperation(wo, "", wo.CompletedQty+wo.ScrapQty+req.Quantity+req.ScrapQty, userID); err != nil {
+		return err
+	}
 
 	now := time.Now()
 	report := &entity.WorkOrderReport{
@@ -233,67 +271,105 @@ func (s *ManufacturingService) Report(id string, req ReportRequest, userID strin
 	return s.woRepo.Update(wo)
 }
 
+type CompleteRequest struct {
+	WarehouseID string   `json:"warehouse_id"`
+	BatchNo     string   `json:"batch_no"`
+	SerialNos   []string `json:"serial_nos"` // 成品序列号，数量须与完工数量一致
+}
+
 // Complete 完工入库
-func (s *ManufacturingService) Complete(id, warehouseID, userID string) error {
-	wo, err := s.woRepo.GetByID(id)
-	if err != nil {
-		return fmt.Errorf("工单不存在: %w", err)
-	}
+func (s *ManufacturingService) Complete(id string, req CompleteRequest, userID string) error {
+	return s.inTx(id, func(t *ManufacturingService, wo *entity.WorkOrder) error {
+		return t.complete(wo, req, userID)
+	})
+}
+
+func (s *ManufacturingService) complete(wo *entity.WorkOrder, req CompleteRequest, userID string) error {
 	if wo.Status != entity.WOStatusInProgress {
 		return fmt.Errorf("工单状态不允许完工: %s", wo.Status)
 	}
 	if wo.CompletedQty <= 0 {
 		return fmt.Errorf("尚未报工，不能完工")
 	}
+	if len(req.SerialNos) > 0 {
+		if float64(len(req.SerialNos)) != wo.CompletedQty {
+			return fmt.Errorf("序列号数量%d与完工数量%.0f不一致", len(req.SerialNos), wo.CompletedQty)
+		}
+		seen := make(map[string]bool, len(req.SerialNos))
+		for _, sn := range req.SerialNos {
+			if sn == "" || seen[sn] {
+				return fmt.Errorf("序列号为空或重复: %q", sn)
+			}
+			seen[sn] = true
+		}
+		dup, err := s.genealogyRepo.FindProducedSerials(req.SerialNos)
+		if err != nil {
+			return err
+		}
+		if len(dup) > 0 {
+			return fmt.Errorf("序列号已存在: %v", dup)
+		}
+	}
 
-	wID := warehouseID
+	wID := req.WarehouseID
 	if wID == "" {
 		wID = wo.WarehouseID
 	}
+	if err := s.backflushCompletion(wo, userID); err != nil {
+		return err
+	}
 
-	// 成品入库
+	// 成品入库：有序列号时每个序列号一笔流水
 	now := time.Now()
-	batchNo := fmt.Sprintf("FG-%s%03d", now.Format("20060102"), now.UnixNano()%1000)
-
-	existingInv, findErr := s.inventoryRepo.GetByMaterialAndWarehouse(wo.ProductID, wID)
-	if findErr == nil && existingInv != nil {
-		existingInv.Quantity += wo.CompletedQty
-		existingInv.AvailableQty += wo.CompletedQty
-		existingInv.LastMovedAt = &now
-		s.inventoryRepo.Update(existingInv)
-	} else {
-		inv := &entity.Inventory{
-			ID:            uuid.New().String(),
-			MaterialID:    wo.ProductID,
-			MaterialCode:  wo.ProductCode,
-			MaterialName:  wo.ProductName,
-			WarehouseID:   wID,
-			BatchNo:       batchNo,
-			InventoryType: entity.InventoryTypeFG,
-			Quantity:      wo.CompletedQty,
-			AvailableQty:  wo.CompletedQty,
-			Unit:          "pcs",
-			LastMovedAt:   &now,
+	batchNo := req.BatchNo
+	if batchNo == "" {
+		batchNo = fmt.Sprintf("FG-%s%03d", now.Format("20060102"), now.UnixNano()%1000)
+	}
+	newTx := func(qty float64, serialNo string) repository.StockMovement {
+		return repository.StockMovement{
+			Tx: &entity.InventoryTransaction{
+				ID:              uuid.New().String(),
+				MaterialID:      wo.ProductID,
+				MaterialCode:    wo.ProductCode,
+				MaterialName:    wo.ProductName,
+				WarehouseID:     wID,
+				TransactionType: entity.TxTypeProductionIn,
+				Quantity:        qty,
+				BatchNo:         batchNo,
+				SerialNo:        serialNo,
+				ReferenceType:   "WO",
+				ReferenceID:     wo.ID,
+				ReferenceCode:   wo.WOCode,
+				CreatedBy:       userID,
+			},
+			CreateIfMissing: true,
+			InventoryType:   entity.InventoryTypeFG,
+		}
+	}
+	var moves []repository.StockMovement
+	if len(req.SerialNos) > 0 {
+		for _, sn := range req.SerialNos {
+			moves = append(moves, newTx(1, sn))
 		}
-		s.inventoryRepo.UpsertInventory(inv)
-	}
-
-	// 库存交易记录
-	tx := &entity.InventoryTransaction{
-		ID:              uuid.New().String(),
-		MaterialID:      wo.ProductID,
-		MaterialCode:    wo.ProductCode,
-		MaterialName:    wo.ProductName,
-		WarehouseID:     wID,
-		TransactionType: entity.TxTypeProductionIn,
-		Quantity:        wo.CompletedQty,
-		BatchNo:         batchNo,
-		ReferenceType:   "WO",
-		ReferenceID:     wo.ID,
-		ReferenceCode:   wo.WOCode,
-		CreatedBy:       userID,
-	}
-	s.inventoryRepo.CreateTransaction(tx)
+	} else {
+		moves = append(moves, newTx(wo.CompletedQty, ""))
+	}
+	placed, err := s.warehouse.PlaceInbound(moves)
+	if err != nil {
+		return fmt.Errorf("完工上架失败: %w", err)
+	}
+	if _, err := s.inventoryRepo.PostMovements(placed); err != nil {
+		return fmt.Errorf("完工入库失败: %w", err)
+	}
+
+	// 记录批次谱系：工单产出的成品批次/序列号
+	links := make([]entity.LotGenealogy, 0, len(placed))
+	for _, m := range placed {
+		links = append(links, woLotLink(wo, entity.GenealogyProduce, m.Tx, m.Tx.Quantity, userID))
+	}
+	if err := s.genealogyRepo.CreateLinks(links); err != nil {
+		return fmt.Errorf("记录批次谱系失败: %w", err)
+	}
 
 	wo.Status = entity.WOStatusCompleted
 	wo.ActualEnd = &now
@@ -0,0 +1,393 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	plmEntity "github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 预测冲减窗口默认值（天），可在成品计划参数中覆盖
+const (
+	defaultConsumptionBackwardDays = 7
+	defaultConsumptionForwardDays  = 7
+)
+
+type MPSService struct {
+	mpsRepo   *repository.MPSRepository
+	mrpRepo   *repository.MRPRepository
+	salesRepo *repository.SalesRepository
+	db        *gorm.DB // 直接访问PLM产品数据
+}
+
+func NewMPSService(
+	mpsRepo *repository.MPSRepository,
+	mrpRepo *repository.MRPRepository,
+	salesRepo *repository.SalesRepository,
+	db *gorm.DB,
+) *MPSService {
+	return &MPSService{
+		mpsRepo:   mpsRepo,
+		mrpRepo:   mrpRepo,
+		salesRepo: salesRepo,
+		db:        db,
+	}
+}
+
+type SaveForecastRequest struct {
+	ProductID   string  `json:"product_id" binding:"required"`
+	PeriodType  string  `json:"period_type"`                     // WEEK/MONTH，默认MONTH
+	PeriodStart string  `json:"period_start" binding:"required"` // YYYY-MM-DD，自动对齐到周一/月初
+	Quantity    float64 `json:"quantity" binding:"gte=0"`
+	Notes       string  `json:"notes"`
+}
+
+// SaveForecast 录入预测（同产品同周期覆盖）
+func (s *MPSService) SaveForecast(req SaveForecastRequest, userID string) (*entity.DemandForecast, error) {
+	var product plmEntity.Product
+	if err := s.db.Where("id = ?", req.ProductID).First(&product).Error; err != nil {
+		return nil, fmt.Errorf("产品不存在: %s", req.ProductID)
+	}
+	return s.saveForecast(&product, req.PeriodType, req.PeriodStart, req.Quantity, req.Notes, entity.ForecastSourceManual, userID)
+}
+
+func (s *MPSService) saveForecast(product *plmEntity.Product, periodType, periodStart string, qty float64, notes, source, userID string) (*entity.DemandForecast, error) {
+	if periodType == "" {
+		periodType = entity.ForecastPeriodMonth
+	}
+	if periodType != entity.ForecastPeriodWeek && periodType != entity.ForecastPeriodMonth {
+		return nil, fmt.Errorf("无效的预测周期: %s", periodType)
+	}
+	if qty < 0 {
+		return nil, fmt.Errorf("预测数量不能为负数")
+	}
+	start, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(periodStart), time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("无效的周期开始日期: %s", periodStart)
+	}
+	if periodType == entity.ForecastPeriodWeek {
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	} else {
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+
+	f := &entity.DemandForecast{
+		ID:          uuid.New().String(),
+		ProductID:   product.ID,
+		ProductCode: product.Code,
+		PeriodType:  periodType,
+		PeriodStart: start,
+		Quantity:    qty,
+		Source:      source,
+		Notes:       notes,
+		CreatedBy:   userID,
+	}
+	if err := s.mpsRepo.UpsertForecast(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ListForecasts 预测列表
+func (s *MPSService) ListForecasts(params repository.ForecastListParams) ([]entity.DemandForecast, error) {
+	return s.mpsRepo.ListForecasts(params)
+}
+
+// DeleteForecast 删除预测
+func (s *MPSService) DeleteForecast(id string) error {
+	return s.mpsRepo.DeleteForecast(id)
+}
+
+type ForecastImportResult struct {
+	Imported int      `json:"imported"`
+	Errors   []string `json:"errors"`
+}
+
+// ImportForecastCSV 导入预测CSV，表头: product_code,period_type,period_start,quantity
+func (s *MPSService) ImportForecastCSV(r io.Reader, userID string) (*ForecastImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析CSV失败: %w", err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("CSV文件为空")
+	}
+
+	col := make(map[string]int)
+	for i, h := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, required := range []string{"product_code", "period_start", "quantity"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("CSV缺少列: %s", required)
+		}
+	}
+	get := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	result := &ForecastImportResult{}
+	products := make(map[string]*plmEntity.Product)
+	for n, row := range rows[1:] {
+		line := n + 2
+		code := get(row, "product_code")
+		product, ok := products[code]
+		if !ok {
+			var p plmEntity.Product
+			if err := s.db.Where("code = ?", code).First(&p).Error; err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("第%d行: 产品编码不存在 %s", line, code))
+				continue
+			}
+			product = &p
+			products[code] = product
+		}
+		qty, err := strconv.ParseFloat(get(row, "quantity"), 64)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("第%d行: 无效的数量", line))
+			continue
+		}
+		periodType := strings.ToUpper(get(row, "period_type"))
+		if _, err := s.saveForecast(product, periodType, get(row, "period_start"), qty, "", entity.ForecastSourceImport, userID); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("第%d行: %v", line, err))
+			continue
+		}
+		result.Imported++
+	}
+	return result, nil
+}
+
+type GenerateMPSRequest struct {
+	ProductID string `json:"product_id"` // 空=全部有预测或订单的产品
+	Horizon   int    `json:"horizon"`    // 计划范围（天），默认90
+}
+
+type GenerateMPSResult struct {
+	Products int               `json:"products"`
+	Entries  []entity.MPSEntry `json:"entries"`
+}
+
+// Generate 生成主生产计划：订单冲减预测，需求时界内只计订单，计划时界内及已确认的计划保持不变
+func (s *MPSService) Generate(req GenerateMPSRequest) (*GenerateMPSResult, error) {
+	if req.Horizon <= 0 {
+		req.Horizon = 90
+	}
+	now := time.Now()
+	buckets := newMRPBuckets(now, req.Horizon, entity.MRPBucketWeek)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	horizonEnd := today.AddDate(0, 0, req.Horizon)
+
+	forecasts, err := s.mpsRepo.ListForecasts(repository.ForecastListParams{ProductID: req.ProductID, To: &horizonEnd})
+	if err != nil {
+		return nil, fmt.Errorf("获取预测失败: %w", err)
+	}
+	lines, err := s.salesRepo.GetOpenDemandLines()
+	if err != nil {
+		return nil, fmt.Errorf("获取销售需求失败: %w", err)
+	}
+
+	forecastByProduct := make(map[string][]entity.DemandForecast)
+	for _, f := range forecasts {
+		if !f.PeriodEnd().Before(buckets.start) {
+			forecastByProduct[f.ProductID] = append(forecastByProduct[f.ProductID], f)
+		}
+	}
+	linesByProduct := make(map[string][]repository.DemandLine)
+	for _, l := range lines {
+		if req.ProductID == "" || l.ProductID == req.ProductID {
+			linesByProduct[l.ProductID] = append(linesByProduct[l.ProductID], l)
+		}
+	}
+
+	productIDs := make([]string, 0)
+	for id := range forecastByProduct {
+		productIDs = append(productIDs, id)
+	}
+	for id := range linesByProduct {
+		if _, ok := forecastByProduct[id]; !ok {
+			productIDs = append(productIDs, id)
+		}
+	}
+	if len(productIDs) == 0 {
+		return &GenerateMPSResult{}, nil
+	}
+
+	var products []plmEntity.Product
+	if err := s.db.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("获取产品失败: %w", err)
+	}
+	params, err := s.mrpRepo.GetPlanningParams(productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("获取计划参数失败: %w", err)
+	}
+
+	result := &GenerateMPSResult{}
+	for _, product := range products {
+		p := params[product.ID]
+		backward, forward := defaultConsumptionBackwardDays, defaultConsumptionForwardDays
+		if p.ConsumptionBackwardDays != nil {
+			backward = *p.ConsumptionBackwardDays
+		}
+		if p.ConsumptionForwardDays != nil {
+			forward = *p.ConsumptionForwardDays
+		}
+		demandFence := today.AddDate(0, 0, p.DemandTimeFenceDays)
+		planningFence := today.AddDate(0, 0, p.PlanningTimeFenceDays)
+
+		// 预测按日均摊到周
+		fc := make([]float64, buckets.count)
+		for _, f := range forecastByProduct[product.ID] {
+			end := f.PeriodEnd()
+			days := int(end.Sub(f.PeriodStart).Hours()/24) + 1
+			daily := f.Quantity / float64(days)
+			for d := f.PeriodStart; !d.After(end); d = d.AddDate(0, 0, 1) {
+				if d.Before(buckets.start) {
+					continue
+				}
+				if idx := buckets.index(&d); idx >= 0 {
+					fc[idx] += daily
+				}
+			}
+		}
+
+		// 订单冲减预测：先本期，再向前窗口，最后向后窗口
+		orders := make([]float64, buckets.count)
+		consumed := make([]float64, buckets.count)
+		back := int(math.Ceil(float64(backward) / float64(buckets.days)))
+		fwd := int(math.Ceil(float64(forward) / float64(buckets.days)))
+		for _, l := range linesByProduct[product.ID] {
+			idx := buckets.index(l.DueDate)
+			if idx < 0 {
+				continue
+			}
+			orders[idx] += l.Quantity
+			remaining := l.Quantity
+			windows := []int{idx}
+			for k := 1; k <= back; k++ {
+				windows = append(windows, idx-k)
+			}
+			for k := 1; k <= fwd; k++ {
+				windows = append(windows, idx+k)
+			}
+			for _, t := range windows {
+				if remaining <= 0 {
+					break
+				}
+				if t < 0 || t >= buckets.count {
+					continue
+				}
+				take := math.Min(remaining, fc[t]-consumed[t])
+				if take > 0 {
+					consumed[t] += take
+					remaining -= take
+				}
+			}
+		}
+
+		existing, err := s.mpsRepo.GetEntriesInRange(product.ID, buckets.start, horizonEnd)
+		if err != nil {
+			return nil, err
+		}
+		kept := make(map[string]entity.MPSEntry)
+		var keepIDs []string
+		for _, e := range existing {
+			if e.Firm || e.PeriodStart.Before(planningFence) {
+				kept[e.PeriodStart.Format("2006-01-02")] = e
+				keepIDs = append(keepIDs, e.ID)
+			}
+		}
+
+		var entries []entity.MPSEntry
+		for t := 0; t < buckets.count; t++ {
+			start, end := buckets.bounds(t)
+			if _, ok := kept[start.Format("2006-01-02")]; ok {
+				continue
+			}
+			inDTF := start.Before(demandFence)
+			qty := orders[t]
+			if !inDTF {
+				qty += fc[t] - consumed[t]
+			}
+			if qty <= 1e-9 && fc[t] == 0 && orders[t] == 0 {
+				continue
+			}
+			entries = append(entries, entity.MPSEntry{
+				ID:              uuid.New().String(),
+				ProductID:       product.ID,
+				ProductCode:     product.Code,
+				ProductName:     product.Name,
+				PeriodStart:     start,
+				PeriodEnd:       end,
+				ForecastQty:     math.Round(fc[t]*10000) / 10000,
+				ConsumedQty:     math.Round(consumed[t]*10000) / 10000,
+				OrderQty:        orders[t],
+				Quantity:        math.Round(qty*10000) / 10000,
+				InDemandFence:   inDTF,
+				InPlanningFence: start.Before(planningFence),
+				GeneratedAt:     now,
+			})
+		}
+
+		if err := s.mpsRepo.ReplaceEntries(product.ID, buckets.start, keepIDs, entries); err != nil {
+			return nil, fmt.Errorf("保存MPS失败: %w", err)
+		}
+		result.Products++
+		result.Entries = append(result.Entries, entries...)
+	}
+	return result, nil
+}
+
+// ListEntries MPS列表
+func (s *MPSService) ListEntries(productID string, horizon int) ([]entity.MPSEntry, error) {
+	if horizon <= 0 {
+		horizon = 90
+	}
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return s.mpsRepo.GetEntriesInRange(productID, from, from.AddDate(0, 0, horizon))
+}
+
+type FirmMPSRequest struct {
+	Quantity *float64 `json:"quantity"` // 调整计划数量，空=保持
+	Firm     bool     `json:"firm"`
+}
+
+// FirmEntry 确认/取消确认MPS计划
+func (s *MPSService) FirmEntry(id string, req FirmMPSRequest, userID string) (*entity.MPSEntry, error) {
+	e, err := s.mpsRepo.GetEntryByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("MPS计划不存在: %w", err)
+	}
+	if req.Quantity != nil {
+		if *req.Quantity < 0 {
+			return nil, fmt.Errorf("计划数量不能为负数")
+		}
+		e.Quantity = *req.Quantity
+	}
+	e.Firm = req.Firm
+	if req.Firm {
+		now := time.Now()
+		e.FirmedBy = userID
+		e.FirmedAt = &now
+	} else {
+		e.FirmedBy = ""
+		e.FirmedAt = nil
+	}
+	if err := s.mpsRepo.UpdateEntry(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+)
+
+// mrpCalcOptions MRP计算选项：模拟场景、净变更基准
+type mrpCalcOptions struct {
+	scenario    *MRPScenario
+	baseRunID   string
+	changed     map[string]bool                // 净变更：有变动的物料，nil=全重排
+	baseResults map[string][]entity.MRPResult  // 基准运行结果，按物料ID
+	basePegs    map[string][]entity.MRPPegging // 基准运行追溯，按结果ID
+}
+
+// MRPScenario 模拟场景：覆盖需求、提前期、库存，结果不生成采购需求/工单
+type MRPScenario struct {
+	DemandOverrides   []DemandOverride   `json:"demand_overrides"`
+	LeadTimeOverrides map[string]int     `json:"lead_time_overrides"` // 物料ID → 提前期（天）
+	StockOverrides    map[string]float64 `json:"stock_overrides"`     // 物料ID → 现有库存
+}
+
+// DemandOverride 需求覆盖：指定订单/订单行按倍数或数量调整；仅指定产品时按倍数调整该产品全部需求，或新增一笔模拟需求
+type DemandOverride struct {
+	SOID      string  `json:"so_id"`
+	SOItemID  string  `json:"so_item_id"`
+	ProductID string  `json:"product_id"`
+	Factor    float64 `json:"factor"`   // 数量倍数，如2=翻倍
+	Quantity  float64 `json:"quantity"` // 覆盖数量；仅指定产品时为新增需求数量
+	DueDate   string  `json:"due_date"` // YYYY-MM-DD，覆盖交期
+}
+
+func (sc *MRPScenario) validate() error {
+	for i, o := range sc.DemandOverrides {
+		if o.SOID == "" && o.SOItemID == "" && o.ProductID == "" {
+			return fmt.Errorf("需求覆盖第%d项需指定订单、订单行或产品", i+1)
+		}
+		if o.Factor < 0 || o.Quantity < 0 {
+			return fmt.Errorf("需求覆盖第%d项倍数/数量不能为负数", i+1)
+		}
+		if o.Factor == 0 && o.Quantity == 0 && o.DueDate == "" {
+			return fmt.Errorf("需求覆盖第%d项未设置调整内容", i+1)
+		}
+		if o.DueDate != "" {
+			if _, err := time.ParseInLocation("2006-01-02", o.DueDate, time.Local); err != nil {
+				return fmt.Errorf("需求覆盖第%d项交期格式错误: %s", i+1, o.DueDate)
+			}
+		}
+	}
+	for id, days := range sc.LeadTimeOverrides {
+		if days < 0 {
+			return fmt.Errorf("物料%s提前期不能为负数", id)
+		}
+	}
+	for id, qty := range sc.StockOverrides {
+		if qty < 0 {
+			return fmt.Errorf("物料%s库存不能为负数", id)
+		}
+	}
+	return nil
+}
+
+// applyDemand 按场景调整销售需求行（nil场景原样返回）
+func (sc *MRPScenario) applyDemand(lines []repository.DemandLine) ([]repository.DemandLine, error) {
+	if sc == nil || len(sc.DemandOverrides) == 0 {
+		return lines, nil
+	}
+	out := make([]repository.DemandLine, len(lines))
+	copy(out, lines)
+	for i, o := range sc.DemandOverrides {
+		var due *time.Time
+		if o.DueDate != "" {
+			d, _ := time.ParseInLocation("2006-01-02", o.DueDate, time.Local)
+			due = &d
+		}
+
+		// 仅指定产品且给出数量：新增一笔模拟需求
+		if o.SOID == "" && o.SOItemID == "" && o.Factor == 0 && o.Quantity > 0 {
+			out = append(out, repository.DemandLine{
+				SOCode: "SIMULATION", ProductID: o.ProductID, Quantity: o.Quantity, DueDate: due,
+			})
+			continue
+		}
+
+		matched := 0
+		for j := range out {
+			l := &out[j]
+			if (o.SOID != "" && l.SOID != o.SOID) || (o.SOItemID != "" && l.SOItemID != o.SOItemID) ||
+				(o.ProductID != "" && l.ProductID != o.ProductID) {
+				continue
+			}
+			matched++
+			if o.Factor > 0 {
+				l.Quantity *= o.Factor
+			} else if o.Quantity > 0 {
+				l.Quantity = o.Quantity
+			}
+			if due != nil {
+				l.DueDate = due
+			}
+		}
+		if matched == 0 {
+			return nil, fmt.Errorf("需求覆盖第%d项未匹配到未交付的销售订单行", i+1)
+		}
+	}
+	return out, nil
+}
+
+func (sc *MRPScenario) stockOverride(materialID string) (float64, bool) {
+	if sc == nil {
+		return 0, false
+	}
+	qty, ok := sc.StockOverrides[materialID]
+	return qty, ok
+}
+
+func (sc *MRPScenario) leadTimeOverride(materialID string) (int, bool) {
+	if sc == nil {
+		return 0, false
+	}
+	days, ok := sc.LeadTimeOverrides[materialID]
+	return days, ok
+}
+
+// prepareNetChange 查找基准运行，收集其后有变动的物料及基准结果
+func (s *MRPService) prepareNetChange(req RunMRPRequest, now time.Time, opts *mrpCalcOptions) (*entity.MRPRun, error) {
+	base, err := s.mrpRepo.GetLatestBaseRun(req.ProductID, req.BucketType, req.PlanningHorizon)
+	if err != nil {
+		return nil, fmt.Errorf("没有相同计划范围的基准运行，请先执行全重排MRP")
+	}
+	// 时间段滚动后基准结果不再对齐，需要全重排
+	prev := newMRPBuckets(base.StartedAt, base.PlanningHorizon, base.BucketType)
+	cur := newMRPBuckets(now, req.PlanningHorizon, req.BucketType)
+	if !prev.start.Equal(cur.start) || prev.count != cur.count {
+		return nil, fmt.Errorf("计划时间段已滚动（基准运行 %s），请执行全重排MRP", base.RunCode)
+	}
+
+	changedIDs, err := s.mrpRepo.GetChangedItemIDs(base.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("获取变动物料失败: %w", err)
+	}
+	results, err := s.mrpRepo.GetResultsByRunID(base.ID)
+	if err != nil {
+		return nil, fmt.Errorf("获取基准运行结果失败: %w", err)
+	}
+	pegs, err := s.mrpRepo.GetPeggingsByRunID(base.ID)
+	if err != nil {
+		return nil, fmt.Errorf("获取基准运行追溯失败: %w", err)
+	}
+
+	opts.changed = make(map[string]bool, len(changedIDs))
+	for _, id := range changedIDs {
+		opts.changed[id] = true
+	}
+	opts.baseResults = make(map[string][]entity.MRPResult)
+	for _, r := range results {
+		opts.baseResults[r.MaterialID] = append(opts.baseResults[r.MaterialID], r)
+	}
+	for id := range opts.baseResults {
+		rows := opts.baseResults[id]
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].BucketStart == nil || rows[j].BucketStart == nil {
+				return rows[j].BucketStart != nil
+			}
+			return rows[i].BucketStart.Before(*rows[j].BucketStart)
+		})
+	}
+	opts.basePegs = make(map[string][]entity.MRPPegging)
+	for _, p := range pegs {
+		opts.basePegs[p.MRPResultID] = append(opts.basePegs[p.MRPResultID], p)
+	}
+	return base, nil
+}
+
+// MRPCompareItem 两次运行按物料汇总的差异
+type MRPCompareItem struct {
+	MaterialID      string     `json:"material_id"`
+	MaterialCode    string     `json:"material_code"`
+	MaterialName    string     `json:"material_name"`
+	ActionType      string     `json:"action_type"`
+	GrossA          float64    `json:"gross_a"`
+	GrossB          float64    `json:"gross_b"`
+	NetA            float64    `json:"net_a"`
+	NetB            float64    `json:"net_b"`
+	PlannedA        float64    `json:"planned_a"`
+	PlannedB        float64    `json:"planned_b"`
+	PlannedDelta    float64    `json:"planned_delta"`
+	OrdersA         int        `json:"orders_a"`
+	OrdersB         int        `json:"orders_b"`
+	FirstOrderDateA *time.Time `json:"first_order_date_a"`
+	FirstOrderDateB *time.Time `json:"first_order_date_b"`
+	PastDueA        bool       `json:"past_due_a"`
+	PastDueB        bool       `json:"past_due_b"`
+	Status          string     `json:"status"` // ADDED, REMOVED, CHANGED, UNCHANGED
+}
+
+// MRPRunComparison 两次MRP运行对比
+type MRPRunComparison struct {
+	RunA    *entity.MRPRun   `json:"run_a"`
+	RunB    *entity.MRPRun   `json:"run_b"`
+	Items   []MRPCompareItem `json:"items"`
+	Summary struct {
+		Added        int     `json:"added"`
+		Removed      int     `json:"removed"`
+		Changed      int     `json:"changed"`
+		Unchanged    int     `json:"unchanged"`
+		PlannedDelta float64 `json:"planned_delta"`
+	} `json:"summary"`
+}
+
+// CompareRuns 对比两次MRP运行（如模拟与实际），按物料汇总毛需求、净需求和计划订单
+func (s *MRPService) CompareRuns(runAID, runBID string, changedOnly bool) (*MRPRunComparison, error) {
+	runA, err := s.mrpRepo.GetRunByID(runAID)
+	if err != nil {
+		return nil, fmt.Errorf("MRP运行记录不存在: %s", runAID)
+	}
+	runB, err := s.mrpRepo.GetRunByID(runBID)
+	if err != nil {
+		return nil, fmt.Errorf("MRP运行记录不存在: %s", runBID)
+	}
+	resultsA, err := s.mrpRepo.GetResultsByRunID(runA.ID)
+	if err != nil {
+		return nil, err
+	}
+	resultsB, err := s.mrpRepo.GetResultsByRunID(runB.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byMaterial := make(map[string]*MRPCompareItem)
+	var order []string
+	get := func(r entity.MRPResult) *MRPCompareItem {
+		item, ok := byMaterial[r.MaterialID]
+		if !ok {
+			item = &MRPCompareItem{
+				MaterialID: r.MaterialID, MaterialCode: r.MaterialCode, MaterialName: r.MaterialName, ActionType: r.ActionType,
+			}
+			byMaterial[r.MaterialID] = item
+			order = append(order, r.MaterialID)
+		}
+		return item
+	}
+	earliest := func(cur, d *time.Time) *time.Time {
+		if d == nil || (cur != nil && !d.Before(*cur)) {
+			return cur
+		}
+		return d
+	}
+	for _, r := range resultsA {
+		item := get(r)
+		item.GrossA += r.GrossRequirement
+		item.NetA += r.NetRequirement
+		item.PlannedA += r.PlannedOrderQty
+		if r.PlannedOrderQty > 0 {
+			item.OrdersA++
+			item.FirstOrderDateA = earliest(item.FirstOrderDateA, r.OrderDate)
+			item.PastDueA = item.PastDueA || r.PastDue
+		}
+	}
+	for _, r := range resultsB {
+		item := get(r)
+		item.GrossB += r.GrossRequirement
+		item.NetB += r.NetRequirement
+		item.PlannedB += r.PlannedOrderQty
+		if r.PlannedOrderQty > 0 {
+			item.OrdersB++
+			item.FirstOrderDateB = earliest(item.FirstOrderDateB, r.OrderDate)
+			item.PastDueB = item.PastDueB || r.PastDue
+		}
+	}
+
+	cmp := &MRPRunComparison{RunA: runA, RunB: runB, Items: make([]MRPCompareItem, 0, len(order))}
+	for _, id := range order {
+		item := byMaterial[id]
+		item.PlannedDelta = item.PlannedB - item.PlannedA
+		sameDate := (item.FirstOrderDateA == nil) == (item.FirstOrderDateB == nil) &&
+			(item.FirstOrderDateA == nil || item.FirstOrderDateA.Equal(*item.FirstOrderDateB))
+		switch {
+		case item.OrdersA == 0 && item.OrdersB > 0:
+			item.Status = "ADDED"
+			cmp.Summary.Added++
+		case item.OrdersA > 0 && item.OrdersB == 0:
+			item.Status = "REMOVED"
+			cmp.Summary.Removed++
+		case math.Abs(item.PlannedDelta) > 1e-6 || math.Abs(item.GrossB-item.GrossA) > 1e-6 || !sameDate:
+			item.Status = "CHANGED"
+			cmp.Summary.Changed++
+		default:
+			item.Status = "UNCHANGED"
+			cmp.Summary.Unchanged++
+		}
+		cmp.Summary.PlannedDelta += item.PlannedDelta
+		if changedOnly && item.Status == "UNCHANGED" {
+			continue
+		}
+		cmp.Items = append(cmp.Items, *item)
+	}
+	sort.SliceStable(cmp.Items, func(i, j int) bool {
+		di, dj := math.Abs(cmp.Items[i].PlannedDelta), math.Abs(cmp.Items[j].PlannedDelta)
+		if di != dj {
+			return di > dj
+		}
+		return cmp.Items[i].MaterialCode < cmp.Items[j].MaterialCode
+	})
+	return cmp, nil
+}
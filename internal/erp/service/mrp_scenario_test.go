@@ -0,0 +1,105 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+)
+
+func scenarioLines() []repository.DemandLine {
+	due := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	return []repository.DemandLine{
+		{SOID: "so-1", SOCode: "SO-1", SOItemID: "so-1-1", ProductID: "fg-a", Quantity: 10, DueDate: &due},
+		{SOID: "so-1", SOCode: "SO-1", SOItemID: "so-1-2", ProductID: "fg-b", Quantity: 4, DueDate: &due},
+		{SOID: "so-2", SOCode: "SO-2", SOItemID: "so-2-1", ProductID: "fg-a", Quantity: 6, DueDate: &due},
+	}
+}
+
+// TestMRPScenarioApplyDemand 模拟场景按订单、订单行、产品调整需求，仅指定产品和数量时新增模拟需求
+func TestMRPScenarioApplyDemand(t *testing.T) {
+	cases := []struct {
+		name     string
+		override DemandOverride
+		wantQty  []float64 // 各需求行数量，含新增行
+		wantDue  string    // 非空时校验第一行交期
+		wantErr  bool
+	}{
+		{name: "产品需求翻倍", override: DemandOverride{ProductID: "fg-a", Factor: 2}, wantQty: []float64{20, 4, 12}},
+		{name: "订单按数量覆盖", override: DemandOverride{SOID: "so-1", Quantity: 3}, wantQty: []float64{3, 3, 6}},
+		{name: "订单行只改交期", override: DemandOverride{SOItemID: "so-1-1", DueDate: "2026-04-01"}, wantQty: []float64{10, 4, 6}, wantDue: "2026-04-01"},
+		{name: "新增产品模拟需求", override: DemandOverride{ProductID: "fg-c", Quantity: 5}, wantQty: []float64{10, 4, 6, 5}},
+		{name: "未匹配订单报错", override: DemandOverride{SOID: "so-9", Factor: 2}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := scenarioLines()
+			sc := &MRPScenario{DemandOverrides: []DemandOverride{tc.override}}
+			if err := sc.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			got, err := sc.applyDemand(lines)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error for an unmatched override")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyDemand: %v", err)
+			}
+			if len(got) != len(tc.wantQty) {
+				t.Fatalf("expected %d lines, got %d", len(tc.wantQty), len(got))
+			}
+			for i, l := range got {
+				if l.Quantity != tc.wantQty[i] {
+					t.Fatalf("line %d: quantity %v, want %v", i, l.Quantity, tc.wantQty[i])
+				}
+			}
+			if tc.wantDue != "" && got[0].DueDate.Format("2006-01-02") != tc.wantDue {
+				t.Fatalf("expected due date %s, got %s", tc.wantDue, got[0].DueDate.Format("2006-01-02"))
+			}
+			if lines[0].Quantity != 10 {
+				t.Fatalf("scenario must not modify the actual demand lines, got %v", lines[0].Quantity)
+			}
+		})
+	}
+}
+
+// TestMRPScenarioOverrides 库存、提前期覆盖只作用于指定物料；非法场景在运行前拒绝
+func TestMRPScenarioOverrides(t *testing.T) {
+	sc := &MRPScenario{
+		StockOverrides:    map[string]float64{"rm-1": 0},
+		LeadTimeOverrides: map[string]int{"rm-1": 3},
+	}
+	if qty, ok := sc.stockOverride("rm-1"); !ok || qty != 0 {
+		t.Fatalf("expected stock override 0 for rm-1, got %v %v", qty, ok)
+	}
+	if _, ok := sc.stockOverride("rm-2"); ok {
+		t.Fatal("expected no stock override for rm-2")
+	}
+	if days, ok := sc.leadTimeOverride("rm-1"); !ok || days != 3 {
+		t.Fatalf("expected lead time override 3 for rm-1, got %v %v", days, ok)
+	}
+	var none *MRPScenario
+	if _, ok := none.stockOverride("rm-1"); ok {
+		t.Fatal("nil scenario must not override stock")
+	}
+	if lines, err := none.applyDemand(scenarioLines()); err != nil || len(lines) != 3 {
+		t.Fatalf("nil scenario must keep the demand lines, got %d / %v", len(lines), err)
+	}
+
+	invalid := []*MRPScenario{
+		{DemandOverrides: []DemandOverride{{Factor: 2}}},
+		{DemandOverrides: []DemandOverride{{ProductID: "fg-a"}}},
+		{DemandOverrides: []DemandOverride{{ProductID: "fg-a", Factor: -1}}},
+		{DemandOverrides: []DemandOverride{{ProductID: "fg-a", DueDate: "2026/04/01"}}},
+		{LeadTimeOverrides: map[string]int{"rm-1": -1}},
+		{StockOverrides: map[string]float64{"rm-1": -5}},
+	}
+	for i, sc := range invalid {
+		if err := sc.validate(); err == nil {
+			t.Errorf("scenario %d: expected a validation error", i)
+		}
+	}
+}
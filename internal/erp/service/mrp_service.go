@@ -1,7 +1,12 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/erp/entity"
@@ -17,7 +22,9 @@ type MRPService struct {
 	inventoryRepo *repository.InventoryRepository
 	woRepo        *repository.WorkOrderRepository
 	salesRepo     *repository.SalesRepository
-	db            *gorm.DB // 直接访问PLM数据
+	mpsRepo       *repository.MPSRepository
+	manufacturing *ManufacturingService // 应用MRP时生成工单
+	db            *gorm.DB              // 直接访问PLM数据
 }
 
 func NewMRPService(
@@ -26,6 +33,7 @@ func NewMRPService(
 	inventoryRepo *repository.InventoryRepository,
 	woRepo *repository.WorkOrderRepository,
 	salesRepo *repository.SalesRepository,
+	mpsRepo *repository.MPSRepository,
 	db *gorm.DB,
 ) *MRPService {
 	return &MRPService{
@@ -34,13 +42,24 @@ func NewMRPService(
 		inventoryRepo: inventoryRepo,
 		woRepo:        woRepo,
 		salesRepo:     salesRepo,
+		mpsRepo:       mpsRepo,
 		db:            db,
 	}
 }
 
+// SetManufacturing 注入工单服务，应用MRP结果时为自制件生成工单
+func (s *MRPService) SetManufacturing(manufacturing *ManufacturingService) {
+	s.manufacturing = manufacturing
+}
+
 type RunMRPRequest struct {
 	ProductID       string `json:"product_id"`       // 指定产品，空=全部
 	PlanningHorizon int    `json:"planning_horizon"` // 计划范围（天），默认30
+	BucketType      string `json:"bucket_type"`      // 时间段：DAY/WEEK，默认WEEK
+	RunType         string `json:"run_type"`         // REGENERATIVE/NET_CHANGE/SIMULATION，默认REGENERATIVE
+	Description     string `json:"description"`
+
+	Scenario *MRPScenario `json:"scenario"` // 模拟场景，仅SIMULATION
 }
 
 // Run 执行MRP计算
@@ -48,8 +67,41 @@ func (s *MRPService) Run(req RunMRPRequest, userID string) (*entity.MRPRun, erro
 	if req.PlanningHorizon <= 0 {
 		req.PlanningHorizon = 30
 	}
+	if req.BucketType != entity.MRPBucketDay {
+		req.BucketType = entity.MRPBucketWeek
+	}
+	if req.RunType == "" {
+		req.RunType = entity.MRPRunRegenerative
+	}
+
+	if req.Scenario != nil && req.RunType != entity.MRPRunSimulation {
+		return nil, fmt.Errorf("仅模拟运行可设置场景")
+	}
 
 	now := time.Now()
+	opts := mrpCalcOptions{}
+	var scenarioJSON string
+	switch req.RunType {
+	case entity.MRPRunRegenerative:
+	case entity.MRPRunSimulation:
+		if req.Scenario != nil {
+			if err := req.Scenario.validate(); err != nil {
+				return nil, err
+			}
+			b, _ := json.Marshal(req.Scenario)
+			scenarioJSON = string(b)
+		}
+		opts.scenario = req.Scenario
+	case entity.MRPRunNetChange:
+		base, err := s.prepareNetChange(req, now, &opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.baseRunID = base.ID
+	default:
+		return nil, fmt.Errorf("无效的运行类型: %s", req.RunType)
+	}
+
 	runCode := fmt.Sprintf("MRP-%s%04d", now.Format("20060102"), now.UnixNano()%10000)
 
 	run := &entity.MRPRun{
@@ -58,6 +110,12 @@ func (s *MRPService) Run(req RunMRPRequest, userID string) (*entity.MRPRun, erro
 		Status:          entity.MRPStatusRunning,
 		ProductID:       req.ProductID,
 		PlanningHorizon: req.PlanningHorizon,
+		BucketType:      req.BucketType,
+		DemandSource:    entity.MRPDemandSourceSO,
+		RunType:         req.RunType,
+		BaseRunID:       opts.baseRunID,
+		Scenario:        scenarioJSON,
+		Description:     req.Description,
 		StartedAt:       now,
 		CreatedBy:       userID,
 	}
@@ -66,7 +124,7 @@ func (s *MRPService) Run(req RunMRPRequest, userID string) (*entity.MRPRun, erro
 	}
 
 	// 异步执行计算（但在当前简单实现中同步完成）
-	results, err := s.calculate(run)
+	results, pegs, err := s.calculate(run, opts)
 	if err != nil {
 		run.Status = entity.MRPStatusFailed
 		run.ErrorMessage = err.Error()
@@ -76,9 +134,13 @@ func (s *MRPService) Run(req RunMRPRequest, userID string) (*entity.MRPRun, erro
 		return run, fmt.Errorf("MRP计算失败: %w", err)
 	}
 
-	// 保存结果
+	// 保存结果及需求追溯
 	if len(results) > 0 {
-		if err := s.mrpRepo.BatchCreateResults(results); err != nil {
+		err := s.mrpRepo.BatchCreateResults(results)
+		if err == nil {
+			err = s.mrpRepo.BatchCreatePeggings(pegs)
+		}
+		if err != nil {
 			run.Status = entity.MRPStatusFailed
 			run.ErrorMessage = err.Error()
 			s.mrpRepo.UpdateRun(run)
@@ -95,154 +157,595 @@ func (s *MRPService) Run(req RunMRPRequest, userID string) (*entity.MRPRun, erro
 	return run, nil
 }
 
-// materialReq 物料需求汇总结构
-type materialReq struct {
-	MaterialID   string
-	MaterialCode string
-	MaterialName string
-	GrossReq     float64
-	SafetyStock  float64
-	LeadTimeDays int
+// maxBOMDepth BOM展开最大层数（防止循环引用）
+const maxBOMDepth = 30
+
+// mrpBuckets MRP计划时间段
+type mrpBuckets struct {
+	start time.Time
+	days  int
+	count int
+}
+
+// newMRPBuckets 从今天（周段从本周一）开始切分计划范围
+func newMRPBuckets(now time.Time, horizon int, bucketType string) mrpBuckets {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := 1
+	if bucketType == entity.MRPBucketWeek {
+		days = 7
+		offset := (int(start.Weekday()) + 6) % 7 // 周一为0
+		start = start.AddDate(0, 0, -offset)
+	}
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, horizon)
+	span := int(end.Sub(start).Hours()/24) + 1
+	return mrpBuckets{start: start, days: days, count: (span + days - 1) / days}
+}
+
+// index 日期所在时间段；早于起点或无日期归入第一段，超出计划范围返回-1
+func (b mrpBuckets) index(t *time.Time) int {
+	if t == nil || t.Before(b.start) {
+		return 0
+	}
+	i := int(t.Sub(b.start).Hours()/24) / b.days
+	if i >= b.count {
+		return -1
+	}
+	return i
+}
+
+func (b mrpBuckets) bounds(i int) (time.Time, time.Time) {
+	s := b.start.AddDate(0, 0, i*b.days)
+	return s, s.AddDate(0, 0, b.days).Add(-time.Second)
+}
+
+// demandEntry 某时间段的一笔毛需求及其来源
+type demandEntry struct {
+	Qty            float64
+	DueDate        time.Time
+	SOID           string
+	SOCode         string
+	SOItemID       string
+	DemandType     string
+	MPSEntryID     string
+	ParentResultID string
+}
+
+// supplyLot 可分配供应（plannedIdx<0 为库存/计划接收）
+type supplyLot struct {
+	qty        float64
+	plannedIdx int
+}
+
+// planItem 参与计划的成品/物料
+type planItem struct {
+	ID           string
+	Code         string
+	Name         string
 	Unit         string
-	ActionType   string // PURCHASE or PRODUCE
+	ActionType   string
+	LLC          int
+	LeadTimeDays int
+	SafetyStock  float64
+	MinOrderQty  float64
+	UnitCost     float64
+	gross        [][]demandEntry
 }
 
-// calculate 核心MRP计算逻辑
-func (s *MRPService) calculate(run *entity.MRPRun) ([]entity.MRPResult, error) {
-	// Step 1: 获取销售需求（按产品汇总）
-	demand, err := s.salesRepo.GetPendingDemand()
+// calculate 时段化MRP：按低层码逐层净算，提前期逐层偏置，计划订单全程追溯到销售订单行
+func (s *MRPService) calculate(run *entity.MRPRun, opts mrpCalcOptions) ([]entity.MRPResult, []entity.MRPPegging, error) {
+	now := time.Now()
+	buckets := newMRPBuckets(now, run.PlanningHorizon, run.BucketType)
+
+	// Step 1: 独立需求（销售订单行），模拟运行按场景覆盖
+	lines, err := s.salesRepo.GetOpenDemandLines()
 	if err != nil {
-		return nil, fmt.Errorf("获取销售需求失败: %w", err)
+		return nil, nil, fmt.Errorf("获取销售需求失败: %w", err)
 	}
-
-	// 如果指定了产品，只计算该产品
-	if run.ProductID != "" {
-		if qty, ok := demand[run.ProductID]; ok {
-			demand = map[string]float64{run.ProductID: qty}
-		} else {
-			// 即使没有销售需求，也按安全库存检查
-			demand = map[string]float64{run.ProductID: 0}
-		}
+	if lines, err = opts.scenario.applyDemand(lines); err != nil {
+		return nil, nil, err
 	}
 
-	// Step 2: 获取所有已发布的BOM
-	var allProducts []plmEntity.Product
+	// Step 2: 计划范围内的产品
+	var products []plmEntity.Product
+	productQuery := s.db.Where("status = 'active'")
 	if run.ProductID != "" {
-		s.db.Where("id = ?", run.ProductID).Find(&allProducts)
-	} else {
-		s.db.Where("status = 'active'").Find(&allProducts)
+		productQuery = s.db.Where("id = ?", run.ProductID)
+	}
+	if err := productQuery.Find(&products).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取产品失败: %w", err)
 	}
 
-	materialReqs := make(map[string]*materialReq)
-
-	// Step 3: BOM展开计算毛需求
-	for _, product := range allProducts {
-		demandQty := demand[product.ID]
-
-		// 获取该产品的最新已发布BOM
+	// Step 3: 读取已发布BOM，构建单层结构
+	items := make(map[string]*planItem)
+	edges := make(map[string]map[string]float64) // 父项 → 子物料 → 单位用量
+	owner := make(map[string]string)             // 半成品结构来源（首个出现的BOM行）
+	var childIDs []string
+	for _, product := range products {
 		var bomHeader plmEntity.BOMHeader
-		if err := s.db.Where("product_id = ? AND status = 'released'", product.ID).
-			Order("created_at DESC").First(&bomHeader).Error; err != nil {
+		err := s.db.Where("product_id = ? AND status = 'released'", product.ID).
+			Order("created_at DESC").First(&bomHeader).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			continue // 没有已发布BOM，跳过
 		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取产品 %s 的BOM失败: %w", product.Code, err)
+		}
+		items[product.ID] = &planItem{ID: product.ID, Code: product.Code, Name: product.Name, Unit: "pcs", ActionType: "PRODUCE"}
 
-		// 获取BOM项
 		var bomItems []plmEntity.BOMItem
-		s.db.Where("bom_header_id = ?", bomHeader.ID).Find(&bomItems)
+		if err := s.db.Where("bom_header_id = ?", bomHeader.ID).Order("level, sequence").Find(&bomItems).Error; err != nil {
+			return nil, nil, fmt.Errorf("读取产品 %s 的BOM明细失败: %w", product.Code, err)
+		}
+		byID := make(map[string]plmEntity.BOMItem, len(bomItems))
+		for _, it := range bomItems {
+			byID[it.ID] = it
+		}
+		for _, it := range bomItems {
+			parent := product.ID
+			if it.ParentItemID != "" {
+				p, ok := byID[it.ParentItemID]
+				if !ok {
+					continue
+				}
+				parent = p.MaterialID
+				if src, ok := owner[parent]; ok && src != it.ParentItemID {
+					continue // 同一半成品结构只取一次
+				}
+				owner[parent] = it.ParentItemID
+			}
+			if edges[parent] == nil {
+				edges[parent] = make(map[string]float64)
+			}
+			if _, seen := edges[parent][it.MaterialID]; !seen {
+				childIDs = append(childIDs, it.MaterialID)
+			}
+			edges[parent][it.MaterialID] += it.Quantity
+		}
+	}
 
-		// 展开BOM，计算每个物料的需求
-		s.expandBOM(bomItems, demandQty, materialReqs)
+	if len(childIDs) > 0 {
+		var mats []plmEntity.Material
+		if err := s.db.Where("id IN ?", childIDs).Find(&mats).Error; err != nil {
+			return nil, nil, fmt.Errorf("获取物料主数据失败: %w", err)
+		}
+		for _, mat := range mats {
+			if _, ok := items[mat.ID]; ok {
+				continue
+			}
+			items[mat.ID] = &planItem{
+				ID: mat.ID, Code: mat.Code, Name: mat.Name, Unit: mat.Unit, ActionType: "PURCHASE",
+				LeadTimeDays: mat.LeadTimeDays, SafetyStock: mat.SafetyStock,
+				MinOrderQty: mat.MinOrderQty, UnitCost: mat.StandardCost,
+			}
+		}
+	}
+	for parent, children := range edges {
+		for child := range children {
+			if _, ok := items[child]; !ok {
+				delete(children, child) // 物料主数据缺失
+			}
+		}
+		if it, ok := items[parent]; ok && len(children) > 0 {
+			it.ActionType = "PRODUCE"
+		}
 	}
 
-	// Step 4: 计算净需求
-	var results []entity.MRPResult
-	for matID, req := range materialReqs {
-		// 获取现有库存
-		onHandStock, _ := s.inventoryRepo.GetTotalStock(matID)
+	// Step 4: 低层码
+	seen := make(map[string]bool)
+	var assign func(id string, level int) error
+	assign = func(id string, level int) error {
+		if level > maxBOMDepth {
+			return fmt.Errorf("BOM层级超过%d层，可能存在循环引用: %s", maxBOMDepth, items[id].Code)
+		}
+		it := items[id]
+		if seen[id] && it.LLC >= level {
+			return nil
+		}
+		seen[id] = true
+		it.LLC = level
+		for child := range edges[id] {
+			if err := assign(child, level+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, product := range products {
+		if _, ok := items[product.ID]; ok {
+			if err := assign(product.ID, 0); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
 
-		// 获取在途数量（PO中已批准但未完成收货的）
-		inTransitQty, _ := s.purchaseRepo.GetInTransitQty(matID)
+	// 净变更：有变动的物料及其全部下层物料需要重算
+	var affected map[string]bool
+	if opts.changed != nil {
+		affected = make(map[string]bool)
+		var mark func(id string)
+		mark = func(id string) {
+			if affected[id] {
+				return
+			}
+			affected[id] = true
+			for child := range edges[id] {
+				mark(child)
+			}
+		}
+		for id := range opts.changed {
+			if seen[id] {
+				mark(id)
+			}
+		}
+		run.ChangedItems = len(affected)
+	}
 
-		// 获取在制数量（工单中的计划数量 - 已完成数量）
-		inProductionQty, _ := s.woRepo.GetInProductionQty(matID)
+	ordered := make([]*planItem, 0, len(items))
+	ids := make([]string, 0, len(items))
+	for id, it := range items {
+		if !seen[id] {
+			continue
+		}
+		it.gross = make([][]demandEntry, buckets.count)
+		ordered = append(ordered, it)
+		ids = append(ids, id)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].LLC != ordered[j].LLC {
+			return ordered[i].LLC < ordered[j].LLC
+		}
+		return ordered[i].Code < ordered[j].Code
+	})
+
+	// Step 5: 独立需求落入时间段。有主生产计划的周期按MPS数量驱动：
+	// 本期订单优先占用计划数量，剩余部分作为预测需求；无MPS的周期直接取销售订单行
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	horizonEnd := today.AddDate(0, 0, run.PlanningHorizon)
+	mpsEntries, err := s.mpsRepo.GetEntriesInRange(run.ProductID, buckets.start, horizonEnd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取主生产计划失败: %w", err)
+	}
+	mpsByProduct := make(map[string][]*entity.MPSEntry)
+	for i := range mpsEntries {
+		e := &mpsEntries[i]
+		if _, ok := items[e.ProductID]; ok && seen[e.ProductID] {
+			mpsByProduct[e.ProductID] = append(mpsByProduct[e.ProductID], e)
+		}
+	}
+	if len(mpsByProduct) > 0 {
+		run.DemandSource = entity.MRPDemandSourceMPS
+	}
+	remaining := make(map[string]float64, len(mpsEntries))
+	for _, e := range mpsEntries {
+		remaining[e.ID] = e.Quantity
+	}
+	for _, line := range lines {
+		it, ok := items[line.ProductID]
+		if !ok || !seen[line.ProductID] {
+			continue
+		}
+		idx := buckets.index(line.DueDate)
+		if idx < 0 {
+			continue // 超出计划范围
+		}
+		due := buckets.start
+		if line.DueDate != nil {
+			due = *line.DueDate
+		}
+		// 订单先冲减本期MPS计划数量，超出计划的部分仍作为毛需求，不能因MPS偏小而漏算
+		excess := line.Quantity
+		for _, e := range mpsByProduct[line.ProductID] {
+			if !due.Before(e.PeriodStart) && !due.After(e.PeriodEnd.AddDate(0, 0, 1).Add(-time.Second)) {
+				consumed := math.Min(excess, math.Max(remaining[e.ID], 0))
+				remaining[e.ID] -= consumed
+				excess -= consumed
+				if consumed > 1e-9 {
+					it.gross[idx] = append(it.gross[idx], demandEntry{
+						Qty: consumed, DueDate: due, SOID: line.SOID, SOCode: line.SOCode, SOItemID: line.SOItemID,
+						DemandType: entity.DemandTypeSO, MPSEntryID: e.ID,
+					})
+				}
+				break
+			}
+		}
+		if excess <= 1e-9 {
+			continue
+		}
+		it.gross[idx] = append(it.gross[idx], demandEntry{
+			Qty: excess, DueDate: due, SOID: line.SOID, SOCode: line.SOCode, SOItemID: line.SOItemID,
+			DemandType: entity.DemandTypeSO,
+		})
+	}
+	for productID, entries := range mpsByProduct {
+		it := items[productID]
+		for _, e := range entries {
+			if remaining[e.ID] <= 1e-9 {
+				continue
+			}
+			due := e.PeriodStart
+			if due.Before(today) {
+				due = today
+			}
+			idx := buckets.index(&due)
+			if idx < 0 {
+				continue
+			}
+			it.gross[idx] = append(it.gross[idx], demandEntry{
+				Qty: remaining[e.ID], DueDate: due, DemandType: entity.DemandTypeForecast, MPSEntryID: e.ID,
+			})
+		}
+	}
+
+	params, err := s.mrpRepo.GetPlanningParams(ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取物料计划参数失败: %w", err)
+	}
 
-		// 加入安全库存需求
-		grossReq := req.GrossReq + req.SafetyStock
+	var results []entity.MRPResult
+	var pegs []entity.MRPPegging
 
-		// 净需求 = 毛需求 - 现有库存 - 在途 - 在制
-		netReq := grossReq - onHandStock - inTransitQty - inProductionQty
-		if netReq < 0 {
-			netReq = 0
+	// explode 计划订单按下单日期展开为下层毛需求，追溯信息随之下传（Step 7）
+	explode := func(it *planItem, firstResult, firstPeg int) {
+		children := edges[it.ID]
+		if len(children) == 0 {
+			return
+		}
+		pegsByResult := make(map[string][]entity.MRPPegging)
+		for _, p := range pegs[firstPeg:] {
+			pegsByResult[p.MRPResultID] = append(pegsByResult[p.MRPResultID], p)
+		}
+		for i := firstResult; i < len(results); i++ {
+			r := results[i]
+			if r.PlannedOrderQty <= 0 {
+				continue
+			}
+			idx := buckets.index(r.OrderDate)
+			if idx < 0 {
+				continue
+			}
+			pegged := 0.0
+			for childID, qtyPer := range children {
+				child := items[childID]
+				for _, p := range pegsByResult[r.ID] {
+					child.gross[idx] = append(child.gross[idx], demandEntry{
+						Qty: p.Quantity * qtyPer, DueDate: *r.OrderDate,
+						SOID: p.SOID, SOCode: p.SOCode, SOItemID: p.SOItemID,
+						DemandType: p.DemandType, MPSEntryID: p.MPSEntryID, ParentResultID: r.ID,
+					})
+				}
+			}
+			for _, p := range pegsByResult[r.ID] {
+				pegged += p.Quantity
+			}
+			if surplus := r.PlannedOrderQty - pegged; surplus > 1e-9 {
+				// 批量放大/安全库存部分无销售来源，仅记录上层计划订单
+				for childID, qtyPer := range children {
+					child := items[childID]
+					child.gross[idx] = append(child.gross[idx], demandEntry{
+						Qty: surplus * qtyPer, DueDate: *r.OrderDate, DemandType: entity.DemandTypeReplenish, ParentResultID: r.ID,
+					})
+				}
+			}
 		}
+	}
 
-		// 计划订单数量（向上取整到最小批量）
-		plannedQty := netReq
-		// TODO: 可以按最小订货量取整
+	// 净变更：未受影响的物料沿用基准运行结果，追溯关系按新ID重新关联
+	carriedIDs := make(map[string]string)
+	carry := func(it *planItem) {
+		for _, r := range opts.baseResults[it.ID] {
+			oldID := r.ID
+			r.ID = uuid.New().String()
+			r.MRPRunID = run.ID
+			r.Carried = true
+			r.CreatedAt = time.Time{}
+			carriedIDs[oldID] = r.ID
+			results = append(results, r)
+			for _, p := range opts.basePegs[oldID] {
+				p.ID = uuid.New().String()
+				p.MRPRunID = run.ID
+				p.MRPResultID = r.ID
+				p.ParentResultID = carriedIDs[p.ParentResultID]
+				p.CreatedAt = time.Time{}
+				pegs = append(pegs, p)
+			}
+		}
+	}
 
-		// 计算需求日期和下单日期
-		now := time.Now()
-		requiredDate := now.AddDate(0, 0, run.PlanningHorizon)
-		orderDate := requiredDate.AddDate(0, 0, -req.LeadTimeDays)
+	// Step 6: 逐层净算
+	for _, it := range ordered {
+		if affected != nil && !affected[it.ID] {
+			firstResult, firstPeg := len(results), len(pegs)
+			carry(it)
+			explode(it, firstResult, firstPeg)
+			continue
+		}
 
-		result := entity.MRPResult{
-			ID:               uuid.New().String(),
-			MRPRunID:         run.ID,
-			MaterialID:       matID,
-			MaterialCode:     req.MaterialCode,
-			MaterialName:     req.MaterialName,
-			GrossRequirement: grossReq,
-			OnHandStock:      onHandStock,
-			InTransitQty:     inTransitQty,
-			InProductionQty:  inProductionQty,
-			SafetyStock:      req.SafetyStock,
-			NetRequirement:   netReq,
-			PlannedOrderQty:  plannedQty,
-			ActionType:       req.ActionType,
-			RequiredDate:     &requiredDate,
-			LeadTimeDays:     req.LeadTimeDays,
-			OrderDate:        &orderDate,
-			Unit:             req.Unit,
+		onHand, err := s.inventoryRepo.GetTotalStock(it.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取 %s 库存失败: %w", it.Code, err)
 		}
-		results = append(results, result)
+		if stock, ok := opts.scenario.stockOverride(it.ID); ok {
+			onHand = stock
+		}
+		inTransit := make([]float64, buckets.count)
+		inProduction := make([]float64, buckets.count)
+		receipts, err := s.purchaseRepo.GetScheduledReceipts(it.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取 %s 采购在途失败: %w", it.Code, err)
+		}
+		for _, r := range receipts {
+			if idx := buckets.index(r.Date); idx >= 0 {
+				inTransit[idx] += r.Quantity
+			}
+		}
+		completions, err := s.woRepo.GetScheduledCompletions(it.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取 %s 工单在制失败: %w", it.Code, err)
+		}
+		for _, r := range completions {
+			if idx := buckets.index(r.Date); idx >= 0 {
+				inProduction[idx] += r.Quantity
+			}
+		}
+
+		lot := entity.LotSizingParams{Rule: entity.LotSizingLFL, MinOrderQty: it.MinOrderQty}
+		leadTime := it.LeadTimeDays
+		if p, ok := params[it.ID]; ok {
+			lot.Rule = p.LotSizingRule
+			lot.FixedLotQty = p.FixedLotQty
+			lot.OrderMultiple = p.OrderMultiple
+			if p.LeadTimeDays != nil {
+				leadTime = *p.LeadTimeDays
+			}
+			if lot.Rule == entity.LotSizingEOQ {
+				var total float64
+				for _, entries := range it.gross {
+					for _, e := range entries {
+						total += e.Qty
+					}
+				}
+				annual := total * 365 / float64(run.PlanningHorizon)
+				lot.EOQ = entity.EconomicOrderQty(annual, p.OrderingCost, it.UnitCost, p.HoldingCostRate)
+			}
+		}
+		if days, ok := opts.scenario.leadTimeOverride(it.ID); ok {
+			leadTime = days
+		}
+
+		firstResult, firstPeg := len(results), len(pegs)
+		rs, ps := mrpNetting{
+			runID: run.ID, item: it, buckets: buckets, today: today, onHand: onHand,
+			inTransit: inTransit, inProduction: inProduction, lot: lot, leadTime: leadTime,
+		}.net()
+		results = append(results, rs...)
+		pegs = append(pegs, ps...)
+		explode(it, firstResult, firstPeg)
 	}
 
-	return results, nil
+	return results, pegs, nil
 }
 
-// expandBOM 递归展开BOM
-func (s *MRPService) expandBOM(items []plmEntity.BOMItem, parentQty float64, reqs map[string]*materialReq) {
-	for _, item := range items {
-		requiredQty := item.Quantity * parentQty
+// mrpNetting 单个物料的时段净算输入
+type mrpNetting struct {
+	runID        string
+	item         *planItem
+	buckets      mrpBuckets
+	today        time.Time
+	onHand       float64
+	inTransit    []float64 // 各时段采购在途
+	inProduction []float64 // 各时段工单完工
+	lot          entity.LotSizingParams
+	leadTime     int
+}
 
-		// 获取物料信息
-		var mat plmEntity.Material
-		if err := s.db.Where("id = ?", item.MaterialID).First(&mat).Error; err != nil {
-			continue
+// net 逐时段净算：供应按时间先后分配给需求，计划订单满足的需求记入追溯
+func (n mrpNetting) net() ([]entity.MRPResult, []entity.MRPPegging) {
+	it, onHand, buckets, today := n.item, n.onHand, n.buckets, n.today
+	inTransit, inProduction, lot, leadTime := n.inTransit, n.inProduction, n.lot, n.leadTime
+	var results []entity.MRPResult
+	var pegs []entity.MRPPegging
+
+	projected := onHand
+	debt := math.Max(it.SafetyStock-onHand, 0) // 安全库存缺口，由后续供应先行补足
+	var lots []supplyLot
+	if onHand > it.SafetyStock {
+		lots = append(lots, supplyLot{qty: onHand - it.SafetyStock, plannedIdx: -1})
+	}
+	addSupply := func(qty float64, plannedIdx int) {
+		repay := math.Min(qty, debt)
+		debt -= repay
+		if qty-repay > 1e-9 {
+			lots = append(lots, supplyLot{qty: qty - repay, plannedIdx: plannedIdx})
+		}
+	}
+	var pending []demandEntry
+	consume := func() {
+		for len(pending) > 0 && len(lots) > 0 {
+			take := math.Min(pending[0].Qty, lots[0].qty)
+			if lots[0].plannedIdx >= 0 && take > 1e-9 {
+				e := pending[0]
+				due := e.DueDate
+				pegs = append(pegs, entity.MRPPegging{
+					ID: uuid.New().String(), MRPRunID: n.runID, MRPResultID: results[lots[0].plannedIdx].ID,
+					MaterialID: it.ID, Quantity: take, DemandDate: &due,
+					SOID: e.SOID, SOCode: e.SOCode, SOItemID: e.SOItemID, DemandType: e.DemandType,
+					MPSEntryID: e.MPSEntryID, ParentResultID: e.ParentResultID,
+				})
+			}
+			pending[0].Qty -= take
+			lots[0].qty -= take
+			if pending[0].Qty <= 1e-9 {
+				pending = pending[1:]
+			}
+			if lots[0].qty <= 1e-9 {
+				lots = lots[1:]
+			}
+		}
+	}
+
+	for t := 0; t < buckets.count; t++ {
+		var gross float64
+		for _, e := range it.gross[t] {
+			gross += e.Qty
+		}
+		receipt := inTransit[t] + inProduction[t]
+		if gross == 0 && receipt == 0 && projected >= it.SafetyStock-1e-9 {
+			continue // 无需求、无接收且不低于安全库存
+		}
+
+		startOnHand := projected
+		available := projected + receipt
+		addSupply(receipt, -1)
+		pending = append(pending, it.gross[t]...)
+		consume()
+
+		bucketStart, bucketEnd := buckets.bounds(t)
+		result := entity.MRPResult{
+			ID:               uuid.New().String(),
+			MRPRunID:         n.runID,
+			MaterialID:       it.ID,
+			MaterialCode:     it.Code,
+			MaterialName:     it.Name,
+			GrossRequirement: gross,
+			OnHandStock:      startOnHand,
+			InTransitQty:     inTransit[t],
+			InProductionQty:  inProduction[t],
+			ScheduledReceipt: receipt,
+			SafetyStock:      it.SafetyStock,
+			ActionType:       it.ActionType,
+			LeadTimeDays:     leadTime,
+			Unit:             it.Unit,
+			LowLevelCode:     it.LLC,
+			BucketStart:      &bucketStart,
+			BucketEnd:        &bucketEnd,
+			LotSizingRule:    lot.Rule,
 		}
 
-		if existing, ok := reqs[item.MaterialID]; ok {
-			existing.GrossReq += requiredQty
-		} else {
-			reqs[item.MaterialID] = &materialReq{
-				MaterialID:   item.MaterialID,
-				MaterialCode: mat.Code,
-				MaterialName: mat.Name,
-				GrossReq:     requiredQty,
-				SafetyStock:  mat.SafetyStock,
-				LeadTimeDays: mat.LeadTimeDays,
-				Unit:         mat.Unit,
-				ActionType:   "PURCHASE", // 默认采购，有子BOM的为生产
+		net := gross + it.SafetyStock - available
+		var planned float64
+		if net > 1e-9 {
+			planned = entity.LotSize(net, lot)
+			requiredDate := bucketStart
+			if t == 0 && requiredDate.Before(today) {
+				requiredDate = today
 			}
+			orderDate := requiredDate.AddDate(0, 0, -leadTime)
+			result.NetRequirement = net
+			result.PlannedOrderQty = planned
+			result.RequiredDate = &requiredDate
+			result.OrderDate = &orderDate
+			result.PastDue = orderDate.Before(today)
 		}
+		projected = available + planned - gross
+		result.ProjectedOnHand = projected
+		results = append(results, result)
 
-		// 递归展开子级BOM项
-		var childItems []plmEntity.BOMItem
-		s.db.Where("parent_item_id = ?", item.ID).Find(&childItems)
-		if len(childItems) > 0 {
-			reqs[item.MaterialID].ActionType = "PRODUCE"
-			s.expandBOM(childItems, requiredQty, reqs)
+		if planned > 0 {
+			addSupply(planned, len(results)-1)
+			consume()
 		}
 	}
+	return results, pegs
 }
 
 // GetResults 获取MRP运行结果
@@ -250,6 +753,95 @@ func (s *MRPService) GetResults(runID string) ([]entity.MRPResult, error) {
 	return s.mrpRepo.GetResultsByRunID(runID)
 }
 
+// PeggingView 计划订单及其需求追溯
+type PeggingView struct {
+	Result   *entity.MRPResult   `json:"result"`
+	Peggings []entity.MRPPegging `json:"peggings"`
+}
+
+// GetPegging 获取MRP结果的需求追溯
+func (s *MRPService) GetPegging(resultID string) (*PeggingView, error) {
+	result, err := s.mrpRepo.GetResultByID(resultID)
+	if err != nil {
+		return nil, fmt.Errorf("MRP结果不存在: %w", err)
+	}
+	pegs, err := s.mrpRepo.GetPeggingsByResultID(resultID)
+	if err != nil {
+		return nil, err
+	}
+	return &PeggingView{Result: result, Peggings: pegs}, nil
+}
+
+// ListPlanningParams 物料计划参数列表
+func (s *MRPService) ListPlanningParams() ([]entity.MaterialPlanning, error) {
+	return s.mrpRepo.ListPlanningParams()
+}
+
+type SavePlanningParamsRequest struct {
+	MaterialID      string  `json:"material_id" binding:"required"`
+	LotSizingRule   string  `json:"lot_sizing_rule" binding:"required"`
+	FixedLotQty     float64 `json:"fixed_lot_qty"`
+	OrderMultiple   float64 `json:"order_multiple"`
+	OrderingCost    float64 `json:"ordering_cost"`
+	HoldingCostRate float64 `json:"holding_cost_rate"`
+	LeadTimeDays    *int    `json:"lead_time_days"`
+
+	DemandTimeFenceDays     int  `json:"demand_time_fence_days"`
+	PlanningTimeFenceDays   int  `json:"planning_time_fence_days"`
+	ConsumptionBackwardDays *int `json:"consumption_backward_days"`
+	ConsumptionForwardDays  *int `json:"consumption_forward_days"`
+}
+
+// SavePlanningParams 保存物料批量规则、提前期及成品时界/预测冲减窗口
+func (s *MRPService) SavePlanningParams(req SavePlanningParamsRequest, userID string) (*entity.MaterialPlanning, error) {
+	switch req.LotSizingRule {
+	case entity.LotSizingLFL, entity.LotSizingMOQ:
+	case entity.LotSizingFixed:
+		if req.FixedLotQty <= 0 {
+			return nil, fmt.Errorf("固定批量规则需设置固定批量")
+		}
+	case entity.LotSizingEOQ:
+		if req.OrderingCost <= 0 || req.HoldingCostRate <= 0 {
+			return nil, fmt.Errorf("经济批量规则需设置订货成本和持有成本率")
+		}
+	case entity.LotSizingMultiple:
+		if req.OrderMultiple <= 0 {
+			return nil, fmt.Errorf("整倍数规则需设置订货倍数")
+		}
+	default:
+		return nil, fmt.Errorf("无效的批量规则: %s", req.LotSizingRule)
+	}
+	if req.LeadTimeDays != nil && *req.LeadTimeDays < 0 {
+		return nil, fmt.Errorf("提前期不能为负数")
+	}
+	if req.DemandTimeFenceDays < 0 || req.PlanningTimeFenceDays < 0 ||
+		(req.ConsumptionBackwardDays != nil && *req.ConsumptionBackwardDays < 0) ||
+		(req.ConsumptionForwardDays != nil && *req.ConsumptionForwardDays < 0) {
+		return nil, fmt.Errorf("时界与冲减天数不能为负数")
+	}
+
+	p := &entity.MaterialPlanning{
+		ID:              uuid.New().String(),
+		MaterialID:      req.MaterialID,
+		LotSizingRule:   req.LotSizingRule,
+		FixedLotQty:     req.FixedLotQty,
+		OrderMultiple:   req.OrderMultiple,
+		OrderingCost:    req.OrderingCost,
+		HoldingCostRate: req.HoldingCostRate,
+		LeadTimeDays:    req.LeadTimeDays,
+		UpdatedBy:       userID,
+
+		DemandTimeFenceDays:     req.DemandTimeFenceDays,
+		PlanningTimeFenceDays:   req.PlanningTimeFenceDays,
+		ConsumptionBackwardDays: req.ConsumptionBackwardDays,
+		ConsumptionForwardDays:  req.ConsumptionForwardDays,
+	}
+	if err := s.mrpRepo.UpsertPlanningParams(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // GetLatestRun 获取最近一次MRP运行
 func (s *MRPService) GetLatestRun() (*entity.MRPRun, error) {
 	return s.mrpRepo.GetLatestRun()
@@ -266,6 +858,9 @@ func (s *MRPService) Apply(runID, userID string) error {
 	if err != nil {
 		return fmt.Errorf("MRP运行记录不存在: %w", err)
 	}
+	if run.RunType == entity.MRPRunSimulation {
+		return fmt.Errorf("模拟运行不能应用")
+	}
 	if run.Status != entity.MRPStatusCompleted {
 		return fmt.Errorf("MRP运行状态不允许应用: %s", run.Status)
 	}
@@ -275,6 +870,36 @@ func (s *MRPService) Apply(runID, userID string) error {
 		return fmt.Errorf("获取MRP结果失败: %w", err)
 	}
 
+	// 自制件需要已发布BOM才能生成工单，缺失时整体拒绝应用，避免只生成一半的计划
+	boms := make(map[string]*plmEntity.BOMHeader)
+	var missing []string
+	for _, result := range results {
+		if result.NetRequirement <= 0 || result.ActionType != "PRODUCE" {
+			continue
+		}
+		if _, ok := boms[result.MaterialID]; ok {
+			continue
+		}
+		if s.manufacturing == nil {
+			return fmt.Errorf("未配置工单服务，无法为自制件 %s 生成工单", result.MaterialCode)
+		}
+		var bom plmEntity.BOMHeader
+		err := s.db.Where("product_id = ? AND status = 'released'", result.MaterialID).
+			Order("created_at DESC").First(&bom).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			missing = append(missing, result.MaterialCode)
+			boms[result.MaterialID] = nil
+		case err != nil:
+			return fmt.Errorf("读取BOM失败: %w", err)
+		default:
+			boms[result.MaterialID] = &bom
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("自制件没有已发布BOM，无法生成工单: %s", strings.Join(missing, ", "))
+	}
+
 	prsGenerated := 0
 	wosGenerated := 0
 
@@ -304,7 +929,24 @@ func (s *MRPService) Apply(runID, userID string) error {
 				prsGenerated++
 			}
 		}
-		// 工单生成暂时跳过（需要更复杂的逻辑来关联产品和BOM）
+		if result.ActionType == "PRODUCE" {
+			req := CreateWorkOrderRequest{
+				ProductID:  result.MaterialID,
+				BOMID:      boms[result.MaterialID].ID,
+				PlannedQty: result.PlannedOrderQty,
+				Notes:      fmt.Sprintf("MRP自动生成 - %s", run.RunCode),
+			}
+			if result.OrderDate != nil {
+				req.PlannedStart = result.OrderDate.Format("2006-01-02")
+			}
+			if result.RequiredDate != nil {
+				req.PlannedEnd = result.RequiredDate.Format("2006-01-02")
+			}
+			if _, err := s.manufacturing.create(req, "MRP", runID, userID); err != nil {
+				return fmt.Errorf("为 %s 生成工单失败: %w", result.MaterialCode, err)
+			}
+			wosGenerated++
+		}
 	}
 
 	// 标记结果为已应用
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+)
+
+// netForTest 按日时段净算单个物料，demand/receipts 以时段序号为键
+func netForTest(onHand, safety float64, demand map[int][]demandEntry, receipts map[int]float64, lot entity.LotSizingParams) ([]entity.MRPResult, []entity.MRPPegging) {
+	today := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	buckets := newMRPBuckets(today, 6, entity.MRPBucketDay)
+	it := &planItem{ID: "mat-1", Code: "MAT-1", SafetyStock: safety, ActionType: "PURCHASE", gross: make([][]demandEntry, buckets.count)}
+	for idx, entries := range demand {
+		due, _ := buckets.bounds(idx)
+		for _, e := range entries {
+			e.DueDate = due
+			it.gross[idx] = append(it.gross[idx], e)
+		}
+	}
+	inTransit := make([]float64, buckets.count)
+	for idx, qty := range receipts {
+		inTransit[idx] = qty
+	}
+	return mrpNetting{
+		runID: "run-1", item: it, buckets: buckets, today: today, onHand: onHand,
+		inTransit: inTransit, inProduction: make([]float64, buckets.count), lot: lot, leadTime: 2,
+	}.net()
+}
+
+func soDemand(so string, qty float64) demandEntry {
+	return demandEntry{Qty: qty, SOID: so, SOCode: so, SOItemID: so + "-1", DemandType: entity.DemandTypeSO}
+}
+
+// TestMRPNetting 库存与计划接收先行抵扣，净需求按提前期倒排下单日期
+func TestMRPNetting(t *testing.T) {
+	lfl := entity.LotSizingParams{Rule: entity.LotSizingLFL}
+	cases := []struct {
+		name        string
+		onHand      float64
+		safety      float64
+		demand      map[int][]demandEntry
+		receipts    map[int]float64
+		lot         entity.LotSizingParams
+		wantBuckets int
+		wantPlanned []float64 // 各结果行的计划订单数量
+		wantEnding  float64   // 最后一行的预计可用量
+	}{
+		{
+			name: "库存足够不下单", onHand: 10,
+			demand: map[int][]demandEntry{0: {soDemand("SO-A", 4)}}, lot: lfl,
+			wantBuckets: 1, wantPlanned: []float64{0}, wantEnding: 6,
+		},
+		{
+			name: "在途抵扣后按净需求下单", onHand: 10,
+			demand:   map[int][]demandEntry{0: {soDemand("SO-A", 4)}, 2: {soDemand("SO-B", 20)}},
+			receipts: map[int]float64{2: 5}, lot: lfl,
+			wantBuckets: 2, wantPlanned: []float64{0, 9}, wantEnding: 0,
+		},
+		{
+			name: "无需求的安全库存缺口仍需补足", onHand: 2, safety: 10, lot: lfl,
+			wantBuckets: 1, wantPlanned: []float64{8}, wantEnding: 10,
+		},
+		{
+			name: "需求与安全库存一并补足", onHand: 0, safety: 5,
+			demand: map[int][]demandEntry{1: {soDemand("SO-A", 10)}}, lot: lfl,
+			wantBuckets: 2, wantPlanned: []float64{5, 10}, wantEnding: 5,
+		},
+		{
+			name: "仅有在途无需求照常记录", onHand: 0,
+			receipts: map[int]float64{3: 7}, lot: lfl,
+			wantBuckets: 1, wantPlanned: []float64{0}, wantEnding: 7,
+		},
+		{
+			name: "固定批量放大", onHand: 0,
+			demand:      map[int][]demandEntry{0: {soDemand("SO-A", 30)}},
+			lot:         entity.LotSizingParams{Rule: entity.LotSizingFixed, FixedLotQty: 25},
+			wantBuckets: 1, wantPlanned: []float64{50}, wantEnding: 20,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, _ := netForTest(tc.onHand, tc.safety, tc.demand, tc.receipts, tc.lot)
+			if len(results) != tc.wantBuckets {
+				t.Fatalf("expected %d result rows, got %d", tc.wantBuckets, len(results))
+			}
+			for i, r := range results {
+				if math.Abs(r.PlannedOrderQty-tc.wantPlanned[i]) > 1e-9 {
+					t.Fatalf("row %d: expected planned %v, got %v", i, tc.wantPlanned[i], r.PlannedOrderQty)
+				}
+				if r.PlannedOrderQty > 0 {
+					if r.RequiredDate == nil || r.OrderDate == nil {
+						t.Fatalf("row %d: planned order without dates", i)
+					}
+					if got := r.RequiredDate.Sub(*r.OrderDate).Hours() / 24; got != 2 {
+						t.Fatalf("row %d: expected 2 days lead time offset, got %v", i, got)
+					}
+				}
+			}
+			if last := results[len(results)-1].ProjectedOnHand; math.Abs(last-tc.wantEnding) > 1e-9 {
+				t.Fatalf("expected ending projected on hand %v, got %v", tc.wantEnding, last)
+			}
+		})
+	}
+}
+
+// TestMRPPegging 计划订单按需求先后追溯到销售订单行，库存满足部分与批量放大部分不追溯
+func TestMRPPegging(t *testing.T) {
+	type peg struct {
+		so     string
+		result int
+		qty    float64
+	}
+	cases := []struct {
+		name   string
+		onHand float64
+		demand map[int][]demandEntry
+		lot    entity.LotSizingParams
+		want   []peg
+	}{
+		{
+			name: "库存先满足，缺口追溯到订单", onHand: 10,
+			demand: map[int][]demandEntry{0: {soDemand("SO-A", 30)}},
+			lot:    entity.LotSizingParams{Rule: entity.LotSizingLFL},
+			want:   []peg{{"SO-A", 0, 20}},
+		},
+		{
+			name: "批量余量满足后续订单", onHand: 0,
+			demand: map[int][]demandEntry{0: {soDemand("SO-A", 30)}, 1: {soDemand("SO-B", 15)}},
+			lot:    entity.LotSizingParams{Rule: entity.LotSizingFixed, FixedLotQty: 50},
+			want:   []peg{{"SO-A", 0, 30}, {"SO-B", 0, 15}},
+		},
+		{
+			name: "余量不足时拆分到下一张计划订单", onHand: 0,
+			demand: map[int][]demandEntry{0: {soDemand("SO-A", 30)}, 1: {soDemand("SO-B", 25)}},
+			lot:    entity.LotSizingParams{Rule: entity.LotSizingFixed, FixedLotQty: 40},
+			want:   []peg{{"SO-A", 0, 30}, {"SO-B", 0, 10}, {"SO-B", 1, 15}},
+		},
+		{
+			name: "同一时段多笔订单按顺序追溯", onHand: 0,
+			demand: map[int][]demandEntry{0: {soDemand("SO-A", 5), soDemand("SO-B", 7)}},
+			lot:    entity.LotSizingParams{Rule: entity.LotSizingLFL},
+			want:   []peg{{"SO-A", 0, 5}, {"SO-B", 0, 7}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, pegs := netForTest(tc.onHand, 0, tc.demand, nil, tc.lot)
+			if len(pegs) != len(tc.want) {
+				t.Fatalf("expected %d peggings, got %d: %+v", len(tc.want), len(pegs), pegs)
+			}
+			for i, w := range tc.want {
+				p := pegs[i]
+				if p.SOID != w.so || p.MRPResultID != results[w.result].ID || math.Abs(p.Quantity-w.qty) > 1e-9 {
+					t.Fatalf("pegging %d: expected %s→row %d qty %v, got %s→%s qty %v",
+						i, w.so, w.result, w.qty, p.SOID, p.MRPResultID, p.Quantity)
+				}
+			}
+		})
+	}
+}
@@ -13,10 +13,11 @@ type ProcurementService struct {
 	purchaseRepo  *repository.PurchaseRepository
 	supplierRepo  *repository.SupplierRepository
 	inventoryRepo *repository.InventoryRepository
+	warehouse     *WarehouseService // 收货上架
 }
 
-func NewProcurementService(pr *repository.PurchaseRepository, sr *repository.SupplierRepository, ir *repository.InventoryRepository) *ProcurementService {
-	return &ProcurementService{purchaseRepo: pr, supplierRepo: sr, inventoryRepo: ir}
+func NewProcurementService(pr *repository.PurchaseRepository, sr *repository.SupplierRepository, ir *repository.InventoryRepository, warehouse *WarehouseService) *ProcurementService {
+	return &ProcurementService{purchaseRepo: pr, supplierRepo: sr, inventoryRepo: ir, warehouse: warehouse}
 }
 
 // --- Purchase Requisition ---
@@ -231,6 +232,8 @@ type ReceiveItemRequest struct {
 	ReceivedQty float64 `json:"received_qty" binding:"required,gt=0"`
 	WarehouseID string  `json:"warehouse_id" binding:"required"`
 	BatchNo     string  `json:"batch_no"`
+	LocationID  string  `json:"location_id"` // 空=按上架规则分配
+	ExpiryDate  string  `json:"expiry_date"` // YYYY-MM-DD
 }
 
 func (s *ProcurementService) ReceivePO(id string, items []ReceiveItemRequest, userID string) error {
@@ -253,43 +256,12 @@ func (s *ProcurementService) ReceivePO(id string, items []ReceiveItemRequest, us
 					po.Items[i].Status = entity.POItemStatusPartial
 					allReceived = false
 				}
-				if err := s.purchaseRepo.UpdatePOItem(&po.Items[i]); err != nil {
-					return err
-				}
 
-				// 创建库存入库
 				batchNo := receiveItem.BatchNo
 				if batchNo == "" {
-					batchNo = fmt.Sprintf("%s%03d", time.Now().Format("20060102"), time.Now().UnixNano()%1000)
-				}
-				inv := &entity.Inventory{
-					ID:            uuid.New().String(),
-					MaterialID:    po.Items[i].MaterialID,
-					MaterialCode:  po.Items[i].MaterialCode,
-					MaterialName:  po.Items[i].MaterialName,
-					WarehouseID:   receiveItem.WarehouseID,
-					BatchNo:       batchNo,
-					InventoryType: entity.InventoryTypeRaw,
-					Quantity:      receiveItem.ReceivedQty,
-					AvailableQty:  receiveItem.ReceivedQty,
-					UnitCost:      po.Items[i].UnitPrice,
-					Unit:          po.Items[i].Unit,
-				}
-				now := time.Now()
-				inv.LastMovedAt = &now
-
-				// 先尝试查询已有库存记录
-				existingInv, findErr := s.inventoryRepo.GetByMaterialAndWarehouse(po.Items[i].MaterialID, receiveItem.WarehouseID)
-				if findErr == nil && existingInv != nil {
-					existingInv.Quantity += receiveItem.ReceivedQty
-					existingInv.AvailableQty += receiveItem.ReceivedQty
-					existingInv.LastMovedAt = &now
-					s.inventoryRepo.Update(existingInv)
-				} else {
-					s.inventoryRepo.UpsertInventory(inv)
+					batchNo = GenerateBatchNo()
 				}
-
-				// 创建库存交易记录
+				// 库存入库：流水与结存同一事务过账
 				tx := &entity.InventoryTransaction{
 					ID:              uuid.New().String(),
 					MaterialID:      po.Items[i].MaterialID,
@@ -299,13 +271,27 @@ func (s *ProcurementService) ReceivePO(id string, items []ReceiveItemRequest, us
 					TransactionType: entity.TxTypePurchaseIn,
 					Quantity:        receiveItem.ReceivedQty,
 					BatchNo:         batchNo,
+					LocationID:      receiveItem.LocationID,
+					ExpiryDate:      parseExpiryDate(receiveItem.ExpiryDate),
 					UnitCost:        po.Items[i].UnitPrice,
 					ReferenceType:   "PO",
 					ReferenceID:     po.ID,
 					ReferenceCode:   po.POCode,
+					SupplierID:      po.SupplierID,
 					CreatedBy:       userID,
 				}
-				s.inventoryRepo.CreateTransaction(tx)
+				moves, err := s.warehouse.PlaceInbound([]repository.StockMovement{{
+					Tx: tx, CreateIfMissing: true, InventoryType: entity.InventoryTypeRaw, Unit: po.Items[i].Unit,
+				}})
+				if err != nil {
+					return fmt.Errorf("收货上架失败: %w", err)
+				}
+				if _, err := s.inventoryRepo.PostMovements(moves); err != nil {
+					return fmt.Errorf("收货入库失败: %w", err)
+				}
+				if err := s.purchaseRepo.UpdatePOItem(&po.Items[i]); err != nil {
+					return err
+				}
 
 				break
 			}
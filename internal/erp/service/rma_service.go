@@ -0,0 +1,603 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSerialNotShipped 序列号没有发货记录，无法确认归属客户与保修起算日
+	ErrSerialNotShipped = errors.New("序列号没有发货记录")
+	// ErrSerialCustomerMismatch 序列号不是发给该客户的
+	ErrSerialCustomerMismatch = errors.New("序列号与客户不一致")
+	// ErrOutOfWarranty 序列号已过保修期，需确认按保外处理
+	ErrOutOfWarranty = errors.New("序列号已过保修期")
+)
+
+// RMAService 退货授权：序列号与保修校验、隔离收货、判定及维修/换货/退款
+type RMAService struct {
+	repo          *repository.RMARepository
+	salesRepo     *repository.SalesRepository
+	genealogyRepo *repository.GenealogyRepository
+	inventoryRepo *repository.InventoryRepository
+	woRepo        *repository.WorkOrderRepository
+	financeRepo   *repository.MRPRepository
+}
+
+func NewRMAService(
+	repo *repository.RMARepository,
+	salesRepo *repository.SalesRepository,
+	genealogyRepo *repository.GenealogyRepository,
+	inventoryRepo *repository.InventoryRepository,
+	woRepo *repository.WorkOrderRepository,
+	financeRepo *repository.MRPRepository,
+) *RMAService {
+	return &RMAService{
+		repo:          repo,
+		salesRepo:     salesRepo,
+		genealogyRepo: genealogyRepo,
+		inventoryRepo: inventoryRepo,
+		woRepo:        woRepo,
+		financeRepo:   financeRepo,
+	}
+}
+
+// withTx 返回各仓储绑定到同一事务的服务副本，多表写入在事务内完成
+func (s *RMAService) withTx(tx *gorm.DB) *RMAService {
+	return &RMAService{
+		repo:          repository.NewRMARepository(tx),
+		salesRepo:     repository.NewSalesRepository(tx),
+		genealogyRepo: repository.NewGenealogyRepository(tx),
+		inventoryRepo: repository.NewInventoryRepository(tx),
+		woRepo:        repository.NewWorkOrderRepository(tx),
+		financeRepo:   repository.NewMRPRepository(tx),
+	}
+}
+
+// SerialWarranty 序列号的发货与保修信息。保修期自首次发货日起算，归属客户取最近一次发货
+type SerialWarranty struct {
+	SerialNo       string     `json:"serial_no"`
+	ProductID      string     `json:"product_id"`
+	ProductCode    string     `json:"product_code"`
+	LotNo          string     `json:"lot_no"`
+	SOID           string     `json:"so_id"`
+	SOCode         string     `json:"so_code"`
+	CustomerID     string     `json:"customer_id"`
+	CustomerName   string     `json:"customer_name"`
+	ShippedAt      time.Time  `json:"shipped_at"`
+	WarrantyMonths int        `json:"warranty_months"`
+	WarrantyUntil  *time.Time `json:"warranty_until"`
+	InWarranty     bool       `json:"in_warranty"`
+}
+
+// VerifySerial 按发货追溯校验序列号并计算保修期
+func (s *RMAService) VerifySerial(serialNo string) (*SerialWarranty, error) {
+	shipments, err := s.genealogyRepo.FindShipments("", "", serialNo)
+	if err != nil {
+		return nil, err
+	}
+	if len(shipments) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSerialNotShipped, serialNo)
+	}
+	first, last := shipments[0], shipments[len(shipments)-1]
+	months, ok, err := s.repo.GetWarrantyMonths(first.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		months = entity.DefaultWarrantyMonths
+	}
+	until := first.ShippedAt.AddDate(0, months, 0)
+	return &SerialWarranty{
+		SerialNo:       serialNo,
+		ProductID:      first.ProductID,
+		ProductCode:    first.ProductCode,
+		LotNo:          first.LotNo,
+		SOID:           last.SOID,
+		SOCode:         last.SOCode,
+		CustomerID:     last.CustomerID,
+		CustomerName:   last.CustomerName,
+		ShippedAt:      first.ShippedAt,
+		WarrantyMonths: months,
+		WarrantyUntil:  &until,
+		InWarranty:     time.Now().Before(until),
+	}, nil
+}
+
+// attachSerial 服务工单建单时带出序列号的发货与保修信息。有发货追溯的必须是发给该客户的序列号；
+// 追溯上线前发出或经其他渠道发出的序列号没有发货记录，照常受理、保修状态记为未知，发放退货授权时再校验
+func (s *RMAService) attachSerial(svc *entity.ServiceOrder) error {
+	w, err := s.VerifySerial(svc.ProductSN)
+	if errors.Is(err, ErrSerialNotShipped) {
+		svc.WarrantyState = entity.WarrantyStateUnknown
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if w.CustomerID != svc.CustomerID {
+		return fmt.Errorf("%w: 序列号 %s 发货给客户 %s", ErrSerialCustomerMismatch, svc.ProductSN, w.CustomerName)
+	}
+	applyWarranty(svc, w)
+	return nil
+}
+
+// applyWarranty 服务工单记录序列号的原发货订单与保修期
+func applyWarranty(svc *entity.ServiceOrder, w *SerialWarranty) {
+	svc.ProductID = w.ProductID
+	svc.ShippedSOID = w.SOID
+	svc.ShippedAt = &w.ShippedAt
+	svc.WarrantyUntil = w.WarrantyUntil
+	svc.InWarranty = w.InWarranty
+	svc.WarrantyState = entity.WarrantyStateOut
+	if w.InWarranty {
+		svc.WarrantyState = entity.WarrantyStateIn
+	}
+}
+
+type IssueRMARequest struct {
+	ServiceOrderID     string `json:"service_order_id" binding:"required"`
+	Reason             string `json:"reason"`
+	AllowOutOfWarranty bool   `json:"allow_out_of_warranty"` // 保外有偿处理
+}
+
+// Issue 由维修/退货/换货服务工单发放退货授权号
+func (s *RMAService) Issue(req IssueRMARequest, userID string) (*entity.RMA, error) {
+	svc, err := s.salesRepo.GetServiceOrderByID(req.ServiceOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("服务工单不存在: %w", err)
+	}
+	if svc.ServiceType == entity.ServiceTypeInquiry {
+		return nil, fmt.Errorf("咨询类服务工单不能发起退货授权")
+	}
+	if svc.RMAID != "" {
+		return nil, fmt.Errorf("服务工单已关联退货授权 %s", svc.RMACode)
+	}
+	if svc.Status == entity.SvcStatusCompleted || svc.Status == entity.SvcStatusClosed {
+		return nil, fmt.Errorf("服务工单状态不允许发起退货授权: %s", svc.Status)
+	}
+	if open, err := s.repo.FindOpenBySerial(svc.ProductSN); err == nil {
+		return nil, fmt.Errorf("序列号 %s 已有未结案的退货授权 %s", svc.ProductSN, open.RMACode)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	w, err := s.VerifySerial(svc.ProductSN)
+	if err != nil {
+		return nil, err
+	}
+	if w.CustomerID != svc.CustomerID {
+		return nil, fmt.Errorf("%w: 序列号 %s 发货给客户 %s", ErrSerialCustomerMismatch, svc.ProductSN, w.CustomerName)
+	}
+	if !w.InWarranty && !req.AllowOutOfWarranty {
+		return nil, fmt.Errorf("%w: 保修截止 %s", ErrOutOfWarranty, w.WarrantyUntil.Format("2006-01-02"))
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = svc.Description
+	}
+	rma := &entity.RMA{
+		ID:             uuid.New().String(),
+		RMACode:        fmt.Sprintf("RMA-%s%04d", time.Now().Format("20060102"), time.Now().UnixNano()%10000),
+		ServiceOrderID: svc.ID,
+		ServiceCode:    svc.ServiceCode,
+		CustomerID:     svc.CustomerID,
+		SerialNo:       svc.ProductSN,
+		ProductID:      w.ProductID,
+		ProductCode:    w.ProductCode,
+		LotNo:          w.LotNo,
+		SOID:           w.SOID,
+		SOCode:         w.SOCode,
+		ShippedAt:      &w.ShippedAt,
+		WarrantyUntil:  w.WarrantyUntil,
+		InWarranty:     w.InWarranty,
+		Reason:         reason,
+		Status:         entity.RMAStatusIssued,
+		CreatedBy:      userID,
+	}
+	svc.RMAID, svc.RMACode = rma.ID, rma.RMACode
+	applyWarranty(svc, w)
+	if svc.Status == entity.SvcStatusCreated || svc.Status == entity.SvcStatusAssigned {
+		svc.Status = entity.SvcStatusInProgress
+	}
+	err = s.repo.DB().Transaction(func(tx *gorm.DB) error {
+		t := s.withTx(tx)
+		if err := t.repo.Create(rma); err != nil {
+			return fmt.Errorf("创建退货授权失败: %w", err)
+		}
+		return t.salesRepo.UpdateServiceOrder(svc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+func (s *RMAService) GetByID(id string) (*entity.RMA, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *RMAService) List(params repository.RMAListParams) ([]entity.RMA, int64, error) {
+	return s.repo.List(params)
+}
+
+type ReceiveRMARequest struct {
+	WarehouseID string `json:"warehouse_id"` // 空=默认退货隔离仓
+	LocationID  string `json:"location_id"`
+	Notes       string `json:"notes"`
+}
+
+// Receive 退货入隔离仓并按退货授权占用，判定前不计入可用库存；入库、占用与状态在同一事务内提交
+func (s *RMAService) Receive(id string, req ReceiveRMARequest, userID string) (*entity.RMA, error) {
+	var rma *entity.RMA
+	err := s.repo.DB().Transaction(func(tx *gorm.DB) error {
+		t := s.withTx(tx)
+		var err error
+		if rma, err = t.repo.LockByID(id); err != nil {
+			return fmt.Errorf("退货授权不存在: %w", err)
+		}
+		if rma.Status != entity.RMAStatusIssued {
+			return fmt.Errorf("退货授权状态不允许收货: %s", rma.Status)
+		}
+		return t.receive(rma, req, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+func (s *RMAService) receive(rma *entity.RMA, req ReceiveRMARequest, userID string) error {
+	warehouseID := req.WarehouseID
+	if warehouseID == "" {
+		wh, err := s.inventoryRepo.EnsureWarehouse(entity.RMAQuarantineWHCode, entity.RMAQuarantineWHName)
+		if err != nil {
+			return fmt.Errorf("获取退货隔离仓失败: %w", err)
+		}
+		warehouseID = wh.ID
+	}
+
+	moves := []repository.StockMovement{{
+		Tx: &entity.InventoryTransaction{
+			ID:              uuid.New().String(),
+			MaterialID:      rma.ProductID,
+			MaterialCode:    rma.ProductCode,
+			WarehouseID:     warehouseID,
+			TransactionType: entity.TxTypeReturnIn,
+			Quantity:        1,
+			BatchNo:         rma.LotNo,
+			LocationID:      req.LocationID,
+			SerialNo:        rma.SerialNo,
+			ReferenceType:   entity.RMAReferenceType,
+			ReferenceID:     rma.ID,
+			ReferenceCode:   rma.RMACode,
+			Notes:           req.Notes,
+			CreatedBy:       userID,
+		},
+		CreateIfMissing: true,
+		InventoryType:   entity.InventoryTypeFG,
+	}}
+	if _, err := s.inventoryRepo.PostMovements(moves); err != nil {
+		return fmt.Errorf("退货入库失败: %w", err)
+	}
+	res := &entity.InventoryReservation{
+		ID:            uuid.New().String(),
+		MaterialID:    rma.ProductID,
+		WarehouseID:   warehouseID,
+		Quantity:      1,
+		ReferenceType: entity.RMAReferenceType,
+		ReferenceID:   rma.ID,
+		ReferenceCode: rma.RMACode,
+		Notes:         "退货隔离待判定 " + rma.SerialNo,
+		CreatedBy:     userID,
+	}
+	if _, err := s.inventoryRepo.Reserve(res); err != nil {
+		return fmt.Errorf("退货隔离占用失败: %w", err)
+	}
+
+	now := time.Now()
+	rma.Status = entity.RMAStatusReceived
+	rma.WarehouseID = warehouseID
+	rma.LocationID = req.LocationID
+	rma.ReservationID = res.ID
+	rma.ReceivedAt = &now
+	rma.ReceivedBy = userID
+	return s.repo.Update(rma)
+}
+
+type TriageRMARequest struct {
+	Disposition  string  `json:"disposition" binding:"required,oneof=REPAIR REPLACE REFUND REJECT"`
+	Note         string  `json:"note"`
+	CreditAmount float64 `json:"credit_amount"` // 退款金额，空=原订单行单价
+}
+
+// Triage 判定退货：维修生成维修工单并把退回件发到工单，换货生成零价换货订单走正常发货，
+// 退款生成贷项通知；换货/退款/驳回时退回件留在隔离仓待后续处置。处置单据与判定结果在同一事务内提交
+func (s *RMAService) Triage(id string, req TriageRMARequest, userID string) (*entity.RMA, error) {
+	var rma *entity.RMA
+	err := s.repo.DB().Transaction(func(tx *gorm.DB) error {
+		t := s.withTx(tx)
+		var err error
+		if rma, err = t.repo.LockByID(id); err != nil {
+			return fmt.Errorf("退货授权不存在: %w", err)
+		}
+		if rma.Status != entity.RMAStatusReceived {
+			return fmt.Errorf("退货授权状态不允许判定: %s", rma.Status)
+		}
+		return t.triage(rma, req, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+func (s *RMAService) triage(rma *entity.RMA, req TriageRMARequest, userID string) error {
+	var err error
+	switch req.Disposition {
+	case entity.RMADispositionRepair:
+		err = s.createRepairWO(rma, userID)
+		rma.Status = entity.RMAStatusRepairing
+	case entity.RMADispositionReplace:
+		err = s.createReplacementSO(rma, userID)
+		rma.Status = entity.RMAStatusResolved
+	case entity.RMADispositionRefund:
+		err = s.createCreditNote(rma, req.CreditAmount, userID)
+		rma.Status = entity.RMAStatusResolved
+	default:
+		rma.Status = entity.RMAStatusRejected
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rma.Disposition = req.Disposition
+	rma.TriageNote = req.Note
+	rma.TriagedBy = userID
+	rma.TriagedAt = &now
+	return s.repo.Update(rma)
+}
+
+// createRepairWO 维修工单沿用序列号原生产工单的BOM版本，退回件从隔离占用直接发到维修工单
+func (s *RMAService) createRepairWO(rma *entity.RMA, userID string) error {
+	wo := &entity.WorkOrder{
+		ID:          uuid.New().String(),
+		WOCode:      fmt.Sprintf("WO-%s%04d", time.Now().Format("20060102"), time.Now().UnixNano()%10000),
+		ProductID:   rma.ProductID,
+		ProductCode: rma.ProductCode,
+		PlannedQty:  1,
+		Status:      entity.WOStatusCreated,
+		WarehouseID: rma.WarehouseID,
+		IssueWHID:   rma.WarehouseID,
+		SourceType:  entity.RMASourceType,
+		SourceID:    rma.ID,
+		Notes:       fmt.Sprintf("退货授权 %s 维修，序列号 %s", rma.RMACode, rma.SerialNo),
+		CreatedBy:   userID,
+	}
+	if produced, err := s.genealogyRepo.FindProduced(rma.ProductID, "", rma.SerialNo); err == nil && len(produced) > 0 {
+		if origin, err := s.woRepo.GetByID(produced[0].WorkOrderID); err == nil {
+			wo.BOMID, wo.BOMVersion = origin.BOMID, origin.BOMVersion
+			wo.ProductName = origin.ProductName
+		}
+	}
+	if err := s.woRepo.Create(wo); err != nil {
+		return fmt.Errorf("创建维修工单失败: %w", err)
+	}
+
+	issue := &entity.InventoryTransaction{
+		ID:              uuid.New().String(),
+		MaterialID:      rma.ProductID,
+		MaterialCode:    rma.ProductCode,
+		WarehouseID:     rma.WarehouseID,
+		TransactionType: entity.TxTypeProductionOut,
+		Quantity:        -1,
+		BatchNo:         rma.LotNo,
+		LocationID:      rma.LocationID,
+		SerialNo:        rma.SerialNo,
+		ReferenceType:   "WO",
+		ReferenceID:     wo.ID,
+		ReferenceCode:   wo.WOCode,
+		Notes:           "退货维修发料 " + rma.RMACode,
+		CreatedBy:       userID,
+	}
+	if _, err := s.inventoryRepo.PostMovements([]repository.StockMovement{{Tx: issue, ReservationID: rma.ReservationID}}); err != nil {
+		return fmt.Errorf("退回件发料到维修工单失败: %w", err)
+	}
+	link := woLotLink(wo, entity.GenealogyConsume, issue, 1, userID)
+	if err := s.genealogyRepo.CreateLinks([]entity.LotGenealogy{link}); err != nil {
+		return err
+	}
+	rma.RepairWOID, rma.RepairWOCode = wo.ID, wo.WOCode
+	return nil
+}
+
+// createReplacementSO 换货订单零价、直接确认，按正常拣货发货流程发出新序列号
+func (s *RMAService) createReplacementSO(rma *entity.RMA, userID string) error {
+	now := time.Now()
+	channel, currency, address := entity.ChannelDirect, "CNY", ""
+	if orig, err := s.salesRepo.GetSOByID(rma.SOID); err == nil {
+		channel, currency, address = orig.Channel, orig.Currency, orig.ShippingAddress
+	}
+	so := &entity.SalesOrder{
+		ID:              uuid.New().String(),
+		SOCode:          fmt.Sprintf("SO-%s%04d", now.Format("20060102"), now.UnixNano()%10000),
+		CustomerID:      rma.CustomerID,
+		Channel:         channel,
+		Status:          entity.SOStatusConfirmed,
+		Currency:        currency,
+		OrderDate:       &now,
+		ShippingAddress: address,
+		Notes:           fmt.Sprintf("退货授权 %s 换货，原序列号 %s", rma.RMACode, rma.SerialNo),
+		CreatedBy:       userID,
+	}
+	so.Items = []entity.SOItem{{
+		ID:          uuid.New().String(),
+		SOID:        so.ID,
+		ProductID:   rma.ProductID,
+		ProductCode: rma.ProductCode,
+		Quantity:    1,
+		Status:      entity.SOItemStatusOpen,
+		DueDate:     &now,
+	}}
+	if err := s.salesRepo.CreateSO(so); err != nil {
+		return fmt.Errorf("创建换货订单失败: %w", err)
+	}
+	rma.ReplacementSOID, rma.ReplacementSOCode = so.ID, so.SOCode
+	return nil
+}
+
+// createCreditNote 退款贷项通知，金额默认取原订单该产品行单价
+func (s *RMAService) createCreditNote(rma *entity.RMA, amount float64, userID string) error {
+	currency := "CNY"
+	if orig, err := s.salesRepo.GetSOByID(rma.SOID); err == nil {
+		currency = orig.Currency
+		if amount <= 0 {
+			for _, item := range orig.Items {
+				if item.ProductID == rma.ProductID {
+					amount = item.UnitPrice
+					break
+				}
+			}
+		}
+	}
+	if amount <= 0 {
+		return fmt.Errorf("无法确定退款金额，请填写 credit_amount")
+	}
+	record := &entity.FinanceRecord{
+		ID:             uuid.New().String(),
+		RecordCode:     fmt.Sprintf("CN-%s%04d", time.Now().Format("20060102"), time.Now().UnixNano()%10000),
+		RecordType:     entity.FinanceRecordCreditNote,
+		ReferenceType:  entity.RMAReferenceType,
+		ReferenceID:    rma.ID,
+		ReferenceCode:  rma.RMACode,
+		CounterpartyID: rma.CustomerID,
+		Amount:         amount,
+		Currency:       currency,
+		Status:         "PENDING",
+		Notes:          fmt.Sprintf("退货退款，序列号 %s", rma.SerialNo),
+		CreatedBy:      userID,
+	}
+	if rma.Customer != nil {
+		record.CounterpartyName = rma.Customer.Name
+	}
+	if err := s.financeRepo.CreateFinanceRecord(record); err != nil {
+		return fmt.Errorf("创建贷项通知失败: %w", err)
+	}
+	rma.CreditNoteID, rma.CreditNoteCode, rma.CreditAmount = record.ID, record.RecordCode, amount
+	return nil
+}
+
+// Close 结案：维修需维修工单已完工
+func (s *RMAService) Close(id string) (*entity.RMA, error) {
+	rma, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("退货授权不存在: %w", err)
+	}
+	switch rma.Status {
+	case entity.RMAStatusRepairing:
+		wo, err := s.woRepo.GetByID(rma.RepairWOID)
+		if err != nil {
+			return nil, fmt.Errorf("维修工单不存在: %w", err)
+		}
+		if wo.Status != entity.WOStatusCompleted && wo.Status != entity.WOStatusClosed {
+			return nil, fmt.Errorf("维修工单 %s 未完工", wo.WOCode)
+		}
+	case entity.RMAStatusResolved, entity.RMAStatusRejected:
+	default:
+		return nil, fmt.Errorf("退货授权状态不允许结案: %s", rma.Status)
+	}
+	now := time.Now()
+	rma.Status = entity.RMAStatusClosed
+	rma.ClosedAt = &now
+	if err := s.repo.Update(rma); err != nil {
+		return nil, err
+	}
+	return rma, nil
+}
+
+// RMATrace 退货授权及其处理结果
+type RMATrace struct {
+	entity.RMA
+	RepairWO             *entity.WorkOrder      `json:"repair_wo,omitempty"`
+	ReplacementShipments []entity.ShipmentTrace `json:"replacement_shipments,omitempty"`
+}
+
+// SerialServiceTrace 序列号售后全链路：生产、发货、服务工单、退货授权及维修/换货/退款
+type SerialServiceTrace struct {
+	SerialNo      string                 `json:"serial_no"`
+	Warranty      *SerialWarranty        `json:"warranty,omitempty"`
+	Produced      []entity.LotGenealogy  `json:"produced"`
+	Shipments     []entity.ShipmentTrace `json:"shipments"`
+	ServiceOrders []entity.ServiceOrder  `json:"service_orders"`
+	RMAs          []RMATrace             `json:"rmas"`
+}
+
+func (s *RMAService) TraceSerial(serialNo string) (*SerialServiceTrace, error) {
+	trace := &SerialServiceTrace{SerialNo: serialNo}
+	w, err := s.VerifySerial(serialNo)
+	if err != nil && !errors.Is(err, ErrSerialNotShipped) {
+		return nil, err
+	}
+	trace.Warranty = w
+	if trace.Produced, err = s.genealogyRepo.FindProduced("", "", serialNo); err != nil {
+		return nil, err
+	}
+	if trace.Shipments, err = s.genealogyRepo.FindShipments("", "", serialNo); err != nil {
+		return nil, err
+	}
+	if trace.ServiceOrders, err = s.repo.FindServiceOrdersBySerial(serialNo); err != nil {
+		return nil, err
+	}
+	rmas, err := s.repo.FindBySerial(serialNo)
+	if err != nil {
+		return nil, err
+	}
+	for _, rma := range rmas {
+		t := RMATrace{RMA: rma}
+		if rma.RepairWOID != "" {
+			if wo, err := s.woRepo.GetByID(rma.RepairWOID); err == nil {
+				t.RepairWO = wo
+			}
+		}
+		if rma.ReplacementSOID != "" {
+			shipments, err := s.genealogyRepo.FindShipmentsBySO(rma.ReplacementSOID)
+			if err != nil {
+				return nil, err
+			}
+			t.ReplacementShipments = shipments
+		}
+		trace.RMAs = append(trace.RMAs, t)
+	}
+	return trace, nil
+}
+
+func (s *RMAService) ListWarrantyPolicies() ([]entity.WarrantyPolicy, error) {
+	return s.repo.ListWarrantyPolicies()
+}
+
+type SaveWarrantyPolicyRequest struct {
+	ProductID      string `json:"product_id" binding:"required"`
+	WarrantyMonths int    `json:"warranty_months" binding:"required,gt=0"`
+	Notes          string `json:"notes"`
+}
+
+func (s *RMAService) SaveWarrantyPolicy(req SaveWarrantyPolicyRequest, userID string) (*entity.WarrantyPolicy, error) {
+	p := &entity.WarrantyPolicy{
+		ID:             uuid.New().String(),
+		ProductID:      req.ProductID,
+		WarrantyMonths: req.WarrantyMonths,
+		Notes:          req.Notes,
+		UpdatedBy:      userID,
+	}
+	if err := s.repo.UpsertWarrantyPolicy(p); err != nil {
+		return nil, fmt.Errorf("保存保修政策失败: %w", err)
+	}
+	return p, nil
+}
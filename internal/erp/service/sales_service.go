@@ -1,21 +1,50 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/erp/entity"
 	"github.com/bitfantasy/nimo/internal/erp/repository"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type SalesService struct {
 	repo          *repository.SalesRepository
 	inventoryRepo *repository.InventoryRepository
+	genealogyRepo *repository.GenealogyRepository
+	atp           *ATPService
+	rma           *RMAService
+	warranty      *WarrantyService
 }
 
-func NewSalesService(repo *repository.SalesRepository, invRepo *repository.InventoryRepository) *SalesService {
-	return &SalesService{repo: repo, inventoryRepo: invRepo}
+func NewSalesService(repo *repository.SalesRepository, invRepo *repository.InventoryRepository, genealogyRepo *repository.GenealogyRepository) *SalesService {
+	return &SalesService{repo: repo, inventoryRepo: invRepo, genealogyRepo: genealogyRepo}
+}
+
+// SetATP 设置交期承诺计算（可选依赖，未设置时订单不做交期承诺）
+func (s *SalesService) SetATP(atp *ATPService) {
+	s.atp = atp
+}
+
+// SetRMA 设置售后序列号校验（可选依赖，未设置时服务工单不校验序列号）
+func (s *SalesService) SetRMA(rma *RMAService) {
+	s.rma = rma
+}
+
+// SetWarranty 设置故障代码校验（可选依赖，未设置时不校验服务工单的故障代码）
+func (s *SalesService) SetWarranty(warranty *WarrantyService) {
+	s.warranty = warranty
+}
+
+func (s *SalesService) checkFailureCode(code string) error {
+	if code == "" || s.warranty == nil {
+		return nil
+	}
+	return s.warranty.checkFailureCode(code)
 }
 
 // --- Customer ---
@@ -82,16 +111,18 @@ type CreateSORequest struct {
 	Channel         string         `json:"channel"`
 	ShippingAddress string         `json:"shipping_address"`
 	Currency        string         `json:"currency"`
+	PromiseMode     string         `json:"promise_mode"` // ATP（默认）, CTP
 	Notes           string         `json:"notes"`
 	Items           []CreateSOItem `json:"items" binding:"required,min=1"`
 }
 
 type CreateSOItem struct {
-	ProductID   string  `json:"product_id" binding:"required"`
-	ProductCode string  `json:"product_code"`
-	ProductName string  `json:"product_name"`
-	Quantity    float64 `json:"quantity" binding:"required,gt=0"`
-	UnitPrice   float64 `json:"unit_price" binding:"required,gt=0"`
+	ProductID   string     `json:"product_id" binding:"required"`
+	ProductCode string     `json:"product_code"`
+	ProductName string     `json:"product_name"`
+	Quantity    float64    `json:"quantity" binding:"required,gt=0"`
+	UnitPrice   float64    `json:"unit_price" binding:"required,gt=0"`
+	DueDate     *time.Time `json:"due_date"`
 }
 
 func (s *SalesService) CreateSO(req CreateSORequest, userID string) (*entity.SalesOrder, error) {
@@ -139,11 +170,23 @@ func (s *SalesService) CreateSO(req CreateSORequest, userID string) (*entity.Sal
 			UnitPrice:   item.UnitPrice,
 			Amount:      amount,
 			Status:      entity.SOItemStatusOpen,
+			DueDate:     item.DueDate,
 		})
 	}
 	so.TotalAmount = totalAmount
 	so.Items = items
 
+	// 未填要求交期的行按最早可发货日期承诺
+	if _, err := s.promiseItems(so, req.PromiseMode); err != nil {
+		return nil, err
+	}
+	for i := range so.Items {
+		if item := &so.Items[i]; item.DueDate == nil && item.PromisedDate != nil {
+			item.DueDate = item.PromisedDate
+			item.PromiseStatus = entity.PromiseStatusOK
+		}
+	}
+
 	if err := s.repo.CreateSO(so); err != nil {
 		return nil, fmt.Errorf("创建销售订单失败: %w", err)
 	}
@@ -158,6 +201,30 @@ func (s *SalesService) ListSOs(params repository.SOListParams) ([]entity.SalesOr
 	return s.repo.ListSOs(params)
 }
 
+// ErrCreditHold 订单超出客户信用额度或客户已信用冻结，订单转入信用冻结待审批
+var ErrCreditHold = errors.New("客户信用检查未通过，订单已转入信用冻结待审批")
+
+func (s *SalesService) GetCreditExposure(customerID string) (*repository.CreditExposure, error) {
+	return s.repo.GetCreditExposure(customerID, "")
+}
+
+// SetCustomerCreditHold 手工冻结/解冻客户信用
+func (s *SalesService) SetCustomerCreditHold(id string, hold bool, reason string) error {
+	customer, err := s.repo.GetCustomerByID(id)
+	if err != nil {
+		return fmt.Errorf("客户不存在: %w", err)
+	}
+	customer.CreditHold = hold
+	customer.CreditHoldReason = ""
+	if hold {
+		customer.CreditHoldReason = reason
+	}
+	return s.repo.UpdateCustomer(customer)
+}
+
+// ConfirmSO 确认订单前按最新供需重算交期承诺，有行不能按要求交期发货时返回 ErrCannotPromise；
+// 再检查客户信用：信用占用（不含本单）加本单金额超过额度，
+// 或客户已信用冻结时，订单转入 CREDIT_HOLD 并返回 ErrCreditHold，需经特批放行
 func (s *SalesService) ConfirmSO(id string) error {
 	so, err := s.repo.GetSOByID(id)
 	if err != nil {
@@ -166,11 +233,205 @@ func (s *SalesService) ConfirmSO(id string) error {
 	if so.Status != entity.SOStatusPending {
 		return fmt.Errorf("订单状态不允许确认: %s", so.Status)
 	}
-	so.Status = entity.SOStatusConfirmed
+	late, err := s.promiseItems(so, "")
+	if err != nil {
+		return err
+	}
+	if err := s.saveItemPromises(so); err != nil {
+		return err
+	}
+	if len(late) > 0 {
+		return fmt.Errorf("%w: %s", ErrCannotPromise, strings.Join(late, "; "))
+	}
+	// 锁定客户后重读订单状态并计算信用占用，同一客户的并发确认依次检查
+	var holdReason string
+	err = s.repo.DB().Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewSalesRepository(tx)
+		if err := repo.LockCustomer(so.CustomerID); err != nil {
+			return fmt.Errorf("客户不存在: %w", err)
+		}
+		order, err := repo.GetSOByID(id)
+		if err != nil {
+			return fmt.Errorf("销售订单不存在: %w", err)
+		}
+		if order.Status != entity.SOStatusPending {
+			return fmt.Errorf("订单状态不允许确认: %s", order.Status)
+		}
+		exp, err := repo.GetCreditExposure(order.CustomerID, order.ID)
+		if err != nil {
+			return fmt.Errorf("计算客户信用占用失败: %w", err)
+		}
+		order.CreditExposure = exp.Exposure
+		holdReason = creditHoldReason(exp, order.TotalAmount)
+		if holdReason != "" {
+			order.Status = entity.SOStatusCreditHold
+			order.CreditStatus = entity.SOCreditHeld
+			order.CreditNote = holdReason
+		} else {
+			order.Status = entity.SOStatusConfirmed
+			order.CreditStatus = entity.SOCreditPassed
+		}
+		return repo.UpdateSO(order)
+	})
+	if err != nil {
+		return err
+	}
+	if holdReason != "" {
+		return fmt.Errorf("%w: %s", ErrCreditHold, holdReason)
+	}
+	return nil
+}
+
+// creditHoldReason 返回信用检查不通过的原因，通过时为空。额度为 0 表示不限额
+func creditHoldReason(exp *repository.CreditExposure, amount float64) string {
+	if exp.CreditHold {
+		return "客户已信用冻结"
+	}
+	if exp.CreditLimit > 0 && exp.Exposure+amount > exp.CreditLimit {
+		return fmt.Sprintf("信用占用 %.2f + 本单 %.2f 超过信用额度 %.2f", exp.Exposure, amount, exp.CreditLimit)
+	}
+	return ""
+}
+
+type ReleaseCreditHoldRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Reason   string `json:"reason" binding:"required"`
+}
+
+// ReleaseCreditHold 信用特批：放行则订单确认，驳回则退回待确认
+func (s *SalesService) ReleaseCreditHold(id string, req ReleaseCreditHoldRequest, userID string) error {
+	so, err := s.repo.GetSOByID(id)
+	if err != nil {
+		return fmt.Errorf("销售订单不存在: %w", err)
+	}
+	if so.Status != entity.SOStatusCreditHold {
+		return fmt.Errorf("订单不在信用冻结状态: %s", so.Status)
+	}
+	now := time.Now()
+	so.CreditApprovedBy = userID
+	so.CreditApprovedAt = &now
+	so.CreditNote = req.Reason
+	if req.Decision == "approve" {
+		so.Status = entity.SOStatusConfirmed
+		so.CreditStatus = entity.SOCreditReleased
+	} else {
+		so.Status = entity.SOStatusPending
+		so.CreditStatus = entity.SOCreditRejected
+	}
 	return s.repo.UpdateSO(so)
 }
 
-func (s *SalesService) ShipSO(id, trackingNo, userID string) error {
+// ErrCannotPromise 订单行无法按要求交期发货
+var ErrCannotPromise = errors.New("订单交期无法承诺")
+
+// CheckATP 查询产品可承诺量与最早可发货日期
+func (s *SalesService) CheckATP(req ATPRequest) (*ATPResult, error) {
+	if s.atp == nil {
+		return nil, fmt.Errorf("未启用交期承诺")
+	}
+	return s.atp.Check(req)
+}
+
+// promiseItems 逐行计算交期承诺并写入订单行，返回不能按要求交期发货的行说明。
+// mode 为空时沿用行上次的承诺方式。同一订单多行同产品时，后行累计前行数量
+func (s *SalesService) promiseItems(so *entity.SalesOrder, mode string) ([]string, error) {
+	if s.atp == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	taken := make(map[string]float64)
+	var late []string
+	for i := range so.Items {
+		item := &so.Items[i]
+		open := item.Quantity - item.ShippedQty
+		if item.Status == entity.SOItemStatusClosed || open <= 1e-9 {
+			continue
+		}
+		lineMode := mode
+		if lineMode == "" {
+			lineMode = item.PromiseMode
+		}
+		res, err := s.atp.Check(ATPRequest{
+			ProductID:   item.ProductID,
+			Quantity:    taken[item.ProductID] + open,
+			RequestDate: item.DueDate,
+			Mode:        lineMode,
+			ExcludeSOID: so.ID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("产品 %s 交期承诺失败: %w", item.ProductCode, err)
+		}
+		taken[item.ProductID] += open
+		item.PromiseMode = res.Mode
+		item.PromiseStatus = res.Status
+		item.ATPQty = min(max(res.ATPQty-(taken[item.ProductID]-open), 0), open)
+		item.PromisedDate = res.PromisedDate
+		item.PromiseNote = res.Note
+		item.PromisedAt = &now
+		if res.Status == entity.PromiseStatusUnavailable || (res.Status == entity.PromiseStatusLate && item.DueDate != nil) {
+			late = append(late, fmt.Sprintf("%s %s", item.ProductCode, res.Note))
+		}
+	}
+	return late, nil
+}
+
+func (s *SalesService) saveItemPromises(so *entity.SalesOrder) error {
+	for i := range so.Items {
+		if err := s.repo.UpdateSOItem(&so.Items[i]); err != nil {
+			return fmt.Errorf("保存交期承诺失败: %w", err)
+		}
+	}
+	return nil
+}
+
+type PromiseSORequest struct {
+	Mode  string `json:"mode"`  // ATP, CTP，空为沿用行上次的方式
+	Adopt bool   `json:"adopt"` // 将无法按期的行的要求交期改为最早可发货日期
+}
+
+// PromiseSO 重算订单各行交期承诺；adopt 时以承诺日期作为新的要求交期
+func (s *SalesService) PromiseSO(id string, req PromiseSORequest) (*entity.SalesOrder, error) {
+	so, err := s.repo.GetSOByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("销售订单不存在: %w", err)
+	}
+	switch so.Status {
+	case entity.SOStatusPending, entity.SOStatusCreditHold, entity.SOStatusConfirmed, entity.SOStatusPicking:
+	default:
+		return nil, fmt.Errorf("订单状态不允许重算交期: %s", so.Status)
+	}
+	if _, err := s.promiseItems(so, req.Mode); err != nil {
+		return nil, err
+	}
+	if req.Adopt {
+		for i := range so.Items {
+			item := &so.Items[i]
+			if item.PromiseStatus == entity.PromiseStatusLate {
+				item.DueDate = item.PromisedDate
+				item.PromiseStatus = entity.PromiseStatusOK
+				item.PromiseNote = ""
+			}
+		}
+	}
+	if err := s.saveItemPromises(so); err != nil {
+		return nil, err
+	}
+	return so, nil
+}
+
+type ShipLot struct {
+	ProductID string  `json:"product_id" binding:"required"`
+	LotNo     string  `json:"lot_no" binding:"required"`
+	Quantity  float64 `json:"quantity" binding:"required,gt=0"`
+}
+
+type ShipSORequest struct {
+	TrackingNo string    `json:"tracking_no"`
+	SerialNos  []string  `json:"serial_nos"` // 发出的成品序列号
+	Lots       []ShipLot `json:"lots"`       // 无序列号产品发出的批次
+}
+
+func (s *SalesService) ShipSO(id string, req ShipSORequest, userID string) error {
 	so, err := s.repo.GetSOByID(id)
 	if err != nil {
 		return fmt.Errorf("销售订单不存在: %w", err)
@@ -179,10 +440,78 @@ func (s *SalesService) ShipSO(id, trackingNo, userID string) error {
 		return fmt.Errorf("订单状态不允许发货: %s", so.Status)
 	}
 	now := time.Now()
+	traces, err := s.buildShipmentTraces(so, req, now, userID)
+	if err != nil {
+		return err
+	}
 	so.Status = entity.SOStatusShipped
 	so.ShippingDate = &now
-	so.TrackingNo = trackingNo
-	return s.repo.UpdateSO(so)
+	so.TrackingNo = req.TrackingNo
+	// 订单状态与发货追溯同时落库，避免已发货订单缺少序列号追溯
+	return s.repo.DB().Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewSalesRepository(tx).UpdateSO(so); err != nil {
+			return err
+		}
+		return repository.NewGenealogyRepository(tx).CreateShipments(traces)
+	})
+}
+
+// buildShipmentTraces 校验发出的序列号/批次属于订单产品，生成发货追溯记录
+func (s *SalesService) buildShipmentTraces(so *entity.SalesOrder, req ShipSORequest, shippedAt time.Time, userID string) ([]entity.ShipmentTrace, error) {
+	products := make(map[string]entity.SOItem, len(so.Items))
+	for _, item := range so.Items {
+		products[item.ProductID] = item
+	}
+	trace := func(productID, lotNo, serialNo string, qty float64) entity.ShipmentTrace {
+		t := entity.ShipmentTrace{
+			ID:          uuid.New().String(),
+			SOID:        so.ID,
+			SOCode:      so.SOCode,
+			CustomerID:  so.CustomerID,
+			ProductID:   productID,
+			ProductCode: products[productID].ProductCode,
+			SerialNo:    serialNo,
+			LotNo:       lotNo,
+			Quantity:    qty,
+			TrackingNo:  req.TrackingNo,
+			ShippedAt:   shippedAt,
+			CreatedBy:   userID,
+		}
+		if so.Customer != nil {
+			t.CustomerName = so.Customer.Name
+		}
+		return t
+	}
+
+	var traces []entity.ShipmentTrace
+	for _, sn := range req.SerialNos {
+		produced, err := s.genealogyRepo.FindProduced("", "", sn)
+		if err != nil {
+			return nil, err
+		}
+		if len(produced) == 0 {
+			return nil, fmt.Errorf("序列号 %s 没有生产记录", sn)
+		}
+		p := produced[0]
+		if _, ok := products[p.MaterialID]; !ok {
+			return nil, fmt.Errorf("序列号 %s 的产品 %s 不在订单中", sn, p.MaterialCode)
+		}
+		shipped, err := s.genealogyRepo.FindShipments("", "", sn)
+		if err != nil {
+			return nil, err
+		}
+		if len(shipped) > 0 {
+			return nil, fmt.Errorf("序列号 %s 已随订单 %s 发出", sn, shipped[0].SOCode)
+		}
+		traces = append(traces, trace(p.MaterialID, p.LotNo, sn, 1))
+	}
+	for _, lot := range req.Lots {
+		if _, ok := products[lot.ProductID]; !ok {
+			return nil, fmt.Errorf("产品 %s 不在订单中", lot.ProductID)
+		}
+		traces = append(traces, trace(lot.ProductID, lot.LotNo, "", lot.Quantity))
+	}
+	return traces, nil
 }
 
 func (s *SalesService) CancelSO(id string) error {
@@ -205,10 +534,14 @@ type CreateServiceOrderRequest struct {
 	ServiceType string `json:"service_type" binding:"required"`
 	Priority    int    `json:"priority"`
 	Description string `json:"description" binding:"required"`
+	FailureCode string `json:"failure_code"`
 	Notes       string `json:"notes"`
 }
 
 func (s *SalesService) CreateServiceOrder(req CreateServiceOrderRequest, userID string) (*entity.ServiceOrder, error) {
+	if err := s.checkFailureCode(req.FailureCode); err != nil {
+		return nil, err
+	}
 	code := fmt.Sprintf("SVC-%s%04d", time.Now().Format("20060102"), time.Now().UnixNano()%10000)
 
 	svcOrder := &entity.ServiceOrder{
@@ -220,9 +553,15 @@ func (s *SalesService) CreateServiceOrder(req CreateServiceOrderRequest, userID
 		Status:      entity.SvcStatusCreated,
 		Priority:    req.Priority,
 		Description: req.Description,
+		FailureCode: req.FailureCode,
 		Notes:       req.Notes,
 		CreatedBy:   userID,
 	}
+	if s.rma != nil {
+		if err := s.rma.attachSerial(svcOrder); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := s.repo.CreateServiceOrder(svcOrder); err != nil {
 		return nil, fmt.Errorf("创建服务工单失败: %w", err)
@@ -249,11 +588,18 @@ func (s *SalesService) AssignServiceOrder(id, assigneeID, assigneeName string) e
 	return s.repo.UpdateServiceOrder(so)
 }
 
-func (s *SalesService) CompleteServiceOrder(id, solution string) error {
+// CompleteServiceOrder 完工时可补录或修正故障代码
+func (s *SalesService) CompleteServiceOrder(id, solution, failureCode string) error {
 	so, err := s.repo.GetServiceOrderByID(id)
 	if err != nil {
 		return fmt.Errorf("服务工单不存在: %w", err)
 	}
+	if err := s.checkFailureCode(failureCode); err != nil {
+		return err
+	}
+	if failureCode != "" {
+		so.FailureCode = failureCode
+	}
 	now := time.Now()
 	so.Status = entity.SvcStatusCompleted
 	so.Solution = solution
@@ -12,16 +12,46 @@ type Services struct {
 	Inventory     *InventoryService
 	Manufacturing *ManufacturingService
 	MRP           *MRPService
+	MPS           *MPSService
 	Sales         *SalesService
+	ATP           *ATPService
+	Genealogy     *GenealogyService
+	Warehouse     *WarehouseService
+	CycleCount    *CycleCountService
+	WorkCenter    *WorkCenterService
+	RMA           *RMAService
+	Warranty      *WarrantyService
 }
 
 func NewServices(repos *repository.Repositories, db *gorm.DB) *Services {
+	warehouse := NewWarehouseService(repos.Warehouse, repos.Inventory, repos.WorkOrder, repos.Sales)
+	inventory := NewInventoryService(repos.Inventory)
+	inventory.SetPutaway(warehouse)
+	atp := NewATPService(repos.Inventory, repos.Purchase, repos.WorkOrder, repos.Sales, repos.MRP, db)
+	sales := NewSalesService(repos.Sales, repos.Inventory, repos.Genealogy)
+	sales.SetATP(atp)
+	rma := NewRMAService(repos.RMA, repos.Sales, repos.Genealogy, repos.Inventory, repos.WorkOrder, repos.MRP)
+	sales.SetRMA(rma)
+	genealogy := NewGenealogyService(repos.Genealogy)
+	warranty := NewWarrantyService(repos.Warranty, repos.Sales, repos.Genealogy, repos.WorkOrder, repos.MRP, rma, genealogy)
+	sales.SetWarranty(warranty)
+	manufacturing := NewManufacturingService(repos.WorkOrder, repos.Inventory, repos.Genealogy, repos.WorkCenter, warehouse, db)
+	mrp := NewMRPService(repos.MRP, repos.Purchase, repos.Inventory, repos.WorkOrder, repos.Sales, repos.MPS, db)
+	mrp.SetManufacturing(manufacturing)
 	return &Services{
 		Supplier:      NewSupplierService(repos.Supplier),
-		Procurement:   NewProcurementService(repos.Purchase, repos.Supplier, repos.Inventory),
-		Inventory:     NewInventoryService(repos.Inventory),
-		Manufacturing: NewManufacturingService(repos.WorkOrder, repos.Inventory, db),
-		MRP:           NewMRPService(repos.MRP, repos.Purchase, repos.Inventory, repos.WorkOrder, repos.Sales, db),
-		Sales:         NewSalesService(repos.Sales, repos.Inventory),
+		Procurement:   NewProcurementService(repos.Purchase, repos.Supplier, repos.Inventory, warehouse),
+		Inventory:     inventory,
+		Manufacturing: manufacturing,
+		MRP:           mrp,
+		MPS:           NewMPSService(repos.MPS, repos.MRP, repos.Sales, db),
+		Sales:         sales,
+		ATP:           atp,
+		Genealogy:     genealogy,
+		Warehouse:     warehouse,
+		CycleCount:    NewCycleCountService(repos.CycleCount, repos.Inventory),
+		WorkCenter:    NewWorkCenterService(repos.WorkCenter, repos.WorkOrder),
+		RMA:           rma,
+		Warranty:      warranty,
 	}
 }
@@ -0,0 +1,593 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/google/uuid"
+)
+
+type WarehouseService struct {
+	repo          *repository.WarehouseRepository
+	inventoryRepo *repository.InventoryRepository
+	woRepo        *repository.WorkOrderRepository
+	salesRepo     *repository.SalesRepository
+}
+
+func NewWarehouseService(repo *repository.WarehouseRepository, invRepo *repository.InventoryRepository, woRepo *repository.WorkOrderRepository, salesRepo *repository.SalesRepository) *WarehouseService {
+	return &WarehouseService{repo: repo, inventoryRepo: invRepo, woRepo: woRepo, salesRepo: salesRepo}
+}
+
+func (s *WarehouseService) List() ([]entity.Warehouse, error) {
+	return s.repo.List()
+}
+
+// WarehouseLayout 仓库库区/库位及各库位占用
+type WarehouseLayout struct {
+	Warehouse *entity.Warehouse      `json:"warehouse"`
+	Zones     []entity.WarehouseZone `json:"zones"`
+	Usage     map[string]float64     `json:"usage"` // 库位ID → 占用数量
+}
+
+func (s *WarehouseService) GetLayout(warehouseID string) (*WarehouseLayout, error) {
+	wh, err := s.repo.GetByID(warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("仓库不存在: %w", err)
+	}
+	zones, err := s.repo.ListZones(warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.inventoryRepo.LocationUsage(warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	return &WarehouseLayout{Warehouse: wh, Zones: zones, Usage: usage}, nil
+}
+
+type CreateZoneRequest struct {
+	Code     string `json:"code" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	ZoneType string `json:"zone_type"` // RAW, WIP, FG, SPARE
+	Sequence int    `json:"sequence"`
+}
+
+func (s *WarehouseService) CreateZone(warehouseID string, req CreateZoneRequest) (*entity.WarehouseZone, error) {
+	if _, err := s.repo.GetByID(warehouseID); err != nil {
+		return nil, fmt.Errorf("仓库不存在: %w", err)
+	}
+	zone := &entity.WarehouseZone{
+		ID:          uuid.New().String(),
+		WarehouseID: warehouseID,
+		Code:        req.Code,
+		Name:        req.Name,
+		ZoneType:    req.ZoneType,
+		Sequence:    req.Sequence,
+		Status:      entity.WarehouseStatusActive,
+	}
+	if err := s.repo.CreateZone(zone); err != nil {
+		return nil, err
+	}
+	return zone, nil
+}
+
+type CreateLocationRequest struct {
+	Code     string  `json:"code" binding:"required"`
+	Name     string  `json:"name"`
+	Capacity float64 `json:"capacity"` // 0=不限
+	Sequence int     `json:"sequence"`
+}
+
+func (s *WarehouseService) CreateLocation(warehouseID, zoneID string, req CreateLocationRequest) (*entity.WarehouseLocation, error) {
+	zone, err := s.repo.GetZone(zoneID)
+	if err != nil {
+		return nil, fmt.Errorf("库区不存在: %w", err)
+	}
+	if zone.WarehouseID != warehouseID {
+		return nil, fmt.Errorf("库区 %s 不属于该仓库", zone.Code)
+	}
+	loc := &entity.WarehouseLocation{
+		ID:       uuid.New().String(),
+		ZoneID:   zoneID,
+		Code:     req.Code,
+		Name:     req.Name,
+		Capacity: req.Capacity,
+		Sequence: req.Sequence,
+		Status:   entity.WarehouseStatusActive,
+	}
+	if err := s.repo.CreateLocation(loc); err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+// --- 上架 ---
+
+type CreatePutawayRuleRequest struct {
+	Priority           int    `json:"priority"`
+	MaterialCategoryID string `json:"material_category_id"`
+	InventoryType      string `json:"inventory_type"`
+	ZoneID             string `json:"zone_id"`
+	ZoneType           string `json:"zone_type"`
+	Notes              string `json:"notes"`
+}
+
+func (s *WarehouseService) CreatePutawayRule(warehouseID string, req CreatePutawayRuleRequest, userID string) (*entity.PutawayRule, error) {
+	if req.ZoneID == "" && req.ZoneType == "" {
+		return nil, fmt.Errorf("请指定目标库区或库区类型")
+	}
+	if req.ZoneID != "" {
+		zone, err := s.repo.GetZone(req.ZoneID)
+		if err != nil {
+			return nil, fmt.Errorf("库区不存在: %w", err)
+		}
+		if zone.WarehouseID != warehouseID {
+			return nil, fmt.Errorf("库区 %s 不属于该仓库", zone.Code)
+		}
+	}
+	rule := &entity.PutawayRule{
+		ID:                 uuid.New().String(),
+		WarehouseID:        warehouseID,
+		Priority:           req.Priority,
+		MaterialCategoryID: req.MaterialCategoryID,
+		InventoryType:      req.InventoryType,
+		ZoneID:             req.ZoneID,
+		ZoneType:           req.ZoneType,
+		Status:             entity.WarehouseStatusActive,
+		Notes:              req.Notes,
+		CreatedBy:          userID,
+	}
+	if err := s.repo.CreatePutawayRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *WarehouseService) ListPutawayRules(warehouseID string) ([]entity.PutawayRule, error) {
+	return s.repo.ListPutawayRules(warehouseID)
+}
+
+func (s *WarehouseService) DeletePutawayRule(id string) error {
+	return s.repo.DeletePutawayRule(id)
+}
+
+type PutawayRequest struct {
+	MaterialID    string  `json:"material_id" binding:"required"`
+	WarehouseID   string  `json:"warehouse_id" binding:"required"`
+	InventoryType string  `json:"inventory_type"`
+	Quantity      float64 `json:"quantity" binding:"required,gt=0"`
+}
+
+// PutawaySuggestion 建议上架库位
+type PutawaySuggestion struct {
+	ZoneID       string  `json:"zone_id"`
+	ZoneCode     string  `json:"zone_code"`
+	LocationID   string  `json:"location_id"`
+	LocationCode string  `json:"location_code"`
+	Quantity     float64 `json:"quantity"`
+	Reason       string  `json:"reason"`
+}
+
+type PutawayPlan struct {
+	Suggestions []PutawaySuggestion `json:"suggestions"`
+	Unplaced    float64             `json:"unplaced"` // 无合适库位的数量
+}
+
+// putawayContext 一次上架计算内共享的库区、规则与库位占用，连续上架时占用累加
+type putawayContext struct {
+	zones  []entity.WarehouseZone
+	rules  []entity.PutawayRule
+	usage  map[string]float64
+	frozen map[string]bool // 盘点冻结的库位
+}
+
+func (s *WarehouseService) loadPutawayContext(warehouseID string) (*putawayContext, error) {
+	zones, err := s.repo.ListZones(warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.ListPutawayRules(warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.inventoryRepo.LocationUsage(warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	frozen, err := s.inventoryRepo.FrozenLocations(warehouseID, "")
+	if err != nil {
+		return nil, err
+	}
+	return &putawayContext{zones: zones, rules: rules, usage: usage, frozen: frozen}, nil
+}
+
+// SuggestPutaway 上架建议：按规则优先级选库区，库区内优先已存放该物料的库位，再按路径顺序，受库位容量约束
+func (s *WarehouseService) SuggestPutaway(req PutawayRequest) (*PutawayPlan, error) {
+	ctx, err := s.loadPutawayContext(req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	return s.plan(ctx, req)
+}
+
+func (s *WarehouseService) plan(ctx *putawayContext, req PutawayRequest) (*PutawayPlan, error) {
+	invType := req.InventoryType
+	if invType == "" {
+		invType = entity.InventoryTypeRaw
+	}
+	category, err := s.repo.GetMaterialCategory(req.MaterialID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 候选库区：命中的规则按优先级，未命中任何规则时按库区类型匹配库存类型
+	var candidates []*entity.WarehouseZone
+	reasons := make(map[string]string)
+	added := make(map[string]bool)
+	addZone := func(z *entity.WarehouseZone, reason string) {
+		if added[z.ID] || z.Status != entity.WarehouseStatusActive {
+			return
+		}
+		added[z.ID] = true
+		candidates = append(candidates, z)
+		reasons[z.ID] = reason
+	}
+	for _, rule := range ctx.rules {
+		if rule.MaterialCategoryID != "" && rule.MaterialCategoryID != category {
+			continue
+		}
+		if rule.InventoryType != "" && rule.InventoryType != invType {
+			continue
+		}
+		for i := range ctx.zones {
+			z := &ctx.zones[i]
+			if z.ID == rule.ZoneID || (rule.ZoneID == "" && z.ZoneType == rule.ZoneType) {
+				addZone(z, fmt.Sprintf("上架规则(优先级%d)", rule.Priority))
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		for i := range ctx.zones {
+			if ctx.zones[i].ZoneType == invType {
+				addZone(&ctx.zones[i], "库区类型匹配 "+invType)
+			}
+		}
+	}
+
+	holding := make(map[string]bool)
+	lots, err := s.inventoryRepo.ListLotBalances(req.MaterialID, req.WarehouseID, entity.PickStrategyFIFO)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lots {
+		holding[l.LocationID] = true
+	}
+
+	plan := &PutawayPlan{Suggestions: make([]PutawaySuggestion, 0)}
+	remaining := req.Quantity
+	for _, z := range candidates {
+		locs := make([]entity.WarehouseLocation, 0, len(z.Locations))
+		for _, l := range z.Locations {
+			if l.Status == entity.WarehouseStatusActive && !ctx.frozen[l.ID] {
+				locs = append(locs, l)
+			}
+		}
+		// 已存放该物料的库位优先（合并存放），其余保持路径顺序
+		sort.SliceStable(locs, func(i, j int) bool { return holding[locs[i].ID] && !holding[locs[j].ID] })
+		for _, l := range locs {
+			if remaining <= 1e-9 {
+				break
+			}
+			free := remaining
+			if l.Capacity > 0 {
+				free = math.Min(remaining, l.Capacity-ctx.usage[l.ID])
+			}
+			if free <= 1e-9 {
+				continue
+			}
+			reason := reasons[z.ID]
+			if holding[l.ID] {
+				reason += "，合并已有库存"
+			}
+			plan.Suggestions = append(plan.Suggestions, PutawaySuggestion{
+				ZoneID: z.ID, ZoneCode: z.Code, LocationID: l.ID, LocationCode: l.Code, Quantity: free, Reason: reason,
+			})
+			ctx.usage[l.ID] += free
+			remaining -= free
+		}
+	}
+	plan.Unplaced = math.Max(remaining, 0)
+	return plan, nil
+}
+
+// PlaceInbound 为未指定库位的入库变动分配库位，超出库位容量的部分保留为未上架；
+// 仓库未配置库区时原样返回
+func (s *WarehouseService) PlaceInbound(moves []repository.StockMovement) ([]repository.StockMovement, error) {
+	contexts := make(map[string]*putawayContext)
+	placed := make([]repository.StockMovement, 0, len(moves))
+	for _, m := range moves {
+		t := m.Tx
+		if t.Quantity <= 0 || t.LocationID != "" {
+			placed = append(placed, m)
+			continue
+		}
+		ctx, ok := contexts[t.WarehouseID]
+		if !ok {
+			var err error
+			if ctx, err = s.loadPutawayContext(t.WarehouseID); err != nil {
+				return nil, err
+			}
+			contexts[t.WarehouseID] = ctx
+		}
+		if len(ctx.zones) == 0 {
+			placed = append(placed, m)
+			continue
+		}
+		plan, err := s.plan(ctx, PutawayRequest{MaterialID: t.MaterialID, WarehouseID: t.WarehouseID, InventoryType: m.InventoryType, Quantity: t.Quantity})
+		if err != nil {
+			return nil, err
+		}
+		pieces := make([]float64, 0, len(plan.Suggestions)+1)
+		locations := make([]string, 0, len(plan.Suggestions)+1)
+		for _, sg := range plan.Suggestions {
+			pieces = append(pieces, sg.Quantity)
+			locations = append(locations, sg.LocationID)
+		}
+		if plan.Unplaced > 1e-9 {
+			pieces = append(pieces, plan.Unplaced)
+			locations = append(locations, "")
+		}
+		for i, qty := range pieces {
+			tx := *t
+			if i > 0 {
+				tx.ID = uuid.New().String()
+			}
+			tx.Quantity = qty
+			tx.LocationID = locations[i]
+			piece := m
+			piece.Tx = &tx
+			placed = append(placed, piece)
+		}
+	}
+	return placed, nil
+}
+
+// --- 移库 ---
+
+type MoveBinRequest struct {
+	MaterialID     string  `json:"material_id" binding:"required"`
+	WarehouseID    string  `json:"warehouse_id" binding:"required"`
+	FromLocationID string  `json:"from_location_id" binding:"required"`
+	ToLocationID   string  `json:"to_location_id" binding:"required"`
+	BatchNo        string  `json:"batch_no"`
+	Quantity       float64 `json:"quantity" binding:"required,gt=0"`
+	Notes          string  `json:"notes"`
+}
+
+// MoveBin 库位间移库
+func (s *WarehouseService) MoveBin(req MoveBinRequest, userID string) ([]entity.InventoryTransaction, error) {
+	if req.FromLocationID == req.ToLocationID {
+		return nil, fmt.Errorf("源库位与目标库位相同")
+	}
+	from, err := s.warehouseLocation(req.FromLocationID, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.warehouseLocation(req.ToLocationID, req.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	if to.Status != entity.WarehouseStatusActive {
+		return nil, fmt.Errorf("目标库位 %s 已停用", to.Code)
+	}
+	txs, err := s.inventoryRepo.MoveBin(repository.BinMove{
+		MaterialID:     req.MaterialID,
+		WarehouseID:    req.WarehouseID,
+		FromLocationID: from.ID,
+		ToLocationID:   to.ID,
+		ToCapacity:     to.Capacity,
+		BatchNo:        req.BatchNo,
+		Quantity:       req.Quantity,
+		Notes:          req.Notes,
+		CreatedBy:      userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("移库失败: %w", err)
+	}
+	return txs, nil
+}
+
+func (s *WarehouseService) warehouseLocation(locationID, warehouseID string) (*entity.WarehouseLocation, error) {
+	loc, err := s.repo.GetLocation(locationID)
+	if err != nil {
+		return nil, fmt.Errorf("库位不存在: %w", err)
+	}
+	if loc.Zone == nil || loc.Zone.WarehouseID != warehouseID {
+		return nil, fmt.Errorf("库位 %s 不属于该仓库", loc.Code)
+	}
+	return loc, nil
+}
+
+// --- 拣货 ---
+
+// PickLine 拣货行：从某库位某批次拣多少
+type PickLine struct {
+	MaterialID   string     `json:"material_id"`
+	MaterialCode string     `json:"material_code"`
+	MaterialName string     `json:"material_name"`
+	BatchNo      string     `json:"batch_no"`
+	LocationID   string     `json:"location_id"`
+	LocationCode string     `json:"location_code"`
+	Quantity     float64    `json:"quantity"`
+	ExpiryDate   *time.Time `json:"expiry_date,omitempty"`
+
+	zoneIdx, locSeq int
+}
+
+// PickZone 一个库区内按路径顺序排列的拣货行
+type PickZone struct {
+	ZoneID   string     `json:"zone_id"`
+	ZoneCode string     `json:"zone_code"`
+	ZoneName string     `json:"zone_name"`
+	Sequence int        `json:"sequence"`
+	Lines    []PickLine `json:"lines"`
+}
+
+// PickShortage 库存不足的物料
+type PickShortage struct {
+	MaterialID   string  `json:"material_id"`
+	MaterialCode string  `json:"material_code"`
+	Required     float64 `json:"required"`
+	Shortage     float64 `json:"shortage"`
+	ExpiredQty   float64 `json:"expired_qty,omitempty"` // 因过期未分配的数量
+}
+
+// PickList 拣货单：按库区分组、按路径顺序排列；未上架的库存单独列出
+type PickList struct {
+	ReferenceType string         `json:"reference_type"`
+	ReferenceID   string         `json:"reference_id"`
+	ReferenceCode string         `json:"reference_code"`
+	WarehouseID   string         `json:"warehouse_id"`
+	Strategy      string         `json:"strategy"`
+	Zones         []PickZone     `json:"zones"`
+	Unlocated     []PickLine     `json:"unlocated"`
+	Shortages     []PickShortage `json:"shortages"`
+}
+
+type pickDemand struct {
+	materialID, code, name string
+	qty                    float64
+}
+
+// WorkOrderPickList 工单领料拣货单
+func (s *WarehouseService) WorkOrderPickList(woID, warehouseID, strategy string) (*PickList, error) {
+	wo, err := s.woRepo.GetByID(woID)
+	if err != nil {
+		return nil, fmt.Errorf("工单不存在: %w", err)
+	}
+	var demands []pickDemand
+	for _, m := range wo.Materials {
+		if need := m.RequiredQty - m.IssuedQty; need > 0 {
+			demands = append(demands, pickDemand{m.MaterialID, m.MaterialCode, m.MaterialName, need})
+		}
+	}
+	return s.buildPickList("WO", wo.ID, wo.WOCode, warehouseID, strategy, demands)
+}
+
+// SalesOrderPickList 销售订单发货拣货单
+func (s *WarehouseService) SalesOrderPickList(soID, warehouseID, strategy string) (*PickList, error) {
+	so, err := s.salesRepo.GetSOByID(soID)
+	if err != nil {
+		return nil, fmt.Errorf("销售订单不存在: %w", err)
+	}
+	var demands []pickDemand
+	for _, item := range so.Items {
+		if need := item.Quantity - item.ShippedQty; need > 0 {
+			demands = append(demands, pickDemand{item.ProductID, item.ProductCode, item.ProductName, need})
+		}
+	}
+	return s.buildPickList("SO", so.ID, so.SOCode, warehouseID, strategy, demands)
+}
+
+// ListLotBalances 物料在仓库内各批次/库位的余额
+func (s *WarehouseService) ListLotBalances(materialID, warehouseID, strategy string) ([]repository.LotBalance, error) {
+	return s.inventoryRepo.ListLotBalances(materialID, warehouseID, strategy)
+}
+
+// SuggestPick 单个物料的拣货建议
+func (s *WarehouseService) SuggestPick(materialID, warehouseID string, qty float64, strategy string) (*PickList, error) {
+	return s.buildPickList("", "", "", warehouseID, strategy, []pickDemand{{materialID: materialID, qty: qty}})
+}
+
+// buildPickList 按与出库过账相同的策略分配批次/库位，再按库区、库位路径顺序排列
+func (s *WarehouseService) buildPickList(refType, refID, refCode, warehouseID, strategy string, demands []pickDemand) (*PickList, error) {
+	if warehouseID == "" {
+		return nil, fmt.Errorf("请指定仓库")
+	}
+	if strategy != entity.PickStrategyFIFO {
+		strategy = entity.PickStrategyFEFO
+	}
+	zones, err := s.repo.ListZones(warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	type binRef struct {
+		zoneIdx int
+		loc     entity.WarehouseLocation
+	}
+	bins := make(map[string]binRef)
+	for zi, z := range zones {
+		for _, l := range z.Locations {
+			bins[l.ID] = binRef{zoneIdx: zi, loc: l}
+		}
+	}
+
+	list := &PickList{
+		ReferenceType: refType, ReferenceID: refID, ReferenceCode: refCode,
+		WarehouseID: warehouseID, Strategy: strategy,
+		Zones: make([]PickZone, 0), Unlocated: make([]PickLine, 0), Shortages: make([]PickShortage, 0),
+	}
+	byZone := make(map[int][]PickLine)
+	now := time.Now()
+	for _, d := range demands {
+		lots, err := s.inventoryRepo.ListLotBalances(d.materialID, warehouseID, strategy)
+		if err != nil {
+			return nil, err
+		}
+		frozen, err := s.inventoryRepo.FrozenLocations(warehouseID, d.materialID)
+		if err != nil {
+			return nil, err
+		}
+		remaining := d.qty
+		var expired float64
+		for _, l := range lots {
+			if remaining <= 1e-9 {
+				break
+			}
+			if frozen[l.LocationID] {
+				continue
+			}
+			if l.ExpiryDate != nil && l.ExpiryDate.Before(now) {
+				expired += l.Quantity // 过期批次不拣，与出库过账一致
+				continue
+			}
+			take := math.Min(l.Quantity, remaining)
+			remaining -= take
+			line := PickLine{
+				MaterialID: d.materialID, MaterialCode: d.code, MaterialName: d.name,
+				BatchNo: l.BatchNo, LocationID: l.LocationID, Quantity: take, ExpiryDate: l.ExpiryDate,
+			}
+			bin, ok := bins[l.LocationID]
+			if !ok {
+				list.Unlocated = append(list.Unlocated, line)
+				continue
+			}
+			line.LocationCode = bin.loc.Code
+			line.zoneIdx, line.locSeq = bin.zoneIdx, bin.loc.Sequence
+			byZone[bin.zoneIdx] = append(byZone[bin.zoneIdx], line)
+		}
+		if remaining > 1e-9 {
+			list.Shortages = append(list.Shortages, PickShortage{MaterialID: d.materialID, MaterialCode: d.code, Required: d.qty, Shortage: remaining, ExpiredQty: expired})
+		}
+	}
+
+	// 库区已按路径顺序加载，库区内按库位顺序、库位编码排列
+	for zi, z := range zones {
+		lines, ok := byZone[zi]
+		if !ok {
+			continue
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			if lines[i].locSeq != lines[j].locSeq {
+				return lines[i].locSeq < lines[j].locSeq
+			}
+			return lines[i].LocationCode < lines[j].LocationCode
+		})
+		list.Zones = append(list.Zones, PickZone{ZoneID: z.ID, ZoneCode: z.Code, ZoneName: z.Name, Sequence: z.Sequence, Lines: lines})
+	}
+	return list, nil
+}
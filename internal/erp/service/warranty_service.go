@@ -0,0 +1,435 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownFailureCode 故障代码不存在或已停用
+	ErrUnknownFailureCode = errors.New("故障代码不存在或已停用")
+	// ErrClaimExists 服务工单已有未驳回的保修索赔
+	ErrClaimExists = errors.New("服务工单已有保修索赔")
+	// ErrClaimNotAttributed 索赔未归因到供应商，不能向供应商索赔
+	ErrClaimNotAttributed = errors.New("索赔未归因到供应商组件批次")
+)
+
+// WarrantyService 保修索赔与现场故障分析：故障代码、序列号谱系归因、返修率统计与供应商索赔
+type WarrantyService struct {
+	repo          *repository.WarrantyRepository
+	salesRepo     *repository.SalesRepository
+	genealogyRepo *repository.GenealogyRepository
+	woRepo        *repository.WorkOrderRepository
+	financeRepo   *repository.MRPRepository
+	rma           *RMAService
+	genealogy     *GenealogyService
+}
+
+func NewWarrantyService(
+	repo *repository.WarrantyRepository,
+	salesRepo *repository.SalesRepository,
+	genealogyRepo *repository.GenealogyRepository,
+	woRepo *repository.WorkOrderRepository,
+	financeRepo *repository.MRPRepository,
+	rma *RMAService,
+	genealogy *GenealogyService,
+) *WarrantyService {
+	return &WarrantyService{
+		repo:          repo,
+		salesRepo:     salesRepo,
+		genealogyRepo: genealogyRepo,
+		woRepo:        woRepo,
+		financeRepo:   financeRepo,
+		rma:           rma,
+		genealogy:     genealogy,
+	}
+}
+
+// --- Failure Code ---
+
+func (s *WarrantyService) ListFailureCodes(category, status string) ([]entity.FailureCode, error) {
+	return s.repo.ListFailureCodes(category, status)
+}
+
+type SaveFailureCodeRequest struct {
+	Code        string `json:"code" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+}
+
+func (s *WarrantyService) CreateFailureCode(req SaveFailureCodeRequest) (*entity.FailureCode, error) {
+	fc := &entity.FailureCode{
+		ID:          uuid.New().String(),
+		Code:        strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:        req.Name,
+		Category:    req.Category,
+		Description: req.Description,
+		Status:      entity.FailureCodeActive,
+	}
+	if req.Status == entity.FailureCodeInactive {
+		fc.Status = req.Status
+	}
+	if err := s.repo.CreateFailureCode(fc); err != nil {
+		return nil, fmt.Errorf("创建故障代码失败: %w", err)
+	}
+	return fc, nil
+}
+
+// UpdateFailureCode 修改故障代码名称、分类与状态；代码本身已被工单引用，不允许修改
+func (s *WarrantyService) UpdateFailureCode(id string, req SaveFailureCodeRequest) (*entity.FailureCode, error) {
+	fc, err := s.repo.GetFailureCodeByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("故障代码不存在: %w", err)
+	}
+	fc.Name = req.Name
+	fc.Category = req.Category
+	fc.Description = req.Description
+	if req.Status == entity.FailureCodeActive || req.Status == entity.FailureCodeInactive {
+		fc.Status = req.Status
+	}
+	if err := s.repo.UpdateFailureCode(fc); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// checkFailureCode 校验故障代码存在且启用
+func (s *WarrantyService) checkFailureCode(code string) error {
+	fc, err := s.repo.GetFailureCode(code)
+	if err != nil || fc.Status != entity.FailureCodeActive {
+		return fmt.Errorf("%w: %s", ErrUnknownFailureCode, code)
+	}
+	return nil
+}
+
+// --- Warranty Claim ---
+
+type CreateWarrantyClaimRequest struct {
+	ServiceOrderID      string  `json:"service_order_id" binding:"required"`
+	FailureCode         string  `json:"failure_code"` // 不填时取服务工单的故障代码
+	Description         string  `json:"description"`
+	ComponentMaterialID string  `json:"component_material_id"` // 已知责任组件时直接归因
+	ComponentLotNo      string  `json:"component_lot_no"`
+	ClaimCost           float64 `json:"claim_cost"` // 不填时取退货授权的退款金额
+}
+
+// CreateClaim 由服务工单发起保修索赔，按序列号带出发货、生产工单与 BOM 版本
+func (s *WarrantyService) CreateClaim(req CreateWarrantyClaimRequest, userID string) (*entity.WarrantyClaim, error) {
+	svc, err := s.salesRepo.GetServiceOrderByID(req.ServiceOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("服务工单不存在: %w", err)
+	}
+	code := req.FailureCode
+	if code == "" {
+		code = svc.FailureCode
+	}
+	if code == "" {
+		return nil, fmt.Errorf("请指定故障代码")
+	}
+	if err := s.checkFailureCode(code); err != nil {
+		return nil, err
+	}
+	if existing, err := s.repo.FindActiveClaimByServiceOrder(svc.ID); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("%w: %s", ErrClaimExists, existing.ClaimCode)
+	}
+
+	w, err := s.rma.VerifySerial(svc.ProductSN)
+	if err != nil {
+		return nil, err
+	}
+	desc := req.Description
+	if desc == "" {
+		desc = svc.Description
+	}
+	claim := &entity.WarrantyClaim{
+		ID:             uuid.New().String(),
+		ClaimCode:      fmt.Sprintf("WC-%s%04d", time.Now().Format("20060102"), time.Now().UnixNano()%10000),
+		ServiceOrderID: svc.ID,
+		ServiceCode:    svc.ServiceCode,
+		RMAID:          svc.RMAID,
+		RMACode:        svc.RMACode,
+		CustomerID:     svc.CustomerID,
+		SerialNo:       svc.ProductSN,
+		ProductID:      w.ProductID,
+		ProductCode:    w.ProductCode,
+		FailureCode:    code,
+		Description:    desc,
+		ShippedAt:      &w.ShippedAt,
+		FailedAt:       svc.CreatedAt,
+		ClaimCost:      req.ClaimCost,
+		Status:         entity.ClaimStatusOpen,
+		CreatedBy:      userID,
+	}
+	// 保修判定以报修时间为准，而不是索赔录入时间
+	claim.InWarranty = w.WarrantyUntil != nil && claim.FailedAt.Before(*w.WarrantyUntil)
+	if days := int(claim.FailedAt.Sub(w.ShippedAt).Hours() / 24); days > 0 {
+		claim.DaysInField = days
+	}
+
+	// 序列号最早的产出记录即原始生产工单，之后的产出来自维修工单
+	produced, err := s.genealogyRepo.FindProduced(w.ProductID, "", svc.ProductSN)
+	if err != nil {
+		return nil, err
+	}
+	if len(produced) > 0 {
+		claim.BuildWOID = produced[0].WorkOrderID
+		claim.BuildWOCode = produced[0].WOCode
+		if wo, err := s.woRepo.GetByID(produced[0].WorkOrderID); err == nil {
+			claim.BOMID = wo.BOMID
+			claim.BOMVersion = wo.BOMVersion
+		}
+	}
+
+	if claim.ClaimCost <= 0 && svc.RMAID != "" {
+		if rma, err := s.rma.GetByID(svc.RMAID); err == nil {
+			claim.ClaimCost = rma.CreditAmount
+		}
+	}
+	if req.ComponentMaterialID != "" {
+		if err := s.attribute(claim, req.ComponentMaterialID, req.ComponentLotNo); err != nil {
+			return nil, err
+		}
+	}
+
+	if svc.FailureCode == "" {
+		svc.FailureCode = code
+		if err := s.salesRepo.UpdateServiceOrder(svc); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.CreateClaim(claim); err != nil {
+		return nil, fmt.Errorf("创建保修索赔失败: %w", err)
+	}
+	return claim, nil
+}
+
+func (s *WarrantyService) GetClaim(id string) (*entity.WarrantyClaim, error) {
+	return s.repo.GetClaimByID(id)
+}
+
+func (s *WarrantyService) ListClaims(params repository.WarrantyClaimListParams) ([]entity.WarrantyClaim, int64, error) {
+	return s.repo.ListClaims(params)
+}
+
+type AttributeClaimRequest struct {
+	ComponentMaterialID string `json:"component_material_id" binding:"required"`
+	ComponentLotNo      string `json:"component_lot_no"`
+}
+
+// AttributeClaim 工程分析后把索赔归因到序列号谱系中的组件批次
+func (s *WarrantyService) AttributeClaim(id string, req AttributeClaimRequest) (*entity.WarrantyClaim, error) {
+	claim, err := s.repo.GetClaimByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("保修索赔不存在: %w", err)
+	}
+	if claim.Status != entity.ClaimStatusOpen && claim.Status != entity.ClaimStatusApproved {
+		return nil, fmt.Errorf("索赔状态 %s 不允许修改归因", claim.Status)
+	}
+	if err := s.attribute(claim, req.ComponentMaterialID, req.ComponentLotNo); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateClaim(claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// attribute 在序列号反向追溯树中定位组件批次；序列号用了同一组件的多个批次时必须指定批次
+func (s *WarrantyService) attribute(claim *entity.WarrantyClaim, materialID, lotNo string) error {
+	root, err := s.genealogy.TraceBackward(BackwardTraceRequest{SerialNo: claim.SerialNo, MaterialID: claim.ProductID})
+	if err != nil {
+		return err
+	}
+	var matches []*BackwardNode
+	collectComponents(root.Components, materialID, lotNo, &matches)
+	if len(matches) == 0 {
+		if lotNo != "" {
+			return fmt.Errorf("序列号 %s 的生产谱系中没有组件 %s 批次 %s", claim.SerialNo, materialID, lotNo)
+		}
+		return fmt.Errorf("序列号 %s 的生产谱系中没有组件 %s", claim.SerialNo, materialID)
+	}
+	lots := make(map[string]bool)
+	for _, n := range matches {
+		lots[n.LotNo] = true
+	}
+	if len(lots) > 1 {
+		names := make([]string, 0, len(lots))
+		for lot := range lots {
+			names = append(names, lot)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("序列号 %s 使用了组件的多个批次 %s，请指定批次", claim.SerialNo, strings.Join(names, ", "))
+	}
+
+	n := matches[0]
+	claim.ComponentMaterialID = n.MaterialID
+	claim.ComponentCode = n.MaterialCode
+	claim.ComponentLotNo = n.LotNo
+	claim.SupplierID, claim.SupplierName = "", ""
+	if n.Origin != nil {
+		claim.SupplierID, claim.SupplierName = n.Origin.SupplierID, n.Origin.SupplierName
+	}
+	if claim.SupplierID == "" && n.LotNo != "" {
+		// 没有入库来源时退回用消耗记录上的供应商
+		consumers, err := s.genealogyRepo.FindConsumers(n.MaterialID, n.LotNo, "")
+		if err != nil {
+			return err
+		}
+		for _, c := range consumers {
+			if c.SupplierID != "" {
+				claim.SupplierID = c.SupplierID
+				claim.SupplierName = s.genealogyRepo.GetSupplierName(c.SupplierID)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func collectComponents(nodes []*BackwardNode, materialID, lotNo string, out *[]*BackwardNode) {
+	for _, n := range nodes {
+		if n.MaterialID == materialID && (lotNo == "" || n.LotNo == lotNo) {
+			*out = append(*out, n)
+		}
+		collectComponents(n.Components, materialID, lotNo, out)
+	}
+}
+
+type ReviewWarrantyClaimRequest struct {
+	Approve bool   `json:"approve"`
+	Note    string `json:"note"`
+}
+
+// ReviewClaim 质量审核索赔：批准后计入返修统计，驳回的不计
+func (s *WarrantyService) ReviewClaim(id string, req ReviewWarrantyClaimRequest, userID string) (*entity.WarrantyClaim, error) {
+	claim, err := s.repo.GetClaimByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("保修索赔不存在: %w", err)
+	}
+	if claim.Status != entity.ClaimStatusOpen {
+		return nil, fmt.Errorf("只有待审核的索赔可以审核")
+	}
+	now := time.Now()
+	claim.Status = entity.ClaimStatusRejected
+	if req.Approve {
+		claim.Status = entity.ClaimStatusApproved
+	}
+	claim.ReviewNote = req.Note
+	claim.ReviewedBy = userID
+	claim.ReviewedAt = &now
+	if err := s.repo.UpdateClaim(claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+type ChargebackRequest struct {
+	Amount float64 `json:"amount"` // 不填时按索赔成本全额索赔
+	Note   string  `json:"note"`
+}
+
+// Chargeback 向责任组件批次的供应商索赔，生成供应商应收记录
+func (s *WarrantyService) Chargeback(id string, req ChargebackRequest, userID string) (*entity.WarrantyClaim, error) {
+	var claim *entity.WarrantyClaim
+	err := s.repo.DB().Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewWarrantyRepository(tx)
+		var err error
+		claim, err = repo.LockClaimByID(id)
+		if err != nil {
+			return fmt.Errorf("保修索赔不存在: %w", err)
+		}
+		if claim.Status != entity.ClaimStatusApproved {
+			return fmt.Errorf("只有已批准的索赔可以向供应商索赔")
+		}
+		if claim.SupplierID == "" {
+			return ErrClaimNotAttributed
+		}
+		amount := req.Amount
+		if amount <= 0 {
+			amount = claim.ClaimCost
+		}
+		if amount <= 0 {
+			return fmt.Errorf("无法确定索赔金额，请填写 amount")
+		}
+		// 按采购该批次时的币种向供应商索赔
+		currency := repository.NewGenealogyRepository(tx).GetLotCurrency(claim.ComponentMaterialID, claim.ComponentLotNo, claim.SupplierID)
+		if currency == "" {
+			return fmt.Errorf("无法确定供应商 %s 的结算币种", claim.SupplierName)
+		}
+
+		notes := fmt.Sprintf("保修索赔 %s，序列号 %s，组件 %s 批次 %s", claim.ClaimCode, claim.SerialNo, claim.ComponentCode, claim.ComponentLotNo)
+		if req.Note != "" {
+			notes += "；" + req.Note
+		}
+		record := &entity.FinanceRecord{
+			ID:               uuid.New().String(),
+			RecordCode:       fmt.Sprintf("CB-%s%04d", time.Now().Format("20060102"), time.Now().UnixNano()%10000),
+			RecordType:       entity.FinanceRecordReceivable,
+			ReferenceType:    entity.WarrantyClaimReferenceType,
+			ReferenceID:      claim.ID,
+			ReferenceCode:    claim.ClaimCode,
+			CounterpartyID:   claim.SupplierID,
+			CounterpartyName: claim.SupplierName,
+			Amount:           amount,
+			Currency:         currency,
+			Status:           "PENDING",
+			Notes:            notes,
+			CreatedBy:        userID,
+		}
+		if err := repository.NewMRPRepository(tx).CreateFinanceRecord(record); err != nil {
+			return fmt.Errorf("创建供应商索赔记录失败: %w", err)
+		}
+
+		claim.Status = entity.ClaimStatusChargedBack
+		claim.ChargebackAmount = amount
+		claim.ChargebackID = record.ID
+		claim.ChargebackCode = record.RecordCode
+		return repo.UpdateClaim(claim)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// --- Analytics ---
+
+// FieldReturnRates 现场返修率：按产品、BOM 版本、组件批次或供应商统计
+func (s *WarrantyService) FieldReturnRates(params repository.FieldReturnParams) ([]repository.FieldReturnRate, error) {
+	if params.GroupBy == "" {
+		params.GroupBy = repository.FieldReturnByProduct
+	}
+	rows, err := s.repo.GetFieldReturnRates(params)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for i := range rows {
+		id := rows[i].SupplierID
+		if id == "" {
+			continue
+		}
+		if _, ok := names[id]; !ok {
+			names[id] = s.genealogyRepo.GetSupplierName(id)
+		}
+		rows[i].SupplierName = names[id]
+	}
+	return rows, nil
+}
+
+// FailurePareto 故障代码排列，用于比较 BOM 版本或供应商的主要失效模式
+func (s *WarrantyService) FailurePareto(params repository.FailureParetoParams) ([]repository.FailurePareto, error) {
+	return s.repo.GetFailurePareto(params)
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/erp/repository"
+	"github.com/google/uuid"
+)
+
+type WorkCenterService struct {
+	repo   *repository.WorkCenterRepository
+	woRepo *repository.WorkOrderRepository
+}
+
+func NewWorkCenterService(repo *repository.WorkCenterRepository, woRepo *repository.WorkOrderRepository) *WorkCenterService {
+	return &WorkCenterService{repo: repo, woRepo: woRepo}
+}
+
+func (s *WorkCenterService) List() ([]entity.WorkCenter, error) {
+	return s.repo.List()
+}
+
+type SaveWorkCenterRequest struct {
+	Code          string  `json:"code" binding:"required"`
+	Name          string  `json:"name" binding:"required"`
+	HoursPerShift float64 `json:"hours_per_shift"`
+	Shifts        int     `json:"shifts"`
+	Units         int     `json:"units"`
+	EfficiencyPct float64 `json:"efficiency_pct"`
+	WorkDays      int     `json:"work_days"`
+	CostRate      float64 `json:"cost_rate"`
+	Status        string  `json:"status"`
+	Notes         string  `json:"notes"`
+}
+
+func (req SaveWorkCenterRequest) apply(wc *entity.WorkCenter) {
+	wc.Code, wc.Name, wc.CostRate, wc.Notes = req.Code, req.Name, req.CostRate, req.Notes
+	wc.HoursPerShift, wc.Shifts, wc.Units, wc.EfficiencyPct, wc.WorkDays = 8, 1, 1, 100, 5
+	if req.HoursPerShift > 0 {
+		wc.HoursPerShift = req.HoursPerShift
+	}
+	if req.Shifts > 0 {
+		wc.Shifts = req.Shifts
+	}
+	if req.Units > 0 {
+		wc.Units = req.Units
+	}
+	if req.EfficiencyPct > 0 {
+		wc.EfficiencyPct = req.EfficiencyPct
+	}
+	if req.WorkDays > 0 && req.WorkDays <= 7 {
+		wc.WorkDays = req.WorkDays
+	}
+	if req.Status != "" {
+		wc.Status = req.Status
+	}
+}
+
+func (s *WorkCenterService) Create(req SaveWorkCenterRequest) (*entity.WorkCenter, error) {
+	wc := &entity.WorkCenter{ID: uuid.New().String(), Status: "ACTIVE"}
+	req.apply(wc)
+	if err := s.repo.Create(wc); err != nil {
+		return nil, fmt.Errorf("创建工作中心失败: %w", err)
+	}
+	return wc, nil
+}
+
+func (s *WorkCenterService) Update(id string, req SaveWorkCenterRequest) (*entity.WorkCenter, error) {
+	wc, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("工作中心不存在: %w", err)
+	}
+	req.apply(wc)
+	if err := s.repo.Update(wc); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+// LoadBucket 工作中心某日的产能与负荷（分钟）
+type LoadBucket struct {
+	Date          string  `json:"date"`
+	Capacity      float64 `json:"capacity"`
+	PlannedLoad   float64 `json:"planned_load"`   // 按工序计划日期的负荷（无限产能）
+	ScheduledLoad float64 `json:"scheduled_load"` // 有限产能排程后的负荷
+	Overloaded    bool    `json:"overloaded"`
+}
+
+// ScheduledOperation 有限产能排程后的工序
+type ScheduledOperation struct {
+	OperationID      string     `json:"operation_id"`
+	WorkOrderID      string     `json:"work_order_id"`
+	WOCode           string     `json:"wo_code"`
+	ProductCode      string     `json:"product_code"`
+	Priority         int        `json:"priority"`
+	Sequence         int        `json:"sequence"`
+	Name             string     `json:"name"`
+	RemainingMinutes float64    `json:"remaining_minutes"`
+	PlannedStart     *time.Time `json:"planned_start"`
+	PlannedEnd       *time.Time `json:"planned_end"`
+	ProjectedStart   *time.Time `json:"projected_start"`
+	ProjectedEnd     *time.Time `json:"projected_end"` // 超出排程范围时为空
+	Late             bool       `json:"late"`          // 预计完成晚于工序计划完成或工单计划完工
+}
+
+// WorkCenterLoad 工作中心负荷
+type WorkCenterLoad struct {
+	WorkCenterID   string               `json:"work_center_id"`
+	Code           string               `json:"code"`
+	Name           string               `json:"name"`
+	DailyCapacity  float64              `json:"daily_capacity"`
+	TotalCapacity  float64              `json:"total_capacity"`
+	PlannedLoad    float64              `json:"planned_load"`
+	UtilizationPct float64              `json:"utilization_pct"` // 计划负荷/产能
+	OverloadedDays int                  `json:"overloaded_days"`
+	BacklogMinutes float64              `json:"backlog_minutes"` // 排程范围内排不下的工时
+	LateOperations int                  `json:"late_operations"`
+	Bottleneck     bool                 `json:"bottleneck"`
+	Days           []LoadBucket         `json:"days"`
+	Operations     []ScheduledOperation `json:"operations"`
+}
+
+type CapacityLoadRequest struct {
+	From         string `form:"from"` // YYYY-MM-DD，默认今天
+	Days         int    `form:"days"` // 默认28
+	WorkCenterID string `form:"work_center_id"`
+}
+
+type CapacityLoadResult struct {
+	From        string           `json:"from"`
+	Days        int              `json:"days"`
+	Centers     []WorkCenterLoad `json:"centers"`
+	Bottlenecks []string         `json:"bottlenecks"` // 超负荷或有延期工序的工作中心编码，按利用率降序
+}
+
+// unassignedCenter 工序未匹配到工作中心时的分组键
+const unassignedCenter = "-"
+
+// CapacityLoad 工作中心负荷：计划负荷按工序计划日期摊到工作日（无限产能），
+// 排程负荷按工单优先级依次占用每日剩余产能（有限产能），同一工单的工序不早于上道工序完成日开工
+func (s *WorkCenterService) CapacityLoad(req CapacityLoadRequest) (*CapacityLoadResult, error) {
+	from := dateOnly(time.Now())
+	if req.From != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.From, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("开始日期格式应为 YYYY-MM-DD")
+		}
+		from = t
+	}
+	if req.Days <= 0 {
+		req.Days = 28
+	}
+	centers, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	ops, err := s.woRepo.ListOpenOperations(req.WorkCenterID)
+	if err != nil {
+		return nil, err
+	}
+
+	loads := make(map[string]*WorkCenterLoad)
+	calendars := make(map[string]entity.WorkCenter)
+	var order []string
+	addCenter := func(key string, wc entity.WorkCenter) *WorkCenterLoad {
+		l := &WorkCenterLoad{WorkCenterID: wc.ID, Code: wc.Code, Name: wc.Name, DailyCapacity: wc.DailyMinutes(),
+			Days: make([]LoadBucket, req.Days), Operations: make([]ScheduledOperation, 0)}
+		for d := 0; d < req.Days; d++ {
+			day := from.AddDate(0, 0, d)
+			l.Days[d].Date = day.Format("2006-01-02")
+			if wc.IsWorkDay(day) {
+				l.Days[d].Capacity = l.DailyCapacity
+				l.TotalCapacity += l.DailyCapacity
+			}
+		}
+		loads[key], calendars[key] = l, wc
+		order = append(order, key)
+		return l
+	}
+	for _, wc := range centers {
+		if req.WorkCenterID == "" || wc.ID == req.WorkCenterID {
+			addCenter(wc.ID, wc)
+		}
+	}
+
+	dayIndex := func(t *time.Time, fallback int) int {
+		if t == nil {
+			return fallback
+		}
+		d := int(math.Floor(dateOnly(*t).Sub(from).Hours() / 24))
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+	remaining := make(map[string][]float64) // 每日剩余可排产能
+	woReady := make(map[string]int)         // 工单下道工序最早可开工日
+	for _, op := range ops {
+		minutes := op.RemainingMinutes()
+		start := dayIndex(op.PlannedStart, 0)
+		if minutes <= 0 || start >= req.Days {
+			continue
+		}
+		key := op.WorkCenterID
+		if key == "" {
+			key = unassignedCenter + op.WorkCenterCode
+		}
+		l, ok := loads[key]
+		if !ok {
+			// 未配置的工作中心没有产能，负荷全部视为超负荷
+			code := op.WorkCenterCode
+			if code == "" {
+				code = unassignedCenter
+			}
+			l = addCenter(key, entity.WorkCenter{Code: code, Name: "未配置工作中心", WorkDays: 7})
+		}
+		cal := calendars[key]
+		l.PlannedLoad += minutes
+
+		// 计划负荷：摊到计划起止之间的工作日，逾期工序计入首日，计划在排程范围之后的工序不计
+		end := dayIndex(op.PlannedEnd, start)
+		if end < start {
+			end = start
+		}
+		var spread []int
+		for d := start; d <= end && d < req.Days; d++ {
+			if cal.IsWorkDay(from.AddDate(0, 0, d)) {
+				spread = append(spread, d)
+			}
+		}
+		if len(spread) == 0 {
+			spread = []int{start}
+		}
+		for _, d := range spread {
+			l.Days[d].PlannedLoad += minutes / float64(len(spread))
+		}
+
+		// 有限产能排程
+		capLeft, ok := remaining[key]
+		if !ok {
+			capLeft = make([]float64, req.Days)
+			for d := range capLeft {
+				capLeft[d] = l.Days[d].Capacity
+			}
+			remaining[key] = capLeft
+		}
+		first := start
+		if ready, ok := woReady[op.WorkOrderID]; ok && ready > first {
+			first = ready
+		}
+		so := ScheduledOperation{
+			OperationID: op.ID, WorkOrderID: op.WorkOrderID, WOCode: op.WOCode, ProductCode: op.ProductCode,
+			Priority: op.Priority, Sequence: op.Sequence, Name: op.Name, RemainingMinutes: minutes,
+			PlannedStart: op.PlannedStart, PlannedEnd: op.PlannedEnd,
+		}
+		left := minutes
+		lastDay := -1
+		for d := first; d < req.Days && left > 1e-6; d++ {
+			if capLeft[d] <= 1e-6 {
+				continue
+			}
+			take := math.Min(capLeft[d], left)
+			capLeft[d] -= take
+			left -= take
+			l.Days[d].ScheduledLoad += take
+			if so.ProjectedStart == nil {
+				t := from.AddDate(0, 0, d)
+				so.ProjectedStart = &t
+			}
+			lastDay = d
+		}
+		if left > 1e-6 {
+			l.BacklogMinutes += left
+			so.Late = true
+			woReady[op.WorkOrderID] = req.Days
+		} else {
+			t := from.AddDate(0, 0, lastDay)
+			so.ProjectedEnd = &t
+			woReady[op.WorkOrderID] = lastDay + 1
+			if (op.PlannedEnd != nil && t.After(dateOnly(*op.PlannedEnd))) ||
+				(op.WOPlannedEnd != nil && t.After(dateOnly(*op.WOPlannedEnd))) {
+				so.Late = true
+			}
+		}
+		if so.Late {
+			l.LateOperations++
+		}
+		l.Operations = append(l.Operations, so)
+	}
+
+	result := &CapacityLoadResult{From: from.Format("2006-01-02"), Days: req.Days,
+		Centers: make([]WorkCenterLoad, 0, len(order)), Bottlenecks: make([]string, 0)}
+	for _, key := range order {
+		l := loads[key]
+		for d := range l.Days {
+			b := &l.Days[d]
+			b.PlannedLoad = math.Round(b.PlannedLoad*100) / 100
+			b.ScheduledLoad = math.Round(b.ScheduledLoad*100) / 100
+			if b.PlannedLoad > b.Capacity+1e-6 {
+				b.Overloaded = true
+				l.OverloadedDays++
+			}
+		}
+		if l.TotalCapacity > 0 {
+			l.UtilizationPct = math.Round(l.PlannedLoad*10000/l.TotalCapacity) / 100
+		}
+		l.Bottleneck = l.BacklogMinutes > 0 || l.LateOperations > 0 || (l.TotalCapacity > 0 && l.PlannedLoad > l.TotalCapacity)
+		result.Centers = append(result.Centers, *l)
+	}
+	sort.SliceStable(result.Centers, func(i, j int) bool {
+		a, b := result.Centers[i], result.Centers[j]
+		if a.Bottleneck != b.Bottleneck {
+			return a.Bottleneck
+		}
+		return a.UtilizationPct > b.UtilizationPct
+	})
+	for _, l := range result.Centers {
+		if l.Bottleneck {
+			result.Bottlenecks = append(result.Bottlenecks, l.Code)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,51 @@
+package entity
+
+import "time"
+
+// DefectCode 缺陷代码（三级：类别/模式/原因）
+type DefectCode struct {
+	ID          string    `json:"id" gorm:"primaryKey;size:32"`
+	Code        string    `json:"code" gorm:"size:32;uniqueIndex;not null"`
+	Name        string    `json:"name" gorm:"size:200;not null"`
+	Level       string    `json:"level" gorm:"size:20;not null;index"` // category/mode/cause
+	ParentID    *string   `json:"parent_id" gorm:"size:32;index"`
+	Description string    `json:"description" gorm:"type:text"`
+	Severity    string    `json:"severity" gorm:"size:20"`              // critical/major/minor
+	Status      string    `json:"status" gorm:"size:20;default:active"` // active/inactive
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
+	CreatedBy   string    `json:"created_by" gorm:"size:32"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 关联
+	Children []DefectCode `json:"children,omitempty" gorm:"-"`
+}
+
+func (DefectCode) TableName() string {
+	return "srm_defect_codes"
+}
+
+// 缺陷代码层级
+const (
+	DefectLevelCategory = "category"
+	DefectLevelMode     = "mode"
+	DefectLevelCause    = "cause"
+)
+
+// 缺陷代码状态
+const (
+	DefectCodeStatusActive   = "active"
+	DefectCodeStatusInactive = "inactive"
+)
+
+// DefectParentLevel 返回该层级要求的父级层级，类别为顶级返回空
+func DefectParentLevel(level string) string {
+	switch level {
+	case DefectLevelMode:
+		return DefectLevelCategory
+	case DefectLevelCause:
+		return DefectLevelMode
+	default:
+		return ""
+	}
+}
@@ -31,6 +31,10 @@ type SupplierEvaluation struct {
 	QualityPassed int `json:"quality_passed" gorm:"default:0"`
 	QualityTotal  int `json:"quality_total" gorm:"default:0"`
 
+	// 评分卡
+	ScorecardID *string `json:"scorecard_id" gorm:"size:32"`
+	PrevGrade   string  `json:"prev_grade" gorm:"size:10"` // 上期等级，用于等级变动通知
+
 	// 其他
 	Remarks     string `json:"remarks" gorm:"type:text"`
 	EvaluatorID string `json:"evaluator_id" gorm:"size:32"`
@@ -40,7 +44,8 @@ type SupplierEvaluation struct {
 	UpdatedAt time.Time `json:"updated_at"`
 
 	// 关联
-	Supplier *Supplier `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
+	Supplier   *Supplier             `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
+	KPIResults []EvaluationKPIResult `json:"kpi_results,omitempty" gorm:"foreignKey:EvaluationID"`
 }
 
 func (SupplierEvaluation) TableName() string {
@@ -54,6 +59,21 @@ const (
 	EvalStatusApproved  = "approved"
 )
 
+// PPMZeroScore 质量得分降为0分时的PPM
+const PPMZeroScore = 10000
+
+// CalcQualityScoreFromPPM 按PPM计算质量得分：0 PPM为100分，线性递减至 PPMZeroScore 为0分
+func CalcQualityScoreFromPPM(ppm float64) float64 {
+	score := 100 - ppm/PPMZeroScore*100
+	if score < 0 {
+		return 0
+	}
+	if score > 100 {
+		return 100
+	}
+	return score
+}
+
 // 评估等级
 func CalcGrade(score float64) string {
 	switch {
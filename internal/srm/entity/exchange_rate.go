@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// FunctionalCurrency 本位币
+const FunctionalCurrency = "CNY"
+
+// 支持的交易币种
+const (
+	CurrencyCNY = "CNY"
+	CurrencyUSD = "USD"
+	CurrencyJPY = "JPY"
+)
+
+// ExchangeRate 汇率（1 FromCurrency = Rate ToCurrency，自生效日起有效，直到下一条生效）
+type ExchangeRate struct {
+	ID            string    `json:"id" gorm:"primaryKey;size:32"`
+	FromCurrency  string    `json:"from_currency" gorm:"size:10;not null;uniqueIndex:idx_srm_fx_pair_date"`
+	ToCurrency    string    `json:"to_currency" gorm:"size:10;not null;uniqueIndex:idx_srm_fx_pair_date"`
+	Rate          float64   `json:"rate" gorm:"type:decimal(18,8);not null"`
+	EffectiveDate time.Time `json:"effective_date" gorm:"type:date;not null;uniqueIndex:idx_srm_fx_pair_date"`
+	Source        string    `json:"source" gorm:"size:20;default:manual"` // manual/import
+	CreatedBy     string    `json:"created_by" gorm:"size:32"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (ExchangeRate) TableName() string {
+	return "srm_exchange_rates"
+}
+
+// 汇率来源
+const (
+	ExchangeRateSourceManual = "manual"
+	ExchangeRateSourceImport = "import"
+)
@@ -50,19 +50,23 @@ func (Inspection) TableName() string {
 
 // InspectionItem 质检行项
 type InspectionItem struct {
-	ID               string  `json:"id" gorm:"primaryKey;size:32"`
-	InspectionID     string  `json:"inspection_id" gorm:"size:32;not null;index"`
-	POItemID         *string `json:"po_item_id" gorm:"size:32"`
-	MaterialName     string  `json:"material_name" gorm:"size:200"`
-	MaterialCode     string  `json:"material_code" gorm:"size:50"`
-	InspectedQty     float64 `json:"inspected_quantity" gorm:"type:decimal(10,2)"`
-	QualifiedQty     float64 `json:"qualified_quantity" gorm:"type:decimal(10,2)"`
-	DefectQty        float64 `json:"defect_quantity" gorm:"type:decimal(10,2)"`
-	DefectDesc       string  `json:"defect_description" gorm:"type:text"`
-	Result           string  `json:"result" gorm:"size:20"` // passed/failed/conditional
-	SortOrder        int     `json:"sort_order" gorm:"default:0"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID           string    `json:"id" gorm:"primaryKey;size:32"`
+	InspectionID string    `json:"inspection_id" gorm:"size:32;not null;index"`
+	POItemID     *string   `json:"po_item_id" gorm:"size:32"`
+	MaterialName string    `json:"material_name" gorm:"size:200"`
+	MaterialCode string    `json:"material_code" gorm:"size:50"`
+	InspectedQty float64   `json:"inspected_quantity" gorm:"type:decimal(10,2)"`
+	QualifiedQty float64   `json:"qualified_quantity" gorm:"type:decimal(10,2)"`
+	DefectQty    float64   `json:"defect_quantity" gorm:"type:decimal(10,2)"`
+	DefectDesc   string    `json:"defect_description" gorm:"type:text"`
+	DefectCodeID *string   `json:"defect_code_id" gorm:"size:32;index"`
+	Result       string    `json:"result" gorm:"size:20"` // passed/failed/conditional
+	SortOrder    int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// 关联
+	DefectCode *DefectCode `json:"defect_code,omitempty" gorm:"foreignKey:DefectCodeID"`
 }
 
 func (InspectionItem) TableName() string {
@@ -86,14 +90,15 @@ const (
 
 // CorrectiveAction 8D改进单
 type CorrectiveAction struct {
-	ID           string  `json:"id" gorm:"primaryKey;size:32"`
-	CACode       string  `json:"ca_code" gorm:"size:32;uniqueIndex;not null"`
-	InspectionID string  `json:"inspection_id" gorm:"size:32;not null"`
-	SupplierID   string  `json:"supplier_id" gorm:"size:32;not null"`
+	ID           string `json:"id" gorm:"primaryKey;size:32"`
+	CACode       string `json:"ca_code" gorm:"size:32;uniqueIndex;not null"`
+	InspectionID string `json:"inspection_id" gorm:"size:32;not null"`
+	SupplierID   string `json:"supplier_id" gorm:"size:32;not null"`
 
 	// 问题描述
-	ProblemDesc string `json:"problem_desc" gorm:"type:text;not null"`
-	Severity    string `json:"severity" gorm:"size:20"` // critical/major/minor
+	ProblemDesc  string  `json:"problem_desc" gorm:"type:text;not null"`
+	Severity     string  `json:"severity" gorm:"size:20"` // critical/major/minor
+	DefectCodeID *string `json:"defect_code_id" gorm:"size:32;index"`
 
 	// 8D流程
 	Status           string `json:"status" gorm:"size:20;default:open"` // open/responded/verified/closed
@@ -110,8 +115,20 @@ type CorrectiveAction struct {
 	CreatedBy string    `json:"created_by" gorm:"size:32"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联
+	DefectCode *DefectCode `json:"defect_code,omitempty" gorm:"foreignKey:DefectCodeID"`
+	Supplier   *Supplier   `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
 }
 
 func (CorrectiveAction) TableName() string {
 	return "srm_corrective_actions"
 }
+
+// 8D状态
+const (
+	CAStatusOpen      = "open"
+	CAStatusResponded = "responded"
+	CAStatusVerified  = "verified"
+	CAStatusClosed    = "closed"
+)
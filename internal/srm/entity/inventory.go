@@ -14,6 +14,7 @@ type InventoryRecord struct {
 	Warehouse    string   `json:"warehouse" gorm:"size:100"`
 	LastInDate   *time.Time `json:"last_in_date"`
 	SafetyStock  float64  `json:"safety_stock" gorm:"type:decimal(10,2);default:0"`
+	Version      int      `json:"version" gorm:"not null;default:0"` // 乐观锁版本，每次变动+1
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 
@@ -31,8 +32,10 @@ type InventoryTransaction struct {
 	InventoryID   string    `json:"inventory_id" gorm:"size:32;not null;index"`
 	Type          string    `json:"type" gorm:"size:10;not null"` // in/out/adjust
 	Quantity      float64   `json:"quantity" gorm:"type:decimal(10,2);not null"`
+	BalanceAfter  float64   `json:"balance_after" gorm:"type:decimal(10,2);default:0"` // 过账后结存
 	ReferenceType string    `json:"reference_type" gorm:"size:20"` // inspection/manual/adjust
 	ReferenceID   string    `json:"reference_id" gorm:"size:32"`
+	POItemID      *string   `json:"po_item_id" gorm:"size:32;index"` // 收货对应的PO行项（三单匹配）
 	Operator      string    `json:"operator" gorm:"size:100"`
 	Notes         string    `json:"notes" gorm:"type:text"`
 	CreatedAt     time.Time `json:"created_at"`
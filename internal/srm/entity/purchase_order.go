@@ -19,6 +19,10 @@ type PurchaseOrder struct {
 	TotalAmount *float64 `json:"total_amount" gorm:"type:decimal(15,2)"`
 	Currency    string   `json:"currency" gorm:"size:10;default:CNY"`
 
+	// 汇率锁定（审批时按本位币锁定）
+	ExchangeRate *float64   `json:"exchange_rate" gorm:"type:decimal(18,8)"`
+	RateLockedAt *time.Time `json:"rate_locked_at"`
+
 	// 交期
 	ExpectedDate *time.Time `json:"expected_date"`
 	ActualDate   *time.Time `json:"actual_date"`
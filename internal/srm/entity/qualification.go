@@ -0,0 +1,133 @@
+package entity
+
+import "time"
+
+// SupplierQualification 供应商准入审核单（问卷 → 现场审核 → 样品承认 → 会签）
+type SupplierQualification struct {
+	ID          string `json:"id" gorm:"primaryKey;size:32"`
+	Code        string `json:"code" gorm:"size:32;uniqueIndex;not null"`
+	SupplierID  string `json:"supplier_id" gorm:"size:32;not null;index"`
+	Status      string `json:"status" gorm:"size:20;default:in_progress"` // in_progress/qualified/rejected/cancelled
+	CurrentStep string `json:"current_step" gorm:"size:20"`               // questionnaire/audit/sample/signoff
+
+	// 问卷
+	QuestionnaireAnswers *JSONB     `json:"questionnaire_answers" gorm:"type:jsonb"`
+	QuestionnaireScore   *float64   `json:"questionnaire_score" gorm:"type:decimal(5,2)"`
+	QuestionnaireAt      *time.Time `json:"questionnaire_at"`
+	QuestionnaireBy      string     `json:"questionnaire_by" gorm:"size:32"`
+
+	// 现场审核
+	AuditDate      *time.Time `json:"audit_date"`
+	Auditor        string     `json:"auditor" gorm:"size:100"`
+	AuditScore     *float64   `json:"audit_score" gorm:"type:decimal(5,2)"`
+	AuditResult    string     `json:"audit_result" gorm:"size:20"` // passed/conditional/failed
+	AuditFindings  string     `json:"audit_findings" gorm:"type:text"`
+	AuditReportURL string     `json:"audit_report_url" gorm:"size:500"`
+
+	// 样品承认（关联打样记录）
+	SamplingRequestID string     `json:"sampling_request_id" gorm:"size:32;index"`
+	SampleResult      string     `json:"sample_result" gorm:"size:20"` // passed/failed
+	SampleApprovedAt  *time.Time `json:"sample_approved_at"`
+
+	// 会签
+	TargetLevel    string     `json:"target_level" gorm:"size:20"`
+	SignedBy       string     `json:"signed_by" gorm:"size:32"`
+	SignedAt       *time.Time `json:"signed_at"`
+	SignoffComment string     `json:"signoff_comment" gorm:"size:500"`
+	RejectReason   string     `json:"reject_reason" gorm:"size:500"`
+
+	CreatedBy string    `json:"created_by" gorm:"size:32"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Supplier *Supplier `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
+}
+
+func (SupplierQualification) TableName() string {
+	return "srm_supplier_qualifications"
+}
+
+// 准入审核状态
+const (
+	QualificationStatusInProgress = "in_progress"
+	QualificationStatusQualified  = "qualified"
+	QualificationStatusRejected   = "rejected"
+	QualificationStatusCancelled  = "cancelled"
+)
+
+// 准入审核步骤
+const (
+	QualificationStepQuestionnaire = "questionnaire"
+	QualificationStepAudit         = "audit"
+	QualificationStepSample        = "sample"
+	QualificationStepSignoff       = "signoff"
+)
+
+// 现场审核结论
+const (
+	AuditResultPassed      = "passed"
+	AuditResultConditional = "conditional"
+	AuditResultFailed      = "failed"
+)
+
+// SupplierCertificate 供应商资质证书
+type SupplierCertificate struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:32"`
+	SupplierID string     `json:"supplier_id" gorm:"size:32;not null;index"`
+	CertType   string     `json:"cert_type" gorm:"size:30;not null"` // iso9001/iatf16949/iso14001/rohs/reach/other
+	CertNo     string     `json:"cert_no" gorm:"size:100"`
+	Issuer     string     `json:"issuer" gorm:"size:200"`
+	IssuedAt   *time.Time `json:"issued_at"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null;index"`
+	FileURL    string     `json:"file_url" gorm:"size:500"`
+	Status     string     `json:"status" gorm:"size:20;default:valid"` // valid/expiring/expired/superseded
+	RemindedAt *time.Time `json:"reminded_at"`
+	Notes      string     `json:"notes" gorm:"size:500"`
+	CreatedBy  string     `json:"created_by" gorm:"size:32"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (SupplierCertificate) TableName() string {
+	return "srm_supplier_certificates"
+}
+
+// 证书类型
+const (
+	CertTypeISO9001   = "iso9001"
+	CertTypeIATF16949 = "iatf16949"
+	CertTypeISO14001  = "iso14001"
+	CertTypeRoHS      = "rohs"
+	CertTypeREACH     = "reach"
+	CertTypeOther     = "other"
+)
+
+// 证书状态
+const (
+	CertStatusValid      = "valid"
+	CertStatusExpiring   = "expiring"   // 临期（已提醒）
+	CertStatusExpired    = "expired"    // 已过期
+	CertStatusSuperseded = "superseded" // 已被同类型新证书替代
+)
+
+// CertReminderDays 证书到期前提醒天数
+const CertReminderDays = 30
+
+// ValidSupplierStatusTransitions 供应商生命周期状态流转（pending→active 仅由准入会签完成）
+var ValidSupplierStatusTransitions = map[string][]string{
+	SupplierStatusPending:   {SupplierStatusBlacklisted},
+	SupplierStatusActive:    {SupplierStatusSuspended, SupplierStatusBlacklisted},
+	SupplierStatusSuspended: {SupplierStatusActive, SupplierStatusBlacklisted},
+}
+
+// IsQualified 是否为已准入供应商（可分配采购）
+func (s *Supplier) IsQualified() bool {
+	if s.Status != SupplierStatusActive {
+		return false
+	}
+	switch s.Level {
+	case SupplierLevelQualified, SupplierLevelPreferred, SupplierLevelStrategic:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package entity
+
+import "time"
+
+// Scorecard 供应商评分卡模型（按供应商分类配置，Category为空表示默认评分卡）
+type Scorecard struct {
+	ID          string    `json:"id" gorm:"primaryKey;size:32"`
+	Name        string    `json:"name" gorm:"size:100;not null"`
+	Category    string    `json:"category" gorm:"size:50;index"`              // 供应商分类，空为默认
+	Frequency   string    `json:"frequency" gorm:"size:20;default:quarterly"` // monthly/quarterly，决定自动评估周期
+	Description string    `json:"description" gorm:"type:text"`
+	Status      string    `json:"status" gorm:"size:20;default:active"` // active/inactive
+	CreatedBy   string    `json:"created_by" gorm:"size:32"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 关联
+	KPIs []ScorecardKPI `json:"kpis,omitempty" gorm:"foreignKey:ScorecardID"`
+}
+
+func (Scorecard) TableName() string {
+	return "srm_scorecards"
+}
+
+// 评估周期
+const (
+	EvalTypeMonthly   = "monthly"
+	EvalTypeQuarterly = "quarterly"
+)
+
+// 评分卡状态
+const (
+	ScorecardStatusActive   = "active"
+	ScorecardStatusInactive = "inactive"
+)
+
+// ScorecardKPI 评分卡指标
+// 得分按原始值在 TargetValue(100分) 与 ZeroValue(0分) 之间线性插值，两端截断；
+// Target 大于 Zero 表示越大越好（如准时率），反之越小越好（如PPM）
+type ScorecardKPI struct {
+	ID          string    `json:"id" gorm:"primaryKey;size:32"`
+	ScorecardID string    `json:"scorecard_id" gorm:"size:32;not null;index"`
+	Code        string    `json:"code" gorm:"size:50;not null"`
+	Name        string    `json:"name" gorm:"size:100;not null"`
+	Dimension   string    `json:"dimension" gorm:"size:20;not null"`   // quality/delivery/price/service
+	DataSource  string    `json:"data_source" gorm:"size:30;not null"` // otd/ppm/8d_response/price_variance/manual
+	Weight      float64   `json:"weight" gorm:"type:decimal(5,2);not null"`
+	TargetValue float64   `json:"target_value" gorm:"type:decimal(12,4)"`
+	ZeroValue   float64   `json:"zero_value" gorm:"type:decimal(12,4)"`
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (ScorecardKPI) TableName() string {
+	return "srm_scorecard_kpis"
+}
+
+// 评分维度（与评估单的四项得分对应）
+const (
+	KPIDimensionQuality  = "quality"
+	KPIDimensionDelivery = "delivery"
+	KPIDimensionPrice    = "price"
+	KPIDimensionService  = "service"
+)
+
+// KPI数据源
+const (
+	KPISourceOTD           = "otd"            // 准时交付率 %：PO实际到货日 ≤ 要求交期
+	KPISourcePPM           = "ppm"            // 来料检验PPM
+	KPISource8DResponse    = "8d_response"    // 8D平均响应时长（小时）
+	KPISourcePriceVariance = "price_variance" // PO单价相对询价中标价的平均偏差 %
+	KPISourceManual        = "manual"         // 人工评分
+)
+
+// 各数据源的默认满分/零分值
+var KPISourceDefaults = map[string][2]float64{
+	KPISourceOTD:           {100, 50},
+	KPISourcePPM:           {0, PPMZeroScore},
+	KPISource8DResponse:    {24, 240},
+	KPISourcePriceVariance: {0, 10},
+	KPISourceManual:        {100, 0},
+}
+
+// CalcKPIScore 按线性插值计算KPI得分（0-100）
+func CalcKPIScore(value, target, zero float64) float64 {
+	if target == zero {
+		if value == target {
+			return 100
+		}
+		return 0
+	}
+	score := (value - zero) / (target - zero) * 100
+	if score < 0 {
+		return 0
+	}
+	if score > 100 {
+		return 100
+	}
+	return score
+}
+
+// EvaluationKPIResult 评估单的KPI明细
+type EvaluationKPIResult struct {
+	ID           string    `json:"id" gorm:"primaryKey;size:32"`
+	EvaluationID string    `json:"evaluation_id" gorm:"size:32;not null;index"`
+	KPIID        string    `json:"kpi_id" gorm:"size:32"`
+	Code         string    `json:"code" gorm:"size:50"`
+	Name         string    `json:"name" gorm:"size:100"`
+	Dimension    string    `json:"dimension" gorm:"size:20"`
+	DataSource   string    `json:"data_source" gorm:"size:30"`
+	Weight       float64   `json:"weight" gorm:"type:decimal(5,2)"`
+	RawValue     *float64  `json:"raw_value" gorm:"type:decimal(14,4)"`
+	Score        *float64  `json:"score" gorm:"type:decimal(5,2)"`
+	SampleSize   int       `json:"sample_size" gorm:"default:0"`
+	Detail       string    `json:"detail" gorm:"size:500"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (EvaluationKPIResult) TableName() string {
+	return "srm_evaluation_kpi_results"
+}
+
+// ScorecardRun 周期评估运行记录（同一周期只运行一次）
+type ScorecardRun struct {
+	ID            string     `json:"id" gorm:"primaryKey;size:32"`
+	Period        string     `json:"period" gorm:"size:20;not null;uniqueIndex:idx_srm_scorecard_run_period"`
+	EvalType      string     `json:"eval_type" gorm:"size:20;not null;uniqueIndex:idx_srm_scorecard_run_period"`
+	Status        string     `json:"status" gorm:"size:20;default:running"` // running/completed/failed
+	Trigger       string     `json:"trigger" gorm:"size:20"`                // schedule/manual
+	SupplierCount int        `json:"supplier_count" gorm:"default:0"`
+	CreatedCount  int        `json:"created_count" gorm:"default:0"`
+	GradeChanges  int        `json:"grade_changes" gorm:"default:0"`
+	ErrorMessage  string     `json:"error_message" gorm:"type:text"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+}
+
+func (ScorecardRun) TableName() string {
+	return "srm_scorecard_runs"
+}
+
+// 运行状态
+const (
+	ScorecardRunRunning   = "running"
+	ScorecardRunCompleted = "completed"
+	ScorecardRunFailed    = "failed"
+)
+
+// LevelForGrade 按评估等级调整供应商等级：A→优选，B/C→合格，D→考察期；
+// 战略供应商仅在D级时降为合格，其余保持不变。考察期供应商仍是已准入供应商，
+// 不影响已下达的采购订单，只是不能分配新的采购需求，下期评估B级以上恢复合格
+func LevelForGrade(grade, current string) string {
+	if current == SupplierLevelStrategic {
+		if grade == "D" {
+			return SupplierLevelQualified
+		}
+		return current
+	}
+	switch grade {
+	case "A":
+		return SupplierLevelPreferred
+	case "B", "C":
+		return SupplierLevelQualified
+	case "D":
+		return SupplierLevelProbation
+	}
+	return current
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "testing"
+
+// TestLevelForGrade 评估等级对供应商等级的调整，D级进入考察期而不是退回潜在
+func TestLevelForGrade(t *testing.T) {
+	cases := []struct {
+		name    string
+		grade   string
+		current string
+		want    string
+	}{
+		{"A级升为优选", "A", SupplierLevelQualified, SupplierLevelPreferred},
+		{"B级为合格", "B", SupplierLevelPreferred, SupplierLevelQualified},
+		{"C级为合格", "C", SupplierLevelQualified, SupplierLevelQualified},
+		{"D级进入考察期", "D", SupplierLevelQualified, SupplierLevelProbation},
+		{"考察期B级恢复合格", "B", SupplierLevelProbation, SupplierLevelQualified},
+		{"战略供应商D级降为合格", "D", SupplierLevelStrategic, SupplierLevelQualified},
+		{"战略供应商A级保持", "A", SupplierLevelStrategic, SupplierLevelStrategic},
+		{"无评级保持不变", "", SupplierLevelPreferred, SupplierLevelPreferred},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := LevelForGrade(tc.grade, tc.current); got != tc.want {
+				t.Fatalf("LevelForGrade(%q, %q) = %s, want %s", tc.grade, tc.current, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestProbationSupplierNotQualified 考察期供应商不能分配新的采购需求
+func TestProbationSupplierNotQualified(t *testing.T) {
+	s := Supplier{Status: SupplierStatusActive, Level: SupplierLevelProbation}
+	if s.IsQualified() {
+		t.Fatal("expected a probation supplier to be excluded from new sourcing")
+	}
+}
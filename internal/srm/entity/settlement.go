@@ -5,8 +5,8 @@ import "time"
 // Settlement 对账单
 type Settlement struct {
 	ID             string     `json:"id" gorm:"primaryKey;size:32"`
-	SettlementCode string    `json:"settlement_code" gorm:"size:32;uniqueIndex;not null"`
-	SupplierID     string    `json:"supplier_id" gorm:"size:32;not null;index"`
+	SettlementCode string     `json:"settlement_code" gorm:"size:32;uniqueIndex;not null"`
+	SupplierID     string     `json:"supplier_id" gorm:"size:32;not null;index"`
 	PeriodStart    *time.Time `json:"period_start"`
 	PeriodEnd      *time.Time `json:"period_end"`
 	Status         string     `json:"status" gorm:"size:20;default:draft"` // draft/confirmed/invoiced/paid
@@ -18,6 +18,13 @@ type Settlement struct {
 	FinalAmount    *float64 `json:"final_amount" gorm:"type:decimal(15,2)"`
 	Currency       string   `json:"currency" gorm:"size:10;default:CNY"`
 
+	// 本位币（PO锁定汇率入账 vs 结算汇率付款，差额为已实现汇兑损益）
+	FunctionalCurrency     string   `json:"functional_currency" gorm:"size:10;default:CNY"`
+	ExchangeRate           *float64 `json:"exchange_rate" gorm:"type:decimal(18,8)"`
+	FunctionalAmount       *float64 `json:"functional_amount" gorm:"type:decimal(15,2)"`
+	BookedFunctionalAmount *float64 `json:"booked_functional_amount" gorm:"type:decimal(15,2)"`
+	FXDiff                 *float64 `json:"fx_diff" gorm:"column:fx_diff;type:decimal(15,2)"` // >0 汇兑损失，<0 汇兑收益
+
 	// 发票
 	InvoiceNo     string   `json:"invoice_no" gorm:"size:100"`
 	InvoiceAmount *float64 `json:"invoice_amount" gorm:"type:decimal(15,2)"`
@@ -34,8 +41,10 @@ type Settlement struct {
 	Notes     string    `json:"notes" gorm:"type:text"`
 
 	// 关联
-	Disputes []SettlementDispute `json:"disputes,omitempty" gorm:"foreignKey:SettlementID"`
-	Supplier *Supplier           `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
+	Lines        []SettlementLine        `json:"lines,omitempty" gorm:"foreignKey:SettlementID"`
+	InvoiceLines []SettlementInvoiceLine `json:"invoice_lines,omitempty" gorm:"foreignKey:SettlementID"`
+	Disputes     []SettlementDispute     `json:"disputes,omitempty" gorm:"foreignKey:SettlementID"`
+	Supplier     *Supplier               `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
 }
 
 func (Settlement) TableName() string {
@@ -44,16 +53,131 @@ func (Settlement) TableName() string {
 
 // SettlementDispute 对账差异记录
 type SettlementDispute struct {
+	ID               string    `json:"id" gorm:"primaryKey;size:32"`
+	SettlementID     string    `json:"settlement_id" gorm:"size:32;not null;index"`
+	SettlementLineID *string   `json:"settlement_line_id" gorm:"size:32;index"`
+	DisputeType      string    `json:"dispute_type" gorm:"size:50"`          // price_diff/quantity_diff/quality_deduction/other
+	Source           string    `json:"source" gorm:"size:20;default:manual"` // manual/match
+	Description      string    `json:"description" gorm:"type:text"`
+	AmountDiff       *float64  `json:"amount_diff" gorm:"type:decimal(12,2)"`
+	Status           string    `json:"status" gorm:"size:20;default:open"` // open/resolved
+	Resolution       string    `json:"resolution" gorm:"type:text"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// 差异状态
+const (
+	DisputeStatusOpen     = "open"
+	DisputeStatusResolved = "resolved"
+)
+
+func (SettlementDispute) TableName() string {
+	return "srm_settlement_disputes"
+}
+
+// 对账单状态
+const (
+	SettlementStatusDraft     = "draft"
+	SettlementStatusConfirmed = "confirmed"
+	SettlementStatusInvoiced  = "invoiced"
+	SettlementStatusPaid      = "paid"
+)
+
+// 差异类型
+const (
+	DisputeTypePriceDiff        = "price_diff"
+	DisputeTypeQuantityDiff     = "quantity_diff"
+	DisputeTypeQualityDeduction = "quality_deduction"
+	DisputeTypeOther            = "other"
+)
+
+// 差异来源
+const (
+	DisputeSourceManual = "manual"
+	DisputeSourceMatch  = "match" // 三单匹配自动生成
+)
+
+// SettlementLine 对账行（PO行项 × 收货 × 发票 三单匹配）
+type SettlementLine struct {
+	ID           string `json:"id" gorm:"primaryKey;size:32"`
+	SettlementID string `json:"settlement_id" gorm:"size:32;not null;index"`
+	POID         string `json:"po_id" gorm:"size:32;not null"`
+	POCode       string `json:"po_code" gorm:"size:32"`
+	POItemID     string `json:"po_item_id" gorm:"size:32;not null;index"`
+	MaterialCode string `json:"material_code" gorm:"size:50"`
+	MaterialName string `json:"material_name" gorm:"size:200"`
+	Unit         string `json:"unit" gorm:"size:20"`
+
+	// PO
+	OrderedQty float64 `json:"ordered_qty" gorm:"type:decimal(10,2)"`
+	UnitPrice  float64 `json:"unit_price" gorm:"type:decimal(12,4)"`
+	LockedRate float64 `json:"locked_rate" gorm:"type:decimal(18,8);default:1"` // PO审批时锁定的汇率
+
+	// 收货（来自库存入库流水）
+	ReceivedQty    float64 `json:"received_qty" gorm:"type:decimal(10,2)"`
+	ReceivedAmount float64 `json:"received_amount" gorm:"type:decimal(15,2)"`
+	BookedAmount   float64 `json:"booked_amount" gorm:"type:decimal(15,2)"` // 收货金额 × 锁定汇率（本位币）
+
+	// 发票（来自发票行汇总）
+	InvoicedQty       float64 `json:"invoiced_qty" gorm:"type:decimal(10,2)"`
+	InvoicedUnitPrice float64 `json:"invoiced_unit_price" gorm:"type:decimal(12,4)"`
+	InvoicedAmount    float64 `json:"invoiced_amount" gorm:"type:decimal(15,2)"`
+
+	// 匹配结果
+	MatchStatus string     `json:"match_status" gorm:"size:20;default:pending"` // pending/matched/mismatch/waived
+	QtyDiff     float64    `json:"qty_diff" gorm:"type:decimal(10,2)"`
+	PriceDiff   float64    `json:"price_diff" gorm:"type:decimal(12,4)"`
+	AmountDiff  float64    `json:"amount_diff" gorm:"type:decimal(15,2)"`
+	MatchedAt   *time.Time `json:"matched_at"`
+	WaivedBy    string     `json:"waived_by" gorm:"size:32"`
+	WaivedAt    *time.Time `json:"waived_at"`
+	WaiveReason string     `json:"waive_reason" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SettlementLine) TableName() string {
+	return "srm_settlement_lines"
+}
+
+// 匹配状态
+const (
+	MatchStatusPending  = "pending"
+	MatchStatusMatched  = "matched"
+	MatchStatusMismatch = "mismatch"
+	MatchStatusWaived   = "waived"
+)
+
+// SettlementInvoiceLine 供应商发票行
+type SettlementInvoiceLine struct {
 	ID           string    `json:"id" gorm:"primaryKey;size:32"`
 	SettlementID string    `json:"settlement_id" gorm:"size:32;not null;index"`
-	DisputeType  string    `json:"dispute_type" gorm:"size:50"` // price_diff/quantity_diff/quality_deduction/other
-	Description  string    `json:"description" gorm:"type:text"`
-	AmountDiff   *float64  `json:"amount_diff" gorm:"type:decimal(12,2)"`
-	Status       string    `json:"status" gorm:"size:20;default:open"` // open/resolved
-	Resolution   string    `json:"resolution" gorm:"type:text"`
+	InvoiceNo    string    `json:"invoice_no" gorm:"size:100;not null"`
+	POItemID     string    `json:"po_item_id" gorm:"size:32;not null;index"`
+	Quantity     float64   `json:"quantity" gorm:"type:decimal(10,2);not null"`
+	UnitPrice    float64   `json:"unit_price" gorm:"type:decimal(12,4);not null"`
+	Amount       float64   `json:"amount" gorm:"type:decimal(15,2)"`
+	CreatedBy    string    `json:"created_by" gorm:"size:32"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
-func (SettlementDispute) TableName() string {
-	return "srm_settlement_disputes"
+func (SettlementInvoiceLine) TableName() string {
+	return "srm_settlement_invoice_lines"
+}
+
+// MatchTolerance 三单匹配容差（SupplierID为空表示全局默认）
+type MatchTolerance struct {
+	ID                string    `json:"id" gorm:"primaryKey;size:32"`
+	SupplierID        *string   `json:"supplier_id" gorm:"size:32;uniqueIndex"`
+	QtyTolerancePct   float64   `json:"qty_tolerance_pct" gorm:"type:decimal(6,2);default:0"`   // 数量容差 %
+	PriceTolerancePct float64   `json:"price_tolerance_pct" gorm:"type:decimal(6,2);default:0"` // 单价容差 %
+	AmountTolerance   float64   `json:"amount_tolerance" gorm:"type:decimal(12,2);default:0"`   // 单行金额差绝对容差
+	UpdatedBy         string    `json:"updated_by" gorm:"size:32"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (MatchTolerance) TableName() string {
+	return "srm_match_tolerances"
 }
@@ -126,6 +126,8 @@ const (
 	SupplierLevelQualified = "qualified"
 	SupplierLevelPreferred = "preferred"
 	SupplierLevelStrategic = "strategic"
+	// SupplierLevelProbation 考察期：周期评估D级降级，暂停新的采购分配，已下达的采购订单照常执行
+	SupplierLevelProbation = "probation"
 )
 
 // 供应商状态
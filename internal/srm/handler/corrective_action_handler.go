@@ -14,30 +14,119 @@ func NewCorrectiveActionHandler(svc *service.CorrectiveActionService) *Correctiv
 	return &CorrectiveActionHandler{svc: svc}
 }
 
+// ListCorrectiveActions 8D列表
+// GET /api/v1/srm/corrective-actions?supplier_id=xxx&status=xxx&defect_code_id=xxx
 func (h *CorrectiveActionHandler) ListCorrectiveActions(c *gin.Context) {
-	Success(c, nil)
+	page, pageSize := GetPagination(c)
+	filters := map[string]string{
+		"supplier_id":    c.Query("supplier_id"),
+		"status":         c.Query("status"),
+		"inspection_id":  c.Query("inspection_id"),
+		"defect_code_id": c.Query("defect_code_id"),
+	}
+
+	items, total, err := h.svc.List(c.Request.Context(), page, pageSize, filters)
+	if err != nil {
+		InternalError(c, "获取8D列表失败: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	Success(c, ListResponse{
+		Items: items,
+		Pagination: &Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: totalPages,
+		},
+	})
 }
 
+// CreateCorrectiveAction 创建8D
+// POST /api/v1/srm/corrective-actions
 func (h *CorrectiveActionHandler) CreateCorrectiveAction(c *gin.Context) {
-	Created(c, nil)
+	var req service.CreateCorrectiveActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	ca, err := h.svc.Create(c.Request.Context(), GetUserID(c), &req)
+	if err != nil {
+		InternalError(c, "创建8D失败: "+err.Error())
+		return
+	}
+	Created(c, ca)
 }
 
+// GetCorrectiveAction 8D详情
+// GET /api/v1/srm/corrective-actions/:id
 func (h *CorrectiveActionHandler) GetCorrectiveAction(c *gin.Context) {
-	Success(c, nil)
+	ca, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		NotFound(c, "8D不存在")
+		return
+	}
+	Success(c, ca)
 }
 
+// UpdateCorrectiveAction 更新8D
+// PUT /api/v1/srm/corrective-actions/:id
 func (h *CorrectiveActionHandler) UpdateCorrectiveAction(c *gin.Context) {
-	Success(c, nil)
+	var req service.UpdateCorrectiveActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	ca, err := h.svc.Update(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		InternalError(c, "更新8D失败: "+err.Error())
+		return
+	}
+	Success(c, ca)
 }
 
+// SupplierRespond 供应商回复
+// POST /api/v1/srm/corrective-actions/:id/respond
 func (h *CorrectiveActionHandler) SupplierRespond(c *gin.Context) {
-	Success(c, nil)
+	var req service.SupplierRespondRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	ca, err := h.svc.SupplierRespond(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		InternalError(c, "回复8D失败: "+err.Error())
+		return
+	}
+	Success(c, ca)
 }
 
+// Verify 验证8D
+// POST /api/v1/srm/corrective-actions/:id/verify
 func (h *CorrectiveActionHandler) Verify(c *gin.Context) {
-	Success(c, nil)
+	ca, err := h.svc.Verify(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, "验证8D失败: "+err.Error())
+		return
+	}
+	Success(c, ca)
 }
 
+// Close 关闭8D
+// POST /api/v1/srm/corrective-actions/:id/close
 func (h *CorrectiveActionHandler) Close(c *gin.Context) {
-	Success(c, nil)
+	ca, err := h.svc.Close(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, "关闭8D失败: "+err.Error())
+		return
+	}
+	Success(c, ca)
 }
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ExchangeRateHandler 汇率处理器
+type ExchangeRateHandler struct {
+	svc *service.ExchangeRateService
+}
+
+func NewExchangeRateHandler(svc *service.ExchangeRateService) *ExchangeRateHandler {
+	return &ExchangeRateHandler{svc: svc}
+}
+
+// ListExchangeRates 汇率列表
+func (h *ExchangeRateHandler) ListExchangeRates(c *gin.Context) {
+	page, pageSize := GetPagination(c)
+	filters := map[string]string{
+		"from_currency": c.Query("from_currency"),
+		"to_currency":   c.Query("to_currency"),
+	}
+
+	items, total, err := h.svc.List(c.Request.Context(), page, pageSize, filters)
+	if err != nil {
+		InternalError(c, "获取汇率列表失败: "+err.Error())
+		return
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	Success(c, ListResponse{
+		Items: items,
+		Pagination: &Pagination{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      int(total),
+			TotalPages: totalPages,
+		},
+	})
+}
+
+// CreateExchangeRate 录入汇率
+func (h *ExchangeRateHandler) CreateExchangeRate(c *gin.Context) {
+	var req service.CreateExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	rate, err := h.svc.Create(c.Request.Context(), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "录入汇率失败: "+err.Error())
+		return
+	}
+	Created(c, rate)
+}
+
+// ImportExchangeRates 导入汇率CSV（multipart 字段 file）
+func (h *ExchangeRateHandler) ImportExchangeRates(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		BadRequest(c, "请上传CSV文件")
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		BadRequest(c, "读取文件失败: "+err.Error())
+		return
+	}
+	defer f.Close()
+
+	result, err := h.svc.ImportCSV(c.Request.Context(), GetUserID(c), f)
+	if err != nil {
+		BadRequest(c, "导入汇率失败: "+err.Error())
+		return
+	}
+	Success(c, result)
+}
+
+// GetEffectiveRate 查询指定日期的有效汇率
+func (h *ExchangeRateHandler) GetEffectiveRate(c *gin.Context) {
+	from := c.Query("from")
+	if from == "" {
+		BadRequest(c, "缺少参数 from")
+		return
+	}
+	date := time.Now()
+	if d := c.Query("date"); d != "" {
+		parsed, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			BadRequest(c, "无效的日期")
+			return
+		}
+		date = parsed
+	}
+
+	rate, err := h.svc.GetRate(c.Request.Context(), from, c.Query("to"), date)
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"from": from, "to": c.DefaultQuery("to", entity.FunctionalCurrency), "date": date.Format("2006-01-02"), "rate": rate})
+}
+
+// DeleteExchangeRate 删除汇率
+func (h *ExchangeRateHandler) DeleteExchangeRate(c *gin.Context) {
+	if err := h.svc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		NotFound(c, "汇率不存在")
+		return
+	}
+	Success(c, nil)
+}
@@ -23,6 +23,10 @@ type Handlers struct {
 	RFQ              *RFQHandler
 	PRItem           *PRItemHandler
 	Sampling         *SamplingHandler
+	Quality          *QualityHandler
+	ExchangeRate     *ExchangeRateHandler
+	Scorecard        *ScorecardHandler
+	Qualification    *QualificationHandler
 }
 
 // NewHandlers 创建SRM处理器集合
@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"errors"
+
+	erpsvc "github.com/bitfantasy/nimo/internal/erp/service"
 	"github.com/bitfantasy/nimo/internal/srm/service"
 	"github.com/gin-gonic/gin"
 )
@@ -156,6 +159,10 @@ func (h *InspectionHandler) CompleteInspection(c *gin.Context) {
 
 	inspection, err := h.svc.CompleteInspection(c.Request.Context(), id, userID, &req)
 	if err != nil {
+		if errors.Is(err, erpsvc.ErrMaterialNotFound) || errors.Is(err, erpsvc.ErrMaterialAmbiguous) {
+			BadRequest(c, "完成检验失败: "+err.Error())
+			return
+		}
 		InternalError(c, "完成检验失败: "+err.Error())
 		return
 	}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"errors"
+
+	erprepo "github.com/bitfantasy/nimo/internal/erp/repository"
 	"github.com/bitfantasy/nimo/internal/srm/service"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // InventoryHandler 库存处理器
@@ -87,7 +91,7 @@ func (h *InventoryHandler) StockIn(c *gin.Context) {
 
 	record, err := h.svc.StockIn(c.Request.Context(), &req)
 	if err != nil {
-		InternalError(c, "入库失败: "+err.Error())
+		inventoryError(c, "入库失败", err)
 		return
 	}
 	Success(c, record)
@@ -105,7 +109,7 @@ func (h *InventoryHandler) StockOut(c *gin.Context) {
 
 	record, err := h.svc.StockOut(c.Request.Context(), &req)
 	if err != nil {
-		BadRequest(c, "出库失败: "+err.Error())
+		inventoryError(c, "出库失败", err)
 		return
 	}
 	Success(c, record)
@@ -123,8 +127,63 @@ func (h *InventoryHandler) StockAdjust(c *gin.Context) {
 
 	record, err := h.svc.StockAdjust(c.Request.Context(), &req)
 	if err != nil {
-		BadRequest(c, "库存调整失败: "+err.Error())
+		inventoryError(c, "库存调整失败", err)
 		return
 	}
 	Success(c, record)
 }
+
+// Reconcile 核对库存结存与流水
+// POST /api/v1/srm/inventory/reconcile
+func (h *InventoryHandler) Reconcile(c *gin.Context) {
+	var req struct {
+		Fix bool `json:"fix"`
+	}
+	c.ShouldBindJSON(&req)
+
+	drifts, err := h.svc.Reconcile(c.Request.Context(), req.Fix, GetUserID(c))
+	if err != nil {
+		InternalError(c, "库存核对失败: "+err.Error())
+		return
+	}
+	Success(c, gin.H{"drifts": drifts, "fixed": req.Fix})
+}
+
+// MigrateLegacyStock 将SRM历史库存合并到统一库存台账
+// POST /api/v1/srm/inventory/migrate
+func (h *InventoryHandler) MigrateLegacyStock(c *gin.Context) {
+	var req struct {
+		DryRun bool `json:"dry_run"`
+	}
+	c.ShouldBindJSON(&req)
+
+	report, err := h.svc.MigrateLegacyStock(c.Request.Context(), req.DryRun, GetUserID(c))
+	if err != nil {
+		InternalError(c, "库存迁移失败: "+err.Error())
+		return
+	}
+	Success(c, report)
+}
+
+// ListMigrations 历史库存迁移记录（冲突报告）
+// GET /api/v1/srm/inventory/migrations?status=CONFLICT
+func (h *InventoryHandler) ListMigrations(c *gin.Context) {
+	items, err := h.svc.ListMigrations(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		InternalError(c, "获取迁移记录失败: "+err.Error())
+		return
+	}
+	Success(c, items)
+}
+
+// inventoryError 并发冲突返回409，库存记录不存在返回404，其余返回400
+func inventoryError(c *gin.Context, prefix string, err error) {
+	switch {
+	case errors.Is(err, erprepo.ErrInventoryConflict):
+		Error(c, 40900, prefix+": "+err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		NotFound(c, prefix+": 库存记录不存在")
+	default:
+		BadRequest(c, prefix+": "+err.Error())
+	}
+}
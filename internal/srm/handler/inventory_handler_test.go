@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	erpentity "github.com/bitfantasy/nimo/internal/erp/entity"
+	erprepo "github.com/bitfantasy/nimo/internal/erp/repository"
+	erpsvc "github.com/bitfantasy/nimo/internal/erp/service"
+	plmentity "github.com/bitfantasy/nimo/internal/plm/entity"
+	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/bitfantasy/nimo/internal/srm/service"
+)
+
+func setupInventoryTest(t *testing.T) *testutil.TestEnv {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	if err := db.AutoMigrate(
+		&entity.Supplier{},
+		&entity.InventoryRecord{},
+		&entity.InventoryTransaction{},
+		&plmentity.Material{},
+		&erpentity.Warehouse{},
+		&erpentity.Inventory{},
+		&erpentity.InventoryTransaction{},
+		&erpentity.InventoryReservation{},
+		&erpentity.InventoryReconciliation{},
+		&erpentity.InventoryMigration{},
+		&plmentity.ProjectBOMItem{},
+	); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
+	}
+
+	for _, code := range []string{"RES-001", "RES-002", "RES-003", "RES-004"} {
+		if err := db.Create(&plmentity.Material{
+			ID: "mat-" + code, Code: code, Name: "测试电阻 " + code, CategoryID: "cat-test", Unit: "pcs", CreatedBy: "test-user",
+		}).Error; err != nil {
+			t.Fatalf("Failed to seed material: %v", err)
+		}
+	}
+
+	stock := erpsvc.NewInventoryService(erprepo.NewInventoryRepository(db))
+	svc := service.NewInventoryService(repository.NewInventoryRepository(db), stock)
+	handler := NewInventoryHandler(svc)
+
+	router := testutil.SetupRouter()
+	api := testutil.AuthGroup(router, "/api/v1/srm")
+	api.POST("/inventory/in", handler.StockIn)
+	api.POST("/inventory/out", handler.StockOut)
+	api.POST("/inventory/adjust", handler.StockAdjust)
+	api.POST("/inventory/reconcile", handler.Reconcile)
+	api.POST("/inventory/migrate", handler.MigrateLegacyStock)
+
+	return &testutil.TestEnv{DB: db, Router: router, T: t}
+}
+
+func stockInForTest(t *testing.T, env *testutil.TestEnv, code string, qty float64) map[string]interface{} {
+	t.Helper()
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/in", map[string]interface{}{
+		"material_name": "测试电阻",
+		"material_code": code,
+		"quantity":      qty,
+	}, testutil.DefaultTestToken())
+	if w.Code != http.StatusOK {
+		t.Fatalf("stock in: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return testutil.ParseResponse(w)["data"].(map[string]interface{})
+}
+
+// TestInventoryStockOutInsufficient 出库超过结存应失败，且不产生流水
+func TestInventoryStockOutInsufficient(t *testing.T) {
+	env := setupInventoryTest(t)
+	token := testutil.DefaultTestToken()
+
+	record := stockInForTest(t, env, "RES-001", 10)
+	id := record["id"].(string)
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/out", map[string]interface{}{
+		"inventory_id": id,
+		"quantity":     11,
+	}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	env.DB.Model(&erpentity.InventoryTransaction{}).Where("inventory_id = ?", id).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 transaction, got %d", count)
+	}
+}
+
+// TestInventoryStaleVersion 携带过期版本号的调整返回409
+func TestInventoryStaleVersion(t *testing.T) {
+	env := setupInventoryTest(t)
+	token := testutil.DefaultTestToken()
+
+	record := stockInForTest(t, env, "RES-002", 10)
+	id := record["id"].(string)
+	version := int(record["version"].(float64))
+
+	stockInForTest(t, env, "RES-002", 5)
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/adjust", map[string]interface{}{
+		"inventory_id": id,
+		"quantity":     3,
+		"version":      version,
+	}, token)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestInventoryConcurrentStockOut 并发出库不超卖，结存与流水一致
+func TestInventoryConcurrentStockOut(t *testing.T) {
+	env := setupInventoryTest(t)
+	token := testutil.DefaultTestToken()
+
+	record := stockInForTest(t, env, "RES-003", 5)
+	id := record["id"].(string)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/out", map[string]interface{}{
+				"inventory_id": id,
+				"quantity":     1,
+			}, token)
+			if w.Code == http.StatusOK {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 5 {
+		t.Fatalf("expected 5 successful stock-outs, got %d", succeeded)
+	}
+
+	var rec erpentity.Inventory
+	env.DB.Where("id = ?", id).First(&rec)
+	if rec.Quantity != 0 {
+		t.Fatalf("expected quantity 0, got %v", rec.Quantity)
+	}
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/reconcile", nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	if drifts, _ := data["drifts"].([]interface{}); len(drifts) != 0 {
+		t.Fatalf("expected no drift, got %v", drifts)
+	}
+}
+
+// TestInventoryMigrateLegacy 历史记录按物料编码合并，无法识别的进入冲突报告，重复执行不重复迁入
+func TestInventoryMigrateLegacy(t *testing.T) {
+	env := setupInventoryTest(t)
+	token := testutil.DefaultTestToken()
+
+	supA, supB := "sup-a", "sup-b"
+	legacy := []entity.InventoryRecord{
+		{ID: "legacy-1", MaterialName: "测试电阻", MaterialCode: "RES-004", SupplierID: &supA, Quantity: 30, Unit: "pcs"},
+		{ID: "legacy-2", MaterialName: "测试电阻", MaterialCode: "RES-004", SupplierID: &supB, Quantity: 20, Unit: "pcs"},
+		{ID: "legacy-3", MaterialName: "未建档物料", MaterialCode: "UNKNOWN-1", Quantity: 5, Unit: "pcs"},
+	}
+	if err := env.DB.Create(&legacy).Error; err != nil {
+		t.Fatalf("Failed to seed legacy records: %v", err)
+	}
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/migrate", nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	if data["merged"].(float64) != 2 || data["conflicts"].(float64) != 1 {
+		t.Fatalf("expected 2 merged and 1 conflict, got %v", data)
+	}
+
+	var inv erpentity.Inventory
+	if err := env.DB.Where("material_id = ?", "mat-RES-004").First(&inv).Error; err != nil {
+		t.Fatalf("expected merged balance: %v", err)
+	}
+	if inv.Quantity != 50 {
+		t.Fatalf("expected merged quantity 50, got %v", inv.Quantity)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/migrate", nil, token)
+	data = testutil.ParseResponse(w)["data"].(map[string]interface{})
+	if data["skipped"].(float64) != 2 {
+		t.Fatalf("expected 2 skipped on rerun, got %v", data)
+	}
+	env.DB.Where("id = ?", inv.ID).First(&inv)
+	if inv.Quantity != 50 {
+		t.Fatalf("expected quantity unchanged on rerun, got %v", inv.Quantity)
+	}
+}
+
+// TestInventoryMigrateAmbiguousName 无编码的历史记录按名称匹配到多个物料时，用制造商料号筛选；无料号的进入冲突报告
+func TestInventoryMigrateAmbiguousName(t *testing.T) {
+	env := setupInventoryTest(t)
+
+	for _, code := range []string{"CAP-001", "CAP-002"} {
+		if err := env.DB.Create(&plmentity.Material{
+			ID: "mat-" + code, Code: code, Name: "同名电容", CategoryID: "cat-test", Unit: "pcs", CreatedBy: "test-user",
+		}).Error; err != nil {
+			t.Fatalf("Failed to seed material: %v", err)
+		}
+	}
+	materialID := "mat-CAP-002"
+	if err := env.DB.Create(&plmentity.ProjectBOMItem{
+		ID: "bom-item-cap", BOMID: "bom-test", MaterialID: &materialID, Category: "electronic", SubCategory: "component",
+		Name: "同名电容", Quantity: 1, Unit: "pcs", MPN: "GRM155R71C104KA88D",
+	}).Error; err != nil {
+		t.Fatalf("Failed to seed BOM item: %v", err)
+	}
+	legacy := []entity.InventoryRecord{
+		{ID: "legacy-cap-1", MaterialName: "同名电容", MPN: "GRM155R71C104KA88D", Quantity: 100, Unit: "pcs"},
+		{ID: "legacy-cap-2", MaterialName: "同名电容", Quantity: 10, Unit: "pcs"},
+	}
+	if err := env.DB.Create(&legacy).Error; err != nil {
+		t.Fatalf("Failed to seed legacy records: %v", err)
+	}
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/inventory/migrate", nil, testutil.DefaultTestToken())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	if data["merged"].(float64) != 1 || data["conflicts"].(float64) != 1 {
+		t.Fatalf("expected 1 merged and 1 conflict, got %v", data)
+	}
+	for _, it := range data["items"].([]interface{}) {
+		item := it.(map[string]interface{})
+		switch item["source_id"] {
+		case "legacy-cap-1":
+			if item["material_id"] != materialID {
+				t.Fatalf("expected MPN to narrow to %s, got %v", materialID, item["material_id"])
+			}
+		case "legacy-cap-2":
+			if item["conflict_type"] != erpentity.MigrationConflictMaterialAmbiguous ||
+				!strings.Contains(item["detail"].(string), "物料名称 同名电容") {
+				t.Fatalf("expected an ambiguous-name conflict, got %v", item)
+			}
+		}
+	}
+}
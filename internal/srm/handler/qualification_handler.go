@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/bitfantasy/nimo/internal/srm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// QualificationHandler 供应商准入与资质证书处理器
+type QualificationHandler struct {
+	svc *service.QualificationService
+}
+
+func NewQualificationHandler(svc *service.QualificationService) *QualificationHandler {
+	return &QualificationHandler{svc: svc}
+}
+
+// ListQualifications 供应商准入审核记录
+// GET /api/v1/srm/suppliers/:id/qualifications
+func (h *QualificationHandler) ListQualifications(c *gin.Context) {
+	items, err := h.svc.ListBySupplier(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, "获取准入审核记录失败: "+err.Error())
+		return
+	}
+	Success(c, gin.H{"items": items})
+}
+
+// StartQualification 发起准入审核
+// POST /api/v1/srm/suppliers/:id/qualifications
+func (h *QualificationHandler) StartQualification(c *gin.Context) {
+	q, err := h.svc.Start(c.Request.Context(), c.Param("id"), GetUserID(c))
+	if err != nil {
+		BadRequest(c, "发起准入审核失败: "+err.Error())
+		return
+	}
+	Created(c, q)
+}
+
+// GetQualification 准入审核单详情
+// GET /api/v1/srm/qualifications/:id
+func (h *QualificationHandler) GetQualification(c *gin.Context) {
+	q, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		NotFound(c, "准入审核单不存在")
+		return
+	}
+	Success(c, q)
+}
+
+// SubmitQuestionnaire 提交调查问卷
+// POST /api/v1/srm/qualifications/:id/questionnaire
+func (h *QualificationHandler) SubmitQuestionnaire(c *gin.Context) {
+	var req service.SubmitQuestionnaireRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	q, err := h.svc.SubmitQuestionnaire(c.Request.Context(), c.Param("id"), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "提交问卷失败: "+err.Error())
+		return
+	}
+	Success(c, q)
+}
+
+// RecordAudit 登记现场审核
+// POST /api/v1/srm/qualifications/:id/audit
+func (h *QualificationHandler) RecordAudit(c *gin.Context) {
+	var req service.RecordAuditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	q, err := h.svc.RecordAudit(c.Request.Context(), c.Param("id"), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "登记现场审核失败: "+err.Error())
+		return
+	}
+	Success(c, q)
+}
+
+// LinkSample 关联样品承认打样记录
+// POST /api/v1/srm/qualifications/:id/sample
+func (h *QualificationHandler) LinkSample(c *gin.Context) {
+	var req service.LinkSampleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	q, err := h.svc.LinkSample(c.Request.Context(), c.Param("id"), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "关联打样记录失败: "+err.Error())
+		return
+	}
+	Success(c, q)
+}
+
+// SignOff 准入会签
+// POST /api/v1/srm/qualifications/:id/signoff
+func (h *QualificationHandler) SignOff(c *gin.Context) {
+	var req service.SignOffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	q, err := h.svc.SignOff(c.Request.Context(), c.Param("id"), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "准入会签失败: "+err.Error())
+		return
+	}
+	Success(c, q)
+}
+
+// ListCertificates 供应商资质证书
+// GET /api/v1/srm/suppliers/:id/certificates
+func (h *QualificationHandler) ListCertificates(c *gin.Context) {
+	items, err := h.svc.ListCertificates(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		InternalError(c, "获取证书列表失败: "+err.Error())
+		return
+	}
+	Success(c, gin.H{"items": items})
+}
+
+// CreateCertificate 新增资质证书
+// POST /api/v1/srm/suppliers/:id/certificates
+func (h *QualificationHandler) CreateCertificate(c *gin.Context) {
+	var req service.CreateCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	cert, err := h.svc.CreateCertificate(c.Request.Context(), c.Param("id"), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "新增证书失败: "+err.Error())
+		return
+	}
+	Created(c, cert)
+}
+
+// DeleteCertificate 删除资质证书
+// DELETE /api/v1/srm/suppliers/:id/certificates/:certId
+func (h *QualificationHandler) DeleteCertificate(c *gin.Context) {
+	if err := h.svc.DeleteCertificate(c.Request.Context(), c.Param("id"), c.Param("certId")); err != nil {
+		NotFound(c, "证书不存在")
+		return
+	}
+	Success(c, nil)
+}
+
+// ListExpiringCertificates 临期/过期证书
+// GET /api/v1/srm/certificates/expiring?days=30
+func (h *QualificationHandler) ListExpiringCertificates(c *gin.Context) {
+	days, _ := strconv.Atoi(c.Query("days"))
+	items, err := h.svc.ListExpiring(c.Request.Context(), days)
+	if err != nil {
+		InternalError(c, "获取临期证书失败: "+err.Error())
+		return
+	}
+	Success(c, gin.H{"items": items})
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/bitfantasy/nimo/internal/srm/service"
+)
+
+func setupQualificationTest(t *testing.T) (*testutil.TestEnv, *service.QualificationService) {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	if err := db.AutoMigrate(
+		&entity.Supplier{},
+		&entity.SupplierContact{},
+		&entity.SamplingRequest{},
+		&entity.SupplierQualification{},
+		&entity.SupplierCertificate{},
+	); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
+	}
+
+	repos := repository.NewRepositories(db)
+	svc := service.NewQualificationService(repos.Qualification, repos.Supplier, repos.Sampling)
+	supplierSvc := service.NewSupplierService(repos.Supplier)
+	supplierSvc.SetQualificationRepo(repos.Qualification)
+	handler := NewQualificationHandler(svc)
+	supplierHandler := NewSupplierHandler(supplierSvc)
+
+	router := testutil.SetupRouter()
+	api := testutil.AuthGroup(router, "/api/v1/srm")
+	api.PUT("/suppliers/:id", supplierHandler.UpdateSupplier)
+	api.POST("/suppliers/:id/status", supplierHandler.ChangeStatus)
+	api.POST("/suppliers/:id/qualifications", handler.StartQualification)
+	api.POST("/suppliers/:id/certificates", handler.CreateCertificate)
+	api.POST("/qualifications/:id/questionnaire", handler.SubmitQuestionnaire)
+	api.POST("/qualifications/:id/audit", handler.RecordAudit)
+	api.POST("/qualifications/:id/sample", handler.LinkSample)
+	api.POST("/qualifications/:id/signoff", handler.SignOff)
+
+	return &testutil.TestEnv{DB: db, Router: router, T: t}, svc
+}
+
+// TestSupplierQualificationWorkflow walks a supplier through questionnaire, audit, sample and sign-off,
+// then lets a certificate expire and checks the automatic suspension.
+func TestSupplierQualificationWorkflow(t *testing.T) {
+	env, svc := setupQualificationTest(t)
+	token := testutil.DefaultTestToken()
+
+	supplierID := "sup-qua-001"
+	seedTestSupplier(t, env, supplierID, "SUP-Q001", "准入供应商", "structural", entity.SupplierStatusPending)
+
+	// level/status can no longer be edited directly
+	w := testutil.DoRequest(env.Router, http.MethodPut, "/api/v1/srm/suppliers/"+supplierID,
+		map[string]interface{}{"status": "active"}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for direct status edit, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/suppliers/"+supplierID+"/qualifications", nil, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	qualID := testutil.ParseResponse(w)["data"].(map[string]interface{})["id"].(string)
+	base := "/api/v1/srm/qualifications/" + qualID
+
+	// steps must run in order
+	w = testutil.DoRequest(env.Router, http.MethodPost, base+"/signoff", map[string]interface{}{"approved": true}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for sign-off before questionnaire, got %d", w.Code)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, base+"/questionnaire",
+		map[string]interface{}{"answers": map[string]interface{}{"has_iso9001": true}, "score": 85}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("questionnaire: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w = testutil.DoRequest(env.Router, http.MethodPost, base+"/audit",
+		map[string]interface{}{"auditor": "张三", "score": 88, "result": "passed"}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("audit: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	verifiedAt := time.Now()
+	env.DB.Create(&entity.SamplingRequest{ID: "smp-qua-other", SupplierID: "sup-other", Round: 1, Status: entity.SamplingStatusPassed})
+	env.DB.Create(&entity.SamplingRequest{ID: "smp-qua-001", SupplierID: supplierID, Round: 1,
+		Status: entity.SamplingStatusPassed, VerifyResult: "passed", VerifiedAt: &verifiedAt})
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, base+"/sample",
+		map[string]interface{}{"sampling_request_id": "smp-qua-other"}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for another supplier's sample, got %d", w.Code)
+	}
+	w = testutil.DoRequest(env.Router, http.MethodPost, base+"/sample",
+		map[string]interface{}{"sampling_request_id": "smp-qua-001"}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sample: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if step := testutil.ParseResponse(w)["data"].(map[string]interface{})["current_step"]; step != entity.QualificationStepSignoff {
+		t.Fatalf("expected step signoff after passed sample, got %v", step)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, base+"/signoff",
+		map[string]interface{}{"approved": true, "comment": "同意准入"}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("signoff: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var supplier entity.Supplier
+	env.DB.First(&supplier, "id = ?", supplierID)
+	if !supplier.IsQualified() {
+		t.Fatalf("expected qualified active supplier, got status=%s level=%s", supplier.Status, supplier.Level)
+	}
+
+	// certificate expiring soon → reminder; expired → supplier suspended
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/suppliers/"+supplierID+"/certificates",
+		map[string]interface{}{"cert_type": "iso9001", "cert_no": "ISO-001", "expires_at": time.Now().AddDate(0, 0, 10)}, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("certificate: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	ctx := context.Background()
+	reminded, suspended, err := svc.CheckCertificates(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("check certificates: %v", err)
+	}
+	if reminded != 1 || suspended != 0 {
+		t.Fatalf("expected 1 reminder and no suspension, got %d/%d", reminded, suspended)
+	}
+
+	env.DB.Model(&entity.SupplierCertificate{}).Where("supplier_id = ?", supplierID).
+		Update("expires_at", time.Now().AddDate(0, 0, -1))
+	_, suspended, err = svc.CheckCertificates(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("check certificates: %v", err)
+	}
+	if suspended != 1 {
+		t.Fatalf("expected supplier suspended on expiry, got %d", suspended)
+	}
+	env.DB.First(&supplier, "id = ?", supplierID)
+	if supplier.Status != entity.SupplierStatusSuspended {
+		t.Fatalf("expected suspended, got %s", supplier.Status)
+	}
+
+	// reactivation is blocked until the certificate is renewed
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/suppliers/"+supplierID+"/status",
+		map[string]interface{}{"status": "active", "reason": "恢复合作"}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for reactivation with expired certificate, got %d", w.Code)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"github.com/bitfantasy/nimo/internal/srm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// QualityHandler 缺陷代码与质量分析处理器
+type QualityHandler struct {
+	defectSvc    *service.DefectService
+	analyticsSvc *service.QualityAnalyticsService
+}
+
+func NewQualityHandler(defectSvc *service.DefectService, analyticsSvc *service.QualityAnalyticsService) *QualityHandler {
+	return &QualityHandler{defectSvc: defectSvc, analyticsSvc: analyticsSvc}
+}
+
+// ListDefectCodes 缺陷代码列表
+// GET /api/v1/srm/defect-codes?level=xxx&parent_id=xxx&status=xxx&search=xxx
+func (h *QualityHandler) ListDefectCodes(c *gin.Context) {
+	filters := map[string]string{
+		"level":     c.Query("level"),
+		"parent_id": c.Query("parent_id"),
+		"status":    c.Query("status"),
+		"search":    c.Query("search"),
+	}
+	items, err := h.defectSvc.List(c.Request.Context(), filters)
+	if err != nil {
+		InternalError(c, "获取缺陷代码失败: "+err.Error())
+		return
+	}
+	Success(c, items)
+}
+
+// GetDefectCodeTree 缺陷代码树
+// GET /api/v1/srm/defect-codes/tree?include_inactive=true
+func (h *QualityHandler) GetDefectCodeTree(c *gin.Context) {
+	tree, err := h.defectSvc.Tree(c.Request.Context(), c.Query("include_inactive") == "true")
+	if err != nil {
+		InternalError(c, "获取缺陷代码树失败: "+err.Error())
+		return
+	}
+	Success(c, tree)
+}
+
+// CreateDefectCode 创建缺陷代码
+// POST /api/v1/srm/defect-codes
+func (h *QualityHandler) CreateDefectCode(c *gin.Context) {
+	var req service.CreateDefectCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	code, err := h.defectSvc.Create(c.Request.Context(), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "创建缺陷代码失败: "+err.Error())
+		return
+	}
+	Created(c, code)
+}
+
+// UpdateDefectCode 更新缺陷代码
+// PUT /api/v1/srm/defect-codes/:id
+func (h *QualityHandler) UpdateDefectCode(c *gin.Context) {
+	var req service.UpdateDefectCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	code, err := h.defectSvc.Update(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		InternalError(c, "更新缺陷代码失败: "+err.Error())
+		return
+	}
+	Success(c, code)
+}
+
+// DeleteDefectCode 删除缺陷代码
+// DELETE /api/v1/srm/defect-codes/:id
+func (h *QualityHandler) DeleteDefectCode(c *gin.Context) {
+	if err := h.defectSvc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		BadRequest(c, "删除缺陷代码失败: "+err.Error())
+		return
+	}
+	Success(c, nil)
+}
+
+// GetPareto 不良Pareto
+// GET /api/v1/srm/quality/pareto?group_by=category|mode|cause|supplier|material&source=inspection|8d&supplier_id=&material_code=&period=2026-Q1
+func (h *QualityHandler) GetPareto(c *gin.Context) {
+	var q service.QualityQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.analyticsSvc.Pareto(c.Request.Context(), &q)
+	if err != nil {
+		BadRequest(c, "Pareto分析失败: "+err.Error())
+		return
+	}
+	Success(c, result)
+}
+
+// GetSupplierPPM 供应商PPM排名
+// GET /api/v1/srm/quality/ppm?period=2026-Q1&material_code=
+func (h *QualityHandler) GetSupplierPPM(c *gin.Context) {
+	var q service.QualityQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	items, err := h.analyticsSvc.SupplierPPM(c.Request.Context(), &q)
+	if err != nil {
+		BadRequest(c, "PPM统计失败: "+err.Error())
+		return
+	}
+	Success(c, items)
+}
+
+// GetTrend 质量趋势
+// GET /api/v1/srm/quality/trend?granularity=month|week&supplier_id=&start_date=&end_date=
+func (h *QualityHandler) GetTrend(c *gin.Context) {
+	var q service.QualityQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	items, err := h.analyticsSvc.Trend(c.Request.Context(), &q)
+	if err != nil {
+		BadRequest(c, "质量趋势统计失败: "+err.Error())
+		return
+	}
+	Success(c, items)
+}
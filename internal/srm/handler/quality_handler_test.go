@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/bitfantasy/nimo/internal/srm/service"
+)
+
+func setupQualityTest(t *testing.T) (*testutil.TestEnv, *QualityHandler) {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	if err := db.AutoMigrate(
+		&entity.Supplier{},
+		&entity.Inspection{},
+		&entity.InspectionItem{},
+		&entity.CorrectiveAction{},
+		&entity.DefectCode{},
+	); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
+	}
+
+	defectRepo := repository.NewDefectRepository(db)
+	handler := NewQualityHandler(service.NewDefectService(defectRepo), service.NewQualityAnalyticsService(defectRepo))
+
+	router := testutil.SetupRouter()
+	api := testutil.AuthGroup(router, "/api/v1/srm")
+	api.GET("/defect-codes/tree", handler.GetDefectCodeTree)
+	api.POST("/defect-codes", handler.CreateDefectCode)
+	api.GET("/quality/pareto", handler.GetPareto)
+	api.GET("/quality/ppm", handler.GetSupplierPPM)
+
+	return &testutil.TestEnv{DB: db, Router: router, T: t}, handler
+}
+
+func createDefectCode(t *testing.T, env *testutil.TestEnv, token string, body map[string]interface{}) string {
+	t.Helper()
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/defect-codes", body, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	return data["id"].(string)
+}
+
+// TestDefectCodeHierarchy verifies that mode/cause codes must hang under the right parent level.
+func TestDefectCodeHierarchy(t *testing.T) {
+	env, _ := setupQualityTest(t)
+	token := testutil.DefaultTestToken()
+
+	catID := createDefectCode(t, env, token, map[string]interface{}{"code": "APP", "name": "外观", "level": "category"})
+	modeID := createDefectCode(t, env, token, map[string]interface{}{"code": "APP-SCR", "name": "划伤", "level": "mode", "parent_id": catID})
+	createDefectCode(t, env, token, map[string]interface{}{"code": "APP-SCR-PKG", "name": "包装不当", "level": "cause", "parent_id": modeID})
+
+	// cause directly under a category is rejected
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/defect-codes",
+		map[string]interface{}{"code": "BAD", "name": "错误层级", "level": "cause", "parent_id": catID}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for cause under category, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodGet, "/api/v1/srm/defect-codes/tree", nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	roots := testutil.ParseResponse(w)["data"].([]interface{})
+	if len(roots) != 1 {
+		t.Fatalf("expected 1 root category, got %d", len(roots))
+	}
+	modes := roots[0].(map[string]interface{})["children"].([]interface{})
+	causes := modes[0].(map[string]interface{})["children"].([]interface{})
+	if len(modes) != 1 || len(causes) != 1 {
+		t.Fatalf("expected 1 mode with 1 cause, got %d modes / %d causes", len(modes), len(causes))
+	}
+}
+
+// TestQualityParetoAndPPM verifies cause-level defects roll up to their category and PPM is computed per supplier.
+func TestQualityParetoAndPPM(t *testing.T) {
+	env, _ := setupQualityTest(t)
+	token := testutil.DefaultTestToken()
+
+	catID := createDefectCode(t, env, token, map[string]interface{}{"code": "DIM", "name": "尺寸", "level": "category"})
+	modeID := createDefectCode(t, env, token, map[string]interface{}{"code": "DIM-OOT", "name": "超差", "level": "mode", "parent_id": catID})
+	causeID := createDefectCode(t, env, token, map[string]interface{}{"code": "DIM-OOT-MLD", "name": "模具磨损", "level": "cause", "parent_id": modeID})
+
+	supplierID := "sup-q-001"
+	env.DB.Create(&entity.Supplier{ID: supplierID, Code: "SUP-Q001", Name: "质量供应商", Category: "structural", Status: "active"})
+
+	now := time.Now()
+	env.DB.Create(&entity.Inspection{
+		ID: "ins-q-001", InspectionCode: "IQC-T-0001", SupplierID: &supplierID,
+		Status: entity.InspectionStatusCompleted, Result: entity.InspectionResultFailed, InspectedAt: &now,
+		Items: []entity.InspectionItem{
+			{ID: "ins-q-001-1", MaterialCode: "M-001", InspectedQty: 1000, DefectQty: 3, DefectCodeID: &causeID},
+			{ID: "ins-q-001-2", MaterialCode: "M-002", InspectedQty: 1000, DefectQty: 1},
+		},
+	})
+
+	w := testutil.DoRequest(env.Router, http.MethodGet, "/api/v1/srm/quality/pareto?group_by=category", nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	entries := data["entries"].([]interface{})
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 pareto entries (DIM + uncoded), got %d", len(entries))
+	}
+	top := entries[0].(map[string]interface{})
+	if top["code"] != "DIM" || top["value"].(float64) != 3 {
+		t.Fatalf("expected DIM with 3 defects first, got %v", top)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodGet, "/api/v1/srm/quality/ppm", nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	ppm := testutil.ParseResponse(w)["data"].([]interface{})
+	if len(ppm) != 1 || ppm[0].(map[string]interface{})["ppm"].(float64) != 2000 {
+		t.Fatalf("expected 2000 PPM for the supplier, got %v", ppm)
+	}
+}
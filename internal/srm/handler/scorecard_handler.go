@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"github.com/bitfantasy/nimo/internal/srm/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ScorecardHandler 评分卡处理器
+type ScorecardHandler struct {
+	svc *service.ScorecardService
+}
+
+func NewScorecardHandler(svc *service.ScorecardService) *ScorecardHandler {
+	return &ScorecardHandler{svc: svc}
+}
+
+// ListScorecards 评分卡列表
+func (h *ScorecardHandler) ListScorecards(c *gin.Context) {
+	filters := map[string]string{
+		"category": c.Query("category"),
+		"status":   c.Query("status"),
+	}
+	items, err := h.svc.List(c.Request.Context(), filters)
+	if err != nil {
+		InternalError(c, "获取评分卡列表失败: "+err.Error())
+		return
+	}
+	Success(c, items)
+}
+
+// GetScorecard 评分卡详情
+func (h *ScorecardHandler) GetScorecard(c *gin.Context) {
+	sc, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		NotFound(c, "评分卡不存在")
+		return
+	}
+	Success(c, sc)
+}
+
+// CreateScorecard 创建评分卡
+func (h *ScorecardHandler) CreateScorecard(c *gin.Context) {
+	var req service.SaveScorecardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	sc, err := h.svc.Create(c.Request.Context(), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "创建评分卡失败: "+err.Error())
+		return
+	}
+	Created(c, sc)
+}
+
+// UpdateScorecard 更新评分卡
+func (h *ScorecardHandler) UpdateScorecard(c *gin.Context) {
+	var req service.SaveScorecardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	sc, err := h.svc.Update(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		BadRequest(c, "更新评分卡失败: "+err.Error())
+		return
+	}
+	Success(c, sc)
+}
+
+// DeleteScorecard 删除评分卡
+func (h *ScorecardHandler) DeleteScorecard(c *gin.Context) {
+	if err := h.svc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		NotFound(c, "评分卡不存在")
+		return
+	}
+	Success(c, nil)
+}
+
+// RunPeriod 手工触发周期评估
+func (h *ScorecardHandler) RunPeriod(c *gin.Context) {
+	var req service.RunScorecardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	run, err := h.svc.RunPeriod(c.Request.Context(), req.Period, req.EvalType, "manual")
+	if err != nil {
+		if run != nil {
+			InternalError(c, "周期评估部分失败: "+err.Error())
+			return
+		}
+		BadRequest(c, "周期评估失败: "+err.Error())
+		return
+	}
+	Success(c, run)
+}
+
+// ListRuns 周期评估运行记录
+func (h *ScorecardHandler) ListRuns(c *gin.Context) {
+	runs, err := h.svc.ListRuns(c.Request.Context())
+	if err != nil {
+		InternalError(c, "获取运行记录失败: "+err.Error())
+		return
+	}
+	Success(c, runs)
+}
+
+// UpdateKPIScore 人工修改评估KPI得分
+func (h *ScorecardHandler) UpdateKPIScore(c *gin.Context) {
+	var req struct {
+		Score *float64 `json:"score" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	eval, err := h.svc.UpdateKPIScore(c.Request.Context(), c.Param("id"), c.Param("resultId"), *req.Score)
+	if err != nil {
+		BadRequest(c, "修改KPI得分失败: "+err.Error())
+		return
+	}
+	Success(c, eval)
+}
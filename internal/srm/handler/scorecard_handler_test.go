@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/bitfantasy/nimo/internal/srm/service"
+)
+
+func setupScorecardTest(t *testing.T) *testutil.TestEnv {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	if err := db.AutoMigrate(
+		&entity.Supplier{},
+		&entity.PurchaseOrder{},
+		&entity.POItem{},
+		&entity.PRItem{},
+		&entity.Inspection{},
+		&entity.InspectionItem{},
+		&entity.CorrectiveAction{},
+		&entity.DefectCode{},
+		&entity.SupplierEvaluation{},
+		&entity.Scorecard{},
+		&entity.ScorecardKPI{},
+		&entity.EvaluationKPIResult{},
+		&entity.ScorecardRun{},
+	); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
+	}
+
+	repos := repository.NewRepositories(db)
+	svc := service.NewScorecardService(repos.Scorecard, repos.Evaluation, repos.Supplier)
+	svc.SetQualityAnalyticsService(service.NewQualityAnalyticsService(repos.Defect))
+	handler := NewScorecardHandler(svc)
+
+	router := testutil.SetupRouter()
+	api := testutil.AuthGroup(router, "/api/v1/srm")
+	api.POST("/scorecards", handler.CreateScorecard)
+	api.POST("/evaluations/run", handler.RunPeriod)
+
+	return &testutil.TestEnv{DB: db, Router: router, T: t}
+}
+
+// TestScorecardPeriodicRun verifies weighted KPI scoring from PO/inspection data and the supplier write-back.
+func TestScorecardPeriodicRun(t *testing.T) {
+	env := setupScorecardTest(t)
+	token := testutil.DefaultTestToken()
+
+	// weights must add up to 100
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/scorecards", map[string]interface{}{
+		"name": "结构件评分卡", "category": "structural", "frequency": "monthly",
+		"kpis": []map[string]interface{}{
+			{"code": "OTD", "name": "准时交付", "dimension": "delivery", "data_source": "otd", "weight": 60},
+			{"code": "PPM", "name": "来料PPM", "dimension": "quality", "data_source": "ppm", "weight": 30},
+		},
+	}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for weights summing to 90, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/scorecards", map[string]interface{}{
+		"name": "结构件评分卡", "category": "structural", "frequency": "monthly",
+		"kpis": []map[string]interface{}{
+			{"code": "OTD", "name": "准时交付", "dimension": "delivery", "data_source": "otd", "weight": 60},
+			{"code": "PPM", "name": "来料PPM", "dimension": "quality", "data_source": "ppm", "weight": 40},
+		},
+	}, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	supplierID := "sup-sc-001"
+	env.DB.Create(&entity.Supplier{ID: supplierID, Code: "SUP-SC001", Name: "评分供应商", Category: "structural",
+		Level: entity.SupplierLevelQualified, Status: entity.SupplierStatusActive})
+
+	day := func(d int) *time.Time {
+		v := time.Date(2026, 3, d, 10, 0, 0, 0, time.Local)
+		return &v
+	}
+	// 2 of 2 deliveries on time → OTD 100% → 100 points
+	env.DB.Create(&entity.PurchaseOrder{ID: "po-sc-1", POCode: "PO-SC-1", SupplierID: supplierID, Type: "production",
+		Status: "received", ExpectedDate: day(10), ActualDate: day(9)})
+	env.DB.Create(&entity.PurchaseOrder{ID: "po-sc-2", POCode: "PO-SC-2", SupplierID: supplierID, Type: "production",
+		Status: "received", ExpectedDate: day(20), ActualDate: day(20)})
+	// 5000 PPM → 50 points
+	env.DB.Create(&entity.Inspection{
+		ID: "ins-sc-1", InspectionCode: "IQC-SC-1", SupplierID: &supplierID,
+		Status: entity.InspectionStatusCompleted, Result: entity.InspectionResultPassed, InspectedAt: day(15),
+		Items: []entity.InspectionItem{{ID: "ins-sc-1-1", MaterialCode: "M-001", InspectedQty: 1000, DefectQty: 5}},
+	})
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/evaluations/run",
+		map[string]interface{}{"period": "2026-03", "eval_type": "monthly"}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	run := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	if run["created_count"].(float64) != 1 {
+		t.Fatalf("expected 1 evaluation created, got %v", run["created_count"])
+	}
+
+	var supplier entity.Supplier
+	env.DB.First(&supplier, "id = ?", supplierID)
+	// 100*0.6 + 50*0.4 = 80 → grade B → qualified
+	if supplier.OverallScore == nil || *supplier.OverallScore != 80 {
+		t.Fatalf("expected overall score 80, got %v", supplier.OverallScore)
+	}
+	if supplier.Level != entity.SupplierLevelQualified {
+		t.Fatalf("expected level qualified, got %s", supplier.Level)
+	}
+
+	// the same period cannot run twice
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/evaluations/run",
+		map[string]interface{}{"period": "2026-03", "eval_type": "monthly"}, token)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a repeated run, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -183,3 +183,73 @@ func (h *SettlementHandler) UpdateDispute(c *gin.Context) {
 
 	Success(c, dispute)
 }
+
+// AddInvoiceLines 录入发票行并执行三单匹配
+func (h *SettlementHandler) AddInvoiceLines(c *gin.Context) {
+	id := c.Param("id")
+	var req service.AddInvoiceLinesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	settlement, err := h.svc.AddInvoiceLines(c.Request.Context(), GetUserID(c), id, &req)
+	if err != nil {
+		BadRequest(c, "录入发票失败: "+err.Error())
+		return
+	}
+	Success(c, settlement)
+}
+
+// MatchSettlement 执行三单匹配
+func (h *SettlementHandler) MatchSettlement(c *gin.Context) {
+	id := c.Param("id")
+	settlement, err := h.svc.Match(c.Request.Context(), id)
+	if err != nil {
+		BadRequest(c, "三单匹配失败: "+err.Error())
+		return
+	}
+	Success(c, settlement)
+}
+
+// WaiveLine 豁免对账行
+func (h *SettlementHandler) WaiveLine(c *gin.Context) {
+	var req service.WaiveLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	line, err := h.svc.WaiveLine(c.Request.Context(), GetUserID(c), c.Param("id"), c.Param("lineId"), &req)
+	if err != nil {
+		BadRequest(c, "豁免失败: "+err.Error())
+		return
+	}
+	Success(c, line)
+}
+
+// ListMatchTolerances 匹配容差列表
+func (h *SettlementHandler) ListMatchTolerances(c *gin.Context) {
+	items, err := h.svc.ListTolerances(c.Request.Context())
+	if err != nil {
+		InternalError(c, "获取匹配容差失败: "+err.Error())
+		return
+	}
+	Success(c, items)
+}
+
+// SaveMatchTolerance 保存匹配容差
+func (h *SettlementHandler) SaveMatchTolerance(c *gin.Context) {
+	var req service.SaveToleranceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	tol, err := h.svc.SaveTolerance(c.Request.Context(), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "保存匹配容差失败: "+err.Error())
+		return
+	}
+	Success(c, tol)
+}
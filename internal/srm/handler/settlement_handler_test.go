@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	erpentity "github.com/bitfantasy/nimo/internal/erp/entity"
+	"github.com/bitfantasy/nimo/internal/plm/testutil"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/bitfantasy/nimo/internal/srm/service"
+)
+
+func setupSettlementTest(t *testing.T) *testutil.TestEnv {
+	t.Helper()
+	db := testutil.SetupTestDB(t)
+
+	if err := db.AutoMigrate(
+		&entity.Supplier{},
+		&entity.PurchaseOrder{},
+		&entity.POItem{},
+		&entity.InventoryRecord{},
+		&entity.InventoryTransaction{},
+		&entity.Settlement{},
+		&entity.SettlementDispute{},
+		&entity.SettlementLine{},
+		&entity.SettlementInvoiceLine{},
+		&entity.MatchTolerance{},
+		&erpentity.InventoryTransaction{},
+	); err != nil {
+		t.Fatalf("Failed to migrate tables: %v", err)
+	}
+
+	handler := NewSettlementHandler(service.NewSettlementService(repository.NewSettlementRepository(db)))
+
+	router := testutil.SetupRouter()
+	api := testutil.AuthGroup(router, "/api/v1/srm")
+	api.POST("/settlements/generate", handler.GenerateSettlement)
+	api.PUT("/settlements/match-tolerances", handler.SaveMatchTolerance)
+	api.POST("/settlements/:id/invoice-lines", handler.AddInvoiceLines)
+	api.POST("/settlements/:id/confirm-buyer", handler.ConfirmByBuyer)
+	api.POST("/settlements/:id/confirm-supplier", handler.ConfirmBySupplier)
+	api.PUT("/settlements/:id/disputes/:disputeId", handler.UpdateDispute)
+	api.POST("/settlements/:id/lines/:lineId/waive", handler.WaiveLine)
+
+	return &testutil.TestEnv{DB: db, Router: router, T: t}
+}
+
+// TestSettlementThreeWayMatch verifies line matching against receipts, auto disputes and the buyer confirmation gate.
+func TestSettlementThreeWayMatch(t *testing.T) {
+	env := setupSettlementTest(t)
+	token := testutil.DefaultTestToken()
+
+	supplierID := "sup-s-001"
+	env.DB.Create(&entity.Supplier{ID: supplierID, Code: "SUP-S001", Name: "对账供应商", Category: "structural", Status: "active"})
+
+	price := 2.0
+	env.DB.Create(&entity.PurchaseOrder{
+		ID: "po-s-001", POCode: "PO-T-0001", SupplierID: supplierID, Type: "production", Status: "received",
+		Items: []entity.POItem{
+			{ID: "poi-s-001", POID: "po-s-001", MaterialCode: "M-001", MaterialName: "螺钉", Quantity: 100, UnitPrice: &price},
+			{ID: "poi-s-002", POID: "po-s-001", MaterialCode: "M-002", MaterialName: "垫片", Quantity: 50, UnitPrice: &price},
+		},
+	})
+	poItem1, poItem2 := "poi-s-001", "poi-s-002"
+	env.DB.Create(&entity.InventoryRecord{ID: "inv-s-001", MaterialName: "螺钉", MaterialCode: "M-001"})
+	env.DB.Create(&entity.InventoryTransaction{ID: "tx-s-001", InventoryID: "inv-s-001", Type: entity.InventoryTxTypeIn, Quantity: 100, POItemID: &poItem1})
+	env.DB.Create(&entity.InventoryTransaction{ID: "tx-s-002", InventoryID: "inv-s-001", Type: entity.InventoryTxTypeIn, Quantity: 40, POItemID: &poItem2})
+
+	w := testutil.DoRequest(env.Router, http.MethodPut, "/api/v1/srm/settlements/match-tolerances",
+		map[string]interface{}{"price_tolerance_pct": 1}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/generate", map[string]interface{}{
+		"supplier_id": supplierID, "period_start": "2000-01-01", "period_end": "2100-01-01",
+	}, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	settlementID := data["id"].(string)
+	if data["received_amount"].(float64) != 280 {
+		t.Fatalf("expected received amount 280, got %v", data["received_amount"])
+	}
+
+	// line 1 within 1% price tolerance, line 2 invoiced 50 but only 40 received
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/"+settlementID+"/invoice-lines", map[string]interface{}{
+		"invoice_no": "INV-001",
+		"lines": []map[string]interface{}{
+			{"po_item_id": poItem1, "quantity": 100, "unit_price": 2.01},
+			{"po_item_id": poItem2, "quantity": 50, "unit_price": 2},
+		},
+	}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data = testutil.ParseResponse(w)["data"].(map[string]interface{})
+
+	var mismatchLineID string
+	for _, l := range data["lines"].([]interface{}) {
+		line := l.(map[string]interface{})
+		switch line["po_item_id"] {
+		case poItem1:
+			if line["match_status"] != entity.MatchStatusMatched {
+				t.Fatalf("expected line 1 matched, got %v", line["match_status"])
+			}
+		case poItem2:
+			if line["match_status"] != entity.MatchStatusMismatch {
+				t.Fatalf("expected line 2 mismatch, got %v", line["match_status"])
+			}
+			mismatchLineID = line["id"].(string)
+		}
+	}
+	disputes := data["disputes"].([]interface{})
+	if len(disputes) != 1 {
+		t.Fatalf("expected 1 auto dispute, got %d", len(disputes))
+	}
+	dispute := disputes[0].(map[string]interface{})
+	if dispute["dispute_type"] != entity.DisputeTypeQuantityDiff || dispute["amount_diff"].(float64) != 20 {
+		t.Fatalf("expected quantity_diff of 20, got %v", dispute)
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/"+settlementID+"/confirm-buyer", nil, token)
+	if w.Code == http.StatusOK {
+		t.Fatalf("expected confirm to be blocked while a line mismatches")
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/"+settlementID+"/lines/"+mismatchLineID+"/waive",
+		map[string]interface{}{"reason": "补货随下批入库"}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/"+settlementID+"/confirm-buyer", nil, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected confirm after waive, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// 采购方确认后补录发票使行1超出价格容差，供应商确认须重新校验
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/"+settlementID+"/invoice-lines", map[string]interface{}{
+		"invoice_no": "INV-001",
+		"lines": []map[string]interface{}{
+			{"po_item_id": poItem1, "quantity": 100, "unit_price": 2.5},
+			{"po_item_id": poItem2, "quantity": 50, "unit_price": 2},
+		},
+	}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var invoiceLines int64
+	env.DB.Model(&entity.SettlementInvoiceLine{}).Where("settlement_id = ?", settlementID).Count(&invoiceLines)
+	if invoiceLines != 2 {
+		t.Fatalf("expected re-entered invoice to replace its lines, got %d", invoiceLines)
+	}
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/"+settlementID+"/confirm-supplier", nil, token)
+	if w.Code == http.StatusOK {
+		t.Fatalf("expected supplier confirm to be blocked after the rematch found a price mismatch")
+	}
+}
+
+// TestSettlementOffsettingVariances verifies that quantity and price variances are checked separately even when
+// the line amount agrees, that receipts outside the period are ignored, and that resolving disputes rematches the line.
+func TestSettlementOffsettingVariances(t *testing.T) {
+	env := setupSettlementTest(t)
+	token := testutil.DefaultTestToken()
+
+	supplierID := "sup-s-002"
+	env.DB.Create(&entity.Supplier{ID: supplierID, Code: "SUP-S002", Name: "抵消供应商", Category: "structural", Status: "active"})
+
+	price := 2.0
+	env.DB.Create(&entity.PurchaseOrder{
+		ID: "po-s-101", POCode: "PO-T-0101", SupplierID: supplierID, Type: "production", Status: "received",
+		Items: []entity.POItem{
+			{ID: "poi-s-101", POID: "po-s-101", MaterialCode: "M-101", MaterialName: "支架", Quantity: 100, UnitPrice: &price},
+			{ID: "poi-s-102", POID: "po-s-101", MaterialCode: "M-102", MaterialName: "卡扣", Quantity: 20, UnitPrice: &price},
+		},
+	})
+	poItem, unreceived := "poi-s-101", "poi-s-102"
+	env.DB.Create(&entity.InventoryRecord{ID: "inv-s-101", MaterialName: "支架", MaterialCode: "M-101"})
+	env.DB.Create(&entity.InventoryTransaction{ID: "tx-s-101", InventoryID: "inv-s-101", Type: entity.InventoryTxTypeIn, Quantity: 100, POItemID: &poItem})
+	env.DB.Create(&entity.InventoryTransaction{ID: "tx-s-102", InventoryID: "inv-s-101", Type: entity.InventoryTxTypeIn, Quantity: 30, POItemID: &poItem,
+		CreatedAt: time.Date(1999, 6, 1, 0, 0, 0, 0, time.UTC)})
+
+	w := testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/generate", map[string]interface{}{
+		"supplier_id": supplierID, "period_start": "2000-01-01", "period_end": "2100-01-01",
+	}, token)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.ParseResponse(w)["data"].(map[string]interface{})
+	settlementID := data["id"].(string)
+	if data["received_amount"].(float64) != 200 {
+		t.Fatalf("expected receipts before the period to be excluded (200), got %v", data["received_amount"])
+	}
+
+	// 110 × 1.8182 = 200.00: the amount agrees but both quantity and price are off
+	w = testutil.DoRequest(env.Router, http.MethodPost, "/api/v1/srm/settlements/"+settlementID+"/invoice-lines", map[string]interface{}{
+		"invoice_no": "INV-101",
+		"lines":      []map[string]interface{}{{"po_item_id": poItem, "quantity": 110, "unit_price": 1.8182}},
+	}, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data = testutil.ParseResponse(w)["data"].(map[string]interface{})
+
+	for _, l := range data["lines"].([]interface{}) {
+		line := l.(map[string]interface{})
+		switch line["po_item_id"] {
+		case poItem:
+			if line["match_status"] != entity.MatchStatusMismatch {
+				t.Fatalf("expected offsetting line to mismatch, got %v", line["match_status"])
+			}
+		case unreceived:
+			if line["match_status"] != entity.MatchStatusPending {
+				t.Fatalf("expected line without receipts or invoice to stay pending, got %v", line["match_status"])
+			}
+		}
+	}
+	disputes := data["disputes"].([]interface{})
+	if len(disputes) != 2 {
+		t.Fatalf("expected quantity and price disputes, got %d", len(disputes))
+	}
+
+	for i, d := range disputes {
+		disputeID := d.(map[string]interface{})["id"].(string)
+		w = testutil.DoRequest(env.Router, http.MethodPut, "/api/v1/srm/settlements/"+settlementID+"/disputes/"+disputeID,
+			map[string]interface{}{"status": entity.DisputeStatusResolved, "resolution": "供应商补开红字发票"}, token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var line entity.SettlementLine
+		env.DB.Where("settlement_id = ? AND po_item_id = ?", settlementID, poItem).First(&line)
+		want := entity.MatchStatusMismatch
+		if i == len(disputes)-1 {
+			want = entity.MatchStatusMatched
+		}
+		if line.MatchStatus != want {
+			t.Fatalf("after resolving %d dispute(s) expected %s, got %s", i+1, want, line.MatchStatus)
+		}
+	}
+}
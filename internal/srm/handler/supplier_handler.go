@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/bitfantasy/nimo/internal/srm/service"
 	"github.com/gin-gonic/gin"
 )
@@ -91,6 +93,10 @@ func (h *SupplierHandler) UpdateSupplier(c *gin.Context) {
 
 	supplier, err := h.svc.Update(c.Request.Context(), id, &req)
 	if err != nil {
+		if errors.Is(err, service.ErrSupplierLifecycleField) {
+			BadRequest(c, err.Error())
+			return
+		}
 		InternalError(c, "更新供应商失败: "+err.Error())
 		return
 	}
@@ -98,6 +104,24 @@ func (h *SupplierHandler) UpdateSupplier(c *gin.Context) {
 	Success(c, supplier)
 }
 
+// ChangeStatus 供应商状态变更（暂停/恢复/拉黑）
+// POST /api/v1/srm/suppliers/:id/status
+func (h *SupplierHandler) ChangeStatus(c *gin.Context) {
+	var req service.ChangeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	supplier, err := h.svc.ChangeStatus(c.Request.Context(), c.Param("id"), GetUserID(c), &req)
+	if err != nil {
+		BadRequest(c, "变更供应商状态失败: "+err.Error())
+		return
+	}
+
+	Success(c, supplier)
+}
+
 // DeleteSupplier 删除供应商
 // DELETE /api/v1/srm/suppliers/:id
 func (h *SupplierHandler) DeleteSupplier(c *gin.Context) {
@@ -1,6 +1,12 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"gorm.io/gorm"
 )
 
@@ -12,3 +18,89 @@ type CorrectiveActionRepository struct {
 func NewCorrectiveActionRepository(db *gorm.DB) *CorrectiveActionRepository {
 	return &CorrectiveActionRepository{db: db}
 }
+
+// FindAll 查询8D列表
+func (r *CorrectiveActionRepository) FindAll(ctx context.Context, page, pageSize int, filters map[string]string) ([]entity.CorrectiveAction, int64, error) {
+	var items []entity.CorrectiveAction
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&entity.CorrectiveAction{})
+
+	if supplierID := filters["supplier_id"]; supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	if status := filters["status"]; status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if inspectionID := filters["inspection_id"]; inspectionID != "" {
+		query = query.Where("inspection_id = ?", inspectionID)
+	}
+	if defectCodeID := filters["defect_code_id"]; defectCodeID != "" {
+		query = query.Where("defect_code_id = ?", defectCodeID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.
+		Preload("Supplier").
+		Preload("DefectCode").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&items).Error
+
+	return items, total, err
+}
+
+// FindByID 根据ID查找8D
+func (r *CorrectiveActionRepository) FindByID(ctx context.Context, id string) (*entity.CorrectiveAction, error) {
+	var ca entity.CorrectiveAction
+	err := r.db.WithContext(ctx).
+		Preload("Supplier").
+		Preload("DefectCode").
+		Where("id = ?", id).
+		First(&ca).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &ca, nil
+}
+
+// Create 创建8D
+func (r *CorrectiveActionRepository) Create(ctx context.Context, ca *entity.CorrectiveAction) error {
+	return r.db.WithContext(ctx).Create(ca).Error
+}
+
+// Update 更新8D
+func (r *CorrectiveActionRepository) Update(ctx context.Context, ca *entity.CorrectiveAction) error {
+	return r.db.WithContext(ctx).Omit("Supplier", "DefectCode").Save(ca).Error
+}
+
+// GenerateCode 生成8D编码 8D-{year}-{4位}
+func (r *CorrectiveActionRepository) GenerateCode(ctx context.Context) (string, error) {
+	year := time.Now().Format("2006")
+	prefix := fmt.Sprintf("8D-%s-", year)
+
+	var maxCode string
+	err := r.db.WithContext(ctx).
+		Model(&entity.CorrectiveAction{}).
+		Select("COALESCE(MAX(ca_code), '')").
+		Where("ca_code LIKE ?", prefix+"%").
+		Scan(&maxCode).Error
+	if err != nil {
+		return "", err
+	}
+
+	var seq int
+	if maxCode != "" {
+		fmt.Sscanf(maxCode, "8D-"+year+"-%04d", &seq)
+	}
+	seq++
+	return fmt.Sprintf("8D-%s-%04d", year, seq), nil
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"gorm.io/gorm"
+)
+
+// DefectRepository 缺陷代码仓库
+type DefectRepository struct {
+	db *gorm.DB
+}
+
+func NewDefectRepository(db *gorm.DB) *DefectRepository {
+	return &DefectRepository{db: db}
+}
+
+// FindAll 查询缺陷代码（不分页，代码表规模有限）
+func (r *DefectRepository) FindAll(ctx context.Context, filters map[string]string) ([]entity.DefectCode, error) {
+	var items []entity.DefectCode
+	query := r.db.WithContext(ctx).Model(&entity.DefectCode{})
+
+	if level := filters["level"]; level != "" {
+		query = query.Where("level = ?", level)
+	}
+	if parentID := filters["parent_id"]; parentID != "" {
+		query = query.Where("parent_id = ?", parentID)
+	}
+	if status := filters["status"]; status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if search := filters["search"]; search != "" {
+		query = query.Where("code ILIKE ? OR name ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	err := query.Order("sort_order ASC, code ASC").Find(&items).Error
+	return items, err
+}
+
+// FindByID 根据ID查找缺陷代码
+func (r *DefectRepository) FindByID(ctx context.Context, id string) (*entity.DefectCode, error) {
+	var code entity.DefectCode
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&code).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+// Create 创建缺陷代码
+func (r *DefectRepository) Create(ctx context.Context, code *entity.DefectCode) error {
+	return r.db.WithContext(ctx).Create(code).Error
+}
+
+// Update 更新缺陷代码
+func (r *DefectRepository) Update(ctx context.Context, code *entity.DefectCode) error {
+	return r.db.WithContext(ctx).Save(code).Error
+}
+
+// Delete 删除缺陷代码
+func (r *DefectRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&entity.DefectCode{}).Error
+}
+
+// CountChildren 统计子代码数量
+func (r *DefectRepository) CountChildren(ctx context.Context, id string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&entity.DefectCode{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CountUsage 统计缺陷代码被检验行项和8D引用的次数
+func (r *DefectRepository) CountUsage(ctx context.Context, id string) (int64, error) {
+	var items, cas int64
+	if err := r.db.WithContext(ctx).Model(&entity.InspectionItem{}).Where("defect_code_id = ?", id).Count(&items).Error; err != nil {
+		return 0, err
+	}
+	if err := r.db.WithContext(ctx).Model(&entity.CorrectiveAction{}).Where("defect_code_id = ?", id).Count(&cas).Error; err != nil {
+		return 0, err
+	}
+	return items + cas, nil
+}
+
+// QualityFilter 质量分析过滤条件
+type QualityFilter struct {
+	SupplierID   string
+	MaterialCode string
+	Start        *time.Time
+	End          *time.Time
+}
+
+// DefectAggregate 按缺陷代码/供应商/物料汇总的不良数量
+type DefectAggregate struct {
+	DefectCodeID *string `json:"defect_code_id"`
+	SupplierID   string  `json:"supplier_id"`
+	MaterialCode string  `json:"material_code"`
+	DefectQty    float64 `json:"defect_qty"`
+	Occurrences  int64   `json:"occurrences"`
+}
+
+// SupplierQualityStat 供应商检验数量统计
+type SupplierQualityStat struct {
+	SupplierID   string  `json:"supplier_id"`
+	SupplierName string  `json:"supplier_name"`
+	InspectedQty float64 `json:"inspected_qty"`
+	DefectQty    float64 `json:"defect_qty"`
+	Inspections  int64   `json:"inspections"`
+	Passed       int64   `json:"passed"`
+}
+
+// QualityTrendPoint 质量趋势点
+type QualityTrendPoint struct {
+	Period       string  `json:"period"`
+	InspectedQty float64 `json:"inspected_qty"`
+	DefectQty    float64 `json:"defect_qty"`
+}
+
+// applyQualityFilter 在 srm_inspection_items i JOIN srm_inspections ins 上应用过滤
+func applyQualityFilter(query *gorm.DB, f QualityFilter) *gorm.DB {
+	query = query.Where("ins.status = ?", entity.InspectionStatusCompleted)
+	if f.SupplierID != "" {
+		query = query.Where("ins.supplier_id = ?", f.SupplierID)
+	}
+	if f.MaterialCode != "" {
+		query = query.Where("i.material_code = ?", f.MaterialCode)
+	}
+	if f.Start != nil {
+		query = query.Where("ins.inspected_at >= ?", *f.Start)
+	}
+	if f.End != nil {
+		query = query.Where("ins.inspected_at < ?", *f.End)
+	}
+	return query
+}
+
+// AggregateDefects 按缺陷代码、供应商、物料汇总不良数
+func (r *DefectRepository) AggregateDefects(ctx context.Context, f QualityFilter) ([]DefectAggregate, error) {
+	var rows []DefectAggregate
+	query := r.db.WithContext(ctx).
+		Table("srm_inspection_items i").
+		Joins("JOIN srm_inspections ins ON ins.id = i.inspection_id").
+		Where("i.defect_qty > 0")
+	query = applyQualityFilter(query, f)
+	err := query.
+		Select(`i.defect_code_id, COALESCE(ins.supplier_id, '') as supplier_id, i.material_code,
+			SUM(i.defect_qty) as defect_qty, COUNT(*) as occurrences`).
+		Group("i.defect_code_id, ins.supplier_id, i.material_code").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// SupplierQualityStats 按供应商统计检验数量与不良数
+func (r *DefectRepository) SupplierQualityStats(ctx context.Context, f QualityFilter) ([]SupplierQualityStat, error) {
+	var rows []SupplierQualityStat
+	query := r.db.WithContext(ctx).
+		Table("srm_inspection_items i").
+		Joins("JOIN srm_inspections ins ON ins.id = i.inspection_id").
+		Joins("LEFT JOIN srm_suppliers s ON s.id = ins.supplier_id").
+		Where("ins.supplier_id IS NOT NULL")
+	query = applyQualityFilter(query, f)
+	err := query.
+		Select(`ins.supplier_id, COALESCE(MAX(s.name), '') as supplier_name,
+			COALESCE(SUM(i.inspected_qty), 0) as inspected_qty,
+			COALESCE(SUM(i.defect_qty), 0) as defect_qty,
+			COUNT(DISTINCT ins.id) as inspections,
+			COUNT(DISTINCT CASE WHEN ins.result = 'passed' THEN ins.id END) as passed`).
+		Group("ins.supplier_id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// QualityTrend 按月/周统计检验数量与不良数
+func (r *DefectRepository) QualityTrend(ctx context.Context, f QualityFilter, granularity string) ([]QualityTrendPoint, error) {
+	bucket := "to_char(ins.inspected_at, 'YYYY-MM')"
+	if granularity == "week" {
+		bucket = "to_char(ins.inspected_at, 'IYYY-\"W\"IW')"
+	}
+
+	var rows []QualityTrendPoint
+	query := r.db.WithContext(ctx).
+		Table("srm_inspection_items i").
+		Joins("JOIN srm_inspections ins ON ins.id = i.inspection_id").
+		Where("ins.inspected_at IS NOT NULL")
+	query = applyQualityFilter(query, f)
+	err := query.
+		Select(bucket + ` as period,
+			COALESCE(SUM(i.inspected_qty), 0) as inspected_qty,
+			COALESCE(SUM(i.defect_qty), 0) as defect_qty`).
+		Group("period").
+		Order("period ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// CADefectCount 按缺陷代码统计的8D数量
+type CADefectCount struct {
+	DefectCodeID *string `json:"defect_code_id"`
+	SupplierID   string  `json:"supplier_id"`
+	Count        int64   `json:"count"`
+}
+
+// CountCorrectiveActions 按缺陷代码、供应商统计8D数量
+func (r *DefectRepository) CountCorrectiveActions(ctx context.Context, f QualityFilter) ([]CADefectCount, error) {
+	var rows []CADefectCount
+	query := r.db.WithContext(ctx).Model(&entity.CorrectiveAction{})
+	if f.SupplierID != "" {
+		query = query.Where("supplier_id = ?", f.SupplierID)
+	}
+	if f.Start != nil {
+		query = query.Where("created_at >= ?", *f.Start)
+	}
+	if f.End != nil {
+		query = query.Where("created_at < ?", *f.End)
+	}
+	err := query.
+		Select("defect_code_id, supplier_id, COUNT(*) as count").
+		Group("defect_code_id, supplier_id").
+		Scan(&rows).Error
+	return rows, err
+}
@@ -57,6 +57,9 @@ func (r *EvaluationRepository) FindByID(ctx context.Context, id string) (*entity
 	var eval entity.SupplierEvaluation
 	err := r.db.WithContext(ctx).
 		Preload("Supplier").
+		Preload("KPIResults", func(db *gorm.DB) *gorm.DB {
+			return db.Order("dimension ASC, code ASC")
+		}).
 		Where("id = ?", id).
 		First(&eval).Error
 	if err != nil {
@@ -73,9 +76,58 @@ func (r *EvaluationRepository) Create(ctx context.Context, eval *entity.Supplier
 	return r.db.WithContext(ctx).Create(eval).Error
 }
 
+// CreateWithResults 创建评估及KPI明细
+func (r *EvaluationRepository) CreateWithResults(ctx context.Context, eval *entity.SupplierEvaluation, results []entity.EvaluationKPIResult) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Supplier", "KPIResults").Create(eval).Error; err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			return nil
+		}
+		return tx.Create(&results).Error
+	})
+}
+
 // Update 更新评估
 func (r *EvaluationRepository) Update(ctx context.Context, eval *entity.SupplierEvaluation) error {
-	return r.db.WithContext(ctx).Save(eval).Error
+	return r.db.WithContext(ctx).Omit("Supplier", "KPIResults").Save(eval).Error
+}
+
+// UpdateKPIResult 更新KPI明细（人工评分）
+func (r *EvaluationRepository) UpdateKPIResult(ctx context.Context, result *entity.EvaluationKPIResult) error {
+	return r.db.WithContext(ctx).Save(result).Error
+}
+
+// FindByPeriod 查找供应商某周期某类型的评估
+func (r *EvaluationRepository) FindByPeriod(ctx context.Context, supplierID, period, evalType string) (*entity.SupplierEvaluation, error) {
+	var eval entity.SupplierEvaluation
+	err := r.db.WithContext(ctx).
+		Where("supplier_id = ? AND period = ? AND eval_type = ?", supplierID, period, evalType).
+		First(&eval).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &eval, nil
+}
+
+// FindLatestGrade 供应商最近一次已出等级的评估等级（不含指定评估）
+func (r *EvaluationRepository) FindLatestGrade(ctx context.Context, supplierID, excludeID string) (string, error) {
+	var eval entity.SupplierEvaluation
+	err := r.db.WithContext(ctx).
+		Where("supplier_id = ? AND id <> ? AND grade <> ''", supplierID, excludeID).
+		Order("created_at DESC").
+		First(&eval).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return eval.Grade, nil
 }
 
 // FindBySupplier 查询某供应商的评估历史
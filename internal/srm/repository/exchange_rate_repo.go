@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExchangeRateRepository 汇率仓库
+type ExchangeRateRepository struct {
+	db *gorm.DB
+}
+
+func NewExchangeRateRepository(db *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db}
+}
+
+// FindAll 查询汇率列表
+func (r *ExchangeRateRepository) FindAll(ctx context.Context, page, pageSize int, filters map[string]string) ([]entity.ExchangeRate, int64, error) {
+	var items []entity.ExchangeRate
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&entity.ExchangeRate{})
+	if from := filters["from_currency"]; from != "" {
+		query = query.Where("from_currency = ?", from)
+	}
+	if to := filters["to_currency"]; to != "" {
+		query = query.Where("to_currency = ?", to)
+	}
+
+	query.Count(&total)
+	offset := (page - 1) * pageSize
+	err := query.Order("effective_date DESC, from_currency ASC").Offset(offset).Limit(pageSize).Find(&items).Error
+	return items, total, err
+}
+
+// FindByID 根据ID查找汇率
+func (r *ExchangeRateRepository) FindByID(ctx context.Context, id string) (*entity.ExchangeRate, error) {
+	var rate entity.ExchangeRate
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&rate).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// FindEffective 查找指定日期有效的汇率（生效日不晚于该日期的最新一条）
+func (r *ExchangeRateRepository) FindEffective(ctx context.Context, from, to string, date time.Time) (*entity.ExchangeRate, error) {
+	var rate entity.ExchangeRate
+	err := r.db.WithContext(ctx).
+		Where("from_currency = ? AND to_currency = ? AND effective_date <= ?", from, to, date).
+		Order("effective_date DESC").
+		First(&rate).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// Upsert 按币种对+生效日新增或覆盖汇率
+func (r *ExchangeRateRepository) Upsert(ctx context.Context, rates []entity.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "from_currency"}, {Name: "to_currency"}, {Name: "effective_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "created_by", "updated_at"}),
+	}).Create(&rates).Error
+}
+
+// Delete 删除汇率
+func (r *ExchangeRateRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&entity.ExchangeRate{}).Error
+}
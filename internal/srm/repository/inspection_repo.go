@@ -67,6 +67,7 @@ func (r *InspectionRepository) FindByID(ctx context.Context, id string) (*entity
 		Preload("Items", func(db *gorm.DB) *gorm.DB {
 			return db.Order("sort_order ASC")
 		}).
+		Preload("Items.DefectCode").
 		Where("id = ?", id).
 		First(&inspection).Error
 	if err != nil {
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"gorm.io/gorm"
+)
+
+// QualificationRepository 供应商准入与资质证书仓库
+type QualificationRepository struct {
+	db *gorm.DB
+}
+
+func NewQualificationRepository(db *gorm.DB) *QualificationRepository {
+	return &QualificationRepository{db: db}
+}
+
+// FindBySupplier 查询供应商的准入审核单（最新在前）
+func (r *QualificationRepository) FindBySupplier(ctx context.Context, supplierID string) ([]entity.SupplierQualification, error) {
+	var items []entity.SupplierQualification
+	err := r.db.WithContext(ctx).
+		Where("supplier_id = ?", supplierID).
+		Order("created_at DESC").
+		Find(&items).Error
+	return items, err
+}
+
+// FindByID 根据ID查找准入审核单
+func (r *QualificationRepository) FindByID(ctx context.Context, id string) (*entity.SupplierQualification, error) {
+	var q entity.SupplierQualification
+	err := r.db.WithContext(ctx).
+		Preload("Supplier").
+		Where("id = ?", id).
+		First(&q).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &q, nil
+}
+
+// FindInProgress 查找供应商进行中的准入审核单
+func (r *QualificationRepository) FindInProgress(ctx context.Context, supplierID string) (*entity.SupplierQualification, error) {
+	var q entity.SupplierQualification
+	err := r.db.WithContext(ctx).
+		Where("supplier_id = ? AND status = ?", supplierID, entity.QualificationStatusInProgress).
+		First(&q).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &q, nil
+}
+
+// FindBySamplingRequest 查找关联指定打样记录且处于样品承认步骤的审核单
+func (r *QualificationRepository) FindBySamplingRequest(ctx context.Context, samplingRequestID string) ([]entity.SupplierQualification, error) {
+	var items []entity.SupplierQualification
+	err := r.db.WithContext(ctx).
+		Where("sampling_request_id = ? AND status = ? AND current_step = ?",
+			samplingRequestID, entity.QualificationStatusInProgress, entity.QualificationStepSample).
+		Find(&items).Error
+	return items, err
+}
+
+// Create 创建准入审核单
+func (r *QualificationRepository) Create(ctx context.Context, q *entity.SupplierQualification) error {
+	return r.db.WithContext(ctx).Create(q).Error
+}
+
+// Update 更新准入审核单
+func (r *QualificationRepository) Update(ctx context.Context, q *entity.SupplierQualification) error {
+	return r.db.WithContext(ctx).Omit("Supplier").Save(q).Error
+}
+
+// GenerateCode 生成准入审核单编码 QUA-{YYYY}-{4位}
+func (r *QualificationRepository) GenerateCode(ctx context.Context) (string, error) {
+	year := time.Now().Format("2006")
+	prefix := fmt.Sprintf("QUA-%s-", year)
+
+	var maxCode string
+	err := r.db.WithContext(ctx).
+		Model(&entity.SupplierQualification{}).
+		Where("code LIKE ?", prefix+"%").
+		Select("COALESCE(MAX(code), '')").
+		Scan(&maxCode).Error
+	if err != nil {
+		return "", err
+	}
+
+	seq := 1
+	if maxCode != "" {
+		var n int
+		fmt.Sscanf(maxCode[len(prefix):], "%d", &n)
+		seq = n + 1
+	}
+	return fmt.Sprintf("%s%04d", prefix, seq), nil
+}
+
+// FindCertificates 查询供应商资质证书
+func (r *QualificationRepository) FindCertificates(ctx context.Context, supplierID string) ([]entity.SupplierCertificate, error) {
+	var items []entity.SupplierCertificate
+	err := r.db.WithContext(ctx).
+		Where("supplier_id = ?", supplierID).
+		Order("cert_type ASC, expires_at DESC").
+		Find(&items).Error
+	return items, err
+}
+
+// FindCertificateByID 根据ID查找证书
+func (r *QualificationRepository) FindCertificateByID(ctx context.Context, id string) (*entity.SupplierCertificate, error) {
+	var cert entity.SupplierCertificate
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&cert).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// CreateCertificate 新增证书，同类型的旧证书标记为已替代
+func (r *QualificationRepository) CreateCertificate(ctx context.Context, cert *entity.SupplierCertificate) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.SupplierCertificate{}).
+			Where("supplier_id = ? AND cert_type = ? AND status <> ?", cert.SupplierID, cert.CertType, entity.CertStatusSuperseded).
+			Update("status", entity.CertStatusSuperseded).Error; err != nil {
+			return err
+		}
+		return tx.Create(cert).Error
+	})
+}
+
+// UpdateCertificate 更新证书
+func (r *QualificationRepository) UpdateCertificate(ctx context.Context, cert *entity.SupplierCertificate) error {
+	return r.db.WithContext(ctx).Save(cert).Error
+}
+
+// DeleteCertificate 删除证书
+func (r *QualificationRepository) DeleteCertificate(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&entity.SupplierCertificate{}).Error
+}
+
+// FindCertificatesExpiringBefore 查询在指定时间前到期、仍在用的证书（不含已替代）
+func (r *QualificationRepository) FindCertificatesExpiringBefore(ctx context.Context, before time.Time) ([]entity.SupplierCertificate, error) {
+	var items []entity.SupplierCertificate
+	err := r.db.WithContext(ctx).
+		Where("expires_at < ? AND status IN ?", before,
+			[]string{entity.CertStatusValid, entity.CertStatusExpiring, entity.CertStatusExpired}).
+		Order("expires_at ASC").
+		Find(&items).Error
+	return items, err
+}
+
+// CountExpiredCertificates 统计供应商已过期且未替代的证书数量
+func (r *QualificationRepository) CountExpiredCertificates(ctx context.Context, supplierID string, now time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&entity.SupplierCertificate{}).
+		Where("supplier_id = ? AND status <> ? AND expires_at < ?", supplierID, entity.CertStatusSuperseded, now).
+		Count(&count).Error
+	return count, err
+}
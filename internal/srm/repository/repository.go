@@ -26,6 +26,10 @@ type Repositories struct {
 	Equipment        *EquipmentRepository
 	RFQ              *RFQRepository
 	Sampling         *SamplingRepository
+	Defect           *DefectRepository
+	ExchangeRate     *ExchangeRateRepository
+	Scorecard        *ScorecardRepository
+	Qualification    *QualificationRepository
 }
 
 // NewRepositories 创建SRM仓库集合
@@ -45,5 +49,9 @@ func NewRepositories(db *gorm.DB) *Repositories {
 		Equipment:        NewEquipmentRepository(db),
 		RFQ:              NewRFQRepository(db),
 		Sampling:         NewSamplingRepository(db),
+		Defect:           NewDefectRepository(db),
+		ExchangeRate:     NewExchangeRateRepository(db),
+		Scorecard:        NewScorecardRepository(db),
+		Qualification:    NewQualificationRepository(db),
 	}
 }
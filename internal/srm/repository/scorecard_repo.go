@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"gorm.io/gorm"
+)
+
+// ScorecardRepository 评分卡仓库
+type ScorecardRepository struct {
+	db *gorm.DB
+}
+
+func NewScorecardRepository(db *gorm.DB) *ScorecardRepository {
+	return &ScorecardRepository{db: db}
+}
+
+func preloadKPIs(db *gorm.DB) *gorm.DB {
+	return db.Order("sort_order ASC, code ASC")
+}
+
+// FindAll 查询评分卡列表
+func (r *ScorecardRepository) FindAll(ctx context.Context, filters map[string]string) ([]entity.Scorecard, error) {
+	var items []entity.Scorecard
+	query := r.db.WithContext(ctx).Model(&entity.Scorecard{}).Preload("KPIs", preloadKPIs)
+	if category, ok := filters["category"]; ok && category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if status := filters["status"]; status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("category ASC, created_at ASC").Find(&items).Error
+	return items, err
+}
+
+// FindByID 根据ID查找评分卡
+func (r *ScorecardRepository) FindByID(ctx context.Context, id string) (*entity.Scorecard, error) {
+	var sc entity.Scorecard
+	err := r.db.WithContext(ctx).Preload("KPIs", preloadKPIs).Where("id = ?", id).First(&sc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// FindForCategory 查找适用于供应商分类的启用评分卡：优先分类专属，其次默认；都没有返回nil
+func (r *ScorecardRepository) FindForCategory(ctx context.Context, category string) (*entity.Scorecard, error) {
+	var sc entity.Scorecard
+	err := r.db.WithContext(ctx).
+		Preload("KPIs", preloadKPIs).
+		Where("status = ? AND (category = ? OR category = '' OR category IS NULL)", entity.ScorecardStatusActive, category).
+		Order("CASE WHEN category = '' OR category IS NULL THEN 1 ELSE 0 END, updated_at DESC").
+		First(&sc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// CountActiveInCategory 统计某分类下其他启用的评分卡数量
+func (r *ScorecardRepository) CountActiveInCategory(ctx context.Context, category, excludeID string) (int64, error) {
+	var count int64
+	query := r.db.WithContext(ctx).Model(&entity.Scorecard{}).
+		Where("status = ? AND COALESCE(category, '') = ?", entity.ScorecardStatusActive, category)
+	if excludeID != "" {
+		query = query.Where("id <> ?", excludeID)
+	}
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// Create 创建评分卡及指标
+func (r *ScorecardRepository) Create(ctx context.Context, sc *entity.Scorecard) error {
+	return r.db.WithContext(ctx).Create(sc).Error
+}
+
+// Update 更新评分卡并整体替换指标
+func (r *ScorecardRepository) Update(ctx context.Context, sc *entity.Scorecard) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("KPIs").Save(sc).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("scorecard_id = ?", sc.ID).Delete(&entity.ScorecardKPI{}).Error; err != nil {
+			return err
+		}
+		if len(sc.KPIs) == 0 {
+			return nil
+		}
+		return tx.Create(&sc.KPIs).Error
+	})
+}
+
+// Delete 删除评分卡及指标
+func (r *ScorecardRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("scorecard_id = ?", id).Delete(&entity.ScorecardKPI{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&entity.Scorecard{}).Error
+	})
+}
+
+// ========== KPI数据源 ==========
+
+// OTDStats 周期内到货的PO数及准时数（按日期比较实际到货日与要求交期）
+func (r *ScorecardRepository) OTDStats(ctx context.Context, supplierID string, start, end time.Time) (total, onTime int64, err error) {
+	var row struct {
+		Total  int64
+		OnTime int64
+	}
+	err = r.db.WithContext(ctx).
+		Model(&entity.PurchaseOrder{}).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE actual_date::date <= expected_date::date) AS on_time").
+		Where("supplier_id = ? AND expected_date IS NOT NULL AND actual_date >= ? AND actual_date < ?", supplierID, start, end).
+		Where("status <> ?", entity.POStatusCancelled).
+		Scan(&row).Error
+	return row.Total, row.OnTime, err
+}
+
+// CAResponseHours 周期内发起的8D平均响应时长（小时），未响应的计至 asOf
+func (r *ScorecardRepository) CAResponseHours(ctx context.Context, supplierID string, start, end, asOf time.Time) (avg float64, count int64, err error) {
+	var row struct {
+		AvgHours float64
+		Cnt      int64
+	}
+	err = r.db.WithContext(ctx).
+		Model(&entity.CorrectiveAction{}).
+		Select("COALESCE(AVG(EXTRACT(EPOCH FROM (COALESCE(responded_at, ?) - created_at)) / 3600), 0) AS avg_hours, COUNT(*) AS cnt", asOf).
+		Where("supplier_id = ? AND created_at >= ? AND created_at < ?", supplierID, start, end).
+		Scan(&row).Error
+	return row.AvgHours, row.Cnt, err
+}
+
+// PriceVariance 周期内PO行单价相对询价中标价（PR行项定标单价）的平均偏差百分比，正数表示高于中标价
+func (r *ScorecardRepository) PriceVariance(ctx context.Context, supplierID string, start, end time.Time) (avg float64, count int64, err error) {
+	var row struct {
+		AvgPct float64
+		Cnt    int64
+	}
+	err = r.db.WithContext(ctx).
+		Table("srm_po_items AS poi").
+		Select("COALESCE(AVG((poi.unit_price - pri.unit_price) / pri.unit_price * 100), 0) AS avg_pct, COUNT(*) AS cnt").
+		Joins("JOIN srm_purchase_orders po ON po.id = poi.po_id").
+		Joins("JOIN srm_pr_items pri ON pri.id = poi.pr_item_id").
+		Where("po.supplier_id = ? AND po.created_at >= ? AND po.created_at < ?", supplierID, start, end).
+		Where("po.status <> ? AND poi.unit_price IS NOT NULL AND pri.unit_price > 0", entity.POStatusCancelled).
+		Scan(&row).Error
+	return row.AvgPct, row.Cnt, err
+}
+
+// ========== 运行记录 ==========
+
+// FindRun 查找周期运行记录
+func (r *ScorecardRepository) FindRun(ctx context.Context, period, evalType string) (*entity.ScorecardRun, error) {
+	var run entity.ScorecardRun
+	err := r.db.WithContext(ctx).Where("period = ? AND eval_type = ?", period, evalType).First(&run).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// CreateRun 创建运行记录（周期+类型唯一，并发重复触发时由唯一索引拦截）
+func (r *ScorecardRepository) CreateRun(ctx context.Context, run *entity.ScorecardRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+// UpdateRun 更新运行记录
+func (r *ScorecardRepository) UpdateRun(ctx context.Context, run *entity.ScorecardRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+// ListRuns 查询最近的运行记录
+func (r *ScorecardRepository) ListRuns(ctx context.Context, limit int) ([]entity.ScorecardRun, error) {
+	var items []entity.ScorecardRun
+	err := r.db.WithContext(ctx).Order("started_at DESC").Limit(limit).Find(&items).Error
+	return items, err
+}
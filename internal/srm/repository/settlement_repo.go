@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
+	erpentity "github.com/bitfantasy/nimo/internal/erp/entity"
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"gorm.io/gorm"
 )
@@ -61,6 +63,10 @@ func (r *SettlementRepository) FindByID(ctx context.Context, id string) (*entity
 	err := r.db.WithContext(ctx).
 		Preload("Supplier").
 		Preload("Disputes").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB {
+			return db.Order("po_code ASC, material_code ASC")
+		}).
+		Preload("InvoiceLines").
 		Where("id = ?", id).
 		First(&s).Error
 	if err != nil {
@@ -77,14 +83,26 @@ func (r *SettlementRepository) Create(ctx context.Context, s *entity.Settlement)
 	return r.db.WithContext(ctx).Create(s).Error
 }
 
-// Update 更新对账单
+// Update 更新对账单（行项、发票行、差异各自维护）
 func (r *SettlementRepository) Update(ctx context.Context, s *entity.Settlement) error {
-	return r.db.WithContext(ctx).Save(s).Error
+	return r.db.WithContext(ctx).Omit("Supplier", "Lines", "InvoiceLines", "Disputes").Save(s).Error
 }
 
-// Delete 删除对账单（仅草稿状态）
+// Delete 删除对账单（仅草稿状态，连同行项与发票行）
 func (r *SettlementRepository) Delete(ctx context.Context, id string) error {
-	return r.db.WithContext(ctx).Where("id = ? AND status = 'draft'", id).Delete(&entity.Settlement{}).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND status = 'draft'", id).Delete(&entity.Settlement{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Where("settlement_id = ?", id).Delete(&entity.SettlementLine{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("settlement_id = ?", id).Delete(&entity.SettlementInvoiceLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("settlement_id = ?", id).Delete(&entity.SettlementDispute{}).Error
+	})
 }
 
 // GenerateCode 生成对账单编码 STL-YYYYMM-XXXX
@@ -137,3 +155,202 @@ func (r *SettlementRepository) FindDisputeByID(ctx context.Context, id string) (
 	}
 	return &d, nil
 }
+
+// UpdateLine 更新对账行
+func (r *SettlementRepository) UpdateLine(ctx context.Context, line *entity.SettlementLine) error {
+	return r.db.WithContext(ctx).Save(line).Error
+}
+
+// FindLineByID 查找对账行
+func (r *SettlementRepository) FindLineByID(ctx context.Context, id string) (*entity.SettlementLine, error) {
+	var line entity.SettlementLine
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&line).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &line, nil
+}
+
+// ReplaceInvoice 在同一事务中用新发票行整体替换同一发票号的旧发票行，并保存对账单（发票附件等）
+func (r *SettlementRepository) ReplaceInvoice(ctx context.Context, s *entity.Settlement, invoiceNo string, lines []entity.SettlementInvoiceLine) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("settlement_id = ? AND invoice_no = ?", s.ID, invoiceNo).
+			Delete(&entity.SettlementInvoiceLine{}).Error; err != nil {
+			return err
+		}
+		if len(lines) > 0 {
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Supplier", "Lines", "InvoiceLines", "Disputes").Save(s).Error
+	})
+}
+
+// SumReceivedByPOItems 按PO行项汇总对账期间 [periodStart, periodEnd] 内的入库流水数量（质检入库时记录了po_item_id）。
+// 统一库存台账之前的入库在 srm_inventory_transactions，之后在 erp_inventory_transactions，两者合计。
+// 有流水但不在期间内的行项返回0；从未有流水的行项不在结果中，由调用方回退到PO行项已收数量。
+// periodEnd 为日期，包含当天。
+func (r *SettlementRepository) SumReceivedByPOItems(ctx context.Context, poItemIDs []string, periodStart, periodEnd *time.Time) (map[string]float64, error) {
+	result := make(map[string]float64)
+	if len(poItemIDs) == 0 {
+		return result, nil
+	}
+	from, to := time.Time{}, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	if periodStart != nil {
+		from = *periodStart
+	}
+	if periodEnd != nil {
+		to = periodEnd.AddDate(0, 0, 1)
+	}
+	var rows []struct {
+		POItemID string
+		Qty      float64
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT po_item_id,
+			COALESCE(SUM(CASE WHEN created_at >= @from AND created_at < @to THEN qty ELSE 0 END), 0) AS qty
+		FROM (
+			SELECT po_item_id, quantity AS qty, created_at FROM srm_inventory_transactions
+			WHERE po_item_id IN @ids AND type = @in
+			UNION ALL
+			SELECT po_item_id, quantity AS qty, created_at FROM erp_inventory_transactions
+			WHERE po_item_id IN @ids AND reference_type = @ref
+		) t GROUP BY po_item_id
+	`, sql.Named("ids", poItemIDs), sql.Named("in", entity.InventoryTxTypeIn),
+		sql.Named("ref", erpentity.InventoryRefSRMInspection),
+		sql.Named("from", from), sql.Named("to", to)).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.POItemID] = row.Qty
+	}
+	return result, nil
+}
+
+// CreateWithLines 在同一事务中创建对账单、对账行及差异记录
+func (r *SettlementRepository) CreateWithLines(ctx context.Context, s *entity.Settlement, lines []entity.SettlementLine, disputes []entity.SettlementDispute) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Supplier", "Lines", "InvoiceLines", "Disputes").Create(s).Error; err != nil {
+			return err
+		}
+		if len(lines) > 0 {
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+		if len(disputes) > 0 {
+			return tx.Create(&disputes).Error
+		}
+		return nil
+	})
+}
+
+// SaveMatchResult 在同一事务中保存匹配结果：替换各对账行未解决的自动差异、更新对账行与对账单汇总
+func (r *SettlementRepository) SaveMatchResult(ctx context.Context, s *entity.Settlement, lines []entity.SettlementLine, disputes map[string][]entity.SettlementDispute) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range lines {
+			line := &lines[i]
+			if line.MatchStatus != entity.MatchStatusWaived {
+				if err := tx.Where("settlement_line_id = ? AND source = ? AND status = ?",
+					line.ID, entity.DisputeSourceMatch, entity.DisputeStatusOpen).
+					Delete(&entity.SettlementDispute{}).Error; err != nil {
+					return err
+				}
+				if ds := disputes[line.ID]; len(ds) > 0 {
+					if err := tx.Create(&ds).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if err := tx.Save(line).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Supplier", "Lines", "InvoiceLines", "Disputes").Save(s).Error
+	})
+}
+
+// UpdateDisputeAndSyncLine 在同一事务中更新差异记录，并按对账行剩余未解决差异重算其匹配状态：
+// 无未解决差异为 matched，仍有则为 mismatch；待匹配、已豁免的行不变
+func (r *SettlementRepository) UpdateDisputeAndSyncLine(ctx context.Context, d *entity.SettlementDispute) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(d).Error; err != nil {
+			return err
+		}
+		if d.SettlementLineID == nil {
+			return nil
+		}
+		var open int64
+		if err := tx.Model(&entity.SettlementDispute{}).
+			Where("settlement_line_id = ? AND status = ?", *d.SettlementLineID, entity.DisputeStatusOpen).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		status := entity.MatchStatusMatched
+		if open > 0 {
+			status = entity.MatchStatusMismatch
+		}
+		return tx.Model(&entity.SettlementLine{}).
+			Where("id = ? AND match_status IN ?", *d.SettlementLineID,
+				[]string{entity.MatchStatusMatched, entity.MatchStatusMismatch}).
+			Update("match_status", status).Error
+	})
+}
+
+// ResolveLineDisputes 将对账行未解决的差异标记为已解决
+func (r *SettlementRepository) ResolveLineDisputes(ctx context.Context, lineID, resolution string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.SettlementDispute{}).
+		Where("settlement_line_id = ? AND status = ?", lineID, entity.DisputeStatusOpen).
+		Updates(map[string]interface{}{
+			"status":     entity.DisputeStatusResolved,
+			"resolution": resolution,
+		}).Error
+}
+
+// FindTolerance 查找匹配容差：优先供应商级，其次全局；都没有返回nil
+func (r *SettlementRepository) FindTolerance(ctx context.Context, supplierID string) (*entity.MatchTolerance, error) {
+	var tol entity.MatchTolerance
+	err := r.db.WithContext(ctx).
+		Where("supplier_id = ? OR supplier_id IS NULL", supplierID).
+		Order("supplier_id IS NULL ASC").
+		First(&tol).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tol, nil
+}
+
+// ListTolerances 查询全部容差配置
+func (r *SettlementRepository) ListTolerances(ctx context.Context) ([]entity.MatchTolerance, error) {
+	var items []entity.MatchTolerance
+	err := r.db.WithContext(ctx).Order("supplier_id IS NULL DESC, created_at ASC").Find(&items).Error
+	return items, err
+}
+
+// SaveTolerance 新增或更新容差配置（按supplier_id唯一）
+func (r *SettlementRepository) SaveTolerance(ctx context.Context, tol *entity.MatchTolerance) error {
+	var existing entity.MatchTolerance
+	query := r.db.WithContext(ctx)
+	if tol.SupplierID == nil {
+		query = query.Where("supplier_id IS NULL")
+	} else {
+		query = query.Where("supplier_id = ?", *tol.SupplierID)
+	}
+	err := query.First(&existing).Error
+	if err == nil {
+		tol.ID = existing.ID
+		tol.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.WithContext(ctx).Save(tol).Error
+}
@@ -120,6 +120,29 @@ func (r *SupplierRepository) UpdateScores(ctx context.Context, id string, qualit
 		}).Error
 }
 
+// UpdateLevel 更新供应商等级
+func (r *SupplierRepository) UpdateLevel(ctx context.Context, id, level string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.Supplier{}).
+		Where("id = ?", id).
+		Update("level", level).Error
+}
+
+// UpdateStatus 更新供应商状态
+func (r *SupplierRepository) UpdateStatus(ctx context.Context, id, status string) error {
+	return r.db.WithContext(ctx).
+		Model(&entity.Supplier{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+}
+
+// FindByStatus 按状态查询全部供应商（不分页，用于批量任务）
+func (r *SupplierRepository) FindByStatus(ctx context.Context, status string) ([]entity.Supplier, error) {
+	var items []entity.Supplier
+	err := r.db.WithContext(ctx).Where("status = ?", status).Order("code ASC").Find(&items).Error
+	return items, err
+}
+
 // CountByCodePrefix 统计编码前缀数量（用于生成编码）
 func (r *SupplierRepository) CountByCodePrefix(ctx context.Context, prefix string) (int64, error) {
 	var count int64
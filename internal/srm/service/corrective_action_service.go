@@ -1,14 +1,21 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/google/uuid"
 )
 
 // CorrectiveActionService 纠正措施服务
 type CorrectiveActionService struct {
 	repo           *repository.CorrectiveActionRepository
 	inspectionRepo *repository.InspectionRepository
+	defectSvc      *DefectService
 	feishuClient   *feishu.FeishuClient
 }
 
@@ -20,3 +27,212 @@ func NewCorrectiveActionService(repo *repository.CorrectiveActionRepository, ins
 func (s *CorrectiveActionService) SetFeishuClient(fc *feishu.FeishuClient) {
 	s.feishuClient = fc
 }
+
+// SetDefectService 注入缺陷代码服务
+func (s *CorrectiveActionService) SetDefectService(svc *DefectService) {
+	s.defectSvc = svc
+}
+
+// CreateCorrectiveActionRequest 创建8D请求
+type CreateCorrectiveActionRequest struct {
+	InspectionID     string  `json:"inspection_id" binding:"required"`
+	ProblemDesc      string  `json:"problem_desc" binding:"required"`
+	Severity         string  `json:"severity"`
+	DefectCodeID     *string `json:"defect_code_id"`
+	ResponseDeadline *string `json:"response_deadline"` // 2006-01-02
+}
+
+// UpdateCorrectiveActionRequest 更新8D请求
+type UpdateCorrectiveActionRequest struct {
+	ProblemDesc      *string `json:"problem_desc"`
+	Severity         *string `json:"severity"`
+	DefectCodeID     *string `json:"defect_code_id"`
+	ResponseDeadline *string `json:"response_deadline"`
+}
+
+// SupplierRespondRequest 供应商回复请求
+type SupplierRespondRequest struct {
+	RootCause        string  `json:"root_cause" binding:"required"`
+	CorrectiveAction string  `json:"corrective_action" binding:"required"`
+	PreventiveAction string  `json:"preventive_action"`
+	DefectCodeID     *string `json:"defect_code_id"` // 供应商确认的根因代码
+}
+
+// List 获取8D列表
+func (s *CorrectiveActionService) List(ctx context.Context, page, pageSize int, filters map[string]string) ([]entity.CorrectiveAction, int64, error) {
+	return s.repo.FindAll(ctx, page, pageSize, filters)
+}
+
+// Get 获取8D详情
+func (s *CorrectiveActionService) Get(ctx context.Context, id string) (*entity.CorrectiveAction, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// validateDefectCode 校验缺陷代码（未注入缺陷服务时跳过）
+func (s *CorrectiveActionService) validateDefectCode(ctx context.Context, id *string) error {
+	if id == nil || *id == "" || s.defectSvc == nil {
+		return nil
+	}
+	return s.defectSvc.Validate(ctx, *id)
+}
+
+// Create 基于检验单创建8D
+func (s *CorrectiveActionService) Create(ctx context.Context, userID string, req *CreateCorrectiveActionRequest) (*entity.CorrectiveAction, error) {
+	inspection, err := s.inspectionRepo.FindByID(ctx, req.InspectionID)
+	if err != nil {
+		return nil, errors.New("检验单不存在")
+	}
+	if inspection.SupplierID == nil || *inspection.SupplierID == "" {
+		return nil, errors.New("检验单未关联供应商")
+	}
+	if err := s.validateDefectCode(ctx, req.DefectCodeID); err != nil {
+		return nil, err
+	}
+
+	code, err := s.repo.GenerateCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ca := &entity.CorrectiveAction{
+		ID:           uuid.New().String()[:32],
+		CACode:       code,
+		InspectionID: inspection.ID,
+		SupplierID:   *inspection.SupplierID,
+		ProblemDesc:  req.ProblemDesc,
+		Severity:     req.Severity,
+		DefectCodeID: emptyToNil(req.DefectCodeID),
+		Status:       entity.CAStatusOpen,
+		CreatedBy:    userID,
+	}
+
+	// 未指定缺陷代码时沿用检验行项中首个已编码的缺陷
+	if ca.DefectCodeID == nil {
+		for _, item := range inspection.Items {
+			if item.DefectCodeID != nil && item.DefectQty > 0 {
+				ca.DefectCodeID = item.DefectCodeID
+				break
+			}
+		}
+	}
+
+	if req.ResponseDeadline != nil {
+		if t, err := time.Parse("2006-01-02", *req.ResponseDeadline); err == nil {
+			ca.ResponseDeadline = &t
+		}
+	}
+
+	if err := s.repo.Create(ctx, ca); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, ca.ID)
+}
+
+// Update 更新8D
+func (s *CorrectiveActionService) Update(ctx context.Context, id string, req *UpdateCorrectiveActionRequest) (*entity.CorrectiveAction, error) {
+	ca, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Status == entity.CAStatusClosed {
+		return nil, errors.New("8D已关闭，不能修改")
+	}
+	if err := s.validateDefectCode(ctx, req.DefectCodeID); err != nil {
+		return nil, err
+	}
+
+	if req.ProblemDesc != nil {
+		ca.ProblemDesc = *req.ProblemDesc
+	}
+	if req.Severity != nil {
+		ca.Severity = *req.Severity
+	}
+	if req.DefectCodeID != nil {
+		ca.DefectCodeID = emptyToNil(req.DefectCodeID)
+	}
+	if req.ResponseDeadline != nil {
+		if t, err := time.Parse("2006-01-02", *req.ResponseDeadline); err == nil {
+			ca.ResponseDeadline = &t
+		}
+	}
+
+	if err := s.repo.Update(ctx, ca); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, ca.ID)
+}
+
+// SupplierRespond 供应商回复根因与对策
+func (s *CorrectiveActionService) SupplierRespond(ctx context.Context, id string, req *SupplierRespondRequest) (*entity.CorrectiveAction, error) {
+	ca, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Status != entity.CAStatusOpen {
+		return nil, errors.New("只能回复待处理的8D")
+	}
+	if err := s.validateDefectCode(ctx, req.DefectCodeID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ca.RootCause = req.RootCause
+	ca.CorrectiveAction = req.CorrectiveAction
+	ca.PreventiveAction = req.PreventiveAction
+	if req.DefectCodeID != nil && *req.DefectCodeID != "" {
+		ca.DefectCodeID = req.DefectCodeID
+	}
+	ca.Status = entity.CAStatusResponded
+	ca.RespondedAt = &now
+
+	if err := s.repo.Update(ctx, ca); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, ca.ID)
+}
+
+// Verify 验证对策有效性
+func (s *CorrectiveActionService) Verify(ctx context.Context, id string) (*entity.CorrectiveAction, error) {
+	ca, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Status != entity.CAStatusResponded {
+		return nil, errors.New("只能验证已回复的8D")
+	}
+
+	now := time.Now()
+	ca.Status = entity.CAStatusVerified
+	ca.VerifiedAt = &now
+	if err := s.repo.Update(ctx, ca); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, ca.ID)
+}
+
+// Close 关闭8D
+func (s *CorrectiveActionService) Close(ctx context.Context, id string) (*entity.CorrectiveAction, error) {
+	ca, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Status != entity.CAStatusVerified {
+		return nil, errors.New("只能关闭已验证的8D")
+	}
+
+	now := time.Now()
+	ca.Status = entity.CAStatusClosed
+	ca.ClosedAt = &now
+	if err := s.repo.Update(ctx, ca); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, ca.ID)
+}
+
+// emptyToNil 空字符串指针转为nil
+func emptyToNil(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/google/uuid"
+)
+
+// DefectService 缺陷代码服务
+type DefectService struct {
+	repo *repository.DefectRepository
+}
+
+func NewDefectService(repo *repository.DefectRepository) *DefectService {
+	return &DefectService{repo: repo}
+}
+
+// CreateDefectCodeRequest 创建缺陷代码请求
+type CreateDefectCodeRequest struct {
+	Code        string  `json:"code" binding:"required"`
+	Name        string  `json:"name" binding:"required"`
+	Level       string  `json:"level" binding:"required"` // category/mode/cause
+	ParentID    *string `json:"parent_id"`
+	Description string  `json:"description"`
+	Severity    string  `json:"severity"`
+	SortOrder   int     `json:"sort_order"`
+}
+
+// UpdateDefectCodeRequest 更新缺陷代码请求
+type UpdateDefectCodeRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Severity    *string `json:"severity"`
+	Status      *string `json:"status"`
+	SortOrder   *int    `json:"sort_order"`
+}
+
+// List 获取缺陷代码列表
+func (s *DefectService) List(ctx context.Context, filters map[string]string) ([]entity.DefectCode, error) {
+	return s.repo.FindAll(ctx, filters)
+}
+
+// Get 获取缺陷代码
+func (s *DefectService) Get(ctx context.Context, id string) (*entity.DefectCode, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// Tree 获取缺陷代码树（类别 → 模式 → 原因）
+func (s *DefectService) Tree(ctx context.Context, includeInactive bool) ([]entity.DefectCode, error) {
+	filters := map[string]string{}
+	if !includeInactive {
+		filters["status"] = entity.DefectCodeStatusActive
+	}
+	codes, err := s.repo.FindAll(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	return buildDefectTree(codes), nil
+}
+
+// buildDefectTree 将平铺的代码组装为树
+func buildDefectTree(codes []entity.DefectCode) []entity.DefectCode {
+	children := make(map[string][]entity.DefectCode)
+	for _, c := range codes {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var attach func(c entity.DefectCode) entity.DefectCode
+	attach = func(c entity.DefectCode) entity.DefectCode {
+		for _, child := range children[c.ID] {
+			c.Children = append(c.Children, attach(child))
+		}
+		return c
+	}
+
+	var roots []entity.DefectCode
+	for _, c := range codes {
+		if c.ParentID == nil {
+			roots = append(roots, attach(c))
+		}
+	}
+	return roots
+}
+
+// Create 创建缺陷代码
+func (s *DefectService) Create(ctx context.Context, userID string, req *CreateDefectCodeRequest) (*entity.DefectCode, error) {
+	parentLevel := entity.DefectParentLevel(req.Level)
+	switch req.Level {
+	case entity.DefectLevelCategory:
+		if req.ParentID != nil && *req.ParentID != "" {
+			return nil, errors.New("缺陷类别不能有上级")
+		}
+		req.ParentID = nil
+	case entity.DefectLevelMode, entity.DefectLevelCause:
+		if req.ParentID == nil || *req.ParentID == "" {
+			return nil, fmt.Errorf("%s 级缺陷代码必须指定上级", req.Level)
+		}
+		parent, err := s.repo.FindByID(ctx, *req.ParentID)
+		if err != nil {
+			return nil, errors.New("上级缺陷代码不存在")
+		}
+		if parent.Level != parentLevel {
+			return nil, fmt.Errorf("%s 级缺陷代码的上级必须是 %s", req.Level, parentLevel)
+		}
+	default:
+		return nil, fmt.Errorf("无效的缺陷代码层级: %s", req.Level)
+	}
+
+	code := &entity.DefectCode{
+		ID:          uuid.New().String()[:32],
+		Code:        req.Code,
+		Name:        req.Name,
+		Level:       req.Level,
+		ParentID:    req.ParentID,
+		Description: req.Description,
+		Severity:    req.Severity,
+		Status:      entity.DefectCodeStatusActive,
+		SortOrder:   req.SortOrder,
+		CreatedBy:   userID,
+	}
+	if err := s.repo.Create(ctx, code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// Update 更新缺陷代码（代码、层级、上级创建后不可修改，以保证历史统计一致）
+func (s *DefectService) Update(ctx context.Context, id string, req *UpdateDefectCodeRequest) (*entity.DefectCode, error) {
+	code, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		code.Name = *req.Name
+	}
+	if req.Description != nil {
+		code.Description = *req.Description
+	}
+	if req.Severity != nil {
+		code.Severity = *req.Severity
+	}
+	if req.Status != nil {
+		if *req.Status != entity.DefectCodeStatusActive && *req.Status != entity.DefectCodeStatusInactive {
+			return nil, fmt.Errorf("无效的状态: %s", *req.Status)
+		}
+		code.Status = *req.Status
+	}
+	if req.SortOrder != nil {
+		code.SortOrder = *req.SortOrder
+	}
+
+	if err := s.repo.Update(ctx, code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// Delete 删除缺陷代码，已被引用或有下级的代码只能停用
+func (s *DefectService) Delete(ctx context.Context, id string) error {
+	if _, err := s.repo.FindByID(ctx, id); err != nil {
+		return err
+	}
+	children, err := s.repo.CountChildren(ctx, id)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return errors.New("存在下级缺陷代码，不能删除")
+	}
+	usage, err := s.repo.CountUsage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if usage > 0 {
+		return errors.New("缺陷代码已被检验或8D引用，请改为停用")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// Validate 校验缺陷代码存在且处于启用状态
+func (s *DefectService) Validate(ctx context.Context, id string) error {
+	code, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("缺陷代码不存在: %s", id)
+	}
+	if code.Status != entity.DefectCodeStatusActive {
+		return fmt.Errorf("缺陷代码已停用: %s", code.Code)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
@@ -13,6 +14,8 @@ import (
 type EvaluationService struct {
 	repo         *repository.EvaluationRepository
 	supplierRepo *repository.SupplierRepository
+	qualitySvc   *QualityAnalyticsService
+	scorecardSvc *ScorecardService
 }
 
 func NewEvaluationService(repo *repository.EvaluationRepository) *EvaluationService {
@@ -23,6 +26,16 @@ func (s *EvaluationService) SetSupplierRepo(repo *repository.SupplierRepository)
 	s.supplierRepo = repo
 }
 
+// SetQualityAnalyticsService 注入质量分析服务（自动评估质量得分取PPM）
+func (s *EvaluationService) SetQualityAnalyticsService(svc *QualityAnalyticsService) {
+	s.qualitySvc = svc
+}
+
+// SetScorecardService 注入评分卡服务（自动评估优先按评分卡计算）
+func (s *EvaluationService) SetScorecardService(svc *ScorecardService) {
+	s.scorecardSvc = svc
+}
+
 // CreateEvaluationRequest 创建评估请求
 type CreateEvaluationRequest struct {
 	SupplierID    string   `json:"supplier_id" binding:"required"`
@@ -178,6 +191,17 @@ func (s *EvaluationService) GetSupplierHistory(ctx context.Context, supplierID s
 
 // AutoGenerate 自动生成评估（基于PO数据）
 func (s *EvaluationService) AutoGenerate(ctx context.Context, userID string, req *AutoGenerateRequest) (*entity.SupplierEvaluation, error) {
+	// 无法识别的历史周期写法（不是 2026-Q1 / 2026-01 等）不取数，只生成待手工评分的评估
+	start, end, perr := ParsePeriod(req.Period)
+
+	// 供应商分类配置了评分卡时按评分卡KPI计算
+	if s.scorecardSvc != nil && perr == nil {
+		eval, err := s.scorecardSvc.GenerateEvaluation(ctx, req.SupplierID, req.Period, req.EvalType, userID)
+		if err != nil || eval != nil {
+			return eval, err
+		}
+	}
+
 	evalType := "quarterly"
 	if req.EvalType != "" {
 		evalType = req.EvalType
@@ -196,6 +220,22 @@ func (s *EvaluationService) AutoGenerate(ctx context.Context, userID string, req
 		Status:         entity.EvalStatusDraft,
 	}
 
+	// 质量得分：按周期内来料检验PPM计算
+	if s.qualitySvc != nil && perr == nil {
+		stat, err := s.qualitySvc.GetSupplierPPM(ctx, req.SupplierID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		eval.QualityTotal = int(stat.Inspections)
+		eval.QualityPassed = int(stat.Passed)
+		if stat.InspectedQty > 0 {
+			score := entity.CalcQualityScoreFromPPM(stat.PPM)
+			eval.QualityScore = &score
+			eval.Remarks = fmt.Sprintf("来料检验 %.0f 件，不良 %.0f 件，PPM %.0f", stat.InspectedQty, stat.DefectQty, stat.PPM)
+		}
+		s.calcTotalScore(eval)
+	}
+
 	if err := s.repo.Create(ctx, eval); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/google/uuid"
+)
+
+// ExchangeRateService 汇率服务
+type ExchangeRateService struct {
+	repo *repository.ExchangeRateRepository
+}
+
+func NewExchangeRateService(repo *repository.ExchangeRateRepository) *ExchangeRateService {
+	return &ExchangeRateService{repo: repo}
+}
+
+// CreateExchangeRateRequest 录入汇率请求
+type CreateExchangeRateRequest struct {
+	FromCurrency  string  `json:"from_currency" binding:"required"`
+	ToCurrency    string  `json:"to_currency"`
+	Rate          float64 `json:"rate" binding:"required"`
+	EffectiveDate string  `json:"effective_date" binding:"required"`
+}
+
+// ImportExchangeRateResult CSV导入结果
+type ImportExchangeRateResult struct {
+	Imported int      `json:"imported"`
+	Errors   []string `json:"errors"`
+}
+
+// List 汇率列表
+func (s *ExchangeRateService) List(ctx context.Context, page, pageSize int, filters map[string]string) ([]entity.ExchangeRate, int64, error) {
+	return s.repo.FindAll(ctx, page, pageSize, filters)
+}
+
+// Create 手工录入汇率（同币种对同生效日覆盖）
+func (s *ExchangeRateService) Create(ctx context.Context, userID string, req *CreateExchangeRateRequest) (*entity.ExchangeRate, error) {
+	rate, err := newExchangeRate(req.FromCurrency, req.ToCurrency, req.Rate, req.EffectiveDate)
+	if err != nil {
+		return nil, err
+	}
+	rate.Source = entity.ExchangeRateSourceManual
+	rate.CreatedBy = userID
+	if err := s.repo.Upsert(ctx, []entity.ExchangeRate{*rate}); err != nil {
+		return nil, err
+	}
+	return s.repo.FindEffective(ctx, rate.FromCurrency, rate.ToCurrency, rate.EffectiveDate)
+}
+
+// Delete 删除汇率
+func (s *ExchangeRateService) Delete(ctx context.Context, id string) error {
+	if _, err := s.repo.FindByID(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// ImportCSV 导入汇率CSV，表头: from_currency,to_currency,rate,effective_date
+// to_currency 为空时默认本位币；出错的行跳过并在结果中列出
+func (s *ExchangeRateService) ImportCSV(ctx context.Context, userID string, r io.Reader) (*ImportExchangeRateResult, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV解析失败: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("CSV文件为空")
+	}
+
+	cols := make(map[string]int)
+	for i, h := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, required := range []string{"from_currency", "rate", "effective_date"} {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("CSV缺少列: %s", required)
+		}
+	}
+	field := func(row []string, name string) string {
+		idx, ok := cols[name]
+		if !ok || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	result := &ImportExchangeRateResult{Errors: []string{}}
+	var rates []entity.ExchangeRate
+	seen := make(map[string]int) // 同一文件内重复的币种对+生效日以最后一行为准
+	for i, row := range records[1:] {
+		lineNo := i + 2
+		value, err := strconv.ParseFloat(field(row, "rate"), 64)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("第%d行: 汇率格式错误", lineNo))
+			continue
+		}
+		rate, err := newExchangeRate(field(row, "from_currency"), field(row, "to_currency"), value, field(row, "effective_date"))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("第%d行: %s", lineNo, err.Error()))
+			continue
+		}
+		rate.Source = entity.ExchangeRateSourceImport
+		rate.CreatedBy = userID
+		key := rate.FromCurrency + "/" + rate.ToCurrency + "/" + rate.EffectiveDate.Format("2006-01-02")
+		if idx, ok := seen[key]; ok {
+			rates[idx] = *rate
+			continue
+		}
+		seen[key] = len(rates)
+		rates = append(rates, *rate)
+	}
+
+	if err := s.repo.Upsert(ctx, rates); err != nil {
+		return nil, err
+	}
+	result.Imported = len(rates)
+	return result, nil
+}
+
+// GetRate 获取指定日期 from→to 的汇率：同币种为1，其次直接汇率，再次反向汇率取倒数
+func (s *ExchangeRateService) GetRate(ctx context.Context, from, to string, date time.Time) (float64, error) {
+	from, to = normalizeCurrency(from), normalizeCurrency(to)
+	if from == to {
+		return 1, nil
+	}
+	rate, err := s.repo.FindEffective(ctx, from, to, date)
+	if err == nil {
+		return rate.Rate, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return 0, err
+	}
+	inverse, err := s.repo.FindEffective(ctx, to, from, date)
+	if err == nil && inverse.Rate > 0 {
+		return 1 / inverse.Rate, nil
+	}
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return 0, err
+	}
+	return 0, fmt.Errorf("缺少 %s→%s 在 %s 的有效汇率", from, to, date.Format("2006-01-02"))
+}
+
+// ToFunctional 获取交易币种折算本位币的汇率
+func (s *ExchangeRateService) ToFunctional(ctx context.Context, currency string, date time.Time) (float64, error) {
+	return s.GetRate(ctx, currency, entity.FunctionalCurrency, date)
+}
+
+func newExchangeRate(from, to string, value float64, effectiveDate string) (*entity.ExchangeRate, error) {
+	if strings.TrimSpace(from) == "" {
+		return nil, errors.New("源币种不能为空")
+	}
+	from, to = normalizeCurrency(from), normalizeCurrency(to)
+	if from == to {
+		return nil, errors.New("源币种与目标币种不能相同")
+	}
+	if value <= 0 {
+		return nil, errors.New("汇率必须大于0")
+	}
+	date, err := time.Parse("2006-01-02", effectiveDate)
+	if err != nil {
+		return nil, errors.New("无效的生效日期，格式应为 YYYY-MM-DD")
+	}
+	return &entity.ExchangeRate{
+		ID:            uuid.New().String()[:32],
+		FromCurrency:  from,
+		ToCurrency:    to,
+		Rate:          value,
+		EffectiveDate: date,
+	}, nil
+}
+
+// normalizeCurrency 币种代码大写，空值视为本位币
+func normalizeCurrency(c string) string {
+	c = strings.ToUpper(strings.TrimSpace(c))
+	if c == "" {
+		return entity.FunctionalCurrency
+	}
+	return c
+}
@@ -22,6 +22,7 @@ type InspectionService struct {
 	inventorySvc    *InventoryService
 	activityLogRepo *repository.ActivityLogRepository
 	feishuClient    *feishu.FeishuClient
+	defectSvc       *DefectService
 }
 
 func NewInspectionService(repo *repository.InspectionRepository, prRepo *repository.PRRepository) *InspectionService {
@@ -51,6 +52,11 @@ func (s *InspectionService) SetFeishuClient(fc *feishu.FeishuClient) {
 	s.feishuClient = fc
 }
 
+// SetDefectService 注入缺陷代码服务
+func (s *InspectionService) SetDefectService(svc *DefectService) {
+	s.defectSvc = svc
+}
+
 // ListInspections 获取检验列表
 func (s *InspectionService) ListInspections(ctx context.Context, page, pageSize int, filters map[string]string) ([]entity.Inspection, int64, error) {
 	return s.repo.FindAll(ctx, page, pageSize, filters)
@@ -119,6 +125,7 @@ type CompleteInspectionItemReq struct {
 	QualifiedQty float64 `json:"qualified_quantity"`
 	DefectQty    float64 `json:"defect_quantity"`
 	DefectDesc   string  `json:"defect_description"`
+	DefectCodeID *string `json:"defect_code_id"`
 	Result       string  `json:"result"`
 }
 
@@ -129,6 +136,18 @@ func (s *InspectionService) CompleteInspection(ctx context.Context, id, userID s
 		return nil, err
 	}
 
+	// 校验缺陷代码
+	if s.defectSvc != nil {
+		for _, itemReq := range req.Items {
+			if itemReq.DefectCodeID == nil || *itemReq.DefectCodeID == "" {
+				continue
+			}
+			if err := s.defectSvc.Validate(ctx, *itemReq.DefectCodeID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	now := time.Now()
 	inspection.Status = entity.InspectionStatusCompleted
 	inspection.Result = req.Result
@@ -151,6 +170,8 @@ func (s *InspectionService) CompleteInspection(ctx context.Context, id, userID s
 					inspection.Items[i].QualifiedQty = itemReq.QualifiedQty
 					inspection.Items[i].DefectQty = itemReq.DefectQty
 					inspection.Items[i].DefectDesc = itemReq.DefectDesc
+					inspection.Items[i].DefectCodeID = emptyToNil(itemReq.DefectCodeID)
+					inspection.Items[i].DefectCode = nil
 					inspection.Items[i].InspectedQty = itemReq.InspectedQty
 					inspection.Items[i].Result = itemReq.Result
 				}
@@ -158,6 +179,18 @@ func (s *InspectionService) CompleteInspection(ctx context.Context, id, userID s
 		}
 	}
 
+	// 合格品需要自动入库，物料主数据无法识别时拒绝完成检验，避免已检货物无法入账
+	if (req.Result == "passed" || req.Result == "conditional") && s.inventorySvc != nil {
+		for _, item := range inspection.Items {
+			if item.Result == "failed" || item.QualifiedQty <= 0 {
+				continue
+			}
+			if err := s.inventorySvc.CheckReceivable(ctx, item.MaterialCode, item.MaterialName); err != nil {
+				return nil, fmt.Errorf("物料 %s 无法入库: %w", item.MaterialCode, err)
+			}
+		}
+	}
+
 	if err := s.repo.Update(ctx, inspection); err != nil {
 		return nil, err
 	}
@@ -179,7 +212,9 @@ func (s *InspectionService) CompleteInspection(ctx context.Context, id, userID s
 
 			// Auto stock-in for qualified quantity
 			if s.inventorySvc != nil && item.QualifiedQty > 0 {
-				s.inventorySvc.StockInFromInspection(ctx, inspection.ID, item.MaterialName, item.MaterialCode, inspection.SupplierID, item.QualifiedQty, "pcs")
+				if err := s.inventorySvc.StockInFromInspection(ctx, inspection.ID, item.POItemID, item.MaterialName, item.MaterialCode, inspection.SupplierID, item.QualifiedQty, "pcs"); err != nil {
+					return nil, fmt.Errorf("检验单 %s 物料 %s 自动入库失败: %w", inspection.InspectionCode, item.MaterialCode, err)
+				}
 			}
 		}
 	}
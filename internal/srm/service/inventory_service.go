@@ -5,33 +5,72 @@ import (
 	"fmt"
 	"time"
 
+	erpentity "github.com/bitfantasy/nimo/internal/erp/entity"
+	erprepo "github.com/bitfantasy/nimo/internal/erp/repository"
+	erpsvc "github.com/bitfantasy/nimo/internal/erp/service"
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
 	"github.com/google/uuid"
 )
 
-// InventoryService 库存服务
+// InventoryService 库存服务：SRM 出入库直接过账到 ERP 统一库存台账，
+// 质检入库对 ERP MRP 与生产领料立即可见
 type InventoryService struct {
-	repo *repository.InventoryRepository
+	repo  *repository.InventoryRepository // SRM 历史库存表，仅迁移使用
+	stock *erpsvc.InventoryService
 }
 
-func NewInventoryService(repo *repository.InventoryRepository) *InventoryService {
-	return &InventoryService{repo: repo}
+func NewInventoryService(repo *repository.InventoryRepository, stock *erpsvc.InventoryService) *InventoryService {
+	return &InventoryService{repo: repo, stock: stock}
 }
 
 // ListInventory 库存列表
 func (s *InventoryService) ListInventory(ctx context.Context, page, pageSize int, filters map[string]string) ([]entity.InventoryRecord, int64, error) {
-	return s.repo.FindAll(ctx, page, pageSize, filters)
+	params := erprepo.InventoryListParams{
+		Keyword:    filters["search"],
+		SupplierID: filters["supplier_id"],
+		LowStock:   filters["low_stock"] == "true",
+		Page:       page,
+		Size:       pageSize,
+	}
+	if warehouse := filters["warehouse"]; warehouse != "" {
+		wh, err := s.stock.ResolveWarehouse(warehouse)
+		if err != nil {
+			return []entity.InventoryRecord{}, 0, nil
+		}
+		params.WarehouseID = wh.ID
+	}
+	items, total, err := s.stock.List(params)
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([]entity.InventoryRecord, 0, len(items))
+	for i := range items {
+		records = append(records, *toInventoryRecord(&items[i]))
+	}
+	return records, total, nil
 }
 
 // GetInventory 库存详情
 func (s *InventoryService) GetInventory(ctx context.Context, id string) (*entity.InventoryRecord, error) {
-	return s.repo.FindByID(ctx, id)
+	inv, err := s.stock.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return toInventoryRecord(inv), nil
 }
 
 // GetTransactions 库存流水
 func (s *InventoryService) GetTransactions(ctx context.Context, inventoryID string, page, pageSize int) ([]entity.InventoryTransaction, int64, error) {
-	return s.repo.FindTransactions(ctx, inventoryID, page, pageSize)
+	items, total, err := s.stock.ListTransactionsByInventory(inventoryID, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	txs := make([]entity.InventoryTransaction, 0, len(items))
+	for i := range items {
+		txs = append(txs, toInventoryTransaction(&items[i]))
+	}
+	return txs, total, nil
 }
 
 // InRequest 入库请求
@@ -42,7 +81,7 @@ type InRequest struct {
 	SupplierID   *string `json:"supplier_id"`
 	Quantity     float64 `json:"quantity" binding:"required"`
 	Unit         string  `json:"unit"`
-	Warehouse    string  `json:"warehouse"`
+	Warehouse    string  `json:"warehouse"` // 仓库ID/编码/名称，为空时入默认收货仓
 	Operator     string  `json:"operator"`
 	Notes        string  `json:"notes"`
 }
@@ -52,37 +91,26 @@ func (s *InventoryService) StockIn(ctx context.Context, req *InRequest) (*entity
 	if req.Quantity <= 0 {
 		return nil, fmt.Errorf("入库数量必须大于0")
 	}
-
-	record, err := s.findOrCreateRecord(ctx, req.MaterialName, req.MaterialCode, req.MPN, req.SupplierID, req.Unit, req.Warehouse)
-	if err != nil {
-		return nil, err
-	}
-
-	record.Quantity += req.Quantity
-	now := time.Now()
-	record.LastInDate = &now
-	if err := s.repo.Update(ctx, record); err != nil {
-		return nil, err
-	}
-
-	tx := &entity.InventoryTransaction{
-		ID:            uuid.New().String()[:32],
-		InventoryID:   record.ID,
-		Type:          entity.InventoryTxTypeIn,
-		Quantity:      req.Quantity,
-		ReferenceType: entity.InventoryRefManual,
-		Operator:      req.Operator,
-		Notes:         req.Notes,
-	}
-	s.repo.CreateTransaction(ctx, tx)
-
-	return record, nil
+	return s.receive(stockReceipt{
+		materialName:  req.MaterialName,
+		materialCode:  req.MaterialCode,
+		mpn:           req.MPN,
+		supplierID:    req.SupplierID,
+		warehouse:     req.Warehouse,
+		unit:          req.Unit,
+		quantity:      req.Quantity,
+		referenceType: erpentity.InventoryRefSRMManual,
+		referenceID:   uuid.New().String(),
+		operator:      req.Operator,
+		notes:         req.Notes,
+	})
 }
 
 // OutRequest 出库请求
 type OutRequest struct {
 	InventoryID string  `json:"inventory_id" binding:"required"`
 	Quantity    float64 `json:"quantity" binding:"required"`
+	Version     *int    `json:"version"` // 可选，乐观锁校验
 	Operator    string  `json:"operator"`
 	Notes       string  `json:"notes"`
 }
@@ -92,122 +120,261 @@ func (s *InventoryService) StockOut(ctx context.Context, req *OutRequest) (*enti
 	if req.Quantity <= 0 {
 		return nil, fmt.Errorf("出库数量必须大于0")
 	}
-
-	record, err := s.repo.FindByID(ctx, req.InventoryID)
+	inv, err := s.stock.GetByID(req.InventoryID)
 	if err != nil {
 		return nil, err
 	}
 
-	if record.Quantity < req.Quantity {
-		return nil, fmt.Errorf("库存不足，当前库存 %.2f", record.Quantity)
-	}
-
-	record.Quantity -= req.Quantity
-	if err := s.repo.Update(ctx, record); err != nil {
+	balances, err := s.stock.Post([]erprepo.StockMovement{{
+		Tx: &erpentity.InventoryTransaction{
+			ID:              uuid.New().String(),
+			MaterialID:      inv.MaterialID,
+			MaterialCode:    inv.MaterialCode,
+			MaterialName:    inv.MaterialName,
+			WarehouseID:     inv.WarehouseID,
+			TransactionType: erpentity.TxTypeProductionOut,
+			Quantity:        -req.Quantity,
+			ReferenceType:   erpentity.InventoryRefSRMManual,
+			ReferenceID:     uuid.New().String(),
+			Notes:           req.Notes,
+			CreatedBy:       req.Operator,
+		},
+		ExpectedVersion: req.Version,
+	}})
+	if err != nil {
 		return nil, err
 	}
-
-	tx := &entity.InventoryTransaction{
-		ID:            uuid.New().String()[:32],
-		InventoryID:   record.ID,
-		Type:          entity.InventoryTxTypeOut,
-		Quantity:      -req.Quantity,
-		ReferenceType: entity.InventoryRefManual,
-		Operator:      req.Operator,
-		Notes:         req.Notes,
-	}
-	s.repo.CreateTransaction(ctx, tx)
-
-	return record, nil
+	return toInventoryRecord(&balances[0]), nil
 }
 
 // AdjustRequest 调整请求
 type AdjustRequest struct {
 	InventoryID string  `json:"inventory_id" binding:"required"`
 	Quantity    float64 `json:"quantity" binding:"required"` // 调整后的数量
+	Version     *int    `json:"version"`                     // 可选，乐观锁校验
 	Operator    string  `json:"operator"`
 	Notes       string  `json:"notes"`
 }
 
 // StockAdjust 库存调整
 func (s *InventoryService) StockAdjust(ctx context.Context, req *AdjustRequest) (*entity.InventoryRecord, error) {
-	record, err := s.repo.FindByID(ctx, req.InventoryID)
+	if req.Quantity < 0 {
+		return nil, fmt.Errorf("调整后数量不能为负")
+	}
+	inv, err := s.stock.GetByID(req.InventoryID)
 	if err != nil {
 		return nil, err
 	}
+	// 差额按读取时的结存计算，未指定版本时以读取版本校验，避免期间的变动被覆盖
+	version := inv.Version
+	if req.Version != nil {
+		version = *req.Version
+	}
 
-	diff := req.Quantity - record.Quantity
-	record.Quantity = req.Quantity
-	if err := s.repo.Update(ctx, record); err != nil {
+	balances, err := s.stock.Post([]erprepo.StockMovement{{
+		Tx: &erpentity.InventoryTransaction{
+			ID:              uuid.New().String(),
+			MaterialID:      inv.MaterialID,
+			MaterialCode:    inv.MaterialCode,
+			MaterialName:    inv.MaterialName,
+			WarehouseID:     inv.WarehouseID,
+			TransactionType: erpentity.TxTypeAdjust,
+			Quantity:        req.Quantity - inv.Quantity,
+			ReferenceType:   erpentity.InventoryRefSRMAdjust,
+			ReferenceID:     uuid.New().String(),
+			Notes:           fmt.Sprintf("调整: %.2f → %.2f (%s)", inv.Quantity, req.Quantity, req.Notes),
+			CreatedBy:       req.Operator,
+		},
+		ExpectedVersion: &version,
+	}})
+	if err != nil {
 		return nil, err
 	}
+	return toInventoryRecord(&balances[0]), nil
+}
 
-	tx := &entity.InventoryTransaction{
-		ID:            uuid.New().String()[:32],
-		InventoryID:   record.ID,
-		Type:          entity.InventoryTxTypeAdjust,
-		Quantity:      diff,
-		ReferenceType: entity.InventoryRefAdjust,
-		Operator:      req.Operator,
-		Notes:         fmt.Sprintf("调整: %.2f → %.2f (%s)", record.Quantity-diff, req.Quantity, req.Notes),
+// StockInFromInspection 质检通过后自动入库，poItemID 用于对账三单匹配
+func (s *InventoryService) StockInFromInspection(ctx context.Context, inspectionID string, poItemID *string, materialName, materialCode string, supplierID *string, qty float64, unit string) error {
+	r := stockReceipt{
+		materialName:  materialName,
+		materialCode:  materialCode,
+		supplierID:    supplierID,
+		unit:          unit,
+		quantity:      qty,
+		referenceType: erpentity.InventoryRefSRMInspection,
+		referenceID:   inspectionID,
+		operator:      "system",
+		notes:         "质检通过自动入库",
 	}
-	s.repo.CreateTransaction(ctx, tx)
+	if poItemID != nil {
+		r.poItemID = *poItemID
+	}
+	_, err := s.receive(r)
+	return err
+}
 
-	return record, nil
+type stockReceipt struct {
+	materialName, materialCode, mpn string
+	supplierID                      *string
+	warehouse, unit                 string
+	quantity                        float64
+	referenceType, referenceID      string
+	poItemID                        string
+	operator, notes                 string
 }
 
-// StockInFromInspection 质检通过后自动入库
-func (s *InventoryService) StockInFromInspection(ctx context.Context, inspectionID string, materialName, materialCode string, supplierID *string, qty float64, unit string) error {
-	record, err := s.findOrCreateRecord(ctx, materialName, materialCode, "", supplierID, unit, "")
+// CheckReceivable 校验物料能在物料主数据中唯一识别，质检完成前调用，避免合格品无法入库
+func (s *InventoryService) CheckReceivable(ctx context.Context, materialCode, materialName string) error {
+	_, err := s.stock.ResolveMaterial(materialCode, materialName, "")
+	return err
+}
+
+// receive 按物料主数据识别物料后过账入库
+func (s *InventoryService) receive(r stockReceipt) (*entity.InventoryRecord, error) {
+	mat, err := s.stock.ResolveMaterial(r.materialCode, r.materialName, r.mpn)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	record.Quantity += qty
-	now := time.Now()
-	record.LastInDate = &now
-	if err := s.repo.Update(ctx, record); err != nil {
-		return err
+	wh, err := s.stock.ResolveWarehouse(r.warehouse)
+	if err != nil {
+		return nil, err
+	}
+	unit := mat.Unit
+	if unit == "" {
+		unit = r.unit
 	}
+	tx := &erpentity.InventoryTransaction{
+		ID:              uuid.New().String(),
+		MaterialID:      mat.ID,
+		MaterialCode:    mat.Code,
+		MaterialName:    mat.Name,
+		WarehouseID:     wh.ID,
+		TransactionType: erpentity.TxTypePurchaseIn,
+		Quantity:        r.quantity,
+		BatchNo:         erpsvc.GenerateBatchNo(),
+		ReferenceType:   r.referenceType,
+		ReferenceID:     r.referenceID,
+		POItemID:        r.poItemID,
+		Notes:           r.notes,
+		CreatedBy:       r.operator,
+	}
+	if r.supplierID != nil {
+		tx.SupplierID = *r.supplierID
+	}
+	balances, err := s.stock.Post([]erprepo.StockMovement{{
+		Tx:              tx,
+		CreateIfMissing: true,
+		InventoryType:   erpentity.InventoryTypeRaw,
+		Unit:            unit,
+		MPN:             r.mpn,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return toInventoryRecord(&balances[0]), nil
+}
 
-	tx := &entity.InventoryTransaction{
-		ID:            uuid.New().String()[:32],
-		InventoryID:   record.ID,
-		Type:          entity.InventoryTxTypeIn,
-		Quantity:      qty,
-		ReferenceType: entity.InventoryRefInspection,
-		ReferenceID:   inspectionID,
-		Operator:      "system",
-		Notes:         "质检通过自动入库",
+// Reconcile 核对统一台账的结存与流水，fix=true 时以流水为准重建结存
+func (s *InventoryService) Reconcile(ctx context.Context, fix bool, userID string) ([]erpsvc.InventoryDrift, error) {
+	result, err := s.stock.Reconcile(fix, "MANUAL", userID)
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.CreateTransaction(ctx, tx)
+	return result.Drifts, nil
 }
 
-func (s *InventoryService) findOrCreateRecord(ctx context.Context, name, code, mpn string, supplierID *string, unit, warehouse string) (*entity.InventoryRecord, error) {
-	if code != "" {
-		existing, err := s.repo.FindByMaterialAndSupplier(ctx, code, supplierID)
-		if err != nil {
-			return nil, err
+// StartReconcileScheduler 定期核对库存结存，只记录差异不自动修复
+func (s *InventoryService) StartReconcileScheduler(ctx context.Context, interval time.Duration) {
+	s.stock.StartReconcileScheduler(ctx, interval)
+}
+
+// MigrateLegacyStock 将 SRM 历史库存按物料身份合并到统一台账，dryRun 只生成报告
+func (s *InventoryService) MigrateLegacyStock(ctx context.Context, dryRun bool, userID string) (*erpsvc.StockMigrationReport, error) {
+	records, err := s.repo.FindAllRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	legacy := make([]erpsvc.LegacyStockRecord, 0, len(records))
+	for _, r := range records {
+		rec := erpsvc.LegacyStockRecord{
+			SourceID:     r.ID,
+			MaterialCode: r.MaterialCode,
+			MaterialName: r.MaterialName,
+			MPN:          r.MPN,
+			Warehouse:    r.Warehouse,
+			Quantity:     r.Quantity,
+			Unit:         r.Unit,
 		}
-		if existing != nil {
-			return existing, nil
+		if r.SupplierID != nil {
+			rec.SupplierID = *r.SupplierID
 		}
+		legacy = append(legacy, rec)
+	}
+	return s.stock.MergeLegacyStock(migrationSourceSRM, legacy, dryRun, userID)
+}
+
+// ListMigrations SRM 历史库存迁移记录，status=CONFLICT 为冲突报告
+func (s *InventoryService) ListMigrations(ctx context.Context, status string) ([]erpentity.InventoryMigration, error) {
+	return s.stock.ListMigrations(migrationSourceSRM, status)
+}
+
+const migrationSourceSRM = "SRM"
+
+// toInventoryRecord 统一台账结存转为 SRM 库存接口的返回结构
+func toInventoryRecord(inv *erpentity.Inventory) *entity.InventoryRecord {
+	rec := &entity.InventoryRecord{
+		ID:           inv.ID,
+		MaterialName: inv.MaterialName,
+		MaterialCode: inv.MaterialCode,
+		MPN:          inv.MPN,
+		Quantity:     inv.Quantity,
+		Unit:         inv.Unit,
+		Warehouse:    inv.WarehouseID,
+		LastInDate:   inv.LastMovedAt,
+		SafetyStock:  inv.SafetyStock,
+		Version:      inv.Version,
+		CreatedAt:    inv.CreatedAt,
+		UpdatedAt:    inv.UpdatedAt,
 	}
+	if inv.Warehouse != nil {
+		rec.Warehouse = inv.Warehouse.Name
+	}
+	return rec
+}
 
-	record := &entity.InventoryRecord{
-		ID:           uuid.New().String()[:32],
-		MaterialName: name,
-		MaterialCode: code,
-		MPN:          mpn,
-		SupplierID:   supplierID,
-		Unit:         unit,
-		Warehouse:    warehouse,
+// toInventoryTransaction 统一台账流水转为 SRM 库存流水结构
+func toInventoryTransaction(tx *erpentity.InventoryTransaction) entity.InventoryTransaction {
+	out := entity.InventoryTransaction{
+		ID:           tx.ID,
+		InventoryID:  tx.InventoryID,
+		Quantity:     tx.Quantity,
+		BalanceAfter: tx.BalanceAfter,
+		ReferenceID:  tx.ReferenceID,
+		Operator:     tx.CreatedBy,
+		Notes:        tx.Notes,
+		CreatedAt:    tx.CreatedAt,
 	}
-	if record.Unit == "" {
-		record.Unit = "pcs"
+	switch {
+	case tx.TransactionType == erpentity.TxTypeAdjust:
+		out.Type = entity.InventoryTxTypeAdjust
+	case tx.Quantity < 0:
+		out.Type = entity.InventoryTxTypeOut
+	default:
+		out.Type = entity.InventoryTxTypeIn
 	}
-	if err := s.repo.Create(ctx, record); err != nil {
-		return nil, err
+	switch tx.ReferenceType {
+	case erpentity.InventoryRefSRMInspection:
+		out.ReferenceType = entity.InventoryRefInspection
+	case erpentity.InventoryRefSRMManual:
+		out.ReferenceType = entity.InventoryRefManual
+	case erpentity.InventoryRefSRMAdjust:
+		out.ReferenceType = entity.InventoryRefAdjust
+	default:
+		out.ReferenceType = tx.ReferenceType
+	}
+	if tx.POItemID != "" {
+		poItemID := tx.POItemID
+		out.POItemID = &poItemID
 	}
-	return record, nil
+	return out
 }
@@ -38,6 +38,8 @@ type ProcurementService struct {
 	db              *gorm.DB
 	feishuClient    *feishu.FeishuClient
 	activityLogRepo *repository.ActivityLogRepository
+	fxSvc           *ExchangeRateService
+	supplierRepo    *repository.SupplierRepository
 }
 
 func NewProcurementService(prRepo *repository.PRRepository, poRepo *repository.PORepository, db *gorm.DB) *ProcurementService {
@@ -48,6 +50,11 @@ func NewProcurementService(prRepo *repository.PRRepository, poRepo *repository.P
 	}
 }
 
+// SetSupplierRepo 注入供应商仓库（分配供应商时校验准入状态）
+func (s *ProcurementService) SetSupplierRepo(repo *repository.SupplierRepository) {
+	s.supplierRepo = repo
+}
+
 // SetActivityLogRepo 注入操作日志仓库
 func (s *ProcurementService) SetActivityLogRepo(repo *repository.ActivityLogRepository) {
 	s.activityLogRepo = repo
@@ -60,6 +67,11 @@ func (s *ProcurementService) logActivity(ctx context.Context, entityType, entity
 	}
 }
 
+// SetExchangeRateService 注入汇率服务（PO审批时锁定汇率）
+func (s *ProcurementService) SetExchangeRateService(svc *ExchangeRateService) {
+	s.fxSvc = svc
+}
+
 // SetFeishuClient 注入飞书客户端
 func (s *ProcurementService) SetFeishuClient(fc *feishu.FeishuClient) {
 	s.feishuClient = fc
@@ -562,6 +574,20 @@ func (s *ProcurementService) AssignSupplierToItem(ctx context.Context, prID, ite
 		return nil, fmt.Errorf("行项不属于该采购需求")
 	}
 
+	// 只能分配已准入（启用且等级不低于合格）的供应商
+	if s.supplierRepo != nil {
+		supplier, err := s.supplierRepo.FindByID(ctx, req.SupplierID)
+		if err != nil {
+			return nil, fmt.Errorf("供应商不存在")
+		}
+		if supplier.Level == entity.SupplierLevelProbation {
+			return nil, fmt.Errorf("供应商 %s 评估为D级处于考察期，暂停分配新的采购需求", supplier.Name)
+		}
+		if !supplier.IsQualified() {
+			return nil, fmt.Errorf("供应商 %s 未通过准入或已暂停（状态: %s，等级: %s），不能分配", supplier.Name, supplier.Status, supplier.Level)
+		}
+	}
+
 	// 更新供应商信息
 	item.SupplierID = &req.SupplierID
 	if req.UnitPrice != nil {
@@ -856,6 +882,10 @@ func (s *ProcurementService) ApprovePO(ctx context.Context, id, userID string) (
 	}
 
 	now := time.Now()
+	if err := s.lockExchangeRate(ctx, po, now); err != nil {
+		return nil, err
+	}
+
 	oldStatus := po.Status
 	po.Status = entity.POStatusApproved
 	po.ApprovedBy = &userID
@@ -874,6 +904,26 @@ func (s *ProcurementService) ApprovePO(ctx context.Context, id, userID string) (
 	return po, nil
 }
 
+// lockExchangeRate 按审批日汇率锁定PO折算本位币的汇率，外币缺少汇率时不允许审批
+func (s *ProcurementService) lockExchangeRate(ctx context.Context, po *entity.PurchaseOrder, at time.Time) error {
+	var rate float64
+	switch {
+	case normalizeCurrency(po.Currency) == entity.FunctionalCurrency:
+		rate = 1
+	case s.fxSvc == nil:
+		return nil
+	default:
+		var err error
+		rate, err = s.fxSvc.ToFunctional(ctx, po.Currency, at)
+		if err != nil {
+			return fmt.Errorf("无法锁定汇率: %w", err)
+		}
+	}
+	po.ExchangeRate = &rate
+	po.RateLockedAt = &at
+	return nil
+}
+
 // SubmitPO 提交PO审批
 func (s *ProcurementService) SubmitPO(ctx context.Context, id string) (*entity.PurchaseOrder, error) {
 	po, err := s.poRepo.FindByID(ctx, id)
@@ -0,0 +1,554 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/google/uuid"
+)
+
+// QualificationService 供应商准入与资质证书服务
+type QualificationService struct {
+	repo            *repository.QualificationRepository
+	supplierRepo    *repository.SupplierRepository
+	samplingRepo    *repository.SamplingRepository
+	activityLogRepo *repository.ActivityLogRepository
+	feishuClient    *feishu.FeishuClient
+}
+
+func NewQualificationService(repo *repository.QualificationRepository, supplierRepo *repository.SupplierRepository, samplingRepo *repository.SamplingRepository) *QualificationService {
+	return &QualificationService{repo: repo, supplierRepo: supplierRepo, samplingRepo: samplingRepo}
+}
+
+// SetActivityLogRepo 注入操作日志仓库
+func (s *QualificationService) SetActivityLogRepo(repo *repository.ActivityLogRepository) {
+	s.activityLogRepo = repo
+}
+
+// SetFeishuClient 注入飞书客户端（证书到期提醒）
+func (s *QualificationService) SetFeishuClient(fc *feishu.FeishuClient) {
+	s.feishuClient = fc
+}
+
+// logActivity 记录操作日志（安全调用）
+func (s *QualificationService) logActivity(ctx context.Context, entityType, entityID, entityCode, action, fromStatus, toStatus, content, operatorID string) {
+	if s.activityLogRepo != nil {
+		s.activityLogRepo.LogActivity(ctx, entityType, entityID, entityCode, action, fromStatus, toStatus, content, operatorID, "")
+	}
+}
+
+// ListBySupplier 供应商准入审核记录
+func (s *QualificationService) ListBySupplier(ctx context.Context, supplierID string) ([]entity.SupplierQualification, error) {
+	return s.repo.FindBySupplier(ctx, supplierID)
+}
+
+// Get 准入审核单详情
+func (s *QualificationService) Get(ctx context.Context, id string) (*entity.SupplierQualification, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// Start 发起准入审核
+func (s *QualificationService) Start(ctx context.Context, supplierID, userID string) (*entity.SupplierQualification, error) {
+	supplier, err := s.supplierRepo.FindByID(ctx, supplierID)
+	if err != nil {
+		return nil, errors.New("供应商不存在")
+	}
+	if supplier.Status == entity.SupplierStatusBlacklisted {
+		return nil, errors.New("黑名单供应商不能发起准入")
+	}
+	if _, err := s.repo.FindInProgress(ctx, supplierID); err == nil {
+		return nil, errors.New("该供应商已有进行中的准入审核")
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	code, err := s.repo.GenerateCode(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("生成编码失败: %w", err)
+	}
+
+	q := &entity.SupplierQualification{
+		ID:          uuid.New().String()[:32],
+		Code:        code,
+		SupplierID:  supplierID,
+		Status:      entity.QualificationStatusInProgress,
+		CurrentStep: entity.QualificationStepQuestionnaire,
+		CreatedBy:   userID,
+	}
+	if err := s.repo.Create(ctx, q); err != nil {
+		return nil, err
+	}
+
+	s.logActivity(ctx, "supplier", supplierID, supplier.Code, "qualification_start", "",
+		q.CurrentStep, fmt.Sprintf("发起准入审核 %s", q.Code), userID)
+	return q, nil
+}
+
+// loadStep 加载进行中的审核单并校验当前步骤
+func (s *QualificationService) loadStep(ctx context.Context, id, step string) (*entity.SupplierQualification, error) {
+	q, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("准入审核单不存在")
+	}
+	if q.Status != entity.QualificationStatusInProgress {
+		return nil, fmt.Errorf("准入审核已结束，当前状态: %s", q.Status)
+	}
+	if q.CurrentStep != step {
+		return nil, fmt.Errorf("当前步骤为 %s，不能执行 %s", q.CurrentStep, step)
+	}
+	return q, nil
+}
+
+// SubmitQuestionnaireRequest 提交调查问卷
+type SubmitQuestionnaireRequest struct {
+	Answers entity.JSONB `json:"answers" binding:"required"`
+	Score   *float64     `json:"score"`
+}
+
+// SubmitQuestionnaire 提交调查问卷，进入现场审核
+func (s *QualificationService) SubmitQuestionnaire(ctx context.Context, id, userID string, req *SubmitQuestionnaireRequest) (*entity.SupplierQualification, error) {
+	q, err := s.loadStep(ctx, id, entity.QualificationStepQuestionnaire)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	q.QuestionnaireAnswers = &req.Answers
+	q.QuestionnaireScore = req.Score
+	q.QuestionnaireAt = &now
+	q.QuestionnaireBy = userID
+	q.CurrentStep = entity.QualificationStepAudit
+	if err := s.repo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+
+	s.logActivity(ctx, "supplier", q.SupplierID, q.Code, "qualification_questionnaire",
+		entity.QualificationStepQuestionnaire, q.CurrentStep, "提交供应商调查问卷", userID)
+	return q, nil
+}
+
+// RecordAuditRequest 登记现场审核
+type RecordAuditRequest struct {
+	AuditDate *time.Time `json:"audit_date"`
+	Auditor   string     `json:"auditor" binding:"required"`
+	Score     *float64   `json:"score"`
+	Result    string     `json:"result" binding:"required"` // passed/conditional/failed
+	Findings  string     `json:"findings"`
+	ReportURL string     `json:"report_url"`
+}
+
+// RecordAudit 登记现场审核结论；不通过则准入驳回，否则进入样品承认
+func (s *QualificationService) RecordAudit(ctx context.Context, id, userID string, req *RecordAuditRequest) (*entity.SupplierQualification, error) {
+	switch req.Result {
+	case entity.AuditResultPassed, entity.AuditResultConditional, entity.AuditResultFailed:
+	default:
+		return nil, fmt.Errorf("无效的审核结论: %s", req.Result)
+	}
+
+	q, err := s.loadStep(ctx, id, entity.QualificationStepAudit)
+	if err != nil {
+		return nil, err
+	}
+
+	auditDate := time.Now()
+	if req.AuditDate != nil {
+		auditDate = *req.AuditDate
+	}
+	q.AuditDate = &auditDate
+	q.Auditor = req.Auditor
+	q.AuditScore = req.Score
+	q.AuditResult = req.Result
+	q.AuditFindings = req.Findings
+	q.AuditReportURL = req.ReportURL
+
+	if req.Result == entity.AuditResultFailed {
+		q.Status = entity.QualificationStatusRejected
+		q.RejectReason = "现场审核不通过"
+	} else {
+		q.CurrentStep = entity.QualificationStepSample
+	}
+	if err := s.repo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+
+	s.logActivity(ctx, "supplier", q.SupplierID, q.Code, "qualification_audit",
+		entity.QualificationStepAudit, q.CurrentStep, fmt.Sprintf("现场审核结论: %s", req.Result), userID)
+	return q, nil
+}
+
+// LinkSampleRequest 关联样品承认的打样记录
+type LinkSampleRequest struct {
+	SamplingRequestID string `json:"sampling_request_id" binding:"required"`
+}
+
+// LinkSample 关联该供应商的打样记录；打样已验证通过则直接进入会签，否则等待验证结果
+func (s *QualificationService) LinkSample(ctx context.Context, id, userID string, req *LinkSampleRequest) (*entity.SupplierQualification, error) {
+	q, err := s.loadStep(ctx, id, entity.QualificationStepSample)
+	if err != nil {
+		return nil, err
+	}
+
+	sampling, err := s.samplingRepo.FindByID(ctx, req.SamplingRequestID)
+	if err != nil {
+		return nil, errors.New("打样记录不存在")
+	}
+	if sampling.SupplierID != q.SupplierID {
+		return nil, errors.New("打样记录不属于该供应商")
+	}
+	if sampling.Status == entity.SamplingStatusFailed {
+		return nil, errors.New("该打样验证未通过，请重新打样后关联")
+	}
+
+	q.SamplingRequestID = sampling.ID
+	q.SampleResult = ""
+	s.applySampleResult(q, sampling)
+	if err := s.repo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+
+	s.logActivity(ctx, "supplier", q.SupplierID, q.Code, "qualification_sample",
+		entity.QualificationStepSample, q.CurrentStep, fmt.Sprintf("关联打样记录 R%d（%s）", sampling.Round, sampling.Status), userID)
+	return q, nil
+}
+
+// applySampleResult 按打样验证结果推进审核单：通过进入会签，不通过清除关联等待重新打样
+func (s *QualificationService) applySampleResult(q *entity.SupplierQualification, sampling *entity.SamplingRequest) {
+	switch sampling.Status {
+	case entity.SamplingStatusPassed:
+		q.SampleResult = "passed"
+		q.SampleApprovedAt = sampling.VerifiedAt
+		if q.SampleApprovedAt == nil {
+			now := time.Now()
+			q.SampleApprovedAt = &now
+		}
+		q.CurrentStep = entity.QualificationStepSignoff
+	case entity.SamplingStatusFailed:
+		q.SampleResult = "failed"
+		q.SamplingRequestID = ""
+	}
+}
+
+// OnSampleVerified 打样验证完成回调：推进关联该打样记录的准入审核单
+func (s *QualificationService) OnSampleVerified(ctx context.Context, sampling *entity.SamplingRequest) {
+	items, err := s.repo.FindBySamplingRequest(ctx, sampling.ID)
+	if err != nil {
+		log.Printf("[SRM] 查询关联准入审核单失败: %v", err)
+		return
+	}
+	for i := range items {
+		q := &items[i]
+		s.applySampleResult(q, sampling)
+		if err := s.repo.Update(ctx, q); err != nil {
+			log.Printf("[SRM] 更新准入审核单失败: %s %v", q.Code, err)
+			continue
+		}
+		s.logActivity(ctx, "supplier", q.SupplierID, q.Code, "qualification_sample",
+			entity.QualificationStepSample, q.CurrentStep, fmt.Sprintf("样品验证结果: %s", sampling.Status), sampling.VerifiedBy)
+	}
+}
+
+// SignOffRequest 准入会签
+type SignOffRequest struct {
+	Approved bool   `json:"approved"`
+	Level    string `json:"level"` // 批准后的供应商等级，默认 qualified
+	Comment  string `json:"comment"`
+}
+
+// SignOff 准入会签：批准则供应商启用并设定等级，驳回则审核单结束
+func (s *QualificationService) SignOff(ctx context.Context, id, userID string, req *SignOffRequest) (*entity.SupplierQualification, error) {
+	q, err := s.loadStep(ctx, id, entity.QualificationStepSignoff)
+	if err != nil {
+		return nil, err
+	}
+	supplier, err := s.supplierRepo.FindByID(ctx, q.SupplierID)
+	if err != nil {
+		return nil, errors.New("供应商不存在")
+	}
+
+	now := time.Now()
+	q.SignedBy = userID
+	q.SignedAt = &now
+	q.SignoffComment = req.Comment
+
+	if !req.Approved {
+		q.Status = entity.QualificationStatusRejected
+		q.RejectReason = req.Comment
+		if err := s.repo.Update(ctx, q); err != nil {
+			return nil, err
+		}
+		s.logActivity(ctx, "supplier", q.SupplierID, q.Code, "qualification_reject",
+			entity.QualificationStepSignoff, q.Status, "准入会签驳回: "+req.Comment, userID)
+		return q, nil
+	}
+
+	level := req.Level
+	if level == "" {
+		level = entity.SupplierLevelQualified
+	}
+	switch level {
+	case entity.SupplierLevelQualified, entity.SupplierLevelPreferred, entity.SupplierLevelStrategic:
+	default:
+		return nil, fmt.Errorf("无效的准入等级: %s", level)
+	}
+
+	expired, err := s.repo.CountExpiredCertificates(ctx, q.SupplierID, now)
+	if err != nil {
+		return nil, err
+	}
+	if expired > 0 {
+		return nil, fmt.Errorf("供应商有 %d 张资质证书已过期，请更新后再会签", expired)
+	}
+
+	q.Status = entity.QualificationStatusQualified
+	q.TargetLevel = level
+	if err := s.repo.Update(ctx, q); err != nil {
+		return nil, err
+	}
+
+	fromStatus := supplier.Status
+	if err := s.supplierRepo.UpdateLevel(ctx, supplier.ID, level); err != nil {
+		return nil, err
+	}
+	if err := s.supplierRepo.UpdateStatus(ctx, supplier.ID, entity.SupplierStatusActive); err != nil {
+		return nil, err
+	}
+
+	s.logActivity(ctx, "supplier", supplier.ID, supplier.Code, "qualification_approve",
+		fromStatus, entity.SupplierStatusActive, fmt.Sprintf("准入会签通过（%s），等级: %s", q.Code, level), userID)
+	return q, nil
+}
+
+// CreateCertificateRequest 新增资质证书
+type CreateCertificateRequest struct {
+	CertType  string     `json:"cert_type" binding:"required"`
+	CertNo    string     `json:"cert_no"`
+	Issuer    string     `json:"issuer"`
+	IssuedAt  *time.Time `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at" binding:"required"`
+	FileURL   string     `json:"file_url"`
+	Notes     string     `json:"notes"`
+}
+
+// ListCertificates 供应商资质证书
+func (s *QualificationService) ListCertificates(ctx context.Context, supplierID string) ([]entity.SupplierCertificate, error) {
+	return s.repo.FindCertificates(ctx, supplierID)
+}
+
+// CreateCertificate 新增证书（同类型旧证书自动标记为已替代）
+func (s *QualificationService) CreateCertificate(ctx context.Context, supplierID, userID string, req *CreateCertificateRequest) (*entity.SupplierCertificate, error) {
+	switch req.CertType {
+	case entity.CertTypeISO9001, entity.CertTypeIATF16949, entity.CertTypeISO14001,
+		entity.CertTypeRoHS, entity.CertTypeREACH, entity.CertTypeOther:
+	default:
+		return nil, fmt.Errorf("无效的证书类型: %s", req.CertType)
+	}
+	if req.IssuedAt != nil && !req.ExpiresAt.After(*req.IssuedAt) {
+		return nil, errors.New("到期日期必须晚于发证日期")
+	}
+	supplier, err := s.supplierRepo.FindByID(ctx, supplierID)
+	if err != nil {
+		return nil, errors.New("供应商不存在")
+	}
+
+	now := time.Now()
+	cert := &entity.SupplierCertificate{
+		ID:         uuid.New().String()[:32],
+		SupplierID: supplierID,
+		CertType:   req.CertType,
+		CertNo:     req.CertNo,
+		Issuer:     req.Issuer,
+		IssuedAt:   req.IssuedAt,
+		ExpiresAt:  req.ExpiresAt,
+		FileURL:    req.FileURL,
+		Status:     certStatusAt(req.ExpiresAt, now),
+		Notes:      req.Notes,
+		CreatedBy:  userID,
+	}
+	if err := s.repo.CreateCertificate(ctx, cert); err != nil {
+		return nil, err
+	}
+
+	s.logActivity(ctx, "supplier", supplierID, supplier.Code, "certificate_add", "",
+		cert.Status, fmt.Sprintf("新增证书 %s %s，有效期至 %s", cert.CertType, cert.CertNo, cert.ExpiresAt.Format("2006-01-02")), userID)
+	return cert, nil
+}
+
+// DeleteCertificate 删除证书
+func (s *QualificationService) DeleteCertificate(ctx context.Context, supplierID, certID string) error {
+	cert, err := s.repo.FindCertificateByID(ctx, certID)
+	if err != nil || cert.SupplierID != supplierID {
+		return repository.ErrNotFound
+	}
+	return s.repo.DeleteCertificate(ctx, certID)
+}
+
+// ListExpiring 指定天数内到期及已过期的证书
+func (s *QualificationService) ListExpiring(ctx context.Context, days int) ([]entity.SupplierCertificate, error) {
+	if days <= 0 {
+		days = entity.CertReminderDays
+	}
+	return s.repo.FindCertificatesExpiringBefore(ctx, time.Now().AddDate(0, 0, days))
+}
+
+// certStatusAt 按到期日计算证书状态
+func certStatusAt(expiresAt, now time.Time) string {
+	if expiresAt.Before(now) {
+		return entity.CertStatusExpired
+	}
+	if expiresAt.Before(now.AddDate(0, 0, entity.CertReminderDays)) {
+		return entity.CertStatusExpiring
+	}
+	return entity.CertStatusValid
+}
+
+// CheckCertificates 证书到期检查：临期证书发送提醒，过期证书标记过期并暂停在用供应商
+func (s *QualificationService) CheckCertificates(ctx context.Context, now time.Time) (reminded, suspended int, err error) {
+	certs, err := s.repo.FindCertificatesExpiringBefore(ctx, now.AddDate(0, 0, entity.CertReminderDays))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range certs {
+		cert := &certs[i]
+		expired := cert.ExpiresAt.Before(now)
+		if !expired && cert.RemindedAt != nil {
+			continue
+		}
+
+		supplier, err := s.supplierRepo.FindByID(ctx, cert.SupplierID)
+		if err != nil {
+			continue
+		}
+
+		if !expired {
+			cert.Status = entity.CertStatusExpiring
+			cert.RemindedAt = &now
+			if err := s.repo.UpdateCertificate(ctx, cert); err != nil {
+				log.Printf("[SRM] 更新证书提醒状态失败: %v", err)
+				continue
+			}
+			reminded++
+			go s.sendCertificateNotification(context.Background(), supplier, *cert, false)
+			continue
+		}
+
+		if cert.Status != entity.CertStatusExpired {
+			cert.Status = entity.CertStatusExpired
+			if err := s.repo.UpdateCertificate(ctx, cert); err != nil {
+				log.Printf("[SRM] 更新证书过期状态失败: %v", err)
+				continue
+			}
+		}
+		if supplier.Status != entity.SupplierStatusActive {
+			continue
+		}
+		if err := s.supplierRepo.UpdateStatus(ctx, supplier.ID, entity.SupplierStatusSuspended); err != nil {
+			log.Printf("[SRM] 暂停供应商失败: %s %v", supplier.Code, err)
+			continue
+		}
+		suspended++
+		s.logActivity(ctx, "supplier", supplier.ID, supplier.Code, "certificate_expired",
+			entity.SupplierStatusActive, entity.SupplierStatusSuspended,
+			fmt.Sprintf("证书 %s %s 已于 %s 过期，供应商自动暂停", cert.CertType, cert.CertNo, cert.ExpiresAt.Format("2006-01-02")), "system")
+		go s.sendCertificateNotification(context.Background(), supplier, *cert, true)
+	}
+	return reminded, suspended, nil
+}
+
+// StartScheduler 启动证书到期检查：启动时及之后每隔 interval 检查一次
+func (s *QualificationService) StartScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		s.runCertificateCheck(ctx, time.Now())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.runCertificateCheck(ctx, now)
+			}
+		}
+	}()
+}
+
+func (s *QualificationService) runCertificateCheck(ctx context.Context, now time.Time) {
+	reminded, suspended, err := s.CheckCertificates(ctx, now)
+	if err != nil {
+		log.Printf("[SRM] 证书到期检查失败: %v", err)
+		return
+	}
+	if reminded > 0 || suspended > 0 {
+		log.Printf("[SRM] 证书到期检查: 提醒 %d 张，暂停供应商 %d 家", reminded, suspended)
+	}
+}
+
+// sendCertificateNotification 发送证书临期/过期飞书通知
+func (s *QualificationService) sendCertificateNotification(ctx context.Context, supplier *entity.Supplier, cert entity.SupplierCertificate, expired bool) {
+	if s.feishuClient == nil {
+		return
+	}
+
+	// 硬编码管理员用户ID（采购+品质负责人）
+	adminUserID := "ou_5b159fc157d4042f1e8088b1ffebb2da"
+
+	rawURL := fmt.Sprintf("http://43.134.86.237:8080/srm/suppliers/%s", supplier.ID)
+	detailURL := fmt.Sprintf("https://applink.feishu.cn/client/web_url/open?url=%s&mode=window", url.QueryEscape(rawURL))
+
+	title := "📄 供应商证书即将到期"
+	template := "orange"
+	action := "请及时联系供应商更新证书"
+	if expired {
+		title = "⛔ 供应商证书已过期"
+		template = "red"
+		action = "供应商已自动暂停，更新证书后可恢复启用"
+	}
+
+	card := feishu.InteractiveCard{
+		Config: &feishu.CardConfig{WideScreenMode: true},
+		Header: &feishu.CardHeader{
+			Title:    feishu.CardText{Tag: "plain_text", Content: title},
+			Template: template,
+		},
+		Elements: []feishu.CardElement{
+			{
+				Tag: "div",
+				Fields: []feishu.CardField{
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**供应商**\n%s", supplier.Name)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**证书类型**\n%s", cert.CertType)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**证书编号**\n%s", cert.CertNo)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**到期日期**\n%s", cert.ExpiresAt.Format("2006-01-02"))}},
+				},
+			},
+			{
+				Tag:  "div",
+				Text: &feishu.CardText{Tag: "lark_md", Content: action},
+			},
+			{Tag: "hr"},
+			{
+				Tag: "action",
+				Actions: []feishu.CardAction{
+					{
+						Tag:  "button",
+						Text: feishu.CardText{Tag: "plain_text", Content: "查看供应商"},
+						Type: "primary",
+						URL:  detailURL,
+					},
+				},
+			},
+		},
+	}
+
+	if err := s.feishuClient.SendUserCard(ctx, adminUserID, card); err != nil {
+		log.Printf("[SRM] 发送飞书证书通知失败: %v", err)
+	} else {
+		log.Printf("[SRM] 飞书证书通知已发送: %s %s", supplier.Code, cert.CertType)
+	}
+}
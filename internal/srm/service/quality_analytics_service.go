@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+)
+
+// QualityAnalyticsService 质量分析服务（Pareto / PPM / 趋势）
+type QualityAnalyticsService struct {
+	defectRepo *repository.DefectRepository
+}
+
+func NewQualityAnalyticsService(defectRepo *repository.DefectRepository) *QualityAnalyticsService {
+	return &QualityAnalyticsService{defectRepo: defectRepo}
+}
+
+// Pareto 分组维度
+const (
+	ParetoByCategory = "category"
+	ParetoByMode     = "mode"
+	ParetoByCause    = "cause"
+	ParetoBySupplier = "supplier"
+	ParetoByMaterial = "material"
+)
+
+// Pareto 数据来源
+const (
+	ParetoSourceInspection = "inspection"
+	ParetoSourceCA         = "8d"
+)
+
+// uncodedKey 未编码/未细分的缺陷归类
+const uncodedKey = "uncoded"
+
+// QualityQuery 质量分析查询参数
+type QualityQuery struct {
+	SupplierID   string `form:"supplier_id"`
+	MaterialCode string `form:"material_code"`
+	Period       string `form:"period"`     // 2026 / 2026-Q1 / 2026-01，与start/end二选一
+	StartDate    string `form:"start_date"` // 2006-01-02
+	EndDate      string `form:"end_date"`   // 2006-01-02，含当天
+	GroupBy      string `form:"group_by"`
+	Source       string `form:"source"`
+	Granularity  string `form:"granularity"` // month/week
+}
+
+// ParetoEntry Pareto条目
+type ParetoEntry struct {
+	Key               string  `json:"key"`
+	Code              string  `json:"code"`
+	Name              string  `json:"name"`
+	Value             float64 `json:"value"`
+	Occurrences       int64   `json:"occurrences"`
+	Percentage        float64 `json:"percentage"`
+	CumulativePercent float64 `json:"cumulative_percent"`
+}
+
+// ParetoResult Pareto分析结果
+type ParetoResult struct {
+	GroupBy string        `json:"group_by"`
+	Source  string        `json:"source"`
+	Total   float64       `json:"total"`
+	Entries []ParetoEntry `json:"entries"`
+}
+
+// SupplierPPM 供应商PPM
+type SupplierPPM struct {
+	SupplierID   string  `json:"supplier_id"`
+	SupplierName string  `json:"supplier_name"`
+	InspectedQty float64 `json:"inspected_qty"`
+	DefectQty    float64 `json:"defect_qty"`
+	PPM          float64 `json:"ppm"`
+	Inspections  int64   `json:"inspections"`
+	Passed       int64   `json:"passed"`
+	PassRate     float64 `json:"pass_rate"`
+}
+
+// QualityTrendEntry 趋势条目
+type QualityTrendEntry struct {
+	Period       string  `json:"period"`
+	InspectedQty float64 `json:"inspected_qty"`
+	DefectQty    float64 `json:"defect_qty"`
+	PPM          float64 `json:"ppm"`
+}
+
+// ParsePeriod 解析评估周期，返回 [start, end)。支持 2026 / 2026-Q1 / 2026-H1 / 2026-01，
+// 以及历史评估里常见的写法 2026Q1、2026 Q1、2026/01、2026-1、202601、2026年1月
+func ParsePeriod(period string) (time.Time, time.Time, error) {
+	invalid := fmt.Errorf("无效的周期: %s", period)
+	p := strings.ToUpper(strings.TrimSpace(period))
+	p = strings.TrimSuffix(p, "月")
+	p = strings.NewReplacer("年", "-", "/", "-", ".", "-", " ", "-", "_", "-").Replace(p)
+	if len(p) == 6 && !strings.ContainsAny(p, "-QH") {
+		p = p[:4] + "-" + p[4:]
+	}
+	if len(p) < 4 {
+		return time.Time{}, time.Time{}, invalid
+	}
+	year, err := strconv.Atoi(p[:4])
+	if err != nil || year < 1900 {
+		return time.Time{}, time.Time{}, invalid
+	}
+	rest := strings.TrimPrefix(p[4:], "-")
+	if rest == "" {
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+		return start, start.AddDate(1, 0, 0), nil
+	}
+	unit, months := rest[:1], 1
+	switch unit {
+	case "Q":
+		months = 3
+	case "H":
+		months = 6
+	default:
+		unit = ""
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(rest, unit))
+	if err != nil || n < 1 || n > 12/months {
+		return time.Time{}, time.Time{}, invalid
+	}
+	start := time.Date(year, time.Month((n-1)*months+1), 1, 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(0, months, 0), nil
+}
+
+// CalcPPM 计算PPM（百万分之不良）
+func CalcPPM(defectQty, inspectedQty float64) float64 {
+	if inspectedQty <= 0 {
+		return 0
+	}
+	return defectQty / inspectedQty * 1e6
+}
+
+// buildFilter 将查询参数转换为仓库过滤条件
+func (s *QualityAnalyticsService) buildFilter(q *QualityQuery) (repository.QualityFilter, error) {
+	f := repository.QualityFilter{
+		SupplierID:   q.SupplierID,
+		MaterialCode: q.MaterialCode,
+	}
+	if q.Period != "" {
+		start, end, err := ParsePeriod(q.Period)
+		if err != nil {
+			return f, err
+		}
+		f.Start, f.End = &start, &end
+		return f, nil
+	}
+	if q.StartDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", q.StartDate, time.Local)
+		if err != nil {
+			return f, errors.New("无效的开始日期")
+		}
+		f.Start = &t
+	}
+	if q.EndDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", q.EndDate, time.Local)
+		if err != nil {
+			return f, errors.New("无效的结束日期")
+		}
+		t = t.AddDate(0, 0, 1)
+		f.End = &t
+	}
+	return f, nil
+}
+
+// Pareto 不良Pareto分析
+func (s *QualityAnalyticsService) Pareto(ctx context.Context, q *QualityQuery) (*ParetoResult, error) {
+	groupBy := q.GroupBy
+	if groupBy == "" {
+		groupBy = ParetoByCategory
+	}
+	source := q.Source
+	if source == "" {
+		source = ParetoSourceInspection
+	}
+	switch groupBy {
+	case ParetoByCategory, ParetoByMode, ParetoByCause, ParetoBySupplier, ParetoByMaterial:
+	default:
+		return nil, fmt.Errorf("无效的分组维度: %s", groupBy)
+	}
+
+	f, err := s.buildFilter(q)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.defectRepo.FindAll(ctx, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	codeMap := make(map[string]entity.DefectCode, len(codes))
+	for _, c := range codes {
+		codeMap[c.ID] = c
+	}
+
+	buckets := make(map[string]*ParetoEntry)
+	add := func(key string, value float64, occurrences int64) {
+		e, ok := buckets[key]
+		if !ok {
+			e = &ParetoEntry{Key: key}
+			buckets[key] = e
+		}
+		e.Value += value
+		e.Occurrences += occurrences
+	}
+
+	switch source {
+	case ParetoSourceInspection:
+		rows, err := s.defectRepo.AggregateDefects(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			add(paretoKey(groupBy, r.DefectCodeID, r.SupplierID, r.MaterialCode, codeMap), r.DefectQty, r.Occurrences)
+		}
+	case ParetoSourceCA:
+		if groupBy == ParetoByMaterial {
+			return nil, errors.New("8D数据不支持按物料分组")
+		}
+		rows, err := s.defectRepo.CountCorrectiveActions(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			add(paretoKey(groupBy, r.DefectCodeID, r.SupplierID, "", codeMap), float64(r.Count), r.Count)
+		}
+	default:
+		return nil, fmt.Errorf("无效的数据来源: %s", source)
+	}
+
+	result := &ParetoResult{GroupBy: groupBy, Source: source}
+	for _, e := range buckets {
+		if c, ok := codeMap[e.Key]; ok {
+			e.Code, e.Name = c.Code, c.Name
+		} else if e.Key == uncodedKey {
+			e.Name = "未分类"
+		} else {
+			e.Code = e.Key
+		}
+		result.Total += e.Value
+		result.Entries = append(result.Entries, *e)
+	}
+
+	sort.Slice(result.Entries, func(i, j int) bool {
+		if result.Entries[i].Value == result.Entries[j].Value {
+			return result.Entries[i].Key < result.Entries[j].Key
+		}
+		return result.Entries[i].Value > result.Entries[j].Value
+	})
+
+	var cumulative float64
+	for i := range result.Entries {
+		if result.Total > 0 {
+			result.Entries[i].Percentage = result.Entries[i].Value / result.Total * 100
+		}
+		cumulative += result.Entries[i].Percentage
+		result.Entries[i].CumulativePercent = cumulative
+	}
+
+	if result.Entries == nil {
+		result.Entries = []ParetoEntry{}
+	}
+	return result, nil
+}
+
+// paretoKey 计算某条记录在指定维度下的分组键
+func paretoKey(groupBy string, defectCodeID *string, supplierID, materialCode string, codeMap map[string]entity.DefectCode) string {
+	switch groupBy {
+	case ParetoBySupplier:
+		if supplierID == "" {
+			return uncodedKey
+		}
+		return supplierID
+	case ParetoByMaterial:
+		if materialCode == "" {
+			return uncodedKey
+		}
+		return materialCode
+	}
+	if defectCodeID == nil {
+		return uncodedKey
+	}
+	return defectAncestorAt(*defectCodeID, groupBy, codeMap)
+}
+
+// defectAncestorAt 沿上级链找到指定层级的代码；记录只编码到更粗层级时归入 uncoded
+func defectAncestorAt(id, level string, codeMap map[string]entity.DefectCode) string {
+	cur, ok := codeMap[id]
+	for ok {
+		if cur.Level == level {
+			return cur.ID
+		}
+		if cur.ParentID == nil {
+			break
+		}
+		cur, ok = codeMap[*cur.ParentID]
+	}
+	return uncodedKey
+}
+
+// SupplierPPM 供应商PPM排名（按PPM降序）
+func (s *QualityAnalyticsService) SupplierPPM(ctx context.Context, q *QualityQuery) ([]SupplierPPM, error) {
+	f, err := s.buildFilter(q)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.defectRepo.SupplierQualityStats(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SupplierPPM, 0, len(stats))
+	for _, st := range stats {
+		item := SupplierPPM{
+			SupplierID:   st.SupplierID,
+			SupplierName: st.SupplierName,
+			InspectedQty: st.InspectedQty,
+			DefectQty:    st.DefectQty,
+			PPM:          CalcPPM(st.DefectQty, st.InspectedQty),
+			Inspections:  st.Inspections,
+			Passed:       st.Passed,
+		}
+		if st.Inspections > 0 {
+			item.PassRate = float64(st.Passed) / float64(st.Inspections) * 100
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].PPM > result[j].PPM })
+	return result, nil
+}
+
+// GetSupplierPPM 获取单个供应商在区间内的PPM统计
+func (s *QualityAnalyticsService) GetSupplierPPM(ctx context.Context, supplierID string, start, end time.Time) (*SupplierPPM, error) {
+	stats, err := s.defectRepo.SupplierQualityStats(ctx, repository.QualityFilter{
+		SupplierID: supplierID,
+		Start:      &start,
+		End:        &end,
+	})
+	if err != nil {
+		return nil, err
+	}
+	item := &SupplierPPM{SupplierID: supplierID}
+	for _, st := range stats {
+		item.SupplierName = st.SupplierName
+		item.InspectedQty += st.InspectedQty
+		item.DefectQty += st.DefectQty
+		item.Inspections += st.Inspections
+		item.Passed += st.Passed
+	}
+	item.PPM = CalcPPM(item.DefectQty, item.InspectedQty)
+	if item.Inspections > 0 {
+		item.PassRate = float64(item.Passed) / float64(item.Inspections) * 100
+	}
+	return item, nil
+}
+
+// Trend 质量趋势（按月/周）
+func (s *QualityAnalyticsService) Trend(ctx context.Context, q *QualityQuery) ([]QualityTrendEntry, error) {
+	f, err := s.buildFilter(q)
+	if err != nil {
+		return nil, err
+	}
+	granularity := q.Granularity
+	if granularity == "" {
+		granularity = "month"
+	}
+	if granularity != "month" && granularity != "week" {
+		return nil, fmt.Errorf("无效的时间粒度: %s", granularity)
+	}
+
+	points, err := s.defectRepo.QualityTrend(ctx, f, granularity)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]QualityTrendEntry, 0, len(points))
+	for _, p := range points {
+		result = append(result, QualityTrendEntry{
+			Period:       p.Period,
+			InspectedQty: p.InspectedQty,
+			DefectQty:    p.DefectQty,
+			PPM:          CalcPPM(p.DefectQty, p.InspectedQty),
+		})
+	}
+	return result, nil
+}
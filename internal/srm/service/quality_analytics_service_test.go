@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParsePeriod 标准周期写法与历史评估里的常见写法
+func TestParsePeriod(t *testing.T) {
+	day := func(y int, m time.Month) time.Time { return time.Date(y, m, 1, 0, 0, 0, 0, time.Local) }
+	cases := []struct {
+		period     string
+		start, end time.Time
+	}{
+		{"2026", day(2026, 1), day(2027, 1)},
+		{"2026-Q1", day(2026, 1), day(2026, 4)},
+		{"2026-q4", day(2026, 10), day(2027, 1)},
+		{"2026Q2", day(2026, 4), day(2026, 7)},
+		{"2026 Q3", day(2026, 7), day(2026, 10)},
+		{"2026-H2", day(2026, 7), day(2027, 1)},
+		{"2026-01", day(2026, 1), day(2026, 2)},
+		{"2026-1", day(2026, 1), day(2026, 2)},
+		{"2026/12", day(2026, 12), day(2027, 1)},
+		{"202603", day(2026, 3), day(2026, 4)},
+		{"2026年3月", day(2026, 3), day(2026, 4)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.period, func(t *testing.T) {
+			start, end, err := ParsePeriod(tc.period)
+			if err != nil {
+				t.Fatalf("ParsePeriod(%q): %v", tc.period, err)
+			}
+			if !start.Equal(tc.start) || !end.Equal(tc.end) {
+				t.Fatalf("ParsePeriod(%q) = [%s, %s), want [%s, %s)", tc.period,
+					start.Format("2006-01-02"), end.Format("2006-01-02"), tc.start.Format("2006-01-02"), tc.end.Format("2006-01-02"))
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "Q1", "2026-Q5", "2026-H3", "2026-13", "2026-00", "上半年"} {
+		if _, _, err := ParsePeriod(bad); err == nil {
+			t.Errorf("ParsePeriod(%q): expected an error", bad)
+		}
+	}
+}
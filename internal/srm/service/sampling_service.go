@@ -15,13 +15,14 @@ import (
 
 // SamplingService 打样服务
 type SamplingService struct {
-	samplingRepo    *repository.SamplingRepository
-	prRepo          *repository.PRRepository
-	supplierRepo    *repository.SupplierRepository
-	activityLogRepo *repository.ActivityLogRepository
-	feishuClient    *feishu.FeishuClient
-	approvalCode    string // 飞书审批定义code（打样验证）
-	db              *gorm.DB
+	samplingRepo     *repository.SamplingRepository
+	prRepo           *repository.PRRepository
+	supplierRepo     *repository.SupplierRepository
+	activityLogRepo  *repository.ActivityLogRepository
+	feishuClient     *feishu.FeishuClient
+	approvalCode     string // 飞书审批定义code（打样验证）
+	db               *gorm.DB
+	qualificationSvc *QualificationService
 }
 
 func NewSamplingService(
@@ -45,6 +46,11 @@ func (s *SamplingService) SetFeishuClient(fc *feishu.FeishuClient) {
 	s.feishuClient = fc
 }
 
+// SetQualificationService 注入准入服务（打样验证结果推进样品承认）
+func (s *SamplingService) SetQualificationService(svc *QualificationService) {
+	s.qualificationSvc = svc
+}
+
 // SetApprovalCode 设置打样验证审批定义code
 func (s *SamplingService) SetApprovalCode(code string) {
 	s.approvalCode = code
@@ -184,6 +190,9 @@ func (s *SamplingService) UpdateSamplingStatus(
 	if err := s.samplingRepo.Update(ctx, sampling); err != nil {
 		return nil, fmt.Errorf("更新打样状态失败: %w", err)
 	}
+	if s.qualificationSvc != nil && (req.Status == entity.SamplingStatusPassed || req.Status == entity.SamplingStatusFailed) {
+		s.qualificationSvc.OnSampleVerified(ctx, sampling)
+	}
 
 	// 记录操作日志
 	if s.activityLogRepo != nil {
@@ -312,7 +321,13 @@ func (s *SamplingService) HandleVerifyCallback(ctx context.Context, instanceCode
 		}
 	}
 
-	return s.samplingRepo.Update(ctx, sampling)
+	if err := s.samplingRepo.Update(ctx, sampling); err != nil {
+		return err
+	}
+	if s.qualificationSvc != nil {
+		s.qualificationSvc.OnSampleVerified(ctx, sampling)
+	}
+	return nil
 }
 
 // EnsureApprovalDefinition 确保打样验证审批定义存在
@@ -0,0 +1,729 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bitfantasy/nimo/internal/shared/feishu"
+	"github.com/bitfantasy/nimo/internal/srm/entity"
+	"github.com/bitfantasy/nimo/internal/srm/repository"
+	"github.com/google/uuid"
+)
+
+// ScorecardService 供应商评分卡服务（KPI取数、评分、周期批量评估）
+type ScorecardService struct {
+	repo            *repository.ScorecardRepository
+	evalRepo        *repository.EvaluationRepository
+	supplierRepo    *repository.SupplierRepository
+	qualitySvc      *QualityAnalyticsService
+	activityLogRepo *repository.ActivityLogRepository
+	feishuClient    *feishu.FeishuClient
+}
+
+func NewScorecardService(repo *repository.ScorecardRepository, evalRepo *repository.EvaluationRepository, supplierRepo *repository.SupplierRepository) *ScorecardService {
+	return &ScorecardService{repo: repo, evalRepo: evalRepo, supplierRepo: supplierRepo}
+}
+
+// SetQualityAnalyticsService 注入质量分析服务（PPM取数）
+func (s *ScorecardService) SetQualityAnalyticsService(svc *QualityAnalyticsService) {
+	s.qualitySvc = svc
+}
+
+// SetActivityLogRepo 注入操作日志仓库（记录等级变动）
+func (s *ScorecardService) SetActivityLogRepo(repo *repository.ActivityLogRepository) {
+	s.activityLogRepo = repo
+}
+
+// SetFeishuClient 注入飞书客户端（等级变动通知）
+func (s *ScorecardService) SetFeishuClient(fc *feishu.FeishuClient) {
+	s.feishuClient = fc
+}
+
+// ScorecardKPIInput 评分卡指标
+type ScorecardKPIInput struct {
+	Code        string   `json:"code" binding:"required"`
+	Name        string   `json:"name" binding:"required"`
+	Dimension   string   `json:"dimension" binding:"required"`
+	DataSource  string   `json:"data_source" binding:"required"`
+	Weight      float64  `json:"weight" binding:"required"`
+	TargetValue *float64 `json:"target_value"`
+	ZeroValue   *float64 `json:"zero_value"`
+	SortOrder   int      `json:"sort_order"`
+}
+
+// SaveScorecardRequest 创建/更新评分卡请求（指标整体替换，权重合计须为100）
+type SaveScorecardRequest struct {
+	Name        string              `json:"name" binding:"required"`
+	Category    string              `json:"category"`
+	Frequency   string              `json:"frequency"`
+	Description string              `json:"description"`
+	Status      string              `json:"status"`
+	KPIs        []ScorecardKPIInput `json:"kpis" binding:"required,min=1"`
+}
+
+// RunScorecardRequest 手工触发周期评估请求
+type RunScorecardRequest struct {
+	Period   string `json:"period" binding:"required"`
+	EvalType string `json:"eval_type"`
+}
+
+// List 评分卡列表
+func (s *ScorecardService) List(ctx context.Context, filters map[string]string) ([]entity.Scorecard, error) {
+	return s.repo.FindAll(ctx, filters)
+}
+
+// Get 评分卡详情
+func (s *ScorecardService) Get(ctx context.Context, id string) (*entity.Scorecard, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// Create 创建评分卡
+func (s *ScorecardService) Create(ctx context.Context, userID string, req *SaveScorecardRequest) (*entity.Scorecard, error) {
+	sc := &entity.Scorecard{
+		ID:        uuid.New().String()[:32],
+		CreatedBy: userID,
+	}
+	if err := s.applyRequest(ctx, sc, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, sc); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, sc.ID)
+}
+
+// Update 更新评分卡
+func (s *ScorecardService) Update(ctx context.Context, id string, req *SaveScorecardRequest) (*entity.Scorecard, error) {
+	sc, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyRequest(ctx, sc, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, sc); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, sc.ID)
+}
+
+// Delete 删除评分卡（历史评估保留KPI明细快照）
+func (s *ScorecardService) Delete(ctx context.Context, id string) error {
+	if _, err := s.repo.FindByID(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// applyRequest 校验并写入评分卡字段与指标
+func (s *ScorecardService) applyRequest(ctx context.Context, sc *entity.Scorecard, req *SaveScorecardRequest) error {
+	frequency := req.Frequency
+	if frequency == "" {
+		frequency = entity.EvalTypeQuarterly
+	}
+	if frequency != entity.EvalTypeMonthly && frequency != entity.EvalTypeQuarterly {
+		return fmt.Errorf("无效的评估周期: %s", frequency)
+	}
+	status := req.Status
+	if status == "" {
+		status = entity.ScorecardStatusActive
+	}
+	if status != entity.ScorecardStatusActive && status != entity.ScorecardStatusInactive {
+		return fmt.Errorf("无效的状态: %s", status)
+	}
+	if status == entity.ScorecardStatusActive {
+		count, err := s.repo.CountActiveInCategory(ctx, req.Category, sc.ID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("该供应商分类已有启用的评分卡")
+		}
+	}
+
+	var totalWeight float64
+	codes := make(map[string]bool)
+	kpis := make([]entity.ScorecardKPI, 0, len(req.KPIs))
+	for _, in := range req.KPIs {
+		switch in.Dimension {
+		case entity.KPIDimensionQuality, entity.KPIDimensionDelivery, entity.KPIDimensionPrice, entity.KPIDimensionService:
+		default:
+			return fmt.Errorf("指标 %s 的维度无效: %s", in.Code, in.Dimension)
+		}
+		defaults, ok := entity.KPISourceDefaults[in.DataSource]
+		if !ok {
+			return fmt.Errorf("指标 %s 的数据源无效: %s", in.Code, in.DataSource)
+		}
+		if in.Weight <= 0 {
+			return fmt.Errorf("指标 %s 的权重必须大于0", in.Code)
+		}
+		if codes[in.Code] {
+			return fmt.Errorf("指标编码重复: %s", in.Code)
+		}
+		codes[in.Code] = true
+
+		kpi := entity.ScorecardKPI{
+			ID:          uuid.New().String()[:32],
+			ScorecardID: sc.ID,
+			Code:        in.Code,
+			Name:        in.Name,
+			Dimension:   in.Dimension,
+			DataSource:  in.DataSource,
+			Weight:      in.Weight,
+			TargetValue: defaults[0],
+			ZeroValue:   defaults[1],
+			SortOrder:   in.SortOrder,
+		}
+		if in.TargetValue != nil {
+			kpi.TargetValue = *in.TargetValue
+		}
+		if in.ZeroValue != nil {
+			kpi.ZeroValue = *in.ZeroValue
+		}
+		if kpi.TargetValue == kpi.ZeroValue {
+			return fmt.Errorf("指标 %s 的满分值与零分值不能相同", in.Code)
+		}
+		totalWeight += in.Weight
+		kpis = append(kpis, kpi)
+	}
+	if math.Abs(totalWeight-100) > 0.01 {
+		return fmt.Errorf("指标权重合计须为100，当前为 %.2f", totalWeight)
+	}
+
+	sc.Name = req.Name
+	sc.Category = req.Category
+	sc.Frequency = frequency
+	sc.Description = req.Description
+	sc.Status = status
+	sc.KPIs = kpis
+	return nil
+}
+
+// ========== 评分 ==========
+
+// kpiMeasurement 单个KPI的取数结果
+type kpiMeasurement struct {
+	value  *float64
+	sample int
+	detail string
+}
+
+// evalContext 单个供应商单周期的取数缓存，同一数据源只查询一次
+type evalContext struct {
+	supplierID string
+	start, end time.Time
+	cache      map[string]kpiMeasurement
+
+	totalPOs, onTimePOs       int
+	qualityTotal, qualityPass int
+}
+
+// measure 按数据源取数
+func (s *ScorecardService) measure(ctx context.Context, ec *evalContext, source string) (kpiMeasurement, error) {
+	if m, ok := ec.cache[source]; ok {
+		return m, nil
+	}
+	var m kpiMeasurement
+	switch source {
+	case entity.KPISourceOTD:
+		total, onTime, err := s.repo.OTDStats(ctx, ec.supplierID, ec.start, ec.end)
+		if err != nil {
+			return m, err
+		}
+		ec.totalPOs, ec.onTimePOs = int(total), int(onTime)
+		m.sample = int(total)
+		if total > 0 {
+			v := float64(onTime) / float64(total) * 100
+			m.value = &v
+			m.detail = fmt.Sprintf("到货PO %d 单，准时 %d 单", total, onTime)
+		}
+	case entity.KPISourcePPM:
+		if s.qualitySvc == nil {
+			break
+		}
+		stat, err := s.qualitySvc.GetSupplierPPM(ctx, ec.supplierID, ec.start, ec.end)
+		if err != nil {
+			return m, err
+		}
+		ec.qualityTotal, ec.qualityPass = int(stat.Inspections), int(stat.Passed)
+		m.sample = int(stat.Inspections)
+		if stat.InspectedQty > 0 {
+			v := stat.PPM
+			m.value = &v
+			m.detail = fmt.Sprintf("来料检验 %.0f 件，不良 %.0f 件", stat.InspectedQty, stat.DefectQty)
+		}
+	case entity.KPISource8DResponse:
+		asOf := time.Now()
+		if ec.end.Before(asOf) {
+			asOf = ec.end
+		}
+		avg, count, err := s.repo.CAResponseHours(ctx, ec.supplierID, ec.start, ec.end, asOf)
+		if err != nil {
+			return m, err
+		}
+		m.sample = int(count)
+		if count > 0 {
+			v := avg
+			m.value = &v
+			m.detail = fmt.Sprintf("8D %d 份，平均响应 %.1f 小时", count, avg)
+		}
+	case entity.KPISourcePriceVariance:
+		avg, count, err := s.repo.PriceVariance(ctx, ec.supplierID, ec.start, ec.end)
+		if err != nil {
+			return m, err
+		}
+		m.sample = int(count)
+		if count > 0 {
+			v := avg
+			m.value = &v
+			m.detail = fmt.Sprintf("PO行 %d 条，相对中标价平均偏差 %.2f%%", count, avg)
+		}
+	case entity.KPISourceManual:
+		m.detail = "待人工评分"
+	}
+	ec.cache[source] = m
+	return m, nil
+}
+
+// Evaluate 按评分卡计算供应商某周期的评估单（未落库）
+// 无数据的KPI不计分，其权重在有数据的KPI间按比例重新分配
+func (s *ScorecardService) Evaluate(ctx context.Context, sc *entity.Scorecard, supplierID, period, evalType string) (*entity.SupplierEvaluation, []entity.EvaluationKPIResult, error) {
+	start, end, err := ParsePeriod(period)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scID := sc.ID
+	eval := &entity.SupplierEvaluation{
+		ID:          uuid.New().String()[:32],
+		SupplierID:  supplierID,
+		Period:      period,
+		EvalType:    evalType,
+		ScorecardID: &scID,
+		Status:      entity.EvalStatusDraft,
+	}
+
+	ec := &evalContext{supplierID: supplierID, start: start, end: end, cache: make(map[string]kpiMeasurement)}
+	results := make([]entity.EvaluationKPIResult, 0, len(sc.KPIs))
+	var notes []string
+	for _, kpi := range sc.KPIs {
+		m, err := s.measure(ctx, ec, kpi.DataSource)
+		if err != nil {
+			return nil, nil, err
+		}
+		result := entity.EvaluationKPIResult{
+			ID:           uuid.New().String()[:32],
+			EvaluationID: eval.ID,
+			KPIID:        kpi.ID,
+			Code:         kpi.Code,
+			Name:         kpi.Name,
+			Dimension:    kpi.Dimension,
+			DataSource:   kpi.DataSource,
+			Weight:       kpi.Weight,
+			RawValue:     m.value,
+			SampleSize:   m.sample,
+			Detail:       m.detail,
+		}
+		if m.value != nil {
+			score := math.Round(entity.CalcKPIScore(*m.value, kpi.TargetValue, kpi.ZeroValue)*100) / 100
+			result.Score = &score
+		}
+		if m.detail != "" {
+			notes = append(notes, kpi.Name+": "+m.detail)
+		}
+		results = append(results, result)
+	}
+
+	eval.TotalPOs, eval.OnTimePOs = ec.totalPOs, ec.onTimePOs
+	eval.QualityTotal, eval.QualityPassed = ec.qualityTotal, ec.qualityPass
+	eval.Remarks = strings.Join(notes, "；")
+	applyKPIResults(eval, results)
+	return eval, results, nil
+}
+
+// applyKPIResults 由KPI明细汇总维度得分、维度权重、综合得分与等级
+func applyKPIResults(eval *entity.SupplierEvaluation, results []entity.EvaluationKPIResult) {
+	type agg struct{ weight, scoredWeight, weighted float64 }
+	dims := make(map[string]*agg)
+	var scoredWeight, weighted, totalWeight float64
+	for _, r := range results {
+		d, ok := dims[r.Dimension]
+		if !ok {
+			d = &agg{}
+			dims[r.Dimension] = d
+		}
+		d.weight += r.Weight
+		totalWeight += r.Weight
+		if r.Score != nil {
+			d.scoredWeight += r.Weight
+			d.weighted += *r.Score * r.Weight
+			scoredWeight += r.Weight
+			weighted += *r.Score * r.Weight
+		}
+	}
+
+	dimScore := func(dim string) *float64 {
+		d := dims[dim]
+		if d == nil || d.scoredWeight == 0 {
+			return nil
+		}
+		v := math.Round(d.weighted/d.scoredWeight*100) / 100
+		return &v
+	}
+	dimWeight := func(dim string) float64 {
+		d := dims[dim]
+		if d == nil || totalWeight == 0 {
+			return 0
+		}
+		return math.Round(d.weight/totalWeight*100) / 100
+	}
+	eval.QualityScore, eval.QualityWeight = dimScore(entity.KPIDimensionQuality), dimWeight(entity.KPIDimensionQuality)
+	eval.DeliveryScore, eval.DeliveryWeight = dimScore(entity.KPIDimensionDelivery), dimWeight(entity.KPIDimensionDelivery)
+	eval.PriceScore, eval.PriceWeight = dimScore(entity.KPIDimensionPrice), dimWeight(entity.KPIDimensionPrice)
+	eval.ServiceScore, eval.ServiceWeight = dimScore(entity.KPIDimensionService), dimWeight(entity.KPIDimensionService)
+
+	eval.TotalScore = nil
+	eval.Grade = ""
+	if scoredWeight > 0 {
+		total := math.Round(weighted/scoredWeight*100) / 100
+		eval.TotalScore = &total
+		eval.Grade = entity.CalcGrade(total)
+	}
+}
+
+// GenerateEvaluation 按适用评分卡为供应商生成评估单；供应商分类无可用评分卡时返回 nil, nil
+func (s *ScorecardService) GenerateEvaluation(ctx context.Context, supplierID, period, evalType, userID string) (*entity.SupplierEvaluation, error) {
+	supplier, err := s.supplierRepo.FindByID(ctx, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := s.repo.FindForCategory(ctx, supplier.Category)
+	if err != nil || sc == nil {
+		return nil, err
+	}
+	if evalType == "" {
+		evalType = sc.Frequency
+	}
+
+	eval, results, err := s.Evaluate(ctx, sc, supplierID, period, evalType)
+	if err != nil {
+		return nil, err
+	}
+	eval.EvaluatorID = userID
+	if eval.PrevGrade, err = s.evalRepo.FindLatestGrade(ctx, supplierID, eval.ID); err != nil {
+		return nil, err
+	}
+	if err := s.evalRepo.CreateWithResults(ctx, eval, results); err != nil {
+		return nil, err
+	}
+	return s.evalRepo.FindByID(ctx, eval.ID)
+}
+
+// UpdateKPIScore 人工修改KPI得分（草稿评估），并重新汇总
+func (s *ScorecardService) UpdateKPIScore(ctx context.Context, evalID, resultID string, score float64) (*entity.SupplierEvaluation, error) {
+	if score < 0 || score > 100 {
+		return nil, errors.New("得分须在0-100之间")
+	}
+	eval, err := s.evalRepo.FindByID(ctx, evalID)
+	if err != nil {
+		return nil, err
+	}
+	if eval.Status != entity.EvalStatusDraft {
+		return nil, errors.New("只能修改草稿状态的评估")
+	}
+
+	var target *entity.EvaluationKPIResult
+	for i := range eval.KPIResults {
+		if eval.KPIResults[i].ID == resultID {
+			target = &eval.KPIResults[i]
+		}
+	}
+	if target == nil {
+		return nil, repository.ErrNotFound
+	}
+	target.Score = &score
+	if err := s.evalRepo.UpdateKPIResult(ctx, target); err != nil {
+		return nil, err
+	}
+
+	applyKPIResults(eval, eval.KPIResults)
+	if err := s.evalRepo.Update(ctx, eval); err != nil {
+		return nil, err
+	}
+	return s.evalRepo.FindByID(ctx, evalID)
+}
+
+// ========== 周期批量评估 ==========
+
+// RunPeriod 为全部合作中供应商生成指定周期的评估（自动审批），并回写供应商评分与等级
+// 仅评估适用评分卡周期与 evalType 一致的供应商；已有该周期评估的供应商跳过
+func (s *ScorecardService) RunPeriod(ctx context.Context, period, evalType, trigger string) (*entity.ScorecardRun, error) {
+	if evalType == "" {
+		evalType = entity.EvalTypeQuarterly
+	}
+	if _, _, err := ParsePeriod(period); err != nil {
+		return nil, err
+	}
+	if existing, err := s.repo.FindRun(ctx, period, evalType); err == nil {
+		if existing.Status != entity.ScorecardRunFailed {
+			return nil, fmt.Errorf("周期 %s(%s) 已运行", period, evalType)
+		}
+		// 失败的运行允许重跑
+		existing.Status = entity.ScorecardRunRunning
+		existing.Trigger = trigger
+		existing.ErrorMessage = ""
+		existing.StartedAt = time.Now()
+		existing.FinishedAt = nil
+		if err := s.repo.UpdateRun(ctx, existing); err != nil {
+			return nil, err
+		}
+		return s.execute(ctx, existing)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	run := &entity.ScorecardRun{
+		ID:        uuid.New().String()[:32],
+		Period:    period,
+		EvalType:  evalType,
+		Status:    entity.ScorecardRunRunning,
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+	}
+	if err := s.repo.CreateRun(ctx, run); err != nil {
+		return nil, fmt.Errorf("创建运行记录失败（可能已在运行）: %w", err)
+	}
+	return s.execute(ctx, run)
+}
+
+func (s *ScorecardService) execute(ctx context.Context, run *entity.ScorecardRun) (*entity.ScorecardRun, error) {
+	runErr := s.evaluateAll(ctx, run)
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Status = entity.ScorecardRunCompleted
+	if runErr != nil {
+		run.Status = entity.ScorecardRunFailed
+		run.ErrorMessage = runErr.Error()
+	}
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, runErr
+}
+
+func (s *ScorecardService) evaluateAll(ctx context.Context, run *entity.ScorecardRun) error {
+	suppliers, err := s.supplierRepo.FindByStatus(ctx, entity.SupplierStatusActive)
+	if err != nil {
+		return err
+	}
+
+	cards := make(map[string]*entity.Scorecard)
+	var failures []string
+	for i := range suppliers {
+		supplier := &suppliers[i]
+		sc, ok := cards[supplier.Category]
+		if !ok {
+			if sc, err = s.repo.FindForCategory(ctx, supplier.Category); err != nil {
+				return err
+			}
+			cards[supplier.Category] = sc
+		}
+		if sc == nil || sc.Frequency != run.EvalType {
+			continue
+		}
+		run.SupplierCount++
+
+		if _, err := s.evalRepo.FindByPeriod(ctx, supplier.ID, run.Period, run.EvalType); err == nil {
+			continue
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		changed, err := s.evaluateSupplier(ctx, sc, supplier, run)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", supplier.Code, err))
+			continue
+		}
+		run.CreatedCount++
+		if changed {
+			run.GradeChanges++
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d 家供应商评估失败: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// evaluateSupplier 生成并审批单个供应商的周期评估，返回等级是否变动
+func (s *ScorecardService) evaluateSupplier(ctx context.Context, sc *entity.Scorecard, supplier *entity.Supplier, run *entity.ScorecardRun) (bool, error) {
+	eval, results, err := s.Evaluate(ctx, sc, supplier.ID, run.Period, run.EvalType)
+	if err != nil {
+		return false, err
+	}
+	eval.EvaluatorID = "system"
+	eval.Status = entity.EvalStatusApproved
+	if eval.PrevGrade, err = s.evalRepo.FindLatestGrade(ctx, supplier.ID, eval.ID); err != nil {
+		return false, err
+	}
+	if err := s.evalRepo.CreateWithResults(ctx, eval, results); err != nil {
+		return false, err
+	}
+	if eval.TotalScore == nil {
+		// 周期内无任何数据，不回写供应商
+		return false, nil
+	}
+
+	if err := s.supplierRepo.UpdateScores(ctx, supplier.ID,
+		derefScore(eval.QualityScore, supplier.QualityScore),
+		derefScore(eval.DeliveryScore, supplier.DeliveryScore),
+		derefScore(eval.PriceScore, supplier.PriceScore),
+		*eval.TotalScore); err != nil {
+		return false, err
+	}
+
+	newLevel := entity.LevelForGrade(eval.Grade, supplier.Level)
+	if newLevel != supplier.Level {
+		if err := s.supplierRepo.UpdateLevel(ctx, supplier.ID, newLevel); err != nil {
+			return false, err
+		}
+	}
+
+	if eval.PrevGrade == "" || eval.PrevGrade == eval.Grade {
+		return false, nil
+	}
+	content := fmt.Sprintf("%s 评估等级 %s → %s（综合 %.2f 分），供应商等级 %s → %s",
+		run.Period, eval.PrevGrade, eval.Grade, *eval.TotalScore, supplier.Level, newLevel)
+	if s.activityLogRepo != nil {
+		s.activityLogRepo.LogActivity(ctx, "supplier", supplier.ID, supplier.Code, "grade_change",
+			eval.PrevGrade, eval.Grade, content, "system", "系统")
+	}
+	go s.sendGradeChangeNotification(context.Background(), supplier, eval, newLevel)
+	return true, nil
+}
+
+// derefScore 维度无数据时沿用供应商原有得分
+func derefScore(v, fallback *float64) float64 {
+	if v != nil {
+		return *v
+	}
+	if fallback != nil {
+		return *fallback
+	}
+	return 0
+}
+
+// PreviousPeriod 返回 now 所在周期的上一个完整周期，如 2026-03-05 → 2026-02 / 2025-Q4
+func PreviousPeriod(now time.Time, evalType string) string {
+	if evalType == entity.EvalTypeMonthly {
+		prev := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+		return prev.Format("2006-01")
+	}
+	quarterStart := time.Date(now.Year(), time.Month((int(now.Month())-1)/3*3+1), 1, 0, 0, 0, 0, now.Location())
+	prev := quarterStart.AddDate(0, -3, 0)
+	return fmt.Sprintf("%d-Q%d", prev.Year(), (int(prev.Month())-1)/3+1)
+}
+
+// RunDue 补跑所有到期未运行的周期（上一个完整月份/季度）
+func (s *ScorecardService) RunDue(ctx context.Context, now time.Time) {
+	for _, evalType := range []string{entity.EvalTypeMonthly, entity.EvalTypeQuarterly} {
+		period := PreviousPeriod(now, evalType)
+		if _, err := s.repo.FindRun(ctx, period, evalType); err == nil {
+			continue
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("[SRM] 查询评分卡运行记录失败: %v", err)
+			continue
+		}
+		run, err := s.RunPeriod(ctx, period, evalType, "schedule")
+		if err != nil {
+			log.Printf("[SRM] 供应商周期评估 %s(%s) 失败: %v", period, evalType, err)
+			continue
+		}
+		log.Printf("[SRM] 供应商周期评估 %s(%s) 完成: 评估 %d 家，等级变动 %d 家",
+			period, evalType, run.CreatedCount, run.GradeChanges)
+	}
+}
+
+// StartScheduler 启动周期评估调度：启动时及之后每隔 interval 检查一次到期周期
+func (s *ScorecardService) StartScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		s.RunDue(ctx, time.Now())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.RunDue(ctx, now)
+			}
+		}
+	}()
+}
+
+// ListRuns 最近的运行记录
+func (s *ScorecardService) ListRuns(ctx context.Context) ([]entity.ScorecardRun, error) {
+	return s.repo.ListRuns(ctx, 50)
+}
+
+// sendGradeChangeNotification 发送供应商等级变动飞书通知
+func (s *ScorecardService) sendGradeChangeNotification(ctx context.Context, supplier *entity.Supplier, eval *entity.SupplierEvaluation, newLevel string) {
+	if s.feishuClient == nil {
+		return
+	}
+
+	// 硬编码管理员用户ID（采购+品质负责人）
+	adminUserID := "ou_5b159fc157d4042f1e8088b1ffebb2da"
+
+	rawURL := "http://43.134.86.237:8080/srm/evaluations"
+	detailURL := fmt.Sprintf("https://applink.feishu.cn/client/web_url/open?url=%s&mode=window", url.QueryEscape(rawURL))
+
+	template := "green"
+	if eval.Grade > eval.PrevGrade {
+		template = "red"
+	}
+
+	card := feishu.InteractiveCard{
+		Config: &feishu.CardConfig{WideScreenMode: true},
+		Header: &feishu.CardHeader{
+			Title:    feishu.CardText{Tag: "plain_text", Content: "📊 供应商等级变动"},
+			Template: template,
+		},
+		Elements: []feishu.CardElement{
+			{
+				Tag: "div",
+				Fields: []feishu.CardField{
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**供应商**\n%s", supplier.Name)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**评估周期**\n%s", eval.Period)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**评估等级**\n%s → %s", eval.PrevGrade, eval.Grade)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**综合得分**\n%.2f", *eval.TotalScore)}},
+					{IsShort: true, Text: feishu.CardText{Tag: "lark_md", Content: fmt.Sprintf("**供应商等级**\n%s → %s", supplier.Level, newLevel)}},
+				},
+			},
+			{Tag: "hr"},
+			{
+				Tag: "action",
+				Actions: []feishu.CardAction{
+					{
+						Tag:  "button",
+						Text: feishu.CardText{Tag: "plain_text", Content: "查看评估详情"},
+						Type: "primary",
+						URL:  detailURL,
+					},
+				},
+			},
+		},
+	}
+
+	if err := s.feishuClient.SendUserCard(ctx, adminUserID, card); err != nil {
+		log.Printf("[SRM] 发送飞书等级变动通知失败: %v", err)
+	} else {
+		log.Printf("[SRM] 飞书等级变动通知已发送: %s %s→%s", supplier.Code, eval.PrevGrade, eval.Grade)
+	}
+}
@@ -3,6 +3,10 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/bitfantasy/nimo/internal/srm/entity"
@@ -12,13 +16,19 @@ import (
 
 // SettlementService 结算服务
 type SettlementService struct {
-	repo *repository.SettlementRepository
+	repo  *repository.SettlementRepository
+	fxSvc *ExchangeRateService
 }
 
 func NewSettlementService(repo *repository.SettlementRepository) *SettlementService {
 	return &SettlementService{repo: repo}
 }
 
+// SetExchangeRateService 注入汇率服务（本位币折算与汇兑损益）
+func (s *SettlementService) SetExchangeRateService(svc *ExchangeRateService) {
+	s.fxSvc = svc
+}
+
 // CreateSettlementRequest 创建对账单请求
 type CreateSettlementRequest struct {
 	SupplierID  string  `json:"supplier_id" binding:"required"`
@@ -43,6 +53,7 @@ type GenerateSettlementRequest struct {
 	SupplierID  string `json:"supplier_id" binding:"required"`
 	PeriodStart string `json:"period_start" binding:"required"`
 	PeriodEnd   string `json:"period_end" binding:"required"`
+	Currency    string `json:"currency"` // 期间内PO涉及多个币种时必填，按币种分别出对账单
 }
 
 // CreateDisputeRequest 创建差异记录请求
@@ -126,12 +137,9 @@ func (s *SettlementService) Generate(ctx context.Context, userID string, req *Ge
 	if err != nil {
 		return nil, err
 	}
-
-	var poAmount float64
-	for _, po := range pos {
-		if po.TotalAmount != nil {
-			poAmount += *po.TotalAmount
-		}
+	currency, pos, err := filterPOsByCurrency(pos, req.Currency)
+	if err != nil {
+		return nil, err
 	}
 
 	code, err := s.repo.GenerateCode(ctx)
@@ -139,27 +147,189 @@ func (s *SettlementService) Generate(ctx context.Context, userID string, req *Ge
 		return nil, err
 	}
 
-	finalAmount := poAmount
+	settlementID := uuid.New().String()[:32]
+	lines, err := s.buildLines(ctx, settlementID, pos, &periodStart, &periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var poAmount, receivedAmount float64
+	for _, line := range lines {
+		poAmount += line.OrderedQty * line.UnitPrice
+		receivedAmount += line.ReceivedAmount
+	}
+
+	finalAmount := receivedAmount
 	settlement := &entity.Settlement{
-		ID:             uuid.New().String()[:32],
+		ID:             settlementID,
 		SettlementCode: code,
 		SupplierID:     req.SupplierID,
 		PeriodStart:    &periodStart,
 		PeriodEnd:      &periodEnd,
-		Status:         "draft",
+		Status:         entity.SettlementStatusDraft,
 		POAmount:       &poAmount,
-		ReceivedAmount: &poAmount,
+		ReceivedAmount: &receivedAmount,
 		FinalAmount:    &finalAmount,
-		Currency:       "CNY",
+		Currency:       currency,
 		CreatedBy:      userID,
 	}
+	if err := s.applyFX(ctx, settlement, lines, time.Now()); err != nil {
+		return nil, err
+	}
 
-	if err := s.repo.Create(ctx, settlement); err != nil {
+	if err := s.repo.CreateWithLines(ctx, settlement, lines, nil); err != nil {
 		return nil, err
 	}
 	return s.repo.FindByID(ctx, settlement.ID)
 }
 
+// buildLines 按PO行项生成对账行，收货数量取对账期间内的质检入库流水，无流水时回退到PO行项已收数量
+func (s *SettlementService) buildLines(ctx context.Context, settlementID string, pos []entity.PurchaseOrder, periodStart, periodEnd *time.Time) ([]entity.SettlementLine, error) {
+	var itemIDs []string
+	for _, po := range pos {
+		for _, item := range po.Items {
+			itemIDs = append(itemIDs, item.ID)
+		}
+	}
+	received, err := s.repo.SumReceivedByPOItems(ctx, itemIDs, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []entity.SettlementLine
+	for _, po := range pos {
+		lockedRate, err := s.poLockedRate(ctx, &po)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range po.Items {
+			price := 0.0
+			if item.UnitPrice != nil {
+				price = *item.UnitPrice
+			}
+			recQty, ok := received[item.ID]
+			if !ok {
+				recQty = item.ReceivedQty
+			}
+			lines = append(lines, entity.SettlementLine{
+				ID:             uuid.New().String()[:32],
+				SettlementID:   settlementID,
+				POID:           po.ID,
+				POCode:         po.POCode,
+				POItemID:       item.ID,
+				MaterialCode:   item.MaterialCode,
+				MaterialName:   item.MaterialName,
+				Unit:           item.Unit,
+				OrderedQty:     item.Quantity,
+				UnitPrice:      price,
+				LockedRate:     lockedRate,
+				ReceivedQty:    recQty,
+				ReceivedAmount: roundAmount(recQty * price),
+				BookedAmount:   roundAmount(recQty * price * lockedRate),
+				MatchStatus:    entity.MatchStatusPending,
+			})
+		}
+	}
+	return lines, nil
+}
+
+// filterPOsByCurrency 按币种筛选PO；未指定币种时要求期间内PO为单一币种
+func filterPOsByCurrency(pos []entity.PurchaseOrder, currency string) (string, []entity.PurchaseOrder, error) {
+	if currency != "" {
+		currency = normalizeCurrency(currency)
+		var filtered []entity.PurchaseOrder
+		for _, po := range pos {
+			if normalizeCurrency(po.Currency) == currency {
+				filtered = append(filtered, po)
+			}
+		}
+		return currency, filtered, nil
+	}
+
+	seen := make(map[string]bool)
+	var currencies []string
+	for _, po := range pos {
+		c := normalizeCurrency(po.Currency)
+		if !seen[c] {
+			seen[c] = true
+			currencies = append(currencies, c)
+		}
+	}
+	switch len(currencies) {
+	case 0:
+		return entity.FunctionalCurrency, pos, nil
+	case 1:
+		return currencies[0], pos, nil
+	default:
+		sort.Strings(currencies)
+		return "", nil, fmt.Errorf("期间内PO涉及多个币种(%s)，请指定币种分别生成对账单", strings.Join(currencies, ","))
+	}
+}
+
+// poLockedRate PO审批时锁定的汇率；历史PO未锁定时按审批日（或创建日）汇率补取
+func (s *SettlementService) poLockedRate(ctx context.Context, po *entity.PurchaseOrder) (float64, error) {
+	if po.ExchangeRate != nil && *po.ExchangeRate > 0 {
+		return *po.ExchangeRate, nil
+	}
+	if normalizeCurrency(po.Currency) == entity.FunctionalCurrency {
+		return 1, nil
+	}
+	if s.fxSvc == nil {
+		return 0, fmt.Errorf("PO %s 为外币(%s)且未锁定汇率", po.POCode, po.Currency)
+	}
+	at := po.CreatedAt
+	if po.ApprovedAt != nil {
+		at = *po.ApprovedAt
+	}
+	return s.fxSvc.ToFunctional(ctx, po.Currency, at)
+}
+
+// applyFX 按结算日汇率折算本位币金额，并与PO锁定汇率入账金额比较得出已实现汇兑损益
+// 扣款按同一结算汇率折算，不产生汇兑差异
+func (s *SettlementService) applyFX(ctx context.Context, st *entity.Settlement, lines []entity.SettlementLine, at time.Time) error {
+	st.Currency = normalizeCurrency(st.Currency)
+	st.FunctionalCurrency = entity.FunctionalCurrency
+
+	rate := 1.0
+	if st.Currency != entity.FunctionalCurrency {
+		if s.fxSvc == nil {
+			return fmt.Errorf("外币对账单(%s)需要汇率服务", st.Currency)
+		}
+		var err error
+		rate, err = s.fxSvc.ToFunctional(ctx, st.Currency, at)
+		if err != nil {
+			return err
+		}
+	}
+
+	var booked float64
+	for _, line := range lines {
+		booked += line.BookedAmount
+	}
+	deduction := 0.0
+	if st.Deduction != nil {
+		deduction = *st.Deduction
+	}
+	final := 0.0
+	if st.FinalAmount != nil {
+		final = *st.FinalAmount
+	}
+	functional := roundAmount(final * rate)
+	if len(lines) == 0 {
+		// 手工对账单无行项，无从追溯入账汇率
+		booked = functional
+	} else {
+		booked = roundAmount(booked - deduction*rate)
+	}
+	fxDiff := roundAmount(functional - booked)
+
+	st.ExchangeRate = &rate
+	st.FunctionalAmount = &functional
+	st.BookedFunctionalAmount = &booked
+	st.FXDiff = &fxDiff
+	return nil
+}
+
 // Update 更新对账单
 func (s *SettlementService) Update(ctx context.Context, id string, req *UpdateSettlementRequest) (*entity.Settlement, error) {
 	settlement, err := s.repo.FindByID(ctx, id)
@@ -179,10 +349,17 @@ func (s *SettlementService) Update(ctx context.Context, id string, req *UpdateSe
 	if req.Deduction != nil {
 		settlement.Deduction = req.Deduction
 		// 重新计算最终金额
-		if settlement.POAmount != nil {
-			final := *settlement.POAmount - *req.Deduction
+		base := settlement.POAmount
+		if len(settlement.Lines) > 0 {
+			base = settlement.ReceivedAmount
+		}
+		if base != nil {
+			final := *base - *req.Deduction
 			settlement.FinalAmount = &final
 		}
+		if err := s.applyFX(ctx, settlement, settlement.Lines, time.Now()); err != nil {
+			return nil, err
+		}
 	}
 	if req.Notes != nil {
 		settlement.Notes = *req.Notes
@@ -215,10 +392,17 @@ func (s *SettlementService) ConfirmByBuyer(ctx context.Context, id string) (*ent
 	if settlement.Status != "draft" {
 		return nil, errors.New("当前状态不允许确认")
 	}
+	if err := checkLinesMatched(settlement); err != nil {
+		return nil, err
+	}
 
 	settlement.ConfirmedByBuyer = true
 	if settlement.ConfirmedBySupplier {
 		now := time.Now()
+		// 双方确认时锁定结算汇率
+		if err := s.applyFX(ctx, settlement, settlement.Lines, now); err != nil {
+			return nil, err
+		}
 		settlement.Status = "confirmed"
 		settlement.ConfirmedAt = &now
 	}
@@ -229,6 +413,16 @@ func (s *SettlementService) ConfirmByBuyer(ctx context.Context, id string) (*ent
 	return settlement, nil
 }
 
+// checkLinesMatched 对账行须全部匹配通过或豁免才能确认
+func checkLinesMatched(settlement *entity.Settlement) error {
+	for _, line := range settlement.Lines {
+		if line.MatchStatus != entity.MatchStatusMatched && line.MatchStatus != entity.MatchStatusWaived {
+			return fmt.Errorf("对账行 %s/%s 尚未匹配通过或豁免", line.POCode, line.MaterialCode)
+		}
+	}
+	return nil
+}
+
 // ConfirmBySupplier 供应商确认
 func (s *SettlementService) ConfirmBySupplier(ctx context.Context, id string) (*entity.Settlement, error) {
 	settlement, err := s.repo.FindByID(ctx, id)
@@ -238,10 +432,20 @@ func (s *SettlementService) ConfirmBySupplier(ctx context.Context, id string) (*
 	if settlement.Status != "draft" {
 		return nil, errors.New("当前状态不允许确认")
 	}
+	// 采购方确认后仍可能补录发票并重新匹配，双方确认生效前须再次校验
+	if settlement.ConfirmedByBuyer {
+		if err := checkLinesMatched(settlement); err != nil {
+			return nil, err
+		}
+	}
 
 	settlement.ConfirmedBySupplier = true
 	if settlement.ConfirmedByBuyer {
 		now := time.Now()
+		// 双方确认时锁定结算汇率
+		if err := s.applyFX(ctx, settlement, settlement.Lines, now); err != nil {
+			return nil, err
+		}
 		settlement.Status = "confirmed"
 		settlement.ConfirmedAt = &now
 	}
@@ -297,6 +501,7 @@ func (s *SettlementService) AddDispute(ctx context.Context, settlementID string,
 		DisputeType:  req.DisputeType,
 		Description:  req.Description,
 		AmountDiff:   req.AmountDiff,
+		Source:       entity.DisputeSourceManual,
 		Status:       "open",
 	}
 
@@ -320,8 +525,311 @@ func (s *SettlementService) UpdateDispute(ctx context.Context, disputeID string,
 		dispute.Resolution = *req.Resolution
 	}
 
-	if err := s.repo.UpdateDispute(ctx, dispute); err != nil {
+	if err := s.repo.UpdateDisputeAndSyncLine(ctx, dispute); err != nil {
 		return nil, err
 	}
 	return dispute, nil
 }
+
+// ========== 三单匹配 ==========
+
+// 未配置容差时的默认值：数量、单价须一致，金额允许0.01的舍入误差
+const defaultAmountTolerance = 0.01
+
+// InvoiceLineInput 发票行
+type InvoiceLineInput struct {
+	POItemID  string  `json:"po_item_id" binding:"required"`
+	Quantity  float64 `json:"quantity" binding:"required"`
+	UnitPrice float64 `json:"unit_price" binding:"required"`
+}
+
+// AddInvoiceLinesRequest 录入发票请求（同一发票号重复提交时整体替换）
+type AddInvoiceLinesRequest struct {
+	InvoiceNo  string             `json:"invoice_no" binding:"required"`
+	InvoiceURL string             `json:"invoice_url"`
+	Lines      []InvoiceLineInput `json:"lines" binding:"required,min=1"`
+}
+
+// WaiveLineRequest 豁免对账行请求
+type WaiveLineRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// SaveToleranceRequest 保存匹配容差请求（supplier_id为空表示全局）
+type SaveToleranceRequest struct {
+	SupplierID        *string `json:"supplier_id"`
+	QtyTolerancePct   float64 `json:"qty_tolerance_pct"`
+	PriceTolerancePct float64 `json:"price_tolerance_pct"`
+	AmountTolerance   float64 `json:"amount_tolerance"`
+}
+
+// AddInvoiceLines 录入供应商发票行并重新匹配
+func (s *SettlementService) AddInvoiceLines(ctx context.Context, userID, settlementID string, req *AddInvoiceLinesRequest) (*entity.Settlement, error) {
+	settlement, err := s.repo.FindByID(ctx, settlementID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement.Status != entity.SettlementStatusDraft {
+		return nil, errors.New("只能为草稿状态的对账单录入发票")
+	}
+
+	lineItems := make(map[string]bool, len(settlement.Lines))
+	for _, line := range settlement.Lines {
+		lineItems[line.POItemID] = true
+	}
+
+	invoiceLines := make([]entity.SettlementInvoiceLine, 0, len(req.Lines))
+	for _, in := range req.Lines {
+		if !lineItems[in.POItemID] {
+			return nil, fmt.Errorf("PO行项 %s 不在该对账单中", in.POItemID)
+		}
+		if in.Quantity <= 0 || in.UnitPrice < 0 {
+			return nil, errors.New("发票数量必须大于0且单价不能为负")
+		}
+		invoiceLines = append(invoiceLines, entity.SettlementInvoiceLine{
+			ID:           uuid.New().String()[:32],
+			SettlementID: settlementID,
+			InvoiceNo:    req.InvoiceNo,
+			POItemID:     in.POItemID,
+			Quantity:     in.Quantity,
+			UnitPrice:    in.UnitPrice,
+			Amount:       roundAmount(in.Quantity * in.UnitPrice),
+			CreatedBy:    userID,
+		})
+	}
+
+	if req.InvoiceURL != "" {
+		settlement.InvoiceURL = req.InvoiceURL
+	}
+	if err := s.repo.ReplaceInvoice(ctx, settlement, req.InvoiceNo, invoiceLines); err != nil {
+		return nil, err
+	}
+	return s.Match(ctx, settlementID)
+}
+
+// Match 执行三单匹配：PO单价/数量 vs 入库数量 vs 发票行，超出容差自动生成差异记录
+func (s *SettlementService) Match(ctx context.Context, settlementID string) (*entity.Settlement, error) {
+	settlement, err := s.repo.FindByID(ctx, settlementID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement.Status != entity.SettlementStatusDraft {
+		return nil, errors.New("只能对草稿状态的对账单执行匹配")
+	}
+
+	tol, err := s.resolveTolerance(ctx, settlement.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	itemIDs := make([]string, 0, len(settlement.Lines))
+	for _, line := range settlement.Lines {
+		itemIDs = append(itemIDs, line.POItemID)
+	}
+	received, err := s.repo.SumReceivedByPOItems(ctx, itemIDs, settlement.PeriodStart, settlement.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	type invoiceAgg struct{ qty, amount float64 }
+	invoiced := make(map[string]*invoiceAgg)
+	invoiceNos := make(map[string]bool)
+	var invoiceTotal float64
+	for _, il := range settlement.InvoiceLines {
+		agg, ok := invoiced[il.POItemID]
+		if !ok {
+			agg = &invoiceAgg{}
+			invoiced[il.POItemID] = agg
+		}
+		agg.qty += il.Quantity
+		agg.amount += il.Amount
+		invoiceNos[il.InvoiceNo] = true
+		invoiceTotal += il.Amount
+	}
+
+	now := time.Now()
+	var receivedAmount float64
+	lineDisputes := make(map[string][]entity.SettlementDispute)
+	for i := range settlement.Lines {
+		line := &settlement.Lines[i]
+		if qty, ok := received[line.POItemID]; ok {
+			line.ReceivedQty = qty
+			line.ReceivedAmount = roundAmount(qty * line.UnitPrice)
+			line.BookedAmount = roundAmount(qty * line.UnitPrice * line.LockedRate)
+		}
+		receivedAmount += line.ReceivedAmount
+
+		if line.MatchStatus == entity.MatchStatusWaived {
+			continue
+		}
+
+		agg := invoiced[line.POItemID]
+		if agg == nil {
+			agg = &invoiceAgg{}
+		}
+		line.InvoicedQty = agg.qty
+		line.InvoicedAmount = roundAmount(agg.amount)
+		line.InvoicedUnitPrice = 0
+		if agg.qty > 0 {
+			line.InvoicedUnitPrice = agg.amount / agg.qty
+		}
+
+		var disputes []entity.SettlementDispute
+		switch {
+		case agg.qty == 0:
+			// 未开票（已收货等待发票，或既无收货也无发票），不能视为已匹配
+			line.MatchStatus = entity.MatchStatusPending
+			line.QtyDiff, line.PriceDiff, line.AmountDiff = 0, 0, 0
+		default:
+			line.QtyDiff = line.InvoicedQty - line.ReceivedQty
+			line.PriceDiff = 0
+			if agg.qty > 0 {
+				line.PriceDiff = line.InvoicedUnitPrice - line.UnitPrice
+			}
+			line.AmountDiff = roundAmount(line.InvoicedAmount - line.ReceivedAmount)
+			disputes = matchDisputes(settlement.ID, line, tol)
+			if len(disputes) == 0 {
+				line.MatchStatus = entity.MatchStatusMatched
+			} else {
+				line.MatchStatus = entity.MatchStatusMismatch
+			}
+		}
+		line.MatchedAt = &now
+		lineDisputes[line.ID] = disputes
+	}
+
+	receivedAmount = roundAmount(receivedAmount)
+	settlement.ReceivedAmount = &receivedAmount
+	final := receivedAmount
+	if settlement.Deduction != nil {
+		final -= *settlement.Deduction
+	}
+	settlement.FinalAmount = &final
+	if len(invoiceNos) > 0 {
+		nos := make([]string, 0, len(invoiceNos))
+		for no := range invoiceNos {
+			nos = append(nos, no)
+		}
+		sort.Strings(nos)
+		settlement.InvoiceNo = strings.Join(nos, ",")
+		total := roundAmount(invoiceTotal)
+		settlement.InvoiceAmount = &total
+	}
+	if err := s.applyFX(ctx, settlement, settlement.Lines, now); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveMatchResult(ctx, settlement, settlement.Lines, lineDisputes); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, settlementID)
+}
+
+// matchDisputes 按数量差、单价差分别判断是否超出容差，超差的各生成一条差异（两者金额之和等于行金额差）。
+// 数量与单价的偏差可能相互抵消，不能只看行金额差；金额容差仅用于忽略舍入误差。
+// 未收货却已开票时数量差按全额超差处理。
+func matchDisputes(settlementID string, line *entity.SettlementLine, tol *entity.MatchTolerance) []entity.SettlementDispute {
+	var disputes []entity.SettlementDispute
+	lineID := line.ID
+	qtyAmount := roundAmount(line.QtyDiff * line.UnitPrice)
+	if math.Abs(line.QtyDiff) > line.ReceivedQty*tol.QtyTolerancePct/100 &&
+		(line.ReceivedQty == 0 || math.Abs(qtyAmount) > tol.AmountTolerance) {
+		disputes = append(disputes, entity.SettlementDispute{
+			ID:               uuid.New().String()[:32],
+			SettlementID:     settlementID,
+			SettlementLineID: &lineID,
+			DisputeType:      entity.DisputeTypeQuantityDiff,
+			Source:           entity.DisputeSourceMatch,
+			Description: fmt.Sprintf("%s %s 发票数量 %.2f，入库数量 %.2f",
+				line.POCode, line.MaterialCode, line.InvoicedQty, line.ReceivedQty),
+			AmountDiff: &qtyAmount,
+			Status:     entity.DisputeStatusOpen,
+		})
+	}
+	priceAmount := roundAmount(line.PriceDiff * line.InvoicedQty)
+	if math.Abs(line.PriceDiff) > line.UnitPrice*tol.PriceTolerancePct/100 &&
+		math.Abs(priceAmount) > tol.AmountTolerance {
+		disputes = append(disputes, entity.SettlementDispute{
+			ID:               uuid.New().String()[:32],
+			SettlementID:     settlementID,
+			SettlementLineID: &lineID,
+			DisputeType:      entity.DisputeTypePriceDiff,
+			Source:           entity.DisputeSourceMatch,
+			Description: fmt.Sprintf("%s %s 发票单价 %.4f，PO单价 %.4f",
+				line.POCode, line.MaterialCode, line.InvoicedUnitPrice, line.UnitPrice),
+			AmountDiff: &priceAmount,
+			Status:     entity.DisputeStatusOpen,
+		})
+	}
+	return disputes
+}
+
+// WaiveLine 豁免对账行（同时关闭其未解决的差异）
+func (s *SettlementService) WaiveLine(ctx context.Context, userID, settlementID, lineID string, req *WaiveLineRequest) (*entity.SettlementLine, error) {
+	settlement, err := s.repo.FindByID(ctx, settlementID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement.Status != entity.SettlementStatusDraft {
+		return nil, errors.New("只能豁免草稿状态对账单的行项")
+	}
+	line, err := s.repo.FindLineByID(ctx, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if line.SettlementID != settlementID {
+		return nil, repository.ErrNotFound
+	}
+
+	now := time.Now()
+	line.MatchStatus = entity.MatchStatusWaived
+	line.WaivedBy = userID
+	line.WaivedAt = &now
+	line.WaiveReason = req.Reason
+	if err := s.repo.UpdateLine(ctx, line); err != nil {
+		return nil, err
+	}
+	if err := s.repo.ResolveLineDisputes(ctx, lineID, "已豁免: "+req.Reason); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// ListTolerances 匹配容差列表
+func (s *SettlementService) ListTolerances(ctx context.Context) ([]entity.MatchTolerance, error) {
+	return s.repo.ListTolerances(ctx)
+}
+
+// SaveTolerance 保存匹配容差
+func (s *SettlementService) SaveTolerance(ctx context.Context, userID string, req *SaveToleranceRequest) (*entity.MatchTolerance, error) {
+	if req.QtyTolerancePct < 0 || req.PriceTolerancePct < 0 || req.AmountTolerance < 0 {
+		return nil, errors.New("容差不能为负数")
+	}
+	tol := &entity.MatchTolerance{
+		ID:                uuid.New().String()[:32],
+		SupplierID:        emptyToNil(req.SupplierID),
+		QtyTolerancePct:   req.QtyTolerancePct,
+		PriceTolerancePct: req.PriceTolerancePct,
+		AmountTolerance:   req.AmountTolerance,
+		UpdatedBy:         userID,
+	}
+	if err := s.repo.SaveTolerance(ctx, tol); err != nil {
+		return nil, err
+	}
+	return tol, nil
+}
+
+// resolveTolerance 供应商容差 > 全局容差 > 默认值
+func (s *SettlementService) resolveTolerance(ctx context.Context, supplierID string) (*entity.MatchTolerance, error) {
+	tol, err := s.repo.FindTolerance(ctx, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	if tol == nil {
+		tol = &entity.MatchTolerance{AmountTolerance: defaultAmountTolerance}
+	}
+	return tol, nil
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -2,27 +2,44 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/bitfantasy/nimo/internal/srm/entity"
 	"github.com/bitfantasy/nimo/internal/srm/repository"
 	"github.com/google/uuid"
 )
 
+// ErrSupplierLifecycleField 等级/状态不允许直接编辑
+var ErrSupplierLifecycleField = errors.New("供应商等级和状态需通过准入审核或状态变更操作修改")
+
 // SupplierService 供应商服务
 type SupplierService struct {
-	repo *repository.SupplierRepository
+	repo              *repository.SupplierRepository
+	qualificationRepo *repository.QualificationRepository
+	activityLogRepo   *repository.ActivityLogRepository
 }
 
 func NewSupplierService(repo *repository.SupplierRepository) *SupplierService {
 	return &SupplierService{repo: repo}
 }
 
+// SetQualificationRepo 注入准入/证书仓库（恢复启用时校验证书）
+func (s *SupplierService) SetQualificationRepo(repo *repository.QualificationRepository) {
+	s.qualificationRepo = repo
+}
+
+// SetActivityLogRepo 注入操作日志仓库
+func (s *SupplierService) SetActivityLogRepo(repo *repository.ActivityLogRepository) {
+	s.activityLogRepo = repo
+}
+
 // CreateSupplierRequest 创建供应商请求
 type CreateSupplierRequest struct {
 	Name           string              `json:"name" binding:"required"`
 	ShortName      string              `json:"short_name"`
 	Category       string              `json:"category" binding:"required"`
-	Level          string              `json:"level"`
 	Country        string              `json:"country"`
 	Province       string              `json:"province"`
 	City           string              `json:"city"`
@@ -95,7 +112,7 @@ func (s *SupplierService) Create(ctx context.Context, userID string, req *Create
 		Name:           req.Name,
 		ShortName:      req.ShortName,
 		Category:       req.Category,
-		Level:          req.Level,
+		Level:          entity.SupplierLevelPotential,
 		Status:         entity.SupplierStatusPending,
 		Country:        req.Country,
 		Province:       req.Province,
@@ -119,10 +136,6 @@ func (s *SupplierService) Create(ctx context.Context, userID string, req *Create
 		CreatedBy:      userID,
 	}
 
-	if supplier.Level == "" {
-		supplier.Level = entity.SupplierLevelPotential
-	}
-
 	if err := s.repo.Create(ctx, supplier); err != nil {
 		return nil, err
 	}
@@ -145,11 +158,9 @@ func (s *SupplierService) Update(ctx context.Context, id string, req *UpdateSupp
 	if req.Category != nil {
 		supplier.Category = *req.Category
 	}
-	if req.Level != nil {
-		supplier.Level = *req.Level
-	}
-	if req.Status != nil {
-		supplier.Status = *req.Status
+	// 等级由准入会签/评估结果决定，状态走生命周期流转，这里只允许原样回传
+	if (req.Level != nil && *req.Level != supplier.Level) || (req.Status != nil && *req.Status != supplier.Status) {
+		return nil, ErrSupplierLifecycleField
 	}
 	if req.Country != nil {
 		supplier.Country = *req.Country
@@ -215,6 +226,58 @@ func (s *SupplierService) Update(ctx context.Context, id string, req *UpdateSupp
 	return supplier, nil
 }
 
+// ChangeStatusRequest 供应商状态变更请求
+type ChangeStatusRequest struct {
+	Status string `json:"status" binding:"required"` // active/suspended/blacklisted
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ChangeStatus 供应商生命周期状态变更（暂停/恢复/拉黑）；启用只能由准入会签完成
+func (s *SupplierService) ChangeStatus(ctx context.Context, id, userID string, req *ChangeStatusRequest) (*entity.Supplier, error) {
+	supplier, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, st := range entity.ValidSupplierStatusTransitions[supplier.Status] {
+		if st == req.Status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("不允许从 %s 变更为 %s", supplier.Status, req.Status)
+	}
+
+	if req.Status == entity.SupplierStatusActive {
+		if supplier.Level == entity.SupplierLevelPotential {
+			return nil, errors.New("供应商未通过准入，不能启用")
+		}
+		if s.qualificationRepo != nil {
+			expired, err := s.qualificationRepo.CountExpiredCertificates(ctx, id, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			if expired > 0 {
+				return nil, fmt.Errorf("供应商有 %d 张资质证书已过期，请更新后再恢复启用", expired)
+			}
+		}
+	}
+
+	fromStatus := supplier.Status
+	if err := s.repo.UpdateStatus(ctx, id, req.Status); err != nil {
+		return nil, err
+	}
+	supplier.Status = req.Status
+
+	if s.activityLogRepo != nil {
+		s.activityLogRepo.LogActivity(ctx, "supplier", supplier.ID, supplier.Code, "status_change",
+			fromStatus, req.Status, req.Reason, userID, "")
+	}
+	return supplier, nil
+}
+
 // Delete 删除供应商
 func (s *SupplierService) Delete(ctx context.Context, id string) error {
 	return s.repo.Delete(ctx, id)
@@ -406,6 +406,11 @@ export const srmApi = {
     return response.data.data;
   },
 
+  changeSupplierStatus: async (id: string, status: string, reason: string): Promise<Supplier> => {
+    const response = await apiClient.post<ApiResponse<Supplier>>(`/srm/suppliers/${id}/status`, { status, reason });
+    return response.data.data;
+  },
+
   deleteSupplier: async (id: string): Promise<void> => {
     await apiClient.delete(`/srm/suppliers/${id}`);
   },
@@ -55,6 +55,7 @@ const levelOptions = [
   { value: 'qualified', label: '合格' },
   { value: 'preferred', label: '优选' },
   { value: 'strategic', label: '战略' },
+  { value: 'probation', label: '考察期' },
 ];
 
 const levelColors: Record<string, string> = {
@@ -62,6 +63,7 @@ const levelColors: Record<string, string> = {
   qualified: 'blue',
   preferred: 'green',
   strategic: 'gold',
+  probation: 'orange',
 };
 
 const levelLabels: Record<string, string> = {
@@ -69,6 +71,7 @@ const levelLabels: Record<string, string> = {
   qualified: '合格',
   preferred: '优选',
   strategic: '战略',
+  probation: '考察期',
 };
 
 const statusOptions = [
@@ -94,7 +97,6 @@ const SupplierFormFields: React.FC<{ compact?: boolean }> = ({ compact }) => {
         <Form.Item name="name" label="供应商名称" rules={[{ required: true, message: '请输入供应商名称' }]}><Input placeholder="请输入供应商名称" /></Form.Item>
         <Form.Item name="short_name" label="简称"><Input placeholder="请输入简称" /></Form.Item>
         <Form.Item name="category" label="分类" rules={[{ required: true, message: '请选择分类' }]}><Select placeholder="请选择分类" options={categoryOptions} /></Form.Item>
-        <Form.Item name="level" label="等级"><Select placeholder="请选择等级" options={levelOptions} allowClear /></Form.Item>
         <Form.Item name="city" label="城市"><Input placeholder="深圳" /></Form.Item>
         <Form.Item name="business_scope" label="业务范围"><Input.TextArea rows={2} placeholder="主营业务描述" /></Form.Item>
       </>
@@ -111,9 +113,6 @@ const SupplierFormFields: React.FC<{ compact?: boolean }> = ({ compact }) => {
       <Form.Item name="category" label="分类" rules={[{ required: true, message: '请选择分类' }]}>
         <Select placeholder="请选择分类" options={categoryOptions} />
       </Form.Item>
-      <Form.Item name="level" label="等级">
-        <Select placeholder="请选择等级" options={levelOptions} allowClear />
-      </Form.Item>
       <Space style={{ width: '100%' }} size="middle">
         <Form.Item name="country" label="国家" style={{ width: 180 }}>
           <Input placeholder="中国" />
@@ -219,7 +218,8 @@ const Suppliers: React.FC = () => {
   });
 
   const statusMutation = useMutation({
-    mutationFn: ({ id, status }: { id: string; status: string }) => srmApi.updateSupplier(id, { status }),
+    mutationFn: ({ id, status, reason }: { id: string; status: string; reason: string }) =>
+      srmApi.changeSupplierStatus(id, status, reason),
     onSuccess: () => {
       message.success('状态更新成功');
       queryClient.invalidateQueries({ queryKey: ['srm-suppliers'] });
@@ -227,9 +227,27 @@ const Suppliers: React.FC = () => {
         queryClient.invalidateQueries({ queryKey: ['srm-supplier', currentSupplier.id] });
       }
     },
-    onError: () => message.error('状态更新失败'),
+    onError: (err: any) => message.error(err?.response?.data?.message || '状态更新失败'),
   });
 
+  // 状态变更须填写原因，启用只能经准入审核完成
+  const confirmStatusChange = (id: string, status: string) => {
+    let reason = '';
+    Modal.confirm({
+      title: `变更状态为「${statusMap[status]?.text || status}」`,
+      content: <Input.TextArea rows={2} placeholder="请输入变更原因" onChange={(e) => { reason = e.target.value; }} />,
+      okText: '确认',
+      cancelText: '取消',
+      onOk: () => {
+        if (!reason.trim()) {
+          message.warning('请输入变更原因');
+          return Promise.reject();
+        }
+        return statusMutation.mutateAsync({ id, status, reason: reason.trim() });
+      },
+    });
+  };
+
   const deleteContactMutation = useMutation({
     mutationFn: (contactId: string) => srmApi.deleteContact(currentSupplier!.id, contactId),
     onSuccess: () => {
@@ -442,7 +460,7 @@ const Suppliers: React.FC = () => {
                 style={{ width: 110 }}
                 loading={statusMutation.isPending}
                 options={statusOptions}
-                onChange={(v) => statusMutation.mutate({ id: detail.id, status: v })}
+                onChange={(v) => confirmStatusChange(detail.id, v)}
               />
             </div>
             <Descriptions column={1} bordered size="small">
@@ -566,7 +584,7 @@ const Suppliers: React.FC = () => {
               style={{ width: 110 }}
               loading={statusMutation.isPending}
               options={statusOptions}
-              onChange={(v) => statusMutation.mutate({ id: detail.id, status: v })}
+              onChange={(v) => confirmStatusChange(detail.id, v)}
             />
             <Button type="primary" icon={<EditOutlined />} onClick={() => handleEdit(detail)}>编辑</Button>
             <Popconfirm title="确认删除该供应商？" onConfirm={() => deleteMutation.mutate(detail.id)}>